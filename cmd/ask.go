@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/embedding"
+	"github.com/sandwichlabs/agent-memory-graph/internal/llm"
+	"github.com/sandwichlabs/agent-memory-graph/internal/llm/prompts"
+	"github.com/sandwichlabs/agent-memory-graph/internal/retrieval"
+	"github.com/sandwichlabs/agent-memory-graph/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var askCmd = &cobra.Command{
+	Use:   "ask [question]",
+	Short: "Answer a question from the memory graph",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		question := strings.Join(args, " ")
+		showSources, _ := cmd.Flags().GetBool("show-sources")
+		noLLM, _ := cmd.Flags().GetBool("no-llm")
+
+		opts, err := searchOptions(cmd)
+		if err != nil {
+			return err
+		}
+		return ask(cmd.Context(), cmd.OutOrStdout(), memoryDir(cmd), embeddingProvider(cmd), llmProvider(cmd), question, opts, showSources, noLLM)
+	},
+}
+
+func init() {
+	addSearchFlags(askCmd)
+	askCmd.Flags().Bool("show-sources", false, "Print the retrieved source text under each citation")
+	askCmd.Flags().Bool("no-llm", false, "Only print the retrieved chunks, without generating an answer")
+	rootCmd.AddCommand(askCmd)
+}
+
+func ask(ctx context.Context, out io.Writer, dir string, embeddingProvider embedding.Provider, llmProvider llm.Provider, question string, opts retrieval.SearchOptions, showSources bool, noLLM bool) error {
+	if key := llm.MissingAPIKey(llmProvider); !noLLM && key != "" {
+		return fmt.Errorf("amg ask needs %s to generate an answer with the %s LLM provider (or use --no-llm)", key, llmProvider)
+	}
+	promptSet, err := prompts.Load(filepath.Join(dir, prompts.Dir))
+	if err != nil {
+		return err
+	}
+
+	results, err := search(ctx, "ask", dir, embeddingProvider, llmProvider, question, opts)
+	if err != nil {
+		return err
+	}
+	hits := results.Hits
+	if results.KeywordOnly {
+		fmt.Fprintln(out, keywordOnlyNote(embeddingProvider))
+		fmt.Fprintln(out)
+	}
+	if results.NoRelevantResults {
+		fmt.Fprintln(out, "I don't have information about that.")
+		return nil
+	}
+	if len(hits) == 0 {
+		fmt.Fprintln(out, "No relevant memories found.")
+		return nil
+	}
+
+	if noLLM {
+		for i, hit := range hits {
+			fmt.Fprintf(out, "[%d] %s (score %.3f)\n%s\n\n", i+1, citation(hit), hit.Score, hit.Content)
+		}
+		return nil
+	}
+
+	llmService, err := llm.NewLlmService(llmProvider)
+	if err != nil {
+		return fmt.Errorf("failed to create llm service: %w", err)
+	}
+
+	// Print the answer as it arrives; an interrupt stops generation but keeps the
+	// citations of what was printed.
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+	answer, err := retrieval.NewAnswerer(llmService).Answer(ctx, question, hits, retrieval.AnswerOptions{
+		Stream:  func(text string) { fmt.Fprint(out, text) },
+		Prompts: promptSet,
+	})
+	if err != nil && !answer.Partial {
+		return err
+	}
+	if len(answer.Dropped) > 0 {
+		slog.WarnContext(ctx, "dropped citations to context the model was not given", "citations", answer.Dropped)
+	}
+
+	fmt.Fprintln(out)
+	fmt.Fprintln(out)
+	if len(answer.Citations) > 0 {
+		fmt.Fprintln(out, "Sources:")
+		for _, cited := range answer.Citations {
+			fmt.Fprintf(out, "[%d] %s\n", cited.Number, citation(cited.Hit))
+			if showSources {
+				fmt.Fprintf(out, "    %s\n", strings.ReplaceAll(strings.TrimSpace(cited.Hit.Content), "\n", "\n    "))
+			}
+		}
+	}
+	fmt.Fprintf(out, "Confidence: %.2f\n", answer.Confidence)
+	return err
+}
+
+// search returns the most relevant chunks to text from the memory graph in dir,
+// by keyword alone when the embedding provider's API key is not set. command
+// names the calling subcommand in the missing-key error. The LLM provider is
+// only used for query expansion and routing.
+func search(ctx context.Context, command string, dir string, embeddingProvider embedding.Provider, llmProvider llm.Provider, text string, opts retrieval.SearchOptions) (retrieval.Results, error) {
+	retriever, store, err := openRetriever(command, dir, embeddingProvider, llmProvider, opts)
+	if err != nil {
+		return retrieval.Results{}, err
+	}
+	defer store.Close()
+	results, err := retriever.Retrieve(ctx, text, opts)
+	if route := results.Route; route != nil {
+		slog.DebugContext(ctx, "routed query", "route", route.Route, "entities", route.Entities, "confidence", route.Confidence,
+			"classifier", route.Classifier, "strategy", route.Strategy)
+	}
+	return results, err
+}
+
+// queryCache holds the query embeddings of every retriever openRetriever creates,
+// so that a command searching the same query twice, such as amg eval, embeds it
+// once.
+var queryCache = retrieval.NewQueryCache(0, 0)
+
+// openRetriever opens the memory graph in dir read-only and creates a retriever
+// for searches with opts. Without the embedding provider's API key the retriever
+// falls back to keyword search. The caller must close the store.
+func openRetriever(command string, dir string, embeddingProvider embedding.Provider, llmProvider llm.Provider, opts retrieval.SearchOptions) (*retrieval.Retriever, *storage.KuzuStore, error) {
+	if key := llm.MissingAPIKey(llmProvider); opts.Expansion != nil && key != "" {
+		return nil, nil, fmt.Errorf("amg %s --multi-query needs %s to rewrite the question with the %s LLM provider", command, key, llmProvider)
+	}
+
+	var embeddingService embedding.Service
+	if embedding.MissingAPIKey(embeddingProvider) == "" {
+		var err error
+		if embeddingService, err = embedding.New(embeddingProvider); err != nil {
+			return nil, nil, fmt.Errorf("failed to create embedding service: %w", err)
+		}
+	}
+	// Routing classifies queries with the LLM when it can, by heuristics otherwise.
+	var llmService llm.LlmService
+	var err error
+	if opts.Expansion != nil || (opts.Routing != nil && llm.MissingAPIKey(llmProvider) == "") {
+		if llmService, err = llm.NewLlmService(llmProvider); err != nil {
+			return nil, nil, fmt.Errorf("failed to create llm service: %w", err)
+		}
+	}
+
+	store, err := storage.Open(dir, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	if embeddingService != nil {
+		if err := retrieval.CheckEmbedder(context.Background(), store, embeddingService); err != nil {
+			store.Close()
+			return nil, nil, err
+		}
+	}
+	retriever := retrieval.NewRetriever(store, embeddingService)
+	if embeddingService != nil {
+		retriever.WithQueryCache(queryCache, string(embeddingProvider), embedding.ModelOf(embeddingService))
+	}
+	if llmService != nil {
+		retriever.WithLLM(llmService)
+	}
+	return retriever, store, nil
+}
+
+// keywordOnlyNote explains why results were ranked by keyword alone.
+func keywordOnlyNote(provider embedding.Provider) string {
+	return fmt.Sprintf("Keyword matches only: set %s to rank memories by meaning with the %s embedding provider.", embedding.MissingAPIKey(provider), provider)
+}
+
+// citation formats a hit as its source path and byte offsets, or its page for a
+// chunk of a PDF, whose offsets are into the text read from it.
+func citation(hit retrieval.Hit) string {
+	if hit.Page > 0 {
+		return fmt.Sprintf("%s (page %d)", hit.Source, hit.Page)
+	}
+	if hit.StartOffset < 0 {
+		return fmt.Sprintf("%s (chunk %d)", hit.Source, hit.Index)
+	}
+	return fmt.Sprintf("%s:%d-%d", hit.Source, hit.StartOffset, hit.EndOffset)
+}
@@ -0,0 +1,231 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/embedding"
+	"github.com/sandwichlabs/agent-memory-graph/internal/ingest"
+	"github.com/sandwichlabs/agent-memory-graph/internal/llm"
+	"github.com/sandwichlabs/agent-memory-graph/internal/storage"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// resetFlags restores every flag to its default so that runs within one test
+// binary do not leak state into each other through the global command tree.
+func resetFlags(cmd *cobra.Command) {
+	reset := func(f *pflag.Flag) {
+		// Setting a slice flag to its "[]" default would append the literal "[]".
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			slice.Replace(nil)
+		} else {
+			f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	}
+	cmd.Flags().VisitAll(reset)
+	cmd.PersistentFlags().VisitAll(reset)
+	for _, sub := range cmd.Commands() {
+		resetFlags(sub)
+	}
+}
+
+// runCLI executes amg with args and returns the exit code, stdout and stderr.
+func runCLI(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	resetFlags(rootCmd)
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(""), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+// newGraphDir creates an empty memory graph in a temporary directory.
+func newGraphDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	store, err := storage.Open(dir, false)
+	if err != nil {
+		t.Fatalf("Failed to create memory graph: %v", err)
+	}
+	store.Close()
+	return dir
+}
+
+func TestRun_UsageErrors(t *testing.T) {
+	dir := t.TempDir()
+	cases := [][]string{
+		{"ingest"},
+		{"-d", dir, "entities", "list", "--bogus"},
+		{"-d", dir, "entities", "show"},
+		{"-d", dir, "graph"},
+		{"completion", "tcsh"},
+		{"--log-level", "loud", "doctor"},
+		{"-d", dir, "query", "kuzu", "--since", "yesterday"},
+		{"-d", dir, "query", "kuzu", "--half-life", "soon"},
+		{"-d", dir, "query", "kuzu", "--snippet-length", "-1"},
+		{"-d", dir, "query", "kuzu", "--context"},
+		{"-d", dir, "query", "kuzu", "--group", "--group-size", "0"},
+		{"-d", dir, "ask", "kuzu", "--document", "d1", "--collection", "notes"},
+	}
+	for _, args := range cases {
+		code, stdout, stderr := runCLI(t, args...)
+		if code != exitUsage {
+			t.Errorf("%v: Expected exit code %d, got %d (stderr: %s)", args, exitUsage, code, stderr)
+		}
+		if stdout != "" {
+			t.Errorf("%v: Expected no stdout, got %q", args, stdout)
+		}
+		if !strings.Contains(stderr, "--help' for usage") {
+			t.Errorf("%v: Expected a usage hint on stderr, got %q", args, stderr)
+		}
+	}
+}
+
+func TestRun_FailuresGoToStderr(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("MISTRAL_API_KEY", "")
+	brokenFile := filepath.Join(dir, "broken.file")
+	if err := os.WriteFile(brokenFile, []byte("text"), 0o644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	cases := []struct {
+		args   []string
+		stderr string
+	}{
+		{[]string{"-d", dir, "ingest", filepath.Join(dir, "missing.txt")}, "no files matched"},
+		{[]string{"-d", dir, "ingest", brokenFile}, "MISTRAL_API_KEY"},
+		{[]string{"-d", dir, "entities", "list"}, "memory graph database not found"},
+	}
+	for _, c := range cases {
+		code, stdout, stderr := runCLI(t, c.args...)
+		if code != exitFailure {
+			t.Errorf("%v: Expected exit code %d, got %d", c.args, exitFailure, code)
+		}
+		if stdout != "" {
+			t.Errorf("%v: Expected no stdout, got %q", c.args, stdout)
+		}
+		if !strings.HasPrefix(stderr, "Error: ") || !strings.Contains(stderr, c.stderr) {
+			t.Errorf("%v: Expected stderr to report %q, got %q", c.args, c.stderr, stderr)
+		}
+	}
+}
+
+func TestRun_LlmProviderSelection(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("MISTRAL_API_KEY", "")
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv(llm.EnvProvider, "OpenAI")
+	file := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(file, []byte("text"), 0o644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	cases := []struct {
+		args   []string
+		stderr string
+	}{
+		{[]string{"-d", dir, "ingest", file}, "OPENAI_API_KEY"},
+		{[]string{"-d", dir, "--llm-provider", "mistral", "ingest", file}, "MISTRAL_API_KEY"},
+		{[]string{"-d", dir, "--llm-provider", "mistal", "ingest", file}, `unknown LLM provider "mistal" (did you mean "mistral"?)`},
+	}
+	for _, c := range cases {
+		code, _, stderr := runCLI(t, c.args...)
+		if code != exitFailure {
+			t.Errorf("%v: Expected exit code %d, got %d", c.args, exitFailure, code)
+		}
+		if !strings.Contains(stderr, c.stderr) {
+			t.Errorf("%v: Expected stderr to report %q, got %q", c.args, c.stderr, stderr)
+		}
+	}
+}
+
+func TestRun_EmbeddingProviderSelection(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("MISTRAL_API_KEY", "")
+	t.Setenv("GEMINI_API_KEY", "")
+	t.Setenv(llm.EnvProvider, "testing")
+	file := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(file, []byte("text"), 0o644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	cases := []struct {
+		env    string
+		args   []string
+		stderr string
+	}{
+		{"", []string{"-d", dir, "ingest", file}, "MISTRAL_API_KEY"},
+		{"Gemini", []string{"-d", dir, "ingest", file}, "GEMINI_API_KEY"},
+		{"Gemini", []string{"-d", dir, "--embedding-provider", "mistral", "ingest", file}, "MISTRAL_API_KEY"},
+		{"", []string{"-d", dir, "--embedding-provider", "gemnii", "ingest", file}, `unknown embedding provider "gemnii" (did you mean "gemini"?)`},
+	}
+	for _, c := range cases {
+		t.Setenv(embedding.EnvProvider, c.env)
+		code, _, stderr := runCLI(t, c.args...)
+		if code != exitFailure {
+			t.Errorf("%v: Expected exit code %d, got %d", c.args, exitFailure, code)
+		}
+		if !strings.Contains(stderr, c.stderr) {
+			t.Errorf("%v: Expected stderr to report %q, got %q", c.args, c.stderr, stderr)
+		}
+	}
+}
+
+func TestRun_Success(t *testing.T) {
+	dir := newGraphDir(t)
+
+	code, stdout, stderr := runCLI(t, "-d", dir, "entities", "list", "--json")
+	if code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d (stderr: %s)", exitOK, code, stderr)
+	}
+	if strings.TrimSpace(stdout) != "[]" {
+		t.Errorf("Expected an empty JSON list, got %q", stdout)
+	}
+
+	code, stdout, _ = runCLI(t, "completion", "bash")
+	if code != exitOK {
+		t.Errorf("Expected exit code %d, got %d", exitOK, code)
+	}
+	if !strings.Contains(stdout, "bash completion") {
+		t.Errorf("Expected a bash completion script, got %q", stdout)
+	}
+}
+
+func TestRun_ClosesLogFile(t *testing.T) {
+	dir := newGraphDir(t)
+	path := filepath.Join(t.TempDir(), "amg.log")
+
+	code, _, stderr := runCLI(t, "-d", dir, "--log-file", path, "entities", "list")
+	if code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d (stderr: %s)", exitOK, code, stderr)
+	}
+	if logFile != nil {
+		t.Errorf("Expected the log file to be closed when the command returns")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Expected the log file to be created, got %v", err)
+	}
+}
+
+func TestIngestError_ExitCodes(t *testing.T) {
+	failure := errors.New("boom")
+	cases := []struct {
+		results []ingest.Result
+		code    int
+	}{
+		{[]ingest.Result{{Source: "a"}, {Source: "b"}}, exitOK},
+		{[]ingest.Result{{Source: "a"}, {Source: "b", Err: failure}}, exitPartial},
+		{[]ingest.Result{{Source: "a", Err: failure}, {Source: "b", Err: failure}}, exitFailure},
+	}
+	for _, c := range cases {
+		if code := exitCode(ingestError(ingest.Report{Results: c.results})); code != c.code {
+			t.Errorf("Expected exit code %d for %+v, got %d", c.code, c.results, code)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"github.com/sandwichlabs/agent-memory-graph/internal/embedding"
+	"github.com/sandwichlabs/agent-memory-graph/internal/llm"
+	"github.com/sandwichlabs/agent-memory-graph/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var completionCmd = &cobra.Command{
+	Use:   "completion [bash|zsh|fish|powershell]",
+	Short: "Generate a shell completion script",
+	Long: `Generate a shell completion script for amg.
+
+  bash:       source <(amg completion bash)
+  zsh:        amg completion zsh > "${fpath[1]}/_amg"
+  fish:       amg completion fish > ~/.config/fish/completions/amg.fish
+  powershell: amg completion powershell | Out-String | Invoke-Expression`,
+	DisableFlagsInUseLine: true,
+	ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+	Args:                  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := cmd.OutOrStdout()
+		switch args[0] {
+		case "bash":
+			return cmd.Root().GenBashCompletionV2(out, true)
+		case "zsh":
+			return cmd.Root().GenZshCompletion(out)
+		case "fish":
+			return cmd.Root().GenFishCompletion(out, true)
+		default:
+			return cmd.Root().GenPowerShellCompletionWithDesc(out)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(completionCmd)
+}
+
+// completeCollections lists the collections in the resolved database. Completion must
+// never fail loudly, so a missing or unreadable database yields no suggestions.
+func completeCollections(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	store, err := storage.Open(memoryDir(cmd), true)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	defer store.Close()
+
+	collections, err := store.Collections(cmd.Context())
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return collections, cobra.ShellCompDirectiveNoFileComp
+}
+
+func completeEmbeddingProviders(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	var names []string
+	for _, provider := range embedding.Providers() {
+		names = append(names, string(provider))
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+func completeLlmProviders(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	var names []string
+	for _, provider := range llm.Providers() {
+		names = append(names, string(provider))
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package cmd
+
+import "syscall"
+
+// freeDiskSpace returns the bytes available to the current user on the filesystem holding dir.
+func freeDiskSpace(dir string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, err
+	}
+	return stat.Bavail * uint64(stat.Bsize), nil
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package cmd
+
+import "errors"
+
+// freeDiskSpace is not implemented on Windows; doctor reports the check as a warning.
+func freeDiskSpace(dir string) (uint64, error) {
+	return 0, errors.New("disk space check is not supported on windows")
+}
@@ -0,0 +1,342 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/embedding"
+	"github.com/sandwichlabs/agent-memory-graph/internal/llm"
+	"github.com/sandwichlabs/agent-memory-graph/internal/llm/prompts"
+	"github.com/sandwichlabs/agent-memory-graph/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+const (
+	checkPass = "pass"
+	checkWarn = "warn"
+	checkFail = "fail"
+)
+
+// pingTimeout bounds each provider round trip made by doctor.
+const pingTimeout = 15 * time.Second
+
+// doctorCheck is the outcome of a single preflight check.
+type doctorCheck struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	Detail    string `json:"detail"`
+	Hint      string `json:"hint,omitempty"`
+	LatencyMS int64  `json:"latency_ms,omitempty"`
+}
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the environment, providers and database for common problems",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		checks := runDoctor(cmd.Context(), memoryDir(cmd), embeddingProvider(cmd), llmProvider(cmd))
+
+		if jsonOutput(cmd) {
+			if err := writeJSON(cmd.OutOrStdout(), checks); err != nil {
+				return err
+			}
+		} else {
+			printDoctorTable(cmd.OutOrStdout(), checks)
+		}
+
+		failed := 0
+		for _, check := range checks {
+			if check.Status == checkFail {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d check(s) failed", failed)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
+
+func runDoctor(ctx context.Context, dir string, embeddingProvider embedding.Provider, llmProvider llm.Provider) []doctorCheck {
+	var checks []doctorCheck
+
+	if err := storage.CheckEngine(); err != nil {
+		checks = append(checks, doctorCheck{Name: "kuzu library", Status: checkFail, Detail: err.Error(),
+			Hint: "make sure the kuzu shared library shipped with go-kuzu is on the library path"})
+	} else {
+		checks = append(checks, doctorCheck{Name: "kuzu library", Status: checkPass, Detail: "loaded"})
+	}
+
+	dbPath := filepath.Join(dir, storage.DatabaseFile)
+	_, statErr := os.Stat(dbPath)
+	dbExists := statErr == nil
+	checks = append(checks, checkDatabasePath(dir, dbPath, dbExists))
+
+	storedDims := 0
+	if dbExists {
+		var check doctorCheck
+		check, storedDims = checkSchema(ctx, dir)
+		checks = append(checks, check)
+	}
+	checks = append(checks, checkPrompts(dir))
+
+	checks = append(checks, checkAPIKeys(embeddingProvider, llmProvider)...)
+
+	embedCheck, dims := pingEmbedding(ctx, embeddingProvider)
+	checks = append(checks, embedCheck, pingLLM(ctx, llmProvider))
+	checks = append(checks, checkDimensions(dims, storedDims))
+	checks = append(checks, checkDiskSpace(dir))
+	return checks
+}
+
+func checkDatabasePath(dir, dbPath string, exists bool) doctorCheck {
+	check := doctorCheck{Name: "database path"}
+	if exists {
+		check.Status, check.Detail = checkPass, dbPath
+		return check
+	}
+
+	// Walk up to the nearest existing directory and make sure we could create the database there.
+	existing := dir
+	for {
+		if _, err := os.Stat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		existing = parent
+	}
+	probe, err := os.CreateTemp(existing, ".amg-doctor-*")
+	if err != nil {
+		check.Status, check.Detail = checkFail, fmt.Sprintf("%s cannot be created: %v", dbPath, err)
+		check.Hint = "choose a writable directory with --dir or AMG_DIR"
+		return check
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	check.Status, check.Detail = checkWarn, fmt.Sprintf("%s does not exist yet", dbPath)
+	check.Hint = "it will be created by the first amg ingest"
+	return check
+}
+
+// checkSchema opens the database read-only and compares its schema version with this build.
+// It also returns the stored embedding dimensions for the dimension check.
+func checkSchema(ctx context.Context, dir string) (doctorCheck, int) {
+	check := doctorCheck{Name: "schema version"}
+	store, err := storage.Open(dir, true)
+	if err != nil {
+		check.Status, check.Detail = checkFail, err.Error()
+		check.Hint = "stop other amg processes writing to this database and retry"
+		return check, 0
+	}
+	defer store.Close()
+
+	dims, _ := store.StoredEmbeddingDimensions(ctx)
+	version, err := store.StoredSchemaVersion(ctx)
+	switch {
+	case err != nil:
+		check.Status, check.Detail = checkFail, err.Error()
+	case version == 0:
+		check.Status, check.Detail = checkWarn, "database predates schema versioning"
+		check.Hint = "re-ingest into a fresh directory to pick up the current schema"
+	case version > storage.SchemaVersion:
+		check.Status, check.Detail = checkFail, fmt.Sprintf("database schema v%d is newer than this amg (v%d)", version, storage.SchemaVersion)
+		check.Hint = "upgrade amg"
+	case version < storage.SchemaVersion:
+		check.Status, check.Detail = checkWarn, fmt.Sprintf("database schema v%d is older than this amg (v%d)", version, storage.SchemaVersion)
+		check.Hint = "the next write (amg ingest or amg reindex) migrates it automatically"
+	default:
+		check.Status, check.Detail = checkPass, fmt.Sprintf("v%d", version)
+	}
+	return check, dims
+}
+
+// checkPrompts loads the prompt templates, reporting which are overridden.
+func checkPrompts(dir string) doctorCheck {
+	check := doctorCheck{Name: "prompt templates"}
+	promptDir := filepath.Join(dir, prompts.Dir)
+	set, err := prompts.Load(promptDir)
+	if err != nil {
+		check.Status, check.Detail = checkFail, err.Error()
+		check.Hint = fmt.Sprintf("fix or remove the template in %s", promptDir)
+		return check
+	}
+	var overridden []string
+	for _, tmpl := range set.List() {
+		if tmpl.Source != prompts.BuiltIn {
+			overridden = append(overridden, string(tmpl.Name))
+		}
+	}
+	check.Status, check.Detail = checkPass, prompts.BuiltIn
+	if len(overridden) > 0 {
+		check.Detail = fmt.Sprintf("%s overridden in %s", strings.Join(overridden, ", "), promptDir)
+	}
+	return check
+}
+
+func checkAPIKeys(embeddingProvider embedding.Provider, llmProvider llm.Provider) []doctorCheck {
+	// providerKeys maps the providers that need an API key to its variable.
+	providerKeys := make(map[string]string)
+	for _, provider := range embedding.Providers() {
+		if key := embedding.APIKeyEnv(provider); key != "" {
+			providerKeys[string(provider)] = key
+		}
+	}
+	for _, provider := range llm.Providers() {
+		if key := llm.APIKeyEnv(provider); key != "" {
+			providerKeys[string(provider)] = key
+		}
+	}
+	providers := make([]string, 0, len(providerKeys))
+	for provider := range providerKeys {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+
+	var checks []doctorCheck
+	for _, provider := range providers {
+		key := providerKeys[provider]
+		check := doctorCheck{Name: key}
+		selected := provider == string(embeddingProvider) || provider == string(llmProvider)
+		switch {
+		case os.Getenv(key) != "":
+			check.Status, check.Detail = checkPass, "set"
+		case selected:
+			check.Status, check.Detail = checkFail, fmt.Sprintf("not set, but %s is the selected provider", provider)
+			check.Hint = fmt.Sprintf("export %s or choose a different provider", key)
+		default:
+			check.Status, check.Detail = checkWarn, "not set"
+			check.Hint = fmt.Sprintf("only needed when using the %s provider", provider)
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// pingEmbedding embeds a short string and reports the latency and vector size.
+func pingEmbedding(ctx context.Context, provider embedding.Provider) (doctorCheck, int) {
+	check := doctorCheck{Name: "embedding provider"}
+	if key := embedding.MissingAPIKey(provider); key != "" {
+		check.Status, check.Detail = checkWarn, fmt.Sprintf("%s skipped: %s not set", provider, key)
+		return check, 0
+	}
+	service, err := embedding.New(provider)
+	if err != nil {
+		check.Status, check.Detail = checkFail, err.Error()
+		check.Hint = "choose a provider with --embedding-provider"
+		return check, 0
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	start := time.Now()
+	vector, err := service.GetEmbeddings(ctx, "amg doctor ping", embedding.EmbeddingTypeRetrievalDocument)
+	check.LatencyMS = time.Since(start).Milliseconds()
+	if err != nil {
+		check.Status, check.Detail = checkFail, fmt.Sprintf("%s: %v", provider, err)
+		check.Hint = "verify the API key and network access to the provider"
+		return check, 0
+	}
+	check.Status, check.Detail = checkPass, fmt.Sprintf("%s responded in %dms", provider, check.LatencyMS)
+	return check, len(vector)
+}
+
+// pingLLM sends a tiny prompt and reports the latency.
+func pingLLM(ctx context.Context, provider llm.Provider) doctorCheck {
+	check := doctorCheck{Name: "llm provider"}
+	if key := llm.MissingAPIKey(provider); key != "" {
+		check.Status, check.Detail = checkWarn, fmt.Sprintf("%s skipped: %s not set", provider, key)
+		return check
+	}
+	service, err := llm.NewLlmService(provider)
+	if err != nil {
+		check.Status, check.Detail = checkFail, err.Error()
+		check.Hint = "choose a provider with --llm-provider"
+		return check
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	start := time.Now()
+	_, err = service.GenerateText(ctx, "Reply with OK.")
+	check.LatencyMS = time.Since(start).Milliseconds()
+	if err != nil {
+		check.Status, check.Detail = checkFail, fmt.Sprintf("%s: %v", provider, err)
+		check.Hint = "verify the API key and network access to the provider"
+		return check
+	}
+	check.Status, check.Detail = checkPass, fmt.Sprintf("%s responded in %dms", provider, check.LatencyMS)
+	return check
+}
+
+func checkDimensions(providerDims, storedDims int) doctorCheck {
+	check := doctorCheck{Name: "embedding dimensions"}
+	expected := storedDims
+	if expected == 0 {
+		expected = storage.EmbeddingDimensions
+	}
+	switch {
+	case providerDims == 0:
+		check.Status, check.Detail = checkWarn, "unknown: embedding provider was not reachable"
+	case providerDims != expected:
+		check.Status, check.Detail = checkFail, fmt.Sprintf("provider returns %d dimensions but the database expects %d", providerDims, expected)
+		check.Hint = "use the embedding provider the database was built with"
+	default:
+		check.Status, check.Detail = checkPass, fmt.Sprintf("%d", providerDims)
+	}
+	return check
+}
+
+func checkDiskSpace(dir string) doctorCheck {
+	const (
+		warnBelow = 1 << 30
+		failBelow = 100 << 20
+	)
+	check := doctorCheck{Name: "disk space"}
+	for {
+		if _, err := os.Stat(dir); err == nil || filepath.Dir(dir) == dir {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	free, err := freeDiskSpace(dir)
+	switch {
+	case err != nil:
+		check.Status, check.Detail = checkWarn, err.Error()
+	case free < failBelow:
+		check.Status, check.Detail = checkFail, fmt.Sprintf("%d MB free", free>>20)
+		check.Hint = "free up disk space or move the memory graph with --dir"
+	case free < warnBelow:
+		check.Status, check.Detail = checkWarn, fmt.Sprintf("%d MB free", free>>20)
+		check.Hint = "large ingests may run out of space"
+	default:
+		check.Status, check.Detail = checkPass, fmt.Sprintf("%.1f GB free", float64(free)/(1<<30))
+	}
+	return check
+}
+
+func printDoctorTable(out io.Writer, checks []doctorCheck) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHECK\tSTATUS\tDETAIL")
+	for _, check := range checks {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", check.Name, check.Status, check.Detail)
+		if check.Hint != "" && check.Status != checkPass {
+			fmt.Fprintf(w, "\t\t-> %s\n", check.Hint)
+		}
+	}
+	w.Flush()
+}
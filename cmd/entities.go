@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/api"
+	"github.com/sandwichlabs/agent-memory-graph/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var entitiesCmd = &cobra.Command{
+	Use:   "entities",
+	Short: "List, inspect and merge entities in the memory graph",
+}
+
+var entitiesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List entities with their mention and relationship counts",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		match, _ := cmd.Flags().GetString("match")
+		entityType, _ := cmd.Flags().GetString("type")
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		store, err := storage.Open(memoryDir(cmd), true)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		entities, err := store.ListEntities(cmd.Context(), storage.EntityFilter{
+			Match: match,
+			Type:  strings.ToUpper(entityType),
+			Limit: limit,
+		})
+		if err != nil {
+			return err
+		}
+		if jsonOutput(cmd) {
+			return writeJSON(cmd.OutOrStdout(), api.NewEntities(entities))
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tTYPE\tMENTIONS\tRELATIONSHIPS")
+		for _, entity := range entities {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\n", entity.Name, entity.Type, entity.MentionCount, entity.RelationshipCount)
+		}
+		return w.Flush()
+	},
+}
+
+var entitiesShowCmd = &cobra.Command{
+	Use:   "show <name>",
+	Short: "Show an entity's aliases, relationships and top mentioning chunks",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		chunks, _ := cmd.Flags().GetInt("chunks")
+
+		store, err := storage.Open(memoryDir(cmd), true)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		detail, err := store.GetEntity(cmd.Context(), args[0], chunks)
+		if err != nil {
+			return err
+		}
+		if detail == nil {
+			return fmt.Errorf("entity %q not found", args[0])
+		}
+
+		if jsonOutput(cmd) {
+			return writeJSON(cmd.OutOrStdout(), api.NewEntityDetail(detail))
+		}
+		printEntityDetail(cmd.OutOrStdout(), detail)
+		return nil
+	},
+}
+
+var entitiesMergeCmd = &cobra.Command{
+	Use:   "merge <keep> <dupes...>",
+	Short: "Merge duplicate entities into one, keeping their names as aliases",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		keep, dupes := args[0], args[1:]
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		if !yes {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Merge %s into %q? [y/N] ", strings.Join(quoteAll(dupes), ", "), keep)
+			answer, _ := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+			if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+				return fmt.Errorf("merge cancelled")
+			}
+		}
+
+		store, err := storage.Open(memoryDir(cmd), false)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		if err := store.MergeEntities(cmd.Context(), keep, dupes); err != nil {
+			return err
+		}
+		detail, err := store.GetEntity(cmd.Context(), keep, 1)
+		if err != nil {
+			return err
+		}
+		if jsonOutput(cmd) {
+			return writeJSON(cmd.OutOrStdout(), api.NewEntity(detail.Entity))
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Merged %d entities into %s (aliases: %s)\n", len(dupes), detail.Name, strings.Join(detail.Aliases, ", "))
+		return nil
+	},
+}
+
+func init() {
+	entitiesListCmd.Flags().String("match", "", "Only list entities whose name contains this text (case-insensitive)")
+	entitiesListCmd.Flags().String("type", "", "Only list entities of this type, e.g. PERSON or ORG")
+	entitiesListCmd.Flags().Int("limit", 50, "Maximum number of entities to list")
+	entitiesShowCmd.Flags().Int("chunks", 5, "Number of mentioning chunks to show")
+	entitiesMergeCmd.Flags().BoolP("yes", "y", false, "Merge without asking for confirmation")
+	entitiesCmd.AddCommand(entitiesListCmd, entitiesShowCmd, entitiesMergeCmd)
+	rootCmd.AddCommand(entitiesCmd)
+}
+
+func printEntityDetail(out io.Writer, detail *storage.EntityDetail) {
+	fmt.Fprintf(out, "%s (%s)\n", detail.Name, detail.Type)
+	if len(detail.Aliases) > 0 {
+		fmt.Fprintf(out, "Aliases: %s\n", strings.Join(detail.Aliases, ", "))
+	}
+	fmt.Fprintf(out, "Mentions: %d\n", detail.MentionCount)
+
+	if len(detail.Relationships) > 0 {
+		fmt.Fprintln(out, "\nRelationships:")
+		for _, rel := range detail.Relationships {
+			fmt.Fprintf(out, "  %s -[%s]-> %s\n", rel.Subject, rel.Predicate, rel.Object)
+		}
+	}
+	if len(detail.Chunks) > 0 {
+		fmt.Fprintln(out, "\nMentioned in:")
+		for _, chunk := range detail.Chunks {
+			fmt.Fprintf(out, "  %s (chunk %d)\n    %s\n", chunk.Source, chunk.Index, strings.ReplaceAll(strings.TrimSpace(chunk.Content), "\n", "\n    "))
+		}
+	}
+}
+
+func writeJSON(out io.Writer, v any) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func quoteAll(values []string) []string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = fmt.Sprintf("%q", value)
+	}
+	return quoted
+}
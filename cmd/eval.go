@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/api"
+	"github.com/sandwichlabs/agent-memory-graph/internal/eval"
+	"github.com/spf13/cobra"
+)
+
+var evalCmd = &cobra.Command{
+	Use:   "eval",
+	Short: "Measure the quality of the memory graph",
+}
+
+var evalRetrievalCmd = &cobra.Command{
+	Use:   "retrieval [cases.yaml]",
+	Short: "Report recall@k and MRR of searches with known answers",
+	Long: `Runs each case's query against the memory graph and checks whether the top k
+hits include the expected chunks. A case file is a YAML or JSON list of cases:
+
+  - query: what does kuzu build
+    expect: [notes/kuzu.md, KuzuDB]
+
+Each expected string matches a hit whose source or content contains it.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cases, err := eval.LoadCases(args[0])
+		if err != nil {
+			return err
+		}
+		opts, err := searchOptions(cmd)
+		if err != nil {
+			return err
+		}
+
+		retriever, store, err := openRetriever("eval retrieval", memoryDir(cmd), embeddingProvider(cmd), llmProvider(cmd), opts)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		report, err := eval.Run(cmd.Context(), retriever, cases, opts)
+		if err != nil {
+			return err
+		}
+		if jsonOutput(cmd) {
+			return writeJSON(cmd.OutOrStdout(), api.NewRetrievalEval(report))
+		}
+
+		if report.KeywordOnly {
+			fmt.Fprintln(cmd.OutOrStdout(), keywordOnlyNote(embeddingProvider(cmd)))
+			fmt.Fprintln(cmd.OutOrStdout())
+		}
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "QUERY\tRECALL\tRR\tMISSED")
+		for _, query := range report.Queries {
+			fmt.Fprintf(w, "%s\t%.2f\t%.2f\t%s\n", query.Query, query.Recall, query.ReciprocalRank, strings.Join(query.Missed, ", "))
+		}
+		fmt.Fprintf(w, "\nRecall@%d:\t%.3f\n", report.K, report.Recall)
+		fmt.Fprintf(w, "MRR:\t%.3f\n", report.MRR)
+		return w.Flush()
+	},
+}
+
+func init() {
+	addSearchFlags(evalRetrievalCmd)
+	evalCmd.AddCommand(evalRetrievalCmd)
+	rootCmd.AddCommand(evalCmd)
+}
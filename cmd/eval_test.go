@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/api"
+)
+
+func TestEvalRetrieval_ExampleCases(t *testing.T) {
+	dir := seedGraph(t)
+
+	code, stdout, stderr := runCLI(t, "-d", dir, "eval", "retrieval", "../docs/eval/cases.example.yaml",
+		"--k", "5", "--json", "--embedding-provider", "testing")
+	if code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d (stderr: %s)", exitOK, code, stderr)
+	}
+	var report api.RetrievalEval
+	if err := json.Unmarshal([]byte(stdout), &report); err != nil {
+		t.Fatalf("Expected a JSON report, got %q: %v", stdout, err)
+	}
+
+	// The first two queries are embedded exactly as the chunks answering them, which
+	// rank first; the third case has no matching chunk.
+	if report.K != 5 || len(report.Queries) != 3 {
+		t.Fatalf("Expected 3 queries at k=5, got %+v", report)
+	}
+	wantRR := []float64{1, 1, 0}
+	for i, query := range report.Queries {
+		if query.ReciprocalRank != wantRR[i] {
+			t.Errorf("Expected reciprocal rank %v for %q, got %v", wantRR[i], query.Query, query.ReciprocalRank)
+		}
+	}
+	if len(report.Queries[2].Missed) != 1 || report.Queries[2].Missed[0] != "notes/storage.md" {
+		t.Errorf("Expected notes/storage.md to be missed, got %v", report.Queries[2].Missed)
+	}
+	if report.Recall != 2.0/3 || report.MRR != 2.0/3 {
+		t.Errorf("Expected recall 0.667 and MRR 0.667, got %v and %v", report.Recall, report.MRR)
+	}
+
+	code, stdout, _ = runCLI(t, "-d", dir, "eval", "retrieval", "../docs/eval/cases.example.yaml", "--k", "1", "--embedding-provider", "testing")
+	if code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d", exitOK, code)
+	}
+	if want := "Recall@1:  0.667"; !strings.Contains(stdout, want) {
+		t.Errorf("Expected %q in the table, got %q", want, stdout)
+	}
+}
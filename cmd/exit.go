@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/spf13/cobra"
+)
+
+// Process exit codes returned by Execute.
+const (
+	exitOK      = 0
+	exitFailure = 1
+	// exitUsage means the command line itself was invalid: unknown flags, wrong
+	// argument counts or conflicting options.
+	exitUsage = 2
+	// exitPartial means a batch command completed but some of its inputs failed.
+	exitPartial = 3
+)
+
+// exitError carries a specific exit code for an error returned from a command.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+// usageErrorf reports an invalid command line; it exits with exitUsage.
+func usageErrorf(format string, args ...any) error {
+	return &exitError{code: exitUsage, err: fmt.Errorf(format, args...)}
+}
+
+// exitCode maps an error returned from a command to a process exit code.
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+	return exitFailure
+}
+
+var wrapArgsOnce sync.Once
+
+// wrapArgsValidators marks errors from every command's positional argument
+// validator, and from flag parsing, as usage errors.
+func wrapArgsValidators(root *cobra.Command) {
+	wrapArgsOnce.Do(func() {
+		root.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+			return &exitError{code: exitUsage, err: err}
+		})
+		var wrap func(cmd *cobra.Command)
+		wrap = func(cmd *cobra.Command) {
+			if validate := cmd.Args; validate != nil {
+				cmd.Args = func(cmd *cobra.Command, args []string) error {
+					if err := validate(cmd, args); err != nil {
+						return &exitError{code: exitUsage, err: err}
+					}
+					return nil
+				}
+			}
+			for _, sub := range cmd.Commands() {
+				wrap(sub)
+			}
+		}
+		wrap(root)
+	})
+}
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+// maxGraphNodes is the size above which --all refuses to export without --force;
+// Graphviz takes minutes to lay out graphs much larger than this.
+const maxGraphNodes = 500
+
+var graphCmd = &cobra.Command{
+	Use:   "graph",
+	Short: "Export the entity graph as DOT or GraphML for visualization",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entity, _ := cmd.Flags().GetString("entity")
+		hops, _ := cmd.Flags().GetInt("hops")
+		format, _ := cmd.Flags().GetString("format")
+		outPath, _ := cmd.Flags().GetString("out")
+		all, _ := cmd.Flags().GetBool("all")
+		force, _ := cmd.Flags().GetBool("force")
+		open, _ := cmd.Flags().GetBool("open")
+
+		if (entity == "") == !all {
+			return usageErrorf("specify exactly one of --entity or --all")
+		}
+		if format != "dot" && format != "graphml" {
+			return usageErrorf("unknown format %q: use dot or graphml", format)
+		}
+		if open && format != "dot" {
+			return usageErrorf("--open requires --format dot")
+		}
+
+		store, err := storage.Open(memoryDir(cmd), true)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		var graph *storage.Graph
+		if all {
+			count, err := store.EntityCount(cmd.Context())
+			if err != nil {
+				return err
+			}
+			if count > maxGraphNodes && !force {
+				return fmt.Errorf("the memory graph has %d entities, more than Graphviz can usefully render (%d); narrow it with --entity or pass --force", count, maxGraphNodes)
+			}
+			graph, err = store.FullGraph(cmd.Context())
+			if err != nil {
+				return err
+			}
+		} else {
+			graph, err = store.Subgraph(cmd.Context(), entity, hops)
+			if err != nil {
+				return err
+			}
+		}
+
+		if open && outPath == "" {
+			file, err := os.CreateTemp("", "amg-*.dot")
+			if err != nil {
+				return err
+			}
+			file.Close()
+			outPath = file.Name()
+		}
+		if err := writeGraph(cmd.OutOrStdout(), outPath, format, graph); err != nil {
+			return err
+		}
+		if outPath != "" {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Wrote %d entities and %d relationships to %s\n", len(graph.Nodes), len(graph.Edges), outPath)
+		}
+		if open {
+			return renderAndOpen(cmd.ErrOrStderr(), outPath)
+		}
+		return nil
+	},
+}
+
+func init() {
+	graphCmd.Flags().String("entity", "", "Export the neighbourhood of this entity (name or alias)")
+	graphCmd.Flags().Int("hops", 1, "Number of relationship hops to include around --entity")
+	graphCmd.Flags().String("format", "dot", "Output format: dot or graphml")
+	graphCmd.Flags().StringP("out", "o", "", "Write to this file instead of stdout")
+	graphCmd.Flags().Bool("all", false, fmt.Sprintf("Export the whole graph (refused above %d entities unless --force)", maxGraphNodes))
+	graphCmd.Flags().Bool("force", false, "Export with --all even when the graph is large")
+	graphCmd.Flags().Bool("open", false, "Render the DOT output to SVG with Graphviz and open it")
+	graphCmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions([]string{"dot", "graphml"}, cobra.ShellCompDirectiveNoFileComp))
+	rootCmd.AddCommand(graphCmd)
+}
+
+func writeGraph(stdout io.Writer, path, format string, graph *storage.Graph) error {
+	out := stdout
+	if path != "" {
+		file, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	if format == "graphml" {
+		return graph.WriteGraphML(out)
+	}
+	return graph.WriteDOT(out)
+}
+
+// renderAndOpen renders a DOT file to SVG next to it and opens it with the
+// platform's default viewer.
+func renderAndOpen(stderr io.Writer, dotPath string) error {
+	dot, err := exec.LookPath("dot")
+	if err != nil {
+		return fmt.Errorf("--open needs Graphviz's dot on the PATH: %w", err)
+	}
+	svgPath := strings.TrimSuffix(dotPath, ".dot") + ".svg"
+	render := exec.Command(dot, "-Tsvg", "-o", svgPath, dotPath)
+	render.Stderr = stderr
+	if err := render.Run(); err != nil {
+		return fmt.Errorf("failed to render %s: %w", dotPath, err)
+	}
+	fmt.Fprintf(stderr, "Rendered %s\n", svgPath)
+
+	var opener *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		opener = exec.Command("open", svgPath)
+	case "windows":
+		opener = exec.Command("rundll32", "url.dll,FileProtocolHandler", svgPath)
+	default:
+		opener = exec.Command("xdg-open", svgPath)
+	}
+	return opener.Start()
+}
@@ -2,26 +2,284 @@ package cmd
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
 
+	"github.com/sandwichlabs/agent-memory-graph/internal/api"
+	"github.com/sandwichlabs/agent-memory-graph/internal/budget"
+	"github.com/sandwichlabs/agent-memory-graph/internal/embedding"
 	"github.com/sandwichlabs/agent-memory-graph/internal/ingest"
+	"github.com/sandwichlabs/agent-memory-graph/internal/llm"
 	"github.com/spf13/cobra"
 )
 
 var ingestCmd = &cobra.Command{
-	Use:   "ingest [file path]",
-	Short: "Ingest a file into the memory graph",
-	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		filePath := args[0]
-		err := ingest.IngestFile(filePath)
+	Use:   "ingest [file|dir|glob|url|-]...",
+	Short: "Ingest files, directories, globs, URLs or stdin into the memory graph",
+	Args:  cobra.MinimumNArgs(1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return nil, cobra.ShellCompDirectiveDefault
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sources, err := ingest.ExpandInputs(args)
 		if err != nil {
-			fmt.Printf("Error ingesting file: %v\n", err)
-			return
+			return err
+		}
+
+		collection, _ := cmd.Flags().GetString("collection")
+		force, _ := cmd.Flags().GetBool("force")
+		tags, _ := cmd.Flags().GetStringSlice("tag")
+		quiet, _ := cmd.Flags().GetBool("quiet")
+		chunkTokens, _ := cmd.Flags().GetInt("chunk-tokens")
+		if chunkTokens < 0 {
+			return usageErrorf("--chunk-tokens must not be negative")
+		}
+		extractionModel, _ := cmd.Flags().GetString("extraction-model")
+		concurrency, _ := cmd.Flags().GetInt("llm-concurrency")
+		if concurrency < 1 {
+			return usageErrorf("--llm-concurrency must be at least 1")
+		}
+		embeddingBatchSize, _ := cmd.Flags().GetInt("embedding-batch-size")
+		if embeddingBatchSize < 1 {
+			return usageErrorf("--embedding-batch-size must be at least 1")
+		}
+		embeddingConcurrency, _ := cmd.Flags().GetInt("embed-concurrency")
+		if embeddingConcurrency < 1 {
+			return usageErrorf("--embed-concurrency must be at least 1")
+		}
+		var limits budget.Limits
+		limits.MaxRetries, _ = cmd.Flags().GetInt("max-retries")
+		limits.MaxTokens, _ = cmd.Flags().GetInt("max-tokens")
+		limits.MaxBackoff, _ = cmd.Flags().GetDuration("max-backoff")
+		if limits.MaxRetries < 0 || limits.MaxTokens < 0 || limits.MaxBackoff < 0 {
+			return usageErrorf("--max-retries, --max-tokens and --max-backoff must not be negative")
+		}
+		noEmbeddingCache, _ := cmd.Flags().GetBool("no-embed-cache")
+		embeddingCacheMaxEntries, _ := cmd.Flags().GetInt("embed-cache-max-entries")
+		if embeddingCacheMaxEntries < 1 {
+			return usageErrorf("--embed-cache-max-entries must be at least 1")
+		}
+		overLimit, _ := cmd.Flags().GetString("embed-over-limit")
+		if !slices.Contains(embedding.LengthStrategies(), embedding.LengthStrategy(overLimit)) {
+			return usageErrorf("--embed-over-limit must be one of %v", embedding.LengthStrategies())
+		}
+		precision, _ := cmd.Flags().GetString("embed-precision")
+		if precision != "" && !slices.Contains(embedding.Precisions(), embedding.Precision(precision)) {
+			return usageErrorf("--embed-precision must be one of %v", embedding.Precisions())
+		}
+		opts := ingest.Options{
+			Collection:               collection,
+			EmbeddingProvider:        embeddingProvider(cmd),
+			LlmProvider:              llmProvider(cmd),
+			Force:                    force,
+			Tags:                     tags,
+			ChunkTokens:              chunkTokens,
+			ExtractionModel:          extractionModel,
+			LlmConcurrency:           concurrency,
+			EmbeddingBatchSize:       embeddingBatchSize,
+			EmbeddingConcurrency:     embeddingConcurrency,
+			Budget:                   limits,
+			OverLimit:                embedding.LengthStrategy(overLimit),
+			EmbeddingPrecision:       embedding.Precision(precision),
+			NoEmbeddingCache:         noEmbeddingCache,
+			EmbeddingCacheMaxEntries: embeddingCacheMaxEntries,
+		}
+		if cache, _ := cmd.Flags().GetBool("llm-cache"); cache {
+			if opts.LlmCacheDir, err = llmCacheDir(); err != nil {
+				return err
+			}
+			opts.RefreshLlmCache, _ = cmd.Flags().GetBool("refresh-llm-cache")
+		}
+		var progress *ingestProgress
+		if !quiet {
+			progress = newIngestProgress(cmd.ErrOrStderr())
+			opts.Progress = progress.Update
+		}
+		ingestor, err := ingest.NewIngestor(memoryDir(cmd), opts)
+		if err != nil {
+			return fmt.Errorf("failed to start ingestion: %w", err)
+		}
+		defer ingestor.Close()
+		if preflight, _ := cmd.Flags().GetBool("preflight"); preflight {
+			if err := ingestor.Preflight(cmd.Context()); err != nil {
+				return err
+			}
+		}
+
+		report := ingestor.IngestAll(cmd.Context(), sources)
+		if progress != nil {
+			progress.Finish()
+		}
+		if jsonOutput(cmd) {
+			if err := writeJSON(cmd.OutOrStdout(), api.NewIngestReport(report)); err != nil {
+				return err
+			}
+		} else {
+			printIngestReport(cmd.OutOrStdout(), report)
 		}
-		fmt.Printf("Ingested file: %s\n", filePath)
+		return ingestError(report)
 	},
 }
 
 func init() {
+	ingestCmd.Flags().String("collection", "", "Collection to store the documents in (default: 'default')")
+	ingestCmd.Flags().Bool("force", false, "Re-ingest sources even when their content has not changed")
+	ingestCmd.Flags().StringSlice("tag", nil, "Label the ingested documents, e.g. for amg prune --tag")
+	ingestCmd.Flags().Int("chunk-tokens", 0, "Split documents into chunks of about this many LLM tokens rather than 512 characters")
+	ingestCmd.Flags().Int("embedding-batch-size", ingest.DefaultEmbeddingBatchSize, "Number of chunks to embed per request")
+	ingestCmd.Flags().Int("embed-concurrency", embedding.DefaultConcurrency, "Number of embedding batches to send at once")
+	ingestCmd.Flags().String("extraction-model", "", "Extract entities with this model of the LLM provider rather than its chat model, e.g. a smaller one")
+	ingestCmd.Flags().Int("llm-concurrency", llm.DefaultBatchConcurrency, "Number of chunks to send to the LLM at once")
+	ingestCmd.Flags().Int("max-retries", 0, "Stop the run after this many retries of failed LLM requests (default: no limit)")
+	ingestCmd.Flags().Int("max-tokens", 0, "Stop the run after its LLM requests spend this many tokens (default: no limit)")
+	ingestCmd.Flags().Duration("max-backoff", 0, "Stop the run after waiting this long in total before retries, e.g. 5m (default: no limit)")
+	ingestCmd.Flags().Bool("preflight", false, "Check the embedding and LLM providers' keys and connectivity before reading any source")
+	ingestCmd.Flags().Bool("llm-cache", false, "Cache LLM replies so re-ingesting unchanged chunks costs nothing ($AMG_LLM_CACHE_DIR or the user cache directory)")
+	ingestCmd.Flags().Bool("refresh-llm-cache", false, "With --llm-cache, ask the LLM again instead of using cached replies")
+	ingestCmd.Flags().String("embed-over-limit", string(embedding.LengthFail), "What to do with chunks over the embedding model's input limit: fail (split them into smaller chunks), truncate, or split (average the vectors of their parts)")
+	ingestCmd.Flags().String("embed-precision", "", "Store vectors as float32, or as int8 in a quarter of the space, for this and later ingests (default: as before, else float32)")
+	ingestCmd.Flags().Bool("no-embed-cache", false, "Embed every chunk instead of reusing the vectors of chunks embedded before")
+	ingestCmd.Flags().Int("embed-cache-max-entries", embedding.DefaultCacheMaxEntries, "Number of vectors the embedding cache keeps, dropping the oldest beyond it")
+	ingestCmd.RegisterFlagCompletionFunc("collection", completeCollections)
 	rootCmd.AddCommand(ingestCmd)
 }
+
+// llmCacheDir returns where --llm-cache stores replies: AMG_LLM_CACHE_DIR, or amg/llm
+// in the user's cache directory.
+func llmCacheDir() (string, error) {
+	if dir := os.Getenv("AMG_LLM_CACHE_DIR"); dir != "" {
+		return dir, nil
+	}
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate the LLM cache: %w (set AMG_LLM_CACHE_DIR)", err)
+	}
+	return filepath.Join(cache, "amg", "llm"), nil
+}
+
+// ingestError summarizes a batch's failures: exitFailure when nothing was ingested,
+// exitPartial when only some inputs failed.
+func ingestError(report ingest.Report) error {
+	failed, total := report.Failed(), len(report.Results)
+	switch {
+	case failed == 0:
+		return nil
+	case failed == total:
+		return fmt.Errorf("all %d inputs failed to ingest", total)
+	default:
+		return &exitError{code: exitPartial, err: fmt.Errorf("%d of %d inputs failed to ingest", failed, total)}
+	}
+}
+
+func printIngestReport(out io.Writer, report ingest.Report) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SOURCE\tCHUNKS\tSTATUS")
+	for _, result := range report.Results {
+		status := string(result.Status)
+		if result.Err != nil {
+			status = "error: " + result.Err.Error()
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\n", result.Source, result.Chunks, status)
+	}
+	w.Flush()
+	fmt.Fprintf(out, "Ingested %d of %d inputs\n", len(report.Results)-report.Failed(), len(report.Results))
+	if failures := report.ExtractionFailures(); failures > 0 {
+		fmt.Fprintf(out, "Extracted entities from %d of %d chunks (%d stored without entities)\n", report.Chunks()-failures, report.Chunks(), failures)
+	}
+	if failures := report.EmbeddingFailures(); failures > 0 {
+		fmt.Fprintf(out, "Embedded %d of %d chunks (%d refused by the provider, stored without a vector; embed them with amg reindex --only-missing)\n", report.Chunks()-failures, report.Chunks(), failures)
+	}
+	if usage := report.Usage(); usage.TotalTokens > 0 {
+		fmt.Fprintf(out, "LLM usage: %d tokens (%d prompt, %d completion)\n", usage.TotalTokens, usage.PromptTokens, usage.CompletionTokens)
+		fmt.Fprintf(out, "Estimated LLM cost: %s\n", report.Cost())
+	}
+	switch usage := report.EmbeddingUsage; {
+	case usage.PromptTokens > 0:
+		fmt.Fprintf(out, "Embedding usage: %d tokens\n", usage.PromptTokens)
+	case usage.Unknown && report.Chunks() > 0:
+		fmt.Fprintln(out, "Embedding usage: not reported by the provider")
+	}
+	if chunks := report.OverLimitChunks(); len(chunks) > 0 {
+		fmt.Fprintf(out, "Fitted %d chunks over the embedding model's input limit (%s)\n", len(chunks), overLimitActions(chunks))
+	}
+	if cache := report.EmbeddingCache; cache.Hits+cache.Misses > 0 {
+		fmt.Fprintf(out, "Embedding cache: %d hits, %d misses\n", cache.Hits, cache.Misses)
+	}
+	if state := report.Budget; !state.Limits.IsZero() {
+		fmt.Fprintf(out, "Budget: %s, %s, %s backoff", spent(state.Retries, state.MaxRetries, "retries"), spent(state.Tokens, state.MaxTokens, "tokens"), spentTime(state.Backoff, state.MaxBackoff))
+		if state.Exhausted != "" {
+			fmt.Fprintf(out, " (exhausted: %s)", state.Exhausted)
+		}
+		fmt.Fprintln(out)
+	}
+	for _, calls := range report.LLMCalls {
+		fmt.Fprintf(out, "LLM %s %s: %d requests, %d errors%s, p50 %s, p95 %s, %d tokens in, %d out\n",
+			calls.Provider, calls.Method, calls.Requests, calls.Errors, errorClasses(calls.ErrorsByClass),
+			calls.P50.Round(time.Millisecond), calls.P95.Round(time.Millisecond), calls.PromptTokens, calls.CompletionTokens)
+	}
+	for _, calls := range report.EmbeddingCalls {
+		fmt.Fprintf(out, "Embedding %s %s: %d requests, %d errors%s, p50 %s, p95 %s, %d texts (%d bytes)",
+			calls.Provider, calls.Method, calls.Requests, calls.Errors, errorClasses(calls.ErrorsByClass),
+			calls.P50.Round(time.Millisecond), calls.P95.Round(time.Millisecond), calls.Texts, calls.Bytes)
+		if calls.CacheHits+calls.CacheMisses > 0 {
+			fmt.Fprintf(out, ", %.0f%% cache hits", 100*calls.CacheHitRate())
+		}
+		fmt.Fprintln(out)
+	}
+}
+
+// overLimitActions formats how many chunks over the embedding model's input limit
+// had each action, such as "2 rechunked, 1 truncated".
+func overLimitActions(chunks []ingest.OverLimitChunk) string {
+	counts := make(map[ingest.OverLimitAction]int)
+	for _, chunk := range chunks {
+		counts[chunk.Action]++
+	}
+	var actions []string
+	for _, action := range []ingest.OverLimitAction{ingest.OverLimitRechunked, ingest.OverLimitSplit, ingest.OverLimitTruncated} {
+		if counts[action] > 0 {
+			actions = append(actions, fmt.Sprintf("%d %s", counts[action], action))
+		}
+	}
+	return strings.Join(actions, ", ")
+}
+
+// spent formats n of a budget's limit, such as "3 of 10 retries", or "3 retries"
+// when unlimited.
+func spent(n, limit int, unit string) string {
+	if limit <= 0 {
+		return fmt.Sprintf("%d %s", n, unit)
+	}
+	return fmt.Sprintf("%d of %d %s", n, limit, unit)
+}
+
+// spentTime formats d of a budget's limit as spent does.
+func spentTime(d, limit time.Duration) string {
+	if limit <= 0 {
+		return d.String()
+	}
+	return fmt.Sprintf("%s of %s", d, limit)
+}
+
+// errorClasses formats counts of errors by class as " (rate_limited: 2, timeout: 1)",
+// or as nothing when there are none.
+func errorClasses(counts map[string]int) string {
+	if len(counts) == 0 {
+		return ""
+	}
+	classes := make([]string, 0, len(counts))
+	for class := range counts {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	for n, class := range classes {
+		classes[n] = fmt.Sprintf("%s: %d", class, counts[class])
+	}
+	return " (" + strings.Join(classes, ", ") + ")"
+}
@@ -0,0 +1,288 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/api"
+	"github.com/sandwichlabs/agent-memory-graph/internal/budget"
+	"github.com/sandwichlabs/agent-memory-graph/internal/embedding"
+	"github.com/sandwichlabs/agent-memory-graph/internal/ingest"
+	"github.com/sandwichlabs/agent-memory-graph/internal/llm"
+	"github.com/sandwichlabs/agent-memory-graph/internal/metrics"
+	"github.com/sandwichlabs/agent-memory-graph/internal/retrieval"
+	"github.com/sandwichlabs/agent-memory-graph/internal/storage"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// seedGraph creates a memory graph with one document, two chunks and a few entities.
+// Each chunk's embedding is that the "testing" embedding provider gives the query
+// of docs/eval/cases.example.yaml it answers, so that the query ranks it first.
+func seedGraph(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	store, err := storage.Open(dir, false)
+	if err != nil {
+		t.Fatalf("Failed to create memory graph: %v", err)
+	}
+	defer store.Close()
+
+	builds := embedding.MockVector("what does kuzu build", storage.EmbeddingDimensions)
+	speaks := embedding.MockVector("which query language does KuzuDB speak", storage.EmbeddingDimensions)
+	doc := storage.Document{ID: "doc1", Source: "notes/kuzu.md", Collection: "research"}
+	chunks := []storage.Chunk{
+		{
+			ID: "doc1-0", DocumentID: "doc1", Content: "Kuzu Inc builds KuzuDB.", Index: 0, StartOffset: 0, EndOffset: 23, Embedding: builds,
+			Entities:      []storage.Entity{{Name: "Kuzu Inc", Type: "ORG"}, {Name: "KuzuDB", Type: "PRODUCT"}},
+			Relationships: []storage.Relationship{{Subject: "Kuzu Inc", Predicate: "builds", Object: "KuzuDB"}},
+		},
+		{
+			ID: "doc1-1", DocumentID: "doc1", Content: "KuzuDB speaks Cypher.", Index: 1, StartOffset: 24, EndOffset: 45, Embedding: speaks,
+			Entities:      []storage.Entity{{Name: "KuzuDB", Type: "PRODUCT"}},
+			Relationships: []storage.Relationship{{Subject: "KuzuDB", Predicate: "speaks", Object: "Cypher"}},
+		},
+	}
+	if err := store.SaveDocument(context.Background(), doc, chunks); err != nil {
+		t.Fatalf("Failed to save document: %v", err)
+	}
+	return dir
+}
+
+// assertGolden compares got against testdata/<name>.json, rewriting it with -update.
+func assertGolden(t *testing.T, name string, got string) {
+	t.Helper()
+	var doc any
+	if err := json.Unmarshal([]byte(got), &doc); err != nil {
+		t.Fatalf("Expected a single JSON document for %s, got %q: %v", name, got, err)
+	}
+
+	path := filepath.Join("testdata", name+".json")
+	if *update {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			t.Fatalf("Failed to create testdata: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatalf("Failed to write golden file: %v", err)
+		}
+		return
+	}
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read golden file (run go test ./cmd -update): %v", err)
+	}
+	if got != string(expected) {
+		t.Errorf("%s JSON does not match %s.\nExpected:\n%s\nGot:\n%s", name, path, expected, got)
+	}
+}
+
+func TestJSONOutput_Golden(t *testing.T) {
+	dir := seedGraph(t)
+	cases := []struct {
+		name string
+		args []string
+	}{
+		{"query", []string{"query", "what does kuzu build", "--k", "2", "--embedding-provider", "testing"}},
+		{"stats", []string{"stats"}},
+		{"entities_list", []string{"entities", "list"}},
+		{"entities_show", []string{"entities", "show", "KuzuDB"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			code, stdout, stderr := runCLI(t, append([]string{"-d", dir, "--json"}, c.args...)...)
+			if code != exitOK {
+				t.Fatalf("Expected exit code %d, got %d (stderr: %s)", exitOK, code, stderr)
+			}
+			assertGolden(t, c.name, stdout)
+		})
+	}
+}
+
+func TestJSONOutput_DoctorGolden(t *testing.T) {
+	dir := seedGraph(t)
+	t.Setenv("MISTRAL_API_KEY", "")
+	t.Setenv("GEMINI_API_KEY", "")
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	_, stdout, _ := runCLI(t, "-d", dir, "--json", "--embedding-provider", "testing", "doctor")
+
+	// Details, hints and latencies depend on the machine; only the shape and the
+	// deterministic statuses are compared.
+	var checks []map[string]any
+	if err := json.Unmarshal([]byte(stdout), &checks); err != nil {
+		t.Fatalf("Expected a JSON list of checks, got %q: %v", stdout, err)
+	}
+	for _, check := range checks {
+		for _, key := range []string{"detail", "hint", "latency_ms"} {
+			if _, ok := check[key]; ok {
+				check[key] = "*"
+			}
+		}
+		if check["name"] == "disk space" {
+			check["status"] = "*"
+		}
+	}
+	normalized, _ := json.MarshalIndent(checks, "", "  ")
+	assertGolden(t, "doctor", string(normalized)+"\n")
+}
+
+func TestJSONOutput_IngestReportGolden(t *testing.T) {
+	report := ingest.Report{Results: []ingest.Result{
+		{Source: "/notes/a.md", Status: ingest.StatusIngested, Chunks: 3, ExtractionFailures: 1, EmbeddingFailures: 1, Usage: llm.Usage{PromptTokens: 900, CompletionTokens: 150, TotalTokens: 1050}, Cost: llm.Cost{USD: 0.000135}},
+		{Source: "/notes/b.bin", Status: ingest.StatusFailed, Err: errors.New("/notes/b.bin is not a UTF-8 text document")},
+		{Source: "/notes/c.md", Status: ingest.StatusFailed, Err: errors.New("failed to extract graph info: mistral API error"), Usage: llm.Usage{PromptTokens: 300, CompletionTokens: 50, TotalTokens: 350}, Cost: llm.Cost{USD: 0.000045}},
+	}, LLMCalls: []metrics.MethodStats{
+		{Provider: "mistral", Method: "chat", Requests: 5, Errors: 1, ErrorsByClass: map[string]int{"upstream": 1}, P50: 800 * time.Millisecond, P95: 2500 * time.Millisecond, PromptTokens: 1200, CompletionTokens: 200},
+	}, Budget: budget.State{Limits: budget.Limits{MaxRetries: 3, MaxBackoff: time.Minute}, Retries: 3, Tokens: 1400, Backoff: 12 * time.Second, Exhausted: "3 retries used"},
+		EmbeddingUsage: embedding.Usage{PromptTokens: 640}}
+	var out strings.Builder
+	if err := writeJSON(&out, api.NewIngestReport(report)); err != nil {
+		t.Fatalf("writeJSON failed: %v", err)
+	}
+	assertGolden(t, "ingest", out.String())
+
+	var text strings.Builder
+	printIngestReport(&text, report)
+	if !strings.Contains(text.String(), "LLM usage: 1400 tokens (1200 prompt, 200 completion)") {
+		t.Errorf("Expected the summary to total the LLM usage, got %q", text.String())
+	}
+	if !strings.Contains(text.String(), "Extracted entities from 2 of 3 chunks (1 stored without entities)") {
+		t.Errorf("Expected the summary to count the extraction failures, got %q", text.String())
+	}
+	if !strings.Contains(text.String(), "Estimated LLM cost: $0.0002") {
+		t.Errorf("Expected the summary to total the LLM cost, got %q", text.String())
+	}
+	if !strings.Contains(text.String(), "LLM mistral chat: 5 requests, 1 errors (upstream: 1), p50 800ms, p95 2.5s, 1200 tokens in, 200 out") {
+		t.Errorf("Expected the summary to aggregate the LLM requests, got %q", text.String())
+	}
+	if !strings.Contains(text.String(), "Budget: 3 of 3 retries, 1400 tokens, 12s of 1m0s backoff (exhausted: 3 retries used)") {
+		t.Errorf("Expected the summary to show the budget, got %q", text.String())
+	}
+	if !strings.Contains(text.String(), "Embedding usage: 640 tokens") {
+		t.Errorf("Expected the summary to show the embedding usage, got %q", text.String())
+	}
+
+	report.EmbeddingUsage = embedding.Usage{Unknown: true}
+	text.Reset()
+	printIngestReport(&text, report)
+	if !strings.Contains(text.String(), "Embedding usage: not reported by the provider") {
+		t.Errorf("Expected the summary to say the embedding usage is unknown, got %q", text.String())
+	}
+}
+
+func TestQuery_FallsBackToKeywordSearchWithoutEmbeddingKey(t *testing.T) {
+	dir := seedGraph(t)
+	t.Setenv("MISTRAL_API_KEY", "")
+
+	code, stdout, stderr := runCLI(t, "-d", dir, "query", "cypher", "--embedding-provider", "mistral", "--json")
+	if code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d (stderr: %s)", exitOK, code, stderr)
+	}
+	var response api.QueryResponse
+	if err := json.Unmarshal([]byte(stdout), &response); err != nil {
+		t.Fatalf("Expected a query response, got %q: %v", stdout, err)
+	}
+	if !response.KeywordOnly || len(response.Results) != 1 || response.Results[0].ID != "doc1-1" {
+		t.Errorf("Expected the keyword match marked keyword-only, got %+v", response)
+	}
+
+	code, stdout, _ = runCLI(t, "-d", dir, "query", "cypher", "--embedding-provider", "mistral")
+	if code != exitOK || !strings.HasPrefix(stdout, "Keyword matches only: set MISTRAL_API_KEY") {
+		t.Errorf("Expected the text output to note keyword-only results, got %q", stdout)
+	}
+
+	// A configured provider still ranks by similarity.
+	_, stdout, _ = runCLI(t, "-d", dir, "query", "cypher", "--embedding-provider", "testing", "--json")
+	if strings.Contains(stdout, "keyword_only") {
+		t.Errorf("Expected similarity results, got %s", stdout)
+	}
+}
+
+func TestQuery_RefusesAnotherEmbedder(t *testing.T) {
+	dir := seedGraph(t)
+	store, err := storage.Open(dir, false)
+	if err != nil {
+		t.Fatalf("Failed to open memory graph: %v", err)
+	}
+	if err := store.SetEmbeddingProvider(context.Background(), "gemini"); err != nil {
+		t.Fatalf("Failed to record provider: %v", err)
+	}
+	store.Close()
+
+	code, _, stderr := runCLI(t, "-d", dir, "query", "kuzu", "--embedding-provider", "testing")
+	if code != exitFailure || !strings.Contains(stderr, "memory graph was embedded with gemini, not testing") {
+		t.Errorf("Expected the mismatched provider to be refused, got exit code %d and %q", code, stderr)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	snippet := retrieval.Snippet{Text: "Kuzu Inc builds KuzuDB.", Highlights: []retrieval.Span{{Start: 0, End: 4}, {Start: 16, End: 22}}}
+
+	if got := highlight(snippet, false); got != snippet.Text {
+		t.Errorf("Expected the plain text without color, got %q", got)
+	}
+	if got, want := highlight(snippet, true), "\033[1mKuzu\033[0m Inc builds \033[1mKuzuDB\033[0m."; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestQuery_RouteReportsDecision(t *testing.T) {
+	dir := seedGraph(t)
+	t.Setenv("MISTRAL_API_KEY", "")
+
+	code, stdout, stderr := runCLI(t, "-d", dir, "query", "Who is Kuzu Inc?", "--route", "--embedding-provider", "testing", "--json")
+	if code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d (stderr: %s)", exitOK, code, stderr)
+	}
+	var response api.QueryResponse
+	if err := json.Unmarshal([]byte(stdout), &response); err != nil {
+		t.Fatalf("Expected a query response, got %q: %v", stdout, err)
+	}
+	want := &api.RouteDecision{Route: "entity", Entities: []string{"Kuzu Inc"}, Confidence: 0.7, Classifier: "heuristic", Strategy: "graph"}
+	if !reflect.DeepEqual(response.Route, want) {
+		t.Errorf("Expected route %+v, got %+v", want, response.Route)
+	}
+	if len(response.Results) != 1 || response.Results[0].ID != "doc1-0" {
+		t.Errorf("Expected the chunk mentioning Kuzu Inc, got %+v", response.Results)
+	}
+}
+
+func TestQuery_GroupsByDocument(t *testing.T) {
+	dir := seedGraph(t)
+
+	code, stdout, stderr := runCLI(t, "-d", dir, "query", "what does kuzu build", "--embedding-provider", "testing", "--min-score", "-1",
+		"--group", "--group-size", "1", "--context", "--json")
+	if code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d (stderr: %s)", exitOK, code, stderr)
+	}
+	var response api.QueryResponse
+	if err := json.Unmarshal([]byte(stdout), &response); err != nil {
+		t.Fatalf("Expected a query response, got %q: %v", stdout, err)
+	}
+	if len(response.Results) != 2 {
+		t.Errorf("Expected both chunks in the flat results, got %+v", response.Results)
+	}
+	if len(response.Groups) != 1 || response.Groups[0].DocumentID != "doc1" || response.Groups[0].Omitted != 1 || len(response.Groups[0].Results) != 1 {
+		t.Fatalf("Expected one doc1 group with one chunk shown, got %+v", response.Groups)
+	}
+	best := response.Groups[0].Results[0]
+	if best.ID != "doc1-0" || best.Before != nil || best.After == nil || best.After.ID != "doc1-1" || best.After.Source != "notes/kuzu.md" {
+		t.Errorf("Expected doc1-0 followed by doc1-1, got %+v", best)
+	}
+
+	code, stdout, _ = runCLI(t, "-d", dir, "query", "what does kuzu build", "--embedding-provider", "testing", "--min-score", "-1", "--snippet-length", "0", "--group", "--context")
+	want := "[1] notes/kuzu.md (score 1.000)\n" +
+		"  notes/kuzu.md:0-23 (score 1.000)\n  Kuzu Inc builds KuzuDB.\n" +
+		"  notes/kuzu.md:24-45 (score -0.049)\n  KuzuDB speaks Cypher.\n\n"
+	if code != exitOK || stdout != want {
+		t.Errorf("Expected grouped text output %q, got %q", want, stdout)
+	}
+}
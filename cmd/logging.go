@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// logFile is the file opened for --log-file, closed by closeLogFile when the
+// command returns.
+var logFile *os.File
+
+// setupLogging installs the default slog handler from the persistent logging flags.
+// Logs always go to stderr or the --log-file, never stdout, because stdout carries
+// MCP stdio traffic and command output.
+func setupLogging(cmd *cobra.Command) error {
+	levelName, _ := cmd.Flags().GetString("log-level")
+	format, _ := cmd.Flags().GetString("log-format")
+	logPath, _ := cmd.Flags().GetString("log-file")
+	quiet, _ := cmd.Flags().GetBool("quiet")
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(levelName)); err != nil {
+		return usageErrorf("invalid --log-level %q: expected debug, info, warn or error", levelName)
+	}
+	if quiet {
+		level = slog.LevelError
+	}
+
+	out := cmd.ErrOrStderr()
+	if logPath != "" {
+		f, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return fmt.Errorf("failed to open log file %s: %w", logPath, err)
+		}
+		closeLogFile()
+		logFile, out = f, f
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "text":
+		handler = slog.NewTextHandler(out, opts)
+	case "json":
+		handler = slog.NewJSONHandler(out, opts)
+	default:
+		return usageErrorf("invalid --log-format %q: expected text or json", format)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// closeLogFile closes the --log-file, if one was opened, and sends any later logs
+// to stderr.
+func closeLogFile() {
+	if logFile == nil {
+		return
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	if err := logFile.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to close log file %s: %v\n", logFile.Name(), err)
+	}
+	logFile = nil
+}
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mattn/go-isatty"
+	"github.com/sandwichlabs/agent-memory-graph/internal/ingest"
+)
+
+// progressLogInterval is how often the non-TTY fallback prints a status line while
+// a source is being processed.
+const progressLogInterval = 10 * time.Second
+
+// ingestProgress renders ingest progress events. On a terminal it redraws a single
+// status line; otherwise it prints a line per finished source and a periodic status
+// line for long-running ones.
+type ingestProgress struct {
+	out      io.Writer
+	tty      bool
+	interval time.Duration
+	now      func() time.Time
+
+	start    time.Time
+	lastLine time.Time
+	errors   int
+	drawn    bool
+}
+
+// newIngestProgress renders to out, redrawing in place when out is a terminal.
+func newIngestProgress(out io.Writer) *ingestProgress {
+	return &ingestProgress{out: out, tty: isTerminal(out), interval: progressLogInterval, now: time.Now}
+}
+
+// isTerminal reports whether out is a terminal.
+func isTerminal(out io.Writer) bool {
+	f, ok := out.(*os.File)
+	return ok && (isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd()))
+}
+
+// Update consumes one progress event.
+func (p *ingestProgress) Update(event ingest.Progress) {
+	now := p.now()
+	if p.start.IsZero() {
+		p.start, p.lastLine = now, now
+	}
+
+	if event.Stage == ingest.StageDone {
+		if event.Result.Err != nil {
+			p.errors++
+		}
+		if !p.tty {
+			p.lastLine = now
+			fmt.Fprintf(p.out, "[%d/%d] %s %s\n", event.File, event.Files, event.Source, outcome(event.Result))
+			return
+		}
+	}
+
+	if p.tty {
+		fmt.Fprintf(p.out, "\r\033[K%s", p.status(event, now))
+		p.drawn = true
+		return
+	}
+	if now.Sub(p.lastLine) >= p.interval {
+		p.lastLine = now
+		fmt.Fprintln(p.out, p.status(event, now))
+	}
+}
+
+// Finish clears the live status line so the final report starts on a clean line.
+func (p *ingestProgress) Finish() {
+	if p.drawn {
+		fmt.Fprint(p.out, "\r\033[K")
+		p.drawn = false
+	}
+}
+
+// status formats the current file, stage, chunk counts, timing and error count.
+func (p *ingestProgress) status(event ingest.Progress, now time.Time) string {
+	line := fmt.Sprintf("[%d/%d] %s %s", event.File, event.Files, filepath.Base(event.Source), event.Stage)
+	if event.Chunks > 0 {
+		line += fmt.Sprintf(" %d/%d chunks", event.Chunk, event.Chunks)
+	}
+	elapsed := now.Sub(p.start)
+	line += " · " + formatDuration(elapsed) + " elapsed"
+
+	// Estimate from the fraction of the batch done, counting partial files by chunk.
+	done := float64(event.File - 1)
+	if event.Stage == ingest.StageDone {
+		done = float64(event.File)
+	} else if event.Chunks > 0 {
+		done += float64(event.Chunk) / float64(event.Chunks)
+	}
+	if fraction := done / float64(event.Files); fraction > 0 && fraction < 1 {
+		remaining := time.Duration(float64(elapsed) * (1 - fraction) / fraction)
+		line += " · ~" + formatDuration(remaining) + " left"
+	}
+	if p.errors > 0 {
+		line += fmt.Sprintf(" · %d error(s)", p.errors)
+	}
+	return line
+}
+
+func outcome(result *ingest.Result) string {
+	if result.Err != nil {
+		return "failed: " + result.Err.Error()
+	}
+	if result.Status == ingest.StatusUnchanged {
+		return "unchanged"
+	}
+	return fmt.Sprintf("%s (%d chunks)", result.Status, result.Chunks)
+}
+
+// formatDuration renders d as m:ss, or h:mm:ss past an hour.
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	h, m, s := int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/ingest"
+)
+
+func TestIngestProgress_PlainFallback(t *testing.T) {
+	var out bytes.Buffer
+	clock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	p := newIngestProgress(&out)
+	p.now = func() time.Time { return clock }
+
+	if p.tty {
+		t.Fatalf("Expected a buffer not to be treated as a terminal")
+	}
+
+	p.Update(ingest.Progress{Source: "docs/a.md", File: 1, Files: 2, Stage: ingest.StageLoading})
+	clock = clock.Add(5 * time.Second)
+	p.Update(ingest.Progress{Source: "docs/a.md", File: 1, Files: 2, Stage: ingest.StageEmbedding, Chunk: 1, Chunks: 4})
+	clock = clock.Add(5 * time.Second)
+	p.Update(ingest.Progress{Source: "docs/a.md", File: 1, Files: 2, Stage: ingest.StageEmbedding, Chunk: 2, Chunks: 4})
+	clock = clock.Add(10 * time.Second)
+	p.Update(ingest.Progress{Source: "docs/a.md", File: 1, Files: 2, Stage: ingest.StageDone, Chunk: 4, Chunks: 4,
+		Result: &ingest.Result{Source: "docs/a.md", Status: ingest.StatusIngested, Chunks: 4}})
+	clock = clock.Add(2 * time.Second)
+	p.Update(ingest.Progress{Source: "docs/b.md", File: 2, Files: 2, Stage: ingest.StageDone,
+		Result: &ingest.Result{Source: "docs/b.md", Status: ingest.StatusFailed, Err: errors.New("boom")}})
+	p.Finish()
+
+	expected := "[1/2] a.md embedding 2/4 chunks · 0:10 elapsed · ~0:30 left\n" +
+		"[1/2] docs/a.md ingested (4 chunks)\n" +
+		"[2/2] docs/b.md failed: boom\n"
+	if out.String() != expected {
+		t.Errorf("Expected output:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestIngestProgress_StatusCountsErrors(t *testing.T) {
+	clock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	p := &ingestProgress{out: &bytes.Buffer{}, tty: true, now: func() time.Time { return clock }}
+
+	p.Update(ingest.Progress{Source: "a.md", File: 1, Files: 3, Stage: ingest.StageDone,
+		Result: &ingest.Result{Source: "a.md", Status: ingest.StatusFailed, Err: errors.New("boom")}})
+	clock = clock.Add(time.Minute)
+
+	status := p.status(ingest.Progress{Source: "b.md", File: 2, Files: 3, Stage: ingest.StageSaving, Chunk: 3, Chunks: 3}, clock)
+	expected := "[2/3] b.md saving 3/3 chunks · 1:00 elapsed · ~0:30 left · 1 error(s)"
+	if status != expected {
+		t.Errorf("Expected status %q, got %q", expected, status)
+	}
+}
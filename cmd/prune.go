@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/api"
+	"github.com/sandwichlabs/agent-memory-graph/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove old or temporary documents from the memory graph",
+	Long: `Remove the documents matching every given criterion, together with their chunks
+and any entities no remaining document mentions.
+
+--older-than takes a number of days, weeks or months (30 days), e.g. 180d, 6w or 3m.
+--tag matches documents ingested with amg ingest --tag.
+
+Use --dry-run to list what would be removed, or --yes to remove it without asking.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		olderThan, _ := cmd.Flags().GetString("older-than")
+		collection, _ := cmd.Flags().GetString("collection")
+		tag, _ := cmd.Flags().GetString("tag")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		if olderThan == "" && collection == "" && tag == "" {
+			return usageErrorf("specify at least one of --older-than, --collection or --tag")
+		}
+		if dryRun && yes {
+			return usageErrorf("--dry-run and --yes cannot be used together")
+		}
+		filter := storage.PruneFilter{Collection: collection, Tag: tag}
+		if olderThan != "" {
+			age, err := parseAge(olderThan)
+			if err != nil {
+				return usageErrorf("invalid --older-than: %v", err)
+			}
+			filter.IngestedBefore = time.Now().Add(-age)
+		}
+
+		dir := memoryDir(cmd)
+		if _, err := os.Stat(filepath.Join(dir, storage.DatabaseFile)); err != nil {
+			return fmt.Errorf("memory graph database not found at %s: %w", filepath.Join(dir, storage.DatabaseFile), err)
+		}
+		store, err := storage.Open(dir, dryRun)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		plan, err := store.PruneCandidates(cmd.Context(), filter)
+		if err != nil {
+			return err
+		}
+		out := cmd.OutOrStdout()
+		if dryRun || len(plan.Candidates) == 0 {
+			if jsonOutput(cmd) {
+				return writeJSON(out, api.NewPruneReport(plan, nil))
+			}
+			printPrunePlan(out, plan)
+			return nil
+		}
+
+		if !yes {
+			if !jsonOutput(cmd) {
+				printPrunePlan(out, plan)
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "Remove %d documents? [y/N] ", len(plan.Candidates))
+			answer, _ := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+			if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+				return fmt.Errorf("prune cancelled")
+			}
+		}
+
+		ids := make([]string, 0, len(plan.Candidates))
+		for _, candidate := range plan.Candidates {
+			ids = append(ids, candidate.ID)
+		}
+		result, err := store.Prune(cmd.Context(), ids)
+		if err != nil {
+			return err
+		}
+		if jsonOutput(cmd) {
+			return writeJSON(out, api.NewPruneReport(plan, &result))
+		}
+		fmt.Fprintf(out, "Removed %d documents, %d chunks and %d orphaned entities\n", result.Documents, result.Chunks, result.Entities)
+		return nil
+	},
+}
+
+func init() {
+	pruneCmd.Flags().String("older-than", "", "Only remove documents last ingested longer ago than this, e.g. 180d, 6w or 3m")
+	pruneCmd.Flags().String("collection", "", "Only remove documents in this collection")
+	pruneCmd.Flags().String("tag", "", "Only remove documents ingested with this tag")
+	pruneCmd.Flags().Bool("dry-run", false, "List the documents that would be removed without removing them")
+	pruneCmd.Flags().BoolP("yes", "y", false, "Remove without asking for confirmation")
+	pruneCmd.RegisterFlagCompletionFunc("collection", completeCollections)
+	rootCmd.AddCommand(pruneCmd)
+}
+
+// parseAge parses a whole number of days (d), weeks (w) or 30-day months (m).
+func parseAge(s string) (time.Duration, error) {
+	units := map[byte]time.Duration{'d': 24 * time.Hour, 'w': 7 * 24 * time.Hour, 'm': 30 * 24 * time.Hour}
+	if len(s) < 2 {
+		return 0, fmt.Errorf("%q is not an age like 180d, 6w or 3m", s)
+	}
+	unit, ok := units[s[len(s)-1]]
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if !ok || err != nil || n <= 0 {
+		return 0, fmt.Errorf("%q is not an age like 180d, 6w or 3m", s)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+func printPrunePlan(out io.Writer, plan *storage.PrunePlan) {
+	if len(plan.Candidates) == 0 {
+		fmt.Fprintln(out, "No documents match")
+		return
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SOURCE\tAGE\tSIZE\tCHUNKS\tENTITIES")
+	chunks := 0
+	for _, candidate := range plan.Candidates {
+		age := time.Since(candidate.IngestedAt)
+		fmt.Fprintf(w, "%s\t%dd\t%s\t%d\t%d\n", candidate.Source, int(age.Hours()/24), formatSize(candidate.Size), candidate.Chunks, candidate.Entities)
+		chunks += candidate.Chunks
+	}
+	w.Flush()
+	fmt.Fprintf(out, "Would remove %d documents, %d chunks and %d orphaned entities\n", len(plan.Candidates), chunks, plan.OrphanedEntities)
+}
+
+func formatSize(bytes int) string {
+	switch {
+	case bytes >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(bytes)/(1<<20))
+	case bytes >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(bytes)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", bytes)
+	}
+}
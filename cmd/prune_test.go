@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAge(t *testing.T) {
+	cases := map[string]time.Duration{
+		"180d": 180 * 24 * time.Hour,
+		"6w":   42 * 24 * time.Hour,
+		"3m":   90 * 24 * time.Hour,
+	}
+	for input, expected := range cases {
+		got, err := parseAge(input)
+		if err != nil {
+			t.Errorf("%s: Expected no error, got %v", input, err)
+		} else if got != expected {
+			t.Errorf("%s: Expected %v, got %v", input, expected, got)
+		}
+	}
+	for _, input := range []string{"", "d", "10", "10y", "-5d", "0w", "1.5m"} {
+		if _, err := parseAge(input); err == nil {
+			t.Errorf("%q: Expected an error, got nil", input)
+		}
+	}
+}
+
+func TestPrune_UsageErrors(t *testing.T) {
+	dir := newGraphDir(t)
+	cases := [][]string{
+		{"-d", dir, "prune"},
+		{"-d", dir, "prune", "--tag", "temp", "--dry-run", "--yes"},
+		{"-d", dir, "prune", "--older-than", "6 months"},
+	}
+	for _, args := range cases {
+		if code, _, stderr := runCLI(t, args...); code != exitUsage {
+			t.Errorf("%v: Expected exit code %d, got %d (stderr: %s)", args, exitUsage, code, stderr)
+		}
+	}
+}
+
+func TestPrune_DryRunThenYes(t *testing.T) {
+	dir := seedGraph(t)
+
+	code, stdout, stderr := runCLI(t, "-d", dir, "prune", "--collection", "research", "--dry-run")
+	if code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d (stderr: %s)", exitOK, code, stderr)
+	}
+	if !strings.Contains(stdout, "notes/kuzu.md") || !strings.Contains(stdout, "Would remove 1 documents, 2 chunks and 3 orphaned entities") {
+		t.Errorf("Expected the dry run to list notes/kuzu.md, got %q", stdout)
+	}
+
+	code, stdout, stderr = runCLI(t, "-d", dir, "prune", "--collection", "research", "--yes")
+	if code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d (stderr: %s)", exitOK, code, stderr)
+	}
+	if strings.TrimSpace(stdout) != "Removed 1 documents, 2 chunks and 3 orphaned entities" {
+		t.Errorf("Expected final counts, got %q", stdout)
+	}
+
+	code, stdout, _ = runCLI(t, "-d", dir, "prune", "--collection", "research", "--dry-run")
+	if code != exitOK || strings.TrimSpace(stdout) != "No documents match" {
+		t.Errorf("Expected nothing left to prune, got exit code %d and %q", code, stdout)
+	}
+}
@@ -0,0 +1,218 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/api"
+	"github.com/sandwichlabs/agent-memory-graph/internal/retrieval"
+	"github.com/spf13/cobra"
+)
+
+var queryCmd = &cobra.Command{
+	Use:   "query [text]",
+	Short: "Find the stored chunks most similar to the text",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		text := strings.Join(args, " ")
+		opts, err := searchOptions(cmd)
+		if err != nil {
+			return err
+		}
+		switch length, _ := cmd.Flags().GetInt("snippet-length"); {
+		case length < 0:
+			return usageErrorf("invalid --snippet-length %d: must not be negative", length)
+		case length > 0:
+			opts.Snippets = &retrieval.Snippets{Length: length}
+		}
+		group, _ := cmd.Flags().GetBool("group")
+		groupSize, _ := cmd.Flags().GetInt("group-size")
+		withContext, _ := cmd.Flags().GetBool("context")
+		switch {
+		case withContext && !group:
+			return usageErrorf("--context needs --group")
+		case groupSize < 1:
+			return usageErrorf("invalid --group-size %d: must be positive", groupSize)
+		case group:
+			opts.Grouping = &retrieval.Grouping{ChunksPerGroup: groupSize, Context: withContext}
+		}
+
+		results, err := search(cmd.Context(), "query", memoryDir(cmd), embeddingProvider(cmd), llmProvider(cmd), text, opts)
+		if err != nil {
+			return err
+		}
+		if jsonOutput(cmd) {
+			return writeJSON(cmd.OutOrStdout(), api.NewQueryResponse(text, results))
+		}
+
+		out := cmd.OutOrStdout()
+		if results.KeywordOnly {
+			fmt.Fprintln(out, keywordOnlyNote(embeddingProvider(cmd)))
+			fmt.Fprintln(out)
+		}
+		if len(results.Hits) == 0 {
+			fmt.Fprintln(out, "No relevant memories found.")
+			return nil
+		}
+		color := isTerminal(out)
+		body := func(hit retrieval.Hit) string {
+			if opts.Snippets != nil {
+				return highlight(hit.Snippet, color)
+			}
+			return hit.Content
+		}
+		if opts.Grouping != nil {
+			printGroups(out, results.Groups, body)
+			return nil
+		}
+		for i, hit := range results.Hits {
+			fmt.Fprintf(out, "[%d] %s (score %.3f)\n%s\n\n", i+1, citation(hit), hit.Score, body(hit))
+		}
+		return nil
+	},
+}
+
+// printGroups prints the hits of each document under its source, with the
+// surrounding chunks marked by "<" and ">" when the search asked for context.
+func printGroups(out io.Writer, groups []retrieval.Group, body func(retrieval.Hit) string) {
+	for i, group := range groups {
+		fmt.Fprintf(out, "[%d] %s (score %.3f)\n", i+1, group.Source, group.Score)
+		for _, hit := range group.Hits {
+			fmt.Fprintf(out, "  %s (score %.3f)\n", citation(hit), hit.Score)
+			if previous := hit.Context.Previous; previous != nil {
+				fmt.Fprintf(out, "  < %s\n", previous.Content)
+			}
+			fmt.Fprintf(out, "  %s\n", body(hit))
+			if next := hit.Context.Next; next != nil {
+				fmt.Fprintf(out, "  > %s\n", next.Content)
+			}
+		}
+		if group.Omitted > 0 {
+			fmt.Fprintf(out, "  (%d more from this document)\n", group.Omitted)
+		}
+		fmt.Fprintln(out)
+	}
+}
+
+func init() {
+	addSearchFlags(queryCmd)
+	queryCmd.Flags().Int("snippet-length", retrieval.DefaultSnippetLength, "Show the best-matching passage of each chunk in at most this many bytes (0 for whole chunks)")
+	queryCmd.Flags().Bool("group", false, "Group the chunks by document, ordered by each document's best chunk")
+	queryCmd.Flags().Int("group-size", retrieval.DefaultChunksPerGroup, "Show at most this many chunks per document with --group")
+	queryCmd.Flags().Bool("context", false, "Show the chunks before and after each grouped chunk")
+	rootCmd.AddCommand(queryCmd)
+}
+
+// highlight returns the snippet's text, with the matched query terms in bold when
+// color is set.
+func highlight(snippet retrieval.Snippet, color bool) string {
+	if !color {
+		return snippet.Text
+	}
+	var b strings.Builder
+	last := 0
+	for _, span := range snippet.Highlights {
+		b.WriteString(snippet.Text[last:span.Start])
+		b.WriteString("\033[1m" + snippet.Text[span.Start:span.End] + "\033[0m")
+		last = span.End
+	}
+	b.WriteString(snippet.Text[last:])
+	return b.String()
+}
+
+// addSearchFlags adds the retrieval flags shared by query and ask.
+func addSearchFlags(cmd *cobra.Command) {
+	cmd.Flags().Int("k", retrieval.DefaultK, "Number of chunks to retrieve")
+	cmd.Flags().Bool("hybrid", false, "Combine similarity search with keyword search")
+	cmd.Flags().Bool("diverse", false, "Prefer chunks that add new information over near-duplicates")
+	cmd.Flags().Bool("dedup", false, "Collapse near-identical chunks, such as overlapping chunks or revisions, into the best of them")
+	cmd.Flags().String("half-life", "", "Halve the score of chunks this old, like 7d, 2w or 1m, to favor recent memories")
+	cmd.Flags().Bool("route", false, "Answer questions about entities and their relationships from the knowledge graph (classified by the LLM when its API key is set)")
+	cmd.Flags().Bool("multi-query", false, "Also search LLM-written paraphrases of the query (costs an LLM call)")
+	cmd.Flags().String("collection", "", "Only search documents in this collection")
+	cmd.Flags().String("document", "", "Only search the document with this ID")
+	cmd.Flags().String("source-prefix", "", "Only search documents whose source starts with this path or URL")
+	cmd.Flags().StringSlice("tag", nil, "Only search documents with any of these tags")
+	cmd.Flags().Bool("all-tags", false, "Require every --tag instead of any")
+	cmd.Flags().String("since", "", "Only search documents ingested at or after this date (YYYY-MM-DD or RFC 3339)")
+	cmd.Flags().String("until", "", "Only search documents ingested at or before this date (YYYY-MM-DD or RFC 3339)")
+	cmd.Flags().Float64("min-score", 0, "Drop chunks whose similarity is below this score (-1 to 1, 0 for none; defaults to a threshold calibrated for the embedding provider)")
+	cmd.RegisterFlagCompletionFunc("collection", completeCollections)
+}
+
+// searchOptions builds the retrieval options from the flags added by addSearchFlags.
+func searchOptions(cmd *cobra.Command) (retrieval.SearchOptions, error) {
+	k, _ := cmd.Flags().GetInt("k")
+	hybrid, _ := cmd.Flags().GetBool("hybrid")
+	diverse, _ := cmd.Flags().GetBool("diverse")
+	tags, _ := cmd.Flags().GetStringSlice("tag")
+	allTags, _ := cmd.Flags().GetBool("all-tags")
+	since, _ := cmd.Flags().GetString("since")
+	until, _ := cmd.Flags().GetString("until")
+
+	opts := retrieval.SearchOptions{K: k, Hybrid: hybrid}
+	if diverse {
+		opts.MMR = &retrieval.MMR{Lambda: retrieval.DefaultMMRLambda}
+	}
+	if dedup, _ := cmd.Flags().GetBool("dedup"); dedup {
+		opts.Dedup = &retrieval.Dedup{Threshold: retrieval.DefaultDedupThreshold}
+	}
+	if halfLife, _ := cmd.Flags().GetString("half-life"); halfLife != "" {
+		age, err := parseAge(halfLife)
+		if err != nil {
+			return opts, usageErrorf("invalid --half-life: %v", err)
+		}
+		opts.Recency = &retrieval.Recency{HalfLife: age}
+	}
+	if multiQuery, _ := cmd.Flags().GetBool("multi-query"); multiQuery {
+		opts.Expansion = &retrieval.QueryExpansion{}
+	}
+	if route, _ := cmd.Flags().GetBool("route"); route {
+		opts.Routing = &retrieval.Routing{}
+	}
+	opts.Filter.Collection, _ = cmd.Flags().GetString("collection")
+	opts.Filter.DocumentID, _ = cmd.Flags().GetString("document")
+	opts.Filter.SourcePrefix, _ = cmd.Flags().GetString("source-prefix")
+	opts.Filter.MinScore = retrieval.DefaultMinScore(embeddingProvider(cmd))
+	if cmd.Flags().Changed("min-score") {
+		opts.Filter.MinScore, _ = cmd.Flags().GetFloat64("min-score")
+	}
+	if allTags {
+		opts.Filter.AllTags = tags
+	} else {
+		opts.Filter.AnyTags = tags
+	}
+
+	var err error
+	if opts.Filter.IngestedAfter, err = parseDate(since, false); err != nil {
+		return opts, usageErrorf("invalid --since: %v", err)
+	}
+	if opts.Filter.IngestedBefore, err = parseDate(until, true); err != nil {
+		return opts, usageErrorf("invalid --until: %v", err)
+	}
+	if err := opts.Filter.Validate(); err != nil {
+		return opts, usageErrorf("%v", err)
+	}
+	return opts, nil
+}
+
+// parseDate parses an RFC 3339 time or a YYYY-MM-DD date in local time. A bare date
+// means its start, or with endOfDay its last instant. An empty string is the zero time.
+func parseDate(s string, endOfDay bool) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a date like 2025-01-31 or 2025-01-31T09:00:00Z", s)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return t, nil
+}
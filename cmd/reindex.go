@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/embedding"
+	"github.com/sandwichlabs/agent-memory-graph/internal/ingest"
+	"github.com/spf13/cobra"
+)
+
+var reindexCmd = &cobra.Command{
+	Use:   "reindex",
+	Short: "Re-embed stored chunks with the selected embedding provider",
+	Long: `Re-embed every stored chunk with --embedding-provider, using --model instead of
+the model its environment variable names when given. The new vectors are staged
+alongside the old ones and swapped in together when all chunks are done, so the
+memory graph stays searchable during the run, and may be of another size than
+the old ones: the memory graph is resized to them at the swap. An interrupted
+reindex resumes where it stopped when run again with the same provider and
+model. There is no vector index to rebuild: searches scan the stored vectors,
+so they use the new ones as soon as they are swapped in.
+
+With --only-missing, only chunks that have no vector are embedded, using the
+provider the memory graph was built with.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		onlyMissing, _ := cmd.Flags().GetBool("only-missing")
+		batchSize, _ := cmd.Flags().GetInt("batch-size")
+		concurrency, _ := cmd.Flags().GetInt("embed-concurrency")
+		if concurrency < 1 {
+			return usageErrorf("--embed-concurrency must be at least 1")
+		}
+		model, _ := cmd.Flags().GetString("model")
+		quiet, _ := cmd.Flags().GetBool("quiet")
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		provider := embeddingProvider(cmd)
+		err := ingest.Reindex(ctx, memoryDir(cmd), ingest.ReindexOptions{
+			EmbeddingProvider: provider,
+			Model:             model,
+			OnlyMissing:       onlyMissing,
+			BatchSize:         batchSize,
+			Concurrency:       concurrency,
+			Progress: func(done, total int) {
+				if !quiet {
+					fmt.Fprintf(cmd.ErrOrStderr(), "Embedded %d/%d chunks\n", done, total)
+				}
+			},
+		})
+		if err != nil {
+			return err
+		}
+		if model != "" {
+			fmt.Fprintf(cmd.OutOrStdout(), "Reindexed the memory graph with %s (%s)\n", provider, model)
+			return nil
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Reindexed the memory graph with %s\n", provider)
+		return nil
+	},
+}
+
+func init() {
+	reindexCmd.Flags().Bool("only-missing", false, "Only embed chunks that have no vector")
+	reindexCmd.Flags().String("model", "", "Embedding model of the provider to reindex with")
+	reindexCmd.Flags().Int("batch-size", ingest.DefaultReindexBatchSize, "Chunks to embed and write per transaction")
+	reindexCmd.Flags().Int("embed-concurrency", embedding.DefaultConcurrency, "Number of chunks to embed at once")
+	rootCmd.AddCommand(reindexCmd)
+}
@@ -2,8 +2,11 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
 
+	"github.com/sandwichlabs/agent-memory-graph/internal/embedding"
+	"github.com/sandwichlabs/agent-memory-graph/internal/llm"
 	"github.com/sandwichlabs/agent-memory-graph/internal/server"
 	"github.com/spf13/cobra"
 )
@@ -12,27 +15,116 @@ var rootCmd = &cobra.Command{
 	Use:   "amg [Path to Memory Graph Directory]",
 	Short: "A CLI to extend MCP with graph data.",
 	Long:  `amg is a command-line tool that exposes memory management and knowledge retrieval functions for MCP.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return setupLogging(cmd)
+	},
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
 		if len(args) == 0 {
-			cmd.Help()
-			return
+			return cmd.Help()
 		}
 		servername, _ := cmd.Flags().GetString("name")
 		if servername == "" {
 			servername = "knowledge"
 		}
 
-		server.Run(args[0], servername)
+		preflight, _ := cmd.Flags().GetBool("preflight")
+		return server.Run(args[0], servername, embeddingProvider(cmd), llmProvider(cmd), preflight)
 	},
 }
 
 func init() {
 	rootCmd.Flags().String("name", "", "Name of the MCP server (default: 'tasks')")
+	rootCmd.Flags().Bool("preflight", false, "Check the embedding and LLM providers' keys and connectivity before serving")
+
+	rootCmd.PersistentFlags().StringP("dir", "d", "", "Memory graph directory (default: $AMG_DIR or the current directory)")
+	rootCmd.PersistentFlags().String("embedding-provider", "", "Embedding provider (default $"+embedding.EnvProvider+", or mistral)")
+	rootCmd.PersistentFlags().String("llm-provider", "", "LLM provider (default $"+llm.EnvProvider+", or mistral)")
+	rootCmd.RegisterFlagCompletionFunc("embedding-provider", completeEmbeddingProviders)
+	rootCmd.RegisterFlagCompletionFunc("llm-provider", completeLlmProviders)
+	rootCmd.PersistentFlags().String("log-level", "info", "Log level: debug, info, warn or error")
+	rootCmd.PersistentFlags().String("log-format", "text", "Log format: text or json")
+	rootCmd.PersistentFlags().String("log-file", "", "Write logs to this file instead of stderr")
+	rootCmd.PersistentFlags().BoolP("quiet", "q", false, "Suppress everything except errors and final summaries")
+	rootCmd.PersistentFlags().Bool("json", false, "Print results as a single JSON document on stdout (query, stats, entities, doctor, ingest, prune, eval)")
+}
+
+// memoryDir resolves the memory graph directory from --dir, then AMG_DIR,
+// falling back to the current directory.
+func memoryDir(cmd *cobra.Command) string {
+	if dir, _ := cmd.Flags().GetString("dir"); dir != "" {
+		return dir
+	}
+	if dir := os.Getenv("AMG_DIR"); dir != "" {
+		return dir
+	}
+	return "."
+}
+
+// jsonOutput reports whether --json was given.
+func jsonOutput(cmd *cobra.Command) bool {
+	asJSON, _ := cmd.Flags().GetBool("json")
+	return asJSON
+}
+
+// embeddingProvider resolves the embedding provider from --embedding-provider,
+// then AMG_EMBEDDING_PROVIDER, then mistral. An unknown name is returned as
+// given, for creating the service to report with the valid choices.
+func embeddingProvider(cmd *cobra.Command) embedding.Provider {
+	provider, _ := cmd.Flags().GetString("embedding-provider")
+	if provider == "" {
+		provider = os.Getenv(embedding.EnvProvider)
+	}
+	if provider == "" {
+		return embedding.ProviderMistral
+	}
+	if parsed, err := embedding.ParseProvider(provider); err == nil {
+		return parsed
+	}
+	return embedding.Provider(provider)
 }
 
+// llmProvider resolves the LLM provider from --llm-provider, then
+// AMG_LLM_PROVIDER, then mistral. An unknown name is returned as given, for
+// creating the service to report with the valid choices.
+func llmProvider(cmd *cobra.Command) llm.Provider {
+	provider, _ := cmd.Flags().GetString("llm-provider")
+	if provider == "" {
+		provider = os.Getenv(llm.EnvProvider)
+	}
+	if provider == "" {
+		return llm.ProviderMistral
+	}
+	if parsed, err := llm.ParseProvider(provider); err == nil {
+		return parsed
+	}
+	return llm.Provider(provider)
+}
+
+// Execute runs the command line and exits with the resulting exit code.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes args against the root command and returns the process exit code.
+// Errors are printed to stderr; usage errors also point at the command's help.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	wrapArgsValidators(rootCmd)
+	rootCmd.SetArgs(args)
+	rootCmd.SetIn(stdin)
+	rootCmd.SetOut(stdout)
+	rootCmd.SetErr(stderr)
+	defer closeLogFile()
+
+	cmd, err := rootCmd.ExecuteC()
+	if err == nil {
+		return exitOK
+	}
+	fmt.Fprintf(stderr, "Error: %v\n", err)
+	code := exitCode(err)
+	if code == exitUsage {
+		fmt.Fprintf(stderr, "Run '%s --help' for usage.\n", cmd.CommandPath())
 	}
+	return code
 }
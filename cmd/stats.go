@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/api"
+	"github.com/sandwichlabs/agent-memory-graph/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show document, chunk, entity and relationship counts",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := storage.Open(memoryDir(cmd), true)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		stored, err := store.Stats(cmd.Context())
+		if err != nil {
+			return err
+		}
+		stats := api.NewStats(stored)
+		if jsonOutput(cmd) {
+			return writeJSON(cmd.OutOrStdout(), stats)
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "Documents:\t%d\n", stats.Documents)
+		fmt.Fprintf(w, "Chunks:\t%d\n", stats.Chunks)
+		fmt.Fprintf(w, "Entities:\t%d\n", stats.Entities)
+		fmt.Fprintf(w, "Relationships:\t%d\n", stats.Relationships)
+		for _, collection := range stats.Collections {
+			fmt.Fprintf(w, "Collection %s:\t%d documents\n", collection.Name, collection.Documents)
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+}
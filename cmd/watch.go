@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/ingest"
+	"github.com/sandwichlabs/agent-memory-graph/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch <dir>",
+	Short: "Keep the memory graph in sync with a directory until interrupted",
+	Long: `Ingest every file under dir, then watch it: changed files are re-ingested and
+deleted files are removed from the memory graph. Stop with Ctrl-C.
+
+The database stays open for writing while watch runs, so other amg commands
+against the same memory graph will fail until it exits.`,
+	Args: cobra.ExactArgs(1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		include, _ := cmd.Flags().GetStringSlice("include")
+		exclude, _ := cmd.Flags().GetStringSlice("exclude")
+		collection, _ := cmd.Flags().GetString("collection")
+		interval, _ := cmd.Flags().GetDuration("interval")
+		daemonLog, _ := cmd.Flags().GetString("daemon-log")
+
+		if info, err := os.Stat(args[0]); err != nil || !info.IsDir() {
+			return usageErrorf("%s is not a directory", args[0])
+		}
+
+		out := cmd.OutOrStdout()
+		if daemonLog != "" {
+			f, err := os.OpenFile(daemonLog, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+			if err != nil {
+				return fmt.Errorf("failed to open daemon log %s: %w", daemonLog, err)
+			}
+			defer f.Close()
+			out = f
+			if logFile, _ := cmd.Flags().GetString("log-file"); logFile == "" {
+				cmd.Flags().Set("log-file", daemonLog)
+				if err := setupLogging(cmd); err != nil {
+					return err
+				}
+			}
+		}
+
+		ingestor, err := ingest.NewIngestor(memoryDir(cmd), ingest.Options{
+			Collection:        collection,
+			EmbeddingProvider: embeddingProvider(cmd),
+			LlmProvider:       llmProvider(cmd),
+		})
+		if errors.Is(err, storage.ErrLocked) {
+			return fmt.Errorf("cannot watch: %w; stop the other amg process first", err)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to start watching: %w", err)
+		}
+		defer ingestor.Close()
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		fmt.Fprintf(out, "Watching %s (Ctrl-C to stop)\n", args[0])
+		err = ingestor.Watch(ctx, args[0], ingest.WatchOptions{
+			Include:  include,
+			Exclude:  exclude,
+			Interval: interval,
+		}, func(result ingest.Result) {
+			printWatchEvent(out, result)
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "Stopped watching")
+		return nil
+	},
+}
+
+func init() {
+	watchCmd.Flags().StringSlice("include", nil, "Only watch files matching these glob patterns (relative path or base name)")
+	watchCmd.Flags().StringSlice("exclude", nil, "Ignore files matching these glob patterns (relative path or base name)")
+	watchCmd.Flags().String("collection", "", "Collection to store the documents in (default: 'default')")
+	watchCmd.Flags().Duration("interval", ingest.DefaultWatchInterval, "How often to scan the directory for changes")
+	watchCmd.Flags().String("daemon-log", "", "Append file events and logs to this file instead of stdout/stderr")
+	watchCmd.RegisterFlagCompletionFunc("collection", completeCollections)
+	rootCmd.AddCommand(watchCmd)
+}
+
+func printWatchEvent(out io.Writer, result ingest.Result) {
+	timestamp := time.Now().Format(time.TimeOnly)
+	if result.Err != nil {
+		fmt.Fprintf(out, "%s failed   %s: %v\n", timestamp, result.Source, result.Err)
+		return
+	}
+	if result.Status == ingest.StatusRemoved {
+		fmt.Fprintf(out, "%s removed  %s\n", timestamp, result.Source)
+		return
+	}
+	fmt.Fprintf(out, "%s %-8s %s (%d chunks)\n", timestamp, result.Status, result.Source, result.Chunks)
+}
@@ -0,0 +1,622 @@
+// Package api defines the JSON documents produced by the amg CLI's --json mode.
+// The same types are returned as structured content by the MCP server, so field
+// names and tags are part of the public contract: add fields, don't rename them.
+package api
+
+import (
+	"sort"
+	"time"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/eval"
+	"github.com/sandwichlabs/agent-memory-graph/internal/ingest"
+	"github.com/sandwichlabs/agent-memory-graph/internal/retrieval"
+	"github.com/sandwichlabs/agent-memory-graph/internal/storage"
+)
+
+// Chunk is a passage of a stored document.
+type Chunk struct {
+	ID     string `json:"id"`
+	Source string `json:"source"`
+	Index  int    `json:"index"`
+	// StartOffset and EndOffset are byte offsets into the source, or -1 when unknown.
+	StartOffset int `json:"start_offset"`
+	EndOffset   int `json:"end_offset"`
+	// Page is the page of the PDF the chunk was read from, counted from 1.
+	Page    int     `json:"page,omitempty"`
+	Content string  `json:"content"`
+	Score   float64 `json:"score,omitempty"`
+	// Snippet is the passage of a search hit that best matches the query.
+	Snippet *Snippet `json:"snippet,omitempty"`
+	// Collapsed lists the IDs of near-duplicate chunks folded into a search hit.
+	Collapsed []string `json:"collapsed,omitempty"`
+	// Before and After are the chunks around a grouped search hit, when asked for
+	// and not shown in the group already.
+	Before *Chunk `json:"before,omitempty"`
+	After  *Chunk `json:"after,omitempty"`
+}
+
+// Snippet is an excerpt of a chunk with the query terms it matches.
+type Snippet struct {
+	Text string `json:"text"`
+	// Highlights are [start, end) byte offsets into Text of the matched terms.
+	Highlights [][2]int `json:"highlights"`
+}
+
+// QueryResponse is the result of a similarity search.
+type QueryResponse struct {
+	Query   string  `json:"query"`
+	Results []Chunk `json:"results"`
+	// NoRelevantResults is set when the graph has chunks but none relevant enough
+	// to the query to be returned.
+	NoRelevantResults bool `json:"no_relevant_results,omitempty"`
+	// KeywordOnly is set when no embedding provider was usable and the results
+	// were ranked by keyword search alone.
+	KeywordOnly bool `json:"keyword_only,omitempty"`
+	// Route is how a search with --route was answered.
+	Route *RouteDecision `json:"route,omitempty"`
+	// Groups holds the results grouped by document for a search with --group, or
+	// with group on search_memory. Results keeps every hit in rank order.
+	Groups []Group `json:"groups,omitempty"`
+	// NextCursor, passed back as search_memory's cursor, returns the next page of
+	// the ranking. It is the same for the grouped and flat shapes, and empty on
+	// the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Group is the search results from one document, ordered by its best result.
+type Group struct {
+	DocumentID string  `json:"document_id"`
+	Source     string  `json:"source"`
+	Collection string  `json:"collection"`
+	Score      float64 `json:"score"`
+	Results    []Chunk `json:"results"`
+	// Omitted counts the document's results beyond the group size.
+	Omitted int `json:"omitted,omitempty"`
+}
+
+// AnswerResponse is an answer to a question from the memory graph.
+type AnswerResponse struct {
+	Question string `json:"question"`
+	// Query is the standalone query a follow-up question was rewritten into and
+	// searched as, when it differs from Question.
+	Query string `json:"query,omitempty"`
+	// Answer cites the chunks it draws on inline like [1], numbered as in
+	// Citations.
+	Answer    string     `json:"answer"`
+	Citations []Citation `json:"citations"`
+	// DroppedCitations holds the citation numbers the model made up, which were
+	// removed from Answer.
+	DroppedCitations []int `json:"dropped_citations,omitempty"`
+	// Partial is set when generation stopped early and Answer holds what was
+	// generated until then.
+	Partial bool `json:"partial,omitempty"`
+	// Confidence is the mean retrieval score of the cited chunks, from 0 to 1.
+	Confidence float64 `json:"confidence"`
+	// NoRelevantResults is set when nothing in the graph was relevant enough to
+	// answer from.
+	NoRelevantResults bool `json:"no_relevant_results,omitempty"`
+}
+
+// Citation is a chunk cited by an answer as [Number].
+type Citation struct {
+	Number int   `json:"number"`
+	Chunk  Chunk `json:"chunk"`
+}
+
+// RouteDecision is the classification of a routed query and the strategy it got:
+// graph, blended or hybrid.
+type RouteDecision struct {
+	Route      string   `json:"route"`
+	Entities   []string `json:"entities"`
+	Confidence float64  `json:"confidence"`
+	Classifier string   `json:"classifier"`
+	Strategy   string   `json:"strategy"`
+}
+
+// Entity is an entity with its mention and relationship counts.
+type Entity struct {
+	Name          string   `json:"name"`
+	Type          string   `json:"type"`
+	Aliases       []string `json:"aliases"`
+	Mentions      int      `json:"mentions"`
+	Relationships int      `json:"relationships"`
+}
+
+// Relationship is a directed, labeled edge between two entities.
+type Relationship struct {
+	Subject   string `json:"subject"`
+	Predicate string `json:"predicate"`
+	Object    string `json:"object"`
+}
+
+// EntityDetail is an entity with its relationships and top mentioning chunks.
+type EntityDetail struct {
+	Entity        Entity         `json:"entity"`
+	Relationships []Relationship `json:"relationships"`
+	Chunks        []Chunk        `json:"chunks"`
+}
+
+// Collection is a named group of documents.
+type Collection struct {
+	Name      string `json:"name"`
+	Documents int    `json:"documents"`
+}
+
+// Stats summarizes the contents of a memory graph.
+type Stats struct {
+	Documents     int          `json:"documents"`
+	Chunks        int          `json:"chunks"`
+	Entities      int          `json:"entities"`
+	Relationships int          `json:"relationships"`
+	Collections   []Collection `json:"collections"`
+	// QueryCache counts the lookups of the query embedding cache of the MCP
+	// server reporting the statistics.
+	QueryCache *QueryCacheStats `json:"query_cache,omitempty"`
+}
+
+// QueryCacheStats counts the lookups of a query embedding cache.
+type QueryCacheStats struct {
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+	Entries int   `json:"entries"`
+}
+
+// IngestResult is the outcome of ingesting one source.
+type IngestResult struct {
+	Source string `json:"source"`
+	// Status is one of ingested, updated, unchanged, removed or failed.
+	Status string `json:"status"`
+	Chunks int    `json:"chunks"`
+	// ExtractionFailures is how many of the chunks are stored without entities as
+	// extracting them failed.
+	ExtractionFailures int `json:"extraction_failures"`
+	// EmbeddingFailures is how many of the chunks are stored without a vector as
+	// the embedding provider refused them.
+	EmbeddingFailures int    `json:"embedding_failures"`
+	Error             string `json:"error,omitempty"`
+	// OverLimitChunks are the chunks over the embedding model's input limit and
+	// what was done about them; omitted when there were none.
+	OverLimitChunks []OverLimitChunk `json:"over_limit_chunks,omitempty"`
+}
+
+// OverLimitChunk is a chunk over the embedding model's input limit.
+type OverLimitChunk struct {
+	// Chunk is the index of the chunk, or of the first that replaced it.
+	Chunk  int `json:"chunk"`
+	Tokens int `json:"tokens"`
+	// Action is truncated, split or rechunked.
+	Action string `json:"action"`
+}
+
+// Usage counts the LLM tokens spent, as reported by the provider.
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// IngestReport is the outcome of a batch ingest.
+type IngestReport struct {
+	Results  []IngestResult `json:"results"`
+	Ingested int            `json:"ingested"`
+	Failed   int            `json:"failed"`
+	// Usage is the LLM tokens spent extracting entities across the batch.
+	Usage Usage `json:"usage"`
+	// EmbeddingUsage is the tokens the embedding provider billed for the batch.
+	EmbeddingUsage EmbeddingUsage `json:"embedding_usage"`
+	// EstimatedCostUSD is the estimated price of Usage in US dollars, or null when
+	// some of it was spent on a model without a known price.
+	EstimatedCostUSD *float64 `json:"estimated_cost_usd"`
+	// LLMCalls aggregates the requests made to LLM providers by provider and
+	// method; omitted when none were made.
+	LLMCalls []LLMCallStats `json:"llm_calls,omitempty"`
+	// EmbeddingCalls aggregates the requests made to embedding providers, and the
+	// lookups of the embedding cache, by provider and method; omitted when none
+	// were made.
+	EmbeddingCalls []EmbeddingCallStats `json:"embedding_calls,omitempty"`
+	// Budget is what the run's budget allowed and what was spent of it; omitted
+	// when the run had no budget.
+	Budget *Budget `json:"budget,omitempty"`
+	// EmbeddingCache counts the chunks found in the embedding cache and those
+	// embedded; omitted when the cache was off or nothing was embedded.
+	EmbeddingCache *EmbeddingCache `json:"embedding_cache,omitempty"`
+}
+
+// EmbeddingUsage is the tokens an embedding provider billed for.
+type EmbeddingUsage struct {
+	PromptTokens int64 `json:"prompt_tokens"`
+	// Unknown is set when the provider does not report usage, so that zero
+	// tokens does not mean the embedding was free.
+	Unknown bool `json:"unknown"`
+}
+
+// EmbeddingCache counts the chunks of a run found in the embedding cache and those
+// that had to be embedded.
+type EmbeddingCache struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+}
+
+// Budget is the state of a run's budget. Limits of zero are no limit.
+type Budget struct {
+	MaxRetries   int     `json:"max_retries"`
+	MaxTokens    int     `json:"max_tokens"`
+	MaxBackoffMs float64 `json:"max_backoff_ms"`
+	Retries      int     `json:"retries"`
+	Tokens       int     `json:"tokens"`
+	BackoffMs    float64 `json:"backoff_ms"`
+	// Exhausted names the limit reached, such as "10 retries used", or is empty.
+	Exhausted string `json:"exhausted,omitempty"`
+}
+
+// LLMCallStats aggregates the requests of one method of an LLM provider, such as
+// mistral chat. Latency percentiles are over the latest requests.
+type LLMCallStats struct {
+	Provider string `json:"provider"`
+	Method   string `json:"method"`
+	Requests int    `json:"requests"`
+	Errors   int    `json:"errors"`
+	// ErrorsByClass counts the failed requests by class, such as rate_limited.
+	ErrorsByClass    map[string]int `json:"errors_by_class,omitempty"`
+	P50Ms            float64        `json:"p50_ms"`
+	P95Ms            float64        `json:"p95_ms"`
+	PromptTokens     int            `json:"prompt_tokens"`
+	CompletionTokens int            `json:"completion_tokens"`
+}
+
+// EmbeddingCallStats aggregates the requests of one method of an embedding
+// provider, such as mistral embed, or the lookups of the embedding cache in front
+// of it, as method cache. Latency percentiles are over the latest requests.
+type EmbeddingCallStats struct {
+	Provider string `json:"provider"`
+	Method   string `json:"method"`
+	Requests int    `json:"requests"`
+	Errors   int    `json:"errors"`
+	// ErrorsByClass counts the failed requests by class, such as unauthorized.
+	ErrorsByClass map[string]int `json:"errors_by_class,omitempty"`
+	P50Ms         float64        `json:"p50_ms"`
+	P95Ms         float64        `json:"p95_ms"`
+	// Texts and Bytes measure the texts embedded or looked up.
+	Texts int `json:"texts"`
+	Bytes int `json:"bytes"`
+	// CacheHits and CacheMisses count the texts of the cache's lookups found in
+	// it and those that had to be embedded.
+	CacheHits   int `json:"cache_hits,omitempty"`
+	CacheMisses int `json:"cache_misses,omitempty"`
+}
+
+// PrunedDocument is a document removed, or that would be removed, by a prune.
+type PrunedDocument struct {
+	Source     string    `json:"source"`
+	Collection string    `json:"collection"`
+	Tags       []string  `json:"tags"`
+	IngestedAt time.Time `json:"ingested_at"`
+	Size       int       `json:"size"`
+	Chunks     int       `json:"chunks"`
+	// Entities is the number of entities mentioned only by this document.
+	Entities int `json:"entities"`
+}
+
+// PruneReport is the outcome of a prune. With DryRun set nothing was removed and
+// the counts are what a real run would remove.
+type PruneReport struct {
+	DryRun    bool             `json:"dry_run"`
+	Documents []PrunedDocument `json:"documents"`
+	Removed   PruneCounts      `json:"removed"`
+}
+
+// PruneCounts counts the nodes a prune removes.
+type PruneCounts struct {
+	Documents int `json:"documents"`
+	Chunks    int `json:"chunks"`
+	Entities  int `json:"entities"`
+}
+
+// RetrievalEval is the outcome of amg eval retrieval.
+type RetrievalEval struct {
+	K       int               `json:"k"`
+	Recall  float64           `json:"recall_at_k"`
+	MRR     float64           `json:"mrr"`
+	Queries []RetrievalResult `json:"queries"`
+	// KeywordOnly is set when the searches were ranked by keyword alone.
+	KeywordOnly bool `json:"keyword_only,omitempty"`
+}
+
+// RetrievalResult scores the hits of one evaluation query.
+type RetrievalResult struct {
+	Query          string   `json:"query"`
+	Recall         float64  `json:"recall_at_k"`
+	ReciprocalRank float64  `json:"reciprocal_rank"`
+	Found          []string `json:"found"`
+	Missed         []string `json:"missed"`
+	Hits           []string `json:"hits"`
+}
+
+// NewChunk converts a mentioning chunk.
+func NewChunk(hit storage.ScoredChunk) Chunk {
+	return Chunk{
+		ID:          hit.ID,
+		Source:      hit.Source,
+		Index:       hit.Index,
+		StartOffset: hit.StartOffset,
+		EndOffset:   hit.EndOffset,
+		Page:        hit.Page,
+		Content:     hit.Content,
+		Score:       hit.Score,
+	}
+}
+
+// NewHit converts a retrieval hit.
+func NewHit(hit retrieval.Hit) Chunk {
+	chunk := Chunk{
+		ID:          hit.ChunkID,
+		Source:      hit.Source,
+		Index:       hit.Index,
+		StartOffset: hit.StartOffset,
+		EndOffset:   hit.EndOffset,
+		Page:        hit.Page,
+		Content:     hit.Content,
+		Score:       hit.Score,
+		Collapsed:   hit.Collapsed,
+	}
+	if hit.Snippet.Text != "" {
+		chunk.Snippet = &Snippet{Text: hit.Snippet.Text, Highlights: make([][2]int, 0, len(hit.Snippet.Highlights))}
+		for _, span := range hit.Snippet.Highlights {
+			chunk.Snippet.Highlights = append(chunk.Snippet.Highlights, [2]int{span.Start, span.End})
+		}
+	}
+	if previous := hit.Context.Previous; previous != nil {
+		chunk.Before = newNeighbour(*previous, hit.Source)
+	}
+	if next := hit.Context.Next; next != nil {
+		chunk.After = newNeighbour(*next, hit.Source)
+	}
+	return chunk
+}
+
+// newNeighbour converts a chunk adjacent to a hit from source.
+func newNeighbour(chunk storage.Chunk, source string) *Chunk {
+	return &Chunk{
+		ID:          chunk.ID,
+		Source:      source,
+		Index:       chunk.Index,
+		StartOffset: chunk.StartOffset,
+		EndOffset:   chunk.EndOffset,
+		Page:        chunk.Page,
+		Content:     chunk.Content,
+	}
+}
+
+// NewQueryResponse converts the results of a search for query.
+func NewQueryResponse(query string, results retrieval.Results) QueryResponse {
+	response := QueryResponse{Query: query, Results: make([]Chunk, 0, len(results.Hits)), NoRelevantResults: results.NoRelevantResults, KeywordOnly: results.KeywordOnly}
+	for _, hit := range results.Hits {
+		response.Results = append(response.Results, NewHit(hit))
+	}
+	if route := results.Route; route != nil {
+		response.Route = &RouteDecision{
+			Route:      string(route.Route),
+			Entities:   nonNil(route.Entities),
+			Confidence: route.Confidence,
+			Classifier: route.Classifier,
+			Strategy:   route.Strategy,
+		}
+	}
+	for _, group := range results.Groups {
+		converted := Group{
+			DocumentID: group.DocumentID,
+			Source:     group.Source,
+			Collection: group.Collection,
+			Score:      group.Score,
+			Results:    make([]Chunk, 0, len(group.Hits)),
+			Omitted:    group.Omitted,
+		}
+		for _, hit := range group.Hits {
+			converted.Results = append(converted.Results, NewHit(hit))
+		}
+		response.Groups = append(response.Groups, converted)
+	}
+	return response
+}
+
+// NewAnswerResponse converts an answer to question.
+func NewAnswerResponse(question string, answer retrieval.Answer) AnswerResponse {
+	response := AnswerResponse{
+		Question:         question,
+		Answer:           answer.Text,
+		Citations:        make([]Citation, 0, len(answer.Citations)),
+		DroppedCitations: answer.Dropped,
+		Partial:          answer.Partial,
+		Confidence:       answer.Confidence,
+	}
+	if answer.Query != question {
+		response.Query = answer.Query
+	}
+	for _, cited := range answer.Citations {
+		response.Citations = append(response.Citations, Citation{Number: cited.Number, Chunk: NewHit(cited.Hit)})
+	}
+	return response
+}
+
+// NewEntity converts a stored entity.
+func NewEntity(entity storage.Entity) Entity {
+	aliases := entity.Aliases
+	if aliases == nil {
+		aliases = []string{}
+	}
+	return Entity{
+		Name:          entity.Name,
+		Type:          entity.Type,
+		Aliases:       aliases,
+		Mentions:      entity.MentionCount,
+		Relationships: entity.RelationshipCount,
+	}
+}
+
+// NewEntities converts a list of stored entities.
+func NewEntities(entities []storage.Entity) []Entity {
+	out := make([]Entity, 0, len(entities))
+	for _, entity := range entities {
+		out = append(out, NewEntity(entity))
+	}
+	return out
+}
+
+// NewEntityDetail converts a stored entity with its relationships and chunks.
+func NewEntityDetail(detail *storage.EntityDetail) EntityDetail {
+	out := EntityDetail{
+		Entity:        NewEntity(detail.Entity),
+		Relationships: make([]Relationship, 0, len(detail.Relationships)),
+		Chunks:        make([]Chunk, 0, len(detail.Chunks)),
+	}
+	for _, rel := range detail.Relationships {
+		out.Relationships = append(out.Relationships, Relationship{Subject: rel.Subject, Predicate: rel.Predicate, Object: rel.Object})
+	}
+	for _, chunk := range detail.Chunks {
+		out.Chunks = append(out.Chunks, NewChunk(chunk))
+	}
+	return out
+}
+
+// NewStats converts storage statistics, listing collections by name.
+func NewStats(stats storage.Stats) Stats {
+	out := Stats{
+		Documents:     stats.Documents,
+		Chunks:        stats.Chunks,
+		Entities:      stats.Entities,
+		Relationships: stats.Relationships,
+		Collections:   make([]Collection, 0, len(stats.Collections)),
+	}
+	for name, documents := range stats.Collections {
+		out.Collections = append(out.Collections, Collection{Name: name, Documents: documents})
+	}
+	sort.Slice(out.Collections, func(i, j int) bool { return out.Collections[i].Name < out.Collections[j].Name })
+	return out
+}
+
+// NewQueryCacheStats converts the statistics of a query embedding cache.
+func NewQueryCacheStats(stats retrieval.QueryCacheStats) *QueryCacheStats {
+	return &QueryCacheStats{Hits: stats.Hits, Misses: stats.Misses, Entries: stats.Entries}
+}
+
+// NewIngestReport converts a batch ingest report.
+func NewIngestReport(report ingest.Report) IngestReport {
+	out := IngestReport{Results: make([]IngestResult, 0, len(report.Results)), Failed: report.Failed()}
+	out.Ingested = len(report.Results) - out.Failed
+	usage := report.Usage()
+	out.Usage = Usage{PromptTokens: usage.PromptTokens, CompletionTokens: usage.CompletionTokens, TotalTokens: usage.TotalTokens}
+	if cost := report.Cost(); !cost.Unknown {
+		out.EstimatedCostUSD = &cost.USD
+	}
+	for _, result := range report.Results {
+		r := IngestResult{Source: result.Source, Status: string(result.Status), Chunks: result.Chunks, ExtractionFailures: result.ExtractionFailures, EmbeddingFailures: result.EmbeddingFailures}
+		if result.Err != nil {
+			r.Error = result.Err.Error()
+		}
+		for _, chunk := range result.OverLimitChunks {
+			r.OverLimitChunks = append(r.OverLimitChunks, OverLimitChunk{Chunk: chunk.Chunk, Tokens: chunk.Tokens, Action: string(chunk.Action)})
+		}
+		out.Results = append(out.Results, r)
+	}
+	for _, calls := range report.LLMCalls {
+		out.LLMCalls = append(out.LLMCalls, LLMCallStats{
+			Provider:         calls.Provider,
+			Method:           calls.Method,
+			Requests:         calls.Requests,
+			Errors:           calls.Errors,
+			ErrorsByClass:    calls.ErrorsByClass,
+			P50Ms:            milliseconds(calls.P50),
+			P95Ms:            milliseconds(calls.P95),
+			PromptTokens:     calls.PromptTokens,
+			CompletionTokens: calls.CompletionTokens,
+		})
+	}
+	for _, calls := range report.EmbeddingCalls {
+		out.EmbeddingCalls = append(out.EmbeddingCalls, EmbeddingCallStats{
+			Provider:      calls.Provider,
+			Method:        calls.Method,
+			Requests:      calls.Requests,
+			Errors:        calls.Errors,
+			ErrorsByClass: calls.ErrorsByClass,
+			P50Ms:         milliseconds(calls.P50),
+			P95Ms:         milliseconds(calls.P95),
+			Texts:         calls.Texts,
+			Bytes:         calls.Bytes,
+			CacheHits:     calls.CacheHits,
+			CacheMisses:   calls.CacheMisses,
+		})
+	}
+	if state := report.Budget; !state.Limits.IsZero() {
+		out.Budget = &Budget{
+			MaxRetries:   state.MaxRetries,
+			MaxTokens:    state.MaxTokens,
+			MaxBackoffMs: milliseconds(state.MaxBackoff),
+			Retries:      state.Retries,
+			Tokens:       state.Tokens,
+			BackoffMs:    milliseconds(state.Backoff),
+			Exhausted:    state.Exhausted,
+		}
+	}
+	out.EmbeddingUsage = EmbeddingUsage{PromptTokens: report.EmbeddingUsage.PromptTokens, Unknown: report.EmbeddingUsage.Unknown}
+	if cache := report.EmbeddingCache; cache.Hits+cache.Misses > 0 {
+		out.EmbeddingCache = &EmbeddingCache{Hits: cache.Hits, Misses: cache.Misses}
+	}
+	return out
+}
+
+// NewPruneReport converts a prune plan and, unless it was a dry run, the result of
+// carrying it out.
+func NewPruneReport(plan *storage.PrunePlan, result *storage.PruneResult) PruneReport {
+	out := PruneReport{DryRun: result == nil, Documents: make([]PrunedDocument, 0, len(plan.Candidates))}
+	for _, candidate := range plan.Candidates {
+		tags := candidate.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		out.Documents = append(out.Documents, PrunedDocument{
+			Source:     candidate.Source,
+			Collection: candidate.Collection,
+			Tags:       tags,
+			IngestedAt: candidate.IngestedAt,
+			Size:       candidate.Size,
+			Chunks:     candidate.Chunks,
+			Entities:   candidate.Entities,
+		})
+		out.Removed.Chunks += candidate.Chunks
+	}
+	out.Removed.Documents = len(plan.Candidates)
+	out.Removed.Entities = plan.OrphanedEntities
+	if result != nil {
+		out.Removed = PruneCounts{Documents: result.Documents, Chunks: result.Chunks, Entities: result.Entities}
+	}
+	return out
+}
+
+// NewRetrievalEval converts an evaluation report.
+func NewRetrievalEval(report eval.Report) RetrievalEval {
+	out := RetrievalEval{K: report.K, Recall: report.Recall, MRR: report.MRR, Queries: make([]RetrievalResult, 0, len(report.Queries)), KeywordOnly: report.KeywordOnly}
+	for _, query := range report.Queries {
+		out.Queries = append(out.Queries, RetrievalResult{
+			Query:          query.Query,
+			Recall:         query.Recall,
+			ReciprocalRank: query.ReciprocalRank,
+			Found:          nonNil(query.Found),
+			Missed:         nonNil(query.Missed),
+			Hits:           nonNil(query.Hits),
+		})
+	}
+	return out
+}
+
+// nonNil returns values, or an empty slice so it encodes as [] rather than null.
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+// milliseconds converts d to fractional milliseconds.
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,137 @@
+// Package budget caps what a pipeline run may spend on provider APIs, so that an
+// unattended run with aggressive retries cannot use up an account's quota.
+package budget
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrBudgetExhausted is the error of calls refused because their run's budget is
+// spent.
+var ErrBudgetExhausted = errors.New("run budget exhausted")
+
+// Limits caps a run. A zero field is no limit.
+type Limits struct {
+	// MaxRetries is the number of retries of failed calls across the run.
+	MaxRetries int
+	// MaxTokens is the number of tokens the run's calls may spend.
+	MaxTokens int
+	// MaxBackoff is the total time the run may wait before retrying.
+	MaxBackoff time.Duration
+}
+
+// IsZero reports whether l limits nothing.
+func (l Limits) IsZero() bool {
+	return l == Limits{}
+}
+
+// Budget tracks what a run has spent against its Limits. Once a limit is reached,
+// every further call fails fast with ErrBudgetExhausted. A nil *Budget limits
+// nothing. It is safe for concurrent use.
+type Budget struct {
+	limits Limits
+
+	mu        sync.Mutex
+	retries   int
+	tokens    int
+	backoff   time.Duration
+	exhausted string // the limit reached, or empty
+}
+
+// New returns a budget with nothing spent.
+func New(limits Limits) *Budget {
+	return &Budget{limits: limits}
+}
+
+// Check returns an error wrapping ErrBudgetExhausted once b is exhausted.
+func (b *Budget) Check() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.err()
+}
+
+// Retry charges b with a retry waiting delay first, or returns an error wrapping
+// ErrBudgetExhausted, without charging, when that would exceed a limit.
+func (b *Budget) Retry(delay time.Duration) error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.err(); err != nil {
+		return err
+	}
+	switch {
+	case b.limits.MaxRetries > 0 && b.retries >= b.limits.MaxRetries:
+		b.exhausted = fmt.Sprintf("%d retries used", b.retries)
+	case b.limits.MaxBackoff > 0 && b.backoff+delay > b.limits.MaxBackoff:
+		b.exhausted = fmt.Sprintf("%s of %s backoff used", b.backoff, b.limits.MaxBackoff)
+	default:
+		b.retries++
+		b.backoff += delay
+		return nil
+	}
+	return b.err()
+}
+
+// Spend charges b with tokens spent by a call. Calls already made are charged in
+// full, so the total may end above MaxTokens.
+func (b *Budget) Spend(tokens int) {
+	if b == nil || tokens <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens += tokens
+	if b.exhausted == "" && b.limits.MaxTokens > 0 && b.tokens >= b.limits.MaxTokens {
+		b.exhausted = fmt.Sprintf("%d of %d tokens used", b.tokens, b.limits.MaxTokens)
+	}
+}
+
+func (b *Budget) err() error {
+	if b.exhausted == "" {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrBudgetExhausted, b.exhausted)
+}
+
+// State is what a budget allowed and what was spent of it.
+type State struct {
+	Limits
+	Retries int
+	Tokens  int
+	Backoff time.Duration
+	// Exhausted names the limit reached, such as "10 retries used", or is empty.
+	Exhausted string
+}
+
+// State returns what b allowed and what was spent so far.
+func (b *Budget) State() State {
+	if b == nil {
+		return State{}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return State{Limits: b.limits, Retries: b.retries, Tokens: b.tokens, Backoff: b.backoff, Exhausted: b.exhausted}
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying b, which the calls made with it
+// consult and charge.
+func NewContext(ctx context.Context, b *Budget) context.Context {
+	return context.WithValue(ctx, contextKey{}, b)
+}
+
+// FromContext returns the budget ctx carries, or nil.
+func FromContext(ctx context.Context) *Budget {
+	b, _ := ctx.Value(contextKey{}).(*Budget)
+	return b
+}
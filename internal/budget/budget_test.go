@@ -0,0 +1,67 @@
+package budget
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBudget_Retries(t *testing.T) {
+	b := New(Limits{MaxRetries: 2, MaxBackoff: time.Minute})
+	for n := 1; n <= 2; n++ {
+		if err := b.Retry(time.Second); err != nil {
+			t.Fatalf("Expected retry %d to be allowed, got %v", n, err)
+		}
+	}
+	if err := b.Check(); err != nil {
+		t.Fatalf("Expected calls to be allowed until a retry is refused, got %v", err)
+	}
+	if err := b.Retry(time.Second); !errors.Is(err, ErrBudgetExhausted) {
+		t.Fatalf("Expected the third retry to be refused, got %v", err)
+	}
+	if err := b.Check(); !errors.Is(err, ErrBudgetExhausted) || err.Error() != "run budget exhausted: 2 retries used" {
+		t.Errorf("Expected calls to fail fast once exhausted, got %v", err)
+	}
+	if state := b.State(); state.Retries != 2 || state.Backoff != 2*time.Second {
+		t.Errorf("Expected 2 retries and 2s of backoff charged, got %+v", state)
+	}
+}
+
+func TestBudget_BackoffAndTokens(t *testing.T) {
+	b := New(Limits{MaxBackoff: 10 * time.Second})
+	if err := b.Retry(8 * time.Second); err != nil {
+		t.Fatalf("Expected 8s of backoff to be allowed, got %v", err)
+	}
+	if err := b.Retry(4 * time.Second); !errors.Is(err, ErrBudgetExhausted) {
+		t.Errorf("Expected a retry past the backoff to be refused, got %v", err)
+	}
+
+	b = New(Limits{MaxTokens: 100})
+	b.Spend(60)
+	if err := b.Check(); err != nil {
+		t.Fatalf("Expected 60 of 100 tokens to be within the budget, got %v", err)
+	}
+	b.Spend(60)
+	if err := b.Check(); !errors.Is(err, ErrBudgetExhausted) {
+		t.Errorf("Expected 120 of 100 tokens to exhaust the budget, got %v", err)
+	}
+	if state := b.State(); state.Tokens != 120 || state.Exhausted != "120 of 100 tokens used" {
+		t.Errorf("Expected every token charged, got %+v", state)
+	}
+}
+
+func TestBudget_NilAndContext(t *testing.T) {
+	var b *Budget
+	b.Spend(1 << 30)
+	if err := b.Retry(time.Hour); err != nil || b.Check() != nil {
+		t.Errorf("Expected a nil budget to limit nothing, got %v", err)
+	}
+	if FromContext(context.Background()) != nil {
+		t.Errorf("Expected no budget in a bare context")
+	}
+	b = New(Limits{MaxTokens: 1})
+	if got := FromContext(NewContext(context.Background(), b)); got != b {
+		t.Errorf("Expected the budget the context carries, got %v", got)
+	}
+}
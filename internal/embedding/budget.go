@@ -0,0 +1,41 @@
+package embedding
+
+import (
+	"context"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/budget"
+)
+
+// budgetedService is a Service that stops sending requests once its budget is
+// exhausted.
+type budgetedService struct {
+	Service
+	budget *budget.Budget
+}
+
+// WithBudget returns service failing fast with budget.ErrBudgetExhausted once b is
+// exhausted, such as by the LLM requests of the same run. A nil b returns service
+// unchanged.
+func WithBudget(service Service, b *budget.Budget) Service {
+	if b == nil {
+		return service
+	}
+	return &budgetedService{Service: service, budget: b}
+}
+
+// GetEmbeddings embeds text with the wrapped service unless the budget is exhausted.
+func (s *budgetedService) GetEmbeddings(ctx context.Context, text string, embeddingType EmbeddingType) (EmbedResponse, error) {
+	if err := s.budget.Check(); err != nil {
+		return nil, err
+	}
+	return s.Service.GetEmbeddings(ctx, text, embeddingType)
+}
+
+// GetEmbeddingsBatch embeds texts with the wrapped service unless the budget is
+// exhausted.
+func (s *budgetedService) GetEmbeddingsBatch(ctx context.Context, texts []string, embeddingType EmbeddingType) ([]EmbedResponse, error) {
+	if err := s.budget.Check(); err != nil {
+		return nil, err
+	}
+	return s.Service.GetEmbeddingsBatch(ctx, texts, embeddingType)
+}
@@ -0,0 +1,370 @@
+package embedding
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/metrics"
+)
+
+// DefaultCacheMaxEntries is the number of vectors a FileCacheStore keeps unless
+// told otherwise, about 300 MB of 768-dimension vectors on disk.
+const DefaultCacheMaxEntries = 100_000
+
+// CacheStore keeps vectors by the key CachedService derives from what was embedded.
+type CacheStore interface {
+	// Get returns the vector stored under key.
+	Get(key string) (EmbedResponse, bool)
+	// Put stores vector under key.
+	Put(key string, vector EmbedResponse) error
+}
+
+// CacheStats counts the texts a CachedService found in its store and those it
+// had to embed.
+type CacheStats struct {
+	Hits   int64
+	Misses int64
+}
+
+// Sub returns the hits and misses of s since earlier.
+func (s CacheStats) Sub(earlier CacheStats) CacheStats {
+	return CacheStats{Hits: s.Hits - earlier.Hits, Misses: s.Misses - earlier.Misses}
+}
+
+// CachedService is a Service keeping the vectors of another in a CacheStore, so
+// that embedding the same text again, such as the unchanged chunks of an edited
+// document, sends no request. Vectors are keyed by a SHA-256 hash of the inner
+// service, its model and dimensions, the embedding type and the text.
+type CachedService struct {
+	// Metrics receives a measurement of every lookup in the store, with its
+	// hits and misses; metrics.Embeddings unless set.
+	Metrics metrics.Sink
+
+	inner  Service
+	store  CacheStore
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// NewCachedService caches the vectors of inner in store.
+func NewCachedService(inner Service, store CacheStore) *CachedService {
+	return &CachedService{inner: inner, store: store, Metrics: metrics.Embeddings}
+}
+
+// Stats returns the hits and misses so far.
+func (s *CachedService) Stats() CacheStats {
+	return CacheStats{Hits: s.hits.Load(), Misses: s.misses.Load()}
+}
+
+// GetEmbeddings returns the cached vector of text, embedding it with the inner
+// service on a miss.
+func (s *CachedService) GetEmbeddings(ctx context.Context, text string, embeddingType EmbeddingType) (EmbedResponse, error) {
+	vectors, err := s.GetEmbeddingsBatch(ctx, []string{text}, embeddingType)
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+// GetEmbeddingsBatch returns the cached vectors of texts, embedding those missing
+// with one call to the inner service.
+func (s *CachedService) GetEmbeddingsBatch(ctx context.Context, texts []string, embeddingType EmbeddingType) ([]EmbedResponse, error) {
+	start := time.Now()
+	vectors := make([]EmbedResponse, len(texts))
+	keys := make([]string, len(texts))
+	var missing []int
+	for n, text := range texts {
+		keys[n] = s.key(text, embeddingType)
+		if vector, ok := s.store.Get(keys[n]); ok {
+			vectors[n] = vector
+			continue
+		}
+		missing = append(missing, n)
+	}
+	s.hits.Add(int64(len(texts) - len(missing)))
+	s.misses.Add(int64(len(missing)))
+	s.recordLookup(start, texts, len(texts)-len(missing), len(missing))
+	if len(missing) == 0 {
+		return vectors, nil
+	}
+
+	batch := make([]string, len(missing))
+	for n, index := range missing {
+		batch[n] = texts[index]
+	}
+	embedded, err := s.inner.GetEmbeddingsBatch(ctx, batch, embeddingType)
+	if err != nil {
+		return nil, err
+	}
+	if len(embedded) != len(batch) {
+		return nil, fmt.Errorf("got %d embeddings for %d texts", len(embedded), len(batch))
+	}
+	for n, index := range missing {
+		vectors[index] = embedded[n]
+		if err := s.store.Put(keys[index], embedded[n]); err != nil {
+			slog.WarnContext(ctx, "CachedService: Failed to cache embedding", "error", err)
+		}
+	}
+	return vectors, nil
+}
+
+// recordLookup records a lookup of texts begun at start, with its hits and
+// misses, to s.Metrics when it is set.
+func (s *CachedService) recordLookup(start time.Time, texts []string, hits, misses int) {
+	if s.Metrics == nil {
+		return
+	}
+	s.Metrics.RecordCall(metrics.Call{
+		Provider:    string(s.inner.GetType()),
+		Method:      MethodCache,
+		Duration:    time.Since(start),
+		Texts:       len(texts),
+		Bytes:       textBytes(texts),
+		CacheHits:   hits,
+		CacheMisses: misses,
+	})
+}
+
+// Ping pings the inner service, as a cache cannot tell whether it is configured.
+func (s *CachedService) Ping(ctx context.Context) error {
+	return s.inner.Ping(ctx)
+}
+
+// GetType returns the provider of the inner service.
+func (s *CachedService) GetType() Provider {
+	return s.inner.GetType()
+}
+
+// Dimensions returns those of the inner service when it reports them, and 0
+// otherwise.
+func (s *CachedService) Dimensions() int {
+	return DimensionsOf(s.inner)
+}
+
+// Usage returns that of the inner service, as vectors found in the store cost
+// nothing.
+func (s *CachedService) Usage() Usage {
+	return UsageOf(s.inner)
+}
+
+// key hashes text of embeddingType together with what determines its vector
+// besides: the inner service, its model, the dimensions it asks for and the
+// prefix of Mistral queries.
+func (s *CachedService) key(text string, embeddingType EmbeddingType) string {
+	model, dimensions := serviceModel(s.inner)
+	fields := map[string]any{
+		"service":    fmt.Sprintf("%T", s.inner),
+		"model":      model,
+		"dimensions": dimensions,
+		"type":       embeddingType,
+		"text":       text,
+	}
+	// Added only when set, so that the keys of other texts are unchanged.
+	if mistral, ok := s.inner.(*MistralService); ok && embeddingType == EmbeddingTypeRetrievalQuery && mistral.queryPrefix != "" {
+		fields["query_prefix"] = mistral.queryPrefix
+	}
+	data, _ := json.Marshal(fields)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ModelOf returns the model service embeds with, or "" when it has none, like
+// the mock.
+func ModelOf(service Service) string {
+	model, _ := serviceModel(service)
+	return model
+}
+
+// serviceModel returns the model service embeds with and the dimensions it asks
+// for, for services that have them.
+func serviceModel(service Service) (string, int) {
+	switch s := service.(type) {
+	case *CachedService:
+		return serviceModel(s.inner)
+	case *MistralService:
+		return s.model, 0
+	case *OpenAIService:
+		return s.model, s.Dimensions
+	case *OllamaService:
+		return s.model, 0
+	case *VoyageService:
+		return s.model, s.OutputDimension
+	case *LocalService:
+		return s.Model(), 0
+	case *geminiService:
+		return s.model, s.outputDimensionality
+	}
+	return "", 0
+}
+
+// FileCacheStore is a CacheStore in a JSON-lines file, read whole when opened and
+// appended to by Put. Beyond its maximum number of entries, the oldest tenth are
+// evicted and the file rewritten.
+type FileCacheStore struct {
+	path       string
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]EmbedResponse
+	order   []string // keys, oldest first
+	file    *os.File
+}
+
+// cacheLine is a line of a FileCacheStore's file.
+type cacheLine struct {
+	Key    string        `json:"key"`
+	Vector EmbedResponse `json:"vector"`
+}
+
+// OpenFileCacheStore opens the cache in the file at path, creating it if needed,
+// keeping at most maxEntries vectors; zero lets it grow without bound. Corrupt
+// lines, such as one cut short by a crash, are skipped.
+func OpenFileCacheStore(path string, maxEntries int) (*FileCacheStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create embedding cache directory: %w", err)
+	}
+	s := &FileCacheStore{path: path, maxEntries: maxEntries, entries: make(map[string]EmbedResponse)}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if s.maxEntries > 0 && len(s.order) > s.maxEntries {
+		if err := s.evict(); err != nil {
+			return nil, err
+		}
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open embedding cache: %w", err)
+	}
+	s.file = file
+	return s, nil
+}
+
+// load reads the entries of the file, if any.
+func (s *FileCacheStore) load() error {
+	file, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read embedding cache: %w", err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64<<10), 16<<20)
+	corrupt := 0
+	for scanner.Scan() {
+		var line cacheLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil || line.Key == "" {
+			corrupt++
+			continue
+		}
+		if _, ok := s.entries[line.Key]; !ok {
+			s.order = append(s.order, line.Key)
+		}
+		s.entries[line.Key] = line.Vector
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read embedding cache: %w", err)
+	}
+	if corrupt > 0 {
+		slog.Warn("FileCacheStore: Skipping corrupt cached embeddings", "path", s.path, "lines", corrupt)
+	}
+	return nil
+}
+
+// Get returns the vector stored under key.
+func (s *FileCacheStore) Get(key string) (EmbedResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	vector, ok := s.entries[key]
+	return vector, ok
+}
+
+// Put appends vector under key to the file, unless it is stored already.
+func (s *FileCacheStore) Put(key string, vector EmbedResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[key]; ok {
+		return nil
+	}
+	data, err := json.Marshal(cacheLine{Key: key, Vector: vector})
+	if err != nil {
+		return err
+	}
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	s.entries[key] = vector
+	s.order = append(s.order, key)
+	if s.maxEntries > 0 && len(s.order) > s.maxEntries {
+		return s.evict()
+	}
+	return nil
+}
+
+// Len returns the number of vectors stored.
+func (s *FileCacheStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.order)
+}
+
+// evict drops the oldest entries down to nine tenths of maxEntries, so that
+// the file is not rewritten on every Put, and rewrites the file with the rest.
+func (s *FileCacheStore) evict() error {
+	keep := s.maxEntries - s.maxEntries/10
+	for _, key := range s.order[:len(s.order)-keep] {
+		delete(s.entries, key)
+	}
+	s.order = append([]string(nil), s.order[len(s.order)-keep:]...)
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	for _, key := range s.order {
+		if err = encoder.Encode(cacheLine{Key: key, Vector: s.entries[key]}); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if s.file == nil {
+		return nil
+	}
+	// Appends must go to the new file.
+	s.file.Close()
+	s.file, err = os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	return err
+}
+
+// Close closes the file.
+func (s *FileCacheStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
@@ -0,0 +1,111 @@
+package embedding
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// countingService embeds each text as its length, recording the texts it was asked
+// to embed.
+type countingService struct {
+	MockService
+	embedded []string
+}
+
+func (s *countingService) GetEmbeddingsBatch(ctx context.Context, texts []string, embeddingType EmbeddingType) ([]EmbedResponse, error) {
+	s.embedded = append(s.embedded, texts...)
+	vectors := make([]EmbedResponse, len(texts))
+	for n, text := range texts {
+		vectors[n] = EmbedResponse{float32(len(text)), 0.5}
+	}
+	return vectors, nil
+}
+
+func TestCachedService_SecondCallHitsCache(t *testing.T) {
+	store, err := OpenFileCacheStore(filepath.Join(t.TempDir(), "cache.jsonl"), 0)
+	if err != nil {
+		t.Fatalf("OpenFileCacheStore failed: %v", err)
+	}
+	defer store.Close()
+	inner := &countingService{}
+	s := NewCachedService(inner, store)
+	ctx := context.Background()
+
+	first, err := s.GetEmbeddings(ctx, "unchanged chunk", EmbeddingTypeRetrievalDocument)
+	if err != nil {
+		t.Fatalf("GetEmbeddings failed: %v", err)
+	}
+	second, err := s.GetEmbeddings(ctx, "unchanged chunk", EmbeddingTypeRetrievalDocument)
+	if err != nil {
+		t.Fatalf("GetEmbeddings failed: %v", err)
+	}
+	if !reflect.DeepEqual(first, second) || len(inner.embedded) != 1 {
+		t.Errorf("Expected the second call to be served from the cache, got %v and %v after embedding %v", first, second, inner.embedded)
+	}
+
+	vectors, err := s.GetEmbeddingsBatch(ctx, []string{"new", "unchanged chunk", "newer"}, EmbeddingTypeRetrievalDocument)
+	if err != nil {
+		t.Fatalf("GetEmbeddingsBatch failed: %v", err)
+	}
+	if expected := []string{"unchanged chunk", "new", "newer"}; !reflect.DeepEqual(inner.embedded, expected) {
+		t.Errorf("Expected only the misses to be embedded, got %v", inner.embedded)
+	}
+	if vectors[0][0] != 3 || vectors[1][0] != 15 || vectors[2][0] != 5 {
+		t.Errorf("Expected the vectors in input order, got %v", vectors)
+	}
+	if _, err := s.GetEmbeddings(ctx, "unchanged chunk", EmbeddingTypeRetrievalQuery); err != nil || len(inner.embedded) != 4 {
+		t.Errorf("Expected another embedding type to miss, got %v after embedding %v", err, inner.embedded)
+	}
+	if stats := s.Stats(); stats != (CacheStats{Hits: 2, Misses: 4}) {
+		t.Errorf("Expected 2 hits and 4 misses, got %+v", stats)
+	}
+}
+
+func TestFileCacheStore_PersistsAndEvicts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.jsonl")
+	store, err := OpenFileCacheStore(path, 10)
+	if err != nil {
+		t.Fatalf("OpenFileCacheStore failed: %v", err)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		if err := store.Put(key, EmbedResponse{0.25, -1}); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	store.Close()
+
+	// A line cut short by a crash is skipped.
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	file.WriteString(`{"key": "d", "vec`)
+	file.Close()
+
+	store, err = OpenFileCacheStore(path, 10)
+	if err != nil {
+		t.Fatalf("OpenFileCacheStore failed: %v", err)
+	}
+	defer store.Close()
+	if vector, ok := store.Get("b"); !ok || !reflect.DeepEqual(vector, EmbedResponse{0.25, -1}) {
+		t.Errorf("Expected the vector stored before reopening, got %v, %v", vector, ok)
+	}
+	if _, ok := store.Get("d"); ok || store.Len() != 3 {
+		t.Errorf("Expected the corrupt line to be skipped, got %d entries", store.Len())
+	}
+
+	for n := range 8 {
+		if err := store.Put(string(rune('e'+n)), EmbedResponse{float32(n)}); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	if store.Len() != 9 {
+		t.Errorf("Expected eviction down to 9 entries, got %d", store.Len())
+	}
+	if _, ok := store.Get("a"); ok {
+		t.Errorf("Expected the oldest entry to be evicted")
+	}
+	if _, ok := store.Get("l"); !ok {
+		t.Errorf("Expected the newest entry to be kept")
+	}
+}
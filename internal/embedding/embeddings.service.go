@@ -3,24 +3,40 @@ package embedding
 import (
 	"context"
 	"fmt"
-	"log/slog"
-	"os"
-
-	"google.golang.org/genai"
 )
 
 type EmbeddingType string
 
 const (
 	EmbeddingTypeRetrievalDocument EmbeddingType = "RETRIEVAL_DOCUMENT"
-	EmbeddintTypeRetrievalQuery    EmbeddingType = "RETRIEVAL_QUERY"
+	EmbeddingTypeRetrievalQuery    EmbeddingType = "RETRIEVAL_QUERY"
+
+	// EmbeddintTypeRetrievalQuery is a misspelling of
+	// EmbeddingTypeRetrievalQuery kept for compatibility.
+	//
+	// Deprecated: Use EmbeddingTypeRetrievalQuery.
+	EmbeddintTypeRetrievalQuery = EmbeddingTypeRetrievalQuery
 )
 
 type EmbedResponse = []float32
 
 // Service represents a service that interacts with the embedding client.
 type Service interface {
-	GetEmbeddings(text string, embeddingType EmbeddingType) (EmbedResponse, error)
+	// GetEmbeddings embeds text, giving up when ctx is done.
+	GetEmbeddings(ctx context.Context, text string, embeddingType EmbeddingType) (EmbedResponse, error)
+	// GetEmbeddingsBatch embeds texts in as few requests as the provider allows,
+	// returning their vectors in the same order. A provider refusing one of the
+	// texts may fail the whole batch with an error wrapping ErrInvalidInput.
+	GetEmbeddingsBatch(ctx context.Context, texts []string, embeddingType EmbeddingType) ([]EmbedResponse, error)
+
+	// Ping makes a minimal authenticated request, such as listing the models, to
+	// check the configuration before any work is done. Its error wraps
+	// ErrUnauthorized for a bad key and ErrUnreachable when the API cannot be
+	// reached.
+	Ping(ctx context.Context) error
+
+	// GetType returns the provider that embeds text.
+	GetType() Provider
 }
 
 // Provider is an enum for the embedding providers.
@@ -29,83 +45,105 @@ type Provider string
 const (
 	ProviderGemini   Provider = "gemini"
 	ProviderMistral  Provider = "mistral"
+	ProviderOpenAI   Provider = "openai"
+	ProviderOllama   Provider = "ollama"
+	ProviderVoyage   Provider = "voyage"
+	ProviderLocal    Provider = "local"
 	ProviderTestMock Provider = "testing" // For testing purposes
 )
 
-type service struct {
-	client *genai.Client
+// Providers lists the embedding providers that New accepts.
+func Providers() []Provider {
+	return []Provider{ProviderGemini, ProviderMistral, ProviderOpenAI, ProviderOllama, ProviderVoyage, ProviderLocal, ProviderTestMock}
 }
 
-var (
-	apiKey          = os.Getenv("GEMINI_API_KEY")
-	serviceInstance *service
-)
-
 // New creates a new embedding service based on the specified provider.
 func New(provider Provider) (Service, error) {
 	switch provider {
 	case ProviderGemini:
-		return newGeminiService(), nil
+		key, err := requireAPIKey(ProviderGemini)
+		if err != nil {
+			return nil, err
+		}
+		return newGeminiService(key)
 	case ProviderMistral:
-		return NewMistralService(), nil
+		return NewMistralService()
+	case ProviderOpenAI:
+		s, err := NewOpenAIService()
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	case ProviderOllama:
+		s, err := NewOllamaService()
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	case ProviderVoyage:
+		s, err := NewVoyageService()
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	case ProviderLocal:
+		s, err := NewLocalService()
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
 	case ProviderTestMock:
 		// For testing purposes, we can return a mock service.
 		return NewMockService(), nil
 	default:
-		return nil, fmt.Errorf("unknown embedding provider: %s", provider)
+		_, err := ParseProvider(string(provider))
+		return nil, err
 	}
 }
 
-// geminiService is a service that interacts with the Gemini API.
-type geminiService struct {
-	client *genai.Client
-}
-
-// newGeminiService creates a new geminiService.
-func newGeminiService() Service {
-	apiKey := os.Getenv("GEMINI_API_KEY")
-	ctx := context.Background()
-	clientInstance, err := genai.NewClient(ctx, &genai.ClientConfig{
-		APIKey:  apiKey,
-		Backend: genai.BackendGeminiAPI,
-	})
-	if err != nil {
-		slog.Error("failed to create genai client", "error", err)
-		panic("failed to create genai client: " + err.Error())
+// NewWithModel creates the service of provider like New, embedding with model
+// instead of the one the provider's variable names, such as
+// OPENAI_EMBEDDING_MODEL; for ProviderLocal, model is the directory of the model.
+// An empty model is New's.
+func NewWithModel(provider Provider, model string) (Service, error) {
+	if provider == ProviderLocal && model != "" {
+		return newLocalService(model)
 	}
-	slog.Info("genai client created successfully")
-	return &geminiService{
-		client: clientInstance,
+	service, err := New(provider)
+	if err != nil || model == "" {
+		return service, err
 	}
-}
-
-func extractEmbeddingVector(embeddings []*genai.ContentEmbedding) EmbedResponse {
-	if len(embeddings) == 0 {
-		return nil
+	switch s := service.(type) {
+	case *MistralService:
+		s.model = model
+	case *OpenAIService:
+		s.model = model
+	case *OllamaService:
+		s.model = model
+	case *VoyageService:
+		s.model = model
+	case *geminiService:
+		s.model = model
+	default:
+		return nil, fmt.Errorf("the %s embedding provider has no models to choose from", provider)
 	}
-	return embeddings[0].Values
+	return service, nil
 }
 
-// GetEmbeddings sends a request to the Gemini API to get embeddings for the given text.
-func (s *geminiService) GetEmbeddings(text string, embeddingType EmbeddingType) (EmbedResponse, error) {
-	ctx := context.Background()
-	contents := []*genai.Content{
-		genai.NewContentFromText(text, genai.RoleUser),
+// inBatches embeds texts with embed, size of them at a time, returning the
+// vectors in order. embed must return a vector for each of its texts.
+func inBatches(texts []string, size int, embed func(batch []string) ([]EmbedResponse, error)) ([]EmbedResponse, error) {
+	vectors := make([]EmbedResponse, 0, len(texts))
+	for start := 0; start < len(texts); start += size {
+		batch := texts[start:min(start+size, len(texts))]
+		embedded, err := embed(batch)
+		if err != nil {
+			return nil, err
+		}
+		if len(embedded) != len(batch) {
+			return nil, fmt.Errorf("got %d embeddings for %d texts", len(embedded), len(batch))
+		}
+		vectors = append(vectors, embedded...)
 	}
-	slog.Info("Requesting embeddings", "text", text, "embeddingType", string(embeddingType))
-	result, err := s.client.Models.EmbedContent(ctx,
-		"gemini-embedding-exp-03-07",
-		contents,
-		&genai.EmbedContentConfig{
-			TaskType: string(embeddingType),
-		},
-	)
-	if err != nil {
-		slog.Error("failed to get embeddings", "error", err)
-		return nil, err
-	}
-
-	embedResponse := extractEmbeddingVector(result.Embeddings)
-
-	return embedResponse, nil
+	return vectors, nil
 }
@@ -0,0 +1,68 @@
+package embedding
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseProvider(t *testing.T) {
+	tests := map[string]Provider{
+		"mistral":  ProviderMistral,
+		"gemini":   ProviderGemini,
+		"testing":  ProviderTestMock,
+		" Gemini ": ProviderGemini,
+		"VOYAGE\n": ProviderVoyage,
+	}
+	for name, want := range tests {
+		got, err := ParseProvider(name)
+		if err != nil || got != want {
+			t.Errorf("Expected %q to be %s, got %q, %v", name, want, got, err)
+		}
+	}
+
+	_, err := ParseProvider("gemnii")
+	want := `unknown embedding provider "gemnii" (did you mean "gemini"?): choose one of gemini, mistral, openai, ollama, voyage, local, testing`
+	if err == nil || err.Error() != want {
+		t.Errorf("Expected %q, got %v", want, err)
+	}
+}
+
+func TestNewFromEnv(t *testing.T) {
+	t.Setenv(EnvProvider, "")
+	if provider, err := ProviderFromEnv(); err != nil || provider != ProviderMistral {
+		t.Errorf("Expected mistral by default, got %q, %v", provider, err)
+	}
+
+	t.Setenv(EnvProvider, "Testing")
+	service, err := NewFromEnv()
+	if err != nil {
+		t.Fatalf("NewFromEnv failed: %v", err)
+	}
+	if service.GetType() != ProviderTestMock {
+		t.Errorf("Expected the mock service, got %T", service)
+	}
+
+	t.Setenv(EnvProvider, "mistal")
+	_, err = NewFromEnv()
+	if err == nil || !strings.Contains(err.Error(), EnvProvider) || !strings.Contains(err.Error(), `did you mean "mistral"`) {
+		t.Errorf("Expected the typo in %s to be reported, got %v", EnvProvider, err)
+	}
+}
+
+func TestNewWithModel(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "test_api_key")
+	t.Setenv("OPENAI_EMBEDDING_MODEL", "text-embedding-3-large")
+	service, err := NewWithModel(ProviderOpenAI, "text-embedding-3-small")
+	if err != nil {
+		t.Fatalf("NewWithModel failed: %v", err)
+	}
+	if model := ModelOf(service); model != "text-embedding-3-small" {
+		t.Errorf("Expected the model given to win over OPENAI_EMBEDDING_MODEL, got %q", model)
+	}
+	if service, _ := NewWithModel(ProviderOpenAI, ""); ModelOf(service) != "text-embedding-3-large" {
+		t.Errorf("Expected OPENAI_EMBEDDING_MODEL without a model, got %q", ModelOf(service))
+	}
+	if _, err := NewWithModel(ProviderTestMock, "any"); err == nil {
+		t.Errorf("Expected a model for the mock to be refused")
+	}
+}
@@ -0,0 +1,105 @@
+package embedding
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/redact"
+)
+
+// Classes of provider failures. Errors returned by the services wrap one of them
+// when the failure can be told apart, so that callers can use errors.Is to decide
+// whether to retry later, skip a text or stop altogether; errors.As with
+// *APIError gives the status and body of the response.
+var (
+	// ErrUnauthorized means the API key is missing or invalid; every request
+	// will fail the same way.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrUnreachable means the provider's API could not be reached at all, such
+	// as for a wrong base URL or no network access.
+	ErrUnreachable = errors.New("unreachable")
+	// ErrRateLimited means too many requests were sent; retry later.
+	ErrRateLimited = errors.New("rate limited")
+	// ErrUpstream means the provider failed or is overloaded; retry later.
+	ErrUpstream = errors.New("upstream error")
+	// ErrInvalidInput means the provider refused the texts, such as one too long
+	// for the model; in a batch, one bad text fails them all.
+	ErrInvalidInput = errors.New("invalid input")
+	// ErrInputTooLong means a text is over the model's input limit, so that the
+	// caller can split it into smaller ones instead. A provider refusing a text
+	// for its length fails with an error wrapping ErrInvalidInput as well.
+	ErrInputTooLong = errors.New("input too long")
+)
+
+// APIError is a response with a non-OK status from a provider's API. It unwraps
+// to the class of the failure, such as ErrRateLimited, when it is known, and to
+// ErrInvalidInput as well for ErrInputTooLong.
+type APIError struct {
+	// Provider names the API, such as "mistral".
+	Provider   string
+	StatusCode int
+	// Body is the whole response body; Error truncates it.
+	Body string
+	// Class is one of the classes of failures above, or nil.
+	Class error
+}
+
+// statusError returns the error of a response with status other than 200 OK and
+// body.
+func statusError(provider string, status int, body []byte) *APIError {
+	return &APIError{Provider: provider, StatusCode: status, Body: string(body), Class: classifyStatus(status, string(body))}
+}
+
+func (e *APIError) Error() string {
+	class := ""
+	if e.Class != nil {
+		class = e.Class.Error() + ": "
+	}
+	return fmt.Sprintf("%s API error: %s%d %s - %s", e.Provider, class, e.StatusCode, http.StatusText(e.StatusCode), redact.Truncate(e.Body, redact.MaxBodyBytes))
+}
+
+func (e *APIError) Unwrap() []error {
+	switch e.Class {
+	case nil:
+		return nil
+	case ErrInputTooLong:
+		return []error{ErrInputTooLong, ErrInvalidInput}
+	}
+	return []error{e.Class}
+}
+
+// tooLongPhrases are those the providers use, lowercase, in the bodies of
+// refusals of texts over the model's input limit.
+var tooLongPhrases = []string{"too long", "too many tokens", "too large", "maximum context length", "context length", "exceeds the max", "exceeds the maximum"}
+
+// classifyStatus returns the class of a failure with status and body, or nil.
+func classifyStatus(status int, body string) error {
+	lower := strings.ToLower(body)
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrUnauthorized
+	case status == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case status == http.StatusRequestEntityTooLarge:
+		return ErrInputTooLong
+	case containsAny(lower, tooLongPhrases):
+		// Ollama refuses a long text with 500 Internal Server Error.
+		return ErrInputTooLong
+	case status == http.StatusBadRequest || status == http.StatusUnprocessableEntity:
+		return ErrInvalidInput
+	case status >= 500:
+		return ErrUpstream
+	}
+	return nil
+}
+
+func containsAny(s string, phrases []string) bool {
+	for _, phrase := range phrases {
+		if strings.Contains(s, phrase) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package embedding
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/retry"
+)
+
+func TestStatusError(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		want   error
+	}{
+		{http.StatusUnauthorized, `{"message": "Unauthorized"}`, ErrUnauthorized},
+		{http.StatusForbidden, `{"message": "Forbidden"}`, ErrUnauthorized},
+		{http.StatusTooManyRequests, `{"message": "Requests rate limit exceeded"}`, ErrRateLimited},
+		{http.StatusRequestEntityTooLarge, ``, ErrInputTooLong},
+		{http.StatusBadRequest, `{"message": "Too many tokens in batch. Max is 16384 got 20000"}`, ErrInputTooLong},
+		{http.StatusBadRequest, `{"error": {"message": "This model's maximum context length is 8192 tokens"}}`, ErrInputTooLong},
+		{http.StatusInternalServerError, `{"error": "the input length exceeds the context length"}`, ErrInputTooLong},
+		{http.StatusBadRequest, `{"message": "Input must not be empty"}`, ErrInvalidInput},
+		{http.StatusUnprocessableEntity, `{"detail": "invalid input_type"}`, ErrInvalidInput},
+		{http.StatusInternalServerError, `Internal Server Error`, ErrUpstream},
+		{http.StatusServiceUnavailable, `upstream connect error`, ErrUpstream},
+		{http.StatusNotFound, `model "nomic-embed-text" not found`, nil},
+	}
+	classes := []error{ErrUnauthorized, ErrRateLimited, ErrInputTooLong, ErrInvalidInput, ErrUpstream}
+	for _, tt := range tests {
+		err := statusError("mistral", tt.status, []byte(tt.body))
+		for _, class := range classes {
+			// A text too long is also invalid input.
+			want := class == tt.want || (class == ErrInvalidInput && tt.want == ErrInputTooLong)
+			if errors.Is(err, class) != want {
+				t.Errorf("Expected %d %q to be %v, got %v", tt.status, tt.body, tt.want, err)
+			}
+		}
+		if !strings.Contains(err.Error(), tt.body) || err.StatusCode != tt.status {
+			t.Errorf("Expected the error to keep the status %d and body %q, got %v", tt.status, tt.body, err)
+		}
+	}
+}
+
+func TestStatusError_TruncatesBody(t *testing.T) {
+	err := statusError("openai", http.StatusBadGateway, []byte(strings.Repeat("x", 4096)))
+	if len(err.Error()) > 1024 || len(err.Body) != 4096 {
+		t.Errorf("Expected the message to truncate the body and Body to keep it whole, got %d and %d bytes", len(err.Error()), len(err.Body))
+	}
+}
+
+func TestMistralService_TypedErrors(t *testing.T) {
+	status, body := http.StatusOK, ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, body, status)
+	}))
+	defer server.Close()
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	s, err := newMistralService(WithHTTPClient(server.Client()), WithBaseURL(server.URL),
+		WithRetry(retry.Policy{MaxAttempts: 1, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}))
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+
+	for _, tt := range []struct {
+		status int
+		body   string
+		want   error
+	}{
+		{http.StatusUnauthorized, `{"message": "Unauthorized"}`, ErrUnauthorized},
+		{http.StatusTooManyRequests, `{"message": "Requests rate limit exceeded"}`, ErrRateLimited},
+		{http.StatusBadRequest, `{"message": "Too many tokens overall, split into more batches."}`, ErrInputTooLong},
+		{http.StatusBadGateway, `Bad Gateway`, ErrUpstream},
+	} {
+		status, body = tt.status, tt.body
+		_, err := s.GetEmbeddings(context.Background(), "text", EmbeddingTypeRetrievalDocument)
+		var apiErr *APIError
+		if !errors.Is(err, tt.want) || !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status {
+			t.Errorf("Expected %d to fail with an *APIError wrapping %v, got %v", tt.status, tt.want, err)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package embedding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/metrics"
+	"github.com/sandwichlabs/agent-memory-graph/internal/redact"
+	"google.golang.org/genai"
+)
+
+// DefaultGeminiEmbeddingModel is the model geminiService embeds text with unless
+// GEMINI_EMBED_MODEL is set; its 768-dimension vectors fit the memory graph.
+const DefaultGeminiEmbeddingModel = "text-embedding-004"
+
+// geminiMaxBatchSize is the number of texts the Gemini API embeds in one request.
+const geminiMaxBatchSize = 100
+
+// geminiDimensions are the lengths of the vectors of the Gemini embedding models
+// when no output dimensionality is requested.
+var geminiDimensions = map[string]int{
+	"text-embedding-004":         768,
+	"gemini-embedding-001":       3072,
+	"gemini-embedding-exp-03-07": 3072,
+}
+
+// geminiService is a service that interacts with the Gemini API.
+type geminiService struct {
+	client *genai.Client
+	model  string
+	// outputDimensionality is the length of the vectors requested; the model's
+	// own when zero.
+	outputDimensionality int
+	timeout              time.Duration // of each request; none when zero
+	metrics              metrics.Sink
+}
+
+// newGeminiService creates a new geminiService with key. It embeds with
+// GEMINI_EMBED_MODEL, or DefaultGeminiEmbeddingModel, into vectors of
+// GEMINI_EMBEDDING_DIMENSIONS, or the model's own length, bounding requests by
+// AMG_EMBEDDING_TIMEOUT.
+func newGeminiService(key string) (Service, error) {
+	var dimensions int
+	if value := os.Getenv("GEMINI_EMBEDDING_DIMENSIONS"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid GEMINI_EMBEDDING_DIMENSIONS %q: must be a number of dimensions, such as 768", value)
+		}
+		dimensions = n
+	}
+	model := os.Getenv("GEMINI_EMBED_MODEL")
+	if model == "" {
+		model = DefaultGeminiEmbeddingModel
+	}
+	timeout, err := TimeoutFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	clientInstance, err := genai.NewClient(context.Background(), &genai.ClientConfig{
+		APIKey:  key,
+		Backend: genai.BackendGeminiAPI,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create genai client: %w", err)
+	}
+	slog.Info("genai client created successfully")
+	return &geminiService{
+		client:               clientInstance,
+		model:                model,
+		outputDimensionality: dimensions,
+		timeout:              timeout,
+		metrics:              metrics.Embeddings,
+	}, nil
+}
+
+// GetType returns ProviderGemini.
+func (s *geminiService) GetType() Provider {
+	return ProviderGemini
+}
+
+// Dimensions returns the output dimensionality requested, or else the length of
+// the model's vectors when it is a known one, or else 0.
+func (s *geminiService) Dimensions() int {
+	if s.outputDimensionality > 0 {
+		return s.outputDimensionality
+	}
+	return geminiDimensions[strings.TrimPrefix(s.model, "models/")]
+}
+
+// config returns the request configuration for embeddingType.
+func (s *geminiService) config(embeddingType EmbeddingType) *genai.EmbedContentConfig {
+	config := &genai.EmbedContentConfig{TaskType: string(embeddingType)}
+	if s.outputDimensionality > 0 {
+		dimensions := int32(s.outputDimensionality)
+		config.OutputDimensionality = &dimensions
+	}
+	return config
+}
+
+// Ping gets the embedding model from the Gemini API with the service's key.
+func (s *geminiService) Ping(ctx context.Context) error {
+	_, err := s.client.Models.Get(ctx, s.model, nil)
+	if err == nil {
+		return nil
+	}
+	var apiErr genai.APIError
+	if errors.As(err, &apiErr) {
+		if apiErr.Code == http.StatusUnauthorized || apiErr.Code == http.StatusForbidden || apiErr.Status == "PERMISSION_DENIED" || apiErr.Status == "UNAUTHENTICATED" {
+			return fmt.Errorf("gemini API error: %w: %v", ErrUnauthorized, err)
+		}
+		if apiErr.Code == http.StatusBadRequest && strings.Contains(apiErr.Message, "API key") {
+			return fmt.Errorf("gemini API error: %w: %v", ErrUnauthorized, err)
+		}
+		return fmt.Errorf("gemini API error: %w", err)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return fmt.Errorf("%w: failed to reach the Gemini API: %v", ErrUnreachable, err)
+}
+
+// geminiError returns err of an embedding request as an *APIError when the
+// Gemini API answered with an error status, and as it is otherwise.
+func geminiError(err error) error {
+	var apiErr genai.APIError
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	return statusError("gemini", apiErr.Code, []byte(apiErr.Message))
+}
+
+func extractEmbeddingVector(embeddings []*genai.ContentEmbedding) EmbedResponse {
+	if len(embeddings) == 0 {
+		return nil
+	}
+	return embeddings[0].Values
+}
+
+// GetEmbeddings sends a request to the Gemini API to get embeddings for the given text.
+func (s *geminiService) GetEmbeddings(ctx context.Context, text string, embeddingType EmbeddingType) (vector EmbedResponse, err error) {
+	defer func(start time.Time) { recordCall(s.metrics, ProviderGemini, start, []string{text}, err) }(time.Now())
+	contents := []*genai.Content{
+		genai.NewContentFromText(text, genai.RoleUser),
+	}
+	slog.Info("Requesting embeddings", "text_length", len(text), "embeddingType", string(embeddingType))
+	slog.Debug("Embedding text", redact.Content("text", text))
+	requestCtx, cancel := requestContext(ctx, s.timeout)
+	defer cancel()
+	result, err := s.client.Models.EmbedContent(requestCtx,
+		s.model,
+		contents,
+		s.config(embeddingType),
+	)
+	if err != nil {
+		slog.Error("failed to get embeddings", "error", err)
+		if err := timeoutError(ctx, requestCtx, ProviderGemini, s.timeout); err != nil {
+			return nil, err
+		}
+		return nil, geminiError(err)
+	}
+
+	embedResponse := extractEmbeddingVector(result.Embeddings)
+
+	return embedResponse, nil
+}
+
+// GetEmbeddingsBatch embeds texts with one request to the Gemini API per
+// geminiMaxBatchSize of them.
+func (s *geminiService) GetEmbeddingsBatch(ctx context.Context, texts []string, embeddingType EmbeddingType) ([]EmbedResponse, error) {
+	return inBatches(texts, geminiMaxBatchSize, func(batch []string) (vectors []EmbedResponse, err error) {
+		defer func(start time.Time) { recordCall(s.metrics, ProviderGemini, start, batch, err) }(time.Now())
+		contents := make([]*genai.Content, len(batch))
+		for n, text := range batch {
+			contents[n] = genai.NewContentFromText(text, genai.RoleUser)
+		}
+		slog.Info("Requesting embeddings", "texts", len(batch), "embeddingType", string(embeddingType))
+		requestCtx, cancel := requestContext(ctx, s.timeout)
+		defer cancel()
+		result, err := s.client.Models.EmbedContent(requestCtx, s.model, contents, s.config(embeddingType))
+		if err != nil {
+			slog.Error("failed to get embeddings", "error", err)
+			if err := timeoutError(ctx, requestCtx, ProviderGemini, s.timeout); err != nil {
+				return nil, err
+			}
+			return nil, geminiError(err)
+		}
+		vectors = make([]EmbedResponse, len(result.Embeddings))
+		for n, embedding := range result.Embeddings {
+			vectors[n] = embedding.Values
+		}
+		return vectors, nil
+	})
+}
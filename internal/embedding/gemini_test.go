@@ -0,0 +1,44 @@
+package embedding
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGeminiService_Model(t *testing.T) {
+	t.Setenv("GEMINI_API_KEY", "test_api_key")
+	t.Setenv("GEMINI_EMBED_MODEL", "")
+	t.Setenv("GEMINI_EMBEDDING_DIMENSIONS", "")
+	service, err := New(ProviderGemini)
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	if ModelOf(service) != DefaultGeminiEmbeddingModel || service.(Dimensioner).Dimensions() != 768 {
+		t.Errorf("Expected %s with 768 dimensions, got %s with %d", DefaultGeminiEmbeddingModel, ModelOf(service), service.(Dimensioner).Dimensions())
+	}
+	if err := CheckDimensions(service, 768); err != nil {
+		t.Errorf("Expected the default model to fit the memory graph, got %v", err)
+	}
+
+	t.Setenv("GEMINI_EMBED_MODEL", "gemini-embedding-001")
+	service, _ = New(ProviderGemini)
+	err = CheckDimensions(service, 768)
+	if err == nil || !strings.Contains(err.Error(), "gemini embeds gemini-embedding-001 into 3072 dimensions but the memory graph stores 768: set GEMINI_EMBEDDING_DIMENSIONS=768") {
+		t.Errorf("Expected the mismatch to name GEMINI_EMBEDDING_DIMENSIONS, got %v", err)
+	}
+
+	t.Setenv("GEMINI_EMBEDDING_DIMENSIONS", "768")
+	service, _ = New(ProviderGemini)
+	if err := CheckDimensions(service, 768); err != nil {
+		t.Errorf("Expected 768 requested dimensions to fit, got %v", err)
+	}
+	config := service.(*geminiService).config(EmbeddingTypeRetrievalDocument)
+	if config.OutputDimensionality == nil || *config.OutputDimensionality != 768 || config.TaskType != string(EmbeddingTypeRetrievalDocument) {
+		t.Errorf("Expected requests for 768 dimensions, got %+v", config)
+	}
+
+	t.Setenv("GEMINI_EMBEDDING_DIMENSIONS", "many")
+	if _, err := New(ProviderGemini); err == nil || !strings.Contains(err.Error(), "GEMINI_EMBEDDING_DIMENSIONS") {
+		t.Errorf("Expected invalid GEMINI_EMBEDDING_DIMENSIONS to fail, got %v", err)
+	}
+}
@@ -0,0 +1,228 @@
+package embedding
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/llm"
+)
+
+// LengthStrategy is what Limit.Embed does with a text over the model's input limit.
+type LengthStrategy string
+
+const (
+	// LengthFail fails with an *InputTooLongError, so that the caller can split the
+	// text into smaller ones itself.
+	LengthFail LengthStrategy = "fail"
+	// LengthTruncate embeds as much of the text as fits, logging a warning.
+	LengthTruncate LengthStrategy = "truncate"
+	// LengthSplit embeds the text in parts that fit and averages their vectors.
+	LengthSplit LengthStrategy = "split"
+)
+
+// LengthStrategies lists the strategies Limit accepts.
+func LengthStrategies() []LengthStrategy {
+	return []LengthStrategy{LengthFail, LengthTruncate, LengthSplit}
+}
+
+// maxInputTokens are the input limits of the providers' embedding models, in
+// tokens, by model name prefix; the longest matching prefix wins.
+var maxInputTokens = map[string]int{
+	"mistral-embed":          8192,
+	"codestral-embed":        8192,
+	"text-embedding-3":       8191,
+	"text-embedding-ada-002": 8191,
+	"voyage-3":               32_000,
+	"voyage-code-3":          32_000,
+	"nomic-embed-text":       8192,
+	"mxbai-embed-large":      512,
+	"all-minilm":             256,
+	"text-embedding-004":     2048,
+	"gemini-embedding":       2048,
+	"gemini-embedding-exp":   8192,
+}
+
+// MaxInputTokens returns the most tokens model embeds in one text, and whether
+// model is known.
+func MaxInputTokens(model string) (int, bool) {
+	best := ""
+	for prefix := range maxInputTokens {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	if best == "" {
+		return 0, false
+	}
+	return maxInputTokens[best], true
+}
+
+// InputTooLongError is the error of a text over the model's input limit. It wraps
+// ErrInputTooLong.
+type InputTooLongError struct {
+	// Index is the position of the text among those embedded together.
+	Index     int
+	Tokens    int
+	MaxTokens int
+	Model     string
+}
+
+func (e *InputTooLongError) Error() string {
+	return fmt.Sprintf("%v: text %d is about %d tokens, over the %d of %s", ErrInputTooLong, e.Index, e.Tokens, e.MaxTokens, e.Model)
+}
+
+func (e *InputTooLongError) Unwrap() error { return ErrInputTooLong }
+
+// Limit fits texts to the input limit of an embedding model before they are sent,
+// instead of letting the provider refuse them. Tokens are estimated as by
+// llm.CountTokens.
+type Limit struct {
+	Model     string
+	MaxTokens int
+	Strategy  LengthStrategy
+}
+
+// LimitOf returns the Limit of the model service embeds with, and whether the
+// model's limit is known.
+func LimitOf(service Service, strategy LengthStrategy) (Limit, bool) {
+	model, _ := serviceModel(service)
+	maxTokens, ok := MaxInputTokens(model)
+	if !ok {
+		return Limit{}, false
+	}
+	return Limit{Model: model, MaxTokens: maxTokens, Strategy: strategy}, true
+}
+
+// Tokens estimates the tokens of text for l's model.
+func (l Limit) Tokens(text string) int {
+	tokens, _ := llm.CountTokens(l.Model, text)
+	return tokens
+}
+
+// Check returns an *InputTooLongError for a text over the limit.
+func (l Limit) Check(text string) error {
+	if tokens := l.Tokens(text); tokens > l.MaxTokens {
+		return &InputTooLongError{Tokens: tokens, MaxTokens: l.MaxTokens, Model: l.Model}
+	}
+	return nil
+}
+
+// Split cuts text into parts that fit the limit, at whitespace where it can.
+func (l Limit) Split(text string) []string {
+	var parts []string
+	for l.Tokens(text) > l.MaxTokens {
+		runes := []rune(text)
+		// The longest prefix that fits, found by bisection as tokens grow with runes.
+		low, high := 1, len(runes)
+		for low < high {
+			mid := (low + high + 1) / 2
+			if l.Tokens(string(runes[:mid])) <= l.MaxTokens {
+				low = mid
+			} else {
+				high = mid - 1
+			}
+		}
+		cut := low
+		for n := low; n > low/2; n-- {
+			if unicode.IsSpace(runes[n-1]) {
+				cut = n
+				break
+			}
+		}
+		parts = append(parts, string(runes[:cut]))
+		text = strings.TrimLeftFunc(string(runes[cut:]), unicode.IsSpace)
+	}
+	if text != "" || len(parts) == 0 {
+		parts = append(parts, text)
+	}
+	return parts
+}
+
+// Fit records a text Limit.Embed fitted to the limit.
+type Fit struct {
+	// Index is the position of the text among those embedded together.
+	Index  int
+	Tokens int
+	// Strategy is what was done with it, LengthTruncate or LengthSplit.
+	Strategy LengthStrategy
+}
+
+// Embed embeds texts with service in one batch, fitting those over the limit as
+// l.Strategy says, and returns their vectors in order and what was done to fit
+// them. With LengthFail, the first text over the limit fails the batch with an
+// *InputTooLongError naming it.
+func (l Limit) Embed(ctx context.Context, service Service, texts []string, embeddingType EmbeddingType) ([]EmbedResponse, []Fit, error) {
+	var inputs []string
+	var fits []Fit
+	parts := make([]int, len(texts)) // inputs per text
+	for n, text := range texts {
+		tokens := l.Tokens(text)
+		if tokens <= l.MaxTokens {
+			inputs = append(inputs, text)
+			parts[n] = 1
+			continue
+		}
+		switch l.Strategy {
+		case LengthTruncate:
+			slog.WarnContext(ctx, "Truncating a text over the embedding model's input limit", "model", l.Model, "tokens", tokens, "max_tokens", l.MaxTokens)
+			inputs = append(inputs, l.Split(text)[0])
+			parts[n] = 1
+		case LengthSplit:
+			split := l.Split(text)
+			inputs = append(inputs, split...)
+			parts[n] = len(split)
+		default:
+			return nil, nil, &InputTooLongError{Index: n, Tokens: tokens, MaxTokens: l.MaxTokens, Model: l.Model}
+		}
+		fits = append(fits, Fit{Index: n, Tokens: tokens, Strategy: l.Strategy})
+	}
+
+	embedded, err := service.GetEmbeddingsBatch(ctx, inputs, embeddingType)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(embedded) != len(inputs) {
+		return nil, nil, fmt.Errorf("got %d embeddings for %d texts", len(embedded), len(inputs))
+	}
+	vectors := make([]EmbedResponse, len(texts))
+	next := 0
+	for n, count := range parts {
+		if count == 1 {
+			vectors[n] = embedded[next]
+		} else {
+			vectors[n] = Average(embedded[next : next+count])
+		}
+		next += count
+	}
+	return vectors, fits, nil
+}
+
+// Average returns the mean of vectors scaled to unit length, like the vectors of
+// the providers, so that it compares with them by cosine similarity.
+func Average(vectors []EmbedResponse) EmbedResponse {
+	if len(vectors) == 0 {
+		return nil
+	}
+	mean := make(EmbedResponse, len(vectors[0]))
+	for _, vector := range vectors {
+		for n := range mean {
+			mean[n] += vector[n]
+		}
+	}
+	var norm float64
+	for _, value := range mean {
+		norm += float64(value) * float64(value)
+	}
+	if norm == 0 {
+		return mean
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	for n := range mean {
+		mean[n] *= scale
+	}
+	return mean
+}
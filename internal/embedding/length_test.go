@@ -0,0 +1,135 @@
+package embedding
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newLimitedMistralService serves embeddings that refuse inputs over maxTokens,
+// as the API does, and records the inputs of each request.
+func newLimitedMistralService(t *testing.T, limit Limit) (*MistralService, *[][]string) {
+	t.Helper()
+	var requests [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Input []string `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		requests = append(requests, request.Input)
+		type data struct {
+			Embedding []float32 `json:"embedding"`
+			Index     int       `json:"index"`
+		}
+		var response struct {
+			Data []data `json:"data"`
+		}
+		for n, input := range request.Input {
+			if limit.Tokens(input) > limit.MaxTokens {
+				http.Error(w, `{"message": "Too many tokens in input"}`, http.StatusBadRequest)
+				return
+			}
+			// Alternate axes so that averaged parts are told apart from either.
+			embedding := []float32{1, 0}
+			if n%2 == 1 {
+				embedding = []float32{0, 1}
+			}
+			response.Data = append(response.Data, data{Embedding: embedding, Index: n})
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	s, err := newMistralService(WithHTTPClient(server.Client()), WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	return s, &requests
+}
+
+func TestLimit_Embed(t *testing.T) {
+	limit := Limit{Model: DefaultMistralEmbeddingModel, MaxTokens: 20}
+	long := strings.Repeat("Ada Lovelace worked with Charles Babbage. ", 5)
+	texts := []string{"Short text.", long}
+
+	t.Run("fail", func(t *testing.T) {
+		service, requests := newLimitedMistralService(t, limit)
+		limit := limit
+		limit.Strategy = LengthFail
+		_, _, err := limit.Embed(context.Background(), service, texts, EmbeddingTypeRetrievalDocument)
+		var tooLong *InputTooLongError
+		if !errors.Is(err, ErrInputTooLong) || !errors.As(err, &tooLong) || tooLong.Index != 1 {
+			t.Fatalf("Expected an InputTooLongError for text 1, got %v", err)
+		}
+		if len(*requests) != 0 {
+			t.Errorf("Expected no request, got %d", len(*requests))
+		}
+	})
+
+	t.Run("truncate", func(t *testing.T) {
+		service, requests := newLimitedMistralService(t, limit)
+		limit := limit
+		limit.Strategy = LengthTruncate
+		vectors, fits, err := limit.Embed(context.Background(), service, texts, EmbeddingTypeRetrievalDocument)
+		if err != nil {
+			t.Fatalf("Embed failed: %v", err)
+		}
+		if len(vectors) != 2 || len(*requests) != 1 || len((*requests)[0]) != 2 {
+			t.Fatalf("Expected 2 vectors from one request of 2 inputs, got %d from %q", len(vectors), *requests)
+		}
+		if !strings.HasPrefix(long, (*requests)[0][1]) {
+			t.Errorf("Expected a prefix of the long text, got %q", (*requests)[0][1])
+		}
+		if len(fits) != 1 || fits[0].Index != 1 || fits[0].Strategy != LengthTruncate || fits[0].Tokens <= limit.MaxTokens {
+			t.Errorf("Expected text 1 recorded as truncated, got %+v", fits)
+		}
+	})
+
+	t.Run("split", func(t *testing.T) {
+		service, requests := newLimitedMistralService(t, limit)
+		limit := limit
+		limit.Strategy = LengthSplit
+		vectors, fits, err := limit.Embed(context.Background(), service, texts, EmbeddingTypeRetrievalDocument)
+		if err != nil {
+			t.Fatalf("Embed failed: %v", err)
+		}
+		parts := limit.Split(long)
+		if len(parts) < 2 || len(*requests) != 1 || len((*requests)[0]) != 1+len(parts) {
+			t.Fatalf("Expected one request of the short text and the %d parts, got %q", len(parts), *requests)
+		}
+		if strings.Join(parts, "") != long {
+			t.Errorf("Expected the parts to make up the text, got %q", parts)
+		}
+		var norm float64
+		for _, value := range vectors[1] {
+			norm += float64(value) * float64(value)
+		}
+		if math.Abs(norm-1) > 1e-5 || vectors[1][1] == 0 {
+			t.Errorf("Expected an averaged unit vector, got %v", vectors[1])
+		}
+		if len(fits) != 1 || fits[0].Strategy != LengthSplit {
+			t.Errorf("Expected text 1 recorded as split, got %+v", fits)
+		}
+	})
+}
+
+func TestMaxInputTokens(t *testing.T) {
+	for model, expected := range map[string]int{
+		"mistral-embed":              8192,
+		"text-embedding-3-small":     8191,
+		"gemini-embedding-exp-03-07": 8192,
+		"gemini-embedding-001":       2048,
+	} {
+		if got, ok := MaxInputTokens(model); !ok || got != expected {
+			t.Errorf("Expected %s to take %d tokens, got %d", model, expected, got)
+		}
+	}
+	if _, ok := MaxInputTokens("unknown-model"); ok {
+		t.Errorf("Expected no limit for an unknown model")
+	}
+}
@@ -0,0 +1,394 @@
+package embedding
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/metrics"
+)
+
+// EnvLocalModel names the environment variable holding the directory of the model
+// LocalService embeds with.
+const EnvLocalModel = "AMG_LOCAL_EMBED_MODEL"
+
+// Files of a local model's directory, laid out as by model2vec.
+const (
+	localWeightsFile   = "model.safetensors"
+	localTokenizerFile = "tokenizer.json"
+)
+
+// LocalService embeds text in process with a static embedding model, such as
+// one distilled from all-MiniLM by model2vec, so that nothing is sent over the
+// network. A text's vector is the mean of its tokens' rows in the model's matrix,
+// scaled to unit length. The model is read on first use.
+type LocalService struct {
+	dir string
+	// Metrics receives a measurement of every call; metrics.Embeddings unless
+	// set.
+	Metrics metrics.Sink
+
+	once  sync.Once
+	model *localModel
+	err   error
+}
+
+// localModel is a loaded static embedding model.
+type localModel struct {
+	tokenizer  wordPiece
+	vectors    []float32 // rows of dimensions floats, one per token ID
+	dimensions int
+}
+
+// NewLocalService creates a LocalService for the model in the directory named by
+// AMG_LOCAL_EMBED_MODEL, holding model.safetensors and tokenizer.json. It fails
+// when the variable is not set or a file is missing, but reads the model only
+// when it is first needed.
+func NewLocalService() (*LocalService, error) {
+	dir := os.Getenv(EnvLocalModel)
+	if dir == "" {
+		return nil, fmt.Errorf("%s is not set: set it to the directory of a static embedding model, such as one from model2vec", EnvLocalModel)
+	}
+	return newLocalService(dir)
+}
+
+// newLocalService creates a LocalService for the model in dir like
+// NewLocalService.
+func newLocalService(dir string) (*LocalService, error) {
+	for _, name := range []string{localWeightsFile, localTokenizerFile} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			return nil, fmt.Errorf("local embedding model %s has no %s: check %s: %w", dir, name, EnvLocalModel, err)
+		}
+	}
+	return &LocalService{dir: dir, Metrics: metrics.Embeddings}, nil
+}
+
+// Model returns the name of the model's directory.
+func (s *LocalService) Model() string {
+	return filepath.Base(s.dir)
+}
+
+// GetType returns ProviderLocal.
+func (s *LocalService) GetType() Provider {
+	return ProviderLocal
+}
+
+// Dimensions returns the length of the model's vectors, read from the header of
+// its weights without loading them, or 0 when it cannot be read.
+func (s *LocalService) Dimensions() int {
+	file, err := os.Open(filepath.Join(s.dir, localWeightsFile))
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+	tensor, _, err := readSafetensorsHeader(file)
+	if err != nil {
+		return 0
+	}
+	return tensor.Shape[1]
+}
+
+// Ping loads the model, so that a corrupt one fails before any work is done.
+func (s *LocalService) Ping(ctx context.Context) error {
+	_, err := s.load()
+	return err
+}
+
+// GetEmbeddings returns the vector of text. Empty text fails with ErrInvalidInput.
+func (s *LocalService) GetEmbeddings(ctx context.Context, text string, embeddingType EmbeddingType) (vector EmbedResponse, err error) {
+	defer func(start time.Time) { recordCall(s.Metrics, ProviderLocal, start, []string{text}, err) }(time.Now())
+	model, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	return model.embed(text)
+}
+
+// GetEmbeddingsBatch returns the vector of each text.
+func (s *LocalService) GetEmbeddingsBatch(ctx context.Context, texts []string, embeddingType EmbeddingType) (vectors []EmbedResponse, err error) {
+	defer func(start time.Time) { recordCall(s.Metrics, ProviderLocal, start, texts, err) }(time.Now())
+	model, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	vectors = make([]EmbedResponse, len(texts))
+	for n, text := range texts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if vectors[n], err = model.embed(text); err != nil {
+			return nil, fmt.Errorf("text %d: %w", n, err)
+		}
+	}
+	return vectors, nil
+}
+
+// load reads the model once.
+func (s *LocalService) load() (*localModel, error) {
+	s.once.Do(func() {
+		s.model, s.err = loadLocalModel(s.dir)
+		if s.err != nil {
+			s.err = fmt.Errorf("failed to load local embedding model %s: %w", s.dir, s.err)
+		}
+	})
+	return s.model, s.err
+}
+
+func loadLocalModel(dir string) (*localModel, error) {
+	tokenizer, err := loadWordPiece(filepath.Join(dir, localTokenizerFile))
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(filepath.Join(dir, localWeightsFile))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	tensor, offset, err := readSafetensorsHeader(file)
+	if err != nil {
+		return nil, err
+	}
+	rows, dimensions := tensor.Shape[0], tensor.Shape[1]
+	size := tensor.DataOffsets[1] - tensor.DataOffsets[0]
+	if width := map[string]int{"F32": 4, "F16": 2}[tensor.DType]; size != rows*dimensions*width {
+		return nil, fmt.Errorf("%s: %s tensor of shape %v has %d bytes", localWeightsFile, tensor.DType, tensor.Shape, size)
+	}
+	data := make([]byte, size)
+	if _, err := file.ReadAt(data, offset+int64(tensor.DataOffsets[0])); err != nil {
+		return nil, fmt.Errorf("%s: %w", localWeightsFile, err)
+	}
+	vectors := make([]float32, rows*dimensions)
+	for n := range vectors {
+		if tensor.DType == "F16" {
+			vectors[n] = float16(binary.LittleEndian.Uint16(data[2*n:]))
+		} else {
+			vectors[n] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*n:]))
+		}
+	}
+	for token, id := range tokenizer.vocab {
+		if id < 0 || id >= rows {
+			return nil, fmt.Errorf("%s: token %q has ID %d beyond the %d rows of the model", localTokenizerFile, token, id, rows)
+		}
+	}
+	return &localModel{tokenizer: tokenizer, vectors: vectors, dimensions: dimensions}, nil
+}
+
+// embed returns the mean of the rows of text's tokens, scaled to unit length.
+// Unknown tokens are skipped unless there is nothing else.
+func (m *localModel) embed(text string) (EmbedResponse, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("%w: empty text", ErrInvalidInput)
+	}
+	ids := m.tokenizer.tokenize(text)
+	var known []int
+	for _, id := range ids {
+		if id != m.tokenizer.unknown {
+			known = append(known, id)
+		}
+	}
+	if len(known) == 0 {
+		known = ids
+	}
+	if len(known) == 0 || known[0] < 0 {
+		return nil, fmt.Errorf("%w: no token of the text is in the model's vocabulary", ErrInvalidInput)
+	}
+	vector := make(EmbedResponse, m.dimensions)
+	for _, id := range known {
+		row := m.vectors[id*m.dimensions : (id+1)*m.dimensions]
+		for n := range vector {
+			vector[n] += row[n]
+		}
+	}
+	return normalize(vector), nil
+}
+
+// safetensorsTensor describes a tensor in the header of a safetensors file.
+type safetensorsTensor struct {
+	DType       string `json:"dtype"`
+	Shape       []int  `json:"shape"`
+	DataOffsets [2]int `json:"data_offsets"`
+}
+
+// readSafetensorsHeader returns the embedding matrix described by the header of a
+// safetensors file, the tensor named "embeddings" or else its only tensor, and
+// the offset in the file its data offsets count from.
+func readSafetensorsHeader(r io.Reader) (safetensorsTensor, int64, error) {
+	var length uint64
+	if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+		return safetensorsTensor{}, 0, fmt.Errorf("%s: %w", localWeightsFile, err)
+	}
+	if length > 100<<20 {
+		return safetensorsTensor{}, 0, fmt.Errorf("%s: header of %d bytes is not that of a safetensors file", localWeightsFile, length)
+	}
+	header := make([]byte, length)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return safetensorsTensor{}, 0, fmt.Errorf("%s: %w", localWeightsFile, err)
+	}
+	var entries map[string]json.RawMessage
+	if err := json.Unmarshal(header, &entries); err != nil {
+		return safetensorsTensor{}, 0, fmt.Errorf("%s: invalid header: %w", localWeightsFile, err)
+	}
+	delete(entries, "__metadata__")
+	raw, ok := entries["embeddings"]
+	if !ok && len(entries) == 1 {
+		for _, only := range entries {
+			raw = only
+		}
+	}
+	if raw == nil {
+		return safetensorsTensor{}, 0, fmt.Errorf("%s: no embeddings tensor", localWeightsFile)
+	}
+	var tensor safetensorsTensor
+	if err := json.Unmarshal(raw, &tensor); err != nil {
+		return safetensorsTensor{}, 0, fmt.Errorf("%s: invalid header: %w", localWeightsFile, err)
+	}
+	if len(tensor.Shape) != 2 || tensor.Shape[0] < 1 || tensor.Shape[1] < 1 {
+		return safetensorsTensor{}, 0, fmt.Errorf("%s: embeddings of shape %v are not a matrix", localWeightsFile, tensor.Shape)
+	}
+	if tensor.DType != "F32" && tensor.DType != "F16" {
+		return safetensorsTensor{}, 0, fmt.Errorf("%s: unsupported dtype %s, want F32 or F16", localWeightsFile, tensor.DType)
+	}
+	return tensor, 8 + int64(length), nil
+}
+
+// float16 converts IEEE 754 half-precision bits to a float32.
+func float16(bits uint16) float32 {
+	sign := uint32(bits>>15) << 31
+	exponent := uint32(bits>>10) & 0x1f
+	mantissa := uint32(bits) & 0x3ff
+	switch {
+	case exponent == 0 && mantissa == 0:
+		return math.Float32frombits(sign)
+	case exponent == 0:
+		// Subnormal: the value is mantissa * 2^-24.
+		value := float32(mantissa) / (1 << 24)
+		if sign != 0 {
+			value = -value
+		}
+		return value
+	case exponent == 0x1f:
+		return math.Float32frombits(sign | 0xff<<23 | mantissa<<13)
+	}
+	return math.Float32frombits(sign | (exponent+127-15)<<23 | mantissa<<13)
+}
+
+// wordPiece is the tokenizer of BERT models: text is lowercased, split at
+// whitespace and punctuation, and each word into the longest pieces in the
+// vocabulary, pieces after the first marked with a prefix.
+type wordPiece struct {
+	vocab     map[string]int
+	unknown   int // ID of the unknown token, or -1
+	prefix    string
+	lowercase bool
+}
+
+// loadWordPiece reads the WordPiece model of a Hugging Face tokenizer.json.
+func loadWordPiece(path string) (wordPiece, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return wordPiece{}, err
+	}
+	var config struct {
+		Model struct {
+			Type                    string         `json:"type"`
+			Vocab                   map[string]int `json:"vocab"`
+			UnkToken                string         `json:"unk_token"`
+			ContinuingSubwordPrefix *string        `json:"continuing_subword_prefix"`
+		} `json:"model"`
+		Normalizer *struct {
+			Lowercase *bool `json:"lowercase"`
+		} `json:"normalizer"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return wordPiece{}, fmt.Errorf("%s: %w", localTokenizerFile, err)
+	}
+	if config.Model.Type != "WordPiece" || len(config.Model.Vocab) == 0 {
+		return wordPiece{}, fmt.Errorf("%s: want a WordPiece model with a vocabulary, got %q", localTokenizerFile, config.Model.Type)
+	}
+	tokenizer := wordPiece{vocab: config.Model.Vocab, unknown: -1, prefix: "##", lowercase: true}
+	if id, ok := config.Model.Vocab[config.Model.UnkToken]; ok {
+		tokenizer.unknown = id
+	}
+	if config.Model.ContinuingSubwordPrefix != nil {
+		tokenizer.prefix = *config.Model.ContinuingSubwordPrefix
+	}
+	if config.Normalizer != nil && config.Normalizer.Lowercase != nil {
+		tokenizer.lowercase = *config.Normalizer.Lowercase
+	}
+	return tokenizer, nil
+}
+
+// tokenize returns the IDs of text's tokens, the unknown token's for words that
+// cannot be split into pieces of the vocabulary.
+func (t wordPiece) tokenize(text string) []int {
+	if t.lowercase {
+		text = strings.ToLower(text)
+	}
+	var ids []int
+	for _, word := range splitWords(text) {
+		ids = append(ids, t.pieces(word)...)
+	}
+	return ids
+}
+
+// pieces splits word greedily into the longest pieces in the vocabulary.
+func (t wordPiece) pieces(word string) []int {
+	runes := []rune(word)
+	if len(runes) > 100 {
+		return []int{t.unknown}
+	}
+	var ids []int
+	for start := 0; start < len(runes); {
+		id, end := -1, len(runes)
+		for ; end > start; end-- {
+			piece := string(runes[start:end])
+			if start > 0 {
+				piece = t.prefix + piece
+			}
+			if found, ok := t.vocab[piece]; ok {
+				id = found
+				break
+			}
+		}
+		if id < 0 {
+			return []int{t.unknown}
+		}
+		ids = append(ids, id)
+		start = end
+	}
+	return ids
+}
+
+// splitWords splits text at whitespace, and around each punctuation mark.
+func splitWords(text string) []string {
+	var words []string
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			words = append(words, word.String())
+			word.Reset()
+		}
+	}
+	for _, r := range text {
+		switch {
+		case unicode.IsSpace(r) || unicode.IsControl(r):
+			flush()
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			flush()
+			words = append(words, string(r))
+		default:
+			word.WriteRune(r)
+		}
+	}
+	flush()
+	return words
+}
@@ -0,0 +1,117 @@
+package embedding
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// localVocab is the vocabulary of the model writeLocalModel writes, whose token
+// of ID n has 1 at index n%4 of its 4-dimension row.
+var localVocab = map[string]int{"[UNK]": 0, "graph": 1, "data": 2, "##base": 3, "kuzu": 4, ".": 5}
+
+// writeLocalModel writes a static embedding model with the weights in dtype,
+// F32 or F16, and returns its directory.
+func writeLocalModel(t *testing.T, dtype string) string {
+	t.Helper()
+	dir := t.TempDir()
+	const dimensions = 4
+	var data []byte
+	for id := 0; id < len(localVocab); id++ {
+		for n := 0; n < dimensions; n++ {
+			value := float32(0)
+			if n == id%dimensions {
+				value = 1
+			}
+			if dtype == "F16" {
+				data = binary.LittleEndian.AppendUint16(data, map[float32]uint16{0: 0, 1: 0x3c00}[value])
+			} else {
+				data = binary.LittleEndian.AppendUint32(data, math.Float32bits(value))
+			}
+		}
+	}
+	header, _ := json.Marshal(map[string]any{
+		"__metadata__": map[string]string{"format": "pt"},
+		"embeddings":   map[string]any{"dtype": dtype, "shape": []int{len(localVocab), dimensions}, "data_offsets": []int{0, len(data)}},
+	})
+	weights := binary.LittleEndian.AppendUint64(nil, uint64(len(header)))
+	weights = append(append(weights, header...), data...)
+	tokenizer, _ := json.Marshal(map[string]any{
+		"normalizer": map[string]any{"type": "BertNormalizer", "lowercase": true},
+		"model":      map[string]any{"type": "WordPiece", "unk_token": "[UNK]", "continuing_subword_prefix": "##", "vocab": localVocab},
+	})
+	if err := os.WriteFile(filepath.Join(dir, localWeightsFile), weights, 0o644); err != nil {
+		t.Fatalf("Failed to write weights: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, localTokenizerFile), tokenizer, 0o644); err != nil {
+		t.Fatalf("Failed to write tokenizer: %v", err)
+	}
+	return dir
+}
+
+func TestLocalService_Embeds(t *testing.T) {
+	for _, dtype := range []string{"F32", "F16"} {
+		t.Run(dtype, func(t *testing.T) {
+			t.Setenv(EnvLocalModel, writeLocalModel(t, dtype))
+			service, err := New(ProviderLocal)
+			if err != nil {
+				t.Fatalf("Failed to create service: %v", err)
+			}
+			if d := service.(Dimensioner).Dimensions(); d != 4 {
+				t.Errorf("Expected 4 dimensions from the model's header, got %d", d)
+			}
+
+			// "Database" is split into "data" and "##base"; "unknown" is skipped.
+			vector, err := service.GetEmbeddings(context.Background(), "Database unknown", EmbeddingTypeRetrievalDocument)
+			if err != nil {
+				t.Fatalf("GetEmbeddings failed: %v", err)
+			}
+			half := float32(1 / math.Sqrt2)
+			if expected := (EmbedResponse{0, 0, half, half}); !reflect.DeepEqual(vector, expected) {
+				t.Errorf("Expected %v, got %v", expected, vector)
+			}
+
+			vectors, err := service.GetEmbeddingsBatch(context.Background(), []string{"KUZU.", "graph"}, EmbeddingTypeRetrievalDocument)
+			if err != nil || len(vectors) != 2 || !reflect.DeepEqual(vectors[1], EmbedResponse{0, 1, 0, 0}) {
+				t.Errorf("Expected the vector of each text, got %v, %v", vectors, err)
+			}
+			if _, err := service.GetEmbeddings(context.Background(), " ", EmbeddingTypeRetrievalDocument); !errors.Is(err, ErrInvalidInput) {
+				t.Errorf("Expected empty text to fail with ErrInvalidInput, got %v", err)
+			}
+		})
+	}
+}
+
+func TestNewLocalService_MissingModel(t *testing.T) {
+	t.Setenv(EnvLocalModel, "")
+	if _, err := New(ProviderLocal); err == nil || !strings.Contains(err.Error(), EnvLocalModel+" is not set") {
+		t.Errorf("Expected an unset %s to fail, got %v", EnvLocalModel, err)
+	}
+
+	dir := t.TempDir()
+	t.Setenv(EnvLocalModel, dir)
+	if _, err := New(ProviderLocal); err == nil || !strings.Contains(err.Error(), "has no "+localWeightsFile) {
+		t.Errorf("Expected a missing %s to fail, got %v", localWeightsFile, err)
+	}
+
+	// A corrupt model is found when it is first used.
+	dir = writeLocalModel(t, "F32")
+	if err := os.WriteFile(filepath.Join(dir, localWeightsFile), []byte("not a model"), 0o644); err != nil {
+		t.Fatalf("Failed to write weights: %v", err)
+	}
+	t.Setenv(EnvLocalModel, dir)
+	service, err := New(ProviderLocal)
+	if err != nil {
+		t.Fatalf("Expected the model to be read lazily, got %v", err)
+	}
+	if err := service.Ping(context.Background()); err == nil || !strings.Contains(err.Error(), "failed to load local embedding model") {
+		t.Errorf("Expected Ping to report the corrupt model, got %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package embedding
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/budget"
+	"github.com/sandwichlabs/agent-memory-graph/internal/metrics"
+)
+
+// Methods the embedding services record calls as.
+const (
+	// MethodEmbed is a request embedding texts.
+	MethodEmbed = "embed"
+	// MethodCache is a lookup of texts in a CachedService's store.
+	MethodCache = "cache"
+)
+
+// errorClass names the class of err in metrics, or is empty for nil.
+func errorClass(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, budget.ErrBudgetExhausted):
+		return "budget_exhausted"
+	case errors.Is(err, ErrUnauthorized):
+		return "unauthorized"
+	case errors.Is(err, ErrUnreachable):
+		return "unreachable"
+	case errors.Is(err, ErrRateLimited):
+		return "rate_limited"
+	case errors.Is(err, ErrUpstream):
+		return "upstream"
+	case errors.Is(err, ErrInputTooLong):
+		return "input_too_long"
+	case errors.Is(err, ErrInvalidInput):
+		return "invalid_input"
+	case errors.Is(err, context.Canceled):
+		return "cancelled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	}
+	return "other"
+}
+
+// recordCall records a request of provider embedding texts to sink, when it is
+// not nil, as having started at start and ended with err.
+func recordCall(sink metrics.Sink, provider Provider, start time.Time, texts []string, err error) {
+	if sink == nil {
+		return
+	}
+	sink.RecordCall(metrics.Call{
+		Provider:   string(provider),
+		Method:     MethodEmbed,
+		Duration:   time.Since(start),
+		ErrorClass: errorClass(err),
+		Texts:      len(texts),
+		Bytes:      textBytes(texts),
+	})
+}
+
+// textBytes returns the total length of texts in bytes.
+func textBytes(texts []string) int {
+	bytes := 0
+	for _, text := range texts {
+		bytes += len(text)
+	}
+	return bytes
+}
@@ -0,0 +1,114 @@
+package embedding
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/budget"
+	"github.com/sandwichlabs/agent-memory-graph/internal/metrics"
+)
+
+func TestMistralService_Metrics(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status != http.StatusOK {
+			http.Error(w, "Unauthorized", status)
+			return
+		}
+		w.Write([]byte(`{"data": [{"embedding": [0.1, 0.2], "index": 0}, {"embedding": [0.3, 0.4], "index": 1}]}`))
+	}))
+	defer server.Close()
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	registry := metrics.NewRegistry()
+	s, err := newMistralService(WithHTTPClient(server.Client()), WithBaseURL(server.URL), WithMetrics(registry))
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+
+	for range 2 {
+		if _, err := s.GetEmbeddingsBatch(context.Background(), []string{"one", "three"}, EmbeddingTypeRetrievalDocument); err != nil {
+			t.Fatalf("GetEmbeddingsBatch failed: %v", err)
+		}
+	}
+	status = http.StatusUnauthorized
+	if _, err := s.GetEmbeddings(context.Background(), "text", EmbeddingTypeRetrievalDocument); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("Expected %v, got %v", ErrUnauthorized, err)
+	}
+
+	snapshot := registry.Snapshot()
+	if len(snapshot) != 1 {
+		t.Fatalf("Expected the embed method of mistral only, got %+v", snapshot)
+	}
+	calls := snapshot[0]
+	if calls.Provider != "mistral" || calls.Method != MethodEmbed {
+		t.Errorf("Expected mistral embed, got %s %s", calls.Provider, calls.Method)
+	}
+	if calls.Requests != 3 || calls.Errors != 1 || calls.ErrorsByClass["unauthorized"] != 1 {
+		t.Errorf("Expected 3 requests and 1 unauthorized error, got %+v", calls)
+	}
+	if calls.Texts != 5 || calls.Bytes != 20 {
+		t.Errorf("Expected 5 texts of 20 bytes, got %d of %d", calls.Texts, calls.Bytes)
+	}
+	if calls.P50 <= 0 || calls.P95 < calls.P50 {
+		t.Errorf("Expected positive latency percentiles, got p50 %s and p95 %s", calls.P50, calls.P95)
+	}
+}
+
+func TestCachedService_Metrics(t *testing.T) {
+	store, err := OpenFileCacheStore(filepath.Join(t.TempDir(), "cache.jsonl"), 0)
+	if err != nil {
+		t.Fatalf("OpenFileCacheStore failed: %v", err)
+	}
+	defer store.Close()
+	registry := metrics.NewRegistry()
+	s := NewCachedService(&countingService{}, store)
+	s.Metrics = registry
+	ctx := context.Background()
+
+	if _, err := s.GetEmbeddingsBatch(ctx, []string{"a", "b"}, EmbeddingTypeRetrievalDocument); err != nil {
+		t.Fatalf("GetEmbeddingsBatch failed: %v", err)
+	}
+	if _, err := s.GetEmbeddingsBatch(ctx, []string{"a", "b", "c", "d"}, EmbeddingTypeRetrievalDocument); err != nil {
+		t.Fatalf("GetEmbeddingsBatch failed: %v", err)
+	}
+
+	snapshot := registry.Snapshot()
+	if len(snapshot) != 1 || snapshot[0].Method != MethodCache || snapshot[0].Provider != string(ProviderTestMock) {
+		t.Fatalf("Expected the cache lookups of the mock only, got %+v", snapshot)
+	}
+	lookups := snapshot[0]
+	if lookups.Requests != 2 || lookups.Texts != 6 || lookups.CacheHits != 2 || lookups.CacheMisses != 4 {
+		t.Errorf("Expected 2 lookups of 6 texts with 2 hits and 4 misses, got %+v", lookups)
+	}
+	if rate := lookups.CacheHitRate(); rate < 0.33 || rate > 0.34 {
+		t.Errorf("Expected a hit rate of a third, got %v", rate)
+	}
+}
+
+func TestErrorClass(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, ""},
+		{budget.ErrBudgetExhausted, "budget_exhausted"},
+		{ErrUnauthorized, "unauthorized"},
+		{ErrUnreachable, "unreachable"},
+		{statusError("mistral", 429, nil), "rate_limited"},
+		{statusError("mistral", 503, nil), "upstream"},
+		{ErrInputTooLong, "input_too_long"},
+		{ErrInvalidInput, "invalid_input"},
+		{context.Canceled, "cancelled"},
+		{context.DeadlineExceeded, "timeout"},
+		{errors.New("connection refused"), "other"},
+	}
+	for _, tt := range tests {
+		if got := errorClass(tt.err); got != tt.want {
+			t.Errorf("Expected %v to be classed %q, got %q", tt.err, tt.want, got)
+		}
+	}
+}
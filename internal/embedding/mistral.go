@@ -2,73 +2,355 @@ package embedding
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/httpclient"
+	"github.com/sandwichlabs/agent-memory-graph/internal/metrics"
+	"github.com/sandwichlabs/agent-memory-graph/internal/ratelimit"
+	"github.com/sandwichlabs/agent-memory-graph/internal/retry"
 )
 
+// DefaultMistralBaseURL is the Mistral API that MistralService calls unless
+// MISTRAL_EMBEDDING_API_BASE, MISTRAL_API_BASE or WithBaseURL is given.
+const DefaultMistralBaseURL = "https://api.mistral.ai/v1"
+
+// DefaultMistralEmbeddingModel is the model MistralService embeds text with unless
+// MISTRAL_EMBED_MODEL or WithModel is given.
+const DefaultMistralEmbeddingModel = "mistral-embed"
+
+// EnvMistralQueryPrefix names the environment variable holding the text
+// MistralService prefixes queries with, as the Mistral API has no task type.
+const EnvMistralQueryPrefix = "MISTRAL_EMBED_QUERY_PREFIX"
+
+// DefaultMistralMaxBatchSize is the number of texts MistralService embeds per
+// request unless MaxBatchSize is set.
+const DefaultMistralMaxBatchSize = 128
+
 // MistralService is a service that interacts with the Mistral API.
 type MistralService struct {
-	apiKey string
-	client *http.Client
+	apiKey      string
+	model       string
+	queryPrefix string
+	client      *http.Client
+	baseURL     string
+	// Limiter spaces out requests; it is shared with the Mistral LLM service when
+	// MISTRAL_RPS is set, and nil otherwise.
+	Limiter *ratelimit.Limiter
+	// MaxBatchSize is the number of texts GetEmbeddingsBatch sends per request;
+	// DefaultMistralMaxBatchSize when zero.
+	MaxBatchSize int
+	// Retry governs retrying rate-limited and failed requests;
+	// retry.DefaultPolicy unless set with WithRetry.
+	Retry retry.Policy
+	// Timeout bounds each attempt of a request, which is retried when it runs
+	// past it; zero lifts the bound. It is AMG_EMBEDDING_TIMEOUT, or
+	// DefaultTimeout, unless set with WithTimeout.
+	Timeout time.Duration
+	// Metrics receives a measurement of every request, retries included;
+	// metrics.Embeddings unless set with WithMetrics.
+	Metrics metrics.Sink
+
+	promptTokens atomic.Int64 // billed so far, as the API reports
+}
+
+// MistralOption configures a MistralService.
+type MistralOption func(*MistralService)
+
+// WithHTTPClient sets the client that sends requests, instead of one configured
+// by the environment, for full control of proxies and TLS.
+func WithHTTPClient(client *http.Client) MistralOption {
+	return func(s *MistralService) { s.client = client }
+}
+
+// WithBaseURL sets the URL of the API, such as a test server, instead of
+// DefaultMistralBaseURL.
+func WithBaseURL(baseURL string) MistralOption {
+	return func(s *MistralService) { s.baseURL = strings.TrimRight(baseURL, "/") }
+}
+
+// WithRetry sets how rate-limited and failed requests are retried.
+func WithRetry(policy retry.Policy) MistralOption {
+	return func(s *MistralService) { s.Retry = policy }
+}
+
+// WithTimeout bounds each attempt of a request instead of AMG_EMBEDDING_TIMEOUT;
+// zero lifts the bound.
+func WithTimeout(timeout time.Duration) MistralOption {
+	return func(s *MistralService) { s.Timeout = timeout }
+}
+
+// WithMetrics records the measurements of requests to sink.
+func WithMetrics(sink metrics.Sink) MistralOption {
+	return func(s *MistralService) { s.Metrics = sink }
+}
+
+// WithAPIKey sets the API key instead of MISTRAL_API_KEY.
+func WithAPIKey(key string) MistralOption {
+	return func(s *MistralService) { s.apiKey = key }
+}
+
+// WithModel sets the model that embeds text instead of MISTRAL_EMBED_MODEL or
+// DefaultMistralEmbeddingModel.
+func WithModel(model string) MistralOption {
+	return func(s *MistralService) { s.model = model }
+}
+
+// WithQueryPrefix sets the text queries are prefixed with instead of
+// MISTRAL_EMBED_QUERY_PREFIX; empty embeds queries as they are.
+func WithQueryPrefix(prefix string) MistralOption {
+	return func(s *MistralService) { s.queryPrefix = prefix }
+}
+
+// NewMistralService creates a new MistralService with the key in MISTRAL_API_KEY,
+// unless WithAPIKey is given,
+// embedding with MISTRAL_EMBED_MODEL, or DefaultMistralEmbeddingModel, unless
+// WithModel is given, and prefixing queries with MISTRAL_EMBED_QUERY_PREFIX
+// unless WithQueryPrefix is given. Each attempt of a request is bounded by
+// AMG_EMBEDDING_TIMEOUT unless WithTimeout is given. Its HTTP client honors HTTPS_PROXY and AMG_CA_BUNDLE unless
+// WithHTTPClient is given, and its base URL MISTRAL_EMBEDDING_API_BASE, or else
+// MISTRAL_API_BASE, unless WithBaseURL is. It fails when the key is not set or a
+// setting is invalid.
+func NewMistralService(opts ...MistralOption) (Service, error) {
+	s, err := newMistralService(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// newMistralService creates a MistralService like NewMistralService.
+func newMistralService(opts ...MistralOption) (*MistralService, error) {
+	limiter, err := ratelimit.FromEnv("MISTRAL_RPS")
+	if err != nil {
+		slog.Error("Ignoring rate limit for Mistral embeddings", "error", err)
+	}
+	baseURL, err := mistralBaseURL()
+	if err != nil {
+		return nil, err
+	}
+	model := os.Getenv("MISTRAL_EMBED_MODEL")
+	if model == "" {
+		model = DefaultMistralEmbeddingModel
+	}
+	timeout, err := TimeoutFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	s := &MistralService{
+		model:       model,
+		queryPrefix: os.Getenv(EnvMistralQueryPrefix),
+		baseURL:     baseURL,
+		Limiter:     limiter,
+		Retry:       retry.DefaultPolicy,
+		Timeout:     timeout,
+		Metrics:     metrics.Embeddings,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.apiKey == "" {
+		if s.apiKey, err = requireAPIKey(ProviderMistral); err != nil {
+			return nil, err
+		}
+	}
+	if s.model == "" {
+		return nil, fmt.Errorf("mistral embedding model must not be empty")
+	}
+	if s.client == nil {
+		if s.client, err = httpclient.FromEnv(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// mistralBaseURL returns the base URL in MISTRAL_EMBEDDING_API_BASE, or else in
+// MISTRAL_API_BASE, which the Mistral LLM service also reads, or else
+// DefaultMistralBaseURL.
+func mistralBaseURL() (string, error) {
+	for _, name := range []string{"MISTRAL_EMBEDDING_API_BASE", "MISTRAL_API_BASE"} {
+		baseURL, err := httpclient.BaseURLFromEnv(name)
+		if err != nil {
+			return "", err
+		}
+		if baseURL != "" {
+			return baseURL, nil
+		}
+	}
+	return DefaultMistralBaseURL, nil
+}
+
+// Model returns the model that embeds text.
+func (s *MistralService) Model() string {
+	return s.model
+}
+
+// GetEmbeddings sends a request to the Mistral API to get embeddings for the
+// given text, prefixed as inputs says.
+func (s *MistralService) GetEmbeddings(ctx context.Context, text string, embeddingType EmbeddingType) (EmbedResponse, error) {
+	vectors, err := s.embed(ctx, s.inputs([]string{text}, embeddingType))
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+// GetEmbeddingsBatch embeds texts, prefixed as inputs says, with one request to
+// the Mistral API per s.MaxBatchSize of them.
+func (s *MistralService) GetEmbeddingsBatch(ctx context.Context, texts []string, embeddingType EmbeddingType) ([]EmbedResponse, error) {
+	size := s.MaxBatchSize
+	if size <= 0 {
+		size = DefaultMistralMaxBatchSize
+	}
+	return inBatches(s.inputs(texts, embeddingType), size, func(batch []string) ([]EmbedResponse, error) {
+		return s.embed(ctx, batch)
+	})
 }
 
-// NewMistralService creates a new MistralService.
-func NewMistralService() Service {
-	return &MistralService{
-		apiKey: os.Getenv("MISTRAL_API_KEY"),
-		client: &http.Client{},
+// inputs returns texts as sent to embed them as embeddingType. The Mistral API
+// has no task type, unlike Gemini's or Voyage AI's, so its models embed
+// documents and queries alike. Queries are prefixed with s.queryPrefix, when
+// set, as an instruction telling them apart; documents are sent as they are, so
+// that the vectors stored stay valid when the prefix changes.
+func (s *MistralService) inputs(texts []string, embeddingType EmbeddingType) []string {
+	if embeddingType != EmbeddingTypeRetrievalQuery || s.queryPrefix == "" {
+		return texts
 	}
+	prefixed := make([]string, len(texts))
+	for n, text := range texts {
+		prefixed[n] = s.queryPrefix + text
+	}
+	return prefixed
 }
 
-// GetEmbeddings sends a request to the Mistral API to get embeddings for the given text.
-func (s *MistralService) GetEmbeddings(text string, embeddingType EmbeddingType) (EmbedResponse, error) {
+// embed sends texts to the embeddings endpoint in one request, retried as s.Retry
+// allows, and returns their vectors in the same order. Its errors name the model,
+// as services of several models may share a key.
+func (s *MistralService) embed(ctx context.Context, texts []string) (vectors []EmbedResponse, err error) {
+	defer func(start time.Time) { recordCall(s.Metrics, ProviderMistral, start, texts, err) }(time.Now())
+	err = s.Retry.Do(ctx, "mistral embeddings", func() (transient bool, err error) {
+		vectors, transient, err = s.post(ctx, texts)
+		return transient, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", s.model, err)
+	}
+	return vectors, nil
+}
+
+// post makes one attempt at the embeddings request of embed, bounded by
+// s.Timeout, reporting whether its failure is transient.
+func (s *MistralService) post(ctx context.Context, texts []string) (vectors []EmbedResponse, transient bool, err error) {
 	// Prepare the request body
 	requestBody, err := json.Marshal(map[string]interface{}{
-		"model": "mistral-embed",
-		"input": []string{text},
+		"model": s.model,
+		"input": texts,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request body: %w", err)
+		return nil, false, fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	// Wait for the rate limit first so that waiting does not use up the timeout.
+	if err := s.Limiter.Wait(ctx); err != nil {
+		return nil, false, err
 	}
+	requestCtx, cancel := requestContext(ctx, s.Timeout)
+	defer cancel()
 
 	// Create the HTTP request
-	req, err := http.NewRequest("POST", "https://api.mistral.ai/v1/embeddings", bytes.NewBuffer(requestBody))
+	req, err := http.NewRequestWithContext(requestCtx, "POST", s.baseURL+"/embeddings", bytes.NewBuffer(requestBody))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, false, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+s.apiKey)
 
-	// Send the request
 	resp, err := s.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		if ctx.Err() != nil {
+			return nil, false, ctx.Err()
+		}
+		if err := timeoutError(ctx, requestCtx, ProviderMistral, s.Timeout); err != nil {
+			return nil, true, err
+		}
+		return nil, retry.TransientError(err), fmt.Errorf("failed to send request: %w", err)
 	}
-	defer resp.Body.Close()
+	defer retry.CloseBody(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("mistral API error: %s - %s", resp.Status, string(bodyBytes))
+		return nil, retry.TransientStatus(resp.StatusCode), retry.StatusError(resp, statusError("mistral", resp.StatusCode, bodyBytes))
 	}
 
-	// Decode the response
+	// Decode the response; each embedding has the index of its input.
 	var mistralResponse struct {
 		Data []struct {
 			Embedding []float32 `json:"embedding"`
+			Index     int       `json:"index"`
 		} `json:"data"`
+		Usage embeddingsUsage `json:"usage"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&mistralResponse); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+		if err := timeoutError(ctx, requestCtx, ProviderMistral, s.Timeout); err != nil {
+			return nil, true, err
+		}
+		return nil, false, fmt.Errorf("failed to decode response: %w", err)
 	}
 
-	if len(mistralResponse.Data) == 0 {
-		return nil, fmt.Errorf("no embeddings found in response")
+	if len(mistralResponse.Data) != len(texts) {
+		return nil, false, fmt.Errorf("got %d embeddings for %d texts", len(mistralResponse.Data), len(texts))
+	}
+	vectors = make([]EmbedResponse, len(texts))
+	for _, data := range mistralResponse.Data {
+		if data.Index < 0 || data.Index >= len(texts) || vectors[data.Index] != nil {
+			return nil, false, fmt.Errorf("invalid embedding index %d for %d texts", data.Index, len(texts))
+		}
+		vectors[data.Index] = data.Embedding
 	}
+	s.promptTokens.Add(mistralResponse.Usage.tokens())
+	return vectors, false, nil
+}
 
-	response := mistralResponse.Data[0].Embedding
+// GetType returns ProviderMistral.
+func (s *MistralService) GetType() Provider {
+	return ProviderMistral
+}
 
-	return (EmbedResponse)(response), nil
+// Usage returns the tokens billed for the requests so far.
+func (s *MistralService) Usage() Usage {
+	return Usage{PromptTokens: s.promptTokens.Load()}
+}
+
+// Ping lists the models of the Mistral API with the service's key. Its error
+// wraps ErrUnauthorized for a bad key and ErrUnreachable when the API cannot be
+// reached.
+func (s *MistralService) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.baseURL+"/models", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("%w: failed to reach the Mistral API at %s: %v", ErrUnreachable, s.baseURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return statusError("mistral", resp.StatusCode, bodyBytes)
+	}
+	return nil
 }
@@ -0,0 +1,351 @@
+package embedding
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/retry"
+)
+
+func newPingTestService(t *testing.T, status int) *MistralService {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/models" || r.Header.Get("Authorization") != "Bearer test_api_key" {
+			http.Error(w, "Not found: Unexpected request "+r.URL.Path, http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(`{"object": "list"}`))
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	s, err := newMistralService(WithHTTPClient(server.Client()), WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	return s
+}
+
+func TestMistralService_Ping(t *testing.T) {
+	service := newPingTestService(t, http.StatusOK)
+	if err := Preflight(context.Background(), ProviderMistral, service); err != nil {
+		t.Errorf("Expected the preflight to pass, got %v", err)
+	}
+}
+
+func TestMistralService_PingUnauthorized(t *testing.T) {
+	service := newPingTestService(t, http.StatusUnauthorized)
+	err := Preflight(context.Background(), ProviderMistral, service)
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("Expected an error wrapping ErrUnauthorized, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "mistral rejected the API key; check MISTRAL_API_KEY") {
+		t.Errorf("Expected the preflight error to name MISTRAL_API_KEY, got %q", err)
+	}
+}
+
+func TestNewMistralService_BaseURLFromEnv(t *testing.T) {
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	t.Setenv("MISTRAL_API_BASE", "https://gateway.example.com/v1/")
+	t.Setenv("MISTRAL_EMBEDDING_API_BASE", "")
+	s, err := newMistralService()
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	if s.baseURL != "https://gateway.example.com/v1" {
+		t.Errorf("Expected the base URL of MISTRAL_API_BASE, got %q", s.baseURL)
+	}
+
+	t.Setenv("MISTRAL_EMBEDDING_API_BASE", "https://embeddings.example.com/v1")
+	if s, _ = newMistralService(); s.baseURL != "https://embeddings.example.com/v1" {
+		t.Errorf("Expected MISTRAL_EMBEDDING_API_BASE to take precedence, got %q", s.baseURL)
+	}
+	if s, _ = newMistralService(WithBaseURL("http://localhost:8080")); s.baseURL != "http://localhost:8080" {
+		t.Errorf("Expected WithBaseURL to take precedence, got %q", s.baseURL)
+	}
+
+	t.Setenv("MISTRAL_EMBEDDING_API_BASE", "embeddings.example.com")
+	if _, err := NewMistralService(); err == nil || !strings.Contains(err.Error(), "MISTRAL_EMBEDDING_API_BASE") {
+		t.Errorf("Expected an invalid MISTRAL_EMBEDDING_API_BASE to fail, got %v", err)
+	}
+}
+
+func TestNewMistralService_MissingKey(t *testing.T) {
+	t.Setenv("MISTRAL_API_KEY", "")
+	if _, err := New(ProviderMistral); err == nil || !strings.Contains(err.Error(), "MISTRAL_API_KEY") {
+		t.Errorf("Expected a missing key to fail, got %v", err)
+	}
+}
+
+func TestMistralService_Model(t *testing.T) {
+	var models []string
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Model string `json:"model"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		models = append(models, request.Model)
+		if status != http.StatusOK {
+			http.Error(w, `{"message": "Invalid model"}`, status)
+			return
+		}
+		w.Write([]byte(`{"data": [{"embedding": [0.1, 0.2], "index": 0}]}`))
+	}))
+	defer server.Close()
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	t.Setenv("MISTRAL_EMBED_MODEL", "")
+
+	s, err := newMistralService(WithHTTPClient(server.Client()), WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	t.Setenv("MISTRAL_EMBED_MODEL", "codestral-embed")
+	fromEnv, err := newMistralService(WithHTTPClient(server.Client()), WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	fromOption, err := newMistralService(WithHTTPClient(server.Client()), WithBaseURL(server.URL), WithModel("custom-embed"))
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	for _, service := range []*MistralService{s, fromEnv, fromOption} {
+		if _, err := service.GetEmbeddings(context.Background(), "text", EmbeddingTypeRetrievalDocument); err != nil {
+			t.Fatalf("GetEmbeddings failed: %v", err)
+		}
+	}
+	if expected := []string{DefaultMistralEmbeddingModel, "codestral-embed", "custom-embed"}; !reflect.DeepEqual(models, expected) {
+		t.Errorf("Expected requests for models %v, got %v", expected, models)
+	}
+
+	status = http.StatusBadRequest
+	if _, err := fromOption.GetEmbeddings(context.Background(), "text", EmbeddingTypeRetrievalDocument); err == nil || !strings.HasPrefix(err.Error(), "custom-embed: mistral API error") {
+		t.Errorf("Expected the error to name the model, got %v", err)
+	}
+	if _, err := newMistralService(WithModel("")); err == nil {
+		t.Errorf("Expected an empty model to fail")
+	}
+}
+
+func TestMistralService_GetEmbeddingsCancelled(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	s, err := newMistralService(WithHTTPClient(server.Client()), WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := s.GetEmbeddings(ctx, "text", EmbeddingTypeRetrievalDocument); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline to end the request, got %v", err)
+	}
+}
+
+func TestMistralService_GetEmbeddingsBatch(t *testing.T) {
+	var batches [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Input []string `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		batches = append(batches, request.Input)
+		if slices.Contains(request.Input, "bad") {
+			http.Error(w, `{"message": "input too long"}`, http.StatusBadRequest)
+			return
+		}
+		// The embeddings come back in reverse, placed by their index.
+		type data struct {
+			Embedding []float32 `json:"embedding"`
+			Index     int       `json:"index"`
+		}
+		var response struct {
+			Data []data `json:"data"`
+		}
+		for n := len(request.Input) - 1; n >= 0; n-- {
+			response.Data = append(response.Data, data{Embedding: []float32{float32(len(request.Input[n]))}, Index: n})
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	s, err := newMistralService(WithHTTPClient(server.Client()), WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	s.MaxBatchSize = 2
+
+	vectors, err := s.GetEmbeddingsBatch(context.Background(), []string{"a", "bb", "ccc", "dddd", "eeeee"}, EmbeddingTypeRetrievalDocument)
+	if err != nil {
+		t.Fatalf("GetEmbeddingsBatch failed: %v", err)
+	}
+	expected := []EmbedResponse{{1}, {2}, {3}, {4}, {5}}
+	if !reflect.DeepEqual(vectors, expected) {
+		t.Errorf("Expected the vectors in input order %v, got %v", expected, vectors)
+	}
+	if len(batches) != 3 || len(batches[0]) != 2 || len(batches[1]) != 2 || len(batches[2]) != 1 {
+		t.Errorf("Expected the batch split into requests of 2, 2 and 1 texts, got %q", batches)
+	}
+
+	if _, err := s.GetEmbeddingsBatch(context.Background(), []string{"good", "bad"}, EmbeddingTypeRetrievalDocument); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("Expected a refused batch to wrap ErrInvalidInput, got %v", err)
+	}
+}
+
+func TestMistralService_Retry(t *testing.T) {
+	requests, failures := 0, 2
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests <= failures {
+			http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"data": [{"embedding": [0.1, 0.2], "index": 0}]}`))
+	}))
+	defer server.Close()
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	s, err := newMistralService(WithHTTPClient(server.Client()), WithBaseURL(server.URL),
+		WithRetry(retry.Policy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}))
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+
+	vector, err := s.GetEmbeddings(context.Background(), "text", EmbeddingTypeRetrievalDocument)
+	if err != nil || len(vector) != 2 || requests != 3 {
+		t.Fatalf("Expected success on the third attempt, got %v, %v after %d requests", vector, err, requests)
+	}
+
+	requests, failures = 0, 10
+	_, err = s.GetEmbeddings(context.Background(), "text", EmbeddingTypeRetrievalDocument)
+	if err == nil || !strings.Contains(err.Error(), "gave up after 3 attempts") || requests != 3 {
+		t.Errorf("Expected to give up after 3 attempts, got %v after %d requests", err, requests)
+	}
+}
+
+func TestMistralService_KeysCoexist(t *testing.T) {
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Authorization"))
+		w.Write([]byte(`{"data": [{"embedding": [1], "index": 0}]}`))
+	}))
+	defer server.Close()
+	t.Setenv("MISTRAL_API_KEY", "")
+	first, err := NewMistralService(WithHTTPClient(server.Client()), WithBaseURL(server.URL), WithAPIKey("key-a"))
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	second, err := NewMistralService(WithHTTPClient(server.Client()), WithBaseURL(server.URL), WithAPIKey("key-b"))
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+
+	for _, service := range []Service{first, second, first} {
+		if _, err := service.GetEmbeddings(context.Background(), "text", EmbeddingTypeRetrievalDocument); err != nil {
+			t.Fatalf("GetEmbeddings failed: %v", err)
+		}
+	}
+	if expected := []string{"Bearer key-a", "Bearer key-b", "Bearer key-a"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Expected each service to send its own key %q, got %q", expected, keys)
+	}
+}
+
+func TestMistralService_Usage(t *testing.T) {
+	fixture, err := os.ReadFile("testdata/mistral-embeddings.json")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(fixture)
+	}))
+	defer server.Close()
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	s, err := newMistralService(WithHTTPClient(server.Client()), WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+
+	for range 2 {
+		if _, err := s.GetEmbeddingsBatch(context.Background(), []string{"first text", "second text"}, EmbeddingTypeRetrievalDocument); err != nil {
+			t.Fatalf("GetEmbeddingsBatch failed: %v", err)
+		}
+	}
+	if usage := UsageOf(s); usage != (Usage{PromptTokens: 22}) {
+		t.Errorf("Expected 22 tokens billed over two requests, got %+v", usage)
+	}
+	if usage := UsageOf(NewCachedService(s, nil)); usage != (Usage{PromptTokens: 22}) {
+		t.Errorf("Expected a cache to report the usage of its inner service, got %+v", usage)
+	}
+	if usage := UsageOf(NewMockService()); !usage.Unknown {
+		t.Errorf("Expected the usage of a service that does not report it to be unknown, got %+v", usage)
+	}
+}
+
+func TestMistralService_EmbeddingType(t *testing.T) {
+	var inputs [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Model string   `json:"model"`
+			Input []string `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		inputs = append(inputs, request.Input)
+		w.Write([]byte(`{"data": [{"embedding": [0.1, 0.2], "index": 0}]}`))
+	}))
+	defer server.Close()
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	t.Setenv(EnvMistralQueryPrefix, "")
+	embed := func(s *MistralService) {
+		t.Helper()
+		if _, err := s.GetEmbeddings(context.Background(), "Who wrote the notes?", EmbeddingTypeRetrievalDocument); err != nil {
+			t.Fatalf("GetEmbeddings failed: %v", err)
+		}
+		if _, err := s.GetEmbeddingsBatch(context.Background(), []string{"Who wrote the notes?"}, EmbeddingTypeRetrievalQuery); err != nil {
+			t.Fatalf("GetEmbeddingsBatch failed: %v", err)
+		}
+	}
+
+	s, err := newMistralService(WithHTTPClient(server.Client()), WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	embed(s)
+	if expected := [][]string{{"Who wrote the notes?"}, {"Who wrote the notes?"}}; !reflect.DeepEqual(inputs, expected) {
+		t.Errorf("Expected documents and queries sent alike without a prefix, got %q", inputs)
+	}
+
+	inputs = nil
+	t.Setenv(EnvMistralQueryPrefix, "Retrieve notes answering: ")
+	if s, err = newMistralService(WithHTTPClient(server.Client()), WithBaseURL(server.URL)); err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	embed(s)
+	if expected := [][]string{{"Who wrote the notes?"}, {"Retrieve notes answering: Who wrote the notes?"}}; !reflect.DeepEqual(inputs, expected) {
+		t.Errorf("Expected only the query prefixed, got %q", inputs)
+	}
+
+	inputs = nil
+	if s, err = newMistralService(WithHTTPClient(server.Client()), WithBaseURL(server.URL), WithQueryPrefix("query: ")); err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	embed(s)
+	if expected := [][]string{{"Who wrote the notes?"}, {"query: Who wrote the notes?"}}; !reflect.DeepEqual(inputs, expected) {
+		t.Errorf("Expected WithQueryPrefix to override %s, got %q", EnvMistralQueryPrefix, inputs)
+	}
+}
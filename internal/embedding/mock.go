@@ -1,26 +1,163 @@
 package embedding
 
-type MockService struct{}
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// DefaultMockDimensions is the length of MockService's vectors unless told
+// otherwise, that of the memory graph's schema.
+const DefaultMockDimensions = 768
+
+// MockService embeds text without a provider, for testing. Each text gets a unit
+// vector drawn from a generator seeded with its SHA-256 hash, so that the same
+// text always gets the same vector and different texts nearly orthogonal ones.
+type MockService struct {
+	dimensions int
+	latency    time.Duration
+}
+
+// MockOption configures a MockService.
+type MockOption func(*MockService)
+
+// WithMockDimensions sets the length of the vectors.
+func WithMockDimensions(dimensions int) MockOption {
+	return func(m *MockService) {
+		m.dimensions = dimensions
+	}
+}
+
+// WithMockLatency makes each request take latency, like a round trip to a
+// provider, for testing concurrency.
+func WithMockLatency(latency time.Duration) MockOption {
+	return func(m *MockService) {
+		m.latency = latency
+	}
+}
 
 // NewMockService creates a new MockService.
-func NewMockService() Service {
-	return &MockService{}
+func NewMockService(opts ...MockOption) Service {
+	m := &MockService{dimensions: DefaultMockDimensions}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// GetEmbeddings returns the vector of text. Empty text fails with ErrInvalidInput,
+// as it does with the providers.
+func (m *MockService) GetEmbeddings(ctx context.Context, text string, embeddingType EmbeddingType) (EmbedResponse, error) {
+	if err := m.wait(ctx); err != nil {
+		return nil, err
+	}
+	return m.vector(text)
+}
+
+// GetEmbeddingsBatch returns the vector of each text, in one request.
+func (m *MockService) GetEmbeddingsBatch(ctx context.Context, texts []string, embeddingType EmbeddingType) ([]EmbedResponse, error) {
+	if err := m.wait(ctx); err != nil {
+		return nil, err
+	}
+	vectors := make([]EmbedResponse, len(texts))
+	for n, text := range texts {
+		vector, err := m.vector(text)
+		if err != nil {
+			return nil, fmt.Errorf("text %d: %w", n, err)
+		}
+		vectors[n] = vector
+	}
+	return vectors, nil
 }
 
-// GetEmbeddings returns a mock embedding response.
-func (m *MockService) GetEmbeddings(text string, embeddingType EmbeddingType) (EmbedResponse, error) {
+// vector returns the vector of text.
+func (m *MockService) vector(text string) (EmbedResponse, error) {
 	if text == "" {
-		return nil, nil // Return nil for empty text
+		return nil, fmt.Errorf("%w: empty text", ErrInvalidInput)
 	}
-	// Return a mock embedding response
-	mockEmbedding := make(EmbedResponse, 768) // Assuming 768 dimensions for the embedding
-	for i := range mockEmbedding {
-		mockEmbedding[i] = float32(i) / 1000.0 // Mock values
+	return MockVector(text, m.dimensions), nil
+}
+
+// wait takes the latency of a request, or returns ctx's error when it ends first.
+func (m *MockService) wait(ctx context.Context) error {
+	if m.latency <= 0 {
+		return ctx.Err()
 	}
-	return mockEmbedding, nil
+	timer := time.NewTimer(m.latency)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Ping always succeeds.
+func (m *MockService) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Dimensions returns the length of the vectors.
+func (m *MockService) Dimensions() int {
+	return m.dimensions
 }
 
 // GetType returns the type of the embedding service.
 func (m *MockService) GetType() Provider {
 	return ProviderTestMock
 }
+
+// MockVector returns the unit vector of length dimensions MockService gives text.
+func MockVector(text string, dimensions int) EmbedResponse {
+	random := rand.New(rand.NewChaCha8(sha256.Sum256([]byte(text))))
+	vector := make(EmbedResponse, dimensions)
+	for n := range vector {
+		vector[n] = float32(random.NormFloat64())
+	}
+	return normalize(vector)
+}
+
+// NearDuplicate returns a unit vector whose cosine similarity to vector is
+// similarity, between -1 and 1, for testing thresholds. The direction it departs
+// from vector in is derived from seed, so that different seeds give different
+// vectors at the same similarity.
+func NearDuplicate(vector EmbedResponse, similarity float64, seed string) EmbedResponse {
+	unit := normalize(append(EmbedResponse(nil), vector...))
+	// The part of a random vector orthogonal to unit, by Gram-Schmidt.
+	orthogonal := MockVector(seed, len(vector))
+	var dot float64
+	for n := range unit {
+		dot += float64(unit[n]) * float64(orthogonal[n])
+	}
+	for n := range orthogonal {
+		orthogonal[n] -= float32(dot) * unit[n]
+	}
+	orthogonal = normalize(orthogonal)
+
+	away := math.Sqrt(max(0, 1-similarity*similarity))
+	duplicate := make(EmbedResponse, len(vector))
+	for n := range duplicate {
+		duplicate[n] = float32(similarity)*unit[n] + float32(away)*orthogonal[n]
+	}
+	return duplicate
+}
+
+// normalize scales vector to unit length in place and returns it.
+func normalize(vector EmbedResponse) EmbedResponse {
+	var norm float64
+	for _, value := range vector {
+		norm += float64(value) * float64(value)
+	}
+	if norm == 0 {
+		return vector
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	for n := range vector {
+		vector[n] *= scale
+	}
+	return vector
+}
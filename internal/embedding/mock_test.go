@@ -0,0 +1,64 @@
+package embedding
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func cosine(a, b EmbedResponse) float64 {
+	var dot, normA, normB float64
+	for n := range a {
+		dot += float64(a[n]) * float64(b[n])
+		normA += float64(a[n]) * float64(a[n])
+		normB += float64(b[n]) * float64(b[n])
+	}
+	return dot / math.Sqrt(normA*normB)
+}
+
+func TestMockService_DerivesVectorsFromText(t *testing.T) {
+	ctx := context.Background()
+	service := NewMockService()
+	first, err := service.GetEmbeddings(ctx, "Ada Lovelace", EmbeddingTypeRetrievalDocument)
+	if err != nil {
+		t.Fatalf("GetEmbeddings failed: %v", err)
+	}
+	again, _ := service.GetEmbeddings(ctx, "Ada Lovelace", EmbeddingTypeRetrievalQuery)
+	other, _ := service.GetEmbeddings(ctx, "Charles Babbage", EmbeddingTypeRetrievalDocument)
+	if len(first) != DefaultMockDimensions || !reflect.DeepEqual(first, again) {
+		t.Errorf("Expected the same %d-dimension vector for the same text", DefaultMockDimensions)
+	}
+	if similarity := cosine(first, other); math.Abs(similarity) > 0.2 {
+		t.Errorf("Expected different texts to get nearly orthogonal vectors, got similarity %f", similarity)
+	}
+	if similarity := cosine(first, first); math.Abs(similarity-1) > 1e-5 {
+		t.Errorf("Expected a unit vector, got self-similarity %f", similarity)
+	}
+
+	small := NewMockService(WithMockDimensions(16))
+	if vector, _ := small.GetEmbeddings(ctx, "Ada Lovelace", EmbeddingTypeRetrievalDocument); len(vector) != 16 || small.(Dimensioner).Dimensions() != 16 {
+		t.Errorf("Expected 16 dimensions, got %d", len(vector))
+	}
+
+	if _, err := service.GetEmbeddings(ctx, "", EmbeddingTypeRetrievalDocument); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("Expected empty text to fail with ErrInvalidInput, got %v", err)
+	}
+	if _, err := service.GetEmbeddingsBatch(ctx, []string{"a", ""}, EmbeddingTypeRetrievalDocument); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("Expected a batch with empty text to fail with ErrInvalidInput, got %v", err)
+	}
+}
+
+func TestNearDuplicate(t *testing.T) {
+	vector := MockVector("Ada Lovelace", DefaultMockDimensions)
+	for _, similarity := range []float64{0.99, 0.9, 0.5, 0, -0.5} {
+		duplicate := NearDuplicate(vector, similarity, "seed")
+		if got := cosine(vector, duplicate); math.Abs(got-similarity) > 1e-4 {
+			t.Errorf("Expected similarity %f, got %f", similarity, got)
+		}
+	}
+	if a, b := NearDuplicate(vector, 0.9, "a"), NearDuplicate(vector, 0.9, "b"); reflect.DeepEqual(a, b) {
+		t.Errorf("Expected different seeds to give different vectors")
+	}
+}
@@ -0,0 +1,232 @@
+package embedding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/httpclient"
+	"github.com/sandwichlabs/agent-memory-graph/internal/metrics"
+)
+
+// Defaults of OllamaService, overridden by OLLAMA_HOST and OLLAMA_EMBEDDING_MODEL.
+const (
+	DefaultOllamaHost           = "http://localhost:11434"
+	DefaultOllamaEmbeddingModel = "nomic-embed-text"
+)
+
+// ollamaDimensions are the lengths of the vectors of common Ollama embedding
+// models, by name without a tag.
+var ollamaDimensions = map[string]int{
+	"nomic-embed-text":       768,
+	"mxbai-embed-large":      1024,
+	"all-minilm":             384,
+	"snowflake-arctic-embed": 1024,
+	"bge-m3":                 1024,
+}
+
+// Dimensioner is implemented by services whose vectors' length depends on their
+// configuration, so that it can be checked against the memory graph's schema
+// before anything is embedded.
+type Dimensioner interface {
+	// Dimensions returns the length of the service's vectors, or 0 when it is
+	// not known yet.
+	Dimensions() int
+}
+
+// dimensionsEnv names the environment variable requesting each provider's vector
+// length, for providers whose models can shorten their vectors.
+var dimensionsEnv = map[Provider]string{
+	ProviderGemini: "GEMINI_EMBEDDING_DIMENSIONS",
+	ProviderOpenAI: "OPENAI_EMBEDDING_DIMENSIONS",
+	ProviderVoyage: "VOYAGE_EMBEDDING_DIMENSIONS",
+}
+
+// DimensionsOf returns the length of service's vectors when it reports it, and 0
+// otherwise.
+func DimensionsOf(service Service) int {
+	if d, ok := service.(Dimensioner); ok {
+		return d.Dimensions()
+	}
+	return 0
+}
+
+// CheckDimensions fails when service reports vectors of another length than the
+// stored ones, naming the variable that requests stored dimensions where there
+// is one. Services that do not know their length yet pass.
+func CheckDimensions(service Service, stored int) error {
+	dimensions := DimensionsOf(service)
+	if dimensions == 0 || dimensions == stored {
+		return nil
+	}
+	err := fmt.Errorf("%s embeds %s into %d dimensions but the memory graph stores %d", service.GetType(), ModelOf(service), dimensions, stored)
+	if name, ok := dimensionsEnv[service.GetType()]; ok {
+		return fmt.Errorf("%w: set %s=%d, or use a model or provider with %d dimensions, switching to it with amg reindex", err, name, stored, stored)
+	}
+	return fmt.Errorf("%w: use a model or provider with %d dimensions, switching to it with amg reindex", err, stored)
+}
+
+// OllamaService embeds text with the embeddings API of a local Ollama server, so
+// that ingestion works without a hosted API.
+type OllamaService struct {
+	model string
+	// APIBaseURL and HTTPClient are exported for testing.
+	APIBaseURL string
+	HTTPClient *http.Client
+	// Timeout bounds each request; zero lifts the bound. It is
+	// AMG_EMBEDDING_TIMEOUT, or DefaultTimeout, unless set.
+	Timeout time.Duration
+	// Metrics receives a measurement of every request; metrics.Embeddings
+	// unless set.
+	Metrics metrics.Sink
+
+	dimensions atomic.Int64 // of the vectors received, once one is
+}
+
+// NewOllamaService creates an OllamaService for the server at OLLAMA_HOST, or
+// DefaultOllamaHost, embedding with OLLAMA_EMBEDDING_MODEL, or
+// DefaultOllamaEmbeddingModel, and bounding requests by AMG_EMBEDDING_TIMEOUT. It
+// does not contact the server.
+func NewOllamaService() (*OllamaService, error) {
+	host := strings.TrimRight(os.Getenv("OLLAMA_HOST"), "/")
+	if host == "" {
+		host = DefaultOllamaHost
+	}
+	if !strings.Contains(host, "://") {
+		// Ollama itself accepts a bare host:port.
+		host = "http://" + host
+	}
+	model := os.Getenv("OLLAMA_EMBEDDING_MODEL")
+	if model == "" {
+		model = DefaultOllamaEmbeddingModel
+	}
+	client, err := httpclient.FromEnv()
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := TimeoutFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return &OllamaService{model: model, APIBaseURL: host, HTTPClient: client, Timeout: timeout, Metrics: metrics.Embeddings}, nil
+}
+
+// Model returns the model that embeds text.
+func (s *OllamaService) Model() string {
+	return s.model
+}
+
+// Dimensions returns the length of the vectors received so far, or else that of
+// the model when it is a common one, or else 0.
+func (s *OllamaService) Dimensions() int {
+	if n := s.dimensions.Load(); n > 0 {
+		return int(n)
+	}
+	name, _, _ := strings.Cut(s.model, ":")
+	return ollamaDimensions[name]
+}
+
+// GetEmbeddings sends a request to the Ollama server to get embeddings for the
+// given text. A model that is not pulled fails with the server's answer and the
+// command that pulls it.
+func (s *OllamaService) GetEmbeddings(ctx context.Context, text string, embeddingType EmbeddingType) (vector EmbedResponse, err error) {
+	defer func(start time.Time) { recordCall(s.Metrics, ProviderOllama, start, []string{text}, err) }(time.Now())
+	requestBody, err := json.Marshal(map[string]interface{}{
+		"model":  s.model,
+		"prompt": text,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	requestCtx, cancel := requestContext(ctx, s.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(requestCtx, "POST", s.APIBaseURL+"/api/embeddings", bytes.NewBuffer(requestBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err := timeoutError(ctx, requestCtx, ProviderOllama, s.Timeout); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: failed to reach Ollama at %s (is ollama serve running?): %v", ErrUnreachable, s.APIBaseURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		err := statusError("ollama", resp.StatusCode, bodyBytes)
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%w; pull the model with: ollama pull %s", err, s.model)
+		}
+		return nil, err
+	}
+
+	var ollamaResponse struct {
+		Embedding []float32 `json:"embedding"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&ollamaResponse); err != nil {
+		if err := timeoutError(ctx, requestCtx, ProviderOllama, s.Timeout); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(ollamaResponse.Embedding) == 0 {
+		return nil, fmt.Errorf("no embeddings found in response")
+	}
+	s.dimensions.Store(int64(len(ollamaResponse.Embedding)))
+	return ollamaResponse.Embedding, nil
+}
+
+// GetEmbeddingsBatch embeds texts one request at a time, as the embeddings API
+// takes a single prompt.
+func (s *OllamaService) GetEmbeddingsBatch(ctx context.Context, texts []string, embeddingType EmbeddingType) ([]EmbedResponse, error) {
+	return inBatches(texts, 1, func(batch []string) ([]EmbedResponse, error) {
+		vector, err := s.GetEmbeddings(ctx, batch[0], embeddingType)
+		if err != nil {
+			return nil, err
+		}
+		return []EmbedResponse{vector}, nil
+	})
+}
+
+// GetType returns ProviderOllama.
+func (s *OllamaService) GetType() Provider {
+	return ProviderOllama
+}
+
+// Ping lists the models the Ollama server has pulled. Its error wraps
+// ErrUnreachable when the server is not running.
+func (s *OllamaService) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.APIBaseURL+"/api/tags", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("%w: failed to reach Ollama at %s (is ollama serve running?): %v", ErrUnreachable, s.APIBaseURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return statusError("ollama", resp.StatusCode, bodyBytes)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package embedding
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newOllamaTestService(t *testing.T, handler http.HandlerFunc) *OllamaService {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	t.Setenv("OLLAMA_HOST", server.URL)
+	t.Setenv("OLLAMA_EMBEDDING_MODEL", "")
+	s, err := NewOllamaService()
+	if err != nil {
+		t.Fatalf("NewOllamaService failed: %v", err)
+	}
+	return s
+}
+
+func TestOllamaService_GetEmbeddings(t *testing.T) {
+	var prompts []string
+	s := newOllamaTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Model  string `json:"model"`
+			Prompt string `json:"prompt"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		if r.URL.Path != "/api/embeddings" || request.Model != DefaultOllamaEmbeddingModel {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		prompts = append(prompts, request.Prompt)
+		w.Write([]byte(`{"embedding": [0.1, 0.2, 0.3]}`))
+	})
+
+	vectors, err := s.GetEmbeddingsBatch(context.Background(), []string{"first", "second"}, EmbeddingTypeRetrievalDocument)
+	if err != nil {
+		t.Fatalf("GetEmbeddingsBatch failed: %v", err)
+	}
+	if len(vectors) != 2 || len(vectors[1]) != 3 {
+		t.Errorf("Expected 2 vectors of 3 dimensions, got %v", vectors)
+	}
+	if strings.Join(prompts, ",") != "first,second" {
+		t.Errorf("Expected a request per text in order, got %v", prompts)
+	}
+	if s.Dimensions() != 3 {
+		t.Errorf("Expected the dimensions of the vectors received, got %d", s.Dimensions())
+	}
+}
+
+func TestOllamaService_GetEmbeddingsModelNotPulled(t *testing.T) {
+	s := newOllamaTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"model \"nomic-embed-text\" not found, try pulling it first"}`, http.StatusNotFound)
+	})
+
+	_, err := s.GetEmbeddings(context.Background(), "text", EmbeddingTypeRetrievalDocument)
+	if err == nil || !strings.Contains(err.Error(), "try pulling it first") || !strings.Contains(err.Error(), "ollama pull nomic-embed-text") {
+		t.Errorf("Expected the server's answer and a pull hint, got %v", err)
+	}
+}
+
+func TestOllamaService_Unreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	t.Setenv("OLLAMA_HOST", server.URL)
+	s, err := NewOllamaService()
+	if err != nil {
+		t.Fatalf("NewOllamaService failed: %v", err)
+	}
+
+	_, err = s.GetEmbeddings(context.Background(), "text", EmbeddingTypeRetrievalDocument)
+	if !errors.Is(err, ErrUnreachable) || !strings.Contains(err.Error(), "is ollama serve running?") {
+		t.Errorf("Expected an error wrapping ErrUnreachable, got %v", err)
+	}
+	if err := s.Ping(context.Background()); !errors.Is(err, ErrUnreachable) {
+		t.Errorf("Expected Ping to wrap ErrUnreachable, got %v", err)
+	}
+}
+
+func TestNewOllamaService_Config(t *testing.T) {
+	t.Setenv("OLLAMA_HOST", "gpu-box:11434/")
+	t.Setenv("OLLAMA_EMBEDDING_MODEL", "mxbai-embed-large:latest")
+	service, err := New(ProviderOllama)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	s := service.(*OllamaService)
+	if s.APIBaseURL != "http://gpu-box:11434" {
+		t.Errorf("Expected http://gpu-box:11434, got %s", s.APIBaseURL)
+	}
+	if s.Model() != "mxbai-embed-large:latest" || s.Dimensions() != 1024 {
+		t.Errorf("Expected mxbai-embed-large:latest with 1024 dimensions, got %s with %d", s.Model(), s.Dimensions())
+	}
+
+	t.Setenv("OLLAMA_HOST", "")
+	t.Setenv("OLLAMA_EMBEDDING_MODEL", "custom-embedder")
+	s, err = NewOllamaService()
+	if err != nil {
+		t.Fatalf("NewOllamaService failed: %v", err)
+	}
+	if s.APIBaseURL != DefaultOllamaHost || s.Dimensions() != 0 {
+		t.Errorf("Expected %s with unknown dimensions, got %s with %d", DefaultOllamaHost, s.APIBaseURL, s.Dimensions())
+	}
+}
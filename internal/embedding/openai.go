@@ -0,0 +1,226 @@
+package embedding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/httpclient"
+	"github.com/sandwichlabs/agent-memory-graph/internal/metrics"
+	"github.com/sandwichlabs/agent-memory-graph/internal/storage"
+)
+
+// Defaults of OpenAIService, overridden by OPENAI_EMBEDDING_MODEL.
+const (
+	DefaultOpenAIBaseURL        = "https://api.openai.com/v1"
+	DefaultOpenAIEmbeddingModel = "text-embedding-3-small"
+	// DefaultOpenAIMaxBatchSize is the number of texts OpenAIService embeds per
+	// request unless MaxBatchSize is set.
+	DefaultOpenAIMaxBatchSize = 256
+)
+
+// OpenAIService embeds text with the embeddings API of OpenAI, such as with
+// text-embedding-3-small or text-embedding-3-large.
+type OpenAIService struct {
+	apiKey string
+	model  string
+	// Dimensions is the length of the vectors requested, which the
+	// text-embedding-3 models shorten theirs to; the model's own when zero.
+	Dimensions int
+	// MaxBatchSize is the number of texts GetEmbeddingsBatch sends per request;
+	// DefaultOpenAIMaxBatchSize when zero.
+	MaxBatchSize int
+	// APIBaseURL and HTTPClient are exported for testing.
+	APIBaseURL string
+	HTTPClient *http.Client
+	// Timeout bounds each request; zero lifts the bound. It is
+	// AMG_EMBEDDING_TIMEOUT, or DefaultTimeout, unless set.
+	Timeout time.Duration
+	// Metrics receives a measurement of every request; metrics.Embeddings
+	// unless set.
+	Metrics metrics.Sink
+
+	promptTokens atomic.Int64 // billed so far, as the API reports
+}
+
+// NewOpenAIService creates an OpenAIService with the key in OPENAI_API_KEY. It
+// embeds with OPENAI_EMBEDDING_MODEL, or DefaultOpenAIEmbeddingModel, into vectors
+// of OPENAI_EMBEDDING_DIMENSIONS, or the storage.EmbeddingDimensions of the memory
+// graph's schema, bounding requests by AMG_EMBEDDING_TIMEOUT.
+func NewOpenAIService() (*OpenAIService, error) {
+	apiKey, err := requireAPIKey(ProviderOpenAI)
+	if err != nil {
+		return nil, err
+	}
+	dimensions := storage.EmbeddingDimensions
+	if value := os.Getenv("OPENAI_EMBEDDING_DIMENSIONS"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid OPENAI_EMBEDDING_DIMENSIONS %q: must be a number of dimensions, such as %d", value, storage.EmbeddingDimensions)
+		}
+		dimensions = n
+	}
+	client, err := httpclient.FromEnv()
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := TimeoutFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	model := os.Getenv("OPENAI_EMBEDDING_MODEL")
+	if model == "" {
+		model = DefaultOpenAIEmbeddingModel
+	}
+	return &OpenAIService{
+		apiKey:     apiKey,
+		model:      model,
+		Dimensions: dimensions,
+		APIBaseURL: DefaultOpenAIBaseURL,
+		HTTPClient: client,
+		Timeout:    timeout,
+		Metrics:    metrics.Embeddings,
+	}, nil
+}
+
+// Model returns the model that embeds text.
+func (s *OpenAIService) Model() string {
+	return s.model
+}
+
+// GetEmbeddings sends a request to the OpenAI API to get embeddings for the given text.
+func (s *OpenAIService) GetEmbeddings(ctx context.Context, text string, embeddingType EmbeddingType) (EmbedResponse, error) {
+	vectors, err := s.embed(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+// GetEmbeddingsBatch embeds texts with one request to the OpenAI API per
+// s.MaxBatchSize of them.
+func (s *OpenAIService) GetEmbeddingsBatch(ctx context.Context, texts []string, embeddingType EmbeddingType) ([]EmbedResponse, error) {
+	size := s.MaxBatchSize
+	if size <= 0 {
+		size = DefaultOpenAIMaxBatchSize
+	}
+	return inBatches(texts, size, func(batch []string) ([]EmbedResponse, error) {
+		return s.embed(ctx, batch)
+	})
+}
+
+// embed sends texts to the embeddings endpoint in one request and returns their
+// vectors in the same order.
+func (s *OpenAIService) embed(ctx context.Context, texts []string) (vectors []EmbedResponse, err error) {
+	defer func(start time.Time) { recordCall(s.Metrics, ProviderOpenAI, start, texts, err) }(time.Now())
+	payload := map[string]interface{}{
+		"model":           s.model,
+		"input":           texts,
+		"encoding_format": "float",
+	}
+	if s.Dimensions > 0 {
+		payload["dimensions"] = s.Dimensions
+	}
+	requestBody, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	requestCtx, cancel := requestContext(ctx, s.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(requestCtx, "POST", s.APIBaseURL+"/embeddings", bytes.NewBuffer(requestBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err := timeoutError(ctx, requestCtx, ProviderOpenAI, s.Timeout); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, statusError("openai", resp.StatusCode, bodyBytes)
+	}
+
+	// Decode the response; each embedding has the index of its input.
+	var openaiResponse struct {
+		Data []struct {
+			Embedding []float32 `json:"embedding"`
+			Index     int       `json:"index"`
+		} `json:"data"`
+		Usage embeddingsUsage `json:"usage"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&openaiResponse); err != nil {
+		if err := timeoutError(ctx, requestCtx, ProviderOpenAI, s.Timeout); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if len(openaiResponse.Data) == 0 {
+		return nil, fmt.Errorf("no embeddings found in response")
+	}
+	if len(openaiResponse.Data) != len(texts) {
+		return nil, fmt.Errorf("got %d embeddings for %d texts", len(openaiResponse.Data), len(texts))
+	}
+	vectors = make([]EmbedResponse, len(texts))
+	for _, data := range openaiResponse.Data {
+		if data.Index < 0 || data.Index >= len(texts) || vectors[data.Index] != nil {
+			return nil, fmt.Errorf("invalid embedding index %d for %d texts", data.Index, len(texts))
+		}
+		vectors[data.Index] = data.Embedding
+	}
+	s.promptTokens.Add(openaiResponse.Usage.tokens())
+	return vectors, nil
+}
+
+// GetType returns ProviderOpenAI.
+func (s *OpenAIService) GetType() Provider {
+	return ProviderOpenAI
+}
+
+// Usage returns the tokens billed for the requests so far.
+func (s *OpenAIService) Usage() Usage {
+	return Usage{PromptTokens: s.promptTokens.Load()}
+}
+
+// Ping lists the models of the OpenAI API with the service's key. Its error wraps
+// ErrUnauthorized for a bad key and ErrUnreachable when the API cannot be reached.
+func (s *OpenAIService) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.APIBaseURL+"/models", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("%w: failed to reach the OpenAI API at %s: %v", ErrUnreachable, s.APIBaseURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return statusError("openai", resp.StatusCode, bodyBytes)
+	}
+	return nil
+}
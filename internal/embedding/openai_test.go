@@ -0,0 +1,114 @@
+package embedding
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/storage"
+)
+
+func newOpenAITestService(t *testing.T, handler http.HandlerFunc) *OpenAIService {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/embeddings" || r.Header.Get("Authorization") != "Bearer test_api_key" {
+			http.Error(w, "Not found: Unexpected request "+r.URL.Path, http.StatusNotFound)
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("OPENAI_API_KEY", "test_api_key")
+	t.Setenv("OPENAI_EMBEDDING_MODEL", "")
+	t.Setenv("OPENAI_EMBEDDING_DIMENSIONS", "")
+	s, err := NewOpenAIService()
+	if err != nil {
+		t.Fatalf("NewOpenAIService failed: %v", err)
+	}
+	s.APIBaseURL = server.URL
+	s.HTTPClient = server.Client()
+	return s
+}
+
+func TestOpenAIService_GetEmbeddings(t *testing.T) {
+	var request struct {
+		Model      string   `json:"model"`
+		Input      []string `json:"input"`
+		Dimensions int      `json:"dimensions"`
+	}
+	s := newOpenAITestService(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&request)
+		w.Write([]byte(`{"object": "list", "data": [
+			{"object": "embedding", "index": 1, "embedding": [0.3, 0.4]},
+			{"object": "embedding", "index": 0, "embedding": [0.1, 0.2]}
+		], "model": "text-embedding-3-small", "usage": {"prompt_tokens": 3, "total_tokens": 3}}`))
+	})
+
+	vectors, err := s.GetEmbeddingsBatch(context.Background(), []string{"first", "second"}, EmbeddingTypeRetrievalDocument)
+	if err != nil {
+		t.Fatalf("GetEmbeddingsBatch failed: %v", err)
+	}
+	if expected := []EmbedResponse{{0.1, 0.2}, {0.3, 0.4}}; !reflect.DeepEqual(vectors, expected) {
+		t.Errorf("Expected the vectors in input order %v, got %v", expected, vectors)
+	}
+	if request.Model != DefaultOpenAIEmbeddingModel || request.Dimensions != storage.EmbeddingDimensions || !reflect.DeepEqual(request.Input, []string{"first", "second"}) {
+		t.Errorf("Expected %s with %d dimensions, got %+v", DefaultOpenAIEmbeddingModel, storage.EmbeddingDimensions, request)
+	}
+	if usage := s.Usage(); usage != (Usage{PromptTokens: 3}) {
+		t.Errorf("Expected the 3 prompt tokens reported, got %+v", usage)
+	}
+}
+
+func TestOpenAIService_GetEmbeddingsAPIError(t *testing.T) {
+	status := http.StatusUnauthorized
+	s := newOpenAITestService(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": {"message": "Incorrect API key provided"}}`, status)
+	})
+
+	_, err := s.GetEmbeddings(context.Background(), "text", EmbeddingTypeRetrievalDocument)
+	if !errors.Is(err, ErrUnauthorized) || !strings.Contains(err.Error(), "openai API error: unauthorized: 401") {
+		t.Errorf("Expected an error wrapping ErrUnauthorized, got %v", err)
+	}
+	status = http.StatusInternalServerError
+	if _, err := s.GetEmbeddings(context.Background(), "text", EmbeddingTypeRetrievalDocument); err == nil || !strings.Contains(err.Error(), "500 Internal Server Error") {
+		t.Errorf("Expected the status in the error, got %v", err)
+	}
+}
+
+func TestOpenAIService_GetEmbeddingsEmptyData(t *testing.T) {
+	s := newOpenAITestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"object": "list", "data": []}`))
+	})
+
+	if _, err := s.GetEmbeddings(context.Background(), "text", EmbeddingTypeRetrievalDocument); err == nil || !strings.Contains(err.Error(), "no embeddings found") {
+		t.Errorf("Expected an error for a response without embeddings, got %v", err)
+	}
+}
+
+func TestNewOpenAIService_Config(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "")
+	if _, err := New(ProviderOpenAI); err == nil || !strings.Contains(err.Error(), "OPENAI_API_KEY") {
+		t.Errorf("Expected a missing key to fail, got %v", err)
+	}
+
+	t.Setenv("OPENAI_API_KEY", "test_api_key")
+	t.Setenv("OPENAI_EMBEDDING_MODEL", "text-embedding-3-large")
+	t.Setenv("OPENAI_EMBEDDING_DIMENSIONS", "1024")
+	s, err := NewOpenAIService()
+	if err != nil {
+		t.Fatalf("NewOpenAIService failed: %v", err)
+	}
+	if s.Model() != "text-embedding-3-large" || s.Dimensions != 1024 {
+		t.Errorf("Expected text-embedding-3-large with 1024 dimensions, got %s with %d", s.Model(), s.Dimensions)
+	}
+
+	t.Setenv("OPENAI_EMBEDDING_DIMENSIONS", "many")
+	if _, err := NewOpenAIService(); err == nil || !strings.Contains(err.Error(), "invalid OPENAI_EMBEDDING_DIMENSIONS") {
+		t.Errorf("Expected invalid dimensions to fail, got %v", err)
+	}
+}
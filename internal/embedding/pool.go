@@ -0,0 +1,74 @@
+package embedding
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/llm"
+)
+
+// DefaultConcurrency is how many embedding requests are in flight at once when
+// the caller does not say.
+const DefaultConcurrency = 4
+
+// EmbedAllError is the error of EmbedAll when texts failed to embed. It wraps the
+// error of each, so that errors.Is finds ErrUnauthorized and the like.
+type EmbedAllError struct {
+	// Errs holds the error of each text, nil for those embedded.
+	Errs []error
+}
+
+func (e *EmbedAllError) Error() string {
+	failed, first := 0, -1
+	for n, err := range e.Errs {
+		if err != nil {
+			failed++
+			if first < 0 {
+				first = n
+			}
+		}
+	}
+	return fmt.Sprintf("%d of %d texts failed to embed, the first, text %d: %v", failed, len(e.Errs), first, e.Errs[first])
+}
+
+func (e *EmbedAllError) Unwrap() []error {
+	var errs []error
+	for _, err := range e.Errs {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// EmbedAll embeds texts as documents with service, one request per text and at
+// most workers requests at once, or DefaultConcurrency when it is zero. The
+// vectors are in the order of texts. A text that fails does not stop the others:
+// the error is then an *EmbedAllError, and the vectors of the texts that failed
+// are nil. Requests still wait on the service's rate limiter, if it has one, so
+// that more workers do not send faster than it allows. The error is ctx's when
+// it ends before every text is sent.
+func EmbedAll(ctx context.Context, service Service, texts []string, workers int) ([]EmbedResponse, error) {
+	if workers == 0 {
+		workers = DefaultConcurrency
+	}
+	vectors := make([]EmbedResponse, len(texts))
+	errs := make([]error, len(texts))
+	failed := false
+	err := llm.RunBatch(ctx, len(texts), workers, func(ctx context.Context, n int) {
+		if errs[n] = ctx.Err(); errs[n] != nil {
+			return
+		}
+		vectors[n], errs[n] = service.GetEmbeddings(ctx, texts[n], EmbeddingTypeRetrievalDocument)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, err := range errs {
+		failed = failed || err != nil
+	}
+	if failed {
+		return vectors, &EmbedAllError{Errs: errs}
+	}
+	return vectors, nil
+}
@@ -0,0 +1,89 @@
+package embedding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/ratelimit"
+)
+
+func TestEmbedAll_ConcurrentAndOrdered(t *testing.T) {
+	const latency = 50 * time.Millisecond
+	service := NewMockService(WithMockLatency(latency))
+	texts := make([]string, 8)
+	for n := range texts {
+		texts[n] = fmt.Sprintf("text %d", n)
+	}
+
+	start := time.Now()
+	vectors, err := EmbedAll(context.Background(), service, texts, 4)
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatalf("EmbedAll failed: %v", err)
+	}
+	if elapsed >= time.Duration(len(texts))*latency/2 {
+		t.Errorf("Expected 8 requests of %v with 4 workers to take about %v, took %v", latency, 2*latency, elapsed)
+	}
+	for n, text := range texts {
+		if !reflect.DeepEqual(vectors[n], MockVector(text, DefaultMockDimensions)) {
+			t.Errorf("Expected vector %d to be that of %q", n, text)
+		}
+	}
+}
+
+func TestEmbedAll_AggregatesErrors(t *testing.T) {
+	texts := []string{"first", "", "third", ""}
+	vectors, err := EmbedAll(context.Background(), NewMockService(), texts, 2)
+	var failed *EmbedAllError
+	if !errors.As(err, &failed) {
+		t.Fatalf("Expected an *EmbedAllError, got %v", err)
+	}
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("Expected the error to wrap ErrInvalidInput, got %v", err)
+	}
+	if failed.Errs[0] != nil || failed.Errs[1] == nil || failed.Errs[2] != nil || failed.Errs[3] == nil {
+		t.Errorf("Expected texts 1 and 3 to fail, got %v", failed.Errs)
+	}
+	if err.Error() != "2 of 4 texts failed to embed, the first, text 1: invalid input: empty text" {
+		t.Errorf("Unexpected error message: %v", err)
+	}
+	if vectors[0] == nil || vectors[1] != nil || vectors[2] == nil {
+		t.Errorf("Expected the vectors of the texts that did not fail, and only those")
+	}
+}
+
+func TestEmbedAll_WaitsOnTheRateLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data": [{"embedding": [1, 0], "index": 0}]}`)
+	}))
+	defer server.Close()
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	service, err := newMistralService(WithHTTPClient(server.Client()), WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	service.Limiter = ratelimit.New(20, 1)
+
+	start := time.Now()
+	if _, err := EmbedAll(context.Background(), service, []string{"a", "b", "c", "d", "e"}, 5); err != nil {
+		t.Fatalf("EmbedAll failed: %v", err)
+	}
+	// The first request goes at once and each of the other four 50ms apart.
+	if elapsed := time.Since(start); elapsed < 190*time.Millisecond {
+		t.Errorf("Expected 5 workers to wait on 20 requests per second, took %v", elapsed)
+	}
+}
+
+func TestEmbedAll_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := EmbedAll(ctx, NewMockService(), []string{"a", "b"}, 2); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
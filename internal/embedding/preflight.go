@@ -0,0 +1,74 @@
+package embedding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// PreflightTimeout bounds the ping of Preflight.
+const PreflightTimeout = 10 * time.Second
+
+// apiKeyEnv names the environment variable holding each provider's API key.
+var apiKeyEnv = map[Provider]string{
+	ProviderGemini:  "GEMINI_API_KEY",
+	ProviderMistral: "MISTRAL_API_KEY",
+	ProviderOpenAI:  "OPENAI_API_KEY",
+	ProviderVoyage:  "VOYAGE_API_KEY",
+}
+
+// APIKeyEnv returns the environment variable holding the API key provider needs,
+// or "" when it needs none.
+func APIKeyEnv(provider Provider) string {
+	return apiKeyEnv[provider]
+}
+
+// MissingAPIKey returns the environment variable holding provider's API key when
+// the provider needs one and it is not set, or "".
+func MissingAPIKey(provider Provider) string {
+	if name := APIKeyEnv(provider); name != "" && os.Getenv(name) == "" {
+		return name
+	}
+	return ""
+}
+
+// requireAPIKey returns the API key of provider, failing with what to do about it when
+// it is not set or is malformed, such as by a line break pasted with it, which
+// cannot be sent in a header.
+func requireAPIKey(provider Provider) (string, error) {
+	name := apiKeyEnv[provider]
+	key := os.Getenv(name)
+	if key == "" {
+		return "", fmt.Errorf("%s is not set: set %s or choose a different provider with --embedding-provider", name, name)
+	}
+	if strings.IndexFunc(key, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) >= 0 {
+		return "", fmt.Errorf("%s is malformed: it contains whitespace or control characters", name)
+	}
+	return key, nil
+}
+
+// Preflight pings service of provider, giving up after PreflightTimeout, so that
+// a bad key or an unreachable API is reported before any work is done rather than
+// on the first embedding. Its error says what is wrong and wraps that of Ping.
+func Preflight(ctx context.Context, provider Provider, service Service) error {
+	ctx, cancel := context.WithTimeout(ctx, PreflightTimeout)
+	defer cancel()
+	err := service.Ping(ctx)
+	if err == nil {
+		return nil
+	}
+	var diagnosis string
+	switch {
+	case errors.Is(err, ErrUnauthorized):
+		diagnosis = fmt.Sprintf("%s rejected the API key; check %s", provider, apiKeyEnv[provider])
+	case errors.Is(err, ErrUnreachable), errors.Is(err, context.DeadlineExceeded):
+		diagnosis = fmt.Sprintf("%s could not be reached; check the network and HTTPS_PROXY", provider)
+	default:
+		diagnosis = fmt.Sprintf("%s did not answer as expected", provider)
+	}
+	return fmt.Errorf("embedding preflight failed: %s: %w", diagnosis, err)
+}
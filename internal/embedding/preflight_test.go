@@ -0,0 +1,54 @@
+package embedding
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNew_MissingCredentials(t *testing.T) {
+	for provider, name := range apiKeyEnv {
+		t.Run(string(provider), func(t *testing.T) {
+			t.Setenv(name, "")
+			service, err := New(provider)
+			if err == nil {
+				t.Fatalf("Expected a missing %s to fail, got %T", name, service)
+			}
+			if expected := "set " + name + " or choose a different provider with --embedding-provider"; !strings.Contains(err.Error(), expected) {
+				t.Errorf("Expected %q in the error, got %q", expected, err)
+			}
+		})
+	}
+}
+
+func TestNew_MalformedGeminiKey(t *testing.T) {
+	t.Setenv("GEMINI_API_KEY", "AIza-example\n")
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("Expected an error for a malformed key, got a panic: %v", r)
+		}
+	}()
+	if _, err := New(ProviderGemini); err == nil || !strings.Contains(err.Error(), "GEMINI_API_KEY is malformed") {
+		t.Errorf("Expected a malformed GEMINI_API_KEY to fail, got %v", err)
+	}
+
+	t.Setenv("GEMINI_API_KEY", "AIza-example")
+	if _, err := New(ProviderGemini); err != nil {
+		t.Errorf("Expected a well-formed key to be accepted, got %v", err)
+	}
+}
+
+func TestNew_GetType(t *testing.T) {
+	for _, name := range apiKeyEnv {
+		t.Setenv(name, "test_api_key")
+	}
+	t.Setenv(EnvLocalModel, writeLocalModel(t, "F32"))
+	for _, provider := range Providers() {
+		service, err := New(provider)
+		if err != nil {
+			t.Fatalf("Failed to create %s service: %v", provider, err)
+		}
+		if service.GetType() != provider {
+			t.Errorf("Expected %s, got %s", provider, service.GetType())
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package embedding
+
+import "math"
+
+// Precision is how the memory graph stores the vectors of chunks.
+type Precision string
+
+const (
+	// PrecisionFloat32 stores vectors as they are embedded.
+	PrecisionFloat32 Precision = "float32"
+	// PrecisionInt8 stores vectors quantized by QuantizeInt8, in a quarter of
+	// the space, at the cost of a small error in every value.
+	PrecisionInt8 Precision = "int8"
+)
+
+// Precisions lists the precisions vectors can be stored in.
+func Precisions() []Precision {
+	return []Precision{PrecisionFloat32, PrecisionInt8}
+}
+
+// Int8Vector is a vector quantized to int8: value n stands for
+// Values[n]*Scale + Offset.
+type Int8Vector struct {
+	Values []int8
+	Scale  float32
+	Offset float32
+}
+
+// QuantizeInt8 maps the range of vector's values linearly onto the 256 values of
+// an int8, so that Dequantize restores each within half of Scale.
+func QuantizeInt8(vector EmbedResponse) Int8Vector {
+	if len(vector) == 0 {
+		return Int8Vector{}
+	}
+	lo, hi := vector[0], vector[0]
+	for _, x := range vector[1:] {
+		lo, hi = min(lo, x), max(hi, x)
+	}
+	q := Int8Vector{Values: make([]int8, len(vector)), Offset: lo}
+	if hi == lo {
+		// Every value is Offset.
+		return q
+	}
+	q.Scale = (hi - lo) / 255
+	q.Offset = lo + 128*q.Scale
+	for n, x := range vector {
+		v := math.Round(float64((x - q.Offset) / q.Scale))
+		q.Values[n] = int8(max(math.MinInt8, min(math.MaxInt8, v)))
+	}
+	return q
+}
+
+// Dequantize returns the vector q stands for.
+func (q Int8Vector) Dequantize() EmbedResponse {
+	vector := make(EmbedResponse, len(q.Values))
+	for n, v := range q.Values {
+		vector[n] = float32(v)*q.Scale + q.Offset
+	}
+	return vector
+}
@@ -0,0 +1,45 @@
+package embedding
+
+import (
+	"math"
+	"testing"
+)
+
+func TestQuantizeInt8_RoundTrips(t *testing.T) {
+	for _, text := range []string{"kuzu", "an embedded graph database", "int8"} {
+		vector := MockVector(text, DefaultMockDimensions)
+		q := QuantizeInt8(vector)
+		if len(q.Values) != len(vector) {
+			t.Fatalf("Expected %d values, got %d", len(vector), len(q.Values))
+		}
+		restored := q.Dequantize()
+		for n := range vector {
+			if diff := math.Abs(float64(restored[n] - vector[n])); diff > float64(q.Scale)/2+1e-6 {
+				t.Fatalf("%q: Expected value %d within %v of %v, got %v", text, n, q.Scale/2, vector[n], restored[n])
+			}
+		}
+		if got := cosine(vector, restored); got < 0.999 {
+			t.Errorf("%q: Expected the restored vector to point the same way, got cosine %v", text, got)
+		}
+	}
+}
+
+func TestQuantizeInt8_KeepsTheRange(t *testing.T) {
+	vector := EmbedResponse{-1, 0.5, 3}
+	q := QuantizeInt8(vector)
+	if q.Values[0] != math.MinInt8 || q.Values[2] != math.MaxInt8 {
+		t.Errorf("Expected the ends of the range at the ends of int8, got %v", q.Values)
+	}
+	restored := q.Dequantize()
+	if math.Abs(float64(restored[0]+1)) > 1e-6 || math.Abs(float64(restored[2]-3)) > 1e-6 {
+		t.Errorf("Expected the ends restored exactly, got %v", restored)
+	}
+
+	constant := QuantizeInt8(EmbedResponse{0.25, 0.25})
+	if got := constant.Dequantize(); got[0] != 0.25 || got[1] != 0.25 {
+		t.Errorf("Expected a constant vector restored exactly, got %v", got)
+	}
+	if got := QuantizeInt8(nil).Dequantize(); len(got) != 0 {
+		t.Errorf("Expected an empty vector, got %v", got)
+	}
+}
@@ -0,0 +1,61 @@
+package embedding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// EnvTimeout names the environment variable bounding each request to an
+// embedding API, as a duration such as 45s or a number of seconds; 0 lifts the
+// bound.
+const EnvTimeout = "AMG_EMBEDDING_TIMEOUT"
+
+// DefaultTimeout bounds each request to an embedding API unless
+// AMG_EMBEDDING_TIMEOUT says otherwise, so that a stalled connection fails the
+// request instead of hanging the ingest.
+const DefaultTimeout = 30 * time.Second
+
+// TimeoutFromEnv returns the bound in AMG_EMBEDDING_TIMEOUT, or DefaultTimeout
+// when it is not set.
+func TimeoutFromEnv() (time.Duration, error) {
+	value := os.Getenv(EnvTimeout)
+	if value == "" {
+		return DefaultTimeout, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		seconds, serr := strconv.ParseFloat(value, 64)
+		if serr != nil {
+			return 0, fmt.Errorf("invalid %s %q: must be a duration such as 45s or a number of seconds", EnvTimeout, value)
+		}
+		timeout = time.Duration(seconds * float64(time.Second))
+	}
+	if timeout < 0 {
+		return 0, fmt.Errorf("invalid %s %q: must not be negative", EnvTimeout, value)
+	}
+	return timeout, nil
+}
+
+// requestContext returns ctx bounded by timeout for one request, or ctx itself
+// when timeout is zero.
+func requestContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// timeoutError returns the error of a request to provider that ran past timeout,
+// wrapping context.DeadlineExceeded, when requestCtx, made from ctx by
+// requestContext, ended on its own deadline rather than with ctx; and nil
+// otherwise.
+func timeoutError(ctx, requestCtx context.Context, provider Provider, timeout time.Duration) error {
+	if ctx.Err() != nil || !errors.Is(requestCtx.Err(), context.DeadlineExceeded) {
+		return nil
+	}
+	return fmt.Errorf("%s embedding request timed out after %s (set %s to wait longer): %w", provider, timeout, EnvTimeout, context.DeadlineExceeded)
+}
@@ -0,0 +1,109 @@
+package embedding
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/retry"
+)
+
+// newStalledServer returns a server whose handlers hang until the request is
+// abandoned, counting the requests.
+func newStalledServer(t *testing.T) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	var requests atomic.Int64
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+	return server, &requests
+}
+
+func TestMistralService_Timeout(t *testing.T) {
+	server, requests := newStalledServer(t)
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	s, err := newMistralService(WithHTTPClient(server.Client()), WithBaseURL(server.URL), WithTimeout(20*time.Millisecond),
+		WithRetry(retry.Policy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}))
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+
+	_, err = s.GetEmbeddings(context.Background(), "text", EmbeddingTypeRetrievalDocument)
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "timed out after 20ms") {
+		t.Errorf("Expected the request to time out, got %v", err)
+	}
+	if requests.Load() != 2 {
+		t.Errorf("Expected a timed out attempt to be retried, got %d requests", requests.Load())
+	}
+}
+
+func TestMistralService_CancelledMidRequest(t *testing.T) {
+	server, _ := newStalledServer(t)
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	s, err := newMistralService(WithHTTPClient(server.Client()), WithBaseURL(server.URL), WithTimeout(time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	start := time.Now()
+	_, err = s.GetEmbeddings(ctx, "text", EmbeddingTypeRetrievalDocument)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the cancellation to end the request, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the request to end promptly, took %v", elapsed)
+	}
+}
+
+func TestOpenAIService_Timeout(t *testing.T) {
+	server, _ := newStalledServer(t)
+	t.Setenv("OPENAI_API_KEY", "test_api_key")
+	t.Setenv(EnvTimeout, "0.02")
+	s, err := NewOpenAIService()
+	if err != nil {
+		t.Fatalf("NewOpenAIService failed: %v", err)
+	}
+	s.APIBaseURL = server.URL
+	s.HTTPClient = server.Client()
+
+	_, err = s.GetEmbeddings(context.Background(), "text", EmbeddingTypeRetrievalDocument)
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "openai embedding request timed out after 20ms") {
+		t.Errorf("Expected the request to time out after %s, got %v", EnvTimeout, err)
+	}
+}
+
+func TestTimeoutFromEnv(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", DefaultTimeout, false},
+		{"45s", 45 * time.Second, false},
+		{"90", 90 * time.Second, false},
+		{"0", 0, false},
+		{"-1s", 0, true},
+		{"soon", 0, true},
+	}
+	for _, tt := range tests {
+		t.Setenv(EnvTimeout, tt.value)
+		got, err := TimeoutFromEnv()
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Expected %q to give %v (error %v), got %v (%v)", tt.value, tt.want, tt.wantErr, got, err)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package embedding
+
+// Usage is the tokens an embedding service was billed for, as its provider
+// reports them.
+type Usage struct {
+	PromptTokens int64
+	// Unknown is set when the provider does not report usage, so that
+	// PromptTokens being zero does not mean the embedding was free.
+	Unknown bool
+}
+
+// Sub returns the usage of u since earlier.
+func (u Usage) Sub(earlier Usage) Usage {
+	return Usage{PromptTokens: u.PromptTokens - earlier.PromptTokens, Unknown: u.Unknown}
+}
+
+// UsageReporter is a Service adding up the usage its provider reports.
+type UsageReporter interface {
+	// Usage returns the usage of every request so far.
+	Usage() Usage
+}
+
+// UsageOf returns the usage of service so far when it reports it, and an Unknown
+// usage otherwise.
+func UsageOf(service Service) Usage {
+	if reporter, ok := service.(UsageReporter); ok {
+		return reporter.Usage()
+	}
+	return Usage{Unknown: true}
+}
+
+// embeddingsUsage is the usage object of the Mistral, OpenAI and Voyage AI
+// embeddings responses; Voyage AI reports only the total.
+type embeddingsUsage struct {
+	PromptTokens int64 `json:"prompt_tokens"`
+	TotalTokens  int64 `json:"total_tokens"`
+}
+
+// tokens returns the tokens billed, the prompt's where reported.
+func (u embeddingsUsage) tokens() int64 {
+	if u.PromptTokens > 0 {
+		return u.PromptTokens
+	}
+	return u.TotalTokens
+}
@@ -0,0 +1,230 @@
+package embedding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/httpclient"
+	"github.com/sandwichlabs/agent-memory-graph/internal/metrics"
+)
+
+// Defaults of VoyageService, overridden by VOYAGE_EMBEDDING_MODEL.
+const (
+	DefaultVoyageBaseURL        = "https://api.voyageai.com/v1"
+	DefaultVoyageEmbeddingModel = "voyage-3.5"
+	// DefaultVoyageMaxBatchSize is the number of texts VoyageService embeds per
+	// request unless MaxBatchSize is set.
+	DefaultVoyageMaxBatchSize = 128
+	// defaultVoyageDimensions is the length of the vectors of the voyage-3 models
+	// when no output dimension is requested.
+	defaultVoyageDimensions = 1024
+)
+
+// voyageInputTypes are Voyage's input_type for each EmbeddingType; Voyage prepends
+// a retrieval prompt of its own to each kind of input.
+var voyageInputTypes = map[EmbeddingType]string{
+	EmbeddingTypeRetrievalDocument: "document",
+	EmbeddingTypeRetrievalQuery:    "query",
+}
+
+// VoyageService embeds text with the embeddings API of Voyage AI, embedding
+// documents and queries as such.
+type VoyageService struct {
+	apiKey string
+	model  string
+	// OutputDimension is the length of the vectors requested, one of those the
+	// model supports such as 512 or 2048; the model's own when zero.
+	OutputDimension int
+	// MaxBatchSize is the number of texts GetEmbeddingsBatch sends per request;
+	// DefaultVoyageMaxBatchSize when zero.
+	MaxBatchSize int
+	// APIBaseURL and HTTPClient are exported for testing.
+	APIBaseURL string
+	HTTPClient *http.Client
+	// Timeout bounds each request; zero lifts the bound. It is
+	// AMG_EMBEDDING_TIMEOUT, or DefaultTimeout, unless set.
+	Timeout time.Duration
+	// Metrics receives a measurement of every request; metrics.Embeddings
+	// unless set.
+	Metrics metrics.Sink
+
+	promptTokens atomic.Int64 // billed so far, as the API reports
+}
+
+// NewVoyageService creates a VoyageService with the key in VOYAGE_API_KEY. It
+// embeds with VOYAGE_EMBEDDING_MODEL, or DefaultVoyageEmbeddingModel, into vectors
+// of VOYAGE_EMBEDDING_DIMENSIONS, or the model's own length, bounding requests by
+// AMG_EMBEDDING_TIMEOUT.
+func NewVoyageService() (*VoyageService, error) {
+	apiKey, err := requireAPIKey(ProviderVoyage)
+	if err != nil {
+		return nil, err
+	}
+	var dimensions int
+	if value := os.Getenv("VOYAGE_EMBEDDING_DIMENSIONS"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid VOYAGE_EMBEDDING_DIMENSIONS %q: must be a number of dimensions, such as %d", value, defaultVoyageDimensions)
+		}
+		dimensions = n
+	}
+	client, err := httpclient.FromEnv()
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := TimeoutFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	model := os.Getenv("VOYAGE_EMBEDDING_MODEL")
+	if model == "" {
+		model = DefaultVoyageEmbeddingModel
+	}
+	return &VoyageService{
+		apiKey:          apiKey,
+		model:           model,
+		OutputDimension: dimensions,
+		APIBaseURL:      DefaultVoyageBaseURL,
+		HTTPClient:      client,
+		Timeout:         timeout,
+		Metrics:         metrics.Embeddings,
+	}, nil
+}
+
+// Model returns the model that embeds text.
+func (s *VoyageService) Model() string {
+	return s.model
+}
+
+// Dimensions returns s.OutputDimension, or the length of the voyage-3 models'
+// vectors when it is not set.
+func (s *VoyageService) Dimensions() int {
+	if s.OutputDimension > 0 {
+		return s.OutputDimension
+	}
+	return defaultVoyageDimensions
+}
+
+// GetEmbeddings sends a request to the Voyage AI API to get embeddings for the given text.
+func (s *VoyageService) GetEmbeddings(ctx context.Context, text string, embeddingType EmbeddingType) (EmbedResponse, error) {
+	vectors, err := s.embed(ctx, []string{text}, embeddingType)
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+// GetEmbeddingsBatch embeds texts with one request to the Voyage AI API per
+// s.MaxBatchSize of them.
+func (s *VoyageService) GetEmbeddingsBatch(ctx context.Context, texts []string, embeddingType EmbeddingType) ([]EmbedResponse, error) {
+	size := s.MaxBatchSize
+	if size <= 0 {
+		size = DefaultVoyageMaxBatchSize
+	}
+	return inBatches(texts, size, func(batch []string) ([]EmbedResponse, error) {
+		return s.embed(ctx, batch, embeddingType)
+	})
+}
+
+// embed sends texts to the embeddings endpoint in one request and returns their
+// vectors in the same order.
+func (s *VoyageService) embed(ctx context.Context, texts []string, embeddingType EmbeddingType) (vectors []EmbedResponse, err error) {
+	defer func(start time.Time) { recordCall(s.Metrics, ProviderVoyage, start, texts, err) }(time.Now())
+	payload := map[string]interface{}{
+		"model": s.model,
+		"input": texts,
+	}
+	if inputType, ok := voyageInputTypes[embeddingType]; ok {
+		payload["input_type"] = inputType
+	}
+	if s.OutputDimension > 0 {
+		payload["output_dimension"] = s.OutputDimension
+	}
+	requestBody, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	requestCtx, cancel := requestContext(ctx, s.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(requestCtx, "POST", s.APIBaseURL+"/embeddings", bytes.NewBuffer(requestBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err := timeoutError(ctx, requestCtx, ProviderVoyage, s.Timeout); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: failed to reach the Voyage AI API at %s: %v", ErrUnreachable, s.APIBaseURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, statusError("voyage", resp.StatusCode, bodyBytes)
+	}
+
+	// Decode the response; each embedding has the index of its input.
+	var voyageResponse struct {
+		Data []struct {
+			Embedding []float32 `json:"embedding"`
+			Index     int       `json:"index"`
+		} `json:"data"`
+		Usage embeddingsUsage `json:"usage"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&voyageResponse); err != nil {
+		if err := timeoutError(ctx, requestCtx, ProviderVoyage, s.Timeout); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if len(voyageResponse.Data) == 0 {
+		return nil, fmt.Errorf("no embeddings found in response")
+	}
+	if len(voyageResponse.Data) != len(texts) {
+		return nil, fmt.Errorf("got %d embeddings for %d texts", len(voyageResponse.Data), len(texts))
+	}
+	vectors = make([]EmbedResponse, len(texts))
+	for _, data := range voyageResponse.Data {
+		if data.Index < 0 || data.Index >= len(texts) || vectors[data.Index] != nil {
+			return nil, fmt.Errorf("invalid embedding index %d for %d texts", data.Index, len(texts))
+		}
+		vectors[data.Index] = data.Embedding
+	}
+	s.promptTokens.Add(voyageResponse.Usage.tokens())
+	return vectors, nil
+}
+
+// GetType returns ProviderVoyage.
+func (s *VoyageService) GetType() Provider {
+	return ProviderVoyage
+}
+
+// Usage returns the tokens billed for the requests so far.
+func (s *VoyageService) Usage() Usage {
+	return Usage{PromptTokens: s.promptTokens.Load()}
+}
+
+// Ping embeds a word with the service's key, as the Voyage AI API has no listing
+// endpoint. Its error wraps ErrUnauthorized for a bad key and ErrUnreachable when
+// the API cannot be reached.
+func (s *VoyageService) Ping(ctx context.Context) error {
+	_, err := s.embed(ctx, []string{"ping"}, EmbeddingTypeRetrievalQuery)
+	return err
+}
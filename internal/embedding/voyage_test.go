@@ -0,0 +1,119 @@
+package embedding
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type voyageRequest struct {
+	Model           string   `json:"model"`
+	Input           []string `json:"input"`
+	InputType       string   `json:"input_type"`
+	OutputDimension int      `json:"output_dimension"`
+}
+
+func newVoyageTestService(t *testing.T, handler http.HandlerFunc) *VoyageService {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/embeddings" || r.Header.Get("Authorization") != "Bearer test_api_key" {
+			http.Error(w, "Not found: Unexpected request "+r.URL.Path, http.StatusNotFound)
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("VOYAGE_API_KEY", "test_api_key")
+	t.Setenv("VOYAGE_EMBEDDING_MODEL", "")
+	t.Setenv("VOYAGE_EMBEDDING_DIMENSIONS", "")
+	s, err := NewVoyageService()
+	if err != nil {
+		t.Fatalf("NewVoyageService failed: %v", err)
+	}
+	s.APIBaseURL = server.URL
+	s.HTTPClient = server.Client()
+	return s
+}
+
+func TestVoyageService_GetEmbeddingsInputType(t *testing.T) {
+	var requests []voyageRequest
+	s := newVoyageTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		var request voyageRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		requests = append(requests, request)
+		if len(request.Input) == 1 {
+			w.Write([]byte(`{"object": "list", "data": [{"object": "embedding", "index": 0, "embedding": [0.5, 0.6]}]}`))
+			return
+		}
+		w.Write([]byte(`{"object": "list", "data": [
+			{"object": "embedding", "index": 1, "embedding": [0.3, 0.4]},
+			{"object": "embedding", "index": 0, "embedding": [0.1, 0.2]}
+		], "model": "voyage-3.5", "usage": {"total_tokens": 4}}`))
+	})
+
+	vectors, err := s.GetEmbeddingsBatch(context.Background(), []string{"first", "second"}, EmbeddingTypeRetrievalDocument)
+	if err != nil {
+		t.Fatalf("GetEmbeddingsBatch failed: %v", err)
+	}
+	if expected := []EmbedResponse{{0.1, 0.2}, {0.3, 0.4}}; !reflect.DeepEqual(vectors, expected) {
+		t.Errorf("Expected the vectors in input order %v, got %v", expected, vectors)
+	}
+	if _, err := s.GetEmbeddings(context.Background(), "question", EmbeddingTypeRetrievalQuery); err != nil {
+		t.Fatalf("GetEmbeddings failed: %v", err)
+	}
+
+	if len(requests) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(requests))
+	}
+	if requests[0].Model != DefaultVoyageEmbeddingModel || requests[0].InputType != "document" || !reflect.DeepEqual(requests[0].Input, []string{"first", "second"}) {
+		t.Errorf("Expected documents embedded with %s, got %+v", DefaultVoyageEmbeddingModel, requests[0])
+	}
+	if requests[1].InputType != "query" || requests[1].OutputDimension != 0 {
+		t.Errorf("Expected a query without an output dimension, got %+v", requests[1])
+	}
+	if usage := s.Usage(); usage != (Usage{PromptTokens: 4}) {
+		t.Errorf("Expected the 4 tokens reported, got %+v", usage)
+	}
+}
+
+func TestVoyageService_GetEmbeddingsAPIError(t *testing.T) {
+	s := newVoyageTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"detail": "Provided API key is invalid."}`, http.StatusUnauthorized)
+	})
+
+	_, err := s.GetEmbeddings(context.Background(), "text", EmbeddingTypeRetrievalDocument)
+	if !errors.Is(err, ErrUnauthorized) || !strings.Contains(err.Error(), "voyage API error: unauthorized: 401") {
+		t.Errorf("Expected an error wrapping ErrUnauthorized, got %v", err)
+	}
+	if err := s.Ping(context.Background()); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected Ping to wrap ErrUnauthorized, got %v", err)
+	}
+}
+
+func TestNewVoyageService_Config(t *testing.T) {
+	t.Setenv("VOYAGE_API_KEY", "")
+	if _, err := New(ProviderVoyage); err == nil || !strings.Contains(err.Error(), "VOYAGE_API_KEY") {
+		t.Errorf("Expected a missing key to fail, got %v", err)
+	}
+
+	t.Setenv("VOYAGE_API_KEY", "test_api_key")
+	t.Setenv("VOYAGE_EMBEDDING_MODEL", "voyage-3-large")
+	t.Setenv("VOYAGE_EMBEDDING_DIMENSIONS", "2048")
+	s, err := NewVoyageService()
+	if err != nil {
+		t.Fatalf("NewVoyageService failed: %v", err)
+	}
+	if s.Model() != "voyage-3-large" || s.Dimensions() != 2048 {
+		t.Errorf("Expected voyage-3-large with 2048 dimensions, got %s with %d", s.Model(), s.Dimensions())
+	}
+
+	t.Setenv("VOYAGE_EMBEDDING_DIMENSIONS", "many")
+	if _, err := NewVoyageService(); err == nil || !strings.Contains(err.Error(), "invalid VOYAGE_EMBEDDING_DIMENSIONS") {
+		t.Errorf("Expected invalid dimensions to fail, got %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+// Package eval measures retrieval quality against a set of queries with known
+// answers, so changes to chunking or providers can be compared.
+package eval
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/retrieval"
+	"gopkg.in/yaml.v3"
+)
+
+// Case is a query and substrings identifying the chunks that should answer it.
+// Each expected string matches a hit whose source or content contains it,
+// ignoring case.
+type Case struct {
+	Query  string   `yaml:"query"`
+	Expect []string `yaml:"expect"`
+}
+
+// QueryResult is the outcome of one case.
+type QueryResult struct {
+	Query string
+	// Found and Missed partition the case's expected strings by whether a hit
+	// within the top K matched them.
+	Found  []string
+	Missed []string
+	// Recall is the fraction of expected strings found.
+	Recall float64
+	// ReciprocalRank is 1/rank of the first hit matching any expected string, or 0.
+	ReciprocalRank float64
+	// Hits are the chunk IDs returned, best first.
+	Hits []string
+}
+
+// Report summarizes a run over every case.
+type Report struct {
+	K int
+	// Recall is the mean recall@K and MRR the mean reciprocal rank over the cases.
+	Recall  float64
+	MRR     float64
+	Queries []QueryResult
+	// KeywordOnly reports that the retriever had no embeddings and ranked by
+	// keyword search alone.
+	KeywordOnly bool
+}
+
+// LoadCases reads cases from a YAML or JSON file holding a list of cases.
+func LoadCases(path string) ([]Case, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cases []Case
+	if err := yaml.Unmarshal(data, &cases); err != nil {
+		return nil, fmt.Errorf("invalid cases in %s: %w", path, err)
+	}
+	if len(cases) == 0 {
+		return nil, fmt.Errorf("no cases in %s", path)
+	}
+	for i, c := range cases {
+		if strings.TrimSpace(c.Query) == "" || len(c.Expect) == 0 {
+			return nil, fmt.Errorf("case %d in %s needs a query and at least one expected string", i+1, path)
+		}
+	}
+	return cases, nil
+}
+
+// Run searches every case with retriever and scores the hits. opts.K defaults
+// like retrieval.SearchOptions.
+func Run(ctx context.Context, retriever *retrieval.Retriever, cases []Case, opts retrieval.SearchOptions) (Report, error) {
+	if opts.K <= 0 {
+		opts.K = retrieval.DefaultK
+	}
+	report := Report{K: opts.K, Queries: make([]QueryResult, 0, len(cases)), KeywordOnly: retriever.KeywordOnly()}
+	for _, c := range cases {
+		hits, err := retriever.Search(ctx, c.Query, opts)
+		if err != nil {
+			return Report{}, fmt.Errorf("failed to search %q: %w", c.Query, err)
+		}
+		// Graph expansion may return more than K hits.
+		if len(hits) > opts.K {
+			hits = hits[:opts.K]
+		}
+		result := score(c, hits)
+		report.Recall += result.Recall
+		report.MRR += result.ReciprocalRank
+		report.Queries = append(report.Queries, result)
+	}
+	if len(cases) > 0 {
+		report.Recall /= float64(len(cases))
+		report.MRR /= float64(len(cases))
+	}
+	return report, nil
+}
+
+func score(c Case, hits []retrieval.Hit) QueryResult {
+	result := QueryResult{Query: c.Query, Hits: make([]string, 0, len(hits))}
+	for _, hit := range hits {
+		result.Hits = append(result.Hits, hit.ChunkID)
+	}
+	for _, expected := range c.Expect {
+		found := false
+		for rank, hit := range hits {
+			if matches(hit, expected) {
+				found = true
+				result.ReciprocalRank = max(result.ReciprocalRank, 1/float64(rank+1))
+				break
+			}
+		}
+		if found {
+			result.Found = append(result.Found, expected)
+		} else {
+			result.Missed = append(result.Missed, expected)
+		}
+	}
+	result.Recall = float64(len(result.Found)) / float64(len(c.Expect))
+	return result
+}
+
+func matches(hit retrieval.Hit, expected string) bool {
+	expected = strings.ToLower(expected)
+	return strings.Contains(strings.ToLower(hit.Source), expected) || strings.Contains(strings.ToLower(hit.Content), expected)
+}
@@ -0,0 +1,60 @@
+package eval
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/retrieval"
+)
+
+func TestLoadCases(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		return path
+	}
+
+	cases, err := LoadCases(write("cases.json", `[{"query": "q", "expect": ["a.md", "b.md"]}]`))
+	if err != nil {
+		t.Fatalf("LoadCases failed: %v", err)
+	}
+	if want := []Case{{Query: "q", Expect: []string{"a.md", "b.md"}}}; !reflect.DeepEqual(cases, want) {
+		t.Errorf("Expected %+v, got %+v", want, cases)
+	}
+
+	for name, content := range map[string]string{
+		"empty.yaml":    "",
+		"noexpect.yaml": "- query: q\n",
+		"noquery.yaml":  "- expect: [a.md]\n",
+		"notalist.yaml": "query: q\n",
+	} {
+		if _, err := LoadCases(write(name, content)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	hits := []retrieval.Hit{
+		{ChunkID: "1", Source: "other.md", Content: "Unrelated."},
+		{ChunkID: "2", Source: "notes/Kuzu.md", Content: "Kuzu Inc builds KuzuDB."},
+		{ChunkID: "3", Source: "notes/cypher.md", Content: "KuzuDB speaks Cypher."},
+	}
+	got := score(Case{Query: "q", Expect: []string{"speaks cypher", "notes/kuzu.md", "missing.md"}}, hits)
+	want := QueryResult{
+		Query:          "q",
+		Found:          []string{"speaks cypher", "notes/kuzu.md"},
+		Missed:         []string{"missing.md"},
+		Recall:         2.0 / 3,
+		ReciprocalRank: 0.5,
+		Hits:           []string{"1", "2", "3"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+}
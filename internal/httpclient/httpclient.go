@@ -0,0 +1,116 @@
+// Package httpclient builds the HTTP clients of the provider services, so that
+// they all honor the same proxy and TLS settings, such as those a corporate
+// network with an intercepting proxy requires.
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Environment variables read by FromEnv. The proxy comes from HTTPS_PROXY,
+// HTTP_PROXY and NO_PROXY, as for other Go programs.
+const (
+	// EnvCABundle is the path of a PEM file of certificates to trust in addition
+	// to the system's, such as the CA of a TLS-intercepting proxy.
+	EnvCABundle = "AMG_CA_BUNDLE"
+	// EnvInsecureSkipVerify, when true, disables verifying server certificates.
+	EnvInsecureSkipVerify = "AMG_INSECURE_SKIP_VERIFY"
+)
+
+// Config configures the transport of a client.
+type Config struct {
+	// Proxy returns the proxy of a request; nil takes it from the environment.
+	Proxy func(*http.Request) (*url.URL, error)
+	// CABundle is the path of a PEM file of certificates to trust in addition to
+	// the system's, or empty.
+	CABundle string
+	// InsecureSkipVerify disables verifying server certificates. It is only for
+	// trying things out against a server with a broken certificate.
+	InsecureSkipVerify bool
+}
+
+// ConfigFromEnv returns the Config set by the environment.
+func ConfigFromEnv() (Config, error) {
+	config := Config{CABundle: os.Getenv(EnvCABundle)}
+	if value := os.Getenv(EnvInsecureSkipVerify); value != "" {
+		skip, err := strconv.ParseBool(value)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid %s %q: must be true or false", EnvInsecureSkipVerify, value)
+		}
+		config.InsecureSkipVerify = skip
+	}
+	return config, nil
+}
+
+// New returns a client whose transport follows config and otherwise behaves like
+// http.DefaultTransport.
+func New(config Config) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if config.Proxy != nil {
+		transport.Proxy = config.Proxy
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if config.CABundle != "" {
+		pool, err := certPool(config.CABundle)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if config.InsecureSkipVerify {
+		slog.Warn("Not verifying server certificates", "setting", EnvInsecureSkipVerify)
+		tlsConfig.InsecureSkipVerify = true
+	}
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport}, nil
+}
+
+// FromEnv returns a client configured by the environment.
+func FromEnv() (*http.Client, error) {
+	config, err := ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return New(config)
+}
+
+// BaseURLFromEnv returns the API base URL in the environment variable name, such
+// as a gateway in front of a provider, without trailing slashes so that paths
+// can be appended. It is empty when name is not set, and an error when the value
+// is not an absolute http or https URL.
+func BaseURLFromEnv(name string) (string, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return "", nil
+	}
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return "", fmt.Errorf("invalid %s %q: must be an absolute http or https URL, such as https://gateway.example.com/v1", name, value)
+	}
+	return strings.TrimRight(value, "/"), nil
+}
+
+// certPool returns the system's certificates and those in the PEM file at path.
+func certPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("CA bundle %s holds no PEM certificates", path)
+	}
+	return pool, nil
+}
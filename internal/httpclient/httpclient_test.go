@@ -0,0 +1,129 @@
+package httpclient
+
+import (
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeCABundle writes the certificate of server to a PEM file and returns its path.
+func writeCABundle(t *testing.T, server *httptest.Server) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("Failed to write CA bundle: %v", err)
+	}
+	return path
+}
+
+func TestNew_TrustsCABundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	client, err := New(Config{})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if _, err := client.Get(server.URL); err == nil {
+		t.Errorf("Expected the test server's certificate to be rejected without the CA bundle")
+	}
+
+	t.Setenv(EnvCABundle, writeCABundle(t, server))
+	client, err = FromEnv()
+	if err != nil {
+		t.Fatalf("FromEnv failed: %v", err)
+	}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected the CA bundle to be trusted, got %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestNew_InsecureSkipVerify(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	t.Setenv(EnvInsecureSkipVerify, "true")
+	client, err := FromEnv()
+	if err != nil {
+		t.Fatalf("FromEnv failed: %v", err)
+	}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected verification to be skipped, got %v", err)
+	}
+	resp.Body.Close()
+
+	t.Setenv(EnvInsecureSkipVerify, "maybe")
+	if _, err := FromEnv(); err == nil || !strings.Contains(err.Error(), EnvInsecureSkipVerify) {
+		t.Errorf("Expected an invalid setting to be rejected, got %v", err)
+	}
+}
+
+func TestNew_Proxy(t *testing.T) {
+	proxied := ""
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		io.WriteString(w, "via proxy")
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	client, err := New(Config{Proxy: http.ProxyURL(proxyURL)})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	resp, err := client.Get("http://api.example.test/v1/models")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	resp.Body.Close()
+	if proxied != "http://api.example.test/v1/models" {
+		t.Errorf("Expected the request to go through the proxy, got %q", proxied)
+	}
+}
+
+func TestNew_InvalidCABundle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(path, []byte("not a certificate"), 0o644)
+	if _, err := New(Config{CABundle: path}); err == nil || !strings.Contains(err.Error(), "no PEM certificates") {
+		t.Errorf("Expected a bundle without certificates to be rejected, got %v", err)
+	}
+	if _, err := New(Config{CABundle: filepath.Join(t.TempDir(), "missing.pem")}); err == nil {
+		t.Errorf("Expected a missing bundle to be rejected")
+	}
+}
+
+func TestBaseURLFromEnv(t *testing.T) {
+	tests := []struct {
+		value, want string
+		valid       bool
+	}{
+		{"", "", true},
+		{"https://gateway.example.com/mistral/v1/", "https://gateway.example.com/mistral/v1", true},
+		{"http://localhost:8080//", "http://localhost:8080", true},
+		{"gateway.example.com/v1", "", false},
+		{"ftp://gateway.example.com", "", false},
+		{"/v1", "", false},
+	}
+	for _, test := range tests {
+		t.Setenv("TEST_API_BASE", test.value)
+		got, err := BaseURLFromEnv("TEST_API_BASE")
+		if test.valid && (err != nil || got != test.want) {
+			t.Errorf("Expected %q to give %q, got %q and %v", test.value, test.want, got, err)
+		}
+		if !test.valid && (err == nil || !strings.Contains(err.Error(), "invalid TEST_API_BASE")) {
+			t.Errorf("Expected %q to be rejected, got %q and %v", test.value, got, err)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/embedding"
+	"github.com/sandwichlabs/agent-memory-graph/internal/llm"
+)
+
+// DefaultEmbeddingBatchSize is the number of chunks embedded per request unless
+// Options.EmbeddingBatchSize is set.
+const DefaultEmbeddingBatchSize = 32
+
+// EmbeddingCacheFile is the file in the memory graph's directory keeping the
+// vectors of embedded chunks, so that re-ingesting a document only embeds the
+// chunks that changed.
+const EmbeddingCacheFile = "embedding-cache.jsonl"
+
+// embedChunks embeds the texts of a document's chunks with service, batchSize at a
+// time and concurrency batches at once, calling progress with the number embedded
+// as batches finish. The vectors are in the order of texts. Texts over limit,
+// unless it is nil, are fitted to it as its strategy says, and returned numbered
+// among texts. A batch the provider refuses, as it does for a single bad text, is
+// embedded again one text at a time, and the texts it refuses on their own, as
+// invalid or too long, are skipped: their vectors are nil and their chunks are
+// returned, so that they are stored without a vector rather than failing the
+// document, unless every one is refused. Any other failure, such as being rate
+// limited, fails the document and gives up the batches not sent yet.
+func embedChunks(ctx context.Context, service embedding.Service, limit *embedding.Limit, texts []string, batchSize, concurrency int, progress func(done int)) ([]embedding.EmbedResponse, []embedding.Fit, []int, error) {
+	embed := func(batch []string) ([]embedding.EmbedResponse, []embedding.Fit, error) {
+		if limit == nil {
+			vectors, err := service.GetEmbeddingsBatch(ctx, batch, embedding.EmbeddingTypeRetrievalDocument)
+			return vectors, nil, err
+		}
+		return limit.Embed(ctx, service, batch, embedding.EmbeddingTypeRetrievalDocument)
+	}
+	embedOne := func(text string) (embedding.EmbedResponse, []embedding.Fit, error) {
+		if limit == nil {
+			vector, err := service.GetEmbeddings(ctx, text, embedding.EmbeddingTypeRetrievalDocument)
+			return vector, nil, err
+		}
+		vectors, fits, err := limit.Embed(ctx, service, []string{text}, embedding.EmbeddingTypeRetrievalDocument)
+		if err != nil {
+			return nil, nil, err
+		}
+		return vectors[0], fits, nil
+	}
+
+	embedBatch := func(start int) ([]embedding.EmbedResponse, []embedding.Fit, []refusal, error) {
+		batch := texts[start:min(start+batchSize, len(texts))]
+		embedded, fitted, err := embed(batch)
+		var refused []refusal
+		var tooLong *embedding.InputTooLongError
+		switch {
+		case errors.As(err, &tooLong):
+			err = fmt.Errorf("chunk %d: %w", start+tooLong.Index, err)
+		case errors.Is(err, embedding.ErrInvalidInput) && len(batch) > 1:
+			slog.WarnContext(ctx, "embedding batch refused, embedding its chunks one at a time", "first_chunk", start, "chunks", len(batch), "error", err)
+			embedded, fitted, refused, err = embedEach(ctx, batch, start, embedOne)
+		case errors.Is(err, embedding.ErrInvalidInput):
+			embedded, refused, err = []embedding.EmbedResponse{nil}, []refusal{refuse(ctx, start, err)}, nil
+		}
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if len(embedded) != len(batch) {
+			return nil, nil, nil, fmt.Errorf("got %d embeddings for %d chunks", len(embedded), len(batch))
+		}
+		return embedded, fitted, refused, nil
+	}
+
+	type batchResult struct {
+		vectors []embedding.EmbedResponse
+		fits    []embedding.Fit
+		refused []refusal
+		err     error
+	}
+	results := make([]batchResult, (len(texts)+batchSize-1)/batchSize)
+	batchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var mu sync.Mutex
+	done := 0
+	progress(0)
+	llm.RunBatch(batchCtx, len(results), concurrency, func(batchCtx context.Context, n int) {
+		result := &results[n]
+		if result.err = batchCtx.Err(); result.err != nil {
+			return
+		}
+		result.vectors, result.fits, result.refused, result.err = embedBatch(n * batchSize)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if result.err != nil {
+			cancel()
+			return
+		}
+		done += len(result.vectors)
+		progress(done)
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	vectors := make([]embedding.EmbedResponse, 0, len(texts))
+	var fits []embedding.Fit
+	var refused []refusal
+	var failed error
+	for n, result := range results {
+		if result.err != nil {
+			// Batches cancelled after the failure are not reported.
+			if failed == nil && !errors.Is(result.err, context.Canceled) {
+				failed = result.err
+			}
+			continue
+		}
+		vectors = append(vectors, result.vectors...)
+		for _, fit := range result.fits {
+			fit.Index += n * batchSize
+			fits = append(fits, fit)
+		}
+		refused = append(refused, result.refused...)
+	}
+	if failed != nil {
+		return nil, nil, nil, failed
+	}
+	if len(texts) > 0 && len(refused) == len(texts) {
+		return nil, nil, nil, fmt.Errorf("the provider refused every chunk, the first: %w", refused[0].err)
+	}
+	chunks := make([]int, len(refused))
+	for n, r := range refused {
+		chunks[n] = r.chunk
+	}
+	return vectors, fits, chunks, nil
+}
+
+// refusal is a chunk whose text the embedding provider refused, and why.
+type refusal struct {
+	chunk int
+	err   error
+}
+
+// refuse logs that chunk is stored without a vector as embedding it failed with
+// err, returning its refusal.
+func refuse(ctx context.Context, chunk int, err error) refusal {
+	slog.WarnContext(ctx, "embedding refused, storing the chunk without a vector", "chunk", chunk, "error", err)
+	return refusal{chunk: chunk, err: err}
+}
+
+// embedEach embeds texts one at a time with embed, skipping those the provider
+// refuses and naming the chunk, numbered from first, whose text fails otherwise.
+func embedEach(ctx context.Context, texts []string, first int, embed func(string) (embedding.EmbedResponse, []embedding.Fit, error)) ([]embedding.EmbedResponse, []embedding.Fit, []refusal, error) {
+	vectors := make([]embedding.EmbedResponse, len(texts))
+	var fits []embedding.Fit
+	var refused []refusal
+	for n, text := range texts {
+		vector, fitted, err := embed(text)
+		if errors.Is(err, embedding.ErrInvalidInput) {
+			refused = append(refused, refuse(ctx, first+n, err))
+			continue
+		}
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("chunk %d: %w", first+n, err)
+		}
+		vectors[n] = vector
+		for _, fit := range fitted {
+			fit.Index = n
+			fits = append(fits, fit)
+		}
+	}
+	return vectors, fits, refused, nil
+}
+
+// rechunk replaces the texts over limit with parts that fit it, so that each part
+// is stored and embedded as a chunk of its own. It returns the texts and, for
+// each text replaced, the first of its parts and its size in tokens.
+func rechunk(limit embedding.Limit, texts []string) ([]string, []OverLimitChunk) {
+	var out []string
+	var replaced []OverLimitChunk
+	for _, text := range texts {
+		var tooLong *embedding.InputTooLongError
+		if !errors.As(limit.Check(text), &tooLong) {
+			out = append(out, text)
+			continue
+		}
+		replaced = append(replaced, OverLimitChunk{Chunk: len(out), Tokens: tooLong.Tokens, Action: OverLimitRechunked})
+		out = append(out, limit.Split(text)...)
+	}
+	return out, replaced
+}
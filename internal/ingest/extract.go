@@ -0,0 +1,274 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/budget"
+	"github.com/sandwichlabs/agent-memory-graph/internal/embedding"
+	"github.com/sandwichlabs/agent-memory-graph/internal/llm"
+	"github.com/sandwichlabs/agent-memory-graph/internal/llm/prompts"
+	"github.com/sandwichlabs/agent-memory-graph/internal/redact"
+	"github.com/sandwichlabs/agent-memory-graph/internal/storage"
+)
+
+// extractionSchema is the JSON schema of the extraction, the shape requested by
+// the extraction prompt.
+var extractionSchema = json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "entities": {"type": "array", "items": {"type": "object", "properties": {
+      "name": {"type": "string"},
+      "type": {"type": "string", "enum": ["PERSON", "ORG", "PLACE", "PRODUCT", "CONCEPT", "EVENT"]}
+    }, "required": ["name", "type"]}},
+    "relationships": {"type": "array", "items": {"type": "object", "properties": {
+      "subject": {"type": "string"},
+      "predicate": {"type": "string"},
+      "object": {"type": "string"}
+    }, "required": ["subject", "predicate", "object"]}}
+  },
+  "required": ["entities", "relationships"]
+}`)
+
+// extractionTool takes the extraction as typed arguments of extractionSchema.
+var extractionTool = llm.ToolDefinition{
+	Name:        "record_graph",
+	Description: "Record the named entities in a text and the relationships between them.",
+	Parameters:  extractionSchema,
+}
+
+// extractionOptions make extraction repeatable, seeding providers that take a
+// seed, and leave room to list everything in a large chunk.
+var extractionOptions = []llm.GenerateOption{llm.DeterministicProfile(), llm.WithMaxTokens(2000)}
+
+// extractionModel returns the provider of service and the model extracting
+// entities: Options.ExtractionModel, or else the service's chat model.
+func extractionModel(service llm.LlmService, opts Options) (llm.Provider, string) {
+	provider, model := llm.ChatModel(service)
+	if opts.ExtractionModel != "" {
+		model = opts.ExtractionModel
+	}
+	return provider, model
+}
+
+// errUnparsedExtraction marks an extraction whose answer could not be read, which
+// leaves the chunk without entities rather than failing the document.
+var errUnparsedExtraction = errors.New("unparsed extraction")
+
+// Extraction failures are handled by the class of LLM error: chunks that are too
+// long for the model, whose extraction is truncated or that it refuses are stored
+// without entities, throttling and provider failures are retried after
+// llmRetryDelay, up to llmRetries times, and other failures fail the document.
+var (
+	llmRetries    = 2
+	llmRetryDelay = 30 * time.Second
+)
+
+// skippable reports whether an extraction failure leaves the chunk without
+// entities rather than failing the document. A reply cut off at the max tokens
+// cannot be parsed either.
+func skippable(err error) bool {
+	return errors.Is(err, errUnparsedExtraction) || errors.Is(err, llm.ErrTruncated) || errors.Is(err, llm.ErrContextLengthExceeded) || errors.Is(err, llm.ErrContentFiltered)
+}
+
+// retryable reports whether an extraction failure may pass if tried again later.
+func retryable(err error) bool {
+	return errors.Is(err, llm.ErrRateLimited) || errors.Is(err, llm.ErrUpstream)
+}
+
+// fatal reports whether a failure means no source can be ingested until the LLM
+// or embedding key or account is fixed, or in the rest of a run whose budget is
+// exhausted.
+func fatal(err error) bool {
+	return errors.Is(err, llm.ErrUnauthorized) || errors.Is(err, llm.ErrQuotaExceeded) || errors.Is(err, budget.ErrBudgetExhausted) ||
+		errors.Is(err, embedding.ErrUnauthorized)
+}
+
+// extractChunk extracts the entities and relationships in text with the
+// Ingestor's LLM, retrying retryable failures as the budget ctx carries allows.
+// The usage totals every attempt.
+func (i *Ingestor) extractChunk(ctx context.Context, text string) ([]storage.Entity, []storage.Relationship, llm.Usage, error) {
+	var opts []llm.GenerateOption
+	if i.opts.ExtractionModel != "" {
+		opts = append(opts, llm.WithModel(i.opts.ExtractionModel))
+	}
+	var usage llm.Usage
+	for attempt := 1; ; attempt++ {
+		entities, relationships, used, err := extract(ctx, i.llm, i.prompts, text, opts...)
+		usage = usage.Add(used)
+		if err == nil || attempt > llmRetries || !retryable(err) {
+			return entities, relationships, usage, err
+		}
+		if budgetErr := budget.FromContext(ctx).Retry(llmRetryDelay); budgetErr != nil {
+			return nil, nil, usage, fmt.Errorf("%w (not retrying: %w)", budgetErr, err)
+		}
+		slog.WarnContext(ctx, "retrying entity extraction later", "attempt", attempt, "delay", llmRetryDelay, "error", err)
+		timer := time.NewTimer(llmRetryDelay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, nil, usage, fmt.Errorf("%w (while waiting to retry after: %v)", ctx.Err(), err)
+		}
+	}
+}
+
+// extractChunks extracts the entities and relationships of chunks with the
+// Ingestor's LLM, Options.LlmConcurrency chunks at a time, adding the tokens spent
+// to usage, their price to cost and the number of chunks left without entities
+// to failures. It fails with the first error, in chunk order, that is not
+// skippable, cancelling the extractions still running.
+func (i *Ingestor) extractChunks(ctx context.Context, source string, chunks []storage.Chunk, usage *llm.Usage, cost *llm.Cost, failures *int, emit func(stage Stage, chunk, chunks int)) error {
+	batchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make([]error, len(chunks))
+	var mu sync.Mutex
+	done := 0
+	emit(StageExtracting, 0, len(chunks))
+	llm.RunBatch(batchCtx, len(chunks), i.opts.LlmConcurrency, func(batchCtx context.Context, n int) {
+		if errs[n] = batchCtx.Err(); errs[n] != nil {
+			return
+		}
+		entities, relationships, used, err := i.extractChunk(batchCtx, chunks[n].Content)
+		chunks[n].Entities, chunks[n].Relationships, errs[n] = entities, relationships, err
+		provider, model := extractionModel(i.llm, i.opts)
+		spent := i.costs.Record(provider, model, used)
+
+		mu.Lock()
+		defer mu.Unlock()
+		*usage, *cost = usage.Add(used), cost.Add(spent)
+		if err != nil && !skippable(err) {
+			cancel()
+		}
+		done++
+		emit(StageExtracting, done, len(chunks))
+	})
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("failed to extract graph info: %w", err)
+	}
+
+	var failed error
+	for n, err := range errs {
+		switch {
+		case err == nil:
+		case skippable(err):
+			slog.WarnContext(ctx, "skipping entity extraction for chunk", "source", source, "chunk", n, "error", err)
+			*failures++
+		case failed == nil && !errors.Is(err, context.Canceled):
+			// Extractions cancelled after the failure are not reported.
+			failed = err
+		}
+	}
+	if failed != nil {
+		return fmt.Errorf("failed to extract graph info: %w", failed)
+	}
+	slog.Debug("extracted graph info", "source", source, "chunks", len(chunks), "failed", *failures)
+	return nil
+}
+
+// extract asks service for the entities and relationships in text, as JSON
+// constrained to extractionSchema when the service supports structured outputs,
+// through function calling when it supports that and as JSON otherwise, with the
+// prompts of set. The requests take extractionOptions followed by opts. Errors
+// wrapping errUnparsedExtraction mean the model's answer could not be read.
+func extract(ctx context.Context, service llm.LlmService, set *prompts.Set, text string, opts ...llm.GenerateOption) ([]storage.Entity, []storage.Relationship, llm.Usage, error) {
+	opts = append(append([]llm.GenerateOption(nil), extractionOptions...), opts...)
+	var answer string
+	var usage llm.Usage
+	if caller, ok := service.(llm.ToolCaller); ok && !llm.SupportsSchema(service) {
+		prompt, err := set.Render(prompts.ToolExtraction, prompts.TextData{Text: text})
+		if err != nil {
+			return nil, nil, usage, err
+		}
+		result, err := caller.GenerateWithTools(ctx, prompt, []llm.ToolDefinition{extractionTool}, opts...)
+		if err != nil {
+			return nil, nil, result.Usage, err
+		}
+		// A model that answers in text may still have written the JSON out.
+		answer, usage = result.Text, result.Usage
+		if result.Called() {
+			answer = string(result.Arguments)
+		}
+	} else {
+		prompt, err := set.Render(prompts.Extraction, prompts.TextData{Text: text})
+		if err != nil {
+			return nil, nil, usage, err
+		}
+		var raw json.RawMessage
+		var used llm.Usage
+		if llm.SupportsSchema(service) {
+			raw, used, err = llm.GenerateWithSchema(ctx, service, prompt, "graph_extraction", extractionSchema, opts...)
+		} else {
+			raw, used, err = llm.GenerateJSON(ctx, service, prompt, opts...)
+		}
+		if errors.Is(err, llm.ErrInvalidJSON) {
+			return nil, nil, used, fmt.Errorf("%w: %v", errUnparsedExtraction, err)
+		}
+		if err != nil {
+			return nil, nil, used, err
+		}
+		answer, usage = string(raw), used
+	}
+	slog.Debug("extracted graph info", "chunk_length", len(text), redact.Content("graph_info", answer))
+
+	entities, relationships, err := parseExtraction(answer)
+	if err != nil {
+		return nil, nil, usage, fmt.Errorf("%w: %v", errUnparsedExtraction, err)
+	}
+	return entities, relationships, usage, nil
+}
+
+// extraction is the JSON shape requested by the extraction prompt.
+type extraction struct {
+	Entities []struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+	} `json:"entities"`
+	Relationships []struct {
+		Subject   string `json:"subject"`
+		Predicate string `json:"predicate"`
+		Object    string `json:"object"`
+	} `json:"relationships"`
+}
+
+// parseExtraction decodes the LLM's extraction response, tolerating a surrounding
+// markdown code fence. Entries with empty names are dropped.
+func parseExtraction(response string) ([]storage.Entity, []storage.Relationship, error) {
+	text := strings.TrimSpace(response)
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```json")
+		text = strings.TrimPrefix(text, "```")
+		text = strings.TrimSuffix(strings.TrimSpace(text), "```")
+	}
+
+	var parsed extraction
+	if err := json.Unmarshal([]byte(text), &parsed); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse extraction response: %w", err)
+	}
+
+	var entities []storage.Entity
+	for _, e := range parsed.Entities {
+		if name := strings.TrimSpace(e.Name); name != "" {
+			entities = append(entities, storage.Entity{Name: name, Type: strings.ToUpper(strings.TrimSpace(e.Type))})
+		}
+	}
+	var relationships []storage.Relationship
+	for _, r := range parsed.Relationships {
+		subject, object := strings.TrimSpace(r.Subject), strings.TrimSpace(r.Object)
+		if subject != "" && object != "" {
+			relationships = append(relationships, storage.Relationship{
+				Subject:   subject,
+				Predicate: strings.TrimSpace(r.Predicate),
+				Object:    object,
+			})
+		}
+	}
+	return entities, relationships, nil
+}
@@ -0,0 +1,205 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/llm"
+	"github.com/sandwichlabs/agent-memory-graph/internal/llm/prompts"
+	"github.com/sandwichlabs/agent-memory-graph/internal/storage"
+)
+
+func TestParseExtraction_FencedResponse(t *testing.T) {
+	response := "```json\n" + `{
+  "entities": [{"name": " Kuzu ", "type": "org"}, {"name": "", "type": "PERSON"}],
+  "relationships": [{"subject": "Kuzu", "predicate": "builds", "object": "KuzuDB"}, {"subject": "Kuzu", "predicate": "x", "object": ""}]
+}` + "\n```"
+
+	entities, relationships, err := parseExtraction(response)
+	if err != nil {
+		t.Fatalf("parseExtraction failed: %v", err)
+	}
+
+	expectedEntities := []storage.Entity{{Name: "Kuzu", Type: "ORG"}}
+	if !reflect.DeepEqual(entities, expectedEntities) {
+		t.Errorf("Expected entities %+v, got %+v", expectedEntities, entities)
+	}
+	expectedRelationships := []storage.Relationship{{Subject: "Kuzu", Predicate: "builds", Object: "KuzuDB"}}
+	if !reflect.DeepEqual(relationships, expectedRelationships) {
+		t.Errorf("Expected relationships %+v, got %+v", expectedRelationships, relationships)
+	}
+}
+
+func TestParseExtraction_InvalidJSON(t *testing.T) {
+	if _, _, err := parseExtraction("Kuzu is a graph database."); err == nil {
+		t.Fatal("Expected an error for a non-JSON response, got nil")
+	}
+}
+
+// fakeLLM answers every prompt with reply.
+type fakeLLM struct {
+	reply string
+	usage llm.Usage
+}
+
+func (f *fakeLLM) GenerateText(ctx context.Context, prompt string, opts ...llm.GenerateOption) (string, error) {
+	return f.reply, nil
+}
+
+func (f *fakeLLM) GenerateTextWithUsage(ctx context.Context, prompt string, opts ...llm.GenerateOption) (string, llm.Usage, error) {
+	return f.reply, f.usage, nil
+}
+
+func (f *fakeLLM) Chat(ctx context.Context, messages []llm.Message, opts ...llm.GenerateOption) (string, error) {
+	return f.reply, nil
+}
+
+func (f *fakeLLM) GenerateTextStream(ctx context.Context, prompt string, opts ...llm.GenerateOption) (<-chan string, <-chan error) {
+	return nil, nil
+}
+
+func (f *fakeLLM) ExtractTextFromImage(ctx context.Context, prompt string, image []byte, mimeType string, opts ...llm.GenerateOption) (string, error) {
+	return "", errors.New("not supported")
+}
+
+func (f *fakeLLM) ExtractTextFromImages(ctx context.Context, prompt string, images []llm.ImageInput, opts ...llm.GenerateOption) (string, error) {
+	return "", errors.New("not supported")
+}
+
+func (f *fakeLLM) ExtractTextFromDocument(ctx context.Context, prompt string, pdf []byte, opts ...llm.GenerateOption) (string, error) {
+	return "", errors.New("not supported")
+}
+
+func (f *fakeLLM) TranscribeAudio(ctx context.Context, audio []byte, mimeType string) (string, error) {
+	return "", errors.New("not supported")
+}
+
+func (f *fakeLLM) Ping(ctx context.Context) error {
+	return nil
+}
+
+// fakeToolLLM calls the tool it is offered with arguments, or answers in text when
+// arguments is empty.
+type fakeToolLLM struct {
+	fakeLLM
+	arguments string
+	offered   []llm.ToolDefinition
+}
+
+func (f *fakeToolLLM) GenerateWithTools(ctx context.Context, prompt string, tools []llm.ToolDefinition, opts ...llm.GenerateOption) (llm.ToolCallResult, error) {
+	f.offered = tools
+	if f.arguments == "" {
+		return llm.ToolCallResult{Text: f.reply, Usage: f.usage}, nil
+	}
+	return llm.ToolCallResult{Tool: tools[0].Name, Arguments: json.RawMessage(f.arguments), Usage: f.usage}, nil
+}
+
+func TestExtract_ToolCall(t *testing.T) {
+	service := &fakeToolLLM{
+		fakeLLM:   fakeLLM{usage: llm.Usage{TotalTokens: 10}},
+		arguments: `{"entities": [{"name": "Kuzu", "type": "ORG"}], "relationships": []}`,
+	}
+	entities, _, usage, err := extract(context.Background(), service, prompts.Default(), "Kuzu Inc builds KuzuDB.")
+	if err != nil {
+		t.Fatalf("extract failed: %v", err)
+	}
+	if len(service.offered) != 1 || service.offered[0].Name != "record_graph" || !json.Valid(service.offered[0].Parameters) {
+		t.Errorf("Expected the record_graph tool offered with a valid schema, got %+v", service.offered)
+	}
+	if !reflect.DeepEqual(entities, []storage.Entity{{Name: "Kuzu", Type: "ORG"}}) || usage.TotalTokens != 10 {
+		t.Errorf("Expected the tool's entities and usage, got %+v and %+v", entities, usage)
+	}
+
+	// A text answer is parsed like a JSON reply, and skipped when it is prose.
+	service.arguments, service.reply = "", "I found no entities."
+	if _, _, _, err := extract(context.Background(), service, prompts.Default(), "Nothing here."); !errors.Is(err, errUnparsedExtraction) {
+		t.Errorf("Expected an unparsed extraction, got %v", err)
+	}
+}
+
+func TestExtract_JSONFallback(t *testing.T) {
+	service := &fakeLLM{reply: "```json\n{\"entities\": [{\"name\": \"KuzuDB\", \"type\": \"product\"}]}\n```", usage: llm.Usage{TotalTokens: 7}}
+	entities, _, usage, err := extract(context.Background(), service, prompts.Default(), "KuzuDB speaks Cypher.")
+	if err != nil {
+		t.Fatalf("extract failed: %v", err)
+	}
+	if !reflect.DeepEqual(entities, []storage.Entity{{Name: "KuzuDB", Type: "PRODUCT"}}) || usage.TotalTokens != 7 {
+		t.Errorf("Expected the JSON reply's entities and usage, got %+v and %+v", entities, usage)
+	}
+
+	service.reply = "no JSON here"
+	if _, _, usage, err := extract(context.Background(), service, prompts.Default(), "KuzuDB speaks Cypher."); !errors.Is(err, errUnparsedExtraction) || usage.TotalTokens != 14 {
+		t.Errorf("Expected an unparsed extraction after two attempts, got %v and %+v", err, usage)
+	}
+}
+
+func TestExtract_Schema(t *testing.T) {
+	var formats []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			ResponseFormat struct {
+				Type       string `json:"type"`
+				JSONSchema struct {
+					Name string `json:"name"`
+				} `json:"json_schema"`
+			} `json:"response_format"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		formats = append(formats, payload.ResponseFormat.Type+":"+payload.ResponseFormat.JSONSchema.Name)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{{"message": map[string]any{"content": `{"entities": [{"name": "Kuzu", "type": "ORG"}], "relationships": []}`}}},
+		})
+	}))
+	defer server.Close()
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	service, err := llm.NewMistralLlmService(llm.WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("NewMistralLlmService failed: %v", err)
+	}
+
+	entities, _, _, err := extract(context.Background(), service, prompts.Default(), "Kuzu Inc builds KuzuDB.")
+	if err != nil {
+		t.Fatalf("extract failed: %v", err)
+	}
+	if !reflect.DeepEqual(entities, []storage.Entity{{Name: "Kuzu", Type: "ORG"}}) {
+		t.Errorf("Expected the schema reply's entities, got %+v", entities)
+	}
+	if !reflect.DeepEqual(formats, []string{"json_schema:graph_extraction"}) {
+		t.Errorf("Expected one request constrained to the extraction schema, got %q", formats)
+	}
+}
+
+// TestExtract_RecordedFixture replays an extraction recorded from Mistral. Run it
+// with AMG_LLM_RECORD=1 and MISTRAL_API_KEY set to record it again, such as after
+// changing the extraction prompt.
+func TestExtract_RecordedFixture(t *testing.T) {
+	t.Setenv("MISTRAL_CHAT_MODEL", "")
+	recorder := llm.NewRecorder(filepath.Join("testdata", "llm"))
+	opts := []llm.MistralOption{llm.WithHTTPClient(recorder.Client())}
+	if !recorder.Record {
+		opts = append(opts, llm.WithAPIKey("test_api_key"))
+	}
+	service, err := llm.NewMistralLlmService(opts...)
+	if err != nil {
+		t.Fatalf("NewMistralLlmService failed: %v", err)
+	}
+
+	entities, relationships, usage, err := extract(context.Background(), service, prompts.Default(), "Ada Lovelace wrote the first notes on the Analytical Engine.")
+	if err != nil {
+		t.Fatalf("extract failed: %v", err)
+	}
+	wantEntities := []storage.Entity{{Name: "Ada Lovelace", Type: "PERSON"}, {Name: "Analytical Engine", Type: "PRODUCT"}}
+	if !reflect.DeepEqual(entities, wantEntities) || len(relationships) != 1 || relationships[0].Predicate != "WROTE_ABOUT" {
+		t.Errorf("Expected the recorded entities and relationship, got %+v and %+v", entities, relationships)
+	}
+	if usage.TotalTokens != 470 {
+		t.Errorf("Expected the recorded usage of 470 tokens, got %+v", usage)
+	}
+}
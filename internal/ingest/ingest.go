@@ -2,92 +2,805 @@ package ingest
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
 
-	"github.com/kuzudb/go-kuzu"
+	"github.com/sandwichlabs/agent-memory-graph/internal/budget"
 	"github.com/sandwichlabs/agent-memory-graph/internal/embedding"
 	"github.com/sandwichlabs/agent-memory-graph/internal/llm"
-	"github.com/tmc/langchaingo/documentloaders"
+	"github.com/sandwichlabs/agent-memory-graph/internal/llm/prompts"
+	"github.com/sandwichlabs/agent-memory-graph/internal/metrics"
+	"github.com/sandwichlabs/agent-memory-graph/internal/storage"
 	"github.com/tmc/langchaingo/textsplitter"
 )
 
-func IngestFile(filePath string) error {
+// Options configures how files are ingested.
+type Options struct {
+	// Collection groups the document with others; storage.DefaultCollection when empty.
+	Collection        string
+	EmbeddingProvider embedding.Provider
+	// LlmProvider extracts entities; when empty, it is read from AMG_LLM_PROVIDER
+	// as by llm.NewFromEnv.
+	LlmProvider llm.Provider
+	// LlmCacheDir, when set, caches the LLM's replies there so that extracting
+	// from the same chunks again costs nothing.
+	LlmCacheDir string
+	// RefreshLlmCache asks the LLM again instead of using cached replies, caching
+	// the new ones.
+	RefreshLlmCache bool
+	// ExtractionModel, when set, extracts entities with this model of the LLM
+	// provider rather than its chat model, such as a small cheap one.
+	ExtractionModel string
+	// Force re-ingests sources whose content has not changed since the last ingest.
+	Force bool
+	// Tags label the stored documents, e.g. so they can be pruned together later.
+	Tags []string
+	// ChunkTokens, when set, splits documents into chunks of at most about this
+	// many tokens of the extraction model, as counted by llm.CountTokens, rather
+	// than 512 characters.
+	ChunkTokens int
+	// EmbeddingBatchSize is how many chunks are embedded per request;
+	// DefaultEmbeddingBatchSize when zero.
+	EmbeddingBatchSize int
+	// EmbeddingConcurrency is how many batches of a source are embedded at once;
+	// embedding.DefaultConcurrency when zero. Requests still wait on the
+	// provider's rate limit.
+	EmbeddingConcurrency int
+	// OverLimit is what is done with chunks over the embedding model's input
+	// limit: embedding.LengthTruncate or embedding.LengthSplit fit their text
+	// when embedding it, while embedding.LengthFail, the default, splits them into
+	// chunks that fit before anything is embedded.
+	OverLimit embedding.LengthStrategy
+	// EmbeddingPrecision is how the vectors of chunks are stored, recorded in
+	// the memory graph for later ingests; when empty, the recorded precision, or
+	// embedding.PrecisionFloat32 in a new memory graph.
+	EmbeddingPrecision embedding.Precision
+	// NoEmbeddingCache embeds every chunk, instead of reusing the vectors of
+	// chunks embedded before, which are kept in EmbeddingCacheFile in the
+	// memory graph's directory.
+	NoEmbeddingCache bool
+	// EmbeddingCacheMaxEntries is how many vectors the cache keeps;
+	// embedding.DefaultCacheMaxEntries when zero.
+	EmbeddingCacheMaxEntries int
+	// LlmConcurrency is how many chunks of a source are sent to the LLM at once;
+	// llm.DefaultBatchConcurrency when zero. Requests still wait on the provider's
+	// rate limit.
+	LlmConcurrency int
+	// Budget caps the retries, tokens and backoff of each IngestAll run, after
+	// which its requests fail with budget.ErrBudgetExhausted and the sources left
+	// are not attempted. The zero value limits nothing.
+	Budget budget.Limits
+	// Progress, when set, receives an event as each source moves through the pipeline.
+	Progress func(Progress)
+}
+
+// Stage is a step of ingesting one source.
+type Stage string
+
+const (
+	StageLoading      Stage = "loading"
+	StageTranscribing Stage = "transcribing"
+	StageReading      Stage = "reading"
+	StageEmbedding    Stage = "embedding"
+	StageExtracting   Stage = "extracting"
+	StageSaving       Stage = "saving"
+	StageDone         Stage = "done"
+)
+
+// Progress reports how far an ingest has got.
+type Progress struct {
+	Source string
+	// File is the 1-based position of Source among Files sources in the batch.
+	File  int
+	Files int
+	Stage Stage
+	// Chunk is the number of Chunks already processed at this stage.
+	Chunk  int
+	Chunks int
+	// Result is set when Stage is StageDone.
+	Result *Result
+}
+
+// Status describes what happened to a source.
+type Status string
+
+const (
+	StatusIngested  Status = "ingested"
+	StatusUpdated   Status = "updated"
+	StatusUnchanged Status = "unchanged"
+	StatusRemoved   Status = "removed"
+	StatusFailed    Status = "failed"
+)
+
+// Result is the outcome of ingesting a single source.
+type Result struct {
+	Source string
+	Status Status
+	Chunks int
+	Err    error
+	// Usage is the LLM tokens spent extracting entities, including for sources
+	// that failed part way through.
+	Usage llm.Usage
+	// Cost is the estimated price of Usage.
+	Cost llm.Cost
+	// ExtractionFailures is how many of the Chunks are stored without entities
+	// as extracting them failed, such as for being too long for the model.
+	ExtractionFailures int
+	// OverLimitChunks are the chunks that were over the embedding model's input
+	// limit and what was done about them.
+	OverLimitChunks []OverLimitChunk
+	// EmbeddingFailures is how many of the Chunks are stored without a vector as
+	// the embedding provider refused their text, such as for being too long;
+	// amg reindex --only-missing embeds them again.
+	EmbeddingFailures int
+}
+
+// OverLimitAction is what was done with a chunk over the embedding model's
+// input limit.
+type OverLimitAction string
+
+const (
+	// OverLimitTruncated embedded as much of the chunk as fits.
+	OverLimitTruncated OverLimitAction = "truncated"
+	// OverLimitSplit embedded the chunk in parts and averaged their vectors.
+	OverLimitSplit OverLimitAction = "split"
+	// OverLimitRechunked replaced the chunk with smaller ones.
+	OverLimitRechunked OverLimitAction = "rechunked"
+)
+
+// OverLimitChunk records a chunk over the embedding model's input limit.
+type OverLimitChunk struct {
+	// Chunk is the index of the chunk, or of the first that replaced it.
+	Chunk  int
+	Tokens int
+	Action OverLimitAction
+}
+
+// Report collects the results of a batch ingest.
+type Report struct {
+	Results []Result
+	// LLMCalls are the requests made to LLM providers by the process so far, as
+	// recorded in metrics.Default, by provider and method.
+	LLMCalls []metrics.MethodStats
+	// EmbeddingCalls are the requests made to embedding providers, and the
+	// lookups of the embedding cache, by the process so far, as recorded in
+	// metrics.Embeddings, by provider and method.
+	EmbeddingCalls []metrics.MethodStats
+	// Budget is what the run's budget allowed and what was spent of it.
+	Budget budget.State
+	// EmbeddingCache is how many chunks were found in the embedding cache and
+	// how many had to be embedded; zero when the cache is off.
+	EmbeddingCache embedding.CacheStats
+	// EmbeddingUsage is the tokens the embedding provider billed for the batch,
+	// Unknown when it does not report them.
+	EmbeddingUsage embedding.Usage
+}
+
+// Usage returns the LLM tokens spent on the whole batch.
+func (r Report) Usage() llm.Usage {
+	var usage llm.Usage
+	for _, result := range r.Results {
+		usage = usage.Add(result.Usage)
+	}
+	return usage
+}
+
+// Cost returns the estimated price of the LLM tokens spent on the whole batch. It
+// is unknown when any of them were spent on a model without a price.
+func (r Report) Cost() llm.Cost {
+	var cost llm.Cost
+	for _, result := range r.Results {
+		cost = cost.Add(result.Cost)
+	}
+	return cost
+}
+
+// ExtractionFailures returns the number of chunks stored without entities across
+// the batch.
+func (r Report) ExtractionFailures() int {
+	failures := 0
+	for _, result := range r.Results {
+		failures += result.ExtractionFailures
+	}
+	return failures
+}
+
+// EmbeddingFailures returns the number of chunks stored without a vector across
+// the batch.
+func (r Report) EmbeddingFailures() int {
+	failures := 0
+	for _, result := range r.Results {
+		failures += result.EmbeddingFailures
+	}
+	return failures
+}
+
+// Chunks returns the number of chunks stored across the batch.
+func (r Report) Chunks() int {
+	chunks := 0
+	for _, result := range r.Results {
+		chunks += result.Chunks
+	}
+	return chunks
+}
+
+// OverLimitChunks returns the chunks over the embedding model's input limit
+// across the batch.
+func (r Report) OverLimitChunks() []OverLimitChunk {
+	var chunks []OverLimitChunk
+	for _, result := range r.Results {
+		chunks = append(chunks, result.OverLimitChunks...)
+	}
+	return chunks
+}
+
+// Failed returns the number of sources that could not be ingested.
+func (r Report) Failed() int {
+	failed := 0
+	for _, result := range r.Results {
+		if result.Err != nil {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Ingestor chunks, embeds and stores documents in a memory graph.
+type Ingestor struct {
+	opts       Options
+	dimensions int // of the memory graph's vectors
+	embeddings embedding.Service
+	limit      *embedding.Limit // nil when the model's input limit is unknown
+	cache      *embedding.CachedService
+	cacheStore *embedding.FileCacheStore
+	llm        llm.LlmService
+	prompts    *prompts.Set
+	store      *storage.KuzuStore
+	costs      *llm.CostAccumulator
+}
+
+// NewIngestor creates the provider services and opens the memory graph in dbDir for
+// writing. Prompt templates in dbDir's prompts directory override the built-in ones.
+func NewIngestor(dbDir string, opts Options) (*Ingestor, error) {
+	if opts.Collection == "" {
+		opts.Collection = storage.DefaultCollection
+	}
+	if opts.OverLimit == "" {
+		opts.OverLimit = embedding.LengthFail
+	}
+	if opts.EmbeddingConcurrency == 0 {
+		opts.EmbeddingConcurrency = embedding.DefaultConcurrency
+	}
+	if !slices.Contains(embedding.LengthStrategies(), opts.OverLimit) {
+		return nil, fmt.Errorf("unknown over-limit strategy %q", opts.OverLimit)
+	}
+	if opts.EmbeddingPrecision != "" && !slices.Contains(embedding.Precisions(), opts.EmbeddingPrecision) {
+		return nil, fmt.Errorf("unknown embedding precision %q", opts.EmbeddingPrecision)
+	}
+	promptSet, err := prompts.Load(filepath.Join(dbDir, prompts.Dir))
+	if err != nil {
+		return nil, err
+	}
+
 	// Initialize services
-	embeddingService, err := embedding.New(embedding.ProviderMistral)
+	var llmService llm.LlmService
+	if opts.LlmProvider == "" {
+		llmService, err = llm.NewFromEnv()
+	} else {
+		llmService, err = llm.NewLlmService(opts.LlmProvider)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to create embedding service: %w", err)
+		return nil, fmt.Errorf("failed to create llm service: %w", err)
+	}
+	if opts.LlmCacheDir != "" {
+		llmService, err = llm.NewCachedService(llmService, opts.LlmCacheDir, llm.WithCacheRefresh(opts.RefreshLlmCache))
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	llmService, err := llm.NewLlmService(llm.ProviderMistral)
+	embeddingService, err := embedding.New(opts.EmbeddingProvider)
 	if err != nil {
-		return fmt.Errorf("failed to create llm service: %w", err)
+		return nil, fmt.Errorf("failed to create embedding service: %w", err)
 	}
 
-	// Load and chunk document
-	loader := documentloaders.NewText(filePath)
-	docs, err := loader.Load(context.Background())
+	if opts.ChunkTokens > 0 {
+		_, model := extractionModel(llmService, opts)
+		if window, ok := llm.MaxContextTokens(model); ok && opts.ChunkTokens > window {
+			return nil, fmt.Errorf("chunks of %d tokens do not fit the %d token context of %s", opts.ChunkTokens, window, model)
+		}
+	}
+
+	// A new memory graph is created for the provider's vectors; an existing
+	// one must match them.
+	store, err := storage.Open(dbDir, false, storage.WithEmbeddingDimensions(embedding.DimensionsOf(embeddingService)))
 	if err != nil {
-		return fmt.Errorf("failed to load document: %w", err)
+		return nil, err
+	}
+	dimensions, err := store.StoredEmbeddingDimensions(context.Background())
+	if err == nil {
+		err = embedding.CheckDimensions(embeddingService, dimensions)
+	}
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	if err := checkEmbeddingProvider(context.Background(), store, opts.EmbeddingProvider, embedding.ModelOf(embeddingService)); err != nil {
+		store.Close()
+		return nil, err
+	}
+	if opts.EmbeddingPrecision, err = embeddingPrecision(context.Background(), store, opts.EmbeddingPrecision); err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	ingestor := &Ingestor{
+		opts:       opts,
+		dimensions: dimensions,
+		embeddings: embeddingService,
+		llm:        llmService,
+		prompts:    promptSet,
+		store:      store,
+		costs:      llm.NewCostAccumulator(llm.NewCostEstimator(llm.DefaultPricing())),
 	}
+	if limit, ok := embedding.LimitOf(embeddingService, opts.OverLimit); ok {
+		ingestor.limit = &limit
+	}
+	if !opts.NoEmbeddingCache {
+		maxEntries := opts.EmbeddingCacheMaxEntries
+		if maxEntries <= 0 {
+			maxEntries = embedding.DefaultCacheMaxEntries
+		}
+		ingestor.cacheStore, err = embedding.OpenFileCacheStore(filepath.Join(dbDir, EmbeddingCacheFile), maxEntries)
+		if err != nil {
+			store.Close()
+			return nil, err
+		}
+		ingestor.cache = embedding.NewCachedService(embeddingService, ingestor.cacheStore)
+		ingestor.embeddings = ingestor.cache
+	}
+	return ingestor, nil
+}
 
-	splitter := textsplitter.NewRecursiveCharacter()
-	chunks, err := splitter.SplitDocuments(docs)
+// checkEmbeddingProvider records provider and model as the memory graph's
+// embedding provider and model, refusing to mix vectors from different providers
+// or models in one graph. A graph whose model was not recorded takes model.
+func checkEmbeddingProvider(ctx context.Context, store *storage.KuzuStore, provider embedding.Provider, model string) error {
+	stored, err := store.EmbeddingProvider(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to split document: %w", err)
+		return err
+	}
+	switch stored {
+	case "":
+		if err := store.SetEmbeddingProvider(ctx, string(provider)); err != nil {
+			return err
+		}
+	case string(provider):
+	default:
+		return fmt.Errorf("memory graph was embedded with %s, not %s: use --embedding-provider %s or run amg reindex --embedding-provider %s", stored, provider, stored, provider)
 	}
 
-	// Setup KuzuDB
-	db, err := kuzu.NewDatabase("amg.db", 0)
+	storedModel, err := store.EmbeddingModel(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to create database: %w", err)
+		return err
 	}
-	defer db.Destroy()
+	switch storedModel {
+	case "":
+		if model == "" {
+			return nil
+		}
+		return store.SetEmbeddingModel(ctx, model)
+	case model:
+		return nil
+	default:
+		return fmt.Errorf("memory graph was embedded with the %s model %s, not %s: configure %s or run amg reindex", provider, storedModel, model, storedModel)
+	}
+}
 
-	conn, err := kuzu.NewConnection(db)
+// embeddingPrecision returns the precision to store vectors in: precision,
+// recorded for later ingests, or else the recorded one or float32. Changing it
+// leaves the stored vectors as they are, as searches compare vectors of either
+// precision.
+func embeddingPrecision(ctx context.Context, store *storage.KuzuStore, precision embedding.Precision) (embedding.Precision, error) {
+	stored, err := store.EmbeddingPrecision(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to create connection: %w", err)
+		return "", err
 	}
-	defer conn.Destroy()
+	switch {
+	case precision == "" && stored == "":
+		return embedding.PrecisionFloat32, nil
+	case precision == "":
+		return embedding.Precision(stored), nil
+	case string(precision) == stored:
+		return precision, nil
+	}
+	if stored != "" {
+		slog.InfoContext(ctx, "changing the embedding precision, stored vectors keep theirs", "from", stored, "to", precision)
+	}
+	return precision, store.SetEmbeddingPrecision(ctx, string(precision))
+}
+
+// storeVector sets the vector of chunk in the given precision.
+func storeVector(chunk *storage.Chunk, vector embedding.EmbedResponse, precision embedding.Precision) {
+	if precision != embedding.PrecisionInt8 {
+		chunk.Embedding = vector
+		return
+	}
+	q := embedding.QuantizeInt8(vector)
+	chunk.Int8Embedding, chunk.EmbeddingScale, chunk.EmbeddingOffset = q.Values, q.Scale, q.Offset
+}
+
+// Costs returns the LLM usage and estimated cost of everything ingested so far.
+func (i *Ingestor) Costs() *llm.CostAccumulator {
+	return i.costs
+}
+
+// Preflight pings the embedding and LLM providers, so that a bad key or an
+// unreachable API fails the run before any source is read.
+func (i *Ingestor) Preflight(ctx context.Context) error {
+	if err := embedding.Preflight(ctx, i.opts.EmbeddingProvider, i.embeddings); err != nil {
+		return err
+	}
+	return llm.Preflight(ctx, i.llm)
+}
 
-	// Create schema
-	_, err = conn.Query("CREATE TABLE Document (content STRING, embedding FLOAT[768], PRIMARY KEY (content))")
+// Close releases the underlying database and embedding cache.
+func (i *Ingestor) Close() {
+	if i.cacheStore != nil {
+		i.cacheStore.Close()
+	}
+	i.store.Close()
+}
+
+// IngestAll ingests every source, isolating failures so one bad file does not stop the batch.
+// A failure of the LLM key or account does stop it, as every later source would fail the
+// same way; the sources left are reported as failed without being attempted.
+func (i *Ingestor) IngestAll(ctx context.Context, sources []string) Report {
+	var report Report
+	var cached embedding.CacheStats
+	if i.cache != nil {
+		cached = i.cache.Stats()
+	}
+	embeddingUsage := embedding.UsageOf(i.embeddings)
+	runBudget := budget.New(i.opts.Budget)
+	ctx = budget.NewContext(ctx, runBudget)
+	var stopped error
+	for n, source := range sources {
+		if stopped != nil {
+			report.Results = append(report.Results, Result{Source: source, Status: StatusFailed, Err: fmt.Errorf("not attempted: %w", stopped)})
+			continue
+		}
+		result := i.ingestAt(ctx, source, n+1, len(sources))
+		if result.Err != nil && fatal(result.Err) {
+			slog.ErrorContext(ctx, "stopping the ingest, as every further request would fail", "sources_left", len(sources)-n-1, "error", result.Err)
+			stopped = result.Err
+		}
+		report.Results = append(report.Results, result)
+	}
+	report.LLMCalls = metrics.Default.Snapshot()
+	report.EmbeddingCalls = metrics.Embeddings.Snapshot()
+	report.Budget = runBudget.State()
+	if i.cache != nil {
+		report.EmbeddingCache = i.cache.Stats().Sub(cached)
+	}
+	report.EmbeddingUsage = embedding.UsageOf(i.embeddings).Sub(embeddingUsage)
+	return report
+}
+
+// Ingest stores a single file, URL or stdin ("-"). Sources whose content matches the
+// stored content hash are skipped unless Options.Force is set.
+func (i *Ingestor) Ingest(ctx context.Context, source string) Result {
+	return i.ingestAt(ctx, source, 1, 1)
+}
+
+// ingestAt ingests the file-th of files sources, reporting progress.
+func (i *Ingestor) ingestAt(ctx context.Context, source string, file, files int) Result {
+	emit := func(stage Stage, chunk, chunks int) {
+		if i.opts.Progress != nil {
+			i.opts.Progress(Progress{Source: source, File: file, Files: files, Stage: stage, Chunk: chunk, Chunks: chunks})
+		}
+	}
+
+	result := Result{Source: source}
+	status, chunks, err := i.ingest(ctx, source, &result.Usage, &result.Cost, &result.ExtractionFailures, &result.EmbeddingFailures, &result.OverLimitChunks, emit)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to ingest source", "source", source, "error", err)
+		result.Status, result.Err = StatusFailed, err
+	} else {
+		result.Status, result.Chunks = status, chunks
+	}
+	if i.opts.Progress != nil {
+		i.opts.Progress(Progress{Source: source, File: file, Files: files, Stage: StageDone, Chunk: chunks, Chunks: chunks, Result: &result})
+	}
+	return result
+}
+
+// Remove deletes a previously ingested file from the memory graph.
+func (i *Ingestor) Remove(ctx context.Context, source string) Result {
+	if err := i.store.DeleteDocument(ctx, documentID(source)); err != nil {
+		slog.ErrorContext(ctx, "failed to remove source", "source", source, "error", err)
+		return Result{Source: source, Status: StatusFailed, Err: err}
+	}
+	slog.Info("removed document", "source", source)
+	return Result{Source: source, Status: StatusRemoved}
+}
+
+// ingest stores source, adding the LLM tokens it spends to usage, their price to
+// cost, the number of chunks stored without entities to failures, the number
+// stored without a vector to refused and the chunks over the embedding model's
+// input limit to overLimit.
+func (i *Ingestor) ingest(ctx context.Context, source string, usage *llm.Usage, cost *llm.Cost, failures, refused *int, overLimit *[]OverLimitChunk, emit func(stage Stage, chunk, chunks int)) (Status, int, error) {
+	// Load and chunk document
+	emit(StageLoading, 0, 0)
+	content, err := load(ctx, source)
 	if err != nil {
-		fmt.Printf("Error creating table: %v\n", err)
+		return "", 0, fmt.Errorf("failed to load document: %w", err)
+	}
+	extension := strings.ToLower(filepath.Ext(source))
+	audioType := audioTypes[extension]
+	pdf := extension == ".pdf"
+	if audioType == "" && !pdf && !utf8.Valid(content) {
+		return "", 0, fmt.Errorf("%s is not a UTF-8 text document", source)
+	}
+
+	doc := storage.Document{
+		ID:          documentID(source),
+		Source:      source,
+		Collection:  i.opts.Collection,
+		IngestedAt:  time.Now().UTC(),
+		ContentHash: contentHash(content),
+		Tags:        i.opts.Tags,
+	}
+	if source == StdinSource {
+		doc.ID = documentID(string(content))
+		doc.Source = "stdin"
+	}
+
+	storedHash, err := i.store.ContentHash(ctx, doc.ID)
+	if err != nil {
+		return "", 0, err
+	}
+	if storedHash == doc.ContentHash && !i.opts.Force {
+		slog.Debug("skipping unchanged document", "source", doc.Source)
+		return StatusUnchanged, 0, nil
+	}
+	status := StatusIngested
+	if storedHash != "" {
+		status = StatusUpdated
 	}
 
-	// Ingest chunks
-	for _, chunk := range chunks {
-		// Get embedding
-		embedding, err := embeddingService.GetEmbeddings(chunk.PageContent, embedding.EmbeddingTypeRetrievalDocument)
+	// Transcribe voice notes; the hash above is of the recording, so an
+	// unchanged one is not transcribed again.
+	if audioType != "" {
+		emit(StageTranscribing, 0, 0)
+		transcript, err := i.llm.TranscribeAudio(ctx, content, audioType)
 		if err != nil {
-			return fmt.Errorf("failed to get embedding: %w", err)
+			return "", 0, fmt.Errorf("failed to transcribe audio: %w", err)
 		}
-
-		// Ingest into KuzuDB
-		query, err := conn.Prepare("CREATE (d:Document {content: $content, embedding: $embedding})")
+		content = []byte(transcript)
+	}
+	// Read PDFs with the LLM provider, which marks where each page starts.
+	if pdf {
+		emit(StageReading, 0, 0)
+		text, err := i.llm.ExtractTextFromDocument(ctx, documentPrompt, content)
 		if err != nil {
-			return fmt.Errorf("failed to prepare query: %w", err)
+			return "", 0, fmt.Errorf("failed to read PDF: %w", err)
 		}
-		defer query.Destroy()
+		content = []byte(text)
+	}
+
+	texts, pages, rechunked, err := i.split(string(content), pdf)
+	if err != nil {
+		return "", 0, err
+	}
+	*overLimit = append(*overLimit, rechunked...)
 
-		params := map[string]interface{}{
-			"content":   chunk.PageContent,
-			"embedding": embedding,
+	// Embed chunks, several at a time
+	batchSize := i.opts.EmbeddingBatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultEmbeddingBatchSize
+	}
+	embeddings := embedding.WithBudget(i.embeddings, budget.FromContext(ctx))
+	vectors, fits, skipped, err := embedChunks(ctx, embeddings, i.limit, texts, batchSize, i.opts.EmbeddingConcurrency, func(done int) { emit(StageEmbedding, done, len(texts)) })
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to get embedding: %w", err)
+	}
+	*refused += len(skipped)
+	// Services that did not know the length of their vectors are checked now,
+	// before anything is extracted.
+	for n, vector := range vectors {
+		if vector != nil && len(vector) != i.dimensions {
+			return "", 0, fmt.Errorf("chunk %d: %s returns %d dimensions but the memory graph stores %d: use a model or provider with %d dimensions, switching to it with amg reindex", n, i.opts.EmbeddingProvider, len(vector), i.dimensions, i.dimensions)
+		}
+	}
+	for _, fit := range fits {
+		action := OverLimitTruncated
+		if fit.Strategy == embedding.LengthSplit {
+			action = OverLimitSplit
 		}
+		*overLimit = append(*overLimit, OverLimitChunk{Chunk: fit.Index, Tokens: fit.Tokens, Action: action})
+	}
+	chunks := make([]storage.Chunk, 0, len(texts))
+	offset := 0
+	for n, text := range texts {
+		start, end := locate(string(content), text, offset)
+		if start >= 0 {
+			offset = start + 1
+		}
+		chunk := storage.Chunk{
+			ID:          fmt.Sprintf("%s-%d", doc.ID, n),
+			DocumentID:  doc.ID,
+			Content:     text,
+			Index:       n,
+			StartOffset: start,
+			EndOffset:   end,
+		}
+		if pages != nil {
+			chunk.Page = pages[n]
+		}
+		storeVector(&chunk, vectors[n], i.opts.EmbeddingPrecision)
+		chunks = append(chunks, chunk)
+	}
 
-		_, err = conn.Execute(query, params)
+	// Extract graph info with LLM, several chunks at a time
+	if err := i.extractChunks(ctx, doc.Source, chunks, usage, cost, failures, emit); err != nil {
+		return "", 0, err
+	}
+
+	// Ingest into KuzuDB
+	emit(StageSaving, len(texts), len(texts))
+	if err := i.store.SaveDocument(ctx, doc, chunks); err != nil {
+		return "", 0, err
+	}
+	slog.Debug("ingested document", "source", doc.Source, "chunks", len(chunks))
+	return status, len(chunks), nil
+}
+
+// split chunks the text of a document, returning the chunks and, for the text of
+// a PDF with page markers, the page of each, counted from 1. The pages are chunked
+// one by one, so that no chunk spans two. Chunks over the embedding model's input
+// limit are rechunked when it fails them, and returned as the OverLimitChunks.
+func (i *Ingestor) split(text string, paged bool) ([]string, []int, []OverLimitChunk, error) {
+	sections := []string{text}
+	if paged {
+		sections = llm.SplitPages(text)
+	}
+	var texts []string
+	var pages []int
+	var rechunked []OverLimitChunk
+	for n, section := range sections {
+		split, err := i.splitter().SplitText(section)
 		if err != nil {
-			return fmt.Errorf("failed to execute query: %w", err)
+			return nil, nil, nil, fmt.Errorf("failed to split document: %w", err)
 		}
+		if i.limit != nil && i.limit.Strategy == embedding.LengthFail {
+			var replaced []OverLimitChunk
+			split, replaced = rechunk(*i.limit, split)
+			for _, chunk := range replaced {
+				chunk.Chunk += len(texts)
+				rechunked = append(rechunked, chunk)
+			}
+		}
+		texts = append(texts, split...)
+		if paged {
+			for range split {
+				pages = append(pages, n+1)
+			}
+		}
+	}
+	return texts, pages, rechunked, nil
+}
 
-		// Extract graph info with LLM
-		prompt := fmt.Sprintf("Extract entities and relationships from the following text:\n\n%s", chunk.PageContent)
-		graphInfo, err := llmService.GenerateText(context.Background(), prompt)
+// splitter returns the splitter of documents into chunks, of 512 characters or of
+// Options.ChunkTokens tokens, overlapping by a fifth.
+func (i *Ingestor) splitter() textsplitter.TextSplitter {
+	if i.opts.ChunkTokens <= 0 {
+		return textsplitter.NewRecursiveCharacter()
+	}
+	_, model := extractionModel(i.llm, i.opts)
+	if model == "" {
+		model = "unknown" // counted like any model without a known tokenizer
+	}
+	return textsplitter.NewRecursiveCharacter(
+		textsplitter.WithChunkSize(i.opts.ChunkTokens),
+		textsplitter.WithChunkOverlap(i.opts.ChunkTokens/5),
+		textsplitter.WithLenFunc(func(text string) int {
+			tokens, _ := llm.CountTokens(model, text)
+			return tokens
+		}),
+	)
+}
+
+// IngestFile chunks, embeds and stores a file in the memory graph located in dbDir,
+// giving up when ctx is done.
+func IngestFile(ctx context.Context, dbDir string, filePath string, opts Options) error {
+	sources, err := ExpandInputs([]string{filePath})
+	if err != nil {
+		return err
+	}
+	ingestor, err := NewIngestor(dbDir, opts)
+	if err != nil {
+		return err
+	}
+	defer ingestor.Close()
+
+	for _, source := range sources {
+		if result := ingestor.Ingest(ctx, source); result.Err != nil {
+			return result.Err
+		}
+	}
+	return nil
+}
+
+// load reads a document from a file path, an http(s) URL or stdin.
+func load(ctx context.Context, source string) ([]byte, error) {
+	switch {
+	case source == StdinSource:
+		return io.ReadAll(os.Stdin)
+	case isURL(source):
+		req, err := http.NewRequestWithContext(ctx, "GET", source, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := http.DefaultClient.Do(req)
 		if err != nil {
-			return fmt.Errorf("failed to extract graph info: %w", err)
+			return nil, err
 		}
-		fmt.Println("Graph Info:", graphInfo)
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("GET %s: %s", source, resp.Status)
+		}
+		return io.ReadAll(resp.Body)
+	default:
+		return os.ReadFile(source)
 	}
+}
 
-	return nil
+// audioTypes are the MIME types of the recordings, by file extension, that are
+// transcribed with the LLM provider before chunking.
+var audioTypes = map[string]string{
+	".mp3": "audio/mpeg",
+	".wav": "audio/wav",
+	".m4a": "audio/mp4",
+}
+
+// documentPrompt asks the LLM provider for the text of a PDF; the provider adds
+// the page markers llm.SplitPages splits on.
+const documentPrompt = "Transcribe all the text of this PDF document in reading order, keeping headings, lists and tables as Markdown. Reply with the text only."
+
+// documentID derives a stable document ID from its source.
+func documentID(source string) string {
+	sum := sha256.Sum256([]byte(source))
+	return hex.EncodeToString(sum[:8])
+}
+
+// contentHash fingerprints document content for change detection.
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// locate finds the byte range of chunk within content, searching from offset so that
+// overlapping chunks resolve to successive positions. It returns -1, -1 when the
+// splitter altered the text and it can no longer be found verbatim.
+func locate(content, chunk string, offset int) (int, int) {
+	if offset > len(content) {
+		offset = len(content)
+	}
+	i := strings.Index(content[offset:], chunk)
+	if i < 0 {
+		return -1, -1
+	}
+	start := offset + i
+	return start, start + len(chunk)
 }
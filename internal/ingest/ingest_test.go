@@ -0,0 +1,661 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/budget"
+	"github.com/sandwichlabs/agent-memory-graph/internal/embedding"
+	"github.com/sandwichlabs/agent-memory-graph/internal/llm"
+	"github.com/sandwichlabs/agent-memory-graph/internal/storage"
+)
+
+var mockProviders = Options{EmbeddingProvider: embedding.ProviderTestMock, LlmProvider: llm.ProviderTestMock}
+
+func writeDocument(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "notes.md")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write document: %v", err)
+	}
+	return path
+}
+
+func TestIngestFile_MockProviders(t *testing.T) {
+	dir := t.TempDir()
+	if err := IngestFile(context.Background(), dir, writeDocument(t, "Ada Lovelace worked with Charles Babbage."), mockProviders); err != nil {
+		t.Fatalf("IngestFile failed: %v", err)
+	}
+
+	store, err := storage.Open(dir, true)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+	stats, err := store.Stats(context.Background())
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.Documents != 1 || stats.Chunks != 1 || stats.Entities != 0 {
+		t.Errorf("Expected 1 document of 1 chunk without entities, got %+v", stats)
+	}
+}
+
+func TestIngestor_ExtractsWithScriptedLLM(t *testing.T) {
+	dir := t.TempDir()
+	ingestor, err := NewIngestor(dir, mockProviders)
+	if err != nil {
+		t.Fatalf("NewIngestor failed: %v", err)
+	}
+	defer ingestor.Close()
+	mock := &llm.MockLlmService{
+		Response: `{"entities": [{"name": "Ada Lovelace", "type": "PERSON"}, {"name": "Charles Babbage", "type": "PERSON"}],
+			"relationships": [{"subject": "Ada Lovelace", "predicate": "worked with", "object": "Charles Babbage"}]}`,
+		Usage: llm.Usage{TotalTokens: 42},
+	}
+	ingestor.llm = mock
+
+	result := ingestor.Ingest(context.Background(), writeDocument(t, "Ada Lovelace worked with Charles Babbage."))
+	if result.Err != nil {
+		t.Fatalf("Ingest failed: %v", result.Err)
+	}
+	if result.Usage.TotalTokens != 42 {
+		t.Errorf("Expected 42 tokens used, got %d", result.Usage.TotalTokens)
+	}
+	if prompts := mock.Prompts(); len(prompts) != 1 || !strings.Contains(prompts[0], "Ada Lovelace worked with Charles Babbage.") {
+		t.Errorf("Expected one extraction prompt quoting the chunk, got %q", prompts)
+	}
+
+	entities, err := ingestor.store.ListEntities(context.Background(), storage.EntityFilter{})
+	if err != nil {
+		t.Fatalf("ListEntities failed: %v", err)
+	}
+	if len(entities) != 2 || entities[0].RelationshipCount != 1 {
+		t.Errorf("Expected two related entities, got %+v", entities)
+	}
+}
+
+func TestIngestor_ExtractionModel(t *testing.T) {
+	var models []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Model string `json:"model"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		models = append(models, payload.Model)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{{"message": map[string]any{"content": `{"entities": [], "relationships": []}`}}},
+			"usage":   map[string]any{"prompt_tokens": 10, "completion_tokens": 2, "total_tokens": 12},
+		})
+	}))
+	defer server.Close()
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	service, err := llm.NewMistralLlmService(llm.WithBaseURL(server.URL), llm.WithChatModel("mistral-large-latest"))
+	if err != nil {
+		t.Fatalf("NewMistralLlmService failed: %v", err)
+	}
+
+	opts := mockProviders
+	opts.ExtractionModel = "tiny-extractor"
+	ingestor, err := NewIngestor(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("NewIngestor failed: %v", err)
+	}
+	defer ingestor.Close()
+	ingestor.llm = service
+
+	if result := ingestor.Ingest(context.Background(), writeDocument(t, "Ada Lovelace worked with Charles Babbage.")); result.Err != nil {
+		t.Fatalf("Ingest failed: %v", result.Err)
+	}
+	if len(models) != 1 || models[0] != "tiny-extractor" {
+		t.Errorf("Expected one extraction request to tiny-extractor, got %q", models)
+	}
+	if unpriced := ingestor.Costs().Unpriced(); len(unpriced) != 1 || unpriced[0] != "mistral/tiny-extractor" {
+		t.Errorf("Expected the extraction model's usage to be priced, got unpriced models %q", unpriced)
+	}
+	if service.ChatModel() != "mistral-large-latest" {
+		t.Errorf("Expected the chat model to be unchanged, got %q", service.ChatModel())
+	}
+}
+
+func TestIngestor_TranscribesAudio(t *testing.T) {
+	ingestor, err := NewIngestor(t.TempDir(), mockProviders)
+	if err != nil {
+		t.Fatalf("NewIngestor failed: %v", err)
+	}
+	defer ingestor.Close()
+	mock := &llm.MockLlmService{
+		Responses: []llm.MockResponse{{Text: "Ada Lovelace worked with Charles Babbage."}},
+		Response:  `{"entities": [{"name": "Ada Lovelace", "type": "PERSON"}], "relationships": []}`,
+	}
+	ingestor.llm = mock
+	recording := []byte{0xff, 0xfb, 0x90, 0x64, 0x00, 0xfe}
+	path := filepath.Join(t.TempDir(), "memo.MP3")
+	if err := os.WriteFile(path, recording, 0o644); err != nil {
+		t.Fatalf("Failed to write recording: %v", err)
+	}
+
+	result := ingestor.Ingest(context.Background(), path)
+	if result.Err != nil {
+		t.Fatalf("Ingest failed: %v", result.Err)
+	}
+	if audio := mock.Audio(); len(audio) != 1 || !bytes.Equal(audio[0], recording) {
+		t.Errorf("Expected the recording to be transcribed, got %d recordings", len(audio))
+	}
+	if prompts := mock.Prompts(); len(prompts) != 2 || !strings.Contains(prompts[1], "Ada Lovelace worked with Charles Babbage.") {
+		t.Errorf("Expected an extraction prompt quoting the transcript, got %q", prompts)
+	}
+
+	if result := ingestor.Ingest(context.Background(), path); result.Status != StatusUnchanged || len(mock.Audio()) != 1 {
+		t.Errorf("Expected an unchanged recording not to be transcribed again, got %s after %d transcriptions", result.Status, len(mock.Audio()))
+	}
+}
+
+func TestIngestor_ReadsPDFsPageByPage(t *testing.T) {
+	ingestor, err := NewIngestor(t.TempDir(), mockProviders)
+	if err != nil {
+		t.Fatalf("NewIngestor failed: %v", err)
+	}
+	defer ingestor.Close()
+	mock := &llm.MockLlmService{
+		Responses: []llm.MockResponse{{Text: llm.PageMarker(1) + "\nAda Lovelace wrote the first program.\n" + llm.PageMarker(2) + "\nCharles Babbage designed the Analytical Engine."}},
+		Response:  `{"entities": [], "relationships": []}`,
+	}
+	ingestor.llm = mock
+	pdf, err := os.ReadFile("testdata/two-pages.pdf")
+	if err != nil {
+		t.Fatalf("Failed to read the fixture PDF: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "history.PDF")
+	if err := os.WriteFile(path, pdf, 0o644); err != nil {
+		t.Fatalf("Failed to write PDF: %v", err)
+	}
+
+	result := ingestor.Ingest(context.Background(), path)
+	if result.Err != nil {
+		t.Fatalf("Ingest failed: %v", result.Err)
+	}
+	if documents := mock.Documents(); len(documents) != 1 || !bytes.Equal(documents[0], pdf) {
+		t.Errorf("Expected the PDF to be read, got %d documents", len(documents))
+	}
+	if result.Chunks != 2 {
+		t.Fatalf("Expected a chunk per page, got %d chunks", result.Chunks)
+	}
+	for query, page := range map[string]int{"Lovelace": 1, "Babbage": 2} {
+		hits, err := ingestor.store.KeywordSearch(context.Background(), query, 1, storage.ChunkFilter{})
+		if err != nil {
+			t.Fatalf("KeywordSearch failed: %v", err)
+		}
+		if len(hits) != 1 || hits[0].Page != page || strings.Contains(hits[0].Content, "[Page") {
+			t.Errorf("Expected the %s chunk on page %d without its marker, got %+v", query, page, hits)
+		}
+	}
+
+	if result := ingestor.Ingest(context.Background(), path); result.Status != StatusUnchanged || len(mock.Documents()) != 1 {
+		t.Errorf("Expected an unchanged PDF not to be read again, got %s after %d readings", result.Status, len(mock.Documents()))
+	}
+}
+
+func TestIngestor_HandlesLLMFailuresByClass(t *testing.T) {
+	defer func(delay time.Duration) { llmRetryDelay = delay }(llmRetryDelay)
+	llmRetryDelay = 0
+	ingestor, err := NewIngestor(t.TempDir(), mockProviders)
+	if err != nil {
+		t.Fatalf("NewIngestor failed: %v", err)
+	}
+	defer ingestor.Close()
+	extraction := `{"entities": [{"name": "Ada Lovelace", "type": "PERSON"}], "relationships": []}`
+
+	mock := &llm.MockLlmService{Response: extraction, Responses: []llm.MockResponse{{Err: fmt.Errorf("too long: %w", llm.ErrContextLengthExceeded)}}}
+	ingestor.llm = mock
+	if result := ingestor.Ingest(context.Background(), writeDocument(t, "A chunk too long for the model.")); result.Err != nil {
+		t.Errorf("Expected a chunk too long for the model to be stored without entities, got %v", result.Err)
+	}
+
+	mock = &llm.MockLlmService{Response: extraction, Responses: []llm.MockResponse{{Err: &llm.TruncatedError{Provider: "testing", Text: `{"entities": [`}}}}
+	ingestor.llm = mock
+	if result := ingestor.Ingest(context.Background(), writeDocument(t, "A chunk with too much to list.")); result.Err != nil || result.ExtractionFailures != 1 {
+		t.Errorf("Expected a truncated extraction to be stored without entities, got %v with %d failures", result.Err, result.ExtractionFailures)
+	}
+
+	mock = &llm.MockLlmService{Response: extraction, Responses: []llm.MockResponse{{Err: fmt.Errorf("slow down: %w", llm.ErrRateLimited)}}}
+	ingestor.llm = mock
+	if result := ingestor.Ingest(context.Background(), writeDocument(t, "Ada Lovelace wrote notes.")); result.Err != nil || mock.Calls() != 2 {
+		t.Errorf("Expected a throttled extraction to be retried, got %v after %d calls", result.Err, mock.Calls())
+	}
+
+	mock = &llm.MockLlmService{Err: fmt.Errorf("bad key: %w", llm.ErrUnauthorized)}
+	ingestor.llm = mock
+	report := ingestor.IngestAll(context.Background(), []string{writeDocument(t, "First."), writeDocument(t, "Second.")})
+	if report.Failed() != 2 || mock.Calls() != 1 || !strings.Contains(report.Results[1].Err.Error(), "not attempted") {
+		t.Errorf("Expected an unauthorized key to stop the batch after one call, got %d calls and %+v", mock.Calls(), report.Results)
+	}
+}
+
+func TestIngestor_StopsAtTheBudget(t *testing.T) {
+	defer func(delay time.Duration) { llmRetryDelay = delay }(llmRetryDelay)
+	llmRetryDelay = time.Millisecond
+	opts := mockProviders
+	opts.Budget = budget.Limits{MaxRetries: 1}
+	ingestor, err := NewIngestor(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("NewIngestor failed: %v", err)
+	}
+	defer ingestor.Close()
+	mock := &llm.MockLlmService{Err: fmt.Errorf("slow down: %w", llm.ErrRateLimited)}
+	ingestor.llm = mock
+
+	report := ingestor.IngestAll(context.Background(), []string{writeDocument(t, "First."), writeDocument(t, "Second.")})
+	if report.Failed() != 2 || !errors.Is(report.Results[0].Err, budget.ErrBudgetExhausted) || !strings.Contains(report.Results[1].Err.Error(), "not attempted") {
+		t.Fatalf("Expected the batch to stop once the budget ran out, got %+v", report.Results)
+	}
+	if mock.Calls() != 2 {
+		t.Errorf("Expected one retry of the throttled extraction, got %d calls", mock.Calls())
+	}
+	want := budget.State{Limits: opts.Budget, Retries: 1, Backoff: time.Millisecond, Exhausted: "1 retries used"}
+	if report.Budget != want {
+		t.Errorf("Expected the report to hold the final budget %+v, got %+v", want, report.Budget)
+	}
+}
+
+func TestIngestor_ExtractsChunksConcurrently(t *testing.T) {
+	opts := mockProviders
+	opts.LlmConcurrency = 3
+	ingestor, err := NewIngestor(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("NewIngestor failed: %v", err)
+	}
+	defer ingestor.Close()
+	mock := &llm.MockLlmService{
+		Response:  `{"entities": [{"name": "Ada Lovelace", "type": "PERSON"}], "relationships": []}`,
+		Responses: []llm.MockResponse{{Err: fmt.Errorf("refused: %w", llm.ErrContentFiltered)}},
+		Usage:     llm.Usage{TotalTokens: 10},
+	}
+	ingestor.llm = mock
+
+	paragraph := strings.Repeat("Ada Lovelace wrote the first published algorithm. ", 10)
+	result := ingestor.Ingest(context.Background(), writeDocument(t, strings.Repeat(paragraph+"\n\n", 8)))
+	if result.Err != nil {
+		t.Fatalf("Ingest failed: %v", result.Err)
+	}
+	if result.Chunks < 4 || mock.Calls() != result.Chunks {
+		t.Fatalf("Expected one extraction per chunk of a long document, got %d calls for %d chunks", mock.Calls(), result.Chunks)
+	}
+	if result.ExtractionFailures != 1 || result.Usage.TotalTokens != 10*(result.Chunks-1) {
+		t.Errorf("Expected one chunk without entities and the usage of the others, got %d and %+v", result.ExtractionFailures, result.Usage)
+	}
+}
+
+func TestIngestor_ChunksByTokens(t *testing.T) {
+	opts := mockProviders
+	opts.ChunkTokens = 50
+	ingestor, err := NewIngestor(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("NewIngestor failed: %v", err)
+	}
+	defer ingestor.Close()
+
+	document := strings.Repeat("Ada Lovelace wrote the first published algorithm for a machine. ", 40)
+	chunks, err := ingestor.splitter().SplitText(document)
+	if err != nil {
+		t.Fatalf("SplitText failed: %v", err)
+	}
+	if len(chunks) < 5 {
+		t.Errorf("Expected a document of about 440 tokens to need several chunks of 50, got %d", len(chunks))
+	}
+	for _, chunk := range chunks {
+		if tokens, _ := llm.CountTokens("mock", chunk); tokens > 50 {
+			t.Errorf("Expected chunks of at most 50 tokens, got %d", tokens)
+		}
+	}
+}
+
+// batchRecorder embeds like the mock service, remembering the size of each batch
+// and refusing any text containing "POISON".
+type batchRecorder struct {
+	embedding.Service
+	mu      sync.Mutex
+	batches []int
+}
+
+func (s *batchRecorder) GetEmbeddings(ctx context.Context, text string, embeddingType embedding.EmbeddingType) (embedding.EmbedResponse, error) {
+	if strings.Contains(text, "POISON") {
+		return nil, fmt.Errorf("%w: text too long", embedding.ErrInvalidInput)
+	}
+	return s.Service.GetEmbeddings(ctx, text, embeddingType)
+}
+
+func (s *batchRecorder) GetEmbeddingsBatch(ctx context.Context, texts []string, embeddingType embedding.EmbeddingType) ([]embedding.EmbedResponse, error) {
+	s.mu.Lock()
+	s.batches = append(s.batches, len(texts))
+	s.mu.Unlock()
+	for _, text := range texts {
+		if strings.Contains(text, "POISON") {
+			return nil, fmt.Errorf("%w: text too long", embedding.ErrInvalidInput)
+		}
+	}
+	return s.Service.GetEmbeddingsBatch(ctx, texts, embeddingType)
+}
+
+func TestIngestor_EmbedsInBatches(t *testing.T) {
+	opts := mockProviders
+	opts.EmbeddingBatchSize = 2
+	opts.EmbeddingConcurrency = 1
+	ingestor, err := NewIngestor(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("NewIngestor failed: %v", err)
+	}
+	defer ingestor.Close()
+	recorder := &batchRecorder{Service: embedding.NewMockService()}
+	ingestor.embeddings = recorder
+
+	paragraphs := make([]string, 5)
+	for n := range paragraphs {
+		paragraphs[n] = fmt.Sprintf("Paragraph %d. ", n) + strings.Repeat("Ada Lovelace worked with Charles Babbage. ", 10)
+	}
+	result := ingestor.Ingest(context.Background(), writeDocument(t, strings.Join(paragraphs, "\n\n")))
+	if result.Err != nil {
+		t.Fatalf("Ingest failed: %v", result.Err)
+	}
+	if result.Chunks != 5 || !reflect.DeepEqual(recorder.batches, []int{2, 2, 1}) {
+		t.Errorf("Expected 5 chunks embedded in batches of 2, 2 and 1, got %d chunks in %v", result.Chunks, recorder.batches)
+	}
+
+	// A bad chunk fails its batch, whose chunks are then embedded one at a time,
+	// and is stored without a vector.
+	recorder.batches = nil
+	paragraphs[3] = "POISON " + paragraphs[3]
+	result = ingestor.Ingest(context.Background(), writeDocument(t, strings.Join(paragraphs, "\n\n")))
+	if result.Err != nil || result.Chunks != 5 || result.EmbeddingFailures != 1 {
+		t.Fatalf("Expected 5 chunks stored with 1 embedding failure, got %d and %d (%v)", result.Chunks, result.EmbeddingFailures, result.Err)
+	}
+	if !reflect.DeepEqual(recorder.batches, []int{2, 2, 1}) {
+		t.Errorf("Expected every batch to be sent, got %v", recorder.batches)
+	}
+	missing, err := ingestor.store.CountChunksToEmbed(context.Background(), false)
+	if err != nil {
+		t.Fatalf("CountChunksToEmbed failed: %v", err)
+	}
+	if missing != 1 {
+		t.Errorf("Expected 1 chunk without a vector, got %d", missing)
+	}
+
+	// A document whose every chunk is refused fails.
+	result = ingestor.Ingest(context.Background(), writeDocument(t, "POISON "+paragraphs[0]))
+	if !errors.Is(result.Err, embedding.ErrInvalidInput) || !strings.Contains(result.Err.Error(), "refused every chunk") {
+		t.Errorf("Expected the document to fail as every chunk was refused, got %v", result.Err)
+	}
+}
+
+// unauthorizedService fails every request as a bad API key does.
+type unauthorizedService struct {
+	embedding.Service
+}
+
+func (unauthorizedService) GetEmbeddingsBatch(ctx context.Context, texts []string, embeddingType embedding.EmbeddingType) ([]embedding.EmbedResponse, error) {
+	return nil, &embedding.APIError{Provider: "mistral", StatusCode: http.StatusUnauthorized, Body: "Unauthorized", Class: embedding.ErrUnauthorized}
+}
+
+func TestIngestor_StopsOnUnauthorizedEmbeddings(t *testing.T) {
+	ingestor, err := NewIngestor(t.TempDir(), mockProviders)
+	if err != nil {
+		t.Fatalf("NewIngestor failed: %v", err)
+	}
+	defer ingestor.Close()
+	ingestor.embeddings = unauthorizedService{Service: embedding.NewMockService()}
+
+	report := ingestor.IngestAll(context.Background(), []string{writeDocument(t, "Ada Lovelace."), writeDocument(t, "Charles Babbage.")})
+	if report.Failed() != 2 || !errors.Is(report.Results[0].Err, embedding.ErrUnauthorized) {
+		t.Fatalf("Expected both sources to fail as unauthorized, got %v", report.Results)
+	}
+	if !strings.Contains(report.Results[1].Err.Error(), "not attempted") {
+		t.Errorf("Expected the second source not to be attempted, got %v", report.Results[1].Err)
+	}
+}
+
+func TestIngestor_CachesEmbeddings(t *testing.T) {
+	dir := t.TempDir()
+	ingestor, err := NewIngestor(dir, mockProviders)
+	if err != nil {
+		t.Fatalf("NewIngestor failed: %v", err)
+	}
+	defer ingestor.Close()
+	recorder := &batchRecorder{Service: embedding.NewMockService()}
+	ingestor.cache = embedding.NewCachedService(recorder, ingestor.cacheStore)
+	ingestor.embeddings = ingestor.cache
+
+	paragraphs := make([]string, 5)
+	for n := range paragraphs {
+		paragraphs[n] = fmt.Sprintf("Paragraph %d. ", n) + strings.Repeat("Ada Lovelace worked with Charles Babbage. ", 10)
+	}
+	source := writeDocument(t, strings.Join(paragraphs, "\n\n"))
+	report := ingestor.IngestAll(context.Background(), []string{source})
+	if report.Failed() != 0 || report.EmbeddingCache != (embedding.CacheStats{Misses: 5}) {
+		t.Fatalf("Expected 5 misses on the first ingest, got %+v (%v)", report.EmbeddingCache, report.Results)
+	}
+
+	// Editing one paragraph embeds only its chunk again.
+	paragraphs[2] = "Paragraph two, edited. " + strings.Repeat("Grace Hopper wrote the first compiler. ", 10)
+	if err := os.WriteFile(source, []byte(strings.Join(paragraphs, "\n\n")), 0o644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	recorder.batches = nil
+	report = ingestor.IngestAll(context.Background(), []string{source})
+	if report.Failed() != 0 || report.EmbeddingCache != (embedding.CacheStats{Hits: 4, Misses: 1}) {
+		t.Errorf("Expected 4 hits and 1 miss on re-ingesting, got %+v (%v)", report.EmbeddingCache, report.Results)
+	}
+	if !reflect.DeepEqual(recorder.batches, []int{1}) {
+		t.Errorf("Expected only the edited chunk to be embedded, got batches %v", recorder.batches)
+	}
+	if _, err := os.Stat(filepath.Join(dir, EmbeddingCacheFile)); err != nil {
+		t.Errorf("Expected the cache in the memory graph's directory, got %v", err)
+	}
+
+	opts := mockProviders
+	opts.NoEmbeddingCache = true
+	uncached, err := NewIngestor(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("NewIngestor failed: %v", err)
+	}
+	defer uncached.Close()
+	if uncached.cache != nil {
+		t.Errorf("Expected no cache with NoEmbeddingCache")
+	}
+}
+
+func TestIngestor_FitsChunksOverTheEmbeddingLimit(t *testing.T) {
+	ingestor, err := NewIngestor(t.TempDir(), mockProviders)
+	if err != nil {
+		t.Fatalf("NewIngestor failed: %v", err)
+	}
+	defer ingestor.Close()
+	recorder := &batchRecorder{Service: embedding.NewMockService()}
+	ingestor.embeddings = recorder
+	ingestor.limit = &embedding.Limit{Model: "mistral-embed", MaxTokens: 40, Strategy: embedding.LengthFail}
+
+	document := writeDocument(t, strings.Repeat("Ada Lovelace worked with Charles Babbage. ", 10))
+	result := ingestor.Ingest(context.Background(), document)
+	if result.Err != nil {
+		t.Fatalf("Ingest failed: %v", result.Err)
+	}
+	if result.Chunks < 2 || len(result.OverLimitChunks) != 1 || result.OverLimitChunks[0].Action != OverLimitRechunked {
+		t.Errorf("Expected the chunk re-chunked into several, got %d chunks and %+v", result.Chunks, result.OverLimitChunks)
+	}
+
+	ingestor.limit.Strategy = embedding.LengthSplit
+	result = ingestor.Ingest(context.Background(), writeDocument(t, strings.Repeat("Grace Hopper wrote the first compiler. ", 10)))
+	if result.Err != nil {
+		t.Fatalf("Ingest failed: %v", result.Err)
+	}
+	if result.Chunks != 1 || len(result.OverLimitChunks) != 1 || result.OverLimitChunks[0].Action != OverLimitSplit {
+		t.Errorf("Expected the chunk embedded in parts, got %d chunks and %+v", result.Chunks, result.OverLimitChunks)
+	}
+}
+
+func TestIngestor_StoresInt8Vectors(t *testing.T) {
+	dir := t.TempDir()
+	opts := mockProviders
+	opts.EmbeddingPrecision = embedding.PrecisionInt8
+	ingestor, err := NewIngestor(dir, opts)
+	if err != nil {
+		t.Fatalf("NewIngestor failed: %v", err)
+	}
+	const text = "Ada Lovelace worked with Charles Babbage."
+	if result := ingestor.Ingest(context.Background(), writeDocument(t, text)); result.Err != nil {
+		t.Fatalf("Ingest failed: %v", result.Err)
+	}
+	ingestor.Close()
+
+	// Later ingests keep the recorded precision.
+	ingestor, err = NewIngestor(dir, mockProviders)
+	if err != nil {
+		t.Fatalf("NewIngestor failed: %v", err)
+	}
+	if ingestor.opts.EmbeddingPrecision != embedding.PrecisionInt8 {
+		t.Errorf("Expected the recorded int8 precision, got %q", ingestor.opts.EmbeddingPrecision)
+	}
+	ingestor.Close()
+
+	store, err := storage.Open(dir, true)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+	if precision, err := store.EmbeddingPrecision(context.Background()); err != nil || precision != "int8" {
+		t.Errorf("Expected int8 recorded, got %q (%v)", precision, err)
+	}
+	hits, err := store.SimilaritySearch(context.Background(), embedding.MockVector(text, embedding.DefaultMockDimensions), 1, storage.ChunkFilter{})
+	if err != nil {
+		t.Fatalf("SimilaritySearch failed: %v", err)
+	}
+	if len(hits) != 1 || hits[0].Score < 0.999 {
+		t.Errorf("Expected the chunk to match its own text despite quantization, got %+v", hits)
+	}
+}
+
+func TestNewIngestor_ChecksEmbeddingDimensions(t *testing.T) {
+	dir := t.TempDir()
+	store, err := storage.Open(dir, false, storage.WithEmbeddingDimensions(384))
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	store.Close()
+
+	_, err = NewIngestor(dir, mockProviders)
+	if err == nil || !strings.Contains(err.Error(), "into 768 dimensions but the memory graph stores 384") || !strings.Contains(err.Error(), "amg reindex") {
+		t.Fatalf("Expected the mismatch refused before ingesting, got %v", err)
+	}
+
+	ingestor, err := NewIngestor(t.TempDir(), mockProviders)
+	if err != nil {
+		t.Fatalf("Expected a new memory graph sized for the provider, got %v", err)
+	}
+	defer ingestor.Close()
+	if ingestor.dimensions != embedding.DefaultMockDimensions {
+		t.Errorf("Expected %d dimensions, got %d", embedding.DefaultMockDimensions, ingestor.dimensions)
+	}
+
+	// A service that could not tell its length beforehand is caught before
+	// anything is extracted or stored.
+	ingestor.embeddings = embedding.NewMockService(embedding.WithMockDimensions(384))
+	result := ingestor.Ingest(context.Background(), writeDocument(t, "Ada Lovelace worked with Charles Babbage."))
+	if result.Err == nil || !strings.Contains(result.Err.Error(), "returns 384 dimensions but the memory graph stores 768") {
+		t.Errorf("Expected the vectors' length refused, got %v", result.Err)
+	}
+}
+
+func TestIngestor_EmbedsBatchesConcurrently(t *testing.T) {
+	const latency = 50 * time.Millisecond
+	opts := mockProviders
+	opts.EmbeddingBatchSize = 1
+	opts.EmbeddingConcurrency = 3
+	opts.NoEmbeddingCache = true
+	dir := t.TempDir()
+	ingestor, err := NewIngestor(dir, opts)
+	if err != nil {
+		t.Fatalf("NewIngestor failed: %v", err)
+	}
+	ingestor.embeddings = embedding.NewMockService(embedding.WithMockLatency(latency))
+
+	paragraphs := make([]string, 6)
+	for n := range paragraphs {
+		paragraphs[n] = fmt.Sprintf("Paragraph %d. ", n) + strings.Repeat("Ada Lovelace worked with Charles Babbage. ", 10)
+	}
+	start := time.Now()
+	result := ingestor.Ingest(context.Background(), writeDocument(t, strings.Join(paragraphs, "\n\n")))
+	elapsed := time.Since(start)
+	ingestor.Close()
+	if result.Err != nil || result.Chunks != 6 {
+		t.Fatalf("Expected 6 chunks ingested, got %d (%v)", result.Chunks, result.Err)
+	}
+	if elapsed >= 4*latency {
+		t.Errorf("Expected 6 batches of %v, 3 at once, to take about %v, took %v", latency, 2*latency, elapsed)
+	}
+
+	store, err := storage.Open(dir, true)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+	for _, paragraph := range paragraphs {
+		hits, err := store.SimilaritySearch(context.Background(), embedding.MockVector(strings.TrimSpace(paragraph), embedding.DefaultMockDimensions), 1, storage.ChunkFilter{})
+		if err != nil {
+			t.Fatalf("SimilaritySearch failed: %v", err)
+		}
+		if len(hits) != 1 || hits[0].Content != strings.TrimSpace(paragraph) || hits[0].Score < 0.999 {
+			t.Errorf("Expected each chunk stored with its own vector, got %+v", hits)
+		}
+	}
+}
+
+// billedService embeds like the mock service, reporting a token billed per text.
+type billedService struct {
+	embedding.Service
+	tokens atomic.Int64
+}
+
+func (s *billedService) GetEmbeddingsBatch(ctx context.Context, texts []string, embeddingType embedding.EmbeddingType) ([]embedding.EmbedResponse, error) {
+	s.tokens.Add(int64(len(texts)))
+	return s.Service.GetEmbeddingsBatch(ctx, texts, embeddingType)
+}
+
+func (s *billedService) Usage() embedding.Usage {
+	return embedding.Usage{PromptTokens: s.tokens.Load()}
+}
+
+func TestIngestor_ReportsEmbeddingUsage(t *testing.T) {
+	opts := mockProviders
+	opts.NoEmbeddingCache = true
+	ingestor, err := NewIngestor(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("NewIngestor failed: %v", err)
+	}
+	defer ingestor.Close()
+
+	report := ingestor.IngestAll(context.Background(), []string{writeDocument(t, "First.")})
+	if report.Failed() != 0 || !report.EmbeddingUsage.Unknown {
+		t.Errorf("Expected the usage of the mock to be unknown, got %+v (%+v)", report.EmbeddingUsage, report.Results)
+	}
+
+	billed := &billedService{Service: embedding.NewMockService()}
+	billed.tokens.Store(100)
+	ingestor.embeddings = billed
+	report = ingestor.IngestAll(context.Background(), []string{writeDocument(t, "Second."), writeDocument(t, "Third.")})
+	if report.Failed() != 0 || report.EmbeddingUsage != (embedding.Usage{PromptTokens: int64(report.Chunks())}) {
+		t.Errorf("Expected a token billed for each of the %d chunks of the batch, got %+v", report.Chunks(), report.EmbeddingUsage)
+	}
+}
@@ -0,0 +1,115 @@
+package ingest
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/storage"
+)
+
+// StdinSource is the argument that reads a document from standard input.
+const StdinSource = "-"
+
+// ExpandInputs resolves command line arguments into a deduplicated list of sources.
+// Each argument may be a file, a directory (walked recursively), a glob pattern,
+// an http(s) URL or "-" for stdin. Files are returned as absolute paths in the
+// order they were first seen. Arguments that match nothing are reported together
+// in a single error.
+func ExpandInputs(args []string) ([]string, error) {
+	var sources []string
+	var unmatched []string
+	seen := make(map[string]bool)
+	add := func(source string) {
+		if !seen[source] {
+			seen[source] = true
+			sources = append(sources, source)
+		}
+	}
+
+	for _, arg := range args {
+		if arg == StdinSource || isURL(arg) {
+			add(arg)
+			continue
+		}
+
+		paths := []string{arg}
+		if strings.ContainsAny(arg, "*?[") {
+			matches, err := filepath.Glob(arg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid glob pattern %q: %w", arg, err)
+			}
+			paths = matches
+		}
+
+		matched := 0
+		for _, path := range paths {
+			files, err := expandPath(path)
+			if err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				return nil, err
+			}
+			for _, file := range files {
+				add(file)
+				matched++
+			}
+		}
+		if matched == 0 {
+			unmatched = append(unmatched, arg)
+		}
+	}
+
+	if len(unmatched) > 0 {
+		return nil, fmt.Errorf("no files matched: %s", strings.Join(unmatched, ", "))
+	}
+	return sources, nil
+}
+
+// expandPath returns the absolute path of a file, or every regular file under a
+// directory in lexical order. Hidden entries and the memory graph database itself
+// are skipped when walking directories.
+func expandPath(path string) ([]string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve path %s: %w", path, err)
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{abs}, nil
+	}
+
+	var files []string
+	err = filepath.WalkDir(abs, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if p != abs && strings.HasPrefix(name, ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() && !strings.HasPrefix(name, storage.DatabaseFile) {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk directory %s: %w", path, err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func isURL(arg string) bool {
+	return strings.HasPrefix(arg, "http://") || strings.HasPrefix(arg, "https://")
+}
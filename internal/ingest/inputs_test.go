@@ -0,0 +1,66 @@
+package ingest
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+}
+
+func TestExpandInputs_DirectoriesGlobsAndDedup(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "a.md", "b.txt", "notes/c.md", ".hidden/d.md", "amg.db")
+
+	sources, err := ExpandInputs([]string{
+		filepath.Join(dir, "*.md"),
+		dir,
+		filepath.Join(dir, "a.md"),
+		"-",
+		"https://example.com/doc.txt",
+	})
+	if err != nil {
+		t.Fatalf("ExpandInputs failed: %v", err)
+	}
+
+	expected := []string{
+		filepath.Join(dir, "a.md"),
+		filepath.Join(dir, "b.txt"),
+		filepath.Join(dir, "notes", "c.md"),
+		"-",
+		"https://example.com/doc.txt",
+	}
+	if !reflect.DeepEqual(sources, expected) {
+		t.Errorf("Expected sources %v, got %v", expected, sources)
+	}
+}
+
+func TestExpandInputs_UnmatchedPatterns(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "a.md")
+
+	_, err := ExpandInputs([]string{
+		filepath.Join(dir, "a.md"),
+		filepath.Join(dir, "*.pdf"),
+		filepath.Join(dir, "missing.txt"),
+	})
+	if err == nil {
+		t.Fatalf("Expected an error for unmatched patterns, got nil")
+	}
+	if !strings.Contains(err.Error(), "*.pdf") || !strings.Contains(err.Error(), "missing.txt") {
+		t.Errorf("Expected error to list both unmatched patterns, got: %v", err)
+	}
+}
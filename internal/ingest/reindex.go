@@ -0,0 +1,158 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/embedding"
+	"github.com/sandwichlabs/agent-memory-graph/internal/storage"
+)
+
+// DefaultReindexBatchSize is the number of chunks embedded and written per transaction.
+const DefaultReindexBatchSize = 64
+
+// ReindexOptions configures Reindex. Vectors are stored in the memory graph's
+// embedding precision.
+type ReindexOptions struct {
+	EmbeddingProvider embedding.Provider
+	// Model, when set, is the provider's model to embed with instead of the one
+	// its environment variable names, see embedding.NewWithModel.
+	Model string
+	// OnlyMissing embeds only chunks without a vector, keeping existing vectors.
+	OnlyMissing bool
+	BatchSize   int
+	// Concurrency is how many chunks of a batch are embedded at once;
+	// embedding.DefaultConcurrency when zero.
+	Concurrency int
+	// Progress, when set, is called after each batch with the number of chunks
+	// embedded so far and the total for this run.
+	Progress func(done, total int)
+}
+
+// Reindex re-embeds the chunks of the memory graph in dbDir with a new provider or
+// model, whose vectors may be of another size than the stored ones. New vectors
+// are staged next to the old ones and swapped in together, with the provider and
+// model they came from, once every chunk has one, so searches keep working while
+// it runs. Each batch is committed, so an interrupted reindex resumes where it
+// stopped when run again with the same provider and model.
+func Reindex(ctx context.Context, dbDir string, opts ReindexOptions) error {
+	service, err := embedding.NewWithModel(opts.EmbeddingProvider, opts.Model)
+	if err != nil {
+		return fmt.Errorf("failed to create embedding service: %w", err)
+	}
+	return reindex(ctx, dbDir, service, opts)
+}
+
+// reindex is Reindex with service.
+func reindex(ctx context.Context, dbDir string, service embedding.Service, opts ReindexOptions) error {
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultReindexBatchSize
+	}
+	store, err := storage.Open(dbDir, false, storage.WithEmbeddingDimensions(embedding.DimensionsOf(service)))
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	dimensions, err := store.StoredEmbeddingDimensions(ctx)
+	if err != nil {
+		return err
+	}
+	precision, err := embeddingPrecision(ctx, store, "")
+	if err != nil {
+		return err
+	}
+
+	pending := !opts.OnlyMissing
+	if opts.OnlyMissing {
+		if err := embedding.CheckDimensions(service, dimensions); err != nil {
+			return err
+		}
+		if err := checkEmbeddingProvider(ctx, store, opts.EmbeddingProvider, embedding.ModelOf(service)); err != nil {
+			return err
+		}
+	} else {
+		if dimensions, err = vectorLength(ctx, service); err != nil {
+			return err
+		}
+		resumed, err := store.BeginReindex(ctx, string(opts.EmbeddingProvider), embedding.ModelOf(service), dimensions)
+		if err != nil {
+			return err
+		}
+		if resumed {
+			slog.InfoContext(ctx, "resuming unfinished reindex", "provider", opts.EmbeddingProvider, "model", embedding.ModelOf(service))
+		}
+	}
+
+	total, err := store.CountChunksToEmbed(ctx, pending)
+	if err != nil {
+		return err
+	}
+	done := 0
+	after := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("reindex interrupted after %d of %d chunks (run it again to resume): %w", done, total, err)
+		}
+		chunks, err := store.ChunksToEmbed(ctx, pending, after, batchSize)
+		if err != nil {
+			return err
+		}
+		if len(chunks) == 0 {
+			break
+		}
+		texts := make([]string, len(chunks))
+		for n, chunk := range chunks {
+			texts[n] = chunk.Content
+		}
+		vectors, err := embedding.EmbedAll(ctx, service, texts, opts.Concurrency)
+		var failed *embedding.EmbedAllError
+		if errors.As(err, &failed) {
+			for n, err := range failed.Errs {
+				if err != nil {
+					return fmt.Errorf("failed to embed chunk %s: %w", chunks[n].ID, err)
+				}
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("reindex interrupted after %d of %d chunks (run it again to resume): %w", done, total, err)
+		}
+		for n, vector := range vectors {
+			if len(vector) != dimensions {
+				return fmt.Errorf("%s returns %d dimensions but the memory graph stores %d", opts.EmbeddingProvider, len(vector), dimensions)
+			}
+			storeVector(&chunks[n], vector, precision)
+		}
+		if err := store.SaveEmbeddings(ctx, chunks, pending); err != nil {
+			return err
+		}
+		done += len(chunks)
+		after = chunks[len(chunks)-1].ID
+		if opts.Progress != nil {
+			opts.Progress(done, total)
+		}
+	}
+
+	if pending {
+		if err := store.FinishReindex(ctx); err != nil {
+			return err
+		}
+	}
+	slog.InfoContext(ctx, "reindex complete", "provider", opts.EmbeddingProvider, "model", embedding.ModelOf(service), "chunks", done, "dimensions", dimensions)
+	return nil
+}
+
+// vectorLength returns the length of service's vectors, embedding a probe text
+// when the service does not know it before its first request.
+func vectorLength(ctx context.Context, service embedding.Service) (int, error) {
+	if dimensions := embedding.DimensionsOf(service); dimensions > 0 {
+		return dimensions, nil
+	}
+	vector, err := service.GetEmbeddings(ctx, "dimensions", embedding.EmbeddingTypeRetrievalDocument)
+	if err != nil {
+		return 0, fmt.Errorf("failed to learn the length of %s vectors: %w", service.GetType(), err)
+	}
+	return len(vector), nil
+}
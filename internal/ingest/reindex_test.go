@@ -0,0 +1,175 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/embedding"
+	"github.com/sandwichlabs/agent-memory-graph/internal/storage"
+)
+
+// seedChunks stores n chunks with zero vectors embedded by provider.
+func seedChunks(t *testing.T, dir string, provider string, n int) {
+	t.Helper()
+	store, err := storage.Open(dir, false)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	var chunks []storage.Chunk
+	for i := 0; i < n; i++ {
+		chunks = append(chunks, storage.Chunk{
+			ID:         fmt.Sprintf("doc-%02d", i),
+			DocumentID: "doc",
+			Content:    fmt.Sprintf("chunk %d", i),
+			Index:      i,
+			Embedding:  make([]float32, storage.EmbeddingDimensions),
+		})
+	}
+	ctx := context.Background()
+	if err := store.SaveDocument(ctx, storage.Document{ID: "doc", Source: "doc.md"}, chunks); err != nil {
+		t.Fatalf("Failed to save document: %v", err)
+	}
+	if err := store.SetEmbeddingProvider(ctx, provider); err != nil {
+		t.Fatalf("Failed to record provider: %v", err)
+	}
+}
+
+func TestReindex_ResumesAndSwaps(t *testing.T) {
+	dir := t.TempDir()
+	seedChunks(t, dir, "mistral", 5)
+
+	// Interrupt after the first batch of two.
+	ctx, cancel := context.WithCancel(context.Background())
+	err := Reindex(ctx, dir, ReindexOptions{
+		EmbeddingProvider: embedding.ProviderTestMock,
+		BatchSize:         2,
+		Progress:          func(done, total int) { cancel() },
+	})
+	if err == nil {
+		t.Fatal("Expected the interrupted reindex to fail, got nil")
+	}
+
+	store, err := storage.Open(dir, true)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	provider, _ := store.EmbeddingProvider(context.Background())
+	remaining, _ := store.CountChunksToEmbed(context.Background(), true)
+	store.Close()
+	if provider != "mistral" {
+		t.Errorf("Expected the old provider to remain until the swap, got %q", provider)
+	}
+	if remaining != 3 {
+		t.Errorf("Expected 3 chunks left to embed, got %d", remaining)
+	}
+
+	var progress []int
+	err = Reindex(context.Background(), dir, ReindexOptions{
+		EmbeddingProvider: embedding.ProviderTestMock,
+		BatchSize:         2,
+		Progress:          func(done, total int) { progress = append(progress, done, total) },
+	})
+	if err != nil {
+		t.Fatalf("Reindex failed: %v", err)
+	}
+	if fmt.Sprint(progress) != "[2 3 3 3]" {
+		t.Errorf("Expected the resumed run to embed the 3 remaining chunks, got progress %v", progress)
+	}
+
+	store, err = storage.Open(dir, true)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+	provider, _ = store.EmbeddingProvider(context.Background())
+	if provider != string(embedding.ProviderTestMock) {
+		t.Errorf("Expected provider %q after the swap, got %q", embedding.ProviderTestMock, provider)
+	}
+	// The mock embeds each chunk's content as its own vector.
+	for i := 0; i < 5; i++ {
+		query, _ := embedding.NewMockService().GetEmbeddings(context.Background(), fmt.Sprintf("chunk %d", i), embedding.EmbeddingTypeRetrievalQuery)
+		hits, err := store.SimilaritySearch(context.Background(), query, 1, storage.ChunkFilter{})
+		if err != nil {
+			t.Fatalf("SimilaritySearch failed: %v", err)
+		}
+		if id := fmt.Sprintf("doc-%02d", i); len(hits) != 1 || hits[0].ID != id || hits[0].Score < 0.999 {
+			t.Errorf("Expected chunk %s to carry the new vector, got %+v", id, hits)
+		}
+	}
+}
+
+func TestReindex_ChangesDimensions(t *testing.T) {
+	dir := t.TempDir()
+	seedChunks(t, dir, "mistral", 3)
+	store, err := storage.Open(dir, false)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	if err := store.SetEmbeddingPrecision(context.Background(), string(embedding.PrecisionInt8)); err != nil {
+		t.Fatalf("Failed to record precision: %v", err)
+	}
+	store.Close()
+
+	service := embedding.NewMockService(embedding.WithMockDimensions(16))
+	if err := reindex(context.Background(), dir, service, ReindexOptions{EmbeddingProvider: embedding.ProviderTestMock, BatchSize: 2}); err != nil {
+		t.Fatalf("Reindex failed: %v", err)
+	}
+
+	store, err = storage.Open(dir, true)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+	if dimensions, _ := store.StoredEmbeddingDimensions(ctx); dimensions != 16 {
+		t.Errorf("Expected the embedding column resized to 16 dimensions, got %d", dimensions)
+	}
+	if provider, _ := store.EmbeddingProvider(ctx); provider != string(embedding.ProviderTestMock) {
+		t.Errorf("Expected provider %q after the swap, got %q", embedding.ProviderTestMock, provider)
+	}
+	for i := 0; i < 3; i++ {
+		id := fmt.Sprintf("doc-%02d", i)
+		query, _ := service.GetEmbeddings(ctx, fmt.Sprintf("chunk %d", i), embedding.EmbeddingTypeRetrievalQuery)
+		hits, err := store.SimilaritySearch(ctx, query, 1, storage.ChunkFilter{})
+		if err != nil {
+			t.Fatalf("SimilaritySearch failed: %v", err)
+		}
+		if len(hits) != 1 || hits[0].ID != id || hits[0].Score < 0.99 {
+			t.Errorf("Expected chunk %s to carry the new vector, got %+v", id, hits)
+		}
+		// Int8 vectors come back dequantized, close to but not the embedded ones.
+		vectors, _ := store.ChunkEmbeddings(ctx, []string{id})
+		if fmt.Sprint(vectors[id]) == fmt.Sprint(query) {
+			t.Errorf("Expected chunk %s to be stored in int8, the graph's precision", id)
+		}
+	}
+}
+
+func TestCheckEmbeddingProvider_RecordsModel(t *testing.T) {
+	store, err := storage.Open(t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+
+	if err := checkEmbeddingProvider(ctx, store, embedding.ProviderMistral, "mistral-embed"); err != nil {
+		t.Fatalf("Expected a new graph to take the provider, got %v", err)
+	}
+	if model, _ := store.EmbeddingModel(ctx); model != "mistral-embed" {
+		t.Errorf("Expected the model recorded, got %q", model)
+	}
+	if err := checkEmbeddingProvider(ctx, store, embedding.ProviderMistral, "mistral-embed"); err != nil {
+		t.Errorf("Expected the same model to be accepted, got %v", err)
+	}
+	if err := checkEmbeddingProvider(ctx, store, embedding.ProviderMistral, "codestral-embed"); err == nil || !strings.Contains(err.Error(), "mistral model mistral-embed, not codestral-embed") {
+		t.Errorf("Expected another model to be refused, got %v", err)
+	}
+	if err := checkEmbeddingProvider(ctx, store, embedding.ProviderGemini, "gemini-embedding-001"); err == nil || !strings.Contains(err.Error(), "embedded with mistral, not gemini") {
+		t.Errorf("Expected another provider to be refused, got %v", err)
+	}
+}
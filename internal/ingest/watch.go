@@ -0,0 +1,135 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// DefaultWatchInterval is how often Watch rescans the directory when no interval is given.
+const DefaultWatchInterval = 2 * time.Second
+
+// WatchOptions configures Watch.
+type WatchOptions struct {
+	// Include and Exclude are glob patterns matched against each file's path relative
+	// to the watched directory and against its base name. A file is watched when it
+	// matches any Include pattern (or Include is empty) and no Exclude pattern.
+	Include  []string
+	Exclude  []string
+	Interval time.Duration
+}
+
+// fileState is what Watch remembers about a file between scans.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// Watch ingests every matching file under dir and then polls the directory,
+// re-ingesting files that change and removing files that are deleted, until ctx is
+// cancelled. Each outcome other than StatusUnchanged is passed to onResult. As with
+// IngestAll, a failing file is reported and watching continues.
+func (i *Ingestor) Watch(ctx context.Context, dir string, opts WatchOptions, onResult func(Result)) error {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve path %s: %w", dir, err)
+	}
+	for _, pattern := range append(append([]string{}, opts.Include...), opts.Exclude...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	report := func(result Result) {
+		if result.Status != StatusUnchanged {
+			onResult(result)
+		}
+	}
+
+	known := make(map[string]fileState)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		current, err := scan(root, opts)
+		if err != nil {
+			return err
+		}
+		for _, path := range sortedKeys(current) {
+			if previous, ok := known[path]; ok && previous == current[path] {
+				continue
+			}
+			report(i.Ingest(ctx, path))
+			if ctx.Err() != nil {
+				return nil
+			}
+		}
+		for _, path := range sortedKeys(known) {
+			if _, ok := current[path]; !ok {
+				report(i.Remove(ctx, path))
+			}
+		}
+		known = current
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// scan lists the watched files under root with their modification times and sizes.
+func scan(root string, opts WatchOptions) (map[string]fileState, error) {
+	files, err := expandPath(root)
+	if err != nil {
+		return nil, err
+	}
+	states := make(map[string]fileState, len(files))
+	for _, path := range files {
+		rel, _ := filepath.Rel(root, path)
+		if !watched(rel, opts) {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			// Deleted between the walk and the stat; the next scan will notice.
+			continue
+		}
+		states[path] = fileState{modTime: info.ModTime(), size: info.Size()}
+	}
+	return states, nil
+}
+
+func watched(rel string, opts WatchOptions) bool {
+	matches := func(patterns []string) bool {
+		for _, pattern := range patterns {
+			if ok, _ := filepath.Match(pattern, rel); ok {
+				return true
+			}
+			if ok, _ := filepath.Match(pattern, filepath.Base(rel)); ok {
+				return true
+			}
+		}
+		return false
+	}
+	if len(opts.Include) > 0 && !matches(opts.Include) {
+		return false
+	}
+	return !matches(opts.Exclude)
+}
+
+func sortedKeys(m map[string]fileState) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
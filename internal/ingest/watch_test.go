@@ -0,0 +1,22 @@
+package ingest
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestScan_IncludeExclude(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "a.md", "b.txt", "notes/c.md", "notes/draft.md", ".hidden/d.md")
+
+	states, err := scan(dir, WatchOptions{Include: []string{"*.md"}, Exclude: []string{"notes/draft.md"}})
+	if err != nil {
+		t.Fatalf("scan failed: %v", err)
+	}
+
+	expected := []string{filepath.Join(dir, "a.md"), filepath.Join(dir, "notes", "c.md")}
+	if got := sortedKeys(states); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
@@ -0,0 +1,387 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/budget"
+	"github.com/sandwichlabs/agent-memory-graph/internal/httpclient"
+	"github.com/sandwichlabs/agent-memory-graph/internal/metrics"
+	"github.com/sandwichlabs/agent-memory-graph/internal/redact"
+)
+
+// anthropicVersion is the Messages API version sent with every request.
+const anthropicVersion = "2023-06-01"
+
+// AnthropicLlmService implements the LlmService interface using the Anthropic
+// Messages API.
+type AnthropicLlmService struct {
+	apiKey     string
+	HTTPClient *http.Client // Exported for testing
+	// ChatModel generates text and MultimodalModel reads images; both default to
+	// current Claude models and may be overridden after construction.
+	ChatModel       string
+	MultimodalModel string
+	APIBaseURL      string // Exported for testing
+	// MaxDocumentBytes caps the PDFs ExtractTextFromDocument sends; zero
+	// disables the cap.
+	MaxDocumentBytes int
+	// Metrics receives a measurement of every request; metrics.Default unless
+	// replaced.
+	Metrics metrics.Sink
+	// Breaker fails requests fast while the provider is down; nil disables it.
+	Breaker *CircuitBreaker
+}
+
+// NewAnthropicLlmService creates a new instance of AnthropicLlmService.
+// It requires the API key to be set in the ANTHROPIC_API_KEY environment variable.
+func NewAnthropicLlmService() (*AnthropicLlmService, error) {
+	apiKey := os.Getenv("ANTHROPIC_API_KEY")
+	if apiKey == "" {
+		return nil, fmt.Errorf("ANTHROPIC_API_KEY environment variable not set")
+	}
+
+	client, err := httpclient.FromEnv()
+	if err != nil {
+		return nil, err
+	}
+	breaker, err := circuitBreakerFromEnv("anthropic")
+	if err != nil {
+		return nil, err
+	}
+	return &AnthropicLlmService{
+		apiKey:           apiKey,
+		HTTPClient:       client,
+		ChatModel:        "claude-haiku-4-5",
+		MultimodalModel:  "claude-sonnet-4-5",
+		APIBaseURL:       "https://api.anthropic.com/v1",
+		MaxDocumentBytes: DefaultMaxDocumentBytes,
+		Metrics:          metrics.Default,
+		Breaker:          breaker,
+	}, nil
+}
+
+// GenerateText generates text using the Anthropic Messages API.
+func (s *AnthropicLlmService) GenerateText(ctx context.Context, prompt string, opts ...GenerateOption) (string, error) {
+	content, _, err := s.GenerateTextWithUsage(ctx, prompt, opts...)
+	return content, err
+}
+
+// Ping lists the models of the Anthropic API with the service's key.
+func (s *AnthropicLlmService) Ping(ctx context.Context) error {
+	return ping(ctx, s.HTTPClient, "anthropic", s.APIBaseURL+"/models", func(req *http.Request) {
+		req.Header.Set("X-Api-Key", s.apiKey)
+		req.Header.Set("Anthropic-Version", anthropicVersion)
+	})
+}
+
+// GenerateTextWithUsage generates text like GenerateText, also returning the
+// token usage the API reports.
+func (s *AnthropicLlmService) GenerateTextWithUsage(ctx context.Context, prompt string, opts ...GenerateOption) (string, Usage, error) {
+	return s.converse(ctx, []Message{{Role: RoleUser, Content: prompt}}, opts)
+}
+
+// Chat generates the assistant's reply to a conversation, sending the system
+// messages as the system prompt.
+func (s *AnthropicLlmService) Chat(ctx context.Context, messages []Message, opts ...GenerateOption) (string, error) {
+	content, _, err := s.converse(ctx, messages, opts)
+	return content, err
+}
+
+// converse generates the reply to messages, returning the usage the API reports.
+func (s *AnthropicLlmService) converse(ctx context.Context, messages []Message, opts []GenerateOption) (string, Usage, error) {
+	slog.InfoContext(ctx, "AnthropicLlmService: Chat called", "model", s.ChatModel, "messages", len(messages))
+
+	if err := checkMessages(messages); err != nil {
+		return "", Usage{}, err
+	}
+	system, turns := splitSystem(messages)
+	requestPayload := generation(defaultTemperature, defaultMaxTokens, opts).anthropicMessages(map[string]interface{}{
+		"model":    s.ChatModel,
+		"messages": chatMessages(turns),
+	})
+	if system != "" {
+		requestPayload["system"] = system
+	}
+
+	content, usage, err := s.complete(ctx, requestPayload, "")
+	if err != nil {
+		return "", Usage{}, err
+	}
+	slog.InfoContext(ctx, "AnthropicLlmService: Text generated successfully", "response_length", len(content), "total_tokens", usage.TotalTokens)
+	return content, usage, nil
+}
+
+// GenerateTextStream generates text like GenerateText, reading the text deltas
+// of a streamed message.
+func (s *AnthropicLlmService) GenerateTextStream(ctx context.Context, prompt string, opts ...GenerateOption) (<-chan string, <-chan error) {
+	slog.InfoContext(ctx, "AnthropicLlmService: GenerateTextStream called", "model", s.ChatModel, "prompt_length", len(prompt))
+
+	return streamText(ctx, streamCall{provider: "anthropic", metrics: s.Metrics, breaker: s.Breaker}, func(emit func(string) bool) (Usage, error) {
+		req, err := streamRequest(ctx, s.APIBaseURL+"/messages", generation(defaultTemperature, defaultMaxTokens, opts).anthropicMessages(map[string]interface{}{
+			"model": s.ChatModel,
+			"messages": []map[string]string{
+				{"role": "user", "content": prompt},
+			},
+			"stream": true,
+		}))
+		if err != nil {
+			return Usage{}, err
+		}
+		req.Header.Set("X-Api-Key", s.apiKey)
+		req.Header.Set("Anthropic-Version", anthropicVersion)
+
+		body, err := openStream(s.HTTPClient, req, "anthropic")
+		if err != nil {
+			return Usage{}, err
+		}
+		defer body.Close()
+		// The prompt's tokens are counted as the message starts, the reply's as it
+		// ends.
+		var usage Usage
+		err = readEvents(body, func(data []byte) (bool, error) {
+			var event struct {
+				Type    string `json:"type"`
+				Message struct {
+					Usage struct {
+						InputTokens int `json:"input_tokens"`
+					} `json:"usage"`
+				} `json:"message"`
+				Delta struct {
+					Type string `json:"type"`
+					Text string `json:"text"`
+				} `json:"delta"`
+				Usage struct {
+					OutputTokens int `json:"output_tokens"`
+				} `json:"usage"`
+				Error struct {
+					Type    string `json:"type"`
+					Message string `json:"message"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(data, &event); err != nil {
+				return false, fmt.Errorf("failed to decode anthropic stream event %q: %w", data, err)
+			}
+			switch event.Type {
+			case "message_start":
+				usage.PromptTokens = event.Message.Usage.InputTokens
+			case "content_block_delta":
+				if event.Delta.Type == "text_delta" && event.Delta.Text != "" {
+					return emit(event.Delta.Text), nil
+				}
+			case "message_delta":
+				usage.CompletionTokens = event.Usage.OutputTokens
+			case "message_stop":
+				return false, nil
+			case "error":
+				// Errors after the stream starts, such as overloading, arrive as events.
+				return false, classified(fmt.Errorf("anthropic API error: %s - %s", event.Error.Type, event.Error.Message), classifyAnthropicError(event.Error.Type, event.Error.Message))
+			}
+			return true, nil
+		})
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+		return usage, err
+	})
+}
+
+// ExtractTextFromImage extracts text from an image by sending it to a Claude model;
+// see ExtractTextFromImages.
+func (s *AnthropicLlmService) ExtractTextFromImage(ctx context.Context, prompt string, image []byte, mimeType string, opts ...GenerateOption) (string, error) {
+	return s.ExtractTextFromImages(ctx, prompt, []ImageInput{{Data: image, MimeType: mimeType}}, opts...)
+}
+
+// ExtractTextFromImages extracts text from images by sending them to a Claude
+// model as base64 image content blocks followed by the prompt.
+func (s *AnthropicLlmService) ExtractTextFromImages(ctx context.Context, prompt string, images []ImageInput, opts ...GenerateOption) (string, error) {
+	slog.InfoContext(ctx, "AnthropicLlmService: ExtractTextFromImages called",
+		"model", s.MultimodalModel,
+		"prompt_length", len(prompt),
+		"images", len(images),
+		"image_size", imageBytes(images))
+
+	if err := checkImages(images); err != nil {
+		slog.ErrorContext(ctx, "AnthropicLlmService: Invalid images", "error", err)
+		return "", err
+	}
+	content := make([]map[string]interface{}, 0, len(images)+1)
+	for n, image := range images {
+		mimeType := image.MimeType
+		if mimeType == "" {
+			slog.WarnContext(ctx, "AnthropicLlmService: MimeType is empty, defaulting to image/jpeg. Accurate MimeType is preferred.", "image", n+1)
+			mimeType = "image/jpeg"
+		}
+		content = append(content, map[string]interface{}{
+			"type": "image",
+			"source": map[string]string{
+				"type":       "base64",
+				"media_type": mimeType,
+				"data":       base64.StdEncoding.EncodeToString(image.Data),
+			},
+		})
+	}
+	content = append(content, map[string]interface{}{
+		"type": "text",
+		"text": prompt,
+	})
+
+	requestPayload := generation(imageTemperature, imageMaxTokens, opts).anthropicMessages(map[string]interface{}{
+		"model": s.MultimodalModel,
+		"messages": []map[string]interface{}{
+			{
+				"role":    "user",
+				"content": content,
+			},
+		},
+	})
+
+	text, _, err := s.complete(ctx, requestPayload, "multimodal")
+	if err != nil {
+		return "", err
+	}
+	slog.InfoContext(ctx, "AnthropicLlmService: Text extracted from images successfully", "response_length", len(text))
+	return text, nil
+}
+
+// ExtractTextFromDocument extracts text from a PDF by sending it to a Claude model
+// as a base64 document content block followed by the prompt. PDFs over
+// s.MaxDocumentBytes are refused.
+func (s *AnthropicLlmService) ExtractTextFromDocument(ctx context.Context, prompt string, pdf []byte, opts ...GenerateOption) (string, error) {
+	slog.InfoContext(ctx, "AnthropicLlmService: ExtractTextFromDocument called",
+		"model", s.MultimodalModel,
+		"prompt_length", len(prompt),
+		"document_size", len(pdf))
+
+	if err := checkDocument(pdf, s.MaxDocumentBytes); err != nil {
+		slog.ErrorContext(ctx, "AnthropicLlmService: Invalid document", "error", err)
+		return "", err
+	}
+	requestPayload := generation(imageTemperature, documentMaxTokens, opts).anthropicMessages(map[string]interface{}{
+		"model": s.MultimodalModel,
+		"messages": []map[string]interface{}{
+			{
+				"role": "user",
+				"content": []map[string]interface{}{
+					{
+						"type": "document",
+						"source": map[string]string{
+							"type":       "base64",
+							"media_type": "application/pdf",
+							"data":       base64.StdEncoding.EncodeToString(pdf),
+						},
+					},
+					{
+						"type": "text",
+						"text": documentPrompt(prompt),
+					},
+				},
+			},
+		},
+	})
+
+	text, _, err := s.complete(ctx, requestPayload, "document")
+	if err != nil {
+		return "", err
+	}
+	slog.InfoContext(ctx, "AnthropicLlmService: Text extracted from document successfully", "response_length", len(text))
+	return text, nil
+}
+
+// TranscribeAudio returns an error wrapping ErrUnsupported: Claude models do not take audio.
+func (s *AnthropicLlmService) TranscribeAudio(ctx context.Context, audio []byte, mimeType string) (string, error) {
+	return "", audioUnsupported(ProviderAnthropic)
+}
+
+// complete posts requestPayload to the messages endpoint and returns the text of
+// the response's text blocks. kind, such as "multimodal", qualifies the errors.
+func (s *AnthropicLlmService) complete(ctx context.Context, requestPayload map[string]interface{}, kind string) (_ string, usage Usage, err error) {
+	model, _ := requestPayload["model"].(string)
+	if err := checkModel(model); err != nil {
+		return "", Usage{}, err
+	}
+	if err := budget.FromContext(ctx).Check(); err != nil {
+		return "", Usage{}, err
+	}
+	defer func(start time.Time) { recordCall(ctx, s.Metrics, "anthropic", kind, start, usage, err) }(time.Now())
+	if err := s.Breaker.Allow(); err != nil {
+		return "", Usage{}, err
+	}
+	defer func() { s.Breaker.Record(ctx, err) }()
+	qualifier := ""
+	if kind != "" {
+		qualifier = kind + " "
+	}
+
+	requestBody, err := json.Marshal(requestPayload)
+	if err != nil {
+		slog.ErrorContext(ctx, "AnthropicLlmService: Failed to marshal request body", "error", err, "kind", kind)
+		return "", Usage{}, fmt.Errorf("failed to marshal %srequest body: %w", qualifier, err)
+	}
+
+	url := s.APIBaseURL + "/messages"
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(requestBody))
+	if err != nil {
+		slog.ErrorContext(ctx, "AnthropicLlmService: Failed to create HTTP request", "error", err, "url", url)
+		return "", Usage{}, fmt.Errorf("failed to create %srequest to %s: %w", qualifier, url, err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Api-Key", s.apiKey)
+	req.Header.Set("Anthropic-Version", anthropicVersion)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		slog.ErrorContext(ctx, "AnthropicLlmService: Failed to send request to Anthropic API", "error", err, "url", url)
+		return "", Usage{}, fmt.Errorf("failed to send %srequest to Anthropic API: %w", qualifier, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		slog.ErrorContext(ctx, "AnthropicLlmService: Anthropic API error", "status_code", resp.StatusCode, redact.Body("response_body", string(bodyBytes)))
+		return "", Usage{}, newAPIError("anthropic", kind, resp, bodyBytes)
+	}
+
+	var anthropicResponse struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		Usage struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&anthropicResponse); err != nil {
+		slog.ErrorContext(ctx, "AnthropicLlmService: Failed to decode Anthropic API response", "error", err)
+		return "", Usage{}, fmt.Errorf("failed to decode anthropic %sresponse: %w", qualifier, err)
+	}
+
+	var text strings.Builder
+	for _, block := range anthropicResponse.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	if text.Len() == 0 {
+		slog.WarnContext(ctx, "AnthropicLlmService: No content found in Anthropic API response")
+		slog.DebugContext(ctx, "AnthropicLlmService: Response without content", redact.Content("response", fmt.Sprintf("%+v", anthropicResponse)))
+		return "", Usage{}, fmt.Errorf("no content found in anthropic %sresponse", qualifier)
+	}
+	reported := anthropicResponse.Usage
+	return text.String(), Usage{
+		PromptTokens:     reported.InputTokens,
+		CompletionTokens: reported.OutputTokens,
+		TotalTokens:      reported.InputTokens + reported.OutputTokens,
+	}, nil
+}
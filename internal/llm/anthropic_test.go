@@ -0,0 +1,183 @@
+package llm
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newAnthropicTestService points an AnthropicLlmService at a test server serving
+// the messages endpoint with handler.
+func newAnthropicTestService(t *testing.T, handler http.HandlerFunc) *AnthropicLlmService {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/messages" {
+			http.Error(w, "Not found: Unexpected path "+r.URL.Path, http.StatusNotFound)
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("ANTHROPIC_API_KEY", "test_api_key")
+	service, err := NewAnthropicLlmService()
+	if err != nil {
+		t.Fatalf("NewAnthropicLlmService failed: %v", err)
+	}
+	service.HTTPClient = server.Client()
+	service.APIBaseURL = server.URL
+	return service
+}
+
+// writeMessage replies with a message holding the given text blocks.
+func writeMessage(w http.ResponseWriter, texts ...string) {
+	blocks := make([]map[string]string, 0, len(texts))
+	for _, text := range texts {
+		blocks = append(blocks, map[string]string{"type": "text", "text": text})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"role": "assistant", "content": blocks})
+}
+
+func TestNewAnthropicLlmService_MissingKey(t *testing.T) {
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	if _, err := NewAnthropicLlmService(); err == nil || !strings.Contains(err.Error(), "ANTHROPIC_API_KEY") {
+		t.Errorf("Expected an error naming ANTHROPIC_API_KEY, got %v", err)
+	}
+}
+
+func TestNewLlmService_Anthropic(t *testing.T) {
+	t.Setenv("ANTHROPIC_API_KEY", "test_api_key")
+	service, err := NewLlmService(ProviderAnthropic)
+	if err != nil {
+		t.Fatalf("NewLlmService failed: %v", err)
+	}
+	if _, ok := service.(*AnthropicLlmService); !ok {
+		t.Errorf("Expected an *AnthropicLlmService, got %T", service)
+	}
+}
+
+func TestAnthropicLlmService_GenerateText_Success(t *testing.T) {
+	var payload struct {
+		Model     string `json:"model"`
+		MaxTokens int    `json:"max_tokens"`
+		Messages  []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	service := newAnthropicTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "test_api_key" || r.Header.Get("Anthropic-Version") != anthropicVersion {
+			http.Error(w, "Missing API key or version", http.StatusUnauthorized)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, "Bad request body, not JSON", http.StatusBadRequest)
+			return
+		}
+		writeMessage(w, "This is ", "a test response.")
+	})
+	service.ChatModel = "claude-test"
+
+	actualText, err := service.GenerateText(context.Background(), "test prompt")
+	if err != nil {
+		t.Fatalf("GenerateText failed: %v", err)
+	}
+	if actualText != "This is a test response." {
+		t.Errorf("Expected the text blocks joined, got '%s'", actualText)
+	}
+	if payload.Model != "claude-test" || payload.MaxTokens == 0 || len(payload.Messages) != 1 || payload.Messages[0].Content != "test prompt" {
+		t.Errorf("Expected a single user message for the overridden model, got %+v", payload)
+	}
+}
+
+func TestAnthropicLlmService_GenerateText_APIError(t *testing.T) {
+	service := newAnthropicTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"type":"error","error":{"type":"overloaded_error"}}`, 529)
+	})
+
+	_, err := service.GenerateText(context.Background(), "test prompt")
+	if err == nil {
+		t.Fatalf("Expected an error, but got nil")
+	}
+	if !strings.Contains(err.Error(), "anthropic API error: 529") || !strings.Contains(err.Error(), "overloaded_error") {
+		t.Errorf("Expected error to contain the status and body, got: %v", err)
+	}
+}
+
+func TestAnthropicLlmService_GenerateText_NoTextBlocks(t *testing.T) {
+	service := newAnthropicTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"content": [{"type": "tool_use", "name": "lookup"}]}`)
+	})
+
+	_, err := service.GenerateText(context.Background(), "test prompt")
+	if err == nil || !strings.Contains(err.Error(), "no content found in anthropic response") {
+		t.Errorf("Expected error to contain 'no content found in anthropic response', got: %v", err)
+	}
+}
+
+func TestAnthropicLlmService_ExtractTextFromImage_Success(t *testing.T) {
+	imageData := []byte("dummyimagedata")
+	service := newAnthropicTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Model    string `json:"model"`
+			Messages []struct {
+				Content []struct {
+					Type   string `json:"type"`
+					Text   string `json:"text"`
+					Source struct {
+						Type      string `json:"type"`
+						MediaType string `json:"media_type"`
+						Data      string `json:"data"`
+					} `json:"source"`
+				} `json:"content"`
+			} `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, "Bad request body, not a multimodal message", http.StatusBadRequest)
+			return
+		}
+		if payload.Model != "claude-sonnet-4-5" || len(payload.Messages) != 1 || len(payload.Messages[0].Content) != 2 {
+			http.Error(w, "Expected one message with two content blocks for the multimodal model", http.StatusBadRequest)
+			return
+		}
+		image, text := payload.Messages[0].Content[0], payload.Messages[0].Content[1]
+		if image.Type != "image" || image.Source.Type != "base64" || image.Source.MediaType != "image/png" || image.Source.Data != base64.StdEncoding.EncodeToString(imageData) {
+			http.Error(w, "First content block is not the base64 image", http.StatusBadRequest)
+			return
+		}
+		if text.Type != "text" || text.Text != "Extract wine info" {
+			http.Error(w, "Second content block is not the prompt", http.StatusBadRequest)
+			return
+		}
+		writeMessage(w, "Wine Name: Test Wine")
+	})
+
+	actualText, err := service.ExtractTextFromImage(context.Background(), "Extract wine info", imageData, "image/png")
+	if err != nil {
+		t.Fatalf("ExtractTextFromImage failed: %v", err)
+	}
+	if actualText != "Wine Name: Test Wine" {
+		t.Errorf("Expected text 'Wine Name: Test Wine', got '%s'", actualText)
+	}
+}
+
+func TestAnthropicLlmService_ExtractTextFromImage_APIError(t *testing.T) {
+	service := newAnthropicTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Gateway Timeout", http.StatusGatewayTimeout)
+	})
+
+	_, err := service.ExtractTextFromImage(context.Background(), "prompt", []byte("dummyData"), "image/jpeg")
+	if err == nil || !strings.Contains(err.Error(), "anthropic API error (multimodal)") || !strings.Contains(err.Error(), "504 Gateway Timeout") {
+		t.Errorf("Expected error to contain 'anthropic API error (multimodal)' and '504 Gateway Timeout', got: %v", err)
+	}
+	if _, err := service.ExtractTextFromImage(context.Background(), "prompt", nil, "image/jpeg"); err == nil || !strings.Contains(err.Error(), "image data is empty") {
+		t.Errorf("Expected error to contain 'image data is empty', got: %v", err)
+	}
+}
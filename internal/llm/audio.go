@@ -0,0 +1,62 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultMaxAudioBytes is the size of the largest recording sent for
+// transcription, the limit of OpenAI's transcriptions API.
+const DefaultMaxAudioBytes = 25 << 20
+
+// ErrAudioTooLarge is returned when a recording exceeds the size limit, before
+// anything is sent.
+var ErrAudioTooLarge = errors.New("audio too large")
+
+// transcriptionPrompt asks a multimodal model for a transcript and nothing else.
+const transcriptionPrompt = "Transcribe this recording word for word. Reply with the transcript alone, without timestamps, speaker labels or comments."
+
+// audioExtensions are the file extensions of the audio MIME types, which
+// transcription APIs use to tell the format of an upload.
+var audioExtensions = map[string]string{
+	"audio/mpeg":  "mp3",
+	"audio/mp3":   "mp3",
+	"audio/wav":   "wav",
+	"audio/x-wav": "wav",
+	"audio/wave":  "wav",
+	"audio/mp4":   "m4a",
+	"audio/x-m4a": "m4a",
+	"audio/m4a":   "m4a",
+	"audio/ogg":   "ogg",
+	"audio/webm":  "webm",
+	"audio/flac":  "flac",
+}
+
+// checkAudio rejects an empty recording, one without an audio MIME type and one
+// over maxBytes; zero disables the limit.
+func checkAudio(audio []byte, mimeType string, maxBytes int) error {
+	if len(audio) == 0 {
+		return fmt.Errorf("audio data is empty")
+	}
+	if !strings.HasPrefix(mimeType, "audio/") {
+		return fmt.Errorf("MIME type %q is not an audio type, such as audio/mpeg", mimeType)
+	}
+	if maxBytes > 0 && len(audio) > maxBytes {
+		return fmt.Errorf("%w: %d bytes, more than the %d allowed", ErrAudioTooLarge, len(audio), maxBytes)
+	}
+	return nil
+}
+
+// audioFileName names an upload of mimeType, such as "audio.mp3".
+func audioFileName(mimeType string) string {
+	if extension, ok := audioExtensions[mimeType]; ok {
+		return "audio." + extension
+	}
+	return "audio"
+}
+
+// audioUnsupported returns the error of TranscribeAudio for provider.
+func audioUnsupported(provider Provider) error {
+	return fmt.Errorf("%w: %s cannot transcribe audio; use openai or gemini", ErrUnsupported, provider)
+}
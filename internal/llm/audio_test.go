@@ -0,0 +1,194 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// transcriptionRequest is a request to the transcriptions API as a test server
+// parsed it.
+type transcriptionRequest struct {
+	URI, APIKey, Authorization string
+	Model, ResponseFormat      string
+	FileName, FileType         string
+	File                       []byte
+}
+
+// newTranscriptionServer answers transcription requests with transcript,
+// recording them.
+func newTranscriptionServer(t *testing.T, transcript string) (*httptest.Server, *[]transcriptionRequest) {
+	t.Helper()
+	var requests []transcriptionRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/audio/transcriptions") {
+			http.Error(w, "Not found: Unexpected request "+r.Method+" "+r.URL.Path, http.StatusNotFound)
+			return
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, "Bad request body, not multipart/form-data: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "Missing file: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(file)
+		requests = append(requests, transcriptionRequest{
+			URI: r.RequestURI, APIKey: r.Header.Get("api-key"), Authorization: r.Header.Get("Authorization"),
+			Model: r.FormValue("model"), ResponseFormat: r.FormValue("response_format"),
+			FileName: header.Filename, FileType: header.Header.Get("Content-Type"), File: data,
+		})
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"text": transcript})
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestOpenAILlmService_TranscribeAudio(t *testing.T) {
+	server, requests := newTranscriptionServer(t, "Remember to call Ada.")
+	t.Setenv("OPENAI_API_KEY", "test_api_key")
+	service, err := NewOpenAILlmService()
+	if err != nil {
+		t.Fatalf("NewOpenAILlmService failed: %v", err)
+	}
+	service.HTTPClient = server.Client()
+	service.APIBaseURL = server.URL
+
+	audio := []byte("ID3\x04\x00fake mp3 frames")
+	transcript, err := service.TranscribeAudio(context.Background(), audio, "audio/mpeg")
+	if err != nil {
+		t.Fatalf("TranscribeAudio failed: %v", err)
+	}
+	if transcript != "Remember to call Ada." {
+		t.Errorf("Expected the transcript, got %q", transcript)
+	}
+	if len(*requests) != 1 {
+		t.Fatalf("Expected one request, got %d", len(*requests))
+	}
+	req := (*requests)[0]
+	if req.URI != "/audio/transcriptions" || req.Authorization != "Bearer test_api_key" {
+		t.Errorf("Expected an authenticated request to /audio/transcriptions, got %s with %q", req.URI, req.Authorization)
+	}
+	if req.Model != "whisper-1" || req.ResponseFormat != "json" {
+		t.Errorf("Expected model whisper-1 and response_format json, got %q and %q", req.Model, req.ResponseFormat)
+	}
+	if req.FileName != "audio.mp3" || req.FileType != "audio/mpeg" || !bytes.Equal(req.File, audio) {
+		t.Errorf("Expected the recording as audio.mp3 of type audio/mpeg, got %q of type %q and %d bytes", req.FileName, req.FileType, len(req.File))
+	}
+}
+
+func TestOpenAILlmService_TranscribeAudio_Refused(t *testing.T) {
+	server, requests := newTranscriptionServer(t, "unexpected")
+	t.Setenv("OPENAI_API_KEY", "test_api_key")
+	service, err := NewOpenAILlmService()
+	if err != nil {
+		t.Fatalf("NewOpenAILlmService failed: %v", err)
+	}
+	service.HTTPClient = server.Client()
+	service.APIBaseURL = server.URL
+	service.MaxAudioBytes = 4
+
+	if _, err := service.TranscribeAudio(context.Background(), []byte("12345"), "audio/wav"); !errors.Is(err, ErrAudioTooLarge) {
+		t.Errorf("Expected an error wrapping ErrAudioTooLarge, got %v", err)
+	}
+	if _, err := service.TranscribeAudio(context.Background(), []byte("1234"), "text/plain"); err == nil || !strings.Contains(err.Error(), "not an audio type") {
+		t.Errorf("Expected an error for a MIME type that is not audio, got %v", err)
+	}
+	if len(*requests) != 0 {
+		t.Errorf("Expected no request to be sent, got %d", len(*requests))
+	}
+}
+
+func TestAzureOpenAILlmService_TranscribeAudio(t *testing.T) {
+	server, requests := newTranscriptionServer(t, "Remember to call Ada.")
+	t.Setenv("AZURE_OPENAI_ENDPOINT", server.URL)
+	t.Setenv("AZURE_OPENAI_API_KEY", "azure_key")
+	t.Setenv("AZURE_OPENAI_CHAT_DEPLOYMENT", "chat")
+	t.Setenv("AZURE_OPENAI_API_VERSION", "")
+	t.Setenv("AZURE_OPENAI_TRANSCRIPTION_DEPLOYMENT", "")
+	service, err := NewAzureOpenAILlmService()
+	if err != nil {
+		t.Fatalf("NewAzureOpenAILlmService failed: %v", err)
+	}
+	service.HTTPClient = server.Client()
+	if _, err := service.TranscribeAudio(context.Background(), []byte("RIFF"), "audio/wav"); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected an error wrapping ErrUnsupported without a transcription deployment, got %v", err)
+	}
+
+	t.Setenv("AZURE_OPENAI_TRANSCRIPTION_DEPLOYMENT", "whisper")
+	service, err = NewAzureOpenAILlmService()
+	if err != nil {
+		t.Fatalf("NewAzureOpenAILlmService failed: %v", err)
+	}
+	service.HTTPClient = server.Client()
+	if _, err := service.TranscribeAudio(context.Background(), []byte("RIFF"), "audio/wav"); err != nil {
+		t.Fatalf("TranscribeAudio failed: %v", err)
+	}
+	if len(*requests) != 1 {
+		t.Fatalf("Expected one request, got %d", len(*requests))
+	}
+	req := (*requests)[0]
+	want := "/openai/deployments/whisper/audio/transcriptions?api-version=" + DefaultAzureOpenAIAPIVersion
+	if req.URI != want || req.APIKey != "azure_key" || req.Authorization != "" || req.FileName != "audio.wav" {
+		t.Errorf("Expected audio.wav sent to %s with the api-key header, got %+v", want, req)
+	}
+}
+
+func TestGeminiLlmService_TranscribeAudio(t *testing.T) {
+	var payload struct {
+		Contents []struct {
+			Parts []struct {
+				Text       string `json:"text"`
+				InlineData *struct {
+					MimeType string `json:"mimeType"`
+					Data     []byte `json:"data"`
+				} `json:"inlineData"`
+			} `json:"parts"`
+		} `json:"contents"`
+	}
+	service := newGeminiTestService(t, "gemini-test", func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, "Bad request body, not JSON", http.StatusBadRequest)
+			return
+		}
+		writeCandidate(w, "Remember to call Ada.")
+	})
+
+	audio := []byte("fake m4a")
+	transcript, err := service.TranscribeAudio(context.Background(), audio, "audio/mp4")
+	if err != nil {
+		t.Fatalf("TranscribeAudio failed: %v", err)
+	}
+	if transcript != "Remember to call Ada." {
+		t.Errorf("Expected the transcript, got %q", transcript)
+	}
+	if len(payload.Contents) != 1 || len(payload.Contents[0].Parts) != 2 {
+		t.Fatalf("Expected one content with the audio and a prompt, got %+v", payload.Contents)
+	}
+	parts := payload.Contents[0].Parts
+	if parts[0].InlineData == nil || parts[0].InlineData.MimeType != "audio/mp4" || !bytes.Equal(parts[0].InlineData.Data, audio) {
+		t.Errorf("Expected the recording as inline data, got %+v", parts[0])
+	}
+	if parts[1].Text != transcriptionPrompt {
+		t.Errorf("Expected the transcription prompt, got %q", parts[1].Text)
+	}
+}
+
+func TestTranscribeAudio_Unsupported(t *testing.T) {
+	service, err := NewMistralLlmService(WithAPIKey("test_api_key"))
+	if err != nil {
+		t.Fatalf("NewMistralLlmService failed: %v", err)
+	}
+	if _, err := service.TranscribeAudio(context.Background(), []byte("ID3"), "audio/mpeg"); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected an error wrapping ErrUnsupported, got %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/httpclient"
+	"github.com/sandwichlabs/agent-memory-graph/internal/metrics"
+)
+
+// DefaultAzureOpenAIAPIVersion is the Azure OpenAI API version used when
+// AZURE_OPENAI_API_VERSION is not set.
+const DefaultAzureOpenAIAPIVersion = "2024-10-21"
+
+// AzureOpenAILlmService implements the LlmService interface using the chat
+// completions API of an Azure OpenAI resource. Requests go to a deployment rather
+// than a model and are authenticated with an api-key header; otherwise they and
+// their responses are those of OpenAILlmService.
+type AzureOpenAILlmService struct {
+	*OpenAILlmService
+	// Endpoint is the resource, such as "https://example.openai.azure.com";
+	// exported for testing.
+	Endpoint   string
+	APIVersion string
+}
+
+// NewAzureOpenAILlmService creates a new instance of AzureOpenAILlmService for the
+// resource at AZURE_OPENAI_ENDPOINT with the key in AZURE_OPENAI_API_KEY. Text is
+// generated by the deployment AZURE_OPENAI_CHAT_DEPLOYMENT and images are read by
+// AZURE_OPENAI_VISION_DEPLOYMENT, or the chat deployment when it is not set.
+// Audio is transcribed by AZURE_OPENAI_TRANSCRIPTION_DEPLOYMENT, such as a
+// whisper deployment, and is unsupported when it is not set.
+// AZURE_OPENAI_API_VERSION overrides DefaultAzureOpenAIAPIVersion.
+func NewAzureOpenAILlmService() (*AzureOpenAILlmService, error) {
+	endpoint := strings.TrimRight(os.Getenv("AZURE_OPENAI_ENDPOINT"), "/")
+	if endpoint == "" {
+		return nil, fmt.Errorf("AZURE_OPENAI_ENDPOINT environment variable not set")
+	}
+	if parsed, err := url.Parse(endpoint); err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid AZURE_OPENAI_ENDPOINT %q: must be a URL such as https://example.openai.azure.com", endpoint)
+	}
+	apiKey := os.Getenv("AZURE_OPENAI_API_KEY")
+	if apiKey == "" {
+		return nil, fmt.Errorf("AZURE_OPENAI_API_KEY environment variable not set")
+	}
+	chatDeployment := os.Getenv("AZURE_OPENAI_CHAT_DEPLOYMENT")
+	if chatDeployment == "" {
+		return nil, fmt.Errorf("AZURE_OPENAI_CHAT_DEPLOYMENT environment variable not set")
+	}
+
+	client, err := httpclient.FromEnv()
+	if err != nil {
+		return nil, err
+	}
+	breaker, err := circuitBreakerFromEnv("azure-openai")
+	if err != nil {
+		return nil, err
+	}
+	s := &AzureOpenAILlmService{
+		Endpoint:   endpoint,
+		APIVersion: envOr("AZURE_OPENAI_API_VERSION", DefaultAzureOpenAIAPIVersion),
+	}
+	s.OpenAILlmService = &OpenAILlmService{
+		apiKey:                apiKey,
+		HTTPClient:            client,
+		chatModel:             chatDeployment,
+		multimodalModel:       envOr("AZURE_OPENAI_VISION_DEPLOYMENT", chatDeployment),
+		transcriptionModel:    os.Getenv("AZURE_OPENAI_TRANSCRIPTION_DEPLOYMENT"),
+		MaxAudioBytes:         DefaultMaxAudioBytes,
+		provider:              "azure-openai",
+		endpoint:              s.deploymentURL,
+		transcriptionEndpoint: s.transcriptionURL,
+		authorize:             func(req *http.Request) { req.Header.Set("api-key", apiKey) },
+		Metrics:               metrics.Default,
+		Breaker:               breaker,
+	}
+	return s, nil
+}
+
+// deploymentURL returns the chat completions URL of deployment.
+func (s *AzureOpenAILlmService) deploymentURL(deployment string) string {
+	return fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s",
+		s.Endpoint, url.PathEscape(deployment), url.QueryEscape(s.APIVersion))
+}
+
+// transcriptionURL returns the audio transcriptions URL of deployment.
+func (s *AzureOpenAILlmService) transcriptionURL(deployment string) string {
+	return fmt.Sprintf("%s/openai/deployments/%s/audio/transcriptions?api-version=%s",
+		s.Endpoint, url.PathEscape(deployment), url.QueryEscape(s.APIVersion))
+}
+
+// Ping lists the models of the Azure OpenAI resource with the service's key.
+func (s *AzureOpenAILlmService) Ping(ctx context.Context) error {
+	modelsURL := fmt.Sprintf("%s/openai/models?api-version=%s", s.Endpoint, url.QueryEscape(s.APIVersion))
+	return ping(ctx, s.HTTPClient, "azure-openai", modelsURL, s.authenticate)
+}
@@ -0,0 +1,143 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// azureRequest is what the Azure test server saw of a request.
+type azureRequest struct {
+	URI           string
+	APIKey        string
+	Authorization string
+}
+
+// newAzureTestService points an AzureOpenAILlmService at a test server replying
+// with handler, recording each request.
+func newAzureTestService(t *testing.T, handler http.HandlerFunc) (*AzureOpenAILlmService, *[]azureRequest) {
+	t.Helper()
+	var requests []azureRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, azureRequest{URI: r.RequestURI, APIKey: r.Header.Get("api-key"), Authorization: r.Header.Get("Authorization")})
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("AZURE_OPENAI_ENDPOINT", server.URL+"/")
+	t.Setenv("AZURE_OPENAI_API_KEY", "azure_key")
+	t.Setenv("AZURE_OPENAI_CHAT_DEPLOYMENT", "chat-4o-mini")
+	t.Setenv("AZURE_OPENAI_VISION_DEPLOYMENT", "vision 4o")
+	t.Setenv("AZURE_OPENAI_API_VERSION", "")
+	service, err := NewAzureOpenAILlmService()
+	if err != nil {
+		t.Fatalf("NewAzureOpenAILlmService failed: %v", err)
+	}
+	service.HTTPClient = server.Client()
+	return service, &requests
+}
+
+func TestNewAzureOpenAILlmService_MissingConfig(t *testing.T) {
+	t.Setenv("AZURE_OPENAI_ENDPOINT", "https://example.openai.azure.com")
+	t.Setenv("AZURE_OPENAI_API_KEY", "azure_key")
+	t.Setenv("AZURE_OPENAI_CHAT_DEPLOYMENT", "chat")
+	for _, key := range []string{"AZURE_OPENAI_ENDPOINT", "AZURE_OPENAI_API_KEY", "AZURE_OPENAI_CHAT_DEPLOYMENT"} {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv(key, "")
+			if _, err := NewAzureOpenAILlmService(); err == nil || !strings.Contains(err.Error(), key) {
+				t.Errorf("Expected an error naming %s, got %v", key, err)
+			}
+		})
+	}
+
+	t.Setenv("AZURE_OPENAI_ENDPOINT", "example.openai.azure.com")
+	if _, err := NewAzureOpenAILlmService(); err == nil || !strings.Contains(err.Error(), "invalid AZURE_OPENAI_ENDPOINT") {
+		t.Errorf("Expected an endpoint without a scheme to be rejected, got %v", err)
+	}
+}
+
+func TestNewLlmService_AzureOpenAI(t *testing.T) {
+	t.Setenv("AZURE_OPENAI_ENDPOINT", "https://example.openai.azure.com")
+	t.Setenv("AZURE_OPENAI_API_KEY", "azure_key")
+	t.Setenv("AZURE_OPENAI_CHAT_DEPLOYMENT", "chat")
+	service, err := NewLlmService(ProviderAzureOpenAI)
+	if err != nil {
+		t.Fatalf("NewLlmService failed: %v", err)
+	}
+	azure, ok := service.(*AzureOpenAILlmService)
+	if !ok {
+		t.Fatalf("Expected an *AzureOpenAILlmService, got %T", service)
+	}
+	if azure.multimodalModel != "chat" || azure.APIVersion != DefaultAzureOpenAIAPIVersion {
+		t.Errorf("Expected the chat deployment to read images with API version %s, got %s and %s", DefaultAzureOpenAIAPIVersion, azure.multimodalModel, azure.APIVersion)
+	}
+	if provider, model := ChatModel(service); provider != ProviderAzureOpenAI || model != "chat" {
+		t.Errorf("Expected the chat deployment of azure-openai, got %s %s", provider, model)
+	}
+}
+
+func TestAzureOpenAILlmService_Requests(t *testing.T) {
+	service, requests := newAzureTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		writeChoice(w, "reply")
+	})
+
+	if text, err := service.GenerateText(context.Background(), "test prompt"); err != nil || text != "reply" {
+		t.Fatalf("Expected reply, got %q, %v", text, err)
+	}
+	if _, err := service.ExtractTextFromImage(context.Background(), "prompt", []byte("image"), "image/png"); err != nil {
+		t.Fatalf("ExtractTextFromImage failed: %v", err)
+	}
+	want := []azureRequest{
+		{URI: "/openai/deployments/chat-4o-mini/chat/completions?api-version=2024-10-21", APIKey: "azure_key"},
+		{URI: "/openai/deployments/vision%204o/chat/completions?api-version=2024-10-21", APIKey: "azure_key"},
+	}
+	if len(*requests) != len(want) {
+		t.Fatalf("Expected %d requests, got %+v", len(want), *requests)
+	}
+	for n, got := range *requests {
+		if got != want[n] {
+			t.Errorf("Expected request %d to be %+v, got %+v", n+1, want[n], got)
+		}
+	}
+}
+
+func TestAzureOpenAILlmService_GenerateTextStream(t *testing.T) {
+	service, requests := newAzureTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		writeEvents(w, `{"choices":[{"delta":{"content":"Hello"}}]}`, "[DONE]")
+	})
+	service.APIVersion = "2025-01-01-preview"
+
+	pieces, err := collect(service.GenerateTextStream(context.Background(), "test prompt"))
+	if err != nil || strings.Join(pieces, "") != "Hello" {
+		t.Fatalf("Expected Hello, got %q, %v", pieces, err)
+	}
+	want := azureRequest{URI: "/openai/deployments/chat-4o-mini/chat/completions?api-version=2025-01-01-preview", APIKey: "azure_key"}
+	if len(*requests) != 1 || (*requests)[0] != want {
+		t.Errorf("Expected %+v, got %+v", want, *requests)
+	}
+}
+
+func TestAzureOpenAILlmService_APIError(t *testing.T) {
+	service, _ := newAzureTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": {"code": "DeploymentNotFound"}}`, http.StatusNotFound)
+	})
+
+	_, err := service.GenerateText(context.Background(), "test prompt")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Provider != "azure-openai" || apiErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected an azure-openai APIError with status 404, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "azure-openai API error: 404 Not Found - ") {
+		t.Errorf("Expected the error to name azure-openai and the status, got %v", err)
+	}
+
+	service, _ = newAzureTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+	})
+	if _, err := service.GenerateText(context.Background(), "test prompt"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Expected %v, got %v", ErrRateLimited, err)
+	}
+}
@@ -0,0 +1,68 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// DefaultBatchConcurrency is how many requests of a batch are in flight at once
+// when the caller does not say.
+const DefaultBatchConcurrency = 4
+
+// BatchResult is the outcome of one prompt of a batch: its text and usage, or
+// the error it failed with.
+type BatchResult struct {
+	Text  string
+	Usage Usage
+	Err   error
+}
+
+// GenerateTextBatch generates text for each of prompts with service, at most
+// concurrency at a time, or DefaultBatchConcurrency when it is zero. The results
+// are in the order of prompts, and a prompt that fails does not stop the others.
+// Requests still wait on the service's rate limiter, if it has one. The error is
+// ctx's when it ends before every prompt is sent; the prompts not sent then fail
+// with it too.
+func GenerateTextBatch(ctx context.Context, service LlmService, prompts []string, concurrency int, opts ...GenerateOption) ([]BatchResult, error) {
+	results := make([]BatchResult, len(prompts))
+	err := RunBatch(ctx, len(prompts), concurrency, func(ctx context.Context, n int) {
+		if err := ctx.Err(); err != nil {
+			results[n].Err = err
+			return
+		}
+		results[n].Text, results[n].Usage, results[n].Err = service.GenerateTextWithUsage(ctx, prompts[n], opts...)
+	})
+	return results, err
+}
+
+// RunBatch calls call for each of count items, numbered from 0, with at most
+// concurrency calls at once, or DefaultBatchConcurrency when it is zero, and
+// returns once they have all returned. Once ctx ends, the calls left are made
+// with it ended, so that they can record the error, and RunBatch returns ctx's
+// error.
+func RunBatch(ctx context.Context, count, concurrency int, call func(ctx context.Context, n int)) error {
+	if concurrency < 0 {
+		return fmt.Errorf("invalid batch concurrency %d: must not be negative", concurrency)
+	}
+	if concurrency == 0 {
+		concurrency = DefaultBatchConcurrency
+	}
+	items := make(chan int)
+	var wg sync.WaitGroup
+	for range min(concurrency, count) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range items {
+				call(ctx, n)
+			}
+		}()
+	}
+	for n := range count {
+		items <- n
+	}
+	close(items)
+	wg.Wait()
+	return ctx.Err()
+}
@@ -0,0 +1,92 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGenerateTextBatch(t *testing.T) {
+	var active, peak atomic.Int32
+	server := mockMistralServer(func(w http.ResponseWriter, r *http.Request) {
+		n := active.Add(1)
+		defer active.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		var payload struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		prompt := payload.Messages[0].Content
+		// Later prompts answer sooner, so that replies arrive out of order.
+		time.Sleep(time.Duration(10-len(prompt)) * 5 * time.Millisecond)
+		if prompt == "fail" {
+			http.Error(w, "invalid prompt", http.StatusBadRequest)
+			return
+		}
+		writeChoice(w, strings.ToUpper(prompt))
+	})
+	defer server.Close()
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	service, err := NewMistralLlmService(WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("NewMistralLlmService failed: %v", err)
+	}
+
+	prompts := []string{"a", "bb", "fail", "dddd", "eeeee", "ffffff"}
+	results, err := GenerateTextBatch(context.Background(), service, prompts, 2)
+	if err != nil {
+		t.Fatalf("GenerateTextBatch failed: %v", err)
+	}
+	if len(results) != len(prompts) {
+		t.Fatalf("Expected %d results, got %d", len(prompts), len(results))
+	}
+	for n, result := range results {
+		if prompts[n] == "fail" {
+			if result.Err == nil {
+				t.Errorf("Expected the failing prompt to have an error, got %+v", result)
+			}
+			continue
+		}
+		if result.Err != nil || result.Text != strings.ToUpper(prompts[n]) {
+			t.Errorf("Expected result %d to answer %q, got %+v", n, prompts[n], result)
+		}
+	}
+	if peak.Load() != 2 {
+		t.Errorf("Expected 2 requests at once, got %d", peak.Load())
+	}
+}
+
+func TestRunBatch_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls, cancelled atomic.Int32
+	err := RunBatch(ctx, 10, 3, func(ctx context.Context, n int) {
+		calls.Add(1)
+		if n == 2 {
+			cancel()
+		}
+		if ctx.Err() != nil {
+			cancelled.Add(1)
+		}
+	})
+	if err != context.Canceled {
+		t.Errorf("Expected the batch to report the cancellation, got %v", err)
+	}
+	if calls.Load() != 10 || cancelled.Load() == 0 {
+		t.Errorf("Expected every item to be called, the later ones cancelled, got %d calls and %d cancelled", calls.Load(), cancelled.Load())
+	}
+
+	if err := RunBatch(context.Background(), 1, -1, func(context.Context, int) {}); err == nil {
+		t.Errorf("Expected a negative concurrency to be rejected")
+	}
+}
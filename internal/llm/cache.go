@@ -0,0 +1,389 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults of NewCachedService.
+const (
+	DefaultCacheTTL     = 30 * 24 * time.Hour
+	DefaultCacheMaxSize = 256 << 20 // bytes
+)
+
+// CacheOption configures a CachedService.
+type CacheOption func(*cacheConfig)
+
+type cacheConfig struct {
+	ttl     time.Duration
+	maxSize int64
+	refresh bool
+}
+
+// WithCacheTTL sets how long a reply is served from the cache; zero keeps replies
+// until they are evicted.
+func WithCacheTTL(ttl time.Duration) CacheOption {
+	return func(c *cacheConfig) { c.ttl = ttl }
+}
+
+// WithCacheMaxSize sets the size in bytes above which the least recently used
+// replies are evicted; zero lets the cache grow without bound.
+func WithCacheMaxSize(bytes int64) CacheOption {
+	return func(c *cacheConfig) { c.maxSize = bytes }
+}
+
+// WithCacheRefresh skips cached replies, asking the service again and storing its
+// new replies.
+func WithCacheRefresh(refresh bool) CacheOption {
+	return func(c *cacheConfig) { c.refresh = refresh }
+}
+
+// CachedService is an LlmService storing the replies of another on disk, so that
+// asking the same again, such as re-ingesting unchanged chunks, costs nothing.
+// Replies are keyed by a SHA-256 hash of the service's models, the method, the
+// prompt or messages, the generation options and any image. Cached replies report
+// no usage, as no tokens were spent. Streams are not cached.
+type CachedService struct {
+	inner  LlmService
+	dir    string
+	config cacheConfig
+	mu     sync.Mutex // serializes eviction
+}
+
+// cachedToolCaller is a CachedService of a ToolCaller, caching its tool calls too.
+type cachedToolCaller struct {
+	*CachedService
+}
+
+// cacheEntry is a stored reply.
+type cacheEntry struct {
+	Created   time.Time       `json:"created"`
+	Text      string          `json:"text"`
+	Tool      string          `json:"tool,omitempty"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+// NewCachedService caches the replies of inner in dir, creating it if needed.
+// Replies expire after DefaultCacheTTL and the cache is kept under
+// DefaultCacheMaxSize unless opts say otherwise. The returned service supports
+// function calling when inner does.
+func NewCachedService(inner LlmService, dir string, opts ...CacheOption) (LlmService, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create LLM cache directory: %w", err)
+	}
+	s := &CachedService{
+		inner:  inner,
+		dir:    dir,
+		config: cacheConfig{ttl: DefaultCacheTTL, maxSize: DefaultCacheMaxSize},
+	}
+	for _, opt := range opts {
+		opt(&s.config)
+	}
+	if _, ok := inner.(ToolCaller); ok {
+		return &cachedToolCaller{s}, nil
+	}
+	return s, nil
+}
+
+// GenerateText returns the cached reply to prompt, asking the inner service on a miss.
+func (s *CachedService) GenerateText(ctx context.Context, prompt string, opts ...GenerateOption) (string, error) {
+	text, _, err := s.GenerateTextWithUsage(ctx, prompt, opts...)
+	return text, err
+}
+
+// GenerateTextWithUsage is GenerateText also returning the tokens spent, none on a hit.
+func (s *CachedService) GenerateTextWithUsage(ctx context.Context, prompt string, opts ...GenerateOption) (string, Usage, error) {
+	entry, usage, err := s.cached(ctx, "text", textRequest(prompt, opts), func() (cacheEntry, Usage, error) {
+		text, usage, err := s.inner.GenerateTextWithUsage(ctx, prompt, opts...)
+		return cacheEntry{Text: text}, usage, err
+	})
+	return entry.Text, usage, err
+}
+
+// Chat returns the cached reply to messages, asking the inner service on a miss.
+func (s *CachedService) Chat(ctx context.Context, messages []Message, opts ...GenerateOption) (string, error) {
+	request := map[string]any{"messages": messages, "options": optionsKey(opts)}
+	entry, _, err := s.cached(ctx, "chat", request, func() (cacheEntry, Usage, error) {
+		text, err := s.inner.Chat(ctx, messages, opts...)
+		return cacheEntry{Text: text}, Usage{}, err
+	})
+	return entry.Text, err
+}
+
+// Ping pings the inner service, as a cache cannot tell whether it is configured.
+func (s *CachedService) Ping(ctx context.Context) error {
+	return s.inner.Ping(ctx)
+}
+
+// GenerateTextStream streams from the inner service without caching.
+func (s *CachedService) GenerateTextStream(ctx context.Context, prompt string, opts ...GenerateOption) (<-chan string, <-chan error) {
+	return s.inner.GenerateTextStream(ctx, prompt, opts...)
+}
+
+// ExtractTextFromImage returns the cached reading of image, asking the inner
+// service on a miss.
+func (s *CachedService) ExtractTextFromImage(ctx context.Context, prompt string, image []byte, mimeType string, opts ...GenerateOption) (string, error) {
+	return s.ExtractTextFromImages(ctx, prompt, []ImageInput{{Data: image, MimeType: mimeType}}, opts...)
+}
+
+// ExtractTextFromImages returns the cached reading of images, asking the inner
+// service on a miss.
+func (s *CachedService) ExtractTextFromImages(ctx context.Context, prompt string, images []ImageInput, opts ...GenerateOption) (string, error) {
+	hashes := make([]string, len(images))
+	for n, image := range images {
+		sum := sha256.Sum256(image.Data)
+		hashes[n] = image.MimeType + ":" + hex.EncodeToString(sum[:])
+	}
+	request := map[string]any{"prompt": prompt, "images": hashes, "options": optionsKey(opts)}
+	entry, _, err := s.cached(ctx, "images", request, func() (cacheEntry, Usage, error) {
+		text, err := s.inner.ExtractTextFromImages(ctx, prompt, images, opts...)
+		return cacheEntry{Text: text}, Usage{}, err
+	})
+	return entry.Text, err
+}
+
+// ExtractTextFromDocument returns the cached reading of pdf, asking the inner
+// service on a miss.
+func (s *CachedService) ExtractTextFromDocument(ctx context.Context, prompt string, pdf []byte, opts ...GenerateOption) (string, error) {
+	sum := sha256.Sum256(pdf)
+	request := map[string]any{"prompt": prompt, "document": hex.EncodeToString(sum[:]), "options": optionsKey(opts)}
+	entry, _, err := s.cached(ctx, "document", request, func() (cacheEntry, Usage, error) {
+		text, err := s.inner.ExtractTextFromDocument(ctx, prompt, pdf, opts...)
+		return cacheEntry{Text: text}, Usage{}, err
+	})
+	return entry.Text, err
+}
+
+// TranscribeAudio returns the cached transcript of audio, asking the inner service
+// on a miss.
+func (s *CachedService) TranscribeAudio(ctx context.Context, audio []byte, mimeType string) (string, error) {
+	sum := sha256.Sum256(audio)
+	request := map[string]any{"audio": mimeType + ":" + hex.EncodeToString(sum[:])}
+	entry, _, err := s.cached(ctx, "audio", request, func() (cacheEntry, Usage, error) {
+		text, err := s.inner.TranscribeAudio(ctx, audio, mimeType)
+		return cacheEntry{Text: text}, Usage{}, err
+	})
+	return entry.Text, err
+}
+
+// generateJSON caches JSON replies, using the inner service's JSON mode when it
+// has one.
+func (s *CachedService) generateJSON(ctx context.Context, prompt string, opts []GenerateOption) (string, Usage, error) {
+	entry, usage, err := s.cached(ctx, "json", textRequest(prompt, opts), func() (cacheEntry, Usage, error) {
+		var text string
+		var usage Usage
+		var err error
+		if generator, ok := s.inner.(jsonGenerator); ok {
+			text, usage, err = generator.generateJSON(ctx, prompt, opts)
+		} else {
+			text, usage, err = s.inner.GenerateTextWithUsage(ctx, prompt, opts...)
+		}
+		return cacheEntry{Text: text}, usage, err
+	})
+	return entry.Text, usage, err
+}
+
+// generateWithSchema caches replies constrained to schema, giving the schema to
+// the inner service when it supports one and asking for JSON otherwise.
+func (s *CachedService) generateWithSchema(ctx context.Context, prompt, name string, schema json.RawMessage, opts []GenerateOption) (string, Usage, error) {
+	request := map[string]any{"prompt": prompt, "schema_name": name, "schema": schema, "options": optionsKey(opts)}
+	entry, usage, err := s.cached(ctx, "schema", request, func() (cacheEntry, Usage, error) {
+		ask := askJSON(s.inner, opts)
+		if generator, ok := s.inner.(schemaGenerator); ok {
+			ask = func(ctx context.Context, request string) (string, Usage, error) {
+				return generator.generateWithSchema(ctx, request, name, schema, opts)
+			}
+		}
+		text, usage, err := ask(ctx, prompt)
+		return cacheEntry{Text: text}, usage, err
+	})
+	return entry.Text, usage, err
+}
+
+// GenerateWithTools returns the cached answer to prompt with tools, asking the
+// inner service on a miss.
+func (s *cachedToolCaller) GenerateWithTools(ctx context.Context, prompt string, tools []ToolDefinition, opts ...GenerateOption) (ToolCallResult, error) {
+	request := map[string]any{"prompt": prompt, "tools": tools, "options": optionsKey(opts)}
+	entry, usage, err := s.cached(ctx, "tools", request, func() (cacheEntry, Usage, error) {
+		result, err := s.inner.(ToolCaller).GenerateWithTools(ctx, prompt, tools, opts...)
+		return cacheEntry{Text: result.Text, Tool: result.Tool, Arguments: result.Arguments}, result.Usage, err
+	})
+	if err != nil {
+		return ToolCallResult{Usage: usage}, err
+	}
+	return ToolCallResult{Tool: entry.Tool, Arguments: entry.Arguments, Text: entry.Text, Usage: usage}, nil
+}
+
+// textRequest is the cached request of a prompt.
+func textRequest(prompt string, opts []GenerateOption) map[string]any {
+	return map[string]any{"prompt": prompt, "options": optionsKey(opts)}
+}
+
+// optionsKey describes the generation parameters opts set, so that asking with
+// other parameters misses the cache. Parameters left to the method's defaults
+// are -1, which no option sets.
+func optionsKey(opts []GenerateOption) string {
+	return fmt.Sprintf("%+v", generation(-1, -1, opts))
+}
+
+// cached returns the stored reply to request of method, or calls ask and stores
+// its reply. Failures to read or write the cache only cost the call.
+func (s *CachedService) cached(ctx context.Context, method string, request any, ask func() (cacheEntry, Usage, error)) (cacheEntry, Usage, error) {
+	key, err := s.key(method, request)
+	if err != nil {
+		return ask()
+	}
+	if !s.config.refresh {
+		if entry, ok := s.load(ctx, key); ok {
+			slog.InfoContext(ctx, "CachedService: Serving LLM reply from cache", "method", method, "key", key[:12])
+			return entry, Usage{}, nil
+		}
+	}
+	entry, usage, err := ask()
+	if err != nil {
+		return entry, usage, err
+	}
+	entry.Created = time.Now().UTC()
+	if err := s.store(key, entry); err != nil {
+		slog.WarnContext(ctx, "CachedService: Failed to cache LLM reply", "method", method, "error", err)
+	}
+	return entry, usage, nil
+}
+
+// key hashes request of method together with the inner service and its models.
+func (s *CachedService) key(method string, request any) (string, error) {
+	data, err := json.Marshal(map[string]any{
+		"service": fmt.Sprintf("%T", s.inner),
+		"models":  serviceModels(s.inner),
+		"method":  method,
+		"request": request,
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// serviceModels returns the models service uses, for services that have them.
+func serviceModels(service LlmService) []string {
+	switch s := service.(type) {
+	case *MistralLlmService:
+		return []string{s.chatModel, s.multimodalModel}
+	case *OpenAILlmService:
+		return []string{s.chatModel, s.multimodalModel}
+	case *AzureOpenAILlmService:
+		return []string{s.chatModel, s.multimodalModel}
+	case *AnthropicLlmService:
+		return []string{s.ChatModel, s.MultimodalModel}
+	case *OllamaLlmService:
+		return []string{s.ChatModel, s.MultimodalModel}
+	case *GeminiLlmService:
+		return []string{s.ChatModel, s.MultimodalModel}
+	}
+	return nil
+}
+
+func (s *CachedService) path(key string) string {
+	return filepath.Join(s.dir, key+".json")
+}
+
+// load returns the unexpired entry stored under key, marking it recently used.
+func (s *CachedService) load(ctx context.Context, key string) (cacheEntry, bool) {
+	path := s.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			slog.WarnContext(ctx, "CachedService: Failed to read cached LLM reply", "error", err)
+		}
+		return cacheEntry{}, false
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		slog.WarnContext(ctx, "CachedService: Discarding corrupt cached LLM reply", "path", path, "error", err)
+		os.Remove(path)
+		return cacheEntry{}, false
+	}
+	if s.config.ttl > 0 && time.Since(entry.Created) > s.config.ttl {
+		os.Remove(path)
+		return cacheEntry{}, false
+	}
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return entry, true
+}
+
+// store writes entry under key, replacing the file whole so that concurrent
+// readers never see part of it, and evicts entries above the size limit.
+func (s *CachedService) store(key string, entry cacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path(key))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return s.evict()
+}
+
+// evict removes the least recently used entries until the cache fits maxSize.
+func (s *CachedService) evict() error {
+	if s.config.maxSize <= 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dirEntries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	var files []fs.FileInfo
+	var total int64
+	for _, dirEntry := range dirEntries {
+		if !strings.HasSuffix(dirEntry.Name(), ".json") {
+			continue
+		}
+		info, err := dirEntry.Info()
+		if err != nil {
+			continue // removed meanwhile
+		}
+		files = append(files, info)
+		total += info.Size()
+	}
+	sort.Slice(files, func(a, b int) bool { return files[a].ModTime().Before(files[b].ModTime()) })
+	for _, file := range files {
+		if total <= s.config.maxSize {
+			break
+		}
+		if err := os.Remove(filepath.Join(s.dir, file.Name())); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		total -= file.Size()
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCachedService_ServesRepeatedCalls(t *testing.T) {
+	mock := &MockLlmService{Response: "cached reply", Usage: Usage{TotalTokens: 9}}
+	dir := t.TempDir()
+	service, err := NewCachedService(mock, dir)
+	if err != nil {
+		t.Fatalf("NewCachedService failed: %v", err)
+	}
+	ctx := context.Background()
+
+	for n, want := range []int{9, 0} {
+		text, usage, err := service.GenerateTextWithUsage(ctx, "test prompt")
+		if err != nil || text != "cached reply" || usage.TotalTokens != want {
+			t.Errorf("Expected call %d to reply with %d tokens, got %q, %v, %v", n+1, want, text, usage, err)
+		}
+	}
+	service.GenerateText(ctx, "test prompt", WithTemperature(0))
+	service.ExtractTextFromImage(ctx, "read it", []byte("png"), "image/png")
+	service.ExtractTextFromImage(ctx, "read it", []byte("png"), "image/png")
+	service.ExtractTextFromImage(ctx, "read it", []byte("jpeg"), "image/png")
+	if mock.Calls() != 4 {
+		t.Errorf("Expected 4 calls to reach the service, got %d", mock.Calls())
+	}
+
+	refreshed, _ := NewCachedService(mock, dir, WithCacheRefresh(true))
+	if _, usage, _ := refreshed.GenerateTextWithUsage(ctx, "test prompt"); usage.TotalTokens != 9 || mock.Calls() != 5 {
+		t.Errorf("Expected a refresh to ask the service again, got %v after %d calls", usage, mock.Calls())
+	}
+
+	mock.Err = os.ErrDeadlineExceeded
+	if _, err := service.GenerateText(ctx, "failing prompt"); err == nil {
+		t.Fatal("Expected the error of the service, got nil")
+	}
+	mock.Err = nil
+	if text, _ := service.GenerateText(ctx, "failing prompt"); text != "cached reply" || mock.Calls() != 7 {
+		t.Errorf("Expected failures to be left uncached, got %q after %d calls", text, mock.Calls())
+	}
+}
+
+func TestCachedService_ExpiresAndEvicts(t *testing.T) {
+	mock := &MockLlmService{Response: "reply"}
+	dir := t.TempDir()
+	service, _ := NewCachedService(mock, dir, WithCacheTTL(time.Hour))
+	ctx := context.Background()
+	service.GenerateText(ctx, "old prompt")
+
+	entries, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 cached reply, got %d", len(entries))
+	}
+	data, _ := os.ReadFile(entries[0])
+	var entry cacheEntry
+	json.Unmarshal(data, &entry)
+	entry.Created = time.Now().Add(-2 * time.Hour)
+	data, _ = json.Marshal(entry)
+	os.WriteFile(entries[0], data, 0o644)
+	service.GenerateText(ctx, "old prompt")
+	if mock.Calls() != 2 {
+		t.Errorf("Expected an expired reply to ask the service again, got %d calls", mock.Calls())
+	}
+
+	small, _ := NewCachedService(mock, t.TempDir(), WithCacheMaxSize(int64(len(data))+20))
+	small.GenerateText(ctx, "first prompt")
+	time.Sleep(10 * time.Millisecond)
+	small.GenerateText(ctx, "second prompt")
+	small.GenerateText(ctx, "second prompt")
+	small.GenerateText(ctx, "first prompt")
+	if mock.Calls() != 5 {
+		t.Errorf("Expected only the least recently used reply evicted, got %d calls", mock.Calls())
+	}
+}
+
+// toolMock is a MockLlmService that supports function calling.
+type toolMock struct {
+	*MockLlmService
+}
+
+func (m toolMock) GenerateWithTools(ctx context.Context, prompt string, tools []ToolDefinition, opts ...GenerateOption) (ToolCallResult, error) {
+	if _, err := m.reply(ctx, prompt); err != nil {
+		return ToolCallResult{}, err
+	}
+	return ToolCallResult{Tool: tools[0].Name, Arguments: json.RawMessage(`{"n":1}`), Usage: m.Usage}, nil
+}
+
+func TestCachedService_ToolCalls(t *testing.T) {
+	mock := toolMock{&MockLlmService{Usage: Usage{TotalTokens: 5}}}
+	service, _ := NewCachedService(mock, t.TempDir())
+	caller, ok := service.(ToolCaller)
+	if !ok {
+		t.Fatal("Expected the cache of a tool caller to call tools")
+	}
+	if _, ok := service.(*CachedService); ok {
+		t.Error("Expected the cache of a plain service not to be a tool caller")
+	}
+
+	tools := []ToolDefinition{{Name: "count"}}
+	for n, want := range []int{5, 0} {
+		result, err := caller.GenerateWithTools(context.Background(), "test prompt", tools)
+		if err != nil || result.Tool != "count" || string(result.Arguments) != `{"n":1}` || result.Usage.TotalTokens != want {
+			t.Errorf("Expected call %d to call count with %d tokens, got %+v, %v", n+1, want, result, err)
+		}
+	}
+	if mock.Calls() != 1 {
+		t.Errorf("Expected 1 call to reach the service, got %d", mock.Calls())
+	}
+}
@@ -0,0 +1,63 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Role is the author of a chat message.
+type Role string
+
+const (
+	// RoleSystem messages instruct the model, such as "You are an entity
+	// extraction engine".
+	RoleSystem    Role = "system"
+	RoleUser      Role = "user"
+	RoleAssistant Role = "assistant"
+)
+
+// Message is one turn of a conversation with a chat model.
+type Message struct {
+	Role    Role
+	Content string
+}
+
+// checkMessages rejects an empty conversation and messages with unknown roles.
+func checkMessages(messages []Message) error {
+	if len(messages) == 0 {
+		return fmt.Errorf("no messages given")
+	}
+	for n, message := range messages {
+		switch message.Role {
+		case RoleSystem, RoleUser, RoleAssistant:
+		default:
+			return fmt.Errorf("message %d has unknown role %q: use system, user or assistant", n+1, message.Role)
+		}
+	}
+	return nil
+}
+
+// chatMessages describes messages in the messages array of a Mistral, OpenAI or
+// Ollama chat request, which take the roles as they are.
+func chatMessages(messages []Message) []map[string]string {
+	payload := make([]map[string]string, 0, len(messages))
+	for _, message := range messages {
+		payload = append(payload, map[string]string{"role": string(message.Role), "content": message.Content})
+	}
+	return payload
+}
+
+// splitSystem separates the system messages, joined into one instruction, from
+// the turns of the conversation, for APIs that take the instruction apart.
+func splitSystem(messages []Message) (string, []Message) {
+	var system []string
+	turns := make([]Message, 0, len(messages))
+	for _, message := range messages {
+		if message.Role == RoleSystem {
+			system = append(system, message.Content)
+		} else {
+			turns = append(turns, message)
+		}
+	}
+	return strings.Join(system, "\n\n"), turns
+}
@@ -0,0 +1,127 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var conversation = []Message{
+	{Role: RoleSystem, Content: "You are an entity extraction engine."},
+	{Role: RoleUser, Content: "Who builds KuzuDB?"},
+	{Role: RoleAssistant, Content: "Kuzu Inc."},
+	{Role: RoleUser, Content: "What does it speak?"},
+}
+
+// chatPayload is the part of a chat request the tests check.
+type chatPayload struct {
+	System   string `json:"system"`
+	Messages []struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	} `json:"messages"`
+}
+
+func (p chatPayload) turns() []string {
+	turns := make([]string, 0, len(p.Messages))
+	for _, message := range p.Messages {
+		turns = append(turns, message.Role+": "+message.Content)
+	}
+	return turns
+}
+
+func TestMistralLlmService_Chat(t *testing.T) {
+	var payload chatPayload
+	server := mockMistralServer(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+		writeChoice(w, "Cypher.")
+	})
+	defer server.Close()
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	service, err := NewMistralLlmService(WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("NewMistralLlmService failed: %v", err)
+	}
+
+	reply, err := service.Chat(context.Background(), conversation)
+	if err != nil || reply != "Cypher." {
+		t.Fatalf("Expected the reply, got %q and %v", reply, err)
+	}
+	expected := []string{
+		"system: You are an entity extraction engine.",
+		"user: Who builds KuzuDB?",
+		"assistant: Kuzu Inc.",
+		"user: What does it speak?",
+	}
+	if !reflect.DeepEqual(payload.turns(), expected) {
+		t.Errorf("Expected the messages in order with the system message first, got %q", payload.turns())
+	}
+
+	if _, err := service.GenerateText(context.Background(), "test prompt"); err != nil || !reflect.DeepEqual(payload.turns(), []string{"user: test prompt"}) {
+		t.Errorf("Expected GenerateText to send a single user message, got %q and %v", payload.turns(), err)
+	}
+}
+
+func TestAnthropicLlmService_Chat(t *testing.T) {
+	var payload chatPayload
+	service := newAnthropicTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+		writeMessage(w, "Cypher.")
+	})
+
+	if _, err := service.Chat(context.Background(), conversation); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if payload.System != "You are an entity extraction engine." {
+		t.Errorf("Expected the system message as the system prompt, got %q", payload.System)
+	}
+	if turns := payload.turns(); len(turns) != 3 || turns[0] != "user: Who builds KuzuDB?" || turns[1] != "assistant: Kuzu Inc." {
+		t.Errorf("Expected the remaining turns in order, got %q", turns)
+	}
+}
+
+func TestGeminiLlmService_Chat(t *testing.T) {
+	var payload struct {
+		SystemInstruction struct {
+			Parts []struct {
+				Text string `json:"text"`
+			} `json:"parts"`
+		} `json:"systemInstruction"`
+		Contents []struct {
+			Role string `json:"role"`
+		} `json:"contents"`
+	}
+	service := newGeminiTestService(t, "gemini-test", func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+		writeCandidate(w, "Cypher.")
+	})
+
+	if _, err := service.Chat(context.Background(), conversation); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if parts := payload.SystemInstruction.Parts; len(parts) != 1 || parts[0].Text != "You are an entity extraction engine." {
+		t.Errorf("Expected the system message as the system instruction, got %+v", payload.SystemInstruction)
+	}
+	var roles []string
+	for _, content := range payload.Contents {
+		roles = append(roles, content.Role)
+	}
+	if !reflect.DeepEqual(roles, []string{"user", "model", "user"}) {
+		t.Errorf("Expected the turns with the assistant as the model, got %q", roles)
+	}
+}
+
+func TestChat_InvalidMessages(t *testing.T) {
+	service := newOpenAITestService(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected no request for invalid messages")
+	})
+	if _, err := service.Chat(context.Background(), nil); err == nil {
+		t.Error("Expected an error for an empty conversation")
+	}
+	if _, err := service.Chat(context.Background(), []Message{{Role: "tool", Content: "42"}}); err == nil || !strings.Contains(err.Error(), `unknown role "tool"`) {
+		t.Errorf("Expected an unknown role error, got %v", err)
+	}
+}
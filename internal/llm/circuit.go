@@ -0,0 +1,174 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/retry"
+)
+
+// ErrCircuitOpen means a provider failed so many times in a row that requests to
+// it fail without being sent until its circuit breaker's cooldown ends.
+var ErrCircuitOpen = errors.New("circuit open")
+
+// Defaults of the circuit breakers of the services, overridden by
+// AMG_LLM_CIRCUIT_THRESHOLD and AMG_LLM_CIRCUIT_COOLDOWN.
+const (
+	DefaultCircuitThreshold = 5
+	DefaultCircuitCooldown  = 30 * time.Second
+)
+
+// CircuitState is the state of a CircuitBreaker.
+type CircuitState string
+
+const (
+	// CircuitClosed lets every request through.
+	CircuitClosed CircuitState = "closed"
+	// CircuitOpen fails every request until the cooldown ends.
+	CircuitOpen CircuitState = "open"
+	// CircuitHalfOpen lets one request through to probe whether the provider has
+	// recovered, failing the others until it ends.
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+// CircuitBreaker stops requests to a provider that is down. After Threshold
+// consecutive outages, such as server errors, dropped connections and timeouts,
+// it opens and requests fail with ErrCircuitOpen; once Cooldown has passed, one
+// probe is let through, which closes the breaker when the provider answers and
+// opens it again otherwise. Other failures, such as a request refused for being
+// too long, show that the provider is up. A nil *CircuitBreaker lets every request
+// through. It is safe for concurrent use.
+type CircuitBreaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time // replaced in tests
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewCircuitBreaker returns a closed breaker for the provider name, opening after
+// threshold consecutive outages for cooldown. A threshold below 1 returns nil,
+// which never opens.
+func NewCircuitBreaker(name string, threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold < 1 {
+		return nil
+	}
+	return &CircuitBreaker{name: name, threshold: threshold, cooldown: cooldown, now: time.Now, state: CircuitClosed}
+}
+
+// circuitBreakerFromEnv returns the breaker of a service for the provider name,
+// configured by AMG_LLM_CIRCUIT_THRESHOLD, a number of consecutive outages where
+// 0 disables the breaker, and AMG_LLM_CIRCUIT_COOLDOWN, a duration.
+func circuitBreakerFromEnv(name string) (*CircuitBreaker, error) {
+	threshold := DefaultCircuitThreshold
+	if value := os.Getenv("AMG_LLM_CIRCUIT_THRESHOLD"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid AMG_LLM_CIRCUIT_THRESHOLD %q: must be a number of failures, or 0 to disable the circuit breaker", value)
+		}
+		threshold = n
+	}
+	cooldown, err := envDuration("AMG_LLM_CIRCUIT_COOLDOWN", DefaultCircuitCooldown)
+	if err != nil {
+		return nil, err
+	}
+	return NewCircuitBreaker(name, threshold, cooldown), nil
+}
+
+// State returns the state of b, which is half-open once an open breaker's
+// cooldown has passed.
+func (b *CircuitBreaker) State() CircuitState {
+	if b == nil {
+		return CircuitClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == CircuitOpen && !b.now().Before(b.openedAt.Add(b.cooldown)) {
+		return CircuitHalfOpen
+	}
+	return b.state
+}
+
+// Allow returns nil when a request may be sent, and an error wrapping
+// ErrCircuitOpen otherwise. Every allowed request must be followed by Record.
+func (b *CircuitBreaker) Allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case CircuitOpen:
+		retryAt := b.openedAt.Add(b.cooldown)
+		if b.now().Before(retryAt) {
+			return fmt.Errorf("%w: %s failed %d times in a row, not sending requests for another %s", ErrCircuitOpen, b.name, b.failures, retryAt.Sub(b.now()).Round(time.Second))
+		}
+		b.transition(CircuitHalfOpen)
+		b.probing = true
+		return nil
+	case CircuitHalfOpen:
+		if b.probing {
+			return fmt.Errorf("%w: waiting for a request to %s to show that it has recovered", ErrCircuitOpen, b.name)
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// Record counts the outcome of a request Allow let through, made with ctx: an
+// outage counts towards opening b, and anything else closes it. Requests ended by
+// ctx say nothing about the provider.
+func (b *CircuitBreaker) Record(ctx context.Context, err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	wasProbe := b.state == CircuitHalfOpen && b.probing
+	b.probing = false
+	switch {
+	case ctx.Err() != nil || errors.Is(err, ErrCircuitOpen):
+		return
+	case outage(err):
+		b.failures++
+		if wasProbe || (b.state == CircuitClosed && b.failures >= b.threshold) {
+			b.openedAt = b.now()
+			b.transition(CircuitOpen)
+		}
+	default:
+		b.failures = 0
+		if b.state != CircuitClosed {
+			b.transition(CircuitClosed)
+		}
+	}
+}
+
+// transition moves b to state, logging the change. b.mu must be held.
+func (b *CircuitBreaker) transition(state CircuitState) {
+	switch state {
+	case CircuitOpen:
+		slog.Warn("Circuit breaker opened: failing requests without sending them", "provider", b.name, "failures", b.failures, "cooldown", b.cooldown)
+	case CircuitHalfOpen:
+		slog.Info("Circuit breaker half-open: probing whether the provider recovered", "provider", b.name)
+	case CircuitClosed:
+		slog.Info("Circuit breaker closed: the provider recovered", "provider", b.name)
+	}
+	b.state = state
+}
+
+// outage reports whether err shows that the provider is down or overloaded, rather
+// than refusing the request itself.
+func outage(err error) bool {
+	return err != nil && (errors.Is(err, ErrUpstream) || errors.Is(err, context.DeadlineExceeded) || retry.TransientError(err))
+}
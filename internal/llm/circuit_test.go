@@ -0,0 +1,130 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fakeClock is a time that tests move forward by hand.
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestBreaker(threshold int, cooldown time.Duration) (*CircuitBreaker, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	b := NewCircuitBreaker("mistral", threshold, cooldown)
+	b.now = clock.Now
+	return b, clock
+}
+
+func TestCircuitBreaker_States(t *testing.T) {
+	ctx := context.Background()
+	outageErr := fmt.Errorf("mistral API error: 503: %w", ErrUpstream)
+	b, clock := newTestBreaker(3, time.Minute)
+
+	// Closed: failures below the threshold, or broken by a success, let requests through.
+	for _, err := range []error{outageErr, outageErr, nil, outageErr, outageErr} {
+		if allowErr := b.Allow(); allowErr != nil {
+			t.Fatalf("Expected a closed breaker to allow requests, got %v", allowErr)
+		}
+		b.Record(ctx, err)
+	}
+	if state := b.State(); state != CircuitClosed {
+		t.Fatalf("Expected %s after two consecutive outages, got %s", CircuitClosed, state)
+	}
+
+	// Open: the third consecutive outage fails requests until the cooldown ends.
+	b.Allow()
+	b.Record(ctx, outageErr)
+	if state := b.State(); state != CircuitOpen {
+		t.Fatalf("Expected %s after three consecutive outages, got %s", CircuitOpen, state)
+	}
+	clock.Advance(59 * time.Second)
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected %v during the cooldown, got %v", ErrCircuitOpen, err)
+	}
+
+	// Half-open: one probe goes through; a failed probe opens the breaker again.
+	clock.Advance(time.Second)
+	if state := b.State(); state != CircuitHalfOpen {
+		t.Fatalf("Expected %s once the cooldown ended, got %s", CircuitHalfOpen, state)
+	}
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Expected a probe to be allowed, got %v", err)
+	}
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected requests during the probe to fail, got %v", err)
+	}
+	b.Record(ctx, outageErr)
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) || b.State() != CircuitOpen {
+		t.Fatalf("Expected a failed probe to open the breaker again, got %s and %v", b.State(), err)
+	}
+
+	// A successful probe closes it.
+	clock.Advance(time.Minute)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Expected a probe to be allowed, got %v", err)
+	}
+	b.Record(ctx, nil)
+	if state := b.State(); state != CircuitClosed {
+		t.Errorf("Expected a successful probe to close the breaker, got %s", state)
+	}
+	b.Allow()
+	b.Record(ctx, outageErr)
+	if state := b.State(); state != CircuitClosed {
+		t.Errorf("Expected the failures to count from zero again, got %s", state)
+	}
+}
+
+func TestCircuitBreaker_IgnoresRefusalsAndCancellation(t *testing.T) {
+	b, _ := newTestBreaker(1, time.Minute)
+	b.Allow()
+	b.Record(context.Background(), fmt.Errorf("too long: %w", ErrContextLengthExceeded))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	b.Allow()
+	b.Record(ctx, ctx.Err())
+	if state := b.State(); state != CircuitClosed {
+		t.Errorf("Expected refusals and cancelled requests not to open the breaker, got %s", state)
+	}
+
+	var disabled *CircuitBreaker
+	disabled.Record(context.Background(), ErrUpstream)
+	if err := disabled.Allow(); err != nil || NewCircuitBreaker("mistral", 0, time.Minute) != nil {
+		t.Errorf("Expected a nil breaker to allow every request, got %v", err)
+	}
+}
+
+func TestMistralLlmService_CircuitBreaker(t *testing.T) {
+	service, requests := newRetryingMistralService(t, 503, 503, 503, 503, 503)
+	WithCircuitBreaker(2, time.Minute)(service)
+
+	_, err := service.GenerateText(context.Background(), "test prompt")
+	if !errors.Is(err, ErrCircuitOpen) || *requests != 2 {
+		t.Errorf("Expected the retries to stop once the breaker opened, got %v after %d requests", err, *requests)
+	}
+	if _, err := service.GenerateText(context.Background(), "test prompt"); !errors.Is(err, ErrCircuitOpen) || *requests != 2 {
+		t.Errorf("Expected the next request to fail without being sent, got %v after %d requests", err, *requests)
+	}
+}
+
+func TestCircuitBreakerFromEnv(t *testing.T) {
+	t.Setenv("AMG_LLM_CIRCUIT_THRESHOLD", "0")
+	if b, err := circuitBreakerFromEnv("mistral"); err != nil || b != nil {
+		t.Errorf("Expected a threshold of 0 to disable the breaker, got %v, %v", b, err)
+	}
+	t.Setenv("AMG_LLM_CIRCUIT_THRESHOLD", "3")
+	t.Setenv("AMG_LLM_CIRCUIT_COOLDOWN", "2m")
+	if b, err := circuitBreakerFromEnv("mistral"); err != nil || b.threshold != 3 || b.cooldown != 2*time.Minute {
+		t.Errorf("Expected a breaker opening after 3 outages for 2m, got %+v, %v", b, err)
+	}
+	t.Setenv("AMG_LLM_CIRCUIT_THRESHOLD", "many")
+	if _, err := NewMistralLlmService(WithAPIKey("key")); err == nil {
+		t.Errorf("Expected an invalid AMG_LLM_CIRCUIT_THRESHOLD to be rejected")
+	}
+}
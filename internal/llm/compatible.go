@@ -0,0 +1,120 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/httpclient"
+	"github.com/sandwichlabs/agent-memory-graph/internal/metrics"
+)
+
+// OpenAICompatibleLlmService implements the LlmService interface using a server
+// with an OpenAI-compatible chat completions API, such as llama.cpp, vLLM, LM
+// Studio or a LiteLLM proxy. Requests and responses are those of
+// OpenAILlmService, except that the API key is optional, a reply without usage
+// counts no tokens, and JSON replies are asked for in the prompt alone once the
+// server refuses the response_format parameter.
+type OpenAICompatibleLlmService struct {
+	*OpenAILlmService
+
+	// responseFormatRefused is set once the server rejects response_format.
+	responseFormatRefused atomic.Bool
+}
+
+// NewOpenAICompatibleLlmService creates a new instance of
+// OpenAICompatibleLlmService for the API at OPENAI_COMPATIBLE_BASE_URL, such as
+// "http://localhost:8080/v1", generating text with OPENAI_COMPATIBLE_MODEL and
+// reading images with OPENAI_COMPATIBLE_VISION_MODEL, or the same model when it
+// is not set. OPENAI_COMPATIBLE_API_KEY, when set, is sent as a bearer token.
+func NewOpenAICompatibleLlmService() (*OpenAICompatibleLlmService, error) {
+	baseURL := strings.TrimRight(os.Getenv("OPENAI_COMPATIBLE_BASE_URL"), "/")
+	if baseURL == "" {
+		return nil, fmt.Errorf("OPENAI_COMPATIBLE_BASE_URL environment variable not set")
+	}
+	if parsed, err := url.Parse(baseURL); err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid OPENAI_COMPATIBLE_BASE_URL %q: must be a URL such as http://localhost:8080/v1", baseURL)
+	}
+	model := os.Getenv("OPENAI_COMPATIBLE_MODEL")
+	if model == "" {
+		return nil, fmt.Errorf("OPENAI_COMPATIBLE_MODEL environment variable not set")
+	}
+
+	client, err := httpclient.FromEnv()
+	if err != nil {
+		return nil, err
+	}
+	breaker, err := circuitBreakerFromEnv("openai-compatible")
+	if err != nil {
+		return nil, err
+	}
+	apiKey := os.Getenv("OPENAI_COMPATIBLE_API_KEY")
+	return &OpenAICompatibleLlmService{OpenAILlmService: &OpenAILlmService{
+		apiKey:          apiKey,
+		HTTPClient:      client,
+		chatModel:       model,
+		multimodalModel: envOr("OPENAI_COMPATIBLE_VISION_MODEL", model),
+		APIBaseURL:      baseURL,
+		provider:        "openai-compatible",
+		authorize: func(req *http.Request) {
+			if apiKey != "" {
+				req.Header.Set("Authorization", "Bearer "+apiKey)
+			}
+		},
+		Metrics: metrics.Default,
+		Breaker: breaker,
+	}}, nil
+}
+
+// generateJSON generates text with the json_object response format, or as plain
+// text when the server does not support it.
+func (s *OpenAICompatibleLlmService) generateJSON(ctx context.Context, prompt string, opts []GenerateOption) (string, Usage, error) {
+	if !s.responseFormatRefused.Load() {
+		text, usage, err := s.OpenAILlmService.generateJSON(ctx, prompt, opts)
+		if !s.refusedResponseFormat(ctx, err) {
+			return text, usage, err
+		}
+	}
+	return s.GenerateTextWithUsage(ctx, prompt, opts...)
+}
+
+// generateWithSchema generates text with the json_schema response format, or as
+// plain text when the server does not support it; the caller checks the reply
+// against schema either way.
+func (s *OpenAICompatibleLlmService) generateWithSchema(ctx context.Context, prompt, name string, schema json.RawMessage, opts []GenerateOption) (string, Usage, error) {
+	if !s.responseFormatRefused.Load() {
+		text, usage, err := s.OpenAILlmService.generateWithSchema(ctx, prompt, name, schema, opts)
+		if !s.refusedResponseFormat(ctx, err) {
+			return text, usage, err
+		}
+	}
+	return s.GenerateTextWithUsage(ctx, prompt, opts...)
+}
+
+// refusedResponseFormat reports whether err is the server rejecting the
+// response_format parameter, remembering it so that it is not sent again.
+func (s *OpenAICompatibleLlmService) refusedResponseFormat(ctx context.Context, err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.StatusCode {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity, http.StatusNotImplemented:
+	default:
+		return false
+	}
+	if !containsAny(strings.ToLower(apiErr.Body), []string{"response_format", "json_object", "json_schema"}) {
+		return false
+	}
+	if !s.responseFormatRefused.Swap(true) {
+		slog.WarnContext(ctx, "The server does not support response_format; asking for JSON in the prompt alone", "url", s.APIBaseURL, "status_code", apiErr.StatusCode)
+	}
+	return true
+}
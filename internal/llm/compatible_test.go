@@ -0,0 +1,136 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// compatibleRequest is a chat completion request as a test server received it.
+type compatibleRequest struct {
+	Authorization  string
+	Model          string
+	ResponseFormat map[string]any
+}
+
+// newCompatibleTestService returns a service for a server at /v1 answering chat
+// completions with handler, recording the requests.
+func newCompatibleTestService(t *testing.T, apiKey string, handler http.HandlerFunc) (*OpenAICompatibleLlmService, *[]compatibleRequest) {
+	t.Helper()
+	var requests []compatibleRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			http.Error(w, "Not found: Unexpected path "+r.URL.Path, http.StatusNotFound)
+			return
+		}
+		var payload struct {
+			Model          string         `json:"model"`
+			ResponseFormat map[string]any `json:"response_format"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, "Bad request body, not JSON", http.StatusBadRequest)
+			return
+		}
+		requests = append(requests, compatibleRequest{Authorization: r.Header.Get("Authorization"), Model: payload.Model, ResponseFormat: payload.ResponseFormat})
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("OPENAI_COMPATIBLE_BASE_URL", server.URL+"/v1/")
+	t.Setenv("OPENAI_COMPATIBLE_MODEL", "qwen2.5-7b-instruct")
+	t.Setenv("OPENAI_COMPATIBLE_VISION_MODEL", "")
+	t.Setenv("OPENAI_COMPATIBLE_API_KEY", apiKey)
+	service, err := NewLlmService(ProviderOpenAICompatible)
+	if err != nil {
+		t.Fatalf("NewLlmService failed: %v", err)
+	}
+	compatible, ok := service.(*OpenAICompatibleLlmService)
+	if !ok {
+		t.Fatalf("Expected an *OpenAICompatibleLlmService, got %T", service)
+	}
+	compatible.HTTPClient = server.Client()
+	return compatible, &requests
+}
+
+func TestNewOpenAICompatibleLlmService_MissingConfig(t *testing.T) {
+	t.Setenv("OPENAI_COMPATIBLE_BASE_URL", "http://localhost:8080/v1")
+	t.Setenv("OPENAI_COMPATIBLE_MODEL", "llama")
+	for _, key := range []string{"OPENAI_COMPATIBLE_BASE_URL", "OPENAI_COMPATIBLE_MODEL"} {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv(key, "")
+			if _, err := NewOpenAICompatibleLlmService(); err == nil || !strings.Contains(err.Error(), key) {
+				t.Errorf("Expected an error naming %s, got %v", key, err)
+			}
+		})
+	}
+
+	t.Setenv("OPENAI_COMPATIBLE_BASE_URL", "localhost:8080")
+	if _, err := NewOpenAICompatibleLlmService(); err == nil || !strings.Contains(err.Error(), "invalid OPENAI_COMPATIBLE_BASE_URL") {
+		t.Errorf("Expected a base URL without a scheme to be rejected, got %v", err)
+	}
+}
+
+func TestOpenAICompatibleLlmService_WithoutUsageOrKey(t *testing.T) {
+	service, requests := newCompatibleTestService(t, "", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "Hello"}}]}`))
+	})
+
+	text, usage, err := service.GenerateTextWithUsage(context.Background(), "Say hello")
+	if err != nil {
+		t.Fatalf("GenerateTextWithUsage failed: %v", err)
+	}
+	if text != "Hello" || usage != (Usage{}) {
+		t.Errorf("Expected the reply without usage, got %q and %+v", text, usage)
+	}
+	if req := (*requests)[0]; req.Authorization != "" || req.Model != "qwen2.5-7b-instruct" {
+		t.Errorf("Expected the configured model without an API key, got %+v", req)
+	}
+	if provider, model := ChatModel(service); provider != ProviderOpenAICompatible || model != "qwen2.5-7b-instruct" {
+		t.Errorf("Expected the openai-compatible provider and model, got %s and %s", provider, model)
+	}
+}
+
+func TestOpenAICompatibleLlmService_APIKey(t *testing.T) {
+	service, requests := newCompatibleTestService(t, "sk-local", func(w http.ResponseWriter, r *http.Request) {
+		writeChoice(w, "Hello")
+	})
+	if _, err := service.GenerateText(context.Background(), "Say hello"); err != nil {
+		t.Fatalf("GenerateText failed: %v", err)
+	}
+	if got := (*requests)[0].Authorization; got != "Bearer sk-local" {
+		t.Errorf("Expected the API key as a bearer token, got %q", got)
+	}
+}
+
+func TestOpenAICompatibleLlmService_ResponseFormatUnsupported(t *testing.T) {
+	var requests *[]compatibleRequest
+	var service *OpenAICompatibleLlmService
+	service, requests = newCompatibleTestService(t, "", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if last := (*requests)[len(*requests)-1]; last.ResponseFormat != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": {"message": "Unsupported parameter: response_format", "type": "invalid_request_error"}}`))
+			return
+		}
+		w.Write([]byte(`{"choices": [{"message": {"content": "{\"name\": \"Ada\"}"}}]}`))
+	})
+
+	var out struct {
+		Name string `json:"name"`
+	}
+	for n := 0; n < 2; n++ {
+		if _, err := GenerateStructured(context.Background(), service, "Who wrote the notes?", &out); err != nil {
+			t.Fatalf("GenerateStructured failed: %v", err)
+		}
+	}
+	if out.Name != "Ada" {
+		t.Errorf("Expected the JSON reply to be decoded, got %+v", out)
+	}
+	if len(*requests) != 3 || (*requests)[0].ResponseFormat == nil || (*requests)[1].ResponseFormat != nil || (*requests)[2].ResponseFormat != nil {
+		t.Errorf("Expected response_format to be dropped after the server refused it, got %+v", *requests)
+	}
+}
@@ -0,0 +1,214 @@
+package llm
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Price is what a model costs in US dollars per million tokens.
+type Price struct {
+	Prompt     float64
+	Completion float64
+}
+
+// Pricing maps each provider's models to their prices. A model named "*" prices
+// every model of its provider that is not listed.
+type Pricing map[Provider]map[string]Price
+
+// DefaultPricing returns the list prices of the default models and their common
+// alternatives, as published by the providers in 2025. Local models are free.
+// Prices change; set current ones with CostEstimator.SetPrice.
+func DefaultPricing() Pricing {
+	return Pricing{
+		ProviderMistral: {
+			"mistral-small-latest":  {Prompt: 0.1, Completion: 0.3},
+			"mistral-medium-latest": {Prompt: 0.4, Completion: 2},
+			"mistral-large-latest":  {Prompt: 2, Completion: 6},
+		},
+		ProviderOpenAI: {
+			"gpt-4o-mini": {Prompt: 0.15, Completion: 0.6},
+			"gpt-4o":      {Prompt: 2.5, Completion: 10},
+		},
+		// Azure deployments named after their model are priced like OpenAI's.
+		ProviderAzureOpenAI: {
+			"gpt-4o-mini": {Prompt: 0.15, Completion: 0.6},
+			"gpt-4o":      {Prompt: 2.5, Completion: 10},
+		},
+		ProviderAnthropic: {
+			"claude-haiku-4-5":  {Prompt: 1, Completion: 5},
+			"claude-sonnet-4-5": {Prompt: 3, Completion: 15},
+		},
+		ProviderGemini: {
+			"gemini-2.5-flash": {Prompt: 0.3, Completion: 2.5},
+			"gemini-2.5-pro":   {Prompt: 1.25, Completion: 10},
+		},
+		ProviderOllama:   {"*": {}},
+		ProviderTestMock: {"*": {}},
+	}
+}
+
+// Cost is an estimated price in US dollars. It is unknown when some of the tokens
+// it covers were spent on a model without a price, in which case USD covers only
+// the others.
+type Cost struct {
+	USD     float64
+	Unknown bool
+}
+
+// Add returns the combined cost of c and other.
+func (c Cost) Add(other Cost) Cost {
+	return Cost{USD: c.USD + other.USD, Unknown: c.Unknown || other.Unknown}
+}
+
+// String formats c like "$0.0125", or as "unknown".
+func (c Cost) String() string {
+	if c.Unknown {
+		return "unknown"
+	}
+	return fmt.Sprintf("$%.4f", c.USD)
+}
+
+// CostEstimator converts token usage into US dollars.
+type CostEstimator struct {
+	mu      sync.Mutex
+	pricing Pricing
+}
+
+// NewCostEstimator creates an estimator with pricing, such as DefaultPricing().
+func NewCostEstimator(pricing Pricing) *CostEstimator {
+	if pricing == nil {
+		pricing = Pricing{}
+	}
+	return &CostEstimator{pricing: pricing}
+}
+
+// SetPrice sets the price of model, or of all unlisted models of provider when
+// model is "*".
+func (e *CostEstimator) SetPrice(provider Provider, model string, price Price) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.pricing[provider] == nil {
+		e.pricing[provider] = make(map[string]Price)
+	}
+	e.pricing[provider][model] = price
+}
+
+// price returns the price of model, and whether it has one.
+func (e *CostEstimator) price(provider Provider, model string) (Price, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if price, ok := e.pricing[provider][model]; ok {
+		return price, true
+	}
+	price, ok := e.pricing[provider]["*"]
+	return price, ok
+}
+
+// Estimate returns the cost of usage on model. It is unknown for a model without a
+// price, unless usage is zero. Usage reporting only a total is priced as prompt
+// tokens.
+func (e *CostEstimator) Estimate(provider Provider, model string, usage Usage) Cost {
+	if usage == (Usage{}) {
+		return Cost{}
+	}
+	price, ok := e.price(provider, model)
+	if !ok {
+		return Cost{Unknown: true}
+	}
+	prompt, completion := usage.PromptTokens, usage.CompletionTokens
+	if prompt == 0 && completion == 0 {
+		prompt = usage.TotalTokens
+	}
+	return Cost{USD: (float64(prompt)*price.Prompt + float64(completion)*price.Completion) / 1e6}
+}
+
+// EstimateCall returns the cost of usage spent generating text with service, on
+// its chat model.
+func (e *CostEstimator) EstimateCall(service LlmService, usage Usage) Cost {
+	provider, model := serviceChatModel(service)
+	return e.Estimate(provider, model, usage)
+}
+
+// serviceChatModel returns the provider and chat model of service, looking through
+// caches.
+func serviceChatModel(service LlmService) (Provider, string) {
+	switch s := service.(type) {
+	case *CachedService:
+		return serviceChatModel(s.inner)
+	case *cachedToolCaller:
+		return serviceChatModel(s.inner)
+	case *MistralLlmService:
+		return ProviderMistral, s.chatModel
+	case *OpenAILlmService:
+		return ProviderOpenAI, s.chatModel
+	case *AzureOpenAILlmService:
+		return ProviderAzureOpenAI, s.chatModel
+	case *OpenAICompatibleLlmService:
+		return ProviderOpenAICompatible, s.chatModel
+	case *AnthropicLlmService:
+		return ProviderAnthropic, s.ChatModel
+	case *OllamaLlmService:
+		return ProviderOllama, s.ChatModel
+	case *GeminiLlmService:
+		return ProviderGemini, s.ChatModel
+	case *MockLlmService:
+		return ProviderTestMock, "mock"
+	}
+	return "", ""
+}
+
+// CostAccumulator totals the usage and cost of many calls, such as those of an
+// ingest or of a long-running server. It is safe for concurrent use.
+type CostAccumulator struct {
+	estimator *CostEstimator
+
+	mu       sync.Mutex
+	usage    Usage
+	cost     Cost
+	unpriced map[string]bool
+}
+
+// NewCostAccumulator creates an accumulator pricing calls with estimator.
+func NewCostAccumulator(estimator *CostEstimator) *CostAccumulator {
+	return &CostAccumulator{estimator: estimator, unpriced: make(map[string]bool)}
+}
+
+// Record adds usage spent on model and returns its cost.
+func (a *CostAccumulator) Record(provider Provider, model string, usage Usage) Cost {
+	cost := a.estimator.Estimate(provider, model, usage)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.usage = a.usage.Add(usage)
+	a.cost = a.cost.Add(cost)
+	if cost.Unknown {
+		a.unpriced[string(provider)+"/"+model] = true
+	}
+	return cost
+}
+
+// RecordCall adds usage spent generating text with service and returns its cost.
+func (a *CostAccumulator) RecordCall(service LlmService, usage Usage) Cost {
+	provider, model := serviceChatModel(service)
+	return a.Record(provider, model, usage)
+}
+
+// Total returns the usage and cost recorded so far.
+func (a *CostAccumulator) Total() (Usage, Cost) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.usage, a.cost
+}
+
+// Unpriced lists the models, as provider/model, whose usage made the total cost
+// unknown.
+func (a *CostAccumulator) Unpriced() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	models := make([]string, 0, len(a.unpriced))
+	for model := range a.unpriced {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+	return models
+}
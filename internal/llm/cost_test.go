@@ -0,0 +1,94 @@
+package llm
+
+import (
+	"math"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestCostEstimator_Estimate(t *testing.T) {
+	estimator := NewCostEstimator(DefaultPricing())
+	usage := Usage{PromptTokens: 2_000_000, CompletionTokens: 1_000_000, TotalTokens: 3_000_000}
+
+	cost := estimator.Estimate(ProviderMistral, "mistral-small-latest", usage)
+	if cost.Unknown || math.Abs(cost.USD-0.5) > 1e-9 {
+		t.Errorf("Expected $0.50 for mistral-small-latest, got %+v", cost)
+	}
+	if cost.String() != "$0.5000" {
+		t.Errorf("Expected the cost formatted as $0.5000, got %q", cost.String())
+	}
+
+	if cost := estimator.Estimate(ProviderMistral, "mistral-unreleased", usage); !cost.Unknown {
+		t.Errorf("Expected an unknown cost for a model without a price, got %+v", cost)
+	}
+	if cost := estimator.Estimate(ProviderMistral, "mistral-unreleased", Usage{}); cost != (Cost{}) {
+		t.Errorf("Expected no usage to cost nothing, got %+v", cost)
+	}
+	if cost := estimator.Estimate(ProviderOllama, "llama3.2", usage); cost != (Cost{}) {
+		t.Errorf("Expected local models to be free, got %+v", cost)
+	}
+
+	estimator.SetPrice(ProviderMistral, "mistral-unreleased", Price{Prompt: 1, Completion: 1})
+	if cost := estimator.Estimate(ProviderMistral, "mistral-unreleased", usage); cost.Unknown || math.Abs(cost.USD-3) > 1e-9 {
+		t.Errorf("Expected the price set to be used, got %+v", cost)
+	}
+
+	totalOnly := Usage{TotalTokens: 1_000_000}
+	if cost := estimator.Estimate(ProviderMistral, "mistral-small-latest", totalOnly); math.Abs(cost.USD-0.1) > 1e-9 {
+		t.Errorf("Expected a bare total to be priced as prompt tokens, got %+v", cost)
+	}
+}
+
+func TestCostEstimator_EstimateCall(t *testing.T) {
+	estimator := NewCostEstimator(DefaultPricing())
+	service, err := NewMistralLlmService(WithAPIKey("test-key"))
+	if err != nil {
+		t.Fatalf("NewMistralLlmService failed: %v", err)
+	}
+	cached, err := NewCachedService(service, t.TempDir())
+	if err != nil {
+		t.Fatalf("NewCachedService failed: %v", err)
+	}
+	usage := Usage{PromptTokens: 1_000_000}
+
+	for _, s := range []LlmService{service, cached} {
+		if cost := estimator.EstimateCall(s, usage); cost.Unknown || math.Abs(cost.USD-0.1) > 1e-9 {
+			t.Errorf("Expected %T to be priced as mistral-small-latest, got %+v", s, cost)
+		}
+	}
+	if cost := estimator.EstimateCall(NewMockLlmService(), usage); cost != (Cost{}) {
+		t.Errorf("Expected the mock to be free, got %+v", cost)
+	}
+}
+
+func TestCostAccumulator(t *testing.T) {
+	costs := NewCostAccumulator(NewCostEstimator(DefaultPricing()))
+	usage := Usage{PromptTokens: 1000, CompletionTokens: 100, TotalTokens: 1100}
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			costs.Record(ProviderOpenAI, "gpt-4o-mini", usage)
+		}()
+	}
+	wg.Wait()
+
+	total, cost := costs.Total()
+	if total.TotalTokens != 11000 {
+		t.Errorf("Expected 11000 tokens in total, got %+v", total)
+	}
+	if cost.Unknown || math.Abs(cost.USD-0.0021) > 1e-9 {
+		t.Errorf("Expected $0.0021 in total, got %+v", cost)
+	}
+
+	costs.Record(ProviderAnthropic, "claude-unreleased", usage)
+	if _, cost := costs.Total(); !cost.Unknown || cost.String() != "unknown" {
+		t.Errorf("Expected the total to become unknown, got %+v", cost)
+	}
+	if unpriced := costs.Unpriced(); !reflect.DeepEqual(unpriced, []string{"anthropic/claude-unreleased"}) {
+		t.Errorf("Expected the unpriced model to be listed, got %v", unpriced)
+	}
+}
@@ -0,0 +1,74 @@
+package llm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultMaxDocumentBytes is the size of the largest PDF sent to a provider unless
+// the service is configured otherwise.
+const DefaultMaxDocumentBytes = 20 << 20
+
+var (
+	// ErrDocumentTooLarge is returned when a PDF exceeds the size limit, before
+	// anything is sent.
+	ErrDocumentTooLarge = errors.New("document too large")
+	// ErrDocumentsUnsupported is returned by ExtractTextFromDocument for providers
+	// that cannot read PDFs.
+	ErrDocumentsUnsupported = errors.New("documents unsupported")
+)
+
+// pageMarkerPattern matches the page markers of extracted documents at the start
+// of a line.
+var pageMarkerPattern = regexp.MustCompile(`(?m)^\[Page \d+\]\n?`)
+
+// PageMarker returns the line that starts page n, counted from 1, of the text
+// extracted from a document, such as "[Page 3]".
+func PageMarker(n int) string {
+	return fmt.Sprintf("[Page %d]", n)
+}
+
+// SplitPages splits the text of a document at its page markers, returning the
+// text of each page without them. Text before the first marker is dropped when
+// blank and taken as a page otherwise; text without markers is a single page.
+func SplitPages(text string) []string {
+	var pages []string
+	for n, page := range pageMarkerPattern.Split(text, -1) {
+		page = strings.TrimSpace(page)
+		if n == 0 && page == "" {
+			continue
+		}
+		pages = append(pages, page)
+	}
+	return pages
+}
+
+// documentPrompt asks for the text of each page to start with its page marker,
+// after prompt.
+func documentPrompt(prompt string) string {
+	return prompt + "\n\nStart the text of each page of the document with a line holding only its page marker, " +
+		PageMarker(1) + " for the first page, " + PageMarker(2) + " for the second and so on."
+}
+
+// checkDocument rejects an empty document, one that is not a PDF and one over
+// maxBytes; zero disables the limit.
+func checkDocument(pdf []byte, maxBytes int) error {
+	if len(pdf) == 0 {
+		return fmt.Errorf("document data is empty")
+	}
+	if !bytes.HasPrefix(pdf, []byte("%PDF-")) {
+		return fmt.Errorf("document is not a PDF")
+	}
+	if maxBytes > 0 && len(pdf) > maxBytes {
+		return fmt.Errorf("%w: %d bytes, more than the %d allowed", ErrDocumentTooLarge, len(pdf), maxBytes)
+	}
+	return nil
+}
+
+// documentsUnsupported returns the error of ExtractTextFromDocument for provider.
+func documentsUnsupported(provider Provider) error {
+	return fmt.Errorf("%w: %s cannot read PDF documents; use mistral or anthropic, or convert the pages to images", ErrDocumentsUnsupported, provider)
+}
@@ -0,0 +1,175 @@
+package llm
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// readFixturePDF returns testdata/two-pages.pdf, a PDF of two pages of text.
+func readFixturePDF(t *testing.T) []byte {
+	t.Helper()
+	pdf, err := os.ReadFile("testdata/two-pages.pdf")
+	if err != nil {
+		t.Fatalf("Failed to read the fixture PDF: %v", err)
+	}
+	return pdf
+}
+
+func TestMistralLlmService_ExtractTextFromDocument(t *testing.T) {
+	pdf := readFixturePDF(t)
+	reply := PageMarker(1) + "\nAda Lovelace wrote the notes.\n" + PageMarker(2) + "\nThe Analytical Engine."
+	var payload struct {
+		Model     string `json:"model"`
+		MaxTokens int    `json:"max_tokens"`
+		Messages  []struct {
+			Role    string `json:"role"`
+			Content []struct {
+				Type        string `json:"type"`
+				Text        string `json:"text"`
+				DocumentURL string `json:"document_url"`
+			} `json:"content"`
+		} `json:"messages"`
+	}
+	server := mockMistralServer(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, "Bad request body, not JSON", http.StatusBadRequest)
+			return
+		}
+		writeChoice(w, reply)
+	})
+	defer server.Close()
+
+	service, err := NewMistralLlmService(WithAPIKey("test_api_key"), WithMultimodalModel("mistral-medium-latest"))
+	if err != nil {
+		t.Fatalf("NewMistralLlmService failed: %v", err)
+	}
+	service.HTTPClient = server.Client()
+	service.APIBaseURL = server.URL
+
+	text, err := service.ExtractTextFromDocument(context.Background(), "Extract the text", pdf)
+	if err != nil {
+		t.Fatalf("ExtractTextFromDocument failed: %v", err)
+	}
+	if text != reply {
+		t.Errorf("Expected text %q, got %q", reply, text)
+	}
+
+	if payload.Model != "mistral-medium-latest" {
+		t.Errorf("Expected the multimodal model, got %q", payload.Model)
+	}
+	if payload.MaxTokens != documentMaxTokens {
+		t.Errorf("Expected max_tokens %d, got %d", documentMaxTokens, payload.MaxTokens)
+	}
+	if len(payload.Messages) != 1 || payload.Messages[0].Role != "user" || len(payload.Messages[0].Content) != 2 {
+		t.Fatalf("Expected one user message with a text and a document, got %+v", payload.Messages)
+	}
+	content := payload.Messages[0].Content
+	if content[0].Type != "text" || !strings.HasPrefix(content[0].Text, "Extract the text") || !strings.Contains(content[0].Text, PageMarker(1)) {
+		t.Errorf("Expected the prompt asking for page markers first, got %+v", content[0])
+	}
+	wantURL := "data:application/pdf;base64," + base64.StdEncoding.EncodeToString(pdf)
+	if content[1].Type != "document_url" || content[1].DocumentURL != wantURL {
+		t.Errorf("Expected the PDF as a base64 document_url, got type %q and %d characters", content[1].Type, len(content[1].DocumentURL))
+	}
+}
+
+func TestMistralLlmService_ExtractTextFromDocument_TooLarge(t *testing.T) {
+	pdf := readFixturePDF(t)
+	requests := 0
+	server := mockMistralServer(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		writeChoice(w, "unexpected")
+	})
+	defer server.Close()
+
+	service, err := NewMistralLlmService(WithAPIKey("test_api_key"), WithMaxDocumentBytes(len(pdf)-1))
+	if err != nil {
+		t.Fatalf("NewMistralLlmService failed: %v", err)
+	}
+	service.HTTPClient = server.Client()
+	service.APIBaseURL = server.URL
+
+	if _, err := service.ExtractTextFromDocument(context.Background(), "Extract the text", pdf); !errors.Is(err, ErrDocumentTooLarge) {
+		t.Errorf("Expected an error wrapping ErrDocumentTooLarge, got %v", err)
+	}
+	if _, err := service.ExtractTextFromDocument(context.Background(), "Extract the text", []byte("not a pdf")); err == nil || !strings.Contains(err.Error(), "not a PDF") {
+		t.Errorf("Expected an error for a document that is not a PDF, got %v", err)
+	}
+	if requests != 0 {
+		t.Errorf("Expected no request to be sent, got %d", requests)
+	}
+}
+
+func TestAnthropicLlmService_ExtractTextFromDocument(t *testing.T) {
+	pdf := readFixturePDF(t)
+	var payload struct {
+		Messages []struct {
+			Content []struct {
+				Type   string            `json:"type"`
+				Text   string            `json:"text"`
+				Source map[string]string `json:"source"`
+			} `json:"content"`
+		} `json:"messages"`
+	}
+	service := newAnthropicTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, "Bad request body, not JSON", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"content": [{"type": "text", "text": "[Page 1]\nAda"}], "stop_reason": "end_turn"}`))
+	})
+
+	text, err := service.ExtractTextFromDocument(context.Background(), "Extract the text", pdf)
+	if err != nil {
+		t.Fatalf("ExtractTextFromDocument failed: %v", err)
+	}
+	if text != "[Page 1]\nAda" {
+		t.Errorf("Expected the reply's text, got %q", text)
+	}
+	if len(payload.Messages) != 1 || len(payload.Messages[0].Content) != 2 {
+		t.Fatalf("Expected one message with a document and a text, got %+v", payload.Messages)
+	}
+	document := payload.Messages[0].Content[0]
+	want := map[string]string{"type": "base64", "media_type": "application/pdf", "data": base64.StdEncoding.EncodeToString(pdf)}
+	if document.Type != "document" || !reflect.DeepEqual(document.Source, want) {
+		t.Errorf("Expected a base64 PDF document block, got type %q", document.Type)
+	}
+	if payload.Messages[0].Content[1].Type != "text" {
+		t.Errorf("Expected the prompt after the document, got %+v", payload.Messages[0].Content[1])
+	}
+}
+
+func TestExtractTextFromDocument_Unsupported(t *testing.T) {
+	service := newOpenAITestService(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Expected no request, got %s %s", r.Method, r.URL.Path)
+	})
+	_, err := service.ExtractTextFromDocument(context.Background(), "Extract the text", readFixturePDF(t))
+	if !errors.Is(err, ErrDocumentsUnsupported) {
+		t.Errorf("Expected an error wrapping ErrDocumentsUnsupported, got %v", err)
+	}
+}
+
+func TestSplitPages(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"[Page 1]\nfirst\n\n[Page 2]\nsecond\n", []string{"first", "second"}},
+		{"Here is the text:\n[Page 1]\nfirst", []string{"Here is the text:", "first"}},
+		{"no markers", []string{"no markers"}},
+		{"see [Page 2] below", []string{"see [Page 2] below"}},
+	}
+	for _, test := range tests {
+		if got := SplitPages(test.text); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Expected SplitPages(%q) to be %q, got %q", test.text, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package llm
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EnvProvider names the environment variable selecting the LLM provider, such as
+// "openai". Each provider then reads its own variables for its key and models,
+// such as OPENAI_API_KEY or MISTRAL_CHAT_MODEL.
+const EnvProvider = "AMG_LLM_PROVIDER"
+
+// ParseProvider returns the provider named name, ignoring case and surrounding
+// spaces. The error for an unknown name lists the providers to choose from and
+// suggests the closest one for a typo.
+func ParseProvider(name string) (Provider, error) {
+	normalized := strings.ToLower(strings.TrimSpace(name))
+	var names []string
+	suggestion := ""
+	for _, provider := range Providers() {
+		if normalized == string(provider) {
+			return provider, nil
+		}
+		names = append(names, string(provider))
+		if suggestion == "" && normalized != "" && editDistance(normalized, string(provider)) <= 2 {
+			suggestion = string(provider)
+		}
+	}
+	hint := ""
+	if suggestion != "" {
+		hint = fmt.Sprintf(" (did you mean %q?)", suggestion)
+	}
+	return "", fmt.Errorf("unknown LLM provider %q%s: choose one of %s", name, hint, strings.Join(names, ", "))
+}
+
+// ProviderFromEnv returns the provider named by AMG_LLM_PROVIDER, or
+// ProviderMistral when it is not set.
+func ProviderFromEnv() (Provider, error) {
+	value := os.Getenv(EnvProvider)
+	if value == "" {
+		return ProviderMistral, nil
+	}
+	provider, err := ParseProvider(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s: %w", EnvProvider, err)
+	}
+	return provider, nil
+}
+
+// NewFromEnv creates the service of the provider named by AMG_LLM_PROVIDER,
+// Mistral when it is not set, configured by that provider's variables. opts
+// configure the Mistral service as for NewLlmService.
+func NewFromEnv(opts ...MistralOption) (LlmService, error) {
+	provider, err := ProviderFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return NewLlmService(provider, opts...)
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
@@ -0,0 +1,63 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseProvider(t *testing.T) {
+	tests := map[string]Provider{
+		"mistral":   ProviderMistral,
+		"openai":    ProviderOpenAI,
+		"anthropic": ProviderAnthropic,
+		"ollama":    ProviderOllama,
+		"gemini":    ProviderGemini,
+		"testing":   ProviderTestMock,
+		" OpenAI ":  ProviderOpenAI,
+		"ANTHROPIC": ProviderAnthropic,
+		"Mistral\n": ProviderMistral,
+	}
+	for name, want := range tests {
+		got, err := ParseProvider(name)
+		if err != nil || got != want {
+			t.Errorf("Expected %q to be %s, got %q, %v", name, want, got, err)
+		}
+	}
+}
+
+func TestParseProvider_Unknown(t *testing.T) {
+	_, err := ParseProvider("openia")
+	want := `unknown LLM provider "openia" (did you mean "openai"?): choose one of mistral, openai, anthropic, ollama, gemini, azure-openai, openai-compatible, testing`
+	if err == nil || err.Error() != want {
+		t.Errorf("Expected %q, got %v", want, err)
+	}
+	_, err = ParseProvider("bedrock")
+	if err == nil || strings.Contains(err.Error(), "did you mean") || !strings.Contains(err.Error(), "choose one of mistral") {
+		t.Errorf("Expected the valid providers without a suggestion, got %v", err)
+	}
+	if _, err := ParseProvider(""); err == nil {
+		t.Errorf("Expected an empty provider to be rejected")
+	}
+}
+
+func TestNewFromEnv(t *testing.T) {
+	t.Setenv(EnvProvider, "")
+	if provider, err := ProviderFromEnv(); err != nil || provider != ProviderMistral {
+		t.Errorf("Expected mistral by default, got %q, %v", provider, err)
+	}
+
+	t.Setenv(EnvProvider, "Testing")
+	service, err := NewFromEnv()
+	if err != nil {
+		t.Fatalf("NewFromEnv failed: %v", err)
+	}
+	if _, ok := service.(*MockLlmService); !ok {
+		t.Errorf("Expected the mock service, got %T", service)
+	}
+
+	t.Setenv(EnvProvider, "gemeni")
+	_, err = NewFromEnv()
+	if err == nil || !strings.Contains(err.Error(), EnvProvider) || !strings.Contains(err.Error(), `did you mean "gemini"`) {
+		t.Errorf("Expected the typo in %s to be reported, got %v", EnvProvider, err)
+	}
+}
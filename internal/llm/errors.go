@@ -0,0 +1,174 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/redact"
+)
+
+// Classes of provider failures. Errors returned by the services wrap one of them
+// when the failure can be told apart, so that callers can use errors.Is to decide
+// whether to retry later, give up on a request or stop altogether; errors.As with
+// *APIError gives the status and body of the response.
+var (
+	// ErrRateLimited means too many requests were sent; retry later.
+	ErrRateLimited = errors.New("rate limited")
+	// ErrQuotaExceeded means the account ran out of credit or quota; retrying
+	// will not help until it is topped up.
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	// ErrUnauthorized means the API key is missing, invalid or not allowed to use
+	// the model.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrContextLengthExceeded means the request is too long for the model.
+	ErrContextLengthExceeded = errors.New("context length exceeded")
+	// ErrContentFiltered means the provider refused the request or its answer
+	// on content grounds.
+	ErrContentFiltered = errors.New("content filtered")
+	// ErrUpstream means the provider failed or is overloaded; retry later.
+	ErrUpstream = errors.New("upstream error")
+	// ErrUnreachable means the provider's API could not be reached at all, such
+	// as for a wrong base URL or no network access.
+	ErrUnreachable = errors.New("unreachable")
+)
+
+// ErrUnsupported means the provider cannot do what was asked, such as transcribe
+// audio; nothing was sent.
+var ErrUnsupported = errors.New("unsupported")
+
+// ErrTruncated means the reply was cut off at the request's max tokens. Errors
+// wrapping it are *TruncatedError, which has the text generated so far.
+var ErrTruncated = errors.New("reply truncated")
+
+// TruncatedError is a reply cut off at the request's max tokens, reported by the
+// provider's finish reason. Callers may retry with a larger WithMaxTokens or send
+// less text. It unwraps to ErrTruncated.
+type TruncatedError struct {
+	// Provider names the API, such as "mistral".
+	Provider string
+	// Text is the reply up to where it was cut off.
+	Text  string
+	Usage Usage
+}
+
+func (e *TruncatedError) Error() string {
+	return fmt.Sprintf("%s reply truncated at the max tokens after %d completion tokens", e.Provider, e.Usage.CompletionTokens)
+}
+
+func (e *TruncatedError) Unwrap() error { return ErrTruncated }
+
+// APIError is a response with a non-OK status from a provider's API. It unwraps
+// to the class of the failure, such as ErrRateLimited, when it is known.
+type APIError struct {
+	// Provider names the API, such as "mistral".
+	Provider string
+	// Kind qualifies the request, such as "multimodal", or is empty.
+	Kind       string
+	StatusCode int
+	// Status is the status line, such as "429 Too Many Requests".
+	Status string
+	// Body is the whole response body; Error truncates it.
+	Body string
+	// Class is one of the classes of failures above, or nil.
+	Class error
+}
+
+// newAPIError returns the error of a response with a non-OK status and body.
+func newAPIError(provider, kind string, resp *http.Response, body []byte) *APIError {
+	return &APIError{
+		Provider:   provider,
+		Kind:       kind,
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Body:       string(body),
+		Class:      classifyStatus(resp.StatusCode, string(body)),
+	}
+}
+
+func (e *APIError) Error() string {
+	label := ""
+	if e.Kind != "" {
+		label = " (" + e.Kind + ")"
+	}
+	return fmt.Sprintf("%s API error%s: %s - %s", e.Provider, label, e.Status, redact.Truncate(e.Body, redact.MaxBodyBytes))
+}
+
+func (e *APIError) Unwrap() error { return e.Class }
+
+// classifiedError is a failure reported other than by status, such as by the
+// provider's SDK or in a stream, with the class it was found to be.
+type classifiedError struct {
+	err   error
+	class error
+}
+
+func (e *classifiedError) Error() string { return e.err.Error() }
+
+func (e *classifiedError) Unwrap() []error { return []error{e.err, e.class} }
+
+// classified returns err wrapping class as well, or err when class is nil.
+func classified(err, class error) error {
+	if class == nil {
+		return err
+	}
+	return &classifiedError{err: err, class: class}
+}
+
+// Phrases the providers use in error bodies, lowercase.
+var (
+	quotaPhrases         = []string{"quota", "billing", "credit balance", "payment required"}
+	contextLengthPhrases = []string{"context length", "context_length", "context window", "maximum context", "too many tokens", "prompt is too long", "too large for model", "input is too long", "exceeds the maximum number of tokens"}
+	contentFilterPhrases = []string{"content filter", "content_filter", "content management policy", "safety", "moderation"}
+)
+
+// classifyStatus returns the class of a failure with status and body, or nil.
+func classifyStatus(status int, body string) error {
+	lower := strings.ToLower(body)
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrUnauthorized
+	case status == http.StatusPaymentRequired:
+		return ErrQuotaExceeded
+	case status == http.StatusTooManyRequests:
+		if containsAny(lower, quotaPhrases) {
+			return ErrQuotaExceeded
+		}
+		return ErrRateLimited
+	case status == http.StatusRequestEntityTooLarge:
+		return ErrContextLengthExceeded
+	case status >= 500:
+		return ErrUpstream
+	case containsAny(lower, contextLengthPhrases):
+		return ErrContextLengthExceeded
+	case containsAny(lower, contentFilterPhrases):
+		return ErrContentFiltered
+	}
+	return nil
+}
+
+// classifyAnthropicError returns the class of an error event of the Anthropic
+// streaming API, which has a type rather than a status.
+func classifyAnthropicError(errorType, message string) error {
+	switch errorType {
+	case "authentication_error", "permission_error":
+		return ErrUnauthorized
+	case "rate_limit_error":
+		return ErrRateLimited
+	case "overloaded_error", "api_error":
+		return ErrUpstream
+	case "request_too_large":
+		return ErrContextLengthExceeded
+	}
+	return classifyStatus(http.StatusBadRequest, message)
+}
+
+func containsAny(s string, phrases []string) bool {
+	for _, phrase := range phrases {
+		if strings.Contains(s, phrase) {
+			return true
+		}
+	}
+	return false
+}
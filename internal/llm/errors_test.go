@@ -0,0 +1,167 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/redact"
+)
+
+func TestClassifyStatus(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		want   error
+	}{
+		{http.StatusUnauthorized, `{"message": "Unauthorized"}`, ErrUnauthorized},
+		{http.StatusForbidden, `{"message": "model not available"}`, ErrUnauthorized},
+		{http.StatusPaymentRequired, `{"message": "Payment Required"}`, ErrQuotaExceeded},
+		{http.StatusTooManyRequests, `{"message": "Requests rate limit exceeded"}`, ErrRateLimited},
+		{http.StatusTooManyRequests, `{"error": {"code": "insufficient_quota"}}`, ErrQuotaExceeded},
+		{http.StatusRequestEntityTooLarge, `request too large`, ErrContextLengthExceeded},
+		{http.StatusBadRequest, `{"message": "Prompt contains 40000 tokens, too large for model with 32768 maximum context length"}`, ErrContextLengthExceeded},
+		{http.StatusBadRequest, `{"error": {"code": "context_length_exceeded"}}`, ErrContextLengthExceeded},
+		{http.StatusBadRequest, `{"error": {"code": "content_filter"}}`, ErrContentFiltered},
+		{http.StatusBadRequest, `{"message": "invalid temperature"}`, nil},
+		{http.StatusUnprocessableEntity, `{"message": "invalid model"}`, nil},
+		{http.StatusInternalServerError, `oops`, ErrUpstream},
+		{http.StatusBadGateway, ``, ErrUpstream},
+		{http.StatusServiceUnavailable, ``, ErrUpstream},
+		{529, `{"type": "overloaded_error"}`, ErrUpstream},
+	}
+	for _, tt := range tests {
+		if got := classifyStatus(tt.status, tt.body); got != tt.want {
+			t.Errorf("Expected %d %s to be %v, got %v", tt.status, tt.body, tt.want, got)
+		}
+	}
+}
+
+func TestClassifyAnthropicError(t *testing.T) {
+	tests := map[string]error{
+		"authentication_error": ErrUnauthorized,
+		"rate_limit_error":     ErrRateLimited,
+		"overloaded_error":     ErrUpstream,
+		"request_too_large":    ErrContextLengthExceeded,
+		"not_found_error":      nil,
+	}
+	for errorType, want := range tests {
+		if got := classifyAnthropicError(errorType, "details"); got != want {
+			t.Errorf("Expected %s to be %v, got %v", errorType, want, got)
+		}
+	}
+}
+
+func TestMistralLlmService_TypedErrors(t *testing.T) {
+	for status, want := range map[int]error{
+		http.StatusUnauthorized:        ErrUnauthorized,
+		http.StatusTooManyRequests:     ErrRateLimited,
+		http.StatusInternalServerError: ErrUpstream,
+	} {
+		service, _ := newRetryingMistralService(t, status)
+		service.Retry.MaxAttempts = 1
+		_, err := service.GenerateText(context.Background(), "test prompt")
+		if !errors.Is(err, want) {
+			t.Errorf("Expected status %d to fail with %v, got %v", status, want, err)
+		}
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != status || apiErr.Provider != "mistral" {
+			t.Errorf("Expected an APIError with status %d, got %#v", status, apiErr)
+		}
+	}
+}
+
+func TestMistralLlmService_LogsRedactedBodies(t *testing.T) {
+	var logs bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	body := "Prompt about customer Jane Doe was rejected. " + strings.Repeat("x", 2000)
+	server := mockMistralServer(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, body, http.StatusBadRequest)
+	})
+	defer server.Close()
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	service, err := NewMistralLlmService(WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("NewMistralLlmService failed: %v", err)
+	}
+
+	_, err = service.GenerateText(context.Background(), "Summarize the account of Jane Doe")
+	if err == nil || len(err.Error()) > 1000 {
+		t.Errorf("Expected an error with a truncated body, got %v", err)
+	}
+	if strings.Contains(logs.String(), strings.Repeat("x", 600)) {
+		t.Errorf("Expected the logged body to be truncated, got %q", logs.String())
+	}
+
+	t.Setenv(redact.EnvRedact, "true")
+	logs.Reset()
+	service.GenerateText(context.Background(), "Summarize the account of Jane Doe")
+	if strings.Contains(logs.String(), "Jane Doe") || !strings.Contains(logs.String(), "sha256:") {
+		t.Errorf("Expected no content in the logs when redacting, got %q", logs.String())
+	}
+}
+
+// writeFinish replies with a chat completion holding content that finished for
+// reason.
+func writeFinish(w http.ResponseWriter, content, reason string) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"choices": []map[string]interface{}{
+			{"message": map[string]interface{}{"role": "assistant", "content": content}, "finish_reason": reason},
+		},
+		"usage": map[string]int{"prompt_tokens": 12, "completion_tokens": 5, "total_tokens": 17},
+	})
+}
+
+func TestMistralLlmService_FinishReason(t *testing.T) {
+	reason := "stop"
+	server := mockMistralServer(func(w http.ResponseWriter, r *http.Request) {
+		writeFinish(w, `{"entities": [{"name": "Ada`, reason)
+	})
+	defer server.Close()
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	service, err := NewMistralLlmService(WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("NewMistralLlmService failed: %v", err)
+	}
+
+	if _, err := service.GenerateText(context.Background(), "test prompt"); err != nil {
+		t.Errorf("Expected a reply that stopped to succeed, got %v", err)
+	}
+
+	reason = "length"
+	text, usage, err := service.GenerateTextWithUsage(context.Background(), "test prompt", WithMaxTokens(5))
+	if !errors.Is(err, ErrTruncated) {
+		t.Fatalf("Expected %v, got %v", ErrTruncated, err)
+	}
+	var truncated *TruncatedError
+	if !errors.As(err, &truncated) || truncated.Text != `{"entities": [{"name": "Ada` || truncated.Provider != "mistral" {
+		t.Errorf("Expected the partial text in the error, got %#v", truncated)
+	}
+	if text != truncated.Text || usage.TotalTokens != 17 {
+		t.Errorf("Expected the partial text and usage returned, got %q and %+v", text, usage)
+	}
+
+	if _, _, err := GenerateJSON(context.Background(), service, "test prompt"); !errors.Is(err, ErrTruncated) {
+		t.Errorf("Expected a truncated JSON reply to fail with %v, got %v", ErrTruncated, err)
+	}
+}
+
+func TestOpenAILlmService_FinishReason(t *testing.T) {
+	service := newOpenAITestService(t, func(w http.ResponseWriter, r *http.Request) {
+		writeFinish(w, "The first half", "length")
+	})
+
+	_, err := service.GenerateText(context.Background(), "test prompt")
+	var truncated *TruncatedError
+	if !errors.As(err, &truncated) || truncated.Provider != "openai" || truncated.Text != "The first half" || truncated.Usage.CompletionTokens != 5 {
+		t.Errorf("Expected a TruncatedError with the partial text, got %v", err)
+	}
+}
@@ -0,0 +1,318 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/budget"
+	"github.com/sandwichlabs/agent-memory-graph/internal/httpclient"
+	"github.com/sandwichlabs/agent-memory-graph/internal/metrics"
+	"github.com/sandwichlabs/agent-memory-graph/internal/redact"
+	"google.golang.org/genai"
+)
+
+// GeminiLlmService implements the LlmService interface with the genai client for
+// the Gemini API, as the Gemini embedding service does.
+type GeminiLlmService struct {
+	client *genai.Client
+	// ChatModel generates text and MultimodalModel reads images; both default to
+	// current Gemini models and may be overridden after construction.
+	ChatModel       string
+	MultimodalModel string
+	// MaxImageBytes caps each image sent inline and MaxTotalImageBytes all the
+	// images of a request; larger ones are rejected before sending. Zero disables
+	// a cap.
+	MaxImageBytes      int
+	MaxTotalImageBytes int
+	// MaxAudioBytes caps the recordings sent inline for transcription; zero
+	// disables the cap.
+	MaxAudioBytes int
+	// Metrics receives a measurement of every request; metrics.Default unless
+	// replaced.
+	Metrics metrics.Sink
+	// Breaker fails requests fast while the provider is down; nil disables it.
+	Breaker *CircuitBreaker
+}
+
+// geminiMaxInlineBytes is the size of the largest request with inline data the
+// Gemini API accepts.
+const geminiMaxInlineBytes = 20 << 20
+
+// NewGeminiLlmService creates a new instance of GeminiLlmService.
+// It requires the API key to be set in the GEMINI_API_KEY environment variable.
+func NewGeminiLlmService() (*GeminiLlmService, error) {
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" {
+		return nil, fmt.Errorf("GEMINI_API_KEY environment variable not set")
+	}
+	client, err := httpclient.FromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return newGeminiLlmService(&genai.ClientConfig{APIKey: apiKey, Backend: genai.BackendGeminiAPI, HTTPClient: client})
+}
+
+// newGeminiLlmService creates the service with a client for config, which tests
+// point at a local server.
+func newGeminiLlmService(config *genai.ClientConfig) (*GeminiLlmService, error) {
+	client, err := genai.NewClient(context.Background(), config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create genai client: %w", err)
+	}
+	breaker, err := circuitBreakerFromEnv("gemini")
+	if err != nil {
+		return nil, err
+	}
+	return &GeminiLlmService{
+		client:             client,
+		ChatModel:          "gemini-2.5-flash",
+		MultimodalModel:    "gemini-2.5-flash",
+		MaxImageBytes:      DefaultMaxImageBytes,
+		MaxTotalImageBytes: DefaultMaxTotalImageBytes,
+		MaxAudioBytes:      geminiMaxInlineBytes,
+		Metrics:            metrics.Default,
+		Breaker:            breaker,
+	}, nil
+}
+
+// GenerateText generates text using the Gemini generateContent API.
+func (s *GeminiLlmService) GenerateText(ctx context.Context, prompt string, opts ...GenerateOption) (string, error) {
+	content, _, err := s.GenerateTextWithUsage(ctx, prompt, opts...)
+	return content, err
+}
+
+// Ping looks up the chat model with the service's key.
+func (s *GeminiLlmService) Ping(ctx context.Context) error {
+	if _, err := s.client.Models.Get(ctx, s.ChatModel, nil); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		slog.ErrorContext(ctx, "GeminiLlmService: Ping failed", "model", s.ChatModel, "error", err)
+		var apiErr genai.APIError
+		if errors.As(err, &apiErr) {
+			return fmt.Errorf("gemini ping: %w", classifyGemini(err))
+		}
+		return fmt.Errorf("%w: failed to reach the gemini API: %w", ErrUnreachable, err)
+	}
+	return nil
+}
+
+// GenerateTextWithUsage generates text like GenerateText, also returning the
+// token usage the API reports.
+func (s *GeminiLlmService) GenerateTextWithUsage(ctx context.Context, prompt string, opts ...GenerateOption) (string, Usage, error) {
+	return s.converse(ctx, []Message{{Role: RoleUser, Content: prompt}}, opts)
+}
+
+// Chat generates the assistant's reply to a conversation, sending the system messages as
+// the system instruction.
+func (s *GeminiLlmService) Chat(ctx context.Context, messages []Message, opts ...GenerateOption) (string, error) {
+	content, _, err := s.converse(ctx, messages, opts)
+	return content, err
+}
+
+// converse generates the reply to messages, returning the usage the API reports.
+func (s *GeminiLlmService) converse(ctx context.Context, messages []Message, opts []GenerateOption) (string, Usage, error) {
+	slog.InfoContext(ctx, "GeminiLlmService: Chat called", "model", s.ChatModel, "messages", len(messages))
+
+	if err := checkMessages(messages); err != nil {
+		return "", Usage{}, err
+	}
+	system, turns := splitSystem(messages)
+	contents := make([]*genai.Content, 0, len(turns))
+	for _, turn := range turns {
+		role := genai.Role(genai.RoleUser)
+		if turn.Role == RoleAssistant {
+			role = genai.RoleModel
+		}
+		contents = append(contents, genai.NewContentFromText(turn.Content, role))
+	}
+	params := generation(defaultTemperature, defaultMaxTokens, opts)
+	config := params.geminiConfig()
+	if system != "" {
+		config.SystemInstruction = genai.NewContentFromText(system, genai.RoleUser)
+	}
+
+	content, usage, err := s.generate(ctx, params.modelOr(s.ChatModel), contents, config, "")
+	if err != nil {
+		return "", Usage{}, err
+	}
+	slog.InfoContext(ctx, "GeminiLlmService: Text generated successfully", "response_length", len(content), "total_tokens", usage.TotalTokens)
+	return content, usage, nil
+}
+
+// generateJSON generates text with the application/json response MIME type.
+func (s *GeminiLlmService) generateJSON(ctx context.Context, prompt string, opts []GenerateOption) (string, Usage, error) {
+	slog.InfoContext(ctx, "GeminiLlmService: generateJSON called", "model", s.ChatModel, "prompt_length", len(prompt))
+
+	contents := []*genai.Content{genai.NewContentFromText(prompt, genai.RoleUser)}
+	params := generation(jsonTemperature, jsonMaxTokens, opts)
+	config := params.geminiConfig()
+	config.ResponseMIMEType = "application/json"
+	return s.generate(ctx, params.modelOr(s.ChatModel), contents, config, "json")
+}
+
+// GenerateTextStream generates text like GenerateText, emitting the text of each
+// response the streaming generateContent API sends.
+func (s *GeminiLlmService) GenerateTextStream(ctx context.Context, prompt string, opts ...GenerateOption) (<-chan string, <-chan error) {
+	slog.InfoContext(ctx, "GeminiLlmService: GenerateTextStream called", "model", s.ChatModel, "prompt_length", len(prompt))
+
+	contents := []*genai.Content{genai.NewContentFromText(prompt, genai.RoleUser)}
+	params := generation(defaultTemperature, defaultMaxTokens, opts)
+	model, config := params.modelOr(s.ChatModel), params.geminiConfig()
+	return streamText(ctx, streamCall{provider: "gemini", metrics: s.Metrics, breaker: s.Breaker}, func(emit func(string) bool) (usage Usage, err error) {
+		if err := checkModel(model); err != nil {
+			return Usage{}, err
+		}
+		for response, err := range s.client.Models.GenerateContentStream(ctx, model, contents, config) {
+			if err != nil {
+				return usage, fmt.Errorf("gemini API error: %w", classifyGemini(err))
+			}
+			// Each response counts the tokens so far.
+			if metadata := response.UsageMetadata; metadata != nil {
+				usage = Usage{
+					PromptTokens:     int(metadata.PromptTokenCount),
+					CompletionTokens: int(metadata.CandidatesTokenCount),
+					TotalTokens:      int(metadata.TotalTokenCount),
+				}
+			}
+			if text := response.Text(); text != "" && !emit(text) {
+				return usage, nil
+			}
+		}
+		return usage, nil
+	})
+}
+
+// ExtractTextFromImage extracts text from an image using a multimodal Gemini model;
+// see ExtractTextFromImages.
+func (s *GeminiLlmService) ExtractTextFromImage(ctx context.Context, prompt string, image []byte, mimeType string, opts ...GenerateOption) (string, error) {
+	return s.ExtractTextFromImages(ctx, prompt, []ImageInput{{Data: image, MimeType: mimeType}}, opts...)
+}
+
+// ExtractTextFromImages extracts text from images using a multimodal Gemini model,
+// passing them as inline data parts before the prompt. Images over
+// s.MaxImageBytes, or together over s.MaxTotalImageBytes, fail with
+// ErrImageTooLarge without being sent.
+func (s *GeminiLlmService) ExtractTextFromImages(ctx context.Context, prompt string, images []ImageInput, opts ...GenerateOption) (string, error) {
+	slog.InfoContext(ctx, "GeminiLlmService: ExtractTextFromImages called",
+		"model", s.MultimodalModel,
+		"prompt_length", len(prompt),
+		"images", len(images),
+		"image_size", imageBytes(images))
+
+	if err := checkImages(images); err != nil {
+		slog.ErrorContext(ctx, "GeminiLlmService: Invalid images", "error", err)
+		return "", err
+	}
+	if err := checkImageSizes(images, s.MaxImageBytes, s.MaxTotalImageBytes); err != nil {
+		slog.ErrorContext(ctx, "GeminiLlmService: Images too large", "error", err)
+		return "", err
+	}
+	parts := make([]*genai.Part, 0, len(images)+1)
+	for n, image := range images {
+		if image.MimeType == "" {
+			slog.ErrorContext(ctx, "GeminiLlmService: MIME type is empty", "image", n+1)
+			return "", imageError(n, len(images), fmt.Errorf("MIME type is empty"))
+		}
+		parts = append(parts, genai.NewPartFromBytes(image.Data, image.MimeType))
+	}
+	parts = append(parts, genai.NewPartFromText(prompt))
+	contents := []*genai.Content{genai.NewContentFromParts(parts, genai.RoleUser)}
+	params := generation(imageTemperature, imageMaxTokens, opts)
+
+	text, _, err := s.generate(ctx, params.modelOr(s.MultimodalModel), contents, params.geminiConfig(), "multimodal")
+	if err != nil {
+		return "", err
+	}
+	slog.InfoContext(ctx, "GeminiLlmService: Text extracted from images successfully", "response_length", len(text))
+	return text, nil
+}
+
+// ExtractTextFromDocument returns an error wrapping ErrDocumentsUnsupported:
+// PDFs are read by the mistral and anthropic providers.
+func (s *GeminiLlmService) ExtractTextFromDocument(ctx context.Context, prompt string, pdf []byte, opts ...GenerateOption) (string, error) {
+	return "", documentsUnsupported(ProviderGemini)
+}
+
+// TranscribeAudio transcribes a recording with the multimodal Gemini model,
+// passing it as an inline data part before a prompt asking for the transcript.
+// Recordings over s.MaxAudioBytes fail with ErrAudioTooLarge without being sent.
+func (s *GeminiLlmService) TranscribeAudio(ctx context.Context, audio []byte, mimeType string) (string, error) {
+	slog.InfoContext(ctx, "GeminiLlmService: TranscribeAudio called",
+		"model", s.MultimodalModel,
+		"mime_type", mimeType,
+		"audio_size", len(audio))
+
+	if err := checkAudio(audio, mimeType, s.MaxAudioBytes); err != nil {
+		slog.ErrorContext(ctx, "GeminiLlmService: Invalid audio", "error", err)
+		return "", err
+	}
+	parts := []*genai.Part{genai.NewPartFromBytes(audio, mimeType), genai.NewPartFromText(transcriptionPrompt)}
+	contents := []*genai.Content{genai.NewContentFromParts(parts, genai.RoleUser)}
+	config := generation(0, transcriptMaxTokens, nil).geminiConfig()
+
+	text, _, err := s.generate(ctx, s.MultimodalModel, contents, config, "audio")
+	if err != nil {
+		return "", err
+	}
+	slog.InfoContext(ctx, "GeminiLlmService: Audio transcribed successfully", "transcript_length", len(text))
+	return text, nil
+}
+
+// generate sends contents to model and returns the text parts of the first
+// candidate and the usage reported for it. kind, such as "multimodal", qualifies
+// the errors.
+func (s *GeminiLlmService) generate(ctx context.Context, model string, contents []*genai.Content, config *genai.GenerateContentConfig, kind string) (_ string, usage Usage, err error) {
+	if err := checkModel(model); err != nil {
+		return "", Usage{}, err
+	}
+	if err := budget.FromContext(ctx).Check(); err != nil {
+		return "", Usage{}, err
+	}
+	defer func(start time.Time) { recordCall(ctx, s.Metrics, "gemini", kind, start, usage, err) }(time.Now())
+	if err := s.Breaker.Allow(); err != nil {
+		return "", Usage{}, err
+	}
+	defer func() { s.Breaker.Record(ctx, err) }()
+	label, qualifier := "", ""
+	if kind != "" {
+		label, qualifier = " ("+kind+")", kind+" "
+	}
+
+	response, err := s.client.Models.GenerateContent(ctx, model, contents, config)
+	if err != nil {
+		slog.ErrorContext(ctx, "GeminiLlmService: Gemini API error", "error", err, "model", model)
+		return "", Usage{}, fmt.Errorf("gemini API error%s: %w", label, classifyGemini(err))
+	}
+	if text := response.Text(); strings.TrimSpace(text) != "" {
+		if metadata := response.UsageMetadata; metadata != nil {
+			usage = Usage{
+				PromptTokens:     int(metadata.PromptTokenCount),
+				CompletionTokens: int(metadata.CandidatesTokenCount),
+				TotalTokens:      int(metadata.TotalTokenCount),
+			}
+		}
+		return text, usage, nil
+	}
+	slog.WarnContext(ctx, "GeminiLlmService: No content found in Gemini API response")
+	slog.DebugContext(ctx, "GeminiLlmService: Response without content", redact.Content("response", fmt.Sprintf("%+v", response)))
+	if feedback := response.PromptFeedback; feedback != nil && feedback.BlockReason != "" {
+		return "", Usage{}, fmt.Errorf("no content found in gemini %sresponse: prompt blocked (%s): %w", qualifier, feedback.BlockReason, ErrContentFiltered)
+	}
+	return "", Usage{}, fmt.Errorf("no content found in gemini %sresponse", qualifier)
+}
+
+// classifyGemini returns err wrapping the class of failure its status shows, when
+// it is an error of the Gemini API.
+func classifyGemini(err error) error {
+	var apiErr genai.APIError
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	return classified(err, classifyStatus(apiErr.Code, apiErr.Message))
+}
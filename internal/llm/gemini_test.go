@@ -0,0 +1,215 @@
+package llm
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+// newGeminiTestService points a GeminiLlmService at a test server serving the
+// endpoints of the given model with handler.
+func newGeminiTestService(t *testing.T, model string, handler http.HandlerFunc) *GeminiLlmService {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/v1beta/models/"+model+":") {
+			http.Error(w, "Not found: Unexpected path "+r.URL.Path, http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("X-Goog-Api-Key") != "test_api_key" {
+			http.Error(w, "Missing API key", http.StatusUnauthorized)
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	service, err := newGeminiLlmService(&genai.ClientConfig{
+		APIKey:      "test_api_key",
+		Backend:     genai.BackendGeminiAPI,
+		HTTPClient:  server.Client(),
+		HTTPOptions: genai.HTTPOptions{BaseURL: server.URL},
+	})
+	if err != nil {
+		t.Fatalf("newGeminiLlmService failed: %v", err)
+	}
+	service.ChatModel, service.MultimodalModel = model, model
+	return service
+}
+
+// writeCandidate replies with a candidate holding the given text parts.
+func writeCandidate(w http.ResponseWriter, texts ...string) {
+	parts := make([]map[string]string, 0, len(texts))
+	for _, text := range texts {
+		parts = append(parts, map[string]string{"text": text})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"candidates": []map[string]interface{}{{"content": map[string]interface{}{"role": "model", "parts": parts}}},
+	})
+}
+
+func TestNewGeminiLlmService_MissingKey(t *testing.T) {
+	t.Setenv("GEMINI_API_KEY", "")
+	if _, err := NewLlmService(ProviderGemini); err == nil || !strings.Contains(err.Error(), "GEMINI_API_KEY") {
+		t.Errorf("Expected an error naming GEMINI_API_KEY, got %v", err)
+	}
+
+	t.Setenv("GEMINI_API_KEY", "test_api_key")
+	service, err := NewLlmService(ProviderGemini)
+	if err != nil {
+		t.Fatalf("NewLlmService failed: %v", err)
+	}
+	if _, ok := service.(*GeminiLlmService); !ok {
+		t.Errorf("Expected a *GeminiLlmService, got %T", service)
+	}
+}
+
+func TestGeminiLlmService_GenerateText_Success(t *testing.T) {
+	var payload struct {
+		Contents []struct {
+			Role  string `json:"role"`
+			Parts []struct {
+				Text string `json:"text"`
+			} `json:"parts"`
+		} `json:"contents"`
+		GenerationConfig struct {
+			MaxOutputTokens int `json:"maxOutputTokens"`
+		} `json:"generationConfig"`
+	}
+	service := newGeminiTestService(t, "gemini-test", func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, "Bad request body, not JSON", http.StatusBadRequest)
+			return
+		}
+		writeCandidate(w, "This is ", "a test response.")
+	})
+
+	actualText, err := service.GenerateText(context.Background(), "test prompt")
+	if err != nil {
+		t.Fatalf("GenerateText failed: %v", err)
+	}
+	if actualText != "This is a test response." {
+		t.Errorf("Expected the text parts joined, got '%s'", actualText)
+	}
+	if len(payload.Contents) != 1 || payload.Contents[0].Role != "user" || len(payload.Contents[0].Parts) != 1 || payload.Contents[0].Parts[0].Text != "test prompt" || payload.GenerationConfig.MaxOutputTokens == 0 {
+		t.Errorf("Expected a single user part with the prompt, got %+v", payload)
+	}
+}
+
+func TestGeminiLlmService_GenerateText_Errors(t *testing.T) {
+	service := newGeminiTestService(t, "gemini-test", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		io.WriteString(w, `{"error": {"code": 429, "message": "Resource has been exhausted", "status": "RESOURCE_EXHAUSTED"}}`)
+	})
+	_, err := service.GenerateText(context.Background(), "test prompt")
+	if err == nil || !strings.Contains(err.Error(), "gemini API error") || !strings.Contains(err.Error(), "Resource has been exhausted") {
+		t.Errorf("Expected error to contain the API error, got: %v", err)
+	}
+
+	service = newGeminiTestService(t, "gemini-test", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"candidates": [], "promptFeedback": {"blockReason": "SAFETY"}}`)
+	})
+	if _, err := service.GenerateText(context.Background(), "test prompt"); err == nil || !strings.Contains(err.Error(), "no content found in gemini response") || !strings.Contains(err.Error(), "SAFETY") {
+		t.Errorf("Expected error to report the blocked prompt, got: %v", err)
+	}
+}
+
+func TestGeminiLlmService_ExtractTextFromImage_Success(t *testing.T) {
+	imageData := []byte("dummyimagedata")
+	service := newGeminiTestService(t, "gemini-vision", func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Contents []struct {
+				Parts []struct {
+					Text       string `json:"text"`
+					InlineData struct {
+						MIMEType string `json:"mimeType"`
+						Data     string `json:"data"`
+					} `json:"inlineData"`
+				} `json:"parts"`
+			} `json:"contents"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || len(payload.Contents) != 1 || len(payload.Contents[0].Parts) != 2 {
+			http.Error(w, "Expected one content with two parts", http.StatusBadRequest)
+			return
+		}
+		image, text := payload.Contents[0].Parts[0], payload.Contents[0].Parts[1]
+		if image.InlineData.MIMEType != "image/png" || image.InlineData.Data != base64.StdEncoding.EncodeToString(imageData) {
+			http.Error(w, "First part is not the inline image", http.StatusBadRequest)
+			return
+		}
+		if text.Text != "Extract wine info" {
+			http.Error(w, "Second part is not the prompt", http.StatusBadRequest)
+			return
+		}
+		writeCandidate(w, "Wine Name: Test Wine")
+	})
+
+	actualText, err := service.ExtractTextFromImage(context.Background(), "Extract wine info", imageData, "image/png")
+	if err != nil {
+		t.Fatalf("ExtractTextFromImage failed: %v", err)
+	}
+	if actualText != "Wine Name: Test Wine" {
+		t.Errorf("Expected text 'Wine Name: Test Wine', got '%s'", actualText)
+	}
+
+	if _, err := service.ExtractTextFromImage(context.Background(), "prompt", nil, "image/jpeg"); err == nil || !strings.Contains(err.Error(), "image data is empty") {
+		t.Errorf("Expected error to contain 'image data is empty', got: %v", err)
+	}
+}
+
+func TestGeminiLlmService_ExtractTextFromImage_TooLarge(t *testing.T) {
+	requests := 0
+	service := newGeminiTestService(t, "gemini-vision", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		writeCandidate(w, "text")
+	})
+	service.MaxImageBytes, service.MaxTotalImageBytes = 10, 15
+
+	_, err := service.ExtractTextFromImage(context.Background(), "prompt", []byte("an image over ten bytes"), "image/png")
+	if !errors.Is(err, ErrImageTooLarge) || !strings.Contains(err.Error(), "23 bytes, more than the 10 allowed") {
+		t.Errorf("Expected %v with the size and limit, got %v", ErrImageTooLarge, err)
+	}
+	images := []ImageInput{{Data: []byte("front page"), MimeType: "image/png"}, {Data: []byte("back page"), MimeType: "image/png"}}
+	if _, err := service.ExtractTextFromImages(context.Background(), "prompt", images); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("Expected images over the total to fail with %v, got %v", ErrImageTooLarge, err)
+	}
+	if requests != 0 {
+		t.Errorf("Expected no request for images over the limit, got %d", requests)
+	}
+
+	if _, err := service.ExtractTextFromImage(context.Background(), "prompt", []byte("small"), "image/png"); err != nil || requests != 1 {
+		t.Errorf("Expected an image within the limit to be sent, got %v after %d requests", err, requests)
+	}
+}
+
+func TestGeminiLlmService_GenerateTextStream(t *testing.T) {
+	service := newGeminiTestService(t, "gemini-test", func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, ":streamGenerateContent") {
+			http.Error(w, "Expected a streaming request", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, text := range []string{"Hello", " there"} {
+			io.WriteString(w, `data: {"candidates": [{"content": {"role": "model", "parts": [{"text": "`+text+`"}]}}]}`+"\n\n")
+			w.(http.Flusher).Flush()
+		}
+	})
+
+	pieces, err := collect(service.GenerateTextStream(context.Background(), "test prompt"))
+	if err != nil {
+		t.Fatalf("GenerateTextStream failed: %v", err)
+	}
+	if strings.Join(pieces, "|") != "Hello| there" {
+		t.Errorf("Expected the streamed texts, got %q", pieces)
+	}
+}
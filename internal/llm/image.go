@@ -0,0 +1,172 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // decoders of the formats fitImage can downscale
+	"image/jpeg"
+	_ "image/png"
+	"log/slog"
+)
+
+// ImageInput is an image given to a multimodal model.
+type ImageInput struct {
+	Data []byte
+	// MimeType is the MIME type of Data, such as "image/jpeg" or "image/png".
+	MimeType string
+}
+
+// Defaults of the images sent to multimodal models. Larger images are downscaled
+// to fit.
+const (
+	DefaultMaxImageBytes      = 10 << 20
+	DefaultMaxImageDimension  = 2048
+	DefaultMaxImages          = 8
+	DefaultMaxTotalImageBytes = 20 << 20
+)
+
+// ErrImageTooLarge is returned when an image exceeds the size limit and cannot be
+// downscaled to fit it.
+var ErrImageTooLarge = errors.New("image too large")
+
+// minImageDimension is the smallest longest side fitImage downscales to; text in
+// a smaller image is rarely legible.
+const minImageDimension = 256
+
+// jpegQualities are tried in turn when re-encoding a downscaled image.
+var jpegQualities = []int{85, 70}
+
+// checkImages rejects an empty list of images and images without data.
+func checkImages(images []ImageInput) error {
+	if len(images) == 0 {
+		return fmt.Errorf("no images given")
+	}
+	for n, image := range images {
+		if len(image.Data) == 0 {
+			return imageError(n, len(images), fmt.Errorf("image data is empty"))
+		}
+	}
+	return nil
+}
+
+// checkImageSizes rejects images over maxBytes each or maxTotalBytes together, for
+// providers that take images as they are. Zero disables a limit.
+func checkImageSizes(images []ImageInput, maxBytes, maxTotalBytes int) error {
+	for n, image := range images {
+		if maxBytes > 0 && len(image.Data) > maxBytes {
+			return imageError(n, len(images), fmt.Errorf("%w: %d bytes, more than the %d allowed", ErrImageTooLarge, len(image.Data), maxBytes))
+		}
+	}
+	if total := imageBytes(images); maxTotalBytes > 0 && total > maxTotalBytes {
+		return fmt.Errorf("%w: the images total %d bytes, more than the %d allowed per request", ErrImageTooLarge, total, maxTotalBytes)
+	}
+	return nil
+}
+
+// imageError qualifies err with the position of the n-th of count images, when
+// there is more than one.
+func imageError(n, count int, err error) error {
+	if count == 1 {
+		return err
+	}
+	return fmt.Errorf("image %d of %d: %w", n+1, count, err)
+}
+
+// imageBytes returns the total size of images.
+func imageBytes(images []ImageInput) int {
+	total := 0
+	for _, image := range images {
+		total += len(image.Data)
+	}
+	return total
+}
+
+// fitImage returns data unchanged when it is at most maxBytes, the limit, or
+// otherwise the image downscaled, keeping its aspect ratio, so that its longest
+// side is at most maxDimension and re-encoded as a JPEG of at most maxBytes,
+// shrinking it further as needed. A maxBytes of zero disables the limit and a
+// maxDimension of zero disables downscaling, so that oversized images fail.
+func fitImage(ctx context.Context, data []byte, mimeType string, maxBytes, maxDimension int) ([]byte, string, error) {
+	if maxBytes <= 0 || len(data) <= maxBytes {
+		return data, mimeType, nil
+	}
+	tooLarge := fmt.Errorf("%w: %d bytes, above the %d byte limit", ErrImageTooLarge, len(data), maxBytes)
+	if maxDimension <= 0 {
+		return nil, "", tooLarge
+	}
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w, and it cannot be downscaled: %v", tooLarge, err)
+	}
+
+	flat := flatten(src)
+	width, height := flat.Rect.Dx(), flat.Rect.Dy()
+	longest := min(max(width, height), maxDimension)
+	for ; longest >= minImageDimension; longest = longest * 3 / 4 {
+		scaled := resize(flat, longest)
+		for _, quality := range jpegQualities {
+			var out bytes.Buffer
+			if err := jpeg.Encode(&out, scaled, &jpeg.Options{Quality: quality}); err != nil {
+				return nil, "", fmt.Errorf("failed to re-encode image: %w", err)
+			}
+			if out.Len() <= maxBytes {
+				slog.InfoContext(ctx, "Downscaled image to fit the size limit",
+					"format", format, "original_bytes", len(data), "original_width", width, "original_height", height,
+					"bytes", out.Len(), "width", scaled.Rect.Dx(), "height", scaled.Rect.Dy(), "quality", quality)
+				return out.Bytes(), "image/jpeg", nil
+			}
+		}
+	}
+	return nil, "", fmt.Errorf("%w, even downscaled to %dpx", tooLarge, minImageDimension)
+}
+
+// flatten copies src onto a white background, as JPEG has no transparency.
+func flatten(src image.Image) *image.RGBA {
+	bounds := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Rect, image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Rect, src, bounds.Min, draw.Over)
+	return dst
+}
+
+// resize scales src, keeping its aspect ratio, so that its longest side is
+// longest, averaging the source pixels that make up each pixel. It does not
+// enlarge src.
+func resize(src *image.RGBA, longest int) *image.RGBA {
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	if max(sw, sh) <= longest {
+		return src
+	}
+	dw, dh := longest, max(sh*longest/sw, 1)
+	if sh > sw {
+		dw, dh = max(sw*longest/sh, 1), longest
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*sh/dh, (y+1)*sh/dh
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*sw/dw, (x+1)*sw/dw
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					for c := range sum {
+						sum[c] += int(row[sx*4+c])
+					}
+				}
+			}
+			n := (y1 - y0) * (x1 - x0)
+			i := y*dst.Stride + x*4
+			for c := range sum {
+				dst.Pix[i+c] = uint8(sum[c] / n)
+			}
+		}
+	}
+	return dst
+}
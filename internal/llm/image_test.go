@@ -0,0 +1,120 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// noisyPNG encodes a width by height image of random pixels, which compresses
+// poorly, like a photo.
+func noisyPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	rng := rand.New(rand.NewPCG(1, 2))
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(rng.IntN(256))
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("Failed to encode image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestFitImage(t *testing.T) {
+	ctx := context.Background()
+	original := noisyPNG(t, 1200, 800)
+
+	data, mimeType, err := fitImage(ctx, original, "image/png", len(original), 600)
+	if err != nil || !bytes.Equal(data, original) || mimeType != "image/png" {
+		t.Errorf("Expected an image within the limit unchanged, got %d bytes of %s, %v", len(data), mimeType, err)
+	}
+
+	limit := 150 << 10
+	data, mimeType, err = fitImage(ctx, original, "image/png", limit, 600)
+	if err != nil {
+		t.Fatalf("fitImage failed: %v", err)
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || format != "jpeg" || mimeType != "image/jpeg" {
+		t.Fatalf("Expected a JPEG, got %s (%s), %v", format, mimeType, err)
+	}
+	if len(data) > limit || config.Width > 600 || config.Width*2 != config.Height*3 {
+		t.Errorf("Expected at most %d bytes, 600px wide and a 3:2 aspect ratio, got %d bytes, %dx%d", limit, len(data), config.Width, config.Height)
+	}
+
+	portrait := noisyPNG(t, 300, 900)
+	data, _, err = fitImage(ctx, portrait, "image/png", len(portrait)/2, 450)
+	if config, _, _ := image.DecodeConfig(bytes.NewReader(data)); err != nil || config.Width != 150 || config.Height != 450 {
+		t.Errorf("Expected a 150x450 image, got %dx%d, %v", config.Width, config.Height, err)
+	}
+
+	if _, _, err := fitImage(ctx, original, "image/png", limit, 0); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("Expected an oversized image to fail without downscaling, got %v", err)
+	}
+	if _, _, err := fitImage(ctx, original, "image/png", 100, 600); !errors.Is(err, ErrImageTooLarge) || !strings.Contains(err.Error(), "even downscaled") {
+		t.Errorf("Expected an image that cannot shrink enough to fail, got %v", err)
+	}
+	if _, _, err := fitImage(ctx, bytes.Repeat([]byte("x"), 1000), "image/webp", 10, 600); !errors.Is(err, ErrImageTooLarge) || !strings.Contains(err.Error(), "cannot be downscaled") {
+		t.Errorf("Expected an undecodable image to fail, got %v", err)
+	}
+}
+
+func TestFlatten_WhiteBackground(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(10, 10, 12, 12))
+	img.Set(10, 10, color.NRGBA{R: 255, A: 255})
+	flat := flatten(img)
+	if got := flat.RGBAAt(0, 0); got != (color.RGBA{R: 255, A: 255}) {
+		t.Errorf("Expected the opaque pixel kept, got %v", got)
+	}
+	if got := flat.RGBAAt(1, 1); got != (color.RGBA{R: 255, G: 255, B: 255, A: 255}) {
+		t.Errorf("Expected a transparent pixel to turn white, got %v", got)
+	}
+}
+
+func TestMistralLlmService_DownscalesImages(t *testing.T) {
+	var url string
+	server := mockMistralServer(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Messages []struct {
+				Content []struct {
+					ImageURL map[string]string `json:"image_url"`
+				} `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		url = payload.Messages[0].Content[1].ImageURL["url"]
+		writeChoice(w, "text")
+	})
+	defer server.Close()
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	service, err := NewMistralLlmService(WithBaseURL(server.URL), WithMaxImageBytes(100<<10), WithMaxImageDimension(400))
+	if err != nil {
+		t.Fatalf("NewMistralLlmService failed: %v", err)
+	}
+
+	if _, err := service.ExtractTextFromImage(context.Background(), "read it", noisyPNG(t, 800, 600), "image/png"); err != nil {
+		t.Fatalf("ExtractTextFromImage failed: %v", err)
+	}
+	encoded, ok := strings.CutPrefix(url, "data:image/jpeg;base64,")
+	data, _ := base64.StdEncoding.DecodeString(encoded)
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if !ok || err != nil || config.Width != 400 || config.Height != 300 {
+		t.Errorf("Expected a 400x300 JPEG to be sent, got %dx%d (%v) in %.40q", config.Width, config.Height, err, url)
+	}
+
+	service.MaxImageDimension = 0
+	if _, err := service.ExtractTextFromImage(context.Background(), "read it", noisyPNG(t, 800, 600), "image/png"); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("Expected an oversized image to fail without downscaling, got %v", err)
+	}
+}
@@ -9,28 +9,105 @@ import (
 type Provider string
 
 const (
-	ProviderMistral Provider = "mistral"
-	// Add other providers like ProviderGemini if needed in the future
+	ProviderMistral     Provider = "mistral"
+	ProviderOpenAI      Provider = "openai"
+	ProviderAnthropic   Provider = "anthropic"
+	ProviderOllama      Provider = "ollama"
+	ProviderGemini      Provider = "gemini"
+	ProviderAzureOpenAI Provider = "azure-openai"
+	// ProviderOpenAICompatible is any server with an OpenAI-compatible API, such
+	// as llama.cpp or vLLM.
+	ProviderOpenAICompatible Provider = "openai-compatible"
+	ProviderTestMock         Provider = "testing" // For testing purposes
 )
 
+// Providers lists the LLM providers that NewLlmService accepts.
+func Providers() []Provider {
+	return []Provider{ProviderMistral, ProviderOpenAI, ProviderAnthropic, ProviderOllama, ProviderGemini, ProviderAzureOpenAI, ProviderOpenAICompatible, ProviderTestMock}
+}
+
 // LlmService defines the interface for Large Language Model services.
 // It includes methods for text generation and extracting text from images.
+// Each method takes GenerateOptions overriding its default temperature and
+// reply length for the call.
 type LlmService interface {
 	// GenerateText generates text based on a given prompt.
-	GenerateText(ctx context.Context, prompt string) (responseText string, err error)
+	GenerateText(ctx context.Context, prompt string, opts ...GenerateOption) (responseText string, err error)
+
+	// GenerateTextWithUsage is GenerateText also returning the tokens the request
+	// used.
+	GenerateTextWithUsage(ctx context.Context, prompt string, opts ...GenerateOption) (responseText string, usage Usage, err error)
+
+	// Chat generates the assistant's reply to a conversation, which may open with
+	// system messages. GenerateText is Chat with a single user message.
+	Chat(ctx context.Context, messages []Message, opts ...GenerateOption) (responseText string, err error)
+
+	// GenerateTextStream is GenerateText delivering the response as it is generated.
+	// The pieces arrive on the first channel, which is closed when the response is
+	// complete; the second channel carries at most one error and is closed after it.
+	// Cancelling ctx stops the stream promptly.
+	GenerateTextStream(ctx context.Context, prompt string, opts ...GenerateOption) (<-chan string, <-chan error)
 
 	// ExtractTextFromImage extracts relevant text from an image based on a guiding prompt.
 	// image is the byte representation of the image.
 	// mimeType is the MIME type of the image (e.g., "image/jpeg", "image/png").
-	ExtractTextFromImage(ctx context.Context, prompt string, image []byte, mimeType string) (extractedText string, err error)
+	ExtractTextFromImage(ctx context.Context, prompt string, image []byte, mimeType string, opts ...GenerateOption) (extractedText string, err error)
+
+	// ExtractTextFromImages is ExtractTextFromImage reading several images, such as
+	// the front and back of a label, in one request. The images are given to the
+	// model in order.
+	ExtractTextFromImages(ctx context.Context, prompt string, images []ImageInput, opts ...GenerateOption) (extractedText string, err error)
+
+	// ExtractTextFromDocument sends a PDF and a prompt to a model that reads
+	// documents and returns the text it extracts, each page starting with its
+	// PageMarker so that SplitPages can split it. Providers that cannot read PDFs
+	// return an error wrapping ErrDocumentsUnsupported, and PDFs over the
+	// service's size limit one wrapping ErrDocumentTooLarge.
+	ExtractTextFromDocument(ctx context.Context, prompt string, pdf []byte, opts ...GenerateOption) (extractedText string, err error)
+
+	// TranscribeAudio returns the transcript of a recording, whose mimeType is
+	// such as "audio/mpeg". Providers that cannot transcribe return an error
+	// wrapping ErrUnsupported, and recordings over the service's size limit one
+	// wrapping ErrAudioTooLarge.
+	TranscribeAudio(ctx context.Context, audio []byte, mimeType string) (transcript string, err error)
+
+	// Ping makes a minimal authenticated request, such as listing the models, to
+	// check the configuration before any work is done. Its error wraps
+	// ErrUnauthorized for a bad key and ErrUnreachable when the API cannot be
+	// reached.
+	Ping(ctx context.Context) error
 }
 
 // NewLlmService acts as a factory to create instances of LlmService
-// based on the specified provider.
-func NewLlmService(provider Provider) (LlmService, error) {
+// based on the specified provider. opts configure the Mistral service, such as
+// WithAPIKey to use a key other than MISTRAL_API_KEY; they are an error for other
+// providers, which are configured by their environment variables. The provider
+// name is matched as by ParseProvider.
+func NewLlmService(provider Provider, opts ...MistralOption) (LlmService, error) {
+	provider, err := ParseProvider(string(provider))
+	if err != nil {
+		return nil, err
+	}
+	if len(opts) > 0 && provider != ProviderMistral {
+		return nil, fmt.Errorf("options are not supported by the %s LLM provider", provider)
+	}
 	switch provider {
 	case ProviderMistral:
-		return NewMistralLlmService()
+		return NewMistralLlmService(opts...)
+	case ProviderOpenAI:
+		return NewOpenAILlmService()
+	case ProviderAnthropic:
+		return NewAnthropicLlmService()
+	case ProviderOllama:
+		return NewOllamaLlmService()
+	case ProviderGemini:
+		return NewGeminiLlmService()
+	case ProviderAzureOpenAI:
+		return NewAzureOpenAILlmService()
+	case ProviderOpenAICompatible:
+		return NewOpenAICompatibleLlmService()
+	case ProviderTestMock:
+		return NewMockLlmService(), nil
 	default:
 		return nil, fmt.Errorf("unknown LLM provider: %s", provider)
 	}
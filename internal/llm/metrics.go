@@ -0,0 +1,63 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/budget"
+	"github.com/sandwichlabs/agent-memory-graph/internal/metrics"
+)
+
+// errorClass names the class of err in metrics, or is empty for nil.
+func errorClass(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, budget.ErrBudgetExhausted):
+		return "budget_exhausted"
+	case errors.Is(err, ErrCircuitOpen):
+		return "circuit_open"
+	case errors.Is(err, ErrRateLimited):
+		return "rate_limited"
+	case errors.Is(err, ErrQuotaExceeded):
+		return "quota_exceeded"
+	case errors.Is(err, ErrUnauthorized):
+		return "unauthorized"
+	case errors.Is(err, ErrContextLengthExceeded):
+		return "context_length_exceeded"
+	case errors.Is(err, ErrContentFiltered):
+		return "content_filtered"
+	case errors.Is(err, ErrUpstream):
+		return "upstream"
+	case errors.Is(err, ErrTruncated):
+		return "truncated"
+	case errors.Is(err, context.Canceled):
+		return "cancelled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	}
+	return "other"
+}
+
+// recordCall charges the tokens of a request of kind to provider, made with ctx,
+// to the budget ctx carries and records the request to sink, when it is not nil,
+// as having started at start and ended with usage and err. Requests without a
+// kind are recorded as "chat".
+func recordCall(ctx context.Context, sink metrics.Sink, provider, kind string, start time.Time, usage Usage, err error) {
+	budget.FromContext(ctx).Spend(usage.TotalTokens)
+	if sink == nil {
+		return
+	}
+	if kind == "" {
+		kind = "chat"
+	}
+	sink.RecordCall(metrics.Call{
+		Provider:         provider,
+		Method:           kind,
+		Duration:         time.Since(start),
+		ErrorClass:       errorClass(err),
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+	})
+}
@@ -0,0 +1,137 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/metrics"
+)
+
+func TestMistralLlmService_Metrics(t *testing.T) {
+	status := http.StatusOK
+	server := mockMistralServer(func(w http.ResponseWriter, r *http.Request) {
+		if status != http.StatusOK {
+			http.Error(w, "Unauthorized", status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]interface{}{"content": "reply"}}},
+			"usage":   map[string]int{"prompt_tokens": 12, "completion_tokens": 3, "total_tokens": 15},
+		})
+	})
+	defer server.Close()
+
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	registry := metrics.NewRegistry()
+	service, err := NewMistralLlmService(WithBaseURL(server.URL), WithMetrics(registry))
+	if err != nil {
+		t.Fatalf("NewMistralLlmService failed: %v", err)
+	}
+
+	for range 2 {
+		if _, err := service.GenerateText(context.Background(), "test prompt"); err != nil {
+			t.Fatalf("GenerateText failed: %v", err)
+		}
+	}
+	status = http.StatusUnauthorized
+	if _, err := service.GenerateText(context.Background(), "test prompt"); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("Expected %v, got %v", ErrUnauthorized, err)
+	}
+
+	snapshot := registry.Snapshot()
+	if len(snapshot) != 1 {
+		t.Fatalf("Expected the chat method of mistral only, got %+v", snapshot)
+	}
+	calls := snapshot[0]
+	if calls.Provider != "mistral" || calls.Method != "chat" {
+		t.Errorf("Expected mistral chat, got %s %s", calls.Provider, calls.Method)
+	}
+	if calls.Requests != 3 || calls.Errors != 1 || calls.ErrorsByClass["unauthorized"] != 1 {
+		t.Errorf("Expected 3 requests and 1 unauthorized error, got %+v", calls)
+	}
+	if calls.PromptTokens != 24 || calls.CompletionTokens != 6 {
+		t.Errorf("Expected 24 tokens in and 6 out, got %d and %d", calls.PromptTokens, calls.CompletionTokens)
+	}
+	if calls.P50 <= 0 || calls.P95 < calls.P50 {
+		t.Errorf("Expected positive latency percentiles, got p50 %s and p95 %s", calls.P50, calls.P95)
+	}
+}
+
+func TestErrorClass(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, ""},
+		{ErrRateLimited, "rate_limited"},
+		{&TruncatedError{Provider: "mistral"}, "truncated"},
+		{context.Canceled, "cancelled"},
+		{context.DeadlineExceeded, "timeout"},
+		{errors.New("connection refused"), "other"},
+	}
+	for _, tt := range tests {
+		if got := errorClass(tt.err); got != tt.want {
+			t.Errorf("Expected %v to be classed %q, got %q", tt.err, tt.want, got)
+		}
+	}
+}
+
+func TestMistralLlmService_GenerateTextStream_Metrics(t *testing.T) {
+	var requests int
+	status := http.StatusOK
+	service := newMistralStreamService(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		if status != http.StatusOK {
+			http.Error(w, "Unauthorized", status)
+			return
+		}
+		writeEvents(w,
+			`{"choices":[{"delta":{"content":"reply"}}]}`,
+			`{"choices":[{"delta":{"content":""},"finish_reason":"stop"}],"usage":{"prompt_tokens":12,"completion_tokens":3,"total_tokens":15}}`,
+			"[DONE]",
+		)
+	})
+	registry := metrics.NewRegistry()
+	service.Metrics = registry
+
+	// The stream opens on the retry after a 503.
+	if _, err := collect(service.GenerateTextStream(context.Background(), "test prompt")); err != nil {
+		t.Fatalf("GenerateTextStream failed: %v", err)
+	}
+	status = http.StatusUnauthorized
+	if _, err := collect(service.GenerateTextStream(context.Background(), "test prompt")); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("Expected %v, got %v", ErrUnauthorized, err)
+	}
+
+	snapshot := registry.Snapshot()
+	if len(snapshot) != 1 || snapshot[0].Provider != "mistral" || snapshot[0].Method != "stream" {
+		t.Fatalf("Expected the stream method of mistral only, got %+v", snapshot)
+	}
+	calls := snapshot[0]
+	if calls.Requests != 2 || calls.Errors != 1 || calls.ErrorsByClass["unauthorized"] != 1 {
+		t.Errorf("Expected 2 streams and 1 unauthorized error, got %+v", calls)
+	}
+	if calls.PromptTokens != 12 || calls.CompletionTokens != 3 {
+		t.Errorf("Expected 12 tokens in and 3 out, got %d and %d", calls.PromptTokens, calls.CompletionTokens)
+	}
+
+	// An open breaker refuses the stream without a request.
+	service.Breaker = NewCircuitBreaker("mistral", 1, time.Hour)
+	service.Breaker.Record(context.Background(), ErrUpstream)
+	sent := requests
+	if _, err := collect(service.GenerateTextStream(context.Background(), "test prompt")); !errors.Is(err, ErrCircuitOpen) || requests != sent {
+		t.Errorf("Expected %v without a request, got %v after %d requests", ErrCircuitOpen, err, requests-sent)
+	}
+	if calls := registry.Snapshot()[0]; calls.ErrorsByClass["circuit_open"] != 1 {
+		t.Errorf("Expected the refused stream counted as circuit_open, got %+v", calls)
+	}
+}
@@ -0,0 +1,103 @@
+package llm
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/redact"
+)
+
+// Doer sends an HTTP request to a provider's API and returns its response, as
+// *http.Client does.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// DoerFunc adapts a function to a Doer.
+type DoerFunc func(req *http.Request) (*http.Response, error)
+
+// Do calls f(req).
+func (f DoerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Middleware wraps the round trip of every request a service sends, such as to
+// audit prompts or add headers. It may change the request, inspect the response,
+// or answer without calling next at all.
+type Middleware func(next Doer) Doer
+
+// chain returns client wrapped in middleware, the first of which sees requests
+// first and responses last.
+func chain(client Doer, middleware []Middleware) Doer {
+	for n := len(middleware) - 1; n >= 0; n-- {
+		client = middleware[n](client)
+	}
+	return client
+}
+
+// LogRequests returns a middleware logging each request to logger at info level:
+// its URL, its body through redact.Content, which hashes it when AMG_LOG_REDACT
+// is set, and the status and duration of its response. Headers, which hold the
+// API key, are not logged. A nil logger logs to slog.Default.
+func LogRequests(logger *slog.Logger) Middleware {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			ctx := req.Context()
+			body, err := requestBody(req)
+			if err != nil {
+				return nil, err
+			}
+			logger.InfoContext(ctx, "LLM request", "method", req.Method, "url", req.URL.String(), redact.Content("body", body))
+			start := time.Now()
+			resp, err := next.Do(req)
+			if err != nil {
+				logger.InfoContext(ctx, "LLM request failed", "url", req.URL.String(), "duration", time.Since(start), "error", err)
+				return nil, err
+			}
+			logger.InfoContext(ctx, "LLM response", "url", req.URL.String(), "status_code", resp.StatusCode, "duration", time.Since(start))
+			return resp, nil
+		})
+	}
+}
+
+// SetHeaders returns a middleware setting headers on each request, such as a
+// tracing header, replacing any values the service set.
+func SetHeaders(headers http.Header) Middleware {
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			for key, values := range headers {
+				req.Header[http.CanonicalHeaderKey(key)] = append([]string(nil), values...)
+			}
+			return next.Do(req)
+		})
+	}
+}
+
+// requestBody returns the body of req, leaving it to be sent.
+func requestBody(req *http.Request) (string, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return "", nil
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return "", err
+		}
+		defer body.Close()
+		data, err := io.ReadAll(body)
+		return string(data), err
+	}
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return "", err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	return string(data), nil
+}
@@ -0,0 +1,114 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/redact"
+)
+
+// recordingMiddleware appends name to calls when a request goes through it.
+func recordingMiddleware(name string, calls *[]string) Middleware {
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			*calls = append(*calls, name)
+			return next.Do(req)
+		})
+	}
+}
+
+func TestMistralLlmService_MiddlewareOrder(t *testing.T) {
+	var calls []string
+	var traceID string
+	server := mockMistralServer(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "server")
+		traceID = r.Header.Get("X-Trace-Id")
+		writeChoice(w, "reply")
+	})
+	defer server.Close()
+
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	service, err := NewMistralLlmService(WithBaseURL(server.URL),
+		WithMiddleware(recordingMiddleware("first", &calls), SetHeaders(http.Header{"X-Trace-Id": {"trace-1"}})),
+		WithMiddleware(recordingMiddleware("second", &calls)))
+	if err != nil {
+		t.Fatalf("NewMistralLlmService failed: %v", err)
+	}
+
+	if _, err := service.GenerateText(context.Background(), "test prompt"); err != nil {
+		t.Fatalf("GenerateText failed: %v", err)
+	}
+	if _, err := service.ExtractTextFromImage(context.Background(), "prompt", []byte("image"), "image/png"); err != nil {
+		t.Fatalf("ExtractTextFromImage failed: %v", err)
+	}
+	want := "first second server first second server"
+	if got := strings.Join(calls, " "); got != want {
+		t.Errorf("Expected the middleware to run in registration order for text and images, got %q", got)
+	}
+	if traceID != "trace-1" {
+		t.Errorf("Expected the injected X-Trace-Id header, got %q", traceID)
+	}
+}
+
+func TestMistralLlmService_MiddlewareShortCircuit(t *testing.T) {
+	requests := 0
+	server := mockMistralServer(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		writeChoice(w, "from the server")
+	})
+	defer server.Close()
+	canned := func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       io.NopCloser(strings.NewReader(`{"choices": [{"message": {"content": "canned"}}]}`)),
+			}, nil
+		})
+	}
+
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	service, err := NewMistralLlmService(WithBaseURL(server.URL), WithMiddleware(canned))
+	if err != nil {
+		t.Fatalf("NewMistralLlmService failed: %v", err)
+	}
+	text, err := service.GenerateText(context.Background(), "test prompt")
+	if err != nil || text != "canned" || requests != 0 {
+		t.Errorf("Expected the middleware's reply without a request, got %q and %v after %d requests", text, err, requests)
+	}
+}
+
+func TestLogRequests(t *testing.T) {
+	server := mockMistralServer(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(body), "secret prompt") {
+			t.Errorf("Expected the request body to reach the server, got %s", body)
+		}
+		writeChoice(w, "reply")
+	})
+	defer server.Close()
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	t.Setenv(redact.EnvRedact, "true")
+	service, err := NewMistralLlmService(WithBaseURL(server.URL), WithMiddleware(LogRequests(logger)))
+	if err != nil {
+		t.Fatalf("NewMistralLlmService failed: %v", err)
+	}
+	if _, err := service.GenerateText(context.Background(), "secret prompt"); err != nil {
+		t.Fatalf("GenerateText failed: %v", err)
+	}
+	out := logs.String()
+	if !strings.Contains(out, "LLM request") || !strings.Contains(out, "body=\"sha256:") || !strings.Contains(out, "status_code=200") {
+		t.Errorf("Expected the request logged with its body hashed and the status, got %s", out)
+	}
+	if strings.Contains(out, "secret prompt") || strings.Contains(out, "test_api_key") {
+		t.Errorf("Expected neither the prompt nor the key in the log, got %s", out)
+	}
+}
@@ -5,11 +5,21 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"os"
+	"strings"
+	"time"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/budget"
+	"github.com/sandwichlabs/agent-memory-graph/internal/httpclient"
+	"github.com/sandwichlabs/agent-memory-graph/internal/metrics"
+	"github.com/sandwichlabs/agent-memory-graph/internal/ratelimit"
+	"github.com/sandwichlabs/agent-memory-graph/internal/redact"
+	"github.com/sandwichlabs/agent-memory-graph/internal/retry"
 )
 
 // MistralLlmService implements the LlmService interface using the Mistral API.
@@ -19,119 +29,466 @@ type MistralLlmService struct {
 	chatModel       string
 	multimodalModel string
 	APIBaseURL      string // Added for testing and flexibility
+	// Retry governs retrying rate-limited and failed requests; DefaultRetryPolicy
+	// unless set with WithRetry.
+	Retry RetryPolicy
+	// Limiter spaces out requests, including retries; nil, the default unless
+	// MISTRAL_RPS or WithRateLimit is set, does not limit them.
+	Limiter *ratelimit.Limiter
+	// Timeout bounds each attempt of a request, and ImageTimeout those reading
+	// images, which upload and process slowly; zero waits as long as ctx allows.
+	// Streams are bounded only by ctx.
+	Timeout      time.Duration
+	ImageTimeout time.Duration
+	// MaxImageBytes caps the images sent to the multimodal model; larger ones
+	// are downscaled so that their longest side is at most MaxImageDimension
+	// and re-encoded as JPEG. Zero disables the cap, and a MaxImageDimension of
+	// zero downscaling.
+	MaxImageBytes     int
+	MaxImageDimension int
+	// MaxImages caps the images of one request, and MaxTotalImageBytes their
+	// total size, which the images share when downscaled. Zero disables either.
+	MaxImages          int
+	MaxTotalImageBytes int
+	// MaxDocumentBytes caps the PDFs sent to the multimodal model; zero disables
+	// the cap.
+	MaxDocumentBytes int
+	// Metrics receives a measurement of every request, covering its retries;
+	// metrics.Default unless set with WithMetrics.
+	Metrics metrics.Sink
+	// Breaker fails requests fast while Mistral is down, including their retries;
+	// nil disables it.
+	Breaker *CircuitBreaker
+	// Middleware wraps every request sent with HTTPClient, the first seeing it
+	// first; set with WithMiddleware.
+	Middleware []Middleware
+}
+
+// Default models of MistralLlmService, overridden by MISTRAL_CHAT_MODEL and
+// MISTRAL_MULTIMODAL_MODEL or by options.
+const (
+	DefaultMistralChatModel       = "mistral-small-latest"
+	DefaultMistralMultimodalModel = "mistral-medium-latest"
+)
+
+// DefaultMistralBaseURL is the API MistralLlmService calls, overridden by
+// MISTRAL_API_BASE or WithBaseURL.
+const DefaultMistralBaseURL = "https://api.mistral.ai/v1"
+
+// Default timeouts of MistralLlmService, overridden by MISTRAL_TIMEOUT and
+// MISTRAL_IMAGE_TIMEOUT or by options.
+const (
+	DefaultMistralTimeout      = 60 * time.Second
+	DefaultMistralImageTimeout = 3 * time.Minute
+)
+
+// MistralOption configures a MistralLlmService, taking precedence over the
+// environment.
+type MistralOption func(*MistralLlmService)
+
+// WithChatModel sets the model that generates text, such as "mistral-large-latest"
+// or a dated model like "mistral-small-2503".
+func WithChatModel(model string) MistralOption {
+	return func(s *MistralLlmService) { s.chatModel = model }
+}
+
+// WithMultimodalModel sets the model that reads images.
+func WithMultimodalModel(model string) MistralOption {
+	return func(s *MistralLlmService) { s.multimodalModel = model }
+}
+
+// WithAPIKey sets the API key instead of MISTRAL_API_KEY.
+func WithAPIKey(apiKey string) MistralOption {
+	return func(s *MistralLlmService) { s.apiKey = apiKey }
+}
+
+// WithBaseURL sets the API base URL, such as a gateway in front of the Mistral API.
+func WithBaseURL(url string) MistralOption {
+	return func(s *MistralLlmService) { s.APIBaseURL = strings.TrimRight(url, "/") }
+}
+
+// WithHTTPClient sets the client that sends requests, instead of one configured
+// by the environment, for full control of proxies and TLS.
+func WithHTTPClient(client *http.Client) MistralOption {
+	return func(s *MistralLlmService) { s.HTTPClient = client }
+}
+
+// WithRetry sets how rate-limited and failed requests are retried.
+func WithRetry(policy RetryPolicy) MistralOption {
+	return func(s *MistralLlmService) { s.Retry = policy }
+}
+
+// WithRateLimit limits requests to rps per second, blocking calls until they may
+// proceed. It applies to this service alone, unlike MISTRAL_RPS.
+func WithRateLimit(rps float64) MistralOption {
+	return func(s *MistralLlmService) { s.Limiter = ratelimit.New(rps, 1) }
+}
+
+// WithTimeout bounds each attempt of a text request; zero disables the timeout.
+func WithTimeout(timeout time.Duration) MistralOption {
+	return func(s *MistralLlmService) { s.Timeout = timeout }
+}
+
+// WithImageTimeout bounds each attempt of ExtractTextFromImage; zero disables the
+// timeout.
+func WithImageTimeout(timeout time.Duration) MistralOption {
+	return func(s *MistralLlmService) { s.ImageTimeout = timeout }
+}
+
+// WithMaxImageBytes sets the size above which images are downscaled to fit.
+func WithMaxImageBytes(maxBytes int) MistralOption {
+	return func(s *MistralLlmService) { s.MaxImageBytes = maxBytes }
+}
+
+// WithMaxImageDimension sets the longest side, in pixels, of downscaled images;
+// zero fails on oversized images instead.
+func WithMaxImageDimension(pixels int) MistralOption {
+	return func(s *MistralLlmService) { s.MaxImageDimension = pixels }
+}
+
+// WithMaxImages sets how many images one ExtractTextFromImages call may send.
+func WithMaxImages(count int) MistralOption {
+	return func(s *MistralLlmService) { s.MaxImages = count }
+}
+
+// WithMaxDocumentBytes sets the size of the largest PDF ExtractTextFromDocument
+// sends.
+func WithMaxDocumentBytes(maxBytes int) MistralOption {
+	return func(s *MistralLlmService) { s.MaxDocumentBytes = maxBytes }
+}
+
+// WithMetrics sets where the measurements of requests are recorded; nil records
+// none.
+func WithMetrics(sink metrics.Sink) MistralOption {
+	return func(s *MistralLlmService) { s.Metrics = sink }
+}
+
+// WithMiddleware adds middleware wrapping every request, text and multimodal,
+// after any added before.
+func WithMiddleware(middleware ...Middleware) MistralOption {
+	return func(s *MistralLlmService) { s.Middleware = append(s.Middleware, middleware...) }
+}
+
+// WithCircuitBreaker opens the circuit breaker after threshold consecutive
+// outages, failing requests for cooldown; a threshold of 0 disables it.
+func WithCircuitBreaker(threshold int, cooldown time.Duration) MistralOption {
+	return func(s *MistralLlmService) { s.Breaker = NewCircuitBreaker("mistral", threshold, cooldown) }
+}
+
+// WithMaxTotalImageBytes sets the total size of the images one request may send.
+func WithMaxTotalImageBytes(maxBytes int) MistralOption {
+	return func(s *MistralLlmService) { s.MaxTotalImageBytes = maxBytes }
 }
 
 // NewMistralLlmService creates a new instance of MistralLlmService.
-// It requires the API key to be set in the MISTRAL_API_KEY environment variable.
-func NewMistralLlmService() (*MistralLlmService, error) {
-	apiKey := os.Getenv("MISTRAL_API_KEY")
-	if apiKey == "" {
+// The API key comes from MISTRAL_API_KEY unless WithAPIKey is given, and the
+// models from MISTRAL_CHAT_MODEL and MISTRAL_MULTIMODAL_MODEL when set.
+// MISTRAL_API_BASE, an absolute URL such as that of a gateway, replaces
+// DefaultMistralBaseURL.
+// MISTRAL_RPS limits the requests per second of all Mistral clients together,
+// and MISTRAL_TIMEOUT and MISTRAL_IMAGE_TIMEOUT, durations such as "90s" or
+// seconds, bound each request. AMG_LLM_CIRCUIT_THRESHOLD and
+// AMG_LLM_CIRCUIT_COOLDOWN configure the circuit breaker.
+func NewMistralLlmService(opts ...MistralOption) (*MistralLlmService, error) {
+	limiter, err := ratelimit.FromEnv("MISTRAL_RPS")
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := envDuration("MISTRAL_TIMEOUT", DefaultMistralTimeout)
+	if err != nil {
+		return nil, err
+	}
+	imageTimeout, err := envDuration("MISTRAL_IMAGE_TIMEOUT", DefaultMistralImageTimeout)
+	if err != nil {
+		return nil, err
+	}
+	breaker, err := circuitBreakerFromEnv("mistral")
+	if err != nil {
+		return nil, err
+	}
+	baseURL, err := httpclient.BaseURLFromEnv("MISTRAL_API_BASE")
+	if err != nil {
+		return nil, err
+	}
+	if baseURL == "" {
+		baseURL = DefaultMistralBaseURL
+	}
+	s := &MistralLlmService{
+		apiKey:             os.Getenv("MISTRAL_API_KEY"),
+		chatModel:          envOr("MISTRAL_CHAT_MODEL", DefaultMistralChatModel),
+		multimodalModel:    envOr("MISTRAL_MULTIMODAL_MODEL", DefaultMistralMultimodalModel),
+		APIBaseURL:         baseURL,
+		Retry:              DefaultRetryPolicy,
+		Limiter:            limiter,
+		Timeout:            timeout,
+		ImageTimeout:       imageTimeout,
+		MaxImageBytes:      DefaultMaxImageBytes,
+		MaxImageDimension:  DefaultMaxImageDimension,
+		MaxImages:          DefaultMaxImages,
+		MaxTotalImageBytes: DefaultMaxTotalImageBytes,
+		MaxDocumentBytes:   DefaultMaxDocumentBytes,
+		Metrics:            metrics.Default,
+		Breaker:            breaker,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.apiKey == "" {
 		return nil, fmt.Errorf("MISTRAL_API_KEY environment variable not set")
 	}
+	if s.HTTPClient == nil {
+		if s.HTTPClient, err = httpclient.FromEnv(); err != nil {
+			return nil, err
+		}
+	}
+	if s.chatModel == "" || s.multimodalModel == "" {
+		return nil, fmt.Errorf("mistral model names must not be empty")
+	}
+	return s, nil
+}
+
+// ChatModel returns the model that generates text.
+func (s *MistralLlmService) ChatModel() string {
+	return s.chatModel
+}
 
-	return &MistralLlmService{
-		apiKey:          apiKey,
-		HTTPClient:      &http.Client{},
-		chatModel:       "mistral-small-latest",
-		multimodalModel: "mistral-medium-latest",
-		APIBaseURL:      "https://api.mistral.ai/v1", // Default API base URL
-	}, nil
+// MultimodalModel returns the model that reads images.
+func (s *MistralLlmService) MultimodalModel() string {
+	return s.multimodalModel
 }
 
 // GenerateText generates text using the Mistral chat completions API.
-func (s *MistralLlmService) GenerateText(ctx context.Context, prompt string) (string, error) {
-	slog.InfoContext(ctx, "MistralLlmService: GenerateText called", "model", s.chatModel, "prompt_length", len(prompt))
+func (s *MistralLlmService) GenerateText(ctx context.Context, prompt string, opts ...GenerateOption) (string, error) {
+	content, _, err := s.GenerateTextWithUsage(ctx, prompt, opts...)
+	return content, err
+}
+
+// Ping lists the models of the Mistral API with the service's key.
+func (s *MistralLlmService) Ping(ctx context.Context) error {
+	return ping(ctx, s.client(), "mistral", s.APIBaseURL+"/models", func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	})
+}
+
+// GenerateTextWithUsage generates text like GenerateText, also returning the
+// token usage the API reports.
+func (s *MistralLlmService) GenerateTextWithUsage(ctx context.Context, prompt string, opts ...GenerateOption) (string, Usage, error) {
+	return s.converse(ctx, []Message{{Role: RoleUser, Content: prompt}}, opts)
+}
+
+// Chat generates the assistant's reply to a conversation, sending the messages
+// in order with their roles.
+func (s *MistralLlmService) Chat(ctx context.Context, messages []Message, opts ...GenerateOption) (string, error) {
+	content, _, err := s.converse(ctx, messages, opts)
+	return content, err
+}
+
+// converse generates the reply to messages, returning the usage the API reports.
+func (s *MistralLlmService) converse(ctx context.Context, messages []Message, opts []GenerateOption) (string, Usage, error) {
+	slog.InfoContext(ctx, "MistralLlmService: Chat called", "model", s.chatModel, "messages", len(messages))
+
+	if err := checkMessages(messages); err != nil {
+		return "", Usage{}, err
+	}
+	requestPayload := generation(defaultTemperature, defaultMaxTokens, opts).chatCompletion("random_seed", map[string]interface{}{
+		"model":    s.chatModel,
+		"messages": chatMessages(messages),
+	})
+
+	content, usage, err := s.complete(ctx, requestPayload, "")
+	if err != nil {
+		// A truncated reply keeps its text and usage.
+		return content, usage, err
+	}
+	slog.InfoContext(ctx, "MistralLlmService: Text generated successfully", "response_length", len(content), "total_tokens", usage.TotalTokens)
+	return content, usage, nil
+}
+
+// generateJSON generates text with the json_object response format, which makes
+// the model reply with valid JSON.
+func (s *MistralLlmService) generateJSON(ctx context.Context, prompt string, opts []GenerateOption) (string, Usage, error) {
+	slog.InfoContext(ctx, "MistralLlmService: generateJSON called", "model", s.chatModel, "prompt_length", len(prompt))
 
-	requestPayload := map[string]interface{}{
+	return s.complete(ctx, generation(jsonTemperature, jsonMaxTokens, opts).chatCompletion("random_seed", map[string]interface{}{
 		"model": s.chatModel,
 		"messages": []map[string]string{
 			{"role": "user", "content": prompt},
 		},
-		// Optional parameters - good to have some defaults
-		"temperature": 0.7,
-		"max_tokens":  500,
-	}
+		"response_format": map[string]string{"type": "json_object"},
+	}), "json")
+}
 
-	requestBody, err := json.Marshal(requestPayload)
-	if err != nil {
-		slog.ErrorContext(ctx, "MistralLlmService: Failed to marshal request body", "error", err)
-		return "", fmt.Errorf("failed to marshal request body: %w", err)
-	}
+// generateWithSchema generates text with the json_schema response format, which
+// constrains the reply to schema.
+func (s *MistralLlmService) generateWithSchema(ctx context.Context, prompt, name string, schema json.RawMessage, opts []GenerateOption) (string, Usage, error) {
+	slog.InfoContext(ctx, "MistralLlmService: generateWithSchema called", "model", s.chatModel, "schema", name, "prompt_length", len(prompt))
 
-	url := s.APIBaseURL + "/chat/completions"
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(requestBody))
-	if err != nil {
-		slog.ErrorContext(ctx, "MistralLlmService: Failed to create HTTP request", "error", err, "url", url)
-		return "", fmt.Errorf("failed to create request to %s: %w", url, err)
-	}
+	return s.complete(ctx, generation(jsonTemperature, jsonMaxTokens, opts).chatCompletion("random_seed", map[string]interface{}{
+		"model": s.chatModel,
+		"messages": []map[string]string{
+			{"role": "user", "content": prompt},
+		},
+		"response_format": schemaResponseFormat(name, schema),
+	}), "json")
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+s.apiKey)
-	req.Header.Set("Accept", "application/json")
+// GenerateWithTools offers the chat model tools as functions it may call and
+// returns its first call, or its text when it answers without calling one.
+func (s *MistralLlmService) GenerateWithTools(ctx context.Context, prompt string, tools []ToolDefinition, opts ...GenerateOption) (ToolCallResult, error) {
+	slog.InfoContext(ctx, "MistralLlmService: GenerateWithTools called", "model", s.chatModel, "prompt_length", len(prompt), "tools", len(tools))
 
-	resp, err := s.HTTPClient.Do(req)
+	if len(tools) == 0 {
+		return ToolCallResult{}, fmt.Errorf("no tools given")
+	}
+	message, usage, err := s.send(ctx, generation(jsonTemperature, jsonMaxTokens, opts).chatCompletion("random_seed", map[string]interface{}{
+		"model": s.chatModel,
+		"messages": []map[string]string{
+			{"role": "user", "content": prompt},
+		},
+		"tools":       toolsPayload(tools),
+		"tool_choice": "auto",
+	}), "tools")
 	if err != nil {
-		slog.ErrorContext(ctx, "MistralLlmService: Failed to send request to Mistral API", "error", err, "url", url)
-		return "", fmt.Errorf("failed to send request to Mistral API: %w", err)
+		return ToolCallResult{Text: message.Content, Usage: usage}, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		slog.ErrorContext(ctx, "MistralLlmService: Mistral API error", "status_code", resp.StatusCode, "response_body", string(bodyBytes))
-		return "", fmt.Errorf("mistral API error: %s - %s", resp.Status, string(bodyBytes))
+	result := ToolCallResult{Text: message.Content, Usage: usage}
+	if len(message.ToolCalls) > 0 {
+		call := message.ToolCalls[0].Function
+		arguments, err := toolArguments(call.Name, call.Arguments)
+		if err != nil {
+			slog.ErrorContext(ctx, "MistralLlmService: Invalid tool call arguments", "tool", call.Name, "error", err)
+			return ToolCallResult{}, err
+		}
+		result.Tool, result.Arguments = call.Name, arguments
 	}
+	slog.InfoContext(ctx, "MistralLlmService: Tools answered successfully", "tool", result.Tool, "total_tokens", usage.TotalTokens)
+	return result, nil
+}
 
-	var mistralResponse struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-	}
+// GenerateTextStream generates text like GenerateText, reading the server-sent
+// events of a streamed chat completion. Opening the stream is retried as s.Retry
+// allows; once text has been emitted, a failure ends the stream.
+func (s *MistralLlmService) GenerateTextStream(ctx context.Context, prompt string, opts ...GenerateOption) (<-chan string, <-chan error) {
+	slog.InfoContext(ctx, "MistralLlmService: GenerateTextStream called", "model", s.chatModel, "prompt_length", len(prompt))
 
-	if err := json.NewDecoder(resp.Body).Decode(&mistralResponse); err != nil {
-		slog.ErrorContext(ctx, "MistralLlmService: Failed to decode Mistral API response", "error", err)
-		return "", fmt.Errorf("failed to decode mistral response: %w", err)
-	}
+	payload := generation(defaultTemperature, defaultMaxTokens, opts).chatCompletion("random_seed", map[string]interface{}{
+		"model": s.chatModel,
+		"messages": []map[string]string{
+			{"role": "user", "content": prompt},
+		},
+		"stream": true,
+	})
+	return streamText(ctx, streamCall{provider: "mistral", metrics: s.Metrics, breaker: s.Breaker}, func(emit func(string) bool) (Usage, error) {
+		var body io.ReadCloser
+		err := s.Retry.Do(ctx, "mistral chat completion stream", func() (bool, error) {
+			req, err := streamRequest(ctx, s.APIBaseURL+"/chat/completions", payload)
+			if err != nil {
+				return false, err
+			}
+			req.Header.Set("Authorization", "Bearer "+s.apiKey)
 
-	if len(mistralResponse.Choices) == 0 || mistralResponse.Choices[0].Message.Content == "" {
-		slog.WarnContext(ctx, "MistralLlmService: No content found in Mistral API response", "response", mistralResponse)
-		return "", fmt.Errorf("no content found in mistral response")
-	}
+			if err := s.Limiter.Wait(ctx); err != nil {
+				return false, err
+			}
+			body, err = openStream(s.client(), req, "mistral")
+			return transientStream(err), err
+		})
+		if err != nil {
+			return Usage{}, err
+		}
+		defer body.Close()
+		return chatCompletionDeltas(body, emit)
+	})
+}
 
-	slog.InfoContext(ctx, "MistralLlmService: Text generated successfully", "response_length", len(mistralResponse.Choices[0].Message.Content))
-	return mistralResponse.Choices[0].Message.Content, nil
+// ExtractTextFromImage extracts text from an image using a Mistral multimodal model;
+// see ExtractTextFromImages.
+func (s *MistralLlmService) ExtractTextFromImage(ctx context.Context, prompt string, image []byte, mimeType string, opts ...GenerateOption) (string, error) {
+	return s.ExtractTextFromImages(ctx, prompt, []ImageInput{{Data: image, MimeType: mimeType}}, opts...)
 }
 
-// ExtractTextFromImage extracts text from an image using a Mistral multimodal model
-// by encoding the image as base64 and sending it with a text prompt.
-func (s *MistralLlmService) ExtractTextFromImage(ctx context.Context, prompt string, image []byte, mimeType string) (string, error) {
-	slog.InfoContext(ctx, "MistralLlmService: ExtractTextFromImage called",
+// ExtractTextFromImages extracts text from images using a Mistral multimodal model
+// by encoding them as base64 and sending them after a text prompt in one message.
+// At most s.MaxImages images are accepted, and images are downscaled to share
+// s.MaxTotalImageBytes.
+func (s *MistralLlmService) ExtractTextFromImages(ctx context.Context, prompt string, images []ImageInput, opts ...GenerateOption) (string, error) {
+	slog.InfoContext(ctx, "MistralLlmService: ExtractTextFromImages called",
 		"model", s.multimodalModel,
 		"prompt_length", len(prompt),
-		"image_size", len(image),
-		"mime_type", mimeType)
+		"images", len(images),
+		"image_size", imageBytes(images))
 
-	if len(image) == 0 {
-		slog.ErrorContext(ctx, "MistralLlmService: Image data is empty")
-		return "", fmt.Errorf("image data is empty")
+	if err := checkImages(images); err != nil {
+		slog.ErrorContext(ctx, "MistralLlmService: Invalid images", "error", err)
+		return "", err
+	}
+	if s.MaxImages > 0 && len(images) > s.MaxImages {
+		return "", fmt.Errorf("%d images given, more than the %d allowed per request", len(images), s.MaxImages)
+	}
+	maxBytes := s.MaxImageBytes
+	if share := s.MaxTotalImageBytes / len(images); share > 0 && (maxBytes <= 0 || share < maxBytes) {
+		maxBytes = share
 	}
 
-	// Validate or default MIME type if necessary.
-	// For "data:<mimeType>;base64,...", the mimeType needs to be accurate.
-	// Example: "image/jpeg", "image/png"
-	if mimeType == "" {
-		// Attempt to detect, or default, or return error
-		// For simplicity, we'll require it for now or default to a common one if appropriate for the API
-		slog.WarnContext(ctx, "MistralLlmService: MimeType is empty, defaulting to image/jpeg. Accurate MimeType is preferred.")
-		mimeType = "image/jpeg" // Or handle more robustly
+	content := []map[string]interface{}{
+		{
+			"type": "text",
+			"text": prompt,
+		},
+	}
+	for n, image := range images {
+		// For "data:<mimeType>;base64,...", the mimeType needs to be accurate.
+		mimeType := image.MimeType
+		if mimeType == "" {
+			slog.WarnContext(ctx, "MistralLlmService: MimeType is empty, defaulting to image/jpeg. Accurate MimeType is preferred.", "image", n+1)
+			mimeType = "image/jpeg"
+		}
+		data, mimeType, err := fitImage(ctx, image.Data, mimeType, maxBytes, s.MaxImageDimension)
+		if err != nil {
+			slog.ErrorContext(ctx, "MistralLlmService: Image exceeds the size limit", "image", n+1, "error", err)
+			return "", imageError(n, len(images), err)
+		}
+		content = append(content, map[string]interface{}{
+			"type": "image_url",
+			"image_url": map[string]string{
+				"url": fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(data)),
+			},
+		})
 	}
 
-	base64Image := base64.StdEncoding.EncodeToString(image)
-	imageURL := fmt.Sprintf("data:%s;base64,%s", mimeType, base64Image)
+	requestPayload := generation(imageTemperature, imageMaxTokens, opts).chatCompletion("random_seed", map[string]interface{}{
+		"model": s.multimodalModel,
+		"messages": []map[string]interface{}{
+			{
+				"role":    "user",
+				"content": content,
+			},
+		},
+	})
+
+	text, _, err := s.complete(ctx, requestPayload, "multimodal")
+	if err != nil {
+		return "", err
+	}
+	slog.InfoContext(ctx, "MistralLlmService: Text extracted from images successfully", "response_length", len(text))
+	return text, nil
+}
+
+// ExtractTextFromDocument extracts text from a PDF using a Mistral multimodal
+// model, which reads documents, by sending it base64-encoded as a document_url
+// after a text prompt in one message. PDFs over s.MaxDocumentBytes are refused.
+func (s *MistralLlmService) ExtractTextFromDocument(ctx context.Context, prompt string, pdf []byte, opts ...GenerateOption) (string, error) {
+	slog.InfoContext(ctx, "MistralLlmService: ExtractTextFromDocument called",
+		"model", s.multimodalModel,
+		"prompt_length", len(prompt),
+		"document_size", len(pdf))
 
-	requestPayload := map[string]interface{}{
+	if err := checkDocument(pdf, s.MaxDocumentBytes); err != nil {
+		slog.ErrorContext(ctx, "MistralLlmService: Invalid document", "error", err)
+		return "", err
+	}
+	requestPayload := generation(imageTemperature, documentMaxTokens, opts).chatCompletion("random_seed", map[string]interface{}{
 		"model": s.multimodalModel,
 		"messages": []map[string]interface{}{
 			{
@@ -139,71 +496,174 @@ func (s *MistralLlmService) ExtractTextFromImage(ctx context.Context, prompt str
 				"content": []map[string]interface{}{
 					{
 						"type": "text",
-						"text": prompt,
+						"text": documentPrompt(prompt),
 					},
 					{
-						"type": "image_url",
-						"image_url": map[string]string{
-							"url": imageURL,
-						},
+						"type":         "document_url",
+						"document_url": "data:application/pdf;base64," + base64.StdEncoding.EncodeToString(pdf),
 					},
 				},
 			},
 		},
-		"temperature": 0.2, // Lower temperature for more factual extraction
-		"max_tokens":  300, // Max tokens for the extracted information
-	}
+	})
 
-	requestBody, err := json.Marshal(requestPayload)
+	text, _, err := s.complete(ctx, requestPayload, "document")
 	if err != nil {
-		slog.ErrorContext(ctx, "MistralLlmService: Failed to marshal multimodal request body", "error", err)
-		return "", fmt.Errorf("failed to marshal multimodal request body: %w", err)
+		return "", err
 	}
+	slog.InfoContext(ctx, "MistralLlmService: Text extracted from document successfully", "response_length", len(text))
+	return text, nil
+}
 
-	url := s.APIBaseURL + "/chat/completions"
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(requestBody))
-	if err != nil {
-		slog.ErrorContext(ctx, "MistralLlmService: Failed to create multimodal HTTP request", "error", err, "url", url)
-		return "", fmt.Errorf("failed to create multimodal request to %s: %w", url, err)
-	}
+// TranscribeAudio returns an error wrapping ErrUnsupported: the chat completions API does not take audio.
+func (s *MistralLlmService) TranscribeAudio(ctx context.Context, audio []byte, mimeType string) (string, error) {
+	return "", audioUnsupported(ProviderMistral)
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+s.apiKey)
-	req.Header.Set("Accept", "application/json")
+// mistralMessage is the message of a chat completion's choice.
+type mistralMessage struct {
+	Content   string `json:"content"`
+	ToolCalls []struct {
+		Function struct {
+			Name string `json:"name"`
+			// Arguments is a JSON object, usually encoded in a string.
+			Arguments json.RawMessage `json:"arguments"`
+		} `json:"function"`
+	} `json:"tool_calls"`
+}
 
-	resp, err := s.HTTPClient.Do(req)
-	if err != nil {
-		slog.ErrorContext(ctx, "MistralLlmService: Failed to send multimodal request to Mistral API", "error", err, "url", url)
-		return "", fmt.Errorf("failed to send multimodal request to Mistral API: %w", err)
-	}
-	defer resp.Body.Close()
+// complete posts requestPayload to the chat completions endpoint and returns the
+// content of the first choice. kind, such as "multimodal", qualifies the errors.
+// A reply cut off at the max tokens is returned with a *TruncatedError.
+func (s *MistralLlmService) complete(ctx context.Context, requestPayload map[string]interface{}, kind string) (string, Usage, error) {
+	message, usage, err := s.send(ctx, requestPayload, kind)
+	return message.Content, usage, err
+}
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		slog.ErrorContext(ctx, "MistralLlmService: Mistral API error on multimodal request", "status_code", resp.StatusCode, "response_body", string(bodyBytes))
-		return "", fmt.Errorf("mistral API error (multimodal): %s - %s", resp.Status, string(bodyBytes))
-	}
+// client returns HTTPClient wrapped in the service's middleware.
+func (s *MistralLlmService) client() Doer {
+	return chain(s.HTTPClient, s.Middleware)
+}
 
-	var mistralResponse struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
+// send posts requestPayload to the chat completions endpoint, retrying transient
+// failures as s.Retry allows, and returns the message of the first choice, which
+// has content or tool calls. kind qualifies the errors as for complete. Each
+// attempt is bounded by s.Timeout, or by s.ImageTimeout for multimodal and
+// document requests.
+func (s *MistralLlmService) send(ctx context.Context, requestPayload map[string]interface{}, kind string) (message mistralMessage, usage Usage, err error) {
+	model, _ := requestPayload["model"].(string)
+	if err := checkModel(model); err != nil {
+		return mistralMessage{}, Usage{}, err
+	}
+	if err := budget.FromContext(ctx).Check(); err != nil {
+		return mistralMessage{}, Usage{}, err
+	}
+	defer func(start time.Time) { recordCall(ctx, s.Metrics, "mistral", kind, start, usage, err) }(time.Now())
+	qualifier := ""
+	if kind != "" {
+		qualifier = kind + " "
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&mistralResponse); err != nil {
-		slog.ErrorContext(ctx, "MistralLlmService: Failed to decode Mistral API multimodal response", "error", err)
-		return "", fmt.Errorf("failed to decode mistral multimodal response: %w", err)
+	requestBody, err := json.Marshal(requestPayload)
+	if err != nil {
+		slog.ErrorContext(ctx, "MistralLlmService: Failed to marshal request body", "error", err, "kind", kind)
+		return mistralMessage{}, Usage{}, fmt.Errorf("failed to marshal %srequest body: %w", qualifier, err)
 	}
 
-	if len(mistralResponse.Choices) == 0 || mistralResponse.Choices[0].Message.Content == "" {
-		slog.WarnContext(ctx, "MistralLlmService: No content found in Mistral API multimodal response", "response", mistralResponse)
-		return "", fmt.Errorf("no content found in mistral multimodal response")
+	timeout := s.Timeout
+	if kind == "multimodal" || kind == "document" {
+		timeout = s.ImageTimeout
 	}
 
-	slog.InfoContext(ctx, "MistralLlmService: Text extracted from image successfully", "response_length", len(mistralResponse.Choices[0].Message.Content))
-	return mistralResponse.Choices[0].Message.Content, nil
+	url := s.APIBaseURL + "/chat/completions"
+	attempt := func() (bool, error) {
+		// Wait for the limiter first so that waiting does not use up the timeout.
+		if err := s.Limiter.Wait(ctx); err != nil {
+			return false, err
+		}
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, timeout)
+		}
+		defer cancel()
+		// timedOut reports whether the attempt failed on its own deadline rather
+		// than ctx ending.
+		timedOut := func() bool {
+			return ctx.Err() == nil && errors.Is(attemptCtx.Err(), context.DeadlineExceeded)
+		}
+		timeoutError := func() error {
+			slog.ErrorContext(ctx, "MistralLlmService: Mistral API request timed out", "timeout", timeout, "kind", kind)
+			return fmt.Errorf("mistral %srequest timed out after %s: %w", qualifier, timeout, context.DeadlineExceeded)
+		}
+
+		req, err := http.NewRequestWithContext(attemptCtx, "POST", url, bytes.NewReader(requestBody))
+		if err != nil {
+			slog.ErrorContext(ctx, "MistralLlmService: Failed to create HTTP request", "error", err, "url", url)
+			return false, fmt.Errorf("failed to create %srequest to %s: %w", qualifier, url, err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+		req.Header.Set("Accept", "application/json")
+
+		resp, err := s.client().Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				slog.InfoContext(ctx, "MistralLlmService: Request cancelled", "kind", kind, "error", ctx.Err())
+				return false, ctx.Err()
+			}
+			if timedOut() {
+				return true, timeoutError()
+			}
+			slog.ErrorContext(ctx, "MistralLlmService: Failed to send request to Mistral API", "error", err, "url", url)
+			return retry.TransientError(err), fmt.Errorf("failed to send %srequest to Mistral API: %w", qualifier, err)
+		}
+		defer retry.CloseBody(resp.Body)
+
+		if resp.StatusCode != http.StatusOK {
+			bodyBytes, _ := io.ReadAll(resp.Body)
+			slog.ErrorContext(ctx, "MistralLlmService: Mistral API error", "status_code", resp.StatusCode, redact.Body("response_body", string(bodyBytes)))
+			return retry.TransientStatus(resp.StatusCode), retry.StatusError(resp, newAPIError("mistral", kind, resp, bodyBytes))
+		}
+
+		var mistralResponse struct {
+			Choices []struct {
+				Message      mistralMessage `json:"message"`
+				FinishReason string         `json:"finish_reason"`
+			} `json:"choices"`
+			Usage chatCompletionUsage `json:"usage"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&mistralResponse); err != nil {
+			if ctx.Err() != nil {
+				slog.InfoContext(ctx, "MistralLlmService: Request cancelled", "kind", kind, "error", ctx.Err())
+				return false, ctx.Err()
+			}
+			if timedOut() {
+				return true, timeoutError()
+			}
+			slog.ErrorContext(ctx, "MistralLlmService: Failed to decode Mistral API response", "error", err)
+			return false, fmt.Errorf("failed to decode mistral %sresponse: %w", qualifier, err)
+		}
+		if len(mistralResponse.Choices) == 0 || (mistralResponse.Choices[0].Message.Content == "" && len(mistralResponse.Choices[0].Message.ToolCalls) == 0) {
+			slog.WarnContext(ctx, "MistralLlmService: No content found in Mistral API response")
+			slog.DebugContext(ctx, "MistralLlmService: Response without content", redact.Content("response", fmt.Sprintf("%+v", mistralResponse)))
+			return false, fmt.Errorf("no content found in mistral %sresponse", qualifier)
+		}
+		message, usage = mistralResponse.Choices[0].Message, mistralResponse.Usage.usage()
+		if mistralResponse.Choices[0].FinishReason == finishLength {
+			slog.WarnContext(ctx, "MistralLlmService: Reply truncated at the max tokens", "kind", kind, "completion_tokens", usage.CompletionTokens)
+			return false, &TruncatedError{Provider: "mistral", Text: message.Content, Usage: usage}
+		}
+		return false, nil
+	}
+	err = s.Retry.Do(ctx, "mistral "+qualifier+"chat completion", func() (bool, error) {
+		if err := s.Breaker.Allow(); err != nil {
+			return false, err
+		}
+		transient, err := attempt()
+		s.Breaker.Record(ctx, err)
+		return transient, err
+	})
+	return message, usage, err
 }
 
 // Ensure NewMistralLlmService is correctly defined and callable from other packages.
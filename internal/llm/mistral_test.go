@@ -2,14 +2,23 @@ package llm
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/budget"
 )
 
 // mockMistralServer sets up a test HTTP server to mock the Mistral API.
@@ -76,6 +85,7 @@ func TestMistralLlmService_GenerateText_APIError(t *testing.T) {
 	}
 	service.HTTPClient = server.Client() // Use exported field
 	service.APIBaseURL = server.URL      // Point service to the mock server
+	service.Retry.BaseDelay = time.Millisecond
 
 	_, err = service.GenerateText(context.Background(), "test prompt")
 	if err == nil {
@@ -245,6 +255,7 @@ func TestMistralLlmService_ExtractTextFromImage_APIError(t *testing.T) {
 	service, _ := NewMistralLlmService()
 	service.HTTPClient = server.Client()
 	service.APIBaseURL = server.URL
+	service.Retry.BaseDelay = time.Millisecond
 
 	_, err := service.ExtractTextFromImage(context.Background(), "prompt", []byte("dummyData"), "image/jpeg")
 	if err == nil {
@@ -254,3 +265,491 @@ func TestMistralLlmService_ExtractTextFromImage_APIError(t *testing.T) {
 		t.Errorf("Expected error to contain 'mistral API error (multimodal)' and '504 Gateway Timeout', got: %v", err)
 	}
 }
+
+func TestNewMistralLlmService_Models(t *testing.T) {
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	t.Setenv("MISTRAL_CHAT_MODEL", "")
+	t.Setenv("MISTRAL_MULTIMODAL_MODEL", "")
+	service, err := NewMistralLlmService()
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	if service.ChatModel() != DefaultMistralChatModel || service.MultimodalModel() != DefaultMistralMultimodalModel {
+		t.Errorf("Expected the default models, got %s and %s", service.ChatModel(), service.MultimodalModel())
+	}
+
+	t.Setenv("MISTRAL_CHAT_MODEL", "mistral-large-latest")
+	t.Setenv("MISTRAL_MULTIMODAL_MODEL", "pixtral-large-2411")
+	service, _ = NewMistralLlmService()
+	if service.ChatModel() != "mistral-large-latest" || service.MultimodalModel() != "pixtral-large-2411" {
+		t.Errorf("Expected the environment's models, got %s and %s", service.ChatModel(), service.MultimodalModel())
+	}
+
+	service, _ = NewMistralLlmService(WithChatModel("mistral-small-2503"), WithMultimodalModel("mistral-medium-2505"))
+	if service.ChatModel() != "mistral-small-2503" || service.MultimodalModel() != "mistral-medium-2505" {
+		t.Errorf("Expected the options to override the environment, got %s and %s", service.ChatModel(), service.MultimodalModel())
+	}
+
+	if _, err := NewMistralLlmService(WithChatModel("")); err == nil {
+		t.Error("Expected an error for an empty model name")
+	}
+}
+
+func TestNewMistralLlmService_KeyAndBaseURLOptions(t *testing.T) {
+	var model, auth string
+	server := mockMistralServer(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Model string `json:"model"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		model, auth = payload.Model, r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices": [{"message": {"content": "ok"}}]}`)
+	})
+	defer server.Close()
+
+	t.Setenv("MISTRAL_API_KEY", "")
+	if _, err := NewMistralLlmService(); err == nil || !strings.Contains(err.Error(), "MISTRAL_API_KEY") {
+		t.Errorf("Expected an error naming MISTRAL_API_KEY, got %v", err)
+	}
+	service, err := NewMistralLlmService(WithAPIKey("option_key"), WithBaseURL(server.URL+"/"), WithChatModel("mistral-large-latest"))
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	service.HTTPClient = server.Client()
+
+	if _, err := service.GenerateText(context.Background(), "test prompt"); err != nil {
+		t.Fatalf("GenerateText failed: %v", err)
+	}
+	if model != "mistral-large-latest" || auth != "Bearer option_key" {
+		t.Errorf("Expected the configured model and key in the request, got %q and %q", model, auth)
+	}
+}
+
+func TestNewLlmService_MistralOptions(t *testing.T) {
+	var keys []string
+	server := mockMistralServer(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Authorization"))
+		writeChoice(w, "ok")
+	})
+	defer server.Close()
+	t.Setenv("MISTRAL_API_KEY", "env_key")
+
+	// Two services with their own keys, such as one for extraction and one for
+	// summaries, and one falling back to the environment.
+	for _, opts := range [][]MistralOption{{WithAPIKey("extraction_key")}, {WithAPIKey("summary_key")}, nil} {
+		service, err := NewLlmService(ProviderMistral, append(opts, WithBaseURL(server.URL), WithHTTPClient(server.Client()))...)
+		if err != nil {
+			t.Fatalf("NewLlmService failed: %v", err)
+		}
+		if _, err := service.GenerateText(context.Background(), "test prompt"); err != nil {
+			t.Fatalf("GenerateText failed: %v", err)
+		}
+	}
+	want := []string{"Bearer extraction_key", "Bearer summary_key", "Bearer env_key"}
+	if strings.Join(keys, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected the keys %v, got %v", want, keys)
+	}
+
+	if _, err := NewLlmService(ProviderOllama, WithAPIKey("key")); err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Errorf("Expected options to be rejected for another provider, got %v", err)
+	}
+}
+
+// newRetryingMistralService points a MistralLlmService that retries quickly at a
+// server answering with statuses in turn, then with a completion; it counts requests.
+func newRetryingMistralService(t *testing.T, statuses ...int) (*MistralLlmService, *int) {
+	t.Helper()
+	requests := 0
+	server := mockMistralServer(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests <= len(statuses) {
+			http.Error(w, http.StatusText(statuses[requests-1]), statuses[requests-1])
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices": [{"message": {"content": "ok"}}]}`)
+	})
+	t.Cleanup(server.Close)
+
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	service, err := NewMistralLlmService(WithBaseURL(server.URL), WithRetry(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 4 * time.Millisecond}))
+	if err != nil {
+		t.Fatalf("NewMistralLlmService failed: %v", err)
+	}
+	service.HTTPClient = server.Client()
+	return service, &requests
+}
+
+func TestMistralLlmService_RetriesTransientFailures(t *testing.T) {
+	service, requests := newRetryingMistralService(t, http.StatusTooManyRequests, http.StatusServiceUnavailable)
+	text, err := service.GenerateText(context.Background(), "test prompt")
+	if err != nil || text != "ok" || *requests != 3 {
+		t.Errorf("Expected success on the third attempt, got %q and %v after %d requests", text, err, *requests)
+	}
+
+	service, requests = newRetryingMistralService(t, http.StatusBadGateway, http.StatusInternalServerError)
+	if text, err := service.ExtractTextFromImage(context.Background(), "prompt", []byte("dummyData"), "image/png"); err != nil || text != "ok" || *requests != 3 {
+		t.Errorf("Expected the image request retried too, got %q and %v after %d requests", text, err, *requests)
+	}
+}
+
+func TestMistralLlmService_RetryLimits(t *testing.T) {
+	service, requests := newRetryingMistralService(t, 503, 503, 503, 503)
+	_, err := service.GenerateText(context.Background(), "test prompt")
+	if err == nil || !strings.Contains(err.Error(), "503 Service Unavailable") || !strings.Contains(err.Error(), "gave up after 3 attempts") || *requests != 3 {
+		t.Errorf("Expected to give up after 3 attempts, got %v after %d requests", err, *requests)
+	}
+
+	service, requests = newRetryingMistralService(t, http.StatusUnauthorized)
+	if _, err := service.GenerateText(context.Background(), "test prompt"); err == nil || !strings.Contains(err.Error(), "401 Unauthorized") || *requests != 1 {
+		t.Errorf("Expected a 401 to fail at once, got %v after %d requests", err, *requests)
+	}
+
+	service, requests = newRetryingMistralService(t, 503, 503)
+	service.Retry = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour, MaxDelay: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := service.GenerateText(ctx, "test prompt"); !errors.Is(err, context.DeadlineExceeded) || *requests != 1 {
+		t.Errorf("Expected the wait to end with the context, got %v after %d requests", err, *requests)
+	}
+}
+
+func TestMistralLlmService_RetryBudget(t *testing.T) {
+	service, requests := newRetryingMistralService(t, 503, 503, 503, 503)
+	runBudget := budget.New(budget.Limits{MaxRetries: 1})
+	ctx := budget.NewContext(context.Background(), runBudget)
+
+	_, err := service.GenerateText(ctx, "test prompt")
+	if !errors.Is(err, budget.ErrBudgetExhausted) || !strings.Contains(err.Error(), "503 Service Unavailable") || *requests != 2 {
+		t.Errorf("Expected the second retry to be refused, got %v after %d requests", err, *requests)
+	}
+	if _, err := service.GenerateText(ctx, "test prompt"); !errors.Is(err, budget.ErrBudgetExhausted) || *requests != 2 {
+		t.Errorf("Expected the next request to fail fast, got %v after %d requests", err, *requests)
+	}
+	if state := runBudget.State(); state.Retries != 1 || state.Exhausted != "1 retries used" {
+		t.Errorf("Expected one retry charged before the budget ran out, got %+v", state)
+	}
+
+	// Tokens are charged as the provider reports them.
+	server := mockMistralServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices": [{"message": {"content": "ok"}}], "usage": {"prompt_tokens": 80, "completion_tokens": 20, "total_tokens": 100}}`)
+	})
+	defer server.Close()
+	service, err = NewMistralLlmService(WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("NewMistralLlmService failed: %v", err)
+	}
+	ctx = budget.NewContext(context.Background(), budget.New(budget.Limits{MaxTokens: 150}))
+	for n := 1; n <= 2; n++ {
+		if _, err := service.GenerateText(ctx, "test prompt"); err != nil {
+			t.Fatalf("Expected request %d within the budget to succeed, got %v", n, err)
+		}
+	}
+	if _, err := service.GenerateText(ctx, "test prompt"); !errors.Is(err, budget.ErrBudgetExhausted) {
+		t.Errorf("Expected %v once 200 of 150 tokens were used, got %v", budget.ErrBudgetExhausted, err)
+	}
+}
+
+func TestMistralLlmService_RateLimit(t *testing.T) {
+	service, requests := newRetryingMistralService(t)
+	WithRateLimit(20)(service)
+
+	start := time.Now()
+	for range 2 {
+		if _, err := service.GenerateText(context.Background(), "test prompt"); err != nil {
+			t.Fatalf("GenerateText failed: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); *requests != 2 || elapsed < 45*time.Millisecond {
+		t.Errorf("Expected two requests spaced by 50ms, got %d in %v", *requests, elapsed)
+	}
+
+	t.Setenv("MISTRAL_RPS", "none")
+	if _, err := NewMistralLlmService(); err == nil || !strings.Contains(err.Error(), "MISTRAL_RPS") {
+		t.Errorf("Expected an invalid MISTRAL_RPS error, got %v", err)
+	}
+}
+
+func TestMistralLlmService_RetryAfter(t *testing.T) {
+	var retryAfter []string
+	requests := 0
+	server := mockMistralServer(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests <= len(retryAfter) {
+			w.Header().Set("Retry-After", retryAfter[requests-1])
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+			return
+		}
+		writeChoice(w, "ok")
+	})
+	defer server.Close()
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	service, err := NewMistralLlmService(WithBaseURL(server.URL),
+		WithRetry(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, MaxRetryAfter: time.Minute}))
+	if err != nil {
+		t.Fatalf("NewMistralLlmService failed: %v", err)
+	}
+
+	retryAfter = []string{"1"}
+	start := time.Now()
+	if text, err := service.GenerateText(context.Background(), "test prompt"); err != nil || text != "ok" {
+		t.Fatalf("Expected success after waiting, got %q, %v", text, err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("Expected to wait the second the server asked for, waited %v", elapsed)
+	}
+
+	requests, retryAfter = 0, []string{"120"}
+	if _, err := service.GenerateText(context.Background(), "test prompt"); err == nil || !strings.Contains(err.Error(), "asked to wait 2m0s") || requests != 1 {
+		t.Errorf("Expected to give up on a wait over the limit, got %v after %d requests", err, requests)
+	}
+}
+
+func TestMistralLlmService_Timeout(t *testing.T) {
+	server := mockMistralServer(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(100 * time.Millisecond):
+			writeChoice(w, "slow reply")
+		case <-r.Context().Done():
+		}
+	})
+	defer server.Close()
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	service, err := NewMistralLlmService(WithBaseURL(server.URL), WithRetry(RetryPolicy{MaxAttempts: 1}),
+		WithTimeout(20*time.Millisecond), WithImageTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("NewMistralLlmService failed: %v", err)
+	}
+
+	_, err = service.GenerateText(context.Background(), "test prompt")
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "timed out after 20ms") {
+		t.Errorf("Expected a timeout error mentioning the 20ms deadline, got %v", err)
+	}
+	text, err := service.ExtractTextFromImage(context.Background(), "read it", []byte("png"), "image/png")
+	if err != nil || text != "slow reply" {
+		t.Errorf("Expected the image request to outlast the text timeout, got %q, %v", text, err)
+	}
+
+	t.Setenv("MISTRAL_TIMEOUT", "90")
+	t.Setenv("MISTRAL_IMAGE_TIMEOUT", "5m")
+	service, err = NewMistralLlmService()
+	if err != nil {
+		t.Fatalf("NewMistralLlmService failed: %v", err)
+	}
+	if service.Timeout != 90*time.Second || service.ImageTimeout != 5*time.Minute {
+		t.Errorf("Expected timeouts of 90s and 5m, got %v and %v", service.Timeout, service.ImageTimeout)
+	}
+	t.Setenv("MISTRAL_TIMEOUT", "soon")
+	if _, err := NewMistralLlmService(); err == nil || !strings.Contains(err.Error(), "MISTRAL_TIMEOUT") {
+		t.Errorf("Expected an invalid MISTRAL_TIMEOUT error, got %v", err)
+	}
+}
+
+func TestMistralLlmService_ExtractTextFromImages(t *testing.T) {
+	var content []map[string]interface{}
+	server := mockMistralServer(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Messages []struct {
+				Content []map[string]interface{} `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		content = payload.Messages[0].Content
+		writeChoice(w, "Château Margaux 2015")
+	})
+	defer server.Close()
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	service, err := NewMistralLlmService(WithBaseURL(server.URL), WithMaxImages(2))
+	if err != nil {
+		t.Fatalf("NewMistralLlmService failed: %v", err)
+	}
+
+	images := []ImageInput{{Data: []byte("front"), MimeType: "image/png"}, {Data: []byte("back")}}
+	text, err := service.ExtractTextFromImages(context.Background(), "read the label", images)
+	if err != nil || text != "Château Margaux 2015" {
+		t.Fatalf("Expected the label's text, got %q, %v", text, err)
+	}
+	expected := []string{
+		"read the label",
+		"data:image/png;base64," + base64.StdEncoding.EncodeToString([]byte("front")),
+		"data:image/jpeg;base64," + base64.StdEncoding.EncodeToString([]byte("back")),
+	}
+	if len(content) != 3 || content[0]["text"] != expected[0] {
+		t.Fatalf("Expected the prompt and two images, got %v", content)
+	}
+	for n, want := range expected[1:] {
+		if url := content[n+1]["image_url"].(map[string]interface{})["url"]; url != want {
+			t.Errorf("Expected image %d to be %q, got %q", n+1, want, url)
+		}
+	}
+
+	if _, err := service.ExtractTextFromImages(context.Background(), "read", append(images, images[0])); err == nil || !strings.Contains(err.Error(), "more than the 2 allowed") {
+		t.Errorf("Expected too many images to fail, got %v", err)
+	}
+	if _, err := service.ExtractTextFromImages(context.Background(), "read", []ImageInput{images[0], {MimeType: "image/png"}}); err == nil || !strings.Contains(err.Error(), "image 2 of 2: image data is empty") {
+		t.Errorf("Expected the empty image to be named, got %v", err)
+	}
+	service.MaxTotalImageBytes = 8
+	if _, err := service.ExtractTextFromImages(context.Background(), "read", images); !errors.Is(err, ErrImageTooLarge) || !strings.Contains(err.Error(), "image 1 of 2") {
+		t.Errorf("Expected images over the total cap to fail, got %v", err)
+	}
+}
+
+func TestMistralLlmService_HTTPClient(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeChoice(w, "ok")
+	}))
+	defer server.Close()
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+
+	// The client trusts the test server's certificate through AMG_CA_BUNDLE.
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o644); err != nil {
+		t.Fatalf("Failed to write CA bundle: %v", err)
+	}
+	t.Setenv("AMG_CA_BUNDLE", bundle)
+	service, err := NewMistralLlmService(WithBaseURL(server.URL), WithRetry(RetryPolicy{MaxAttempts: 1}))
+	if err != nil {
+		t.Fatalf("NewMistralLlmService failed: %v", err)
+	}
+	if text, err := service.GenerateText(context.Background(), "test prompt"); err != nil || text != "ok" {
+		t.Errorf("Expected the CA bundle to be trusted, got %q, %v", text, err)
+	}
+
+	// WithHTTPClient takes precedence, even over invalid settings.
+	t.Setenv("AMG_CA_BUNDLE", filepath.Join(t.TempDir(), "missing.pem"))
+	if _, err := NewMistralLlmService(); err == nil {
+		t.Errorf("Expected a missing CA bundle to fail")
+	}
+	service, err = NewMistralLlmService(WithBaseURL(server.URL), WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatalf("NewMistralLlmService failed: %v", err)
+	}
+	if text, err := service.GenerateText(context.Background(), "test prompt"); err != nil || text != "ok" {
+		t.Errorf("Expected the given client to be used, got %q, %v", text, err)
+	}
+}
+
+func TestMistralLlmService_Cancellation(t *testing.T) {
+	var requests, active atomic.Int32
+	server := mockMistralServer(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		active.Add(1)
+		defer active.Add(-1)
+		// The server notices the client going away once the body is read.
+		io.Copy(io.Discard, r.Body)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+	defer server.Close()
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	service, err := NewMistralLlmService(WithBaseURL(server.URL), WithRetry(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}))
+	if err != nil {
+		t.Fatalf("NewMistralLlmService failed: %v", err)
+	}
+	goroutines := runtime.NumGoroutine()
+
+	calls := map[string]func(ctx context.Context) error{
+		"GenerateText": func(ctx context.Context) error {
+			_, err := service.GenerateText(ctx, "test prompt")
+			return err
+		},
+		"ExtractTextFromImage": func(ctx context.Context) error {
+			_, err := service.ExtractTextFromImage(ctx, "read", []byte("image"), "image/png")
+			return err
+		},
+	}
+	for name, call := range calls {
+		requests.Store(0)
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+		start := time.Now()
+		err := call(ctx)
+		if err != context.Canceled {
+			t.Errorf("Expected %s to return context.Canceled, got %v", name, err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Expected %s to return once cancelled, took %v", name, elapsed)
+		}
+		if requests.Load() != 1 {
+			t.Errorf("Expected %s not to retry after cancellation, got %d requests", name, requests.Load())
+		}
+	}
+
+	// The server sees every request end, and no goroutines are left behind.
+	deadline := time.Now().Add(2 * time.Second)
+	for (active.Load() > 0 || runtime.NumGoroutine() > goroutines) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if active.Load() > 0 {
+		t.Errorf("Expected the cancelled requests to be closed, %d still open", active.Load())
+	}
+	if now := runtime.NumGoroutine(); now > goroutines {
+		t.Errorf("Expected no goroutines to leak, got %d rather than %d", now, goroutines)
+	}
+}
+
+func TestMistralLlmService_CancelledWhileReadingBody(t *testing.T) {
+	server := mockMistralServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.Copy(io.Discard, r.Body)
+		io.WriteString(w, `{"choices": [`)
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+	defer server.Close()
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	service, err := NewMistralLlmService(WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("NewMistralLlmService failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := service.GenerateText(ctx, "test prompt"); err != context.DeadlineExceeded {
+		t.Errorf("Expected the caller's deadline to be returned, got %v", err)
+	}
+}
+
+func TestNewMistralLlmService_BaseURLFromEnv(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		writeChoice(w, "ok")
+	}))
+	defer server.Close()
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+
+	t.Setenv("MISTRAL_API_BASE", server.URL+"/v1/")
+	service, err := NewMistralLlmService(WithHTTPClient(server.Client()), WithRetry(RetryPolicy{MaxAttempts: 1}))
+	if err != nil {
+		t.Fatalf("NewMistralLlmService failed: %v", err)
+	}
+	if text, err := service.GenerateText(context.Background(), "test prompt"); err != nil || text != "ok" {
+		t.Fatalf("Expected the gateway to answer, got %q, %v", text, err)
+	}
+	if len(paths) != 1 || paths[0] != "/v1/chat/completions" {
+		t.Errorf("Expected a request to /v1/chat/completions, got %v", paths)
+	}
+
+	t.Setenv("MISTRAL_API_BASE", "")
+	service, err = NewMistralLlmService()
+	if err != nil {
+		t.Fatalf("NewMistralLlmService failed: %v", err)
+	}
+	if service.APIBaseURL != DefaultMistralBaseURL {
+		t.Errorf("Expected base URL %q without MISTRAL_API_BASE, got %q", DefaultMistralBaseURL, service.APIBaseURL)
+	}
+
+	t.Setenv("MISTRAL_API_BASE", "gateway.example.com")
+	if _, err := NewMistralLlmService(); err == nil || !strings.Contains(err.Error(), "MISTRAL_API_BASE") {
+		t.Errorf("Expected an invalid MISTRAL_API_BASE to fail, got %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// DefaultMockResponse is the reply of a MockLlmService with no Response set, a
+// JSON object with nothing extracted so that ingesting with it succeeds.
+const DefaultMockResponse = `{"entities": [], "relationships": []}`
+
+// MockResponse is one scripted reply of a MockLlmService: Text, or Err when set.
+type MockResponse struct {
+	Text string
+	Err  error
+}
+
+// MockLlmService is an LlmService for tests that answers without a network. Each
+// call takes the next of Responses, then falls back to Err or Response, and is
+// recorded so tests can check the prompts, images, documents and audio the
+// service was given. It is safe for concurrent use; set the fields before the first call.
+type MockLlmService struct {
+	// Response is the reply once Responses are used up.
+	Response string
+	// Responses are replied in order, one per call.
+	Responses []MockResponse
+	// Err, when set, fails every call once Responses are used up.
+	Err error
+	// Usage is reported for each reply.
+	Usage Usage
+	// PingErr, when set, is returned by Ping.
+	PingErr error
+
+	mu        sync.Mutex
+	calls     int
+	prompts   []string
+	images    [][]byte
+	documents [][]byte
+	audio     [][]byte
+}
+
+// NewMockLlmService creates a MockLlmService replying DefaultMockResponse.
+func NewMockLlmService() *MockLlmService {
+	return &MockLlmService{Response: DefaultMockResponse}
+}
+
+// Prompts returns the prompts of the calls so far, in order. For Chat it is the
+// content of the last message, and for TranscribeAudio it is empty.
+func (m *MockLlmService) Prompts() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.prompts...)
+}
+
+// Images returns the images given to ExtractTextFromImage and
+// ExtractTextFromImages so far, in order.
+func (m *MockLlmService) Images() [][]byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([][]byte(nil), m.images...)
+}
+
+// Documents returns the PDFs given to ExtractTextFromDocument so far, in order.
+func (m *MockLlmService) Documents() [][]byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([][]byte(nil), m.documents...)
+}
+
+// Audio returns the recordings given to TranscribeAudio so far, in order.
+func (m *MockLlmService) Audio() [][]byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([][]byte(nil), m.audio...)
+}
+
+// Calls returns the number of calls so far.
+func (m *MockLlmService) Calls() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.calls
+}
+
+// reply records a call with prompt and returns its scripted reply.
+func (m *MockLlmService) reply(ctx context.Context, prompt string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := m.calls
+	m.calls++
+	m.prompts = append(m.prompts, prompt)
+	if n < len(m.Responses) {
+		return m.Responses[n].Text, m.Responses[n].Err
+	}
+	if m.Err != nil {
+		return "", m.Err
+	}
+	return m.Response, nil
+}
+
+// GenerateText returns the next reply.
+func (m *MockLlmService) GenerateText(ctx context.Context, prompt string, opts ...GenerateOption) (string, error) {
+	return m.reply(ctx, prompt)
+}
+
+// GenerateTextWithUsage returns the next reply with m.Usage.
+func (m *MockLlmService) GenerateTextWithUsage(ctx context.Context, prompt string, opts ...GenerateOption) (string, Usage, error) {
+	text, err := m.reply(ctx, prompt)
+	if err != nil {
+		return "", Usage{}, err
+	}
+	return text, m.Usage, nil
+}
+
+// Chat returns the next reply, recording the last message as its prompt.
+func (m *MockLlmService) Chat(ctx context.Context, messages []Message, opts ...GenerateOption) (string, error) {
+	if err := checkMessages(messages); err != nil {
+		return "", err
+	}
+	return m.reply(ctx, messages[len(messages)-1].Content)
+}
+
+// Ping returns m.PingErr without counting a call.
+func (m *MockLlmService) Ping(ctx context.Context) error {
+	return m.PingErr
+}
+
+// GenerateTextStream delivers the next reply word by word.
+func (m *MockLlmService) GenerateTextStream(ctx context.Context, prompt string, opts ...GenerateOption) (<-chan string, <-chan error) {
+	chunks := make(chan string)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		defer close(chunks)
+		text, err := m.reply(ctx, prompt)
+		if err != nil {
+			errs <- err
+			return
+		}
+		for _, word := range strings.SplitAfter(text, " ") {
+			select {
+			case chunks <- word:
+			case <-ctx.Done():
+				errs <- ctx.Err()
+				return
+			}
+		}
+	}()
+	return chunks, errs
+}
+
+// ExtractTextFromImage returns the next reply, recording the image.
+func (m *MockLlmService) ExtractTextFromImage(ctx context.Context, prompt string, image []byte, mimeType string, opts ...GenerateOption) (string, error) {
+	return m.ExtractTextFromImages(ctx, prompt, []ImageInput{{Data: image, MimeType: mimeType}}, opts...)
+}
+
+// ExtractTextFromImages returns the next reply, recording the images.
+func (m *MockLlmService) ExtractTextFromImages(ctx context.Context, prompt string, images []ImageInput, opts ...GenerateOption) (string, error) {
+	m.mu.Lock()
+	for _, image := range images {
+		m.images = append(m.images, image.Data)
+	}
+	m.mu.Unlock()
+	return m.reply(ctx, prompt)
+}
+
+// ExtractTextFromDocument returns the next reply, recording the document.
+func (m *MockLlmService) ExtractTextFromDocument(ctx context.Context, prompt string, pdf []byte, opts ...GenerateOption) (string, error) {
+	m.mu.Lock()
+	m.documents = append(m.documents, pdf)
+	m.mu.Unlock()
+	return m.reply(ctx, prompt)
+}
+
+// TranscribeAudio returns the next reply, recording the audio.
+func (m *MockLlmService) TranscribeAudio(ctx context.Context, audio []byte, mimeType string) (string, error) {
+	m.mu.Lock()
+	m.audio = append(m.audio, audio)
+	m.mu.Unlock()
+	return m.reply(ctx, "")
+}
@@ -0,0 +1,52 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMockLlmService_Script(t *testing.T) {
+	unavailable := errors.New("unavailable")
+	service, err := NewLlmService(ProviderTestMock)
+	if err != nil {
+		t.Fatalf("NewLlmService failed: %v", err)
+	}
+	mock := service.(*MockLlmService)
+	mock.Responses = []MockResponse{{Text: "first"}, {Err: unavailable}}
+	mock.Usage = Usage{TotalTokens: 3}
+	ctx := context.Background()
+
+	if text, usage, err := mock.GenerateTextWithUsage(ctx, "one"); err != nil || text != "first" || usage.TotalTokens != 3 {
+		t.Errorf("Expected the first scripted reply, got %q, %v, %v", text, usage, err)
+	}
+	if _, err := mock.Chat(ctx, []Message{{Role: RoleSystem, Content: "be brief"}, {Role: RoleUser, Content: "two"}}); !errors.Is(err, unavailable) {
+		t.Errorf("Expected the scripted error, got %v", err)
+	}
+	if text, err := mock.ExtractTextFromImage(ctx, "three", []byte("png"), "image/png"); err != nil || text != DefaultMockResponse {
+		t.Errorf("Expected the default reply, got %q, %v", text, err)
+	}
+
+	mock.Response = "streamed reply text"
+	chunks, errs := mock.GenerateTextStream(ctx, "four")
+	var pieces []string
+	for chunk := range chunks {
+		pieces = append(pieces, chunk)
+	}
+	if err := <-errs; err != nil || len(pieces) != 3 || strings.Join(pieces, "") != "streamed reply text" {
+		t.Errorf("Expected the reply in three pieces, got %q, %v", pieces, err)
+	}
+
+	if prompts := mock.Prompts(); strings.Join(prompts, ",") != "one,two,three,four" || mock.Calls() != 4 {
+		t.Errorf("Expected four recorded prompts, got %q", prompts)
+	}
+	if images := mock.Images(); len(images) != 1 || string(images[0]) != "png" {
+		t.Errorf("Expected the recorded image, got %q", images)
+	}
+
+	mock.Err = unavailable
+	if _, err := mock.GenerateText(ctx, "five"); !errors.Is(err, unavailable) {
+		t.Errorf("Expected every call to fail, got %v", err)
+	}
+}
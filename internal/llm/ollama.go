@@ -0,0 +1,415 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/budget"
+	"github.com/sandwichlabs/agent-memory-graph/internal/httpclient"
+	"github.com/sandwichlabs/agent-memory-graph/internal/metrics"
+	"github.com/sandwichlabs/agent-memory-graph/internal/redact"
+)
+
+// Defaults for OllamaLlmService, overridden by OLLAMA_HOST, OLLAMA_MODEL and
+// OLLAMA_VISION_MODEL.
+const (
+	DefaultOllamaHost        = "http://localhost:11434"
+	DefaultOllamaModel       = "llama3.2"
+	DefaultOllamaVisionModel = "llava"
+)
+
+// ImagesUnsupportedError is returned by ExtractTextFromImage when the model cannot
+// read images, before anything is sent to it.
+type ImagesUnsupportedError struct {
+	Provider Provider
+	Model    string
+}
+
+func (e *ImagesUnsupportedError) Error() string {
+	return fmt.Sprintf("%s model %s does not support images; configure a multimodal model such as llava", e.Provider, e.Model)
+}
+
+// OllamaLlmService implements the LlmService interface using the chat API of a
+// local Ollama server, so ingestion works without network access or API keys.
+type OllamaLlmService struct {
+	HTTPClient *http.Client // Exported for testing
+	// ChatModel generates text and MultimodalModel reads images. They default to
+	// OLLAMA_MODEL and OLLAMA_VISION_MODEL and may be overridden after construction.
+	ChatModel       string
+	MultimodalModel string
+	APIBaseURL      string // OLLAMA_HOST; exported for testing
+	// Metrics receives a measurement of every request; metrics.Default unless
+	// replaced.
+	Metrics metrics.Sink
+	// Breaker fails requests fast while the provider is down; nil disables it.
+	Breaker *CircuitBreaker
+
+	mu     sync.Mutex
+	vision map[string]bool // whether each model supports images
+}
+
+// NewOllamaLlmService creates a new instance of OllamaLlmService for the server at
+// OLLAMA_HOST, or DefaultOllamaHost. It does not contact the server.
+func NewOllamaLlmService() (*OllamaLlmService, error) {
+	host := strings.TrimRight(envOr("OLLAMA_HOST", DefaultOllamaHost), "/")
+	if !strings.Contains(host, "://") {
+		// Ollama itself accepts a bare host:port.
+		host = "http://" + host
+	}
+	client, err := httpclient.FromEnv()
+	if err != nil {
+		return nil, err
+	}
+	breaker, err := circuitBreakerFromEnv("ollama")
+	if err != nil {
+		return nil, err
+	}
+	return &OllamaLlmService{
+		HTTPClient:      client,
+		ChatModel:       envOr("OLLAMA_MODEL", DefaultOllamaModel),
+		MultimodalModel: envOr("OLLAMA_VISION_MODEL", DefaultOllamaVisionModel),
+		APIBaseURL:      host,
+		Metrics:         metrics.Default,
+		Breaker:         breaker,
+		vision:          make(map[string]bool),
+	}, nil
+}
+
+// GenerateText generates text using the Ollama chat API.
+func (s *OllamaLlmService) GenerateText(ctx context.Context, prompt string, opts ...GenerateOption) (string, error) {
+	content, _, err := s.GenerateTextWithUsage(ctx, prompt, opts...)
+	return content, err
+}
+
+// Ping lists the models the Ollama server has pulled.
+func (s *OllamaLlmService) Ping(ctx context.Context) error {
+	return ping(ctx, s.HTTPClient, "ollama", s.APIBaseURL+"/api/tags", nil)
+}
+
+// GenerateTextWithUsage generates text like GenerateText, also returning the
+// token usage the API reports.
+func (s *OllamaLlmService) GenerateTextWithUsage(ctx context.Context, prompt string, opts ...GenerateOption) (string, Usage, error) {
+	return s.converse(ctx, []Message{{Role: RoleUser, Content: prompt}}, opts)
+}
+
+// Chat generates the assistant's reply to a conversation, sending the messages in order
+// with their roles.
+func (s *OllamaLlmService) Chat(ctx context.Context, messages []Message, opts ...GenerateOption) (string, error) {
+	content, _, err := s.converse(ctx, messages, opts)
+	return content, err
+}
+
+// converse generates the reply to messages, returning the usage the API reports.
+func (s *OllamaLlmService) converse(ctx context.Context, messages []Message, opts []GenerateOption) (string, Usage, error) {
+	slog.InfoContext(ctx, "OllamaLlmService: Chat called", "model", s.ChatModel, "messages", len(messages))
+
+	if err := checkMessages(messages); err != nil {
+		return "", Usage{}, err
+	}
+	requestPayload := generation(defaultTemperature, defaultMaxTokens, opts).ollamaChat(map[string]interface{}{
+		"model":    s.ChatModel,
+		"messages": chatMessages(messages),
+		"stream":   false,
+	})
+
+	content, usage, err := s.chat(ctx, requestPayload, "")
+	if err != nil {
+		return "", Usage{}, err
+	}
+	slog.InfoContext(ctx, "OllamaLlmService: Text generated successfully", "response_length", len(content), "total_tokens", usage.TotalTokens)
+	return content, usage, nil
+}
+
+// generateJSON generates text in Ollama's JSON format mode.
+func (s *OllamaLlmService) generateJSON(ctx context.Context, prompt string, opts []GenerateOption) (string, Usage, error) {
+	slog.InfoContext(ctx, "OllamaLlmService: generateJSON called", "model", s.ChatModel, "prompt_length", len(prompt))
+
+	return s.chat(ctx, generation(jsonTemperature, jsonMaxTokens, opts).ollamaChat(map[string]interface{}{
+		"model": s.ChatModel,
+		"messages": []map[string]interface{}{
+			{"role": "user", "content": prompt},
+		},
+		"stream": false,
+		"format": "json",
+	}), "json")
+}
+
+// GenerateTextStream generates text like GenerateText, reading the
+// newline-delimited JSON messages of a streamed chat.
+func (s *OllamaLlmService) GenerateTextStream(ctx context.Context, prompt string, opts ...GenerateOption) (<-chan string, <-chan error) {
+	slog.InfoContext(ctx, "OllamaLlmService: GenerateTextStream called", "model", s.ChatModel, "prompt_length", len(prompt))
+
+	return streamText(ctx, streamCall{provider: "ollama", metrics: s.Metrics, breaker: s.Breaker}, func(emit func(string) bool) (Usage, error) {
+		req, err := streamRequest(ctx, s.APIBaseURL+"/api/chat", generation(defaultTemperature, defaultMaxTokens, opts).ollamaChat(map[string]interface{}{
+			"model": s.ChatModel,
+			"messages": []map[string]interface{}{
+				{"role": "user", "content": prompt},
+			},
+			"stream": true,
+		}))
+		if err != nil {
+			return Usage{}, err
+		}
+		req.Header.Set("Accept", "application/x-ndjson")
+
+		body, err := openStream(s.HTTPClient, req, "ollama")
+		if err != nil {
+			return Usage{}, err
+		}
+		defer body.Close()
+		scanner := bufio.NewScanner(body)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxEventSize)
+		for scanner.Scan() {
+			var message struct {
+				Message struct {
+					Content string `json:"content"`
+				} `json:"message"`
+				Done  bool   `json:"done"`
+				Error string `json:"error"`
+				// The last message counts the tokens of the prompt and the reply.
+				PromptEvalCount int `json:"prompt_eval_count"`
+				EvalCount       int `json:"eval_count"`
+			}
+			if err := json.Unmarshal(scanner.Bytes(), &message); err != nil {
+				return Usage{}, fmt.Errorf("failed to decode ollama stream message %q: %w", scanner.Text(), err)
+			}
+			if message.Error != "" {
+				return Usage{}, classified(fmt.Errorf("ollama API error: %s", message.Error), classifyStatus(http.StatusBadRequest, message.Error))
+			}
+			if message.Message.Content != "" && !emit(message.Message.Content) {
+				return Usage{}, nil
+			}
+			if message.Done {
+				return Usage{
+					PromptTokens:     message.PromptEvalCount,
+					CompletionTokens: message.EvalCount,
+					TotalTokens:      message.PromptEvalCount + message.EvalCount,
+				}, nil
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return Usage{}, fmt.Errorf("failed to read stream: %w", err)
+		}
+		return Usage{}, nil
+	})
+}
+
+// ExtractTextFromImage extracts text from an image with a multimodal Ollama model;
+// see ExtractTextFromImages.
+func (s *OllamaLlmService) ExtractTextFromImage(ctx context.Context, prompt string, image []byte, mimeType string, opts ...GenerateOption) (string, error) {
+	return s.ExtractTextFromImages(ctx, prompt, []ImageInput{{Data: image, MimeType: mimeType}}, opts...)
+}
+
+// ExtractTextFromImages extracts text from images with a multimodal Ollama model,
+// passing them base64-encoded in the message's images. It returns an
+// *ImagesUnsupportedError when the model cannot read images.
+func (s *OllamaLlmService) ExtractTextFromImages(ctx context.Context, prompt string, images []ImageInput, opts ...GenerateOption) (string, error) {
+	slog.InfoContext(ctx, "OllamaLlmService: ExtractTextFromImages called",
+		"model", s.MultimodalModel,
+		"prompt_length", len(prompt),
+		"images", len(images),
+		"image_size", imageBytes(images))
+
+	if err := checkImages(images); err != nil {
+		slog.ErrorContext(ctx, "OllamaLlmService: Invalid images", "error", err)
+		return "", err
+	}
+	config := generation(imageTemperature, imageMaxTokens, opts)
+	model := config.modelOr(s.MultimodalModel)
+	if err := checkModel(model); err != nil {
+		return "", err
+	}
+	vision, err := s.supportsImages(ctx, model)
+	if err != nil {
+		return "", err
+	}
+	if !vision {
+		return "", &ImagesUnsupportedError{Provider: ProviderOllama, Model: model}
+	}
+
+	// Ollama detects the image format itself, so the MIME types are not sent.
+	encoded := make([]string, len(images))
+	for n, image := range images {
+		encoded[n] = base64.StdEncoding.EncodeToString(image.Data)
+	}
+	requestPayload := config.ollamaChat(map[string]interface{}{
+		"model": model,
+		"messages": []map[string]interface{}{
+			{
+				"role":    "user",
+				"content": prompt,
+				"images":  encoded,
+			},
+		},
+		"stream": false,
+	})
+
+	text, _, err := s.chat(ctx, requestPayload, "multimodal")
+	if err != nil {
+		return "", err
+	}
+	slog.InfoContext(ctx, "OllamaLlmService: Text extracted from images successfully", "response_length", len(text))
+	return text, nil
+}
+
+// ExtractTextFromDocument returns an error wrapping ErrDocumentsUnsupported:
+// Ollama models read images but not PDFs.
+func (s *OllamaLlmService) ExtractTextFromDocument(ctx context.Context, prompt string, pdf []byte, opts ...GenerateOption) (string, error) {
+	return "", documentsUnsupported(ProviderOllama)
+}
+
+// TranscribeAudio returns an error wrapping ErrUnsupported: Ollama models do not take audio.
+func (s *OllamaLlmService) TranscribeAudio(ctx context.Context, audio []byte, mimeType string) (string, error) {
+	return "", audioUnsupported(ProviderOllama)
+}
+
+// supportsImages asks the server whether model lists the vision capability, or for
+// older servers whether it has a CLIP projector, and remembers the answer.
+func (s *OllamaLlmService) supportsImages(ctx context.Context, model string) (bool, error) {
+	s.mu.Lock()
+	vision, ok := s.vision[model]
+	s.mu.Unlock()
+	if ok {
+		return vision, nil
+	}
+
+	var show struct {
+		Capabilities []string `json:"capabilities"`
+		Details      struct {
+			Families []string `json:"families"`
+		} `json:"details"`
+	}
+	if err := s.post(ctx, "/api/show", map[string]interface{}{"model": model}, "show", &show); err != nil {
+		return false, err
+	}
+	vision = slices.Contains(show.Capabilities, "vision") || slices.Contains(show.Details.Families, "clip") || slices.Contains(show.Details.Families, "mllama")
+
+	s.mu.Lock()
+	s.vision[model] = vision
+	s.mu.Unlock()
+	return vision, nil
+}
+
+// chat posts requestPayload to the chat endpoint and returns the reply's content.
+// kind, such as "multimodal", qualifies the errors.
+func (s *OllamaLlmService) chat(ctx context.Context, requestPayload map[string]interface{}, kind string) (_ string, usage Usage, err error) {
+	model, _ := requestPayload["model"].(string)
+	if err := checkModel(model); err != nil {
+		return "", Usage{}, err
+	}
+	if err := budget.FromContext(ctx).Check(); err != nil {
+		return "", Usage{}, err
+	}
+	defer func(start time.Time) { recordCall(ctx, s.Metrics, "ollama", kind, start, usage, err) }(time.Now())
+	if err := s.Breaker.Allow(); err != nil {
+		return "", Usage{}, err
+	}
+	defer func() { s.Breaker.Record(ctx, err) }()
+	var ollamaResponse struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+		// Ollama counts the tokens it evaluated for the prompt and the reply.
+		PromptEvalCount int `json:"prompt_eval_count"`
+		EvalCount       int `json:"eval_count"`
+	}
+	if err := s.post(ctx, "/api/chat", requestPayload, kind, &ollamaResponse); err != nil {
+		return "", Usage{}, err
+	}
+	if ollamaResponse.Message.Content == "" {
+		slog.WarnContext(ctx, "OllamaLlmService: No content found in Ollama API response")
+		slog.DebugContext(ctx, "OllamaLlmService: Response without content", redact.Content("response", fmt.Sprintf("%+v", ollamaResponse)))
+		if kind != "" {
+			return "", Usage{}, fmt.Errorf("no content found in ollama %s response", kind)
+		}
+		return "", Usage{}, fmt.Errorf("no content found in ollama response")
+	}
+	return ollamaResponse.Message.Content, Usage{
+		PromptTokens:     ollamaResponse.PromptEvalCount,
+		CompletionTokens: ollamaResponse.EvalCount,
+		TotalTokens:      ollamaResponse.PromptEvalCount + ollamaResponse.EvalCount,
+	}, nil
+}
+
+// post sends requestPayload to path and decodes the JSON response into out.
+func (s *OllamaLlmService) post(ctx context.Context, path string, requestPayload map[string]interface{}, kind string, out interface{}) error {
+	qualifier := ""
+	if kind != "" {
+		qualifier = kind + " "
+	}
+
+	requestBody, err := json.Marshal(requestPayload)
+	if err != nil {
+		slog.ErrorContext(ctx, "OllamaLlmService: Failed to marshal request body", "error", err, "kind", kind)
+		return fmt.Errorf("failed to marshal %srequest body: %w", qualifier, err)
+	}
+
+	url := s.APIBaseURL + path
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(requestBody))
+	if err != nil {
+		slog.ErrorContext(ctx, "OllamaLlmService: Failed to create HTTP request", "error", err, "url", url)
+		return fmt.Errorf("failed to create %srequest to %s: %w", qualifier, url, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		slog.ErrorContext(ctx, "OllamaLlmService: Failed to send request to Ollama", "error", err, "url", url)
+		return fmt.Errorf("failed to send %srequest to Ollama at %s (is ollama serve running?): %w", qualifier, s.APIBaseURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		slog.ErrorContext(ctx, "OllamaLlmService: Ollama API error", "status_code", resp.StatusCode, redact.Body("response_body", string(bodyBytes)))
+		return newAPIError("ollama", kind, resp, bodyBytes)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		slog.ErrorContext(ctx, "OllamaLlmService: Failed to decode Ollama API response", "error", err)
+		return fmt.Errorf("failed to decode ollama %sresponse: %w", qualifier, err)
+	}
+	return nil
+}
+
+// envOr returns the environment variable key, or fallback when it is not set.
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// envDuration returns the duration in the environment variable key, written as a
+// Go duration such as "90s" or as a number of seconds, or fallback when unset.
+func envDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		seconds, serr := strconv.ParseFloat(value, 64)
+		if serr != nil {
+			return 0, fmt.Errorf("invalid %s %q: must be a duration such as 90s or a number of seconds", key, value)
+		}
+		duration = time.Duration(seconds * float64(time.Second))
+	}
+	if duration < 0 {
+		return 0, fmt.Errorf("invalid %s %q: must not be negative", key, value)
+	}
+	return duration, nil
+}
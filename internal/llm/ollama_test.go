@@ -0,0 +1,182 @@
+package llm
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// mockOllamaServer serves /api/chat with chat and /api/show with the given
+// capabilities for every model, counting show requests.
+func mockOllamaServer(t *testing.T, capabilities []string, chat http.HandlerFunc) (*OllamaLlmService, *int) {
+	t.Helper()
+	shows := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/chat":
+			chat(w, r)
+		case "/api/show":
+			shows++
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{"capabilities": capabilities})
+		default:
+			http.Error(w, "Not found: Unexpected path "+r.URL.Path, http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	service, err := NewOllamaLlmService()
+	if err != nil {
+		t.Fatalf("NewOllamaLlmService failed: %v", err)
+	}
+	service.HTTPClient = server.Client()
+	service.APIBaseURL = server.URL
+	return service, &shows
+}
+
+func TestNewOllamaLlmService_Environment(t *testing.T) {
+	t.Setenv("OLLAMA_HOST", "")
+	t.Setenv("OLLAMA_MODEL", "")
+	t.Setenv("OLLAMA_VISION_MODEL", "")
+	service, err := NewLlmService(ProviderOllama)
+	if err != nil {
+		t.Fatalf("NewLlmService failed: %v", err)
+	}
+	ollama := service.(*OllamaLlmService)
+	if ollama.APIBaseURL != DefaultOllamaHost || ollama.ChatModel != DefaultOllamaModel || ollama.MultimodalModel != DefaultOllamaVisionModel {
+		t.Errorf("Expected the defaults, got %s %s %s", ollama.APIBaseURL, ollama.ChatModel, ollama.MultimodalModel)
+	}
+
+	t.Setenv("OLLAMA_HOST", "gpu-box:11434/")
+	t.Setenv("OLLAMA_MODEL", "qwen2.5")
+	t.Setenv("OLLAMA_VISION_MODEL", "llama3.2-vision")
+	ollama, _ = NewOllamaLlmService()
+	if ollama.APIBaseURL != "http://gpu-box:11434" || ollama.ChatModel != "qwen2.5" || ollama.MultimodalModel != "llama3.2-vision" {
+		t.Errorf("Expected the environment's host and models, got %s %s %s", ollama.APIBaseURL, ollama.ChatModel, ollama.MultimodalModel)
+	}
+}
+
+func TestOllamaLlmService_GenerateText_Success(t *testing.T) {
+	var payload struct {
+		Model    string `json:"model"`
+		Stream   bool   `json:"stream"`
+		Messages []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	service, _ := mockOllamaServer(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, "Bad request body, not JSON", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": map[string]string{"role": "assistant", "content": "This is a test response."},
+			"done":    true,
+		})
+	})
+	service.ChatModel = "qwen2.5"
+
+	actualText, err := service.GenerateText(context.Background(), "test prompt")
+	if err != nil {
+		t.Fatalf("GenerateText failed: %v", err)
+	}
+	if actualText != "This is a test response." {
+		t.Errorf("Expected text 'This is a test response.', got '%s'", actualText)
+	}
+	if payload.Model != "qwen2.5" || payload.Stream || len(payload.Messages) != 1 || payload.Messages[0].Content != "test prompt" {
+		t.Errorf("Expected a single non-streaming user message for the chat model, got %+v", payload)
+	}
+}
+
+func TestOllamaLlmService_GenerateText_Errors(t *testing.T) {
+	service, _ := mockOllamaServer(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"model \"llama3.2\" not found, try pulling it first"}`, http.StatusNotFound)
+	})
+	_, err := service.GenerateText(context.Background(), "test prompt")
+	if err == nil || !strings.Contains(err.Error(), "ollama API error: 404") || !strings.Contains(err.Error(), "try pulling it first") {
+		t.Errorf("Expected error to contain the status and body, got: %v", err)
+	}
+
+	service, _ = mockOllamaServer(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"message": {"role": "assistant", "content": ""}, "done": true}`))
+	})
+	if _, err := service.GenerateText(context.Background(), "test prompt"); err == nil || !strings.Contains(err.Error(), "no content found in ollama response") {
+		t.Errorf("Expected error to contain 'no content found in ollama response', got: %v", err)
+	}
+
+	service.APIBaseURL = "http://127.0.0.1:1"
+	if _, err := service.GenerateText(context.Background(), "test prompt"); err == nil || !strings.Contains(err.Error(), "is ollama serve running?") {
+		t.Errorf("Expected an unreachable server to be reported, got: %v", err)
+	}
+}
+
+func TestOllamaLlmService_ExtractTextFromImage_Success(t *testing.T) {
+	imageData := []byte("dummyimagedata")
+	service, shows := mockOllamaServer(t, []string{"completion", "vision"}, func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Model    string `json:"model"`
+			Messages []struct {
+				Content string   `json:"content"`
+				Images  []string `json:"images"`
+			} `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, "Bad request body, not JSON", http.StatusBadRequest)
+			return
+		}
+		if payload.Model != "llava" || len(payload.Messages) != 1 || payload.Messages[0].Content != "Extract wine info" {
+			http.Error(w, "Expected one message with the prompt for the vision model", http.StatusBadRequest)
+			return
+		}
+		if images := payload.Messages[0].Images; len(images) != 1 || images[0] != base64.StdEncoding.EncodeToString(imageData) {
+			http.Error(w, "Expected the base64 image in images", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"message": {"role": "assistant", "content": "Wine Name: Test Wine"}, "done": true}`))
+	})
+	service.MultimodalModel = "llava"
+
+	for range 2 {
+		actualText, err := service.ExtractTextFromImage(context.Background(), "Extract wine info", imageData, "image/png")
+		if err != nil {
+			t.Fatalf("ExtractTextFromImage failed: %v", err)
+		}
+		if actualText != "Wine Name: Test Wine" {
+			t.Errorf("Expected text 'Wine Name: Test Wine', got '%s'", actualText)
+		}
+	}
+	if *shows != 1 {
+		t.Errorf("Expected the model's capabilities to be looked up once, got %d lookups", *shows)
+	}
+}
+
+func TestOllamaLlmService_ExtractTextFromImage_TextOnlyModel(t *testing.T) {
+	chats := 0
+	service, _ := mockOllamaServer(t, []string{"completion"}, func(w http.ResponseWriter, r *http.Request) {
+		chats++
+		http.Error(w, `{"error":"model does not support images"}`, http.StatusBadRequest)
+	})
+	service.MultimodalModel = "llama3.2"
+
+	_, err := service.ExtractTextFromImage(context.Background(), "prompt", []byte("dummyData"), "image/jpeg")
+	var unsupported *ImagesUnsupportedError
+	if !errors.As(err, &unsupported) || unsupported.Model != "llama3.2" {
+		t.Fatalf("Expected an ImagesUnsupportedError for llama3.2, got %v", err)
+	}
+	if chats != 0 {
+		t.Errorf("Expected no chat request, got %d", chats)
+	}
+
+	if _, err := service.ExtractTextFromImage(context.Background(), "prompt", nil, "image/jpeg"); err == nil || !strings.Contains(err.Error(), "image data is empty") {
+		t.Errorf("Expected error to contain 'image data is empty', got: %v", err)
+	}
+}
@@ -0,0 +1,441 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"time"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/budget"
+	"github.com/sandwichlabs/agent-memory-graph/internal/httpclient"
+	"github.com/sandwichlabs/agent-memory-graph/internal/metrics"
+	"github.com/sandwichlabs/agent-memory-graph/internal/redact"
+)
+
+// OpenAILlmService implements the LlmService interface using the OpenAI chat
+// completions API.
+type OpenAILlmService struct {
+	apiKey          string
+	HTTPClient      *http.Client // Exported for testing
+	chatModel       string
+	multimodalModel string
+	// transcriptionModel transcribes audio; TranscribeAudio is unsupported when
+	// it is empty.
+	transcriptionModel string
+	APIBaseURL         string // Exported for testing and OpenAI-compatible endpoints
+	// MaxAudioBytes caps the recordings sent for transcription; zero disables
+	// the cap.
+	MaxAudioBytes int
+	// Metrics receives a measurement of every request; metrics.Default unless
+	// replaced.
+	Metrics metrics.Sink
+	// Breaker fails requests fast while the provider is down; nil disables it.
+	Breaker *CircuitBreaker
+
+	// provider names the API in errors; "openai" when empty.
+	provider string
+	// endpoint, when set, returns the chat completions URL for model instead of
+	// the one under APIBaseURL, and transcriptionEndpoint the transcriptions URL.
+	endpoint              func(model string) string
+	transcriptionEndpoint func(model string) string
+	// authorize, when set, authenticates requests instead of the bearer token.
+	authorize func(req *http.Request)
+}
+
+// NewOpenAILlmService creates a new instance of OpenAILlmService.
+// It requires the API key to be set in the OPENAI_API_KEY environment variable.
+func NewOpenAILlmService() (*OpenAILlmService, error) {
+	apiKey := os.Getenv("OPENAI_API_KEY")
+	if apiKey == "" {
+		return nil, fmt.Errorf("OPENAI_API_KEY environment variable not set")
+	}
+
+	client, err := httpclient.FromEnv()
+	if err != nil {
+		return nil, err
+	}
+	breaker, err := circuitBreakerFromEnv("openai")
+	if err != nil {
+		return nil, err
+	}
+	return &OpenAILlmService{
+		apiKey:             apiKey,
+		HTTPClient:         client,
+		chatModel:          "gpt-4o-mini",
+		multimodalModel:    "gpt-4o",
+		transcriptionModel: "whisper-1",
+		APIBaseURL:         "https://api.openai.com/v1",
+		MaxAudioBytes:      DefaultMaxAudioBytes,
+		Metrics:            metrics.Default,
+		Breaker:            breaker,
+	}, nil
+}
+
+// GenerateText generates text using the OpenAI chat completions API.
+func (s *OpenAILlmService) GenerateText(ctx context.Context, prompt string, opts ...GenerateOption) (string, error) {
+	content, _, err := s.GenerateTextWithUsage(ctx, prompt, opts...)
+	return content, err
+}
+
+// Ping lists the models of the OpenAI API with the service's key.
+func (s *OpenAILlmService) Ping(ctx context.Context) error {
+	return ping(ctx, s.HTTPClient, s.providerName(), s.APIBaseURL+"/models", s.authenticate)
+}
+
+// GenerateTextWithUsage generates text like GenerateText, also returning the
+// token usage the API reports.
+func (s *OpenAILlmService) GenerateTextWithUsage(ctx context.Context, prompt string, opts ...GenerateOption) (string, Usage, error) {
+	return s.converse(ctx, []Message{{Role: RoleUser, Content: prompt}}, opts)
+}
+
+// Chat generates the assistant's reply to a conversation, sending the messages
+// in order with their roles.
+func (s *OpenAILlmService) Chat(ctx context.Context, messages []Message, opts ...GenerateOption) (string, error) {
+	content, _, err := s.converse(ctx, messages, opts)
+	return content, err
+}
+
+// converse generates the reply to messages, returning the usage the API reports.
+func (s *OpenAILlmService) converse(ctx context.Context, messages []Message, opts []GenerateOption) (string, Usage, error) {
+	slog.InfoContext(ctx, "OpenAILlmService: Chat called", "model", s.chatModel, "messages", len(messages))
+
+	if err := checkMessages(messages); err != nil {
+		return "", Usage{}, err
+	}
+	requestPayload := generation(defaultTemperature, defaultMaxTokens, opts).chatCompletion("seed", map[string]interface{}{
+		"model":    s.chatModel,
+		"messages": chatMessages(messages),
+	})
+
+	content, usage, err := s.complete(ctx, requestPayload, "")
+	if err != nil {
+		// A truncated reply keeps its text and usage.
+		return content, usage, err
+	}
+	slog.InfoContext(ctx, "OpenAILlmService: Text generated successfully", "response_length", len(content), "total_tokens", usage.TotalTokens)
+	return content, usage, nil
+}
+
+// generateJSON generates text with the json_object response format, which makes
+// the model reply with valid JSON.
+func (s *OpenAILlmService) generateJSON(ctx context.Context, prompt string, opts []GenerateOption) (string, Usage, error) {
+	slog.InfoContext(ctx, "OpenAILlmService: generateJSON called", "model", s.chatModel, "prompt_length", len(prompt))
+
+	return s.complete(ctx, generation(jsonTemperature, jsonMaxTokens, opts).chatCompletion("seed", map[string]interface{}{
+		"model": s.chatModel,
+		"messages": []map[string]string{
+			{"role": "user", "content": prompt},
+		},
+		"response_format": map[string]string{"type": "json_object"},
+	}), "json")
+}
+
+// generateWithSchema generates text with the json_schema response format, which
+// constrains the reply to schema.
+func (s *OpenAILlmService) generateWithSchema(ctx context.Context, prompt, name string, schema json.RawMessage, opts []GenerateOption) (string, Usage, error) {
+	slog.InfoContext(ctx, "OpenAILlmService: generateWithSchema called", "model", s.chatModel, "schema", name, "prompt_length", len(prompt))
+
+	return s.complete(ctx, generation(jsonTemperature, jsonMaxTokens, opts).chatCompletion("seed", map[string]interface{}{
+		"model": s.chatModel,
+		"messages": []map[string]string{
+			{"role": "user", "content": prompt},
+		},
+		"response_format": schemaResponseFormat(name, schema),
+	}), "json")
+}
+
+// GenerateTextStream generates text like GenerateText, reading the server-sent
+// events of a streamed chat completion.
+func (s *OpenAILlmService) GenerateTextStream(ctx context.Context, prompt string, opts ...GenerateOption) (<-chan string, <-chan error) {
+	slog.InfoContext(ctx, "OpenAILlmService: GenerateTextStream called", "model", s.chatModel, "prompt_length", len(prompt))
+
+	config := generation(defaultTemperature, defaultMaxTokens, opts)
+	return streamText(ctx, streamCall{provider: s.providerName(), metrics: s.Metrics, breaker: s.Breaker}, func(emit func(string) bool) (Usage, error) {
+		req, err := streamRequest(ctx, s.chatCompletionsURL(config.modelOr(s.chatModel)), config.chatCompletion("seed", map[string]interface{}{
+			"model": s.chatModel,
+			"messages": []map[string]string{
+				{"role": "user", "content": prompt},
+			},
+			"stream": true,
+		}))
+		if err != nil {
+			return Usage{}, err
+		}
+		s.authenticate(req)
+
+		body, err := openStream(s.HTTPClient, req, s.providerName())
+		if err != nil {
+			return Usage{}, err
+		}
+		defer body.Close()
+		return chatCompletionDeltas(body, emit)
+	})
+}
+
+// ExtractTextFromImage extracts text from an image using an OpenAI vision model;
+// see ExtractTextFromImages.
+func (s *OpenAILlmService) ExtractTextFromImage(ctx context.Context, prompt string, image []byte, mimeType string, opts ...GenerateOption) (string, error) {
+	return s.ExtractTextFromImages(ctx, prompt, []ImageInput{{Data: image, MimeType: mimeType}}, opts...)
+}
+
+// ExtractTextFromImages extracts text from images using an OpenAI vision model by
+// sending them as base64 data URLs after the prompt in one message.
+func (s *OpenAILlmService) ExtractTextFromImages(ctx context.Context, prompt string, images []ImageInput, opts ...GenerateOption) (string, error) {
+	slog.InfoContext(ctx, "OpenAILlmService: ExtractTextFromImages called",
+		"model", s.multimodalModel,
+		"prompt_length", len(prompt),
+		"images", len(images),
+		"image_size", imageBytes(images))
+
+	if err := checkImages(images); err != nil {
+		slog.ErrorContext(ctx, "OpenAILlmService: Invalid images", "error", err)
+		return "", err
+	}
+	content := []map[string]interface{}{
+		{
+			"type": "text",
+			"text": prompt,
+		},
+	}
+	for n, image := range images {
+		mimeType := image.MimeType
+		if mimeType == "" {
+			slog.WarnContext(ctx, "OpenAILlmService: MimeType is empty, defaulting to image/jpeg. Accurate MimeType is preferred.", "image", n+1)
+			mimeType = "image/jpeg"
+		}
+		content = append(content, map[string]interface{}{
+			"type": "image_url",
+			"image_url": map[string]string{
+				"url": fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(image.Data)),
+			},
+		})
+	}
+
+	requestPayload := generation(imageTemperature, imageMaxTokens, opts).chatCompletion("seed", map[string]interface{}{
+		"model": s.multimodalModel,
+		"messages": []map[string]interface{}{
+			{
+				"role":    "user",
+				"content": content,
+			},
+		},
+	})
+
+	text, _, err := s.complete(ctx, requestPayload, "multimodal")
+	if err != nil {
+		return "", err
+	}
+	slog.InfoContext(ctx, "OpenAILlmService: Text extracted from images successfully", "response_length", len(text))
+	return text, nil
+}
+
+// ExtractTextFromDocument returns an error wrapping ErrDocumentsUnsupported:
+// PDFs are read by the mistral and anthropic providers.
+func (s *OpenAILlmService) ExtractTextFromDocument(ctx context.Context, prompt string, pdf []byte, opts ...GenerateOption) (string, error) {
+	return "", documentsUnsupported(Provider(s.providerName()))
+}
+
+// TranscribeAudio transcribes a recording with the transcriptions API, sending it
+// as a file of a multipart form. Recordings over s.MaxAudioBytes fail with
+// ErrAudioTooLarge without being sent.
+func (s *OpenAILlmService) TranscribeAudio(ctx context.Context, audio []byte, mimeType string) (string, error) {
+	slog.InfoContext(ctx, "OpenAILlmService: TranscribeAudio called",
+		"model", s.transcriptionModel,
+		"mime_type", mimeType,
+		"audio_size", len(audio))
+
+	if s.transcriptionModel == "" {
+		return "", audioUnsupported(Provider(s.providerName()))
+	}
+	if err := checkAudio(audio, mimeType, s.MaxAudioBytes); err != nil {
+		slog.ErrorContext(ctx, "OpenAILlmService: Invalid audio", "error", err)
+		return "", err
+	}
+	text, _, err := s.transcribe(ctx, audio, mimeType)
+	if err != nil {
+		return "", err
+	}
+	slog.InfoContext(ctx, "OpenAILlmService: Audio transcribed successfully", "transcript_length", len(text))
+	return text, nil
+}
+
+// complete posts requestPayload to the chat completions endpoint and returns the
+// content of the first choice. kind, such as "multimodal", qualifies the errors.
+// A reply cut off at the max tokens is returned with a *TruncatedError.
+func (s *OpenAILlmService) complete(ctx context.Context, requestPayload map[string]interface{}, kind string) (_ string, usage Usage, err error) {
+	model, _ := requestPayload["model"].(string)
+	if err := checkModel(model); err != nil {
+		return "", Usage{}, err
+	}
+	if err := budget.FromContext(ctx).Check(); err != nil {
+		return "", Usage{}, err
+	}
+	defer func(start time.Time) { recordCall(ctx, s.Metrics, s.providerName(), kind, start, usage, err) }(time.Now())
+	if err := s.Breaker.Allow(); err != nil {
+		return "", Usage{}, err
+	}
+	defer func() { s.Breaker.Record(ctx, err) }()
+	qualifier := ""
+	if kind != "" {
+		qualifier = kind + " "
+	}
+
+	requestBody, err := json.Marshal(requestPayload)
+	if err != nil {
+		slog.ErrorContext(ctx, "OpenAILlmService: Failed to marshal request body", "error", err, "kind", kind)
+		return "", Usage{}, fmt.Errorf("failed to marshal %srequest body: %w", qualifier, err)
+	}
+
+	url := s.chatCompletionsURL(model)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(requestBody))
+	if err != nil {
+		slog.ErrorContext(ctx, "OpenAILlmService: Failed to create HTTP request", "error", err, "url", url)
+		return "", Usage{}, fmt.Errorf("failed to create %srequest to %s: %w", qualifier, url, err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	s.authenticate(req)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		slog.ErrorContext(ctx, "OpenAILlmService: Failed to send request to OpenAI API", "error", err, "url", url)
+		return "", Usage{}, fmt.Errorf("failed to send %srequest to OpenAI API: %w", qualifier, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		slog.ErrorContext(ctx, "OpenAILlmService: OpenAI API error", "status_code", resp.StatusCode, redact.Body("response_body", string(bodyBytes)))
+		return "", Usage{}, newAPIError(s.providerName(), kind, resp, bodyBytes)
+	}
+
+	var openaiResponse struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+		Usage chatCompletionUsage `json:"usage"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&openaiResponse); err != nil {
+		slog.ErrorContext(ctx, "OpenAILlmService: Failed to decode OpenAI API response", "error", err)
+		return "", Usage{}, fmt.Errorf("failed to decode openai %sresponse: %w", qualifier, err)
+	}
+
+	if len(openaiResponse.Choices) == 0 || openaiResponse.Choices[0].Message.Content == "" {
+		slog.WarnContext(ctx, "OpenAILlmService: No content found in OpenAI API response")
+		slog.DebugContext(ctx, "OpenAILlmService: Response without content", redact.Content("response", fmt.Sprintf("%+v", openaiResponse)))
+		return "", Usage{}, fmt.Errorf("no content found in openai %sresponse", qualifier)
+	}
+	content := openaiResponse.Choices[0].Message.Content
+	usage = openaiResponse.Usage.usage()
+	if openaiResponse.Choices[0].FinishReason == finishLength {
+		slog.WarnContext(ctx, "OpenAILlmService: Reply truncated at the max tokens", "kind", kind, "completion_tokens", usage.CompletionTokens)
+		return content, usage, &TruncatedError{Provider: s.providerName(), Text: content, Usage: usage}
+	}
+	return content, usage, nil
+}
+
+// transcribe posts audio to the transcriptions endpoint as multipart/form-data
+// and returns the transcript.
+func (s *OpenAILlmService) transcribe(ctx context.Context, audio []byte, mimeType string) (_ string, usage Usage, err error) {
+	if err := budget.FromContext(ctx).Check(); err != nil {
+		return "", Usage{}, err
+	}
+	defer func(start time.Time) { recordCall(ctx, s.Metrics, s.providerName(), "audio", start, usage, err) }(time.Now())
+	if err := s.Breaker.Allow(); err != nil {
+		return "", Usage{}, err
+	}
+	defer func() { s.Breaker.Record(ctx, err) }()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("model", s.transcriptionModel)
+	form.WriteField("response_format", "json")
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename=%q`, audioFileName(mimeType)))
+	header.Set("Content-Type", mimeType)
+	part, err := form.CreatePart(header)
+	if err != nil {
+		return "", Usage{}, fmt.Errorf("failed to create audio request body: %w", err)
+	}
+	part.Write(audio)
+	if err := form.Close(); err != nil {
+		return "", Usage{}, fmt.Errorf("failed to create audio request body: %w", err)
+	}
+
+	url := s.transcriptionsURL(s.transcriptionModel)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, &body)
+	if err != nil {
+		slog.ErrorContext(ctx, "OpenAILlmService: Failed to create HTTP request", "error", err, "url", url)
+		return "", Usage{}, fmt.Errorf("failed to create audio request to %s: %w", url, err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	s.authenticate(req)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		slog.ErrorContext(ctx, "OpenAILlmService: Failed to send request to OpenAI API", "error", err, "url", url)
+		return "", Usage{}, fmt.Errorf("failed to send audio request to OpenAI API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		slog.ErrorContext(ctx, "OpenAILlmService: OpenAI API error", "status_code", resp.StatusCode, redact.Body("response_body", string(bodyBytes)))
+		return "", Usage{}, newAPIError(s.providerName(), "audio", resp, bodyBytes)
+	}
+
+	var transcription struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&transcription); err != nil {
+		slog.ErrorContext(ctx, "OpenAILlmService: Failed to decode OpenAI API response", "error", err)
+		return "", Usage{}, fmt.Errorf("failed to decode openai audio response: %w", err)
+	}
+	return transcription.Text, Usage{}, nil
+}
+
+// transcriptionsURL returns the URL of the transcriptions endpoint for model.
+func (s *OpenAILlmService) transcriptionsURL(model string) string {
+	if s.transcriptionEndpoint != nil {
+		return s.transcriptionEndpoint(model)
+	}
+	return s.APIBaseURL + "/audio/transcriptions"
+}
+
+// chatCompletionsURL returns the URL of the chat completions endpoint for model.
+func (s *OpenAILlmService) chatCompletionsURL(model string) string {
+	if s.endpoint != nil {
+		return s.endpoint(model)
+	}
+	return s.APIBaseURL + "/chat/completions"
+}
+
+// authenticate sets the credentials of req.
+func (s *OpenAILlmService) authenticate(req *http.Request) {
+	if s.authorize != nil {
+		s.authorize(req)
+		return
+	}
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+}
+
+func (s *OpenAILlmService) providerName() string {
+	if s.provider == "" {
+		return "openai"
+	}
+	return s.provider
+}
@@ -0,0 +1,200 @@
+package llm
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// newOpenAITestService points an OpenAILlmService at a test server serving the chat
+// completions endpoint with handler.
+func newOpenAITestService(t *testing.T, handler http.HandlerFunc) *OpenAILlmService {
+	t.Helper()
+	server := mockMistralServer(handler)
+	t.Cleanup(server.Close)
+
+	t.Setenv("OPENAI_API_KEY", "test_api_key")
+	service, err := NewOpenAILlmService()
+	if err != nil {
+		t.Fatalf("NewOpenAILlmService failed: %v", err)
+	}
+	service.HTTPClient = server.Client()
+	service.APIBaseURL = server.URL
+	return service
+}
+
+// writeChoice replies with a chat completion holding content.
+func writeChoice(w http.ResponseWriter, content string) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"choices": []map[string]interface{}{
+			{"message": map[string]interface{}{"role": "assistant", "content": content}},
+		},
+	})
+}
+
+func TestNewOpenAILlmService_MissingKey(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "")
+	if _, err := NewOpenAILlmService(); err == nil || !strings.Contains(err.Error(), "OPENAI_API_KEY") {
+		t.Errorf("Expected an error naming OPENAI_API_KEY, got %v", err)
+	}
+}
+
+func TestNewLlmService_OpenAI(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "test_api_key")
+	service, err := NewLlmService(ProviderOpenAI)
+	if err != nil {
+		t.Fatalf("NewLlmService failed: %v", err)
+	}
+	if _, ok := service.(*OpenAILlmService); !ok {
+		t.Errorf("Expected an *OpenAILlmService, got %T", service)
+	}
+}
+
+func TestOpenAILlmService_GenerateText_Success(t *testing.T) {
+	expectedResponseText := "This is a test response."
+	var payload struct {
+		Model    string `json:"model"`
+		Messages []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	service := newOpenAITestService(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.Header.Get("Authorization") != "Bearer test_api_key" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, "Bad request body, not JSON", http.StatusBadRequest)
+			return
+		}
+		writeChoice(w, expectedResponseText)
+	})
+
+	actualText, err := service.GenerateText(context.Background(), "test prompt")
+	if err != nil {
+		t.Fatalf("GenerateText failed: %v", err)
+	}
+	if actualText != expectedResponseText {
+		t.Errorf("Expected text '%s', got '%s'", expectedResponseText, actualText)
+	}
+	if payload.Model != "gpt-4o-mini" || len(payload.Messages) != 1 || payload.Messages[0].Role != "user" || payload.Messages[0].Content != "test prompt" {
+		t.Errorf("Expected a single user message for the chat model, got %+v", payload)
+	}
+}
+
+func TestOpenAILlmService_GenerateText_APIError(t *testing.T) {
+	service := newOpenAITestService(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	})
+
+	_, err := service.GenerateText(context.Background(), "test prompt")
+	if err == nil {
+		t.Fatalf("Expected an error, but got nil")
+	}
+	if !strings.Contains(err.Error(), "openai API error: ") || !strings.Contains(err.Error(), "500 Internal Server Error") {
+		t.Errorf("Expected error to contain 'openai API error' and '500 Internal Server Error', got: %v", err)
+	}
+}
+
+func TestOpenAILlmService_GenerateText_MalformedResponse(t *testing.T) {
+	service := newOpenAITestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices": [{"message": {"content": "test"}}`) // Malformed JSON
+	})
+
+	_, err := service.GenerateText(context.Background(), "test prompt")
+	if err == nil || !strings.Contains(err.Error(), "failed to decode openai response") {
+		t.Errorf("Expected error to contain 'failed to decode openai response', got: %v", err)
+	}
+}
+
+func TestOpenAILlmService_GenerateText_EmptyChoices(t *testing.T) {
+	service := newOpenAITestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices": []}`)
+	})
+
+	_, err := service.GenerateText(context.Background(), "test prompt")
+	if err == nil || !strings.Contains(err.Error(), "no content found in openai response") {
+		t.Errorf("Expected error to contain 'no content found in openai response', got: %v", err)
+	}
+}
+
+func TestOpenAILlmService_ExtractTextFromImage_Success(t *testing.T) {
+	expectedResponseText := "Wine Name: Test Wine, Region: Test Region, Varietal: Test Varietal"
+	imageData := []byte("dummyimagedata")
+	service := newOpenAITestService(t, func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Model    string `json:"model"`
+			Messages []struct {
+				Role    string `json:"role"`
+				Content []struct {
+					Type     string `json:"type"`
+					Text     string `json:"text"`
+					ImageURL struct {
+						URL string `json:"url"`
+					} `json:"image_url"`
+				} `json:"content"`
+			} `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, "Bad request body, not a multimodal message", http.StatusBadRequest)
+			return
+		}
+		if payload.Model != "gpt-4o" || len(payload.Messages) != 1 || len(payload.Messages[0].Content) != 2 {
+			http.Error(w, "Expected one message with two content parts for the vision model", http.StatusBadRequest)
+			return
+		}
+		text, image := payload.Messages[0].Content[0], payload.Messages[0].Content[1]
+		if text.Type != "text" || text.Text != "Extract wine info" {
+			http.Error(w, "First content part is not the prompt", http.StatusBadRequest)
+			return
+		}
+		if image.Type != "image_url" || image.ImageURL.URL != "data:image/png;base64,"+base64.StdEncoding.EncodeToString(imageData) {
+			http.Error(w, "Second content part is not the image data URL", http.StatusBadRequest)
+			return
+		}
+		writeChoice(w, expectedResponseText)
+	})
+
+	actualText, err := service.ExtractTextFromImage(context.Background(), "Extract wine info", imageData, "image/png")
+	if err != nil {
+		t.Fatalf("ExtractTextFromImage failed: %v", err)
+	}
+	if actualText != expectedResponseText {
+		t.Errorf("Expected text '%s', got '%s'", expectedResponseText, actualText)
+	}
+}
+
+func TestOpenAILlmService_ExtractTextFromImage_EmptyImage(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "test_api_key")
+	service, err := NewOpenAILlmService()
+	if err != nil {
+		t.Fatalf("NewOpenAILlmService failed: %v", err)
+	}
+
+	_, err = service.ExtractTextFromImage(context.Background(), "prompt", []byte{}, "image/png")
+	if err == nil || !strings.Contains(err.Error(), "image data is empty") {
+		t.Errorf("Expected error to contain 'image data is empty', got: %v", err)
+	}
+}
+
+func TestOpenAILlmService_ExtractTextFromImage_APIError(t *testing.T) {
+	service := newOpenAITestService(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Gateway Timeout", http.StatusGatewayTimeout)
+	})
+
+	_, err := service.ExtractTextFromImage(context.Background(), "prompt", []byte("dummyData"), "image/jpeg")
+	if err == nil {
+		t.Fatalf("Expected an API error, got nil")
+	}
+	if !strings.Contains(err.Error(), "openai API error (multimodal)") || !strings.Contains(err.Error(), "504 Gateway Timeout") {
+		t.Errorf("Expected error to contain 'openai API error (multimodal)' and '504 Gateway Timeout', got: %v", err)
+	}
+}
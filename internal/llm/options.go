@@ -0,0 +1,198 @@
+package llm
+
+import (
+	"fmt"
+	"log/slog"
+
+	"google.golang.org/genai"
+)
+
+// GenerateOption overrides a generation parameter for one call, such as a lower
+// temperature for extraction.
+type GenerateOption func(*generateConfig)
+
+// generateConfig holds the generation parameters of a call: the method's
+// defaults overridden by its options.
+type generateConfig struct {
+	temperature float64
+	maxTokens   int
+	// topP is left to the provider's default when zero.
+	topP float64
+	stop []string
+	// seed is sent only when seeded.
+	seed   int
+	seeded bool
+	// model replaces the service's model only when modelSet.
+	model    string
+	modelSet bool
+}
+
+// Defaults of text generation, and of reading images and documents, which favours
+// factual answers. Documents get more tokens, as their reply is every page's text.
+const (
+	defaultTemperature = 0.7
+	defaultMaxTokens   = 500
+	imageTemperature   = 0.2
+	imageMaxTokens     = 300
+	documentMaxTokens  = 4096
+	// transcriptMaxTokens bounds the transcripts of models that are not
+	// transcription APIs, which answer in full.
+	transcriptMaxTokens = 8192
+)
+
+// WithTemperature sets the sampling temperature; 0 makes the reply as
+// deterministic as the provider allows.
+func WithTemperature(temperature float64) GenerateOption {
+	return func(c *generateConfig) { c.temperature = temperature }
+}
+
+// WithMaxTokens caps the length of the reply. Values below 1 keep the default.
+func WithMaxTokens(maxTokens int) GenerateOption {
+	return func(c *generateConfig) {
+		if maxTokens > 0 {
+			c.maxTokens = maxTokens
+		}
+	}
+}
+
+// WithTopP sets nucleus sampling: only the most likely tokens making up topP of
+// the probability are considered.
+func WithTopP(topP float64) GenerateOption {
+	return func(c *generateConfig) { c.topP = topP }
+}
+
+// WithStop ends the reply before any of sequences would be generated.
+func WithStop(sequences ...string) GenerateOption {
+	return func(c *generateConfig) { c.stop = sequences }
+}
+
+// WithSeed makes sampling repeatable: calls with the same seed, prompt and
+// parameters get the same reply, as far as the provider allows. Providers without
+// a seed parameter, such as Anthropic, ignore it.
+func WithSeed(seed int) GenerateOption {
+	return func(c *generateConfig) { c.seed, c.seeded = seed, true }
+}
+
+// WithModel sends the call to the model name instead of the service's model for
+// it, such as a small model for extraction and a large one for summarization. For
+// Azure OpenAI, name is a deployment. An empty name fails the call.
+func WithModel(name string) GenerateOption {
+	return func(c *generateConfig) { c.model, c.modelSet = name, true }
+}
+
+// DeterministicSeed is the seed of DeterministicProfile.
+const DeterministicSeed = 42
+
+// DeterministicProfile makes replies as repeatable as the provider allows, for
+// tests and for extraction: temperature 0, a top_p of 1 and DeterministicSeed.
+// Options after it override its parameters.
+func DeterministicProfile() GenerateOption {
+	return func(c *generateConfig) {
+		c.temperature, c.topP = 0, 1
+		c.seed, c.seeded = DeterministicSeed, true
+	}
+}
+
+// generation returns the parameters of a call with the given defaults and opts.
+func generation(temperature float64, maxTokens int, opts []GenerateOption) generateConfig {
+	config := generateConfig{temperature: temperature, maxTokens: maxTokens}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return config
+}
+
+// modelOr returns the model of the call: the one given WithModel, or model.
+func (c generateConfig) modelOr(model string) string {
+	if c.modelSet {
+		return c.model
+	}
+	return model
+}
+
+// checkModel returns an error when a request names no model, as after an empty
+// WithModel.
+func checkModel(model string) error {
+	if model == "" {
+		return fmt.Errorf("model name must not be empty")
+	}
+	return nil
+}
+
+// chatCompletion sets the parameters in a Mistral or OpenAI chat completion
+// request, including the model given WithModel, and returns it. seedField names the seed parameter, which is
+// "random_seed" for Mistral and "seed" for OpenAI.
+func (c generateConfig) chatCompletion(seedField string, payload map[string]interface{}) map[string]interface{} {
+	if c.modelSet {
+		payload["model"] = c.model
+	}
+	payload["temperature"] = c.temperature
+	payload["max_tokens"] = c.maxTokens
+	if c.topP > 0 {
+		payload["top_p"] = c.topP
+	}
+	if len(c.stop) > 0 {
+		payload["stop"] = c.stop
+	}
+	if c.seeded {
+		payload[seedField] = c.seed
+	}
+	return payload
+}
+
+// anthropicMessages sets the parameters in an Anthropic Messages API request,
+// including the model given WithModel, and returns it.
+func (c generateConfig) anthropicMessages(payload map[string]interface{}) map[string]interface{} {
+	if c.modelSet {
+		payload["model"] = c.model
+	}
+	payload["temperature"] = c.temperature
+	payload["max_tokens"] = c.maxTokens
+	if c.topP > 0 {
+		payload["top_p"] = c.topP
+	}
+	if len(c.stop) > 0 {
+		payload["stop_sequences"] = c.stop
+	}
+	if c.seeded {
+		// The Messages API rejects unknown fields.
+		slog.Debug("Ignoring the seed, which Anthropic does not support", "seed", c.seed)
+	}
+	return payload
+}
+
+// ollamaChat sets the options of an Ollama chat request, and the model given
+// WithModel, and returns it.
+func (c generateConfig) ollamaChat(payload map[string]interface{}) map[string]interface{} {
+	if c.modelSet {
+		payload["model"] = c.model
+	}
+	options := map[string]interface{}{"temperature": c.temperature, "num_predict": c.maxTokens}
+	if c.topP > 0 {
+		options["top_p"] = c.topP
+	}
+	if len(c.stop) > 0 {
+		options["stop"] = c.stop
+	}
+	if c.seeded {
+		options["seed"] = c.seed
+	}
+	payload["options"] = options
+	return payload
+}
+
+// geminiConfig returns the configuration of a Gemini generateContent request.
+func (c generateConfig) geminiConfig() *genai.GenerateContentConfig {
+	config := &genai.GenerateContentConfig{
+		Temperature:     genai.Ptr(float32(c.temperature)),
+		MaxOutputTokens: int32(c.maxTokens),
+		StopSequences:   c.stop,
+	}
+	if c.topP > 0 {
+		config.TopP = genai.Ptr(float32(c.topP))
+	}
+	if c.seeded {
+		config.Seed = genai.Ptr(int32(c.seed))
+	}
+	return config
+}
@@ -0,0 +1,278 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var callOptions = []GenerateOption{WithTemperature(0), WithMaxTokens(4000), WithTopP(0.9), WithStop("###", "END")}
+
+func TestMistralLlmService_GenerateOptions(t *testing.T) {
+	var payload map[string]interface{}
+	server := mockMistralServer(func(w http.ResponseWriter, r *http.Request) {
+		payload = nil
+		json.NewDecoder(r.Body).Decode(&payload)
+		writeChoice(w, "ok")
+	})
+	defer server.Close()
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	service, err := NewMistralLlmService(WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("NewMistralLlmService failed: %v", err)
+	}
+
+	if _, err := service.GenerateText(context.Background(), "test prompt"); err != nil {
+		t.Fatalf("GenerateText failed: %v", err)
+	}
+	if payload["temperature"] != 0.7 || payload["max_tokens"] != 500.0 || payload["top_p"] != nil || payload["stop"] != nil {
+		t.Errorf("Expected the default parameters, got %v", payload)
+	}
+
+	if _, err := service.GenerateText(context.Background(), "test prompt", callOptions...); err != nil {
+		t.Fatalf("GenerateText failed: %v", err)
+	}
+	if payload["temperature"] != 0.0 || payload["max_tokens"] != 4000.0 || payload["top_p"] != 0.9 || !reflect.DeepEqual(payload["stop"], []interface{}{"###", "END"}) {
+		t.Errorf("Expected the call's parameters, got %v", payload)
+	}
+
+	if _, err := service.ExtractTextFromImage(context.Background(), "read it", []byte("png"), "image/png"); err != nil {
+		t.Fatalf("ExtractTextFromImage failed: %v", err)
+	}
+	if payload["temperature"] != 0.2 || payload["max_tokens"] != 300.0 {
+		t.Errorf("Expected the image defaults, got %v", payload)
+	}
+	if _, err := service.ExtractTextFromImage(context.Background(), "read it", []byte("png"), "image/png", WithMaxTokens(1000), WithMaxTokens(0)); err != nil {
+		t.Fatalf("ExtractTextFromImage failed: %v", err)
+	}
+	if payload["temperature"] != 0.2 || payload["max_tokens"] != 1000.0 {
+		t.Errorf("Expected only the max tokens overridden, got %v", payload)
+	}
+}
+
+func TestGenerateOptions_OtherProviders(t *testing.T) {
+	var anthropic map[string]interface{}
+	anthropicService := newAnthropicTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&anthropic)
+		writeMessage(w, "ok")
+	})
+	if _, err := anthropicService.GenerateText(context.Background(), "test prompt", callOptions...); err != nil {
+		t.Fatalf("Anthropic GenerateText failed: %v", err)
+	}
+	if anthropic["temperature"] != 0.0 || anthropic["max_tokens"] != 4000.0 || anthropic["top_p"] != 0.9 || !reflect.DeepEqual(anthropic["stop_sequences"], []interface{}{"###", "END"}) {
+		t.Errorf("Expected the Anthropic parameters, got %v", anthropic)
+	}
+
+	var ollama struct {
+		Options map[string]interface{} `json:"options"`
+	}
+	ollamaService, _ := mockOllamaServer(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&ollama)
+		json.NewEncoder(w).Encode(map[string]interface{}{"message": map[string]string{"content": "ok"}})
+	})
+	if _, err := ollamaService.GenerateText(context.Background(), "test prompt", callOptions...); err != nil {
+		t.Fatalf("Ollama GenerateText failed: %v", err)
+	}
+	expected := map[string]interface{}{"temperature": 0.0, "num_predict": 4000.0, "top_p": 0.9, "stop": []interface{}{"###", "END"}}
+	if !reflect.DeepEqual(ollama.Options, expected) {
+		t.Errorf("Expected the Ollama options %v, got %v", expected, ollama.Options)
+	}
+
+	var gemini struct {
+		GenerationConfig map[string]interface{} `json:"generationConfig"`
+	}
+	geminiService := newGeminiTestService(t, "gemini-test", func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&gemini)
+		writeCandidate(w, "ok")
+	})
+	if _, err := geminiService.GenerateText(context.Background(), "test prompt", callOptions...); err != nil {
+		t.Fatalf("Gemini GenerateText failed: %v", err)
+	}
+	config := gemini.GenerationConfig
+	if config["temperature"] != 0.0 || config["maxOutputTokens"] != 4000.0 || config["topP"] == nil || !reflect.DeepEqual(config["stopSequences"], []interface{}{"###", "END"}) {
+		t.Errorf("Expected the Gemini generation config, got %v", config)
+	}
+}
+
+func TestGenerateOptions_Seed(t *testing.T) {
+	var mistral map[string]interface{}
+	server := mockMistralServer(func(w http.ResponseWriter, r *http.Request) {
+		mistral = nil
+		json.NewDecoder(r.Body).Decode(&mistral)
+		writeChoice(w, "ok")
+	})
+	defer server.Close()
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	mistralService, err := NewMistralLlmService(WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("NewMistralLlmService failed: %v", err)
+	}
+	if _, err := mistralService.GenerateText(context.Background(), "test prompt"); err != nil {
+		t.Fatalf("GenerateText failed: %v", err)
+	}
+	if _, ok := mistral["random_seed"]; ok {
+		t.Errorf("Expected no seed by default, got %v", mistral)
+	}
+	if _, err := mistralService.GenerateText(context.Background(), "test prompt", DeterministicProfile()); err != nil {
+		t.Fatalf("GenerateText failed: %v", err)
+	}
+	if mistral["random_seed"] != float64(DeterministicSeed) || mistral["temperature"] != 0.0 || mistral["top_p"] != 1.0 || mistral["seed"] != nil {
+		t.Errorf("Expected the deterministic profile as random_seed, got %v", mistral)
+	}
+
+	var openai map[string]interface{}
+	openaiService := newOpenAITestService(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&openai)
+		writeChoice(w, "ok")
+	})
+	if _, err := openaiService.GenerateText(context.Background(), "test prompt", WithSeed(7)); err != nil {
+		t.Fatalf("OpenAI GenerateText failed: %v", err)
+	}
+	if openai["seed"] != 7.0 || openai["random_seed"] != nil {
+		t.Errorf("Expected the OpenAI seed, got %v", openai)
+	}
+
+	var logs bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	var anthropic map[string]interface{}
+	anthropicService := newAnthropicTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&anthropic)
+		writeMessage(w, "ok")
+	})
+	if _, err := anthropicService.GenerateText(context.Background(), "test prompt", WithSeed(7)); err != nil {
+		t.Fatalf("Anthropic GenerateText failed: %v", err)
+	}
+	if _, ok := anthropic["seed"]; ok {
+		t.Errorf("Expected no seed sent to Anthropic, got %v", anthropic)
+	}
+	if !strings.Contains(logs.String(), "Ignoring the seed") {
+		t.Errorf("Expected a debug log about the ignored seed, got %q", logs.String())
+	}
+
+	var ollama struct {
+		Options map[string]interface{} `json:"options"`
+	}
+	ollamaService, _ := mockOllamaServer(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&ollama)
+		json.NewEncoder(w).Encode(map[string]interface{}{"message": map[string]string{"content": "ok"}})
+	})
+	if _, err := ollamaService.GenerateText(context.Background(), "test prompt", WithSeed(7)); err != nil {
+		t.Fatalf("Ollama GenerateText failed: %v", err)
+	}
+	if ollama.Options["seed"] != 7.0 {
+		t.Errorf("Expected the Ollama seed, got %v", ollama.Options)
+	}
+
+	var gemini struct {
+		GenerationConfig map[string]interface{} `json:"generationConfig"`
+	}
+	geminiService := newGeminiTestService(t, "gemini-test", func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&gemini)
+		writeCandidate(w, "ok")
+	})
+	if _, err := geminiService.GenerateText(context.Background(), "test prompt", WithSeed(7)); err != nil {
+		t.Fatalf("Gemini GenerateText failed: %v", err)
+	}
+	if gemini.GenerationConfig["seed"] != 7.0 {
+		t.Errorf("Expected the Gemini seed, got %v", gemini.GenerationConfig)
+	}
+}
+
+func TestGenerateOptions_Model(t *testing.T) {
+	var models []interface{}
+	server := mockMistralServer(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		models = append(models, payload["model"])
+		writeChoice(w, "{}")
+	})
+	defer server.Close()
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	service, err := NewMistralLlmService(WithBaseURL(server.URL), WithChatModel("mistral-large-latest"), WithMultimodalModel("pixtral-large-latest"))
+	if err != nil {
+		t.Fatalf("NewMistralLlmService failed: %v", err)
+	}
+
+	ctx := context.Background()
+	if _, err := service.GenerateText(ctx, "extract", WithModel("mistral-small-latest")); err != nil {
+		t.Fatalf("GenerateText failed: %v", err)
+	}
+	if _, err := service.GenerateText(ctx, "summarize"); err != nil {
+		t.Fatalf("GenerateText failed: %v", err)
+	}
+	if _, _, err := GenerateJSON(ctx, service, "extract as JSON", WithModel("ministral-8b-latest")); err != nil {
+		t.Fatalf("GenerateJSON failed: %v", err)
+	}
+	if _, err := service.ExtractTextFromImage(ctx, "read it", []byte("png"), "image/png", WithModel("pixtral-12b-latest")); err != nil {
+		t.Fatalf("ExtractTextFromImage failed: %v", err)
+	}
+	expected := []interface{}{"mistral-small-latest", "mistral-large-latest", "ministral-8b-latest", "pixtral-12b-latest"}
+	if !reflect.DeepEqual(models, expected) {
+		t.Errorf("Expected the models %v, got %v", expected, models)
+	}
+	if service.ChatModel() != "mistral-large-latest" {
+		t.Errorf("Expected the service's chat model to be unchanged, got %q", service.ChatModel())
+	}
+
+	if _, err := service.GenerateText(ctx, "extract", WithModel("")); err == nil || !strings.Contains(err.Error(), "model name must not be empty") {
+		t.Errorf("Expected an empty model to fail, got %v", err)
+	}
+	if len(models) != len(expected) {
+		t.Errorf("Expected no request with an empty model, got %v", models[len(expected):])
+	}
+}
+
+func TestGenerateOptions_ModelOtherProviders(t *testing.T) {
+	ctx := context.Background()
+	var anthropic map[string]interface{}
+	anthropicService := newAnthropicTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&anthropic)
+		writeMessage(w, "ok")
+	})
+	if _, err := anthropicService.GenerateText(ctx, "test prompt", WithModel("claude-haiku")); err != nil {
+		t.Fatalf("Anthropic GenerateText failed: %v", err)
+	}
+	if anthropic["model"] != "claude-haiku" {
+		t.Errorf("Expected the Anthropic model claude-haiku, got %v", anthropic["model"])
+	}
+
+	var ollama map[string]interface{}
+	ollamaService, _ := mockOllamaServer(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&ollama)
+		json.NewEncoder(w).Encode(map[string]interface{}{"message": map[string]string{"content": "ok"}})
+	})
+	if _, err := ollamaService.GenerateText(ctx, "test prompt", WithModel("llama3.2:1b")); err != nil {
+		t.Fatalf("Ollama GenerateText failed: %v", err)
+	}
+	if ollama["model"] != "llama3.2:1b" || ollama["options"] == nil {
+		t.Errorf("Expected the Ollama model llama3.2:1b with options, got %v", ollama)
+	}
+
+	azureService, requests := newAzureTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		writeChoice(w, "ok")
+	})
+	if _, err := azureService.GenerateText(ctx, "test prompt", WithModel("chat-4o")); err != nil {
+		t.Fatalf("Azure GenerateText failed: %v", err)
+	}
+	if len(*requests) != 1 || !strings.HasPrefix((*requests)[0].URI, "/openai/deployments/chat-4o/chat/completions") {
+		t.Errorf("Expected the request to go to the chat-4o deployment, got %v", *requests)
+	}
+
+	var gemini []string
+	geminiService := newGeminiTestService(t, "gemini-flash-lite", func(w http.ResponseWriter, r *http.Request) {
+		gemini = append(gemini, r.URL.Path)
+		writeCandidate(w, "ok")
+	})
+	if _, err := geminiService.GenerateText(ctx, "test prompt", WithModel("gemini-flash-lite")); err != nil {
+		t.Fatalf("Gemini GenerateText failed: %v", err)
+	}
+	if len(gemini) != 1 {
+		t.Errorf("Expected one request to gemini-flash-lite, got %v", gemini)
+	}
+}
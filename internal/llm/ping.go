@@ -0,0 +1,111 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/redact"
+	"github.com/sandwichlabs/agent-memory-graph/internal/retry"
+)
+
+// PreflightTimeout bounds the ping of Preflight.
+const PreflightTimeout = 10 * time.Second
+
+// apiKeyEnv names the environment variable holding each provider's API key.
+var apiKeyEnv = map[Provider]string{
+	ProviderMistral:          "MISTRAL_API_KEY",
+	ProviderOpenAI:           "OPENAI_API_KEY",
+	ProviderAnthropic:        "ANTHROPIC_API_KEY",
+	ProviderGemini:           "GEMINI_API_KEY",
+	ProviderAzureOpenAI:      "AZURE_OPENAI_API_KEY",
+	ProviderOpenAICompatible: "OPENAI_COMPATIBLE_API_KEY",
+}
+
+// APIKeyEnv returns the environment variable holding the API key provider needs,
+// or "" when it needs none. The OpenAI-compatible provider's key is optional, as
+// local servers take none.
+func APIKeyEnv(provider Provider) string {
+	if provider == ProviderOpenAICompatible {
+		return ""
+	}
+	return apiKeyEnv[provider]
+}
+
+// MissingAPIKey returns the environment variable holding provider's API key when
+// the provider needs one and it is not set, or "".
+func MissingAPIKey(provider Provider) string {
+	if name := APIKeyEnv(provider); name != "" && os.Getenv(name) == "" {
+		return name
+	}
+	return ""
+}
+
+// Preflight pings service, giving up after PreflightTimeout, so that a bad key or
+// an unreachable API is reported before any work is done rather than on the first
+// request. Its error says what is wrong and wraps that of Ping.
+func Preflight(ctx context.Context, service LlmService) error {
+	ctx, cancel := context.WithTimeout(ctx, PreflightTimeout)
+	defer cancel()
+	err := service.Ping(ctx)
+	if err == nil {
+		return nil
+	}
+	provider, _ := ChatModel(service)
+	return fmt.Errorf("LLM preflight failed: %s: %w", diagnose(provider, err), err)
+}
+
+// diagnose explains what a failed ping of provider says about its configuration.
+func diagnose(provider Provider, err error) string {
+	name := string(provider)
+	if name == "" {
+		name = "the LLM provider"
+	}
+	switch {
+	case errors.Is(err, ErrUnauthorized):
+		if env, ok := apiKeyEnv[provider]; ok {
+			return fmt.Sprintf("%s rejected the API key; check %s", name, env)
+		}
+		return fmt.Sprintf("%s rejected the API key", name)
+	case errors.Is(err, ErrUnreachable), errors.Is(err, context.DeadlineExceeded):
+		return fmt.Sprintf("%s could not be reached; check the network, HTTPS_PROXY and the API's base URL", name)
+	case errors.Is(err, ErrQuotaExceeded):
+		return fmt.Sprintf("the %s account is out of credit or quota", name)
+	}
+	return fmt.Sprintf("%s did not answer as expected", name)
+}
+
+// ping sends an authenticated GET to url, such as a provider's list of models,
+// and returns nil when the API answers it. Failing to reach the API wraps
+// ErrUnreachable; a refusal is an *APIError, which wraps ErrUnauthorized for a
+// bad key.
+func ping(ctx context.Context, client Doer, provider, url string, authorize func(*http.Request)) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create ping request to %s: %w", url, err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if authorize != nil {
+		authorize(req)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		slog.ErrorContext(ctx, "Failed to reach the LLM provider", "provider", provider, "url", url, "error", err)
+		return fmt.Errorf("%w: failed to reach the %s API at %s: %v", ErrUnreachable, provider, url, err)
+	}
+	defer retry.CloseBody(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		slog.ErrorContext(ctx, "LLM provider refused the ping", "provider", provider, "status_code", resp.StatusCode, redact.Body("response_body", string(bodyBytes)))
+		return newAPIError(provider, "ping", resp, bodyBytes)
+	}
+	return nil
+}
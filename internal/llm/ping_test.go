@@ -0,0 +1,153 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newPingTestService returns a Mistral service whose API answers GET /models
+// with status, recording the Authorization header it was sent.
+func newPingTestService(t *testing.T, status int, authorization *string) *MistralLlmService {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/models" {
+			http.Error(w, "Not found: Unexpected request "+r.Method+" "+r.URL.Path, http.StatusNotFound)
+			return
+		}
+		*authorization = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			w.Write([]byte(`{"object": "list", "data": [{"id": "mistral-small-latest"}]}`))
+		} else {
+			w.Write([]byte(`{"message": "Unauthorized", "request_id": "abc"}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	service, err := NewMistralLlmService(WithAPIKey("test_api_key"))
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	service.HTTPClient = server.Client()
+	service.APIBaseURL = server.URL
+	return service
+}
+
+func TestMistralLlmService_Ping(t *testing.T) {
+	var authorization string
+	service := newPingTestService(t, http.StatusOK, &authorization)
+
+	if err := service.Ping(context.Background()); err != nil {
+		t.Fatalf("Expected the ping to succeed, got %v", err)
+	}
+	if authorization != "Bearer test_api_key" {
+		t.Errorf("Expected the ping to send the API key, got Authorization %q", authorization)
+	}
+	if err := Preflight(context.Background(), service); err != nil {
+		t.Errorf("Expected the preflight to pass, got %v", err)
+	}
+}
+
+func TestMistralLlmService_PingUnauthorized(t *testing.T) {
+	var authorization string
+	service := newPingTestService(t, http.StatusUnauthorized, &authorization)
+
+	err := service.Ping(context.Background())
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("Expected an error wrapping ErrUnauthorized, got %v", err)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected an *APIError with status 401, got %#v", err)
+	}
+
+	err = Preflight(context.Background(), service)
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("Expected the preflight error to wrap ErrUnauthorized, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "mistral rejected the API key; check MISTRAL_API_KEY") {
+		t.Errorf("Expected the preflight error to name MISTRAL_API_KEY, got %q", err)
+	}
+}
+
+func TestMistralLlmService_PingUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	service, err := NewMistralLlmService(WithAPIKey("test_api_key"))
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	service.APIBaseURL = server.URL
+
+	err = Preflight(context.Background(), service)
+	if !errors.Is(err, ErrUnreachable) {
+		t.Fatalf("Expected an error wrapping ErrUnreachable, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "mistral could not be reached") {
+		t.Errorf("Expected the preflight error to say the API could not be reached, got %q", err)
+	}
+}
+
+func TestAnthropicLlmService_PingUnauthorized(t *testing.T) {
+	var apiKey, version string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiKey, version = r.Header.Get("X-Api-Key"), r.Header.Get("Anthropic-Version")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"type": "error", "error": {"type": "authentication_error", "message": "invalid x-api-key"}}`))
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("ANTHROPIC_API_KEY", "test_api_key")
+	service, err := NewAnthropicLlmService()
+	if err != nil {
+		t.Fatalf("NewAnthropicLlmService failed: %v", err)
+	}
+	service.HTTPClient = server.Client()
+	service.APIBaseURL = server.URL
+
+	err = Preflight(context.Background(), service)
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("Expected an error wrapping ErrUnauthorized, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "check ANTHROPIC_API_KEY") {
+		t.Errorf("Expected the preflight error to name ANTHROPIC_API_KEY, got %q", err)
+	}
+	if apiKey != "test_api_key" || version == "" {
+		t.Errorf("Expected the ping to send the API key and version, got %q and %q", apiKey, version)
+	}
+}
+
+func TestPreflight_Mock(t *testing.T) {
+	service := &MockLlmService{PingErr: ErrUnauthorized}
+	err := Preflight(context.Background(), service)
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("Expected an error wrapping ErrUnauthorized, got %v", err)
+	}
+	if !strings.HasPrefix(err.Error(), "LLM preflight failed: testing rejected the API key") {
+		t.Errorf("Expected a diagnosis of the key, got %q", err)
+	}
+}
+
+func TestMissingAPIKey(t *testing.T) {
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	t.Setenv("MISTRAL_API_KEY", "key")
+	t.Setenv("OPENAI_COMPATIBLE_API_KEY", "")
+
+	if got := MissingAPIKey(ProviderAnthropic); got != "ANTHROPIC_API_KEY" {
+		t.Errorf("Expected ANTHROPIC_API_KEY to be missing, got %q", got)
+	}
+	if got := MissingAPIKey(ProviderMistral); got != "" {
+		t.Errorf("Expected no missing key with MISTRAL_API_KEY set, got %q", got)
+	}
+	if got := MissingAPIKey(ProviderOpenAICompatible); got != "" {
+		t.Errorf("Expected the optional OpenAI-compatible key not to be missing, got %q", got)
+	}
+	if got := MissingAPIKey(ProviderOllama); got != "" {
+		t.Errorf("Expected ollama to need no key, got %q", got)
+	}
+}
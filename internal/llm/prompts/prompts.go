@@ -0,0 +1,194 @@
+// Package prompts holds the prompt templates sent to language models. The
+// built-in templates can be overridden, without recompiling, by files named after
+// them in a prompts directory next to the memory graph, such as
+// prompts/extraction.tmpl. Templates use text/template.
+package prompts
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// Dir is the directory of template overrides, relative to the memory graph.
+const Dir = "prompts"
+
+// BuiltIn is the Source of templates that are not overridden.
+const BuiltIn = "built-in"
+
+// Name identifies a template; an override is the file Name + ".tmpl".
+type Name string
+
+const (
+	// Extraction asks for the entities and relationships in a text as JSON. It
+	// is rendered with TextData.
+	Extraction Name = "extraction"
+	// ToolExtraction asks for the same as Extraction to be recorded by calling
+	// the record_graph tool. It is rendered with TextData.
+	ToolExtraction Name = "tool_extraction"
+	// Summarization asks for a short summary of a text. It is rendered with
+	// TextData.
+	Summarization Name = "summarization"
+	// Answer asks for an answer to a question from numbered context, citing it
+	// inline like [1]. It is rendered with AnswerData.
+	Answer Name = "answer"
+)
+
+// TextData is the data of templates about one text.
+type TextData struct {
+	Text string
+}
+
+// AnswerData is the data of the Answer template.
+type AnswerData struct {
+	Question string
+	Context  []Passage
+}
+
+// Passage is a numbered piece of context.
+type Passage struct {
+	Number  int
+	Content string
+}
+
+// samples are the data each template is rendered with when it is loaded, so that
+// an override referring to data that does not exist fails then.
+var samples = map[Name]any{
+	Extraction:     TextData{Text: "Ada Lovelace worked with Charles Babbage."},
+	ToolExtraction: TextData{Text: "Ada Lovelace worked with Charles Babbage."},
+	Summarization:  TextData{Text: "Ada Lovelace worked with Charles Babbage."},
+	Answer: AnswerData{
+		Question: "Who did Ada Lovelace work with?",
+		Context:  []Passage{{Number: 1, Content: "Ada Lovelace worked with Charles Babbage."}},
+	},
+}
+
+// Names lists the templates in the order List reports them.
+func Names() []Name {
+	return []Name{Extraction, ToolExtraction, Summarization, Answer}
+}
+
+//go:embed templates/*.tmpl
+var builtins embed.FS
+
+// Template describes a loaded template.
+type Template struct {
+	Name Name
+	// Source is the path of the override, or BuiltIn.
+	Source string
+}
+
+// Set is a complete set of parsed and validated templates.
+type Set struct {
+	templates map[Name]*template.Template
+	sources   map[Name]string
+}
+
+var defaultSet = sync.OnceValue(func() *Set {
+	set, err := Load("")
+	if err != nil {
+		panic(err) // the built-in templates are tested
+	}
+	return set
+})
+
+// Default returns the built-in templates.
+func Default() *Set {
+	return defaultSet()
+}
+
+// Load returns the built-in templates overridden by the .tmpl files in dir, which
+// may be empty or missing. It fails on the first template that does not parse or
+// render, naming it, and on files that override no template.
+func Load(dir string) (*Set, error) {
+	set := &Set{templates: make(map[Name]*template.Template), sources: make(map[Name]string)}
+	for _, name := range Names() {
+		text, err := builtins.ReadFile("templates/" + string(name) + ".tmpl")
+		if err != nil {
+			return nil, err
+		}
+		if err := set.add(name, string(text), BuiltIn); err != nil {
+			return nil, err
+		}
+	}
+	if dir == "" {
+		return set, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return set, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prompt templates: %w", err)
+	}
+	for _, entry := range entries {
+		file, ok := strings.CutSuffix(entry.Name(), ".tmpl")
+		if entry.IsDir() || !ok {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if _, known := samples[Name(file)]; !known {
+			return nil, fmt.Errorf("prompt template %s overrides no template: name it after one of %s", path, strings.Join(nameStrings(), ", "))
+		}
+		text, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read prompt template %s: %w", path, err)
+		}
+		if err := set.add(Name(file), string(text), path); err != nil {
+			return nil, err
+		}
+	}
+	return set, nil
+}
+
+// add parses text as the template name and checks it renders its sample data.
+func (s *Set) add(name Name, text, source string) error {
+	tmpl, err := template.New(string(name)).Parse(text)
+	if err != nil {
+		return fmt.Errorf("invalid prompt template %s (%s): %w", name, source, err)
+	}
+	if err := tmpl.Execute(&bytes.Buffer{}, samples[name]); err != nil {
+		return fmt.Errorf("invalid prompt template %s (%s): %w", name, source, err)
+	}
+	s.templates[name], s.sources[name] = tmpl, source
+	return nil
+}
+
+// Render returns the prompt of template name for data, which should be of the
+// type the template's Name documents.
+func (s *Set) Render(name Name, data any) (string, error) {
+	tmpl, ok := s.templates[name]
+	if !ok {
+		return "", fmt.Errorf("unknown prompt template %q", name)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to render prompt template %s: %w", name, err)
+	}
+	return b.String(), nil
+}
+
+// List describes the templates of s, in the order of Names.
+func (s *Set) List() []Template {
+	templates := make([]Template, 0, len(s.templates))
+	for _, name := range Names() {
+		templates = append(templates, Template{Name: name, Source: s.sources[name]})
+	}
+	return templates
+}
+
+func nameStrings() []string {
+	var names []string
+	for _, name := range Names() {
+		names = append(names, string(name))
+	}
+	return names
+}
@@ -0,0 +1,71 @@
+package prompts
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDefault_RendersEveryTemplate(t *testing.T) {
+	set := Default()
+	for _, tmpl := range set.List() {
+		if tmpl.Source != BuiltIn {
+			t.Errorf("Expected %s to be built in, got %s", tmpl.Name, tmpl.Source)
+		}
+		if _, err := set.Render(tmpl.Name, samples[tmpl.Name]); err != nil {
+			t.Errorf("Expected %s to render, got %v", tmpl.Name, err)
+		}
+	}
+
+	prompt, err := set.Render(Answer, AnswerData{Question: "who?", Context: []Passage{{1, "first"}, {2, "second"}}})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if !strings.Contains(prompt, "[1] first\n\n[2] second\n\nQuestion: who?\n") {
+		t.Errorf("Expected numbered context before the question, got %q", prompt)
+	}
+	if _, err := set.Render("poem", nil); err == nil {
+		t.Error("Expected an unknown template to fail, got nil")
+	}
+}
+
+func writeTemplate(t *testing.T, dir, file, text string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, file), []byte(text), 0o644); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+}
+
+func TestLoad_Overrides(t *testing.T) {
+	if set, err := Load(filepath.Join(t.TempDir(), "missing")); err != nil || set.List()[0].Source != BuiltIn {
+		t.Fatalf("Expected a missing directory to keep the built-in templates, got %v", err)
+	}
+
+	dir := t.TempDir()
+	writeTemplate(t, dir, "extraction.tmpl", "List the entities in: {{.Text}}")
+	writeTemplate(t, dir, "notes.txt", "not a template")
+	set, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if prompt, _ := set.Render(Extraction, TextData{Text: "Ada"}); prompt != "List the entities in: Ada" {
+		t.Errorf("Expected the overriding template, got %q", prompt)
+	}
+	if source := set.List()[0].Source; source != filepath.Join(dir, "extraction.tmpl") {
+		t.Errorf("Expected the override's path as its source, got %s", source)
+	}
+
+	for file, text := range map[string]string{
+		"answer.tmpl":        "{{range .Context}}",
+		"summarization.tmpl": "{{.Question}}",
+		"sumary.tmpl":        "{{.Text}}",
+	} {
+		dir := t.TempDir()
+		writeTemplate(t, dir, file, text)
+		name := strings.TrimSuffix(file, ".tmpl")
+		if _, err := Load(dir); err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("Expected %s to fail naming the template, got %v", file, err)
+		}
+	}
+}
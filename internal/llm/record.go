@@ -0,0 +1,171 @@
+package llm
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// EnvRecord is the environment variable that, when true, makes a Recorder send
+// requests and save them with their responses instead of replaying them.
+const EnvRecord = "AMG_LLM_RECORD"
+
+// redactedHeaders are the request headers holding API keys, which fixtures do not
+// keep.
+var redactedHeaders = []string{"Authorization", "Api-Key", "X-Api-Key", "X-Goog-Api-Key"}
+
+// Recorder is an http.RoundTripper that records requests to a provider's API with
+// their responses as JSON fixtures in Dir, and replays them, so that tests of
+// code calling an LLM can run offline and give the same result every time. A
+// request matches a fixture by its method, URL path and the hash of its body,
+// with JSON bodies normalized first; a request without a fixture fails when
+// replaying. Give a service Recorder.Client, such as with WithHTTPClient.
+type Recorder struct {
+	Dir string
+	// Record sends requests with Transport and saves them and their responses,
+	// replacing fixtures that match; otherwise fixtures are replayed and nothing
+	// is sent.
+	Record bool
+	// Transport sends requests when recording; http.DefaultTransport when nil.
+	Transport http.RoundTripper
+}
+
+// NewRecorder returns a recorder of fixtures in dir, recording when AMG_LLM_RECORD
+// is true and replaying otherwise.
+func NewRecorder(dir string) *Recorder {
+	record, _ := strconv.ParseBool(os.Getenv(EnvRecord))
+	return &Recorder{Dir: dir, Record: record}
+}
+
+// Client returns an HTTP client sending its requests through r.
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// fixture is a request and its response as saved by a Recorder.
+type fixture struct {
+	Request  fixtureRequest  `json:"request"`
+	Response fixtureResponse `json:"response"`
+}
+
+type fixtureRequest struct {
+	Method string      `json:"method"`
+	Path   string      `json:"path"`
+	Header http.Header `json:"header"`
+	Body   string      `json:"body"`
+}
+
+type fixtureResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       string      `json:"body"`
+}
+
+// RoundTrip replays the fixture matching req, or sends req and records it.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := requestBody(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	path := filepath.Join(r.Dir, fixtureName(req.Method, req.URL.Path, body))
+	if r.Record {
+		return r.record(req, body, path)
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no fixture for %s %s in %s (expected %s): record it with %s=1", req.Method, req.URL.Path, r.Dir, filepath.Base(path), EnvRecord)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var f fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid fixture %s: %w", path, err)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", f.Response.StatusCode, http.StatusText(f.Response.StatusCode)),
+		StatusCode:    f.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        f.Response.Header,
+		Body:          io.NopCloser(strings.NewReader(f.Response.Body)),
+		ContentLength: int64(len(f.Response.Body)),
+		Request:       req,
+	}, nil
+}
+
+// record sends req, whose body is body, and saves it and its response to path.
+func (r *Recorder) record(req *http.Request, body, path string) (*http.Response, error) {
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	header := req.Header.Clone()
+	for _, key := range redactedHeaders {
+		if header.Get(key) != "" {
+			header.Set(key, "REDACTED")
+		}
+	}
+	data, err := json.MarshalIndent(fixture{
+		Request:  fixtureRequest{Method: req.Method, Path: req.URL.Path, Header: header, Body: body},
+		Response: fixtureResponse{StatusCode: resp.StatusCode, Header: resp.Header, Body: string(respBody)},
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(r.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create fixture directory: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return nil, fmt.Errorf("failed to save fixture: %w", err)
+	}
+	return resp, nil
+}
+
+// fixtureName names the fixture of a request by its method, path and the hash of
+// its normalized body, such as "post-chat-completions-2cf24dba5fb0a30e.json".
+func fixtureName(method, path, body string) string {
+	slug := strings.ToLower(method) + "-" + strings.Trim(strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '-'
+	}, path), "-")
+	sum := sha256.Sum256([]byte(normalizeBody(body)))
+	return slug + "-" + hex.EncodeToString(sum[:8]) + ".json"
+}
+
+// normalizeBody returns a JSON body re-encoded with sorted keys and no spacing,
+// so that bodies differing only in layout match, and other bodies as they are.
+func normalizeBody(body string) string {
+	var v any
+	if err := json.Unmarshal([]byte(body), &v); err != nil {
+		return body
+	}
+	normalized, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+	return string(normalized)
+}
@@ -0,0 +1,76 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecorder_RecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	requests := 0
+	server := mockMistralServer(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		writeChoice(w, "recorded reply")
+	})
+	defer server.Close()
+
+	t.Setenv(EnvRecord, "1")
+	recorder := NewRecorder(dir)
+	if !recorder.Record {
+		t.Fatalf("Expected %s=1 to record", EnvRecord)
+	}
+	service, err := NewMistralLlmService(WithAPIKey("secret_key"), WithBaseURL(server.URL), WithHTTPClient(recorder.Client()))
+	if err != nil {
+		t.Fatalf("NewMistralLlmService failed: %v", err)
+	}
+	if text, err := service.GenerateText(context.Background(), "test prompt"); err != nil || text != "recorded reply" {
+		t.Fatalf("Expected the server's reply while recording, got %q, %v", text, err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "post-chat-completions-*.json"))
+	if len(files) != 1 {
+		t.Fatalf("Expected one fixture, got %v", files)
+	}
+	data, _ := os.ReadFile(files[0])
+	var f fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		t.Fatalf("Failed to decode fixture: %v", err)
+	}
+	if f.Request.Header.Get("Authorization") != "REDACTED" || strings.Contains(string(data), "secret_key") {
+		t.Errorf("Expected the Authorization header redacted, got %v", f.Request.Header)
+	}
+	if f.Request.Method != http.MethodPost || f.Request.Path != "/chat/completions" || f.Response.StatusCode != http.StatusOK {
+		t.Errorf("Expected a POST to /chat/completions answered with 200, got %+v", f)
+	}
+
+	// Replaying needs neither the server nor the key it was recorded with.
+	t.Setenv(EnvRecord, "")
+	service, err = NewMistralLlmService(WithAPIKey("other_key"), WithBaseURL("http://replay.invalid"), WithHTTPClient(NewRecorder(dir).Client()))
+	if err != nil {
+		t.Fatalf("NewMistralLlmService failed: %v", err)
+	}
+	if text, err := service.GenerateText(context.Background(), "test prompt"); err != nil || text != "recorded reply" || requests != 1 {
+		t.Errorf("Expected the recorded reply without a request, got %q, %v after %d requests", text, err, requests)
+	}
+
+	_, err = service.GenerateText(context.Background(), "another prompt")
+	if err == nil || !strings.Contains(err.Error(), "no fixture for POST /chat/completions") || !strings.Contains(err.Error(), EnvRecord+"=1") {
+		t.Errorf("Expected a request without a fixture to fail naming how to record it, got %v", err)
+	}
+}
+
+func TestFixtureName_NormalizesJSON(t *testing.T) {
+	a := fixtureName("POST", "/v1/chat/completions", `{"model": "m", "messages": [{"role": "user"}]}`)
+	b := fixtureName("POST", "/v1/chat/completions", `{"messages":[{"role":"user"}],"model":"m"}`)
+	if a != b || !strings.HasPrefix(a, "post-v1-chat-completions-") {
+		t.Errorf("Expected bodies differing in layout to share a fixture, got %s and %s", a, b)
+	}
+	if c := fixtureName("POST", "/v1/chat/completions", `{"model": "other"}`); c == a {
+		t.Errorf("Expected different bodies to have different fixtures, got %s", c)
+	}
+}
@@ -0,0 +1,11 @@
+package llm
+
+import "github.com/sandwichlabs/agent-memory-graph/internal/retry"
+
+// RetryPolicy configures retrying transient failures: rate limiting, server
+// errors and dropped connections. It is shared with the embedding services.
+type RetryPolicy = retry.Policy
+
+// DefaultRetryPolicy makes up to four attempts, waiting about 0.5s, 1s and 2s
+// between them, or up to a minute when the server asks.
+var DefaultRetryPolicy = retry.DefaultPolicy
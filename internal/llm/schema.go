@@ -0,0 +1,311 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SchemaError is a reply that is valid JSON but does not conform to the schema it
+// was asked for.
+type SchemaError struct {
+	// Schema is the name the schema was given.
+	Schema string
+	// Path locates the failing value, such as "$.entities[0].type".
+	Path    string
+	Message string
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("reply does not match schema %s at %s: %s", e.Schema, e.Path, e.Message)
+}
+
+// schemaGenerator is implemented by services whose API can be given a JSON schema
+// the reply must conform to, such as the json_schema response format of Mistral
+// and OpenAI.
+type schemaGenerator interface {
+	generateWithSchema(ctx context.Context, prompt, name string, schema json.RawMessage, opts []GenerateOption) (string, Usage, error)
+}
+
+// SupportsSchema reports whether the API of service constrains replies to the
+// schema given to GenerateWithSchema, looking through caches. Other services are
+// only asked for JSON and have their replies checked.
+func SupportsSchema(service LlmService) bool {
+	switch s := service.(type) {
+	case *CachedService:
+		return SupportsSchema(s.inner)
+	case *cachedToolCaller:
+		return SupportsSchema(s.inner)
+	}
+	_, ok := service.(schemaGenerator)
+	return ok
+}
+
+// GenerateWithSchema asks service for a JSON reply to prompt conforming to schema,
+// a JSON schema named schemaName. Providers with structured outputs are given the
+// schema; the others are asked for JSON as by GenerateJSON. Every reply is
+// checked against the schema here, and one that is not valid JSON or does not
+// conform is retried once with a corrective prompt, after which the error wraps
+// ErrInvalidJSON and, for a reply that does not conform, a *SchemaError. The
+// usage covers both attempts.
+//
+// The check covers the keywords type, enum, const, properties, required,
+// additionalProperties, items, minItems, maxItems, minLength, maxLength, pattern,
+// minimum, maximum, anyOf and oneOf; others, such as $ref, are ignored.
+func GenerateWithSchema(ctx context.Context, service LlmService, prompt, schemaName string, schema json.RawMessage, opts ...GenerateOption) (json.RawMessage, Usage, error) {
+	if schemaName == "" {
+		return nil, Usage{}, fmt.Errorf("schema name must not be empty")
+	}
+	var compiled map[string]any
+	if err := json.Unmarshal(schema, &compiled); err != nil {
+		return nil, Usage{}, fmt.Errorf("invalid schema %s: %w", schemaName, err)
+	}
+
+	ask := askJSON(service, opts)
+	if generator, ok := service.(schemaGenerator); ok {
+		ask = func(ctx context.Context, request string) (string, Usage, error) {
+			return generator.generateWithSchema(ctx, request, schemaName, schema, opts)
+		}
+	}
+	var raw json.RawMessage
+	usage, err := generateValid(ctx, prompt, ask, func(reply string) error {
+		var v any
+		if err := json.Unmarshal([]byte(reply), &v); err != nil {
+			return err
+		}
+		if err := validateSchema(schemaName, compiled, v, "$"); err != nil {
+			return err
+		}
+		raw = json.RawMessage(reply)
+		return nil
+	})
+	return raw, usage, err
+}
+
+// schemaResponseFormat is the response_format of a Mistral or OpenAI chat
+// completion constraining the reply to schema.
+func schemaResponseFormat(name string, schema json.RawMessage) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "json_schema",
+		"json_schema": map[string]interface{}{
+			"name":   name,
+			"schema": schema,
+		},
+	}
+}
+
+// validateSchema checks the decoded JSON value v at path against schema.
+func validateSchema(name string, schema map[string]any, v any, path string) error {
+	fail := func(format string, args ...any) error {
+		return &SchemaError{Schema: name, Path: path, Message: fmt.Sprintf(format, args...)}
+	}
+
+	if types, ok := schemaTypes(schema["type"]); ok {
+		matched := false
+		for _, t := range types {
+			if hasType(v, t) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fail("expected %s, got %s", strings.Join(types, " or "), jsonType(v))
+		}
+	}
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, allowed := range enum {
+			if reflect.DeepEqual(v, allowed) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fail("%s is not one of %s", compact(v), compact(enum))
+		}
+	}
+	if constant, ok := schema["const"]; ok && !reflect.DeepEqual(v, constant) {
+		return fail("expected %s, got %s", compact(constant), compact(v))
+	}
+	if err := validateAlternatives(name, schema, v, path); err != nil {
+		return err
+	}
+
+	switch value := v.(type) {
+	case map[string]any:
+		properties, _ := schema["properties"].(map[string]any)
+		if required, ok := schema["required"].([]any); ok {
+			for _, key := range required {
+				if k, ok := key.(string); ok {
+					if _, present := value[k]; !present {
+						return fail("missing required property %q", k)
+					}
+				}
+			}
+		}
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			child := path + "." + key
+			if property, ok := properties[key].(map[string]any); ok {
+				if err := validateSchema(name, property, value[key], child); err != nil {
+					return err
+				}
+				continue
+			}
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					return fail("unexpected property %q", key)
+				}
+			case map[string]any:
+				if err := validateSchema(name, additional, value[key], child); err != nil {
+					return err
+				}
+			}
+		}
+	case []any:
+		if minItems, ok := schemaNumber(schema["minItems"]); ok && float64(len(value)) < minItems {
+			return fail("expected at least %v items, got %d", minItems, len(value))
+		}
+		if maxItems, ok := schemaNumber(schema["maxItems"]); ok && float64(len(value)) > maxItems {
+			return fail("expected at most %v items, got %d", maxItems, len(value))
+		}
+		if items, ok := schema["items"].(map[string]any); ok {
+			for n, item := range value {
+				if err := validateSchema(name, items, item, fmt.Sprintf("%s[%d]", path, n)); err != nil {
+					return err
+				}
+			}
+		}
+	case string:
+		length := float64(len([]rune(value)))
+		if minLength, ok := schemaNumber(schema["minLength"]); ok && length < minLength {
+			return fail("expected at least %v characters, got %v", minLength, length)
+		}
+		if maxLength, ok := schemaNumber(schema["maxLength"]); ok && length > maxLength {
+			return fail("expected at most %v characters, got %v", maxLength, length)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fail("invalid pattern %q in schema: %v", pattern, err)
+			}
+			if !re.MatchString(value) {
+				return fail("%q does not match pattern %q", value, pattern)
+			}
+		}
+	case float64:
+		if minimum, ok := schemaNumber(schema["minimum"]); ok && value < minimum {
+			return fail("%v is less than the minimum %v", value, minimum)
+		}
+		if maximum, ok := schemaNumber(schema["maximum"]); ok && value > maximum {
+			return fail("%v is greater than the maximum %v", value, maximum)
+		}
+	}
+	return nil
+}
+
+// validateAlternatives checks v against the anyOf and oneOf keywords of schema.
+func validateAlternatives(name string, schema map[string]any, v any, path string) error {
+	for _, keyword := range []string{"anyOf", "oneOf"} {
+		alternatives, ok := schema[keyword].([]any)
+		if !ok {
+			continue
+		}
+		matches := 0
+		var first error
+		for _, alternative := range alternatives {
+			sub, ok := alternative.(map[string]any)
+			if !ok {
+				continue
+			}
+			if err := validateSchema(name, sub, v, path); err != nil {
+				if first == nil {
+					first = err
+				}
+				continue
+			}
+			matches++
+		}
+		switch {
+		case matches == 0 && first != nil:
+			return &SchemaError{Schema: name, Path: path, Message: fmt.Sprintf("matches none of %s: %v", keyword, first.(*SchemaError).Message)}
+		case keyword == "oneOf" && matches > 1:
+			return &SchemaError{Schema: name, Path: path, Message: fmt.Sprintf("matches %d of oneOf, not exactly one", matches)}
+		}
+	}
+	return nil
+}
+
+// schemaTypes returns the types allowed by the type keyword t, a name or a list of
+// names.
+func schemaTypes(t any) ([]string, bool) {
+	switch t := t.(type) {
+	case string:
+		return []string{t}, true
+	case []any:
+		var types []string
+		for _, name := range t {
+			if s, ok := name.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types, len(types) > 0
+	}
+	return nil, false
+}
+
+// hasType reports whether the decoded JSON value v is of the schema type t.
+func hasType(v any, t string) bool {
+	switch t {
+	case "integer":
+		n, ok := v.(float64)
+		return ok && n == math.Trunc(n)
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	}
+	return jsonType(v) == t
+}
+
+// jsonType names the type of the decoded JSON value v.
+func jsonType(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func schemaNumber(v any) (float64, bool) {
+	n, ok := v.(float64)
+	return n, ok
+}
+
+// compact formats the decoded JSON value v for messages.
+func compact(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
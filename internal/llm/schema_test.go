@@ -0,0 +1,167 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+const testSchema = `{
+  "type": "object",
+  "properties": {
+    "entities": {"type": "array", "items": {"type": "object", "properties": {
+      "name": {"type": "string", "minLength": 1},
+      "type": {"type": "string", "enum": ["PERSON", "ORG"]}
+    }, "required": ["name", "type"], "additionalProperties": false}},
+    "count": {"type": "integer", "minimum": 0}
+  },
+  "required": ["entities"]
+}`
+
+func TestValidateSchema(t *testing.T) {
+	var schema map[string]any
+	if err := json.Unmarshal([]byte(testSchema), &schema); err != nil {
+		t.Fatalf("Failed to decode schema: %v", err)
+	}
+	tests := []struct {
+		reply string
+		path  string // empty when the reply conforms
+	}{
+		{`{"entities": [{"name": "Kuzu", "type": "ORG"}], "count": 1}`, ""},
+		{`{"entities": [], "extra": true}`, ""},
+		{`[]`, "$"},
+		{`{"count": 1}`, "$"},
+		{`{"entities": [{"name": "Kuzu", "type": "org"}]}`, "$.entities[0].type"},
+		{`{"entities": [{"name": "Ada", "type": "PERSON"}, {"name": "Kuzu"}]}`, "$.entities[1]"},
+		{`{"entities": [{"name": "", "type": "ORG"}]}`, "$.entities[0].name"},
+		{`{"entities": [{"name": "Kuzu", "type": "ORG", "url": "x"}]}`, "$.entities[0]"},
+		{`{"entities": [], "count": 1.5}`, "$.count"},
+		{`{"entities": [], "count": -1}`, "$.count"},
+	}
+	for _, tt := range tests {
+		var v any
+		if err := json.Unmarshal([]byte(tt.reply), &v); err != nil {
+			t.Fatalf("Failed to decode %s: %v", tt.reply, err)
+		}
+		err := validateSchema("test", schema, v, "$")
+		if tt.path == "" {
+			if err != nil {
+				t.Errorf("Expected %s to conform, got %v", tt.reply, err)
+			}
+			continue
+		}
+		var schemaErr *SchemaError
+		if !errors.As(err, &schemaErr) || schemaErr.Path != tt.path {
+			t.Errorf("Expected %s to fail at %s, got %v", tt.reply, tt.path, err)
+		}
+	}
+}
+
+// newSchemaMistralService replies to successive chat completions with replies,
+// recording the response format of each request.
+func newSchemaMistralService(t *testing.T, replies ...string) (*MistralLlmService, *[]map[string]any) {
+	t.Helper()
+	var formats []map[string]any
+	server := mockMistralServer(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			ResponseFormat map[string]any `json:"response_format"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		formats = append(formats, payload.ResponseFormat)
+		writeChoice(w, replies[len(formats)-1])
+	})
+	t.Cleanup(server.Close)
+
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	service, err := NewMistralLlmService(WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("NewMistralLlmService failed: %v", err)
+	}
+	return service, &formats
+}
+
+func TestGenerateWithSchema_ValidReply(t *testing.T) {
+	reply := `{"entities": [{"name": "Kuzu", "type": "ORG"}]}`
+	service, formats := newSchemaMistralService(t, reply)
+
+	raw, _, err := GenerateWithSchema(context.Background(), service, "List the entities.", "entities", json.RawMessage(testSchema))
+	if err != nil {
+		t.Fatalf("GenerateWithSchema failed: %v", err)
+	}
+	if string(raw) != reply {
+		t.Errorf("Expected the reply unchanged, got %s", raw)
+	}
+	if len(*formats) != 1 {
+		t.Fatalf("Expected one request, got %d", len(*formats))
+	}
+	format := (*formats)[0]
+	jsonSchema, _ := format["json_schema"].(map[string]any)
+	if format["type"] != "json_schema" || jsonSchema["name"] != "entities" || jsonSchema["schema"] == nil {
+		t.Errorf("Expected the json_schema response format with the schema, got %v", format)
+	}
+}
+
+func TestGenerateWithSchema_InvalidAgainstSchema(t *testing.T) {
+	service, formats := newSchemaMistralService(t,
+		`{"entities": [{"name": "Kuzu", "type": "COMPANY"}]}`,
+		`{"entities": [{"name": "Kuzu"}]}`,
+	)
+
+	_, _, err := GenerateWithSchema(context.Background(), service, "List the entities.", "entities", json.RawMessage(testSchema))
+	if !errors.Is(err, ErrInvalidJSON) {
+		t.Errorf("Expected %v, got %v", ErrInvalidJSON, err)
+	}
+	var schemaErr *SchemaError
+	if !errors.As(err, &schemaErr) || schemaErr.Schema != "entities" || schemaErr.Path != "$.entities[0]" {
+		t.Errorf("Expected a SchemaError at $.entities[0], got %v", err)
+	}
+	if len(*formats) != 2 {
+		t.Errorf("Expected a corrective request, got %d requests", len(*formats))
+	}
+}
+
+func TestGenerateWithSchema_NotJSON(t *testing.T) {
+	service, _ := newSchemaMistralService(t, "Kuzu is an organization.", "Still not JSON.")
+
+	_, _, err := GenerateWithSchema(context.Background(), service, "List the entities.", "entities", json.RawMessage(testSchema))
+	var schemaErr *SchemaError
+	if !errors.Is(err, ErrInvalidJSON) || errors.As(err, &schemaErr) {
+		t.Errorf("Expected %v without a SchemaError, got %v", ErrInvalidJSON, err)
+	}
+
+	if _, _, err := GenerateWithSchema(context.Background(), service, "List.", "entities", json.RawMessage(`{"type":`)); err == nil {
+		t.Errorf("Expected an invalid schema to be rejected")
+	}
+}
+
+func TestGenerateWithSchema_OpenAIAndFallback(t *testing.T) {
+	var format map[string]any
+	service := newOpenAITestService(t, func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			ResponseFormat map[string]any `json:"response_format"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		format = payload.ResponseFormat
+		writeChoice(w, `{"entities": []}`)
+	})
+	if _, _, err := GenerateWithSchema(context.Background(), service, "List.", "entities", json.RawMessage(testSchema)); err != nil {
+		t.Fatalf("GenerateWithSchema failed: %v", err)
+	}
+	if format["type"] != "json_schema" {
+		t.Errorf("Expected the json_schema response format, got %v", format)
+	}
+
+	// Services without structured outputs still have their replies checked.
+	mock := NewMockLlmService()
+	mock.Response = `{"entities": [{"name": "Kuzu", "type": "COMPANY"}]}`
+	if SupportsSchema(mock) {
+		t.Errorf("Expected the mock not to support schemas")
+	}
+	_, _, err := GenerateWithSchema(context.Background(), mock, "List.", "entities", json.RawMessage(testSchema))
+	var schemaErr *SchemaError
+	if !errors.As(err, &schemaErr) || schemaErr.Path != "$.entities[0].type" {
+		t.Errorf("Expected a SchemaError at $.entities[0].type, got %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/budget"
+	"github.com/sandwichlabs/agent-memory-graph/internal/metrics"
+	"github.com/sandwichlabs/agent-memory-graph/internal/redact"
+	"github.com/sandwichlabs/agent-memory-graph/internal/retry"
+)
+
+// maxEventSize bounds a line of a streamed reply.
+const maxEventSize = 1 << 20
+
+// streamCall is how a streamed request is accounted for, like the requests of a
+// service's send: provider names it in metrics, which receives it when not nil,
+// and breaker, when not nil, fails it fast while the provider is down.
+type streamCall struct {
+	provider string
+	metrics  metrics.Sink
+	breaker  *CircuitBreaker
+}
+
+// streamText runs read in a goroutine and delivers what it emits as
+// GenerateTextStream does. emit reports false once ctx is cancelled, and read
+// should then return, with the usage the stream reported so far. The error
+// channel carries read's error, or ctx's when it was cancelled, and is closed
+// after the text channel.
+//
+// The stream is refused when the budget ctx carries is spent or call's breaker is
+// open, and is recorded as one "stream" request of call.provider, lasting until
+// read returns, charged its usage.
+func streamText(ctx context.Context, call streamCall, read func(emit func(string) bool) (Usage, error)) (<-chan string, <-chan error) {
+	pieces, errs := make(chan string), make(chan error, 1)
+	go func() {
+		defer close(errs)
+		defer close(pieces)
+		emit := func(piece string) bool {
+			select {
+			case pieces <- piece:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		if err := call.stream(ctx, read, emit); err != nil {
+			errs <- err
+		}
+	}()
+	return pieces, errs
+}
+
+// stream runs read through the budget, breaker and metrics of call.
+func (call streamCall) stream(ctx context.Context, read func(emit func(string) bool) (Usage, error), emit func(string) bool) (err error) {
+	if err := budget.FromContext(ctx).Check(); err != nil {
+		return err
+	}
+	var usage Usage
+	defer func(start time.Time) { recordCall(ctx, call.metrics, call.provider, "stream", start, usage, err) }(time.Now())
+	if err := call.breaker.Allow(); err != nil {
+		return err
+	}
+	defer func() { call.breaker.Record(ctx, err) }()
+	usage, err = read(emit)
+	if ctx.Err() != nil {
+		// The body read fails when the request's context is cancelled.
+		err = ctx.Err()
+	}
+	return err
+}
+
+// streamRequest creates a request posting payload to url and accepting a streamed
+// reply. Callers add their credentials.
+func streamRequest(ctx context.Context, url string, payload map[string]interface{}) (*http.Request, error) {
+	if model, _ := payload["model"].(string); model == "" {
+		return nil, checkModel(model)
+	}
+	requestBody, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal stream request body: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(requestBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stream request to %s: %w", url, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	return req, nil
+}
+
+// openStream sends req and returns the body of a successful reply. provider names
+// the API in errors, like "mistral API error: 429 Too Many Requests - ...".
+func openStream(client Doer, req *http.Request, provider string) (io.ReadCloser, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		slog.ErrorContext(req.Context(), "Failed to send stream request", "provider", provider, "error", err, "url", req.URL.String())
+		return nil, fmt.Errorf("failed to send stream request to %s: %w", req.URL, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		slog.ErrorContext(req.Context(), "Stream request failed", "provider", provider, "status_code", resp.StatusCode, redact.Body("response_body", string(bodyBytes)))
+		return nil, newAPIError(provider, "", resp, bodyBytes)
+	}
+	return resp.Body, nil
+}
+
+// transientStream reports whether opening a stream failed in a way worth
+// retrying: with a transient status, or without reaching the API.
+func transientStream(err error) bool {
+	if err == nil {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return retry.TransientStatus(apiErr.StatusCode)
+	}
+	return retry.TransientError(err)
+}
+
+// readEvents calls handle with the data of each server-sent event in body until
+// the data is "[DONE]", handle returns false or an error, or body ends.
+func readEvents(body io.Reader, handle func(data []byte) (bool, error)) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxEventSize)
+	var data [][]byte
+	dispatch := func() (bool, error) {
+		if len(data) == 0 {
+			return true, nil
+		}
+		event := bytes.Join(data, []byte("\n"))
+		data = nil
+		if string(event) == "[DONE]" {
+			return false, nil
+		}
+		return handle(event)
+	}
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			if more, err := dispatch(); !more || err != nil {
+				return err
+			}
+			continue
+		}
+		if value, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+			data = append(data, bytes.Clone(bytes.TrimPrefix(value, []byte(" "))))
+		}
+		// Event names, IDs, retry hints and comments are not needed.
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read stream: %w", err)
+	}
+	_, err := dispatch()
+	return err
+}
+
+// chatCompletionDeltas emits the content deltas of a streamed chat completion in
+// the format shared by Mistral and OpenAI, and returns the usage of the chunk that
+// reports it, the last one, when the API sends it.
+func chatCompletionDeltas(body io.Reader, emit func(string) bool) (Usage, error) {
+	var usage Usage
+	err := readEvents(body, func(data []byte) (bool, error) {
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+			} `json:"choices"`
+			Usage *chatCompletionUsage `json:"usage"`
+		}
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return false, fmt.Errorf("failed to decode stream event %q: %w", data, err)
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage.usage()
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content != "" && !emit(choice.Delta.Content) {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+	return usage, err
+}
@@ -0,0 +1,157 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// collect drains a stream, returning its pieces and error.
+func collect(pieces <-chan string, errs <-chan error) ([]string, error) {
+	var got []string
+	for piece := range pieces {
+		got = append(got, piece)
+	}
+	return got, <-errs
+}
+
+// writeEvents writes each data as a server-sent event, flushing after each.
+func writeEvents(w http.ResponseWriter, data ...string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	for _, d := range data {
+		fmt.Fprintf(w, "data: %s\n\n", d)
+		w.(http.Flusher).Flush()
+	}
+}
+
+func newMistralStreamService(t *testing.T, handler http.HandlerFunc) *MistralLlmService {
+	t.Helper()
+	server := mockMistralServer(handler)
+	t.Cleanup(server.Close)
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	service, err := NewMistralLlmService()
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	service.HTTPClient = server.Client()
+	service.APIBaseURL = server.URL
+	service.Retry = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	return service
+}
+
+func TestMistralLlmService_GenerateTextStream_Success(t *testing.T) {
+	var stream bool
+	service := newMistralStreamService(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		stream = strings.Contains(string(body), `"stream":true`)
+		writeEvents(w,
+			`{"choices":[{"delta":{"role":"assistant","content":""}}]}`,
+			`{"choices":[{"delta":{"content":"This is "}}]}`,
+			`{"choices":[{"delta":{"content":"a test response."},"finish_reason":"stop"}]}`,
+			"[DONE]",
+			`{"choices":[{"delta":{"content":"ignored"}}]}`,
+		)
+	})
+
+	pieces, err := collect(service.GenerateTextStream(context.Background(), "test prompt"))
+	if err != nil {
+		t.Fatalf("GenerateTextStream failed: %v", err)
+	}
+	if want := []string{"This is ", "a test response."}; strings.Join(pieces, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected pieces %q, got %q", want, pieces)
+	}
+	if !stream {
+		t.Error("Expected a streaming request")
+	}
+}
+
+func TestMistralLlmService_GenerateTextStream_Errors(t *testing.T) {
+	service := newMistralStreamService(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"Requests rate limit exceeded"}`, http.StatusTooManyRequests)
+	})
+	if _, err := collect(service.GenerateTextStream(context.Background(), "test prompt")); err == nil || !strings.Contains(err.Error(), "mistral API error: 429") {
+		t.Errorf("Expected error to contain 'mistral API error: 429', got: %v", err)
+	}
+
+	service = newMistralStreamService(t, func(w http.ResponseWriter, r *http.Request) {
+		writeEvents(w, `{"choices":[{"delta":{"content":"partial"}}]}`, `not json`)
+	})
+	pieces, err := collect(service.GenerateTextStream(context.Background(), "test prompt"))
+	if len(pieces) != 1 || err == nil || !strings.Contains(err.Error(), "failed to decode stream event") {
+		t.Errorf("Expected the partial piece and a decoding error, got %q and %v", pieces, err)
+	}
+}
+
+func TestMistralLlmService_GenerateTextStream_Cancel(t *testing.T) {
+	closed := make(chan struct{})
+	service := newMistralStreamService(t, func(w http.ResponseWriter, r *http.Request) {
+		writeEvents(w, `{"choices":[{"delta":{"content":"first"}}]}`)
+		<-r.Context().Done()
+		close(closed)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pieces, errs := service.GenerateTextStream(ctx, "test prompt")
+	if piece := <-pieces; piece != "first" {
+		t.Fatalf("Expected the first piece, got %q", piece)
+	}
+	cancel()
+
+	select {
+	case _, ok := <-pieces:
+		if ok {
+			t.Error("Expected no more pieces after cancelling")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the stream to stop promptly after cancelling")
+	}
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Error("Expected the request to be cancelled")
+	}
+}
+
+func TestAnthropicLlmService_GenerateTextStream(t *testing.T) {
+	service := newAnthropicTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "event: message_start\ndata: {\"type\":\"message_start\"}\n\n")
+		io.WriteString(w, "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"Hello\"}}\n\n")
+		io.WriteString(w, ": ping\n\nevent: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\" there\"}}\n\n")
+		io.WriteString(w, "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n")
+	})
+
+	pieces, err := collect(service.GenerateTextStream(context.Background(), "test prompt"))
+	if strings.Join(pieces, "") != "Hello there" {
+		t.Errorf("Expected the text deltas, got %q", pieces)
+	}
+	if err == nil || !strings.Contains(err.Error(), "overloaded_error") {
+		t.Errorf("Expected the error event to be reported, got %v", err)
+	}
+}
+
+func TestOllamaLlmService_GenerateTextStream(t *testing.T) {
+	service, _ := mockOllamaServer(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		io.WriteString(w, `{"message":{"role":"assistant","content":"Hello"},"done":false}`+"\n")
+		w.(http.Flusher).Flush()
+		io.WriteString(w, `{"message":{"role":"assistant","content":" there"},"done":false}`+"\n")
+		io.WriteString(w, `{"message":{"role":"assistant","content":""},"done":true}`+"\n")
+	})
+
+	pieces, err := collect(service.GenerateTextStream(context.Background(), "test prompt"))
+	if err != nil {
+		t.Fatalf("GenerateTextStream failed: %v", err)
+	}
+	if strings.Join(pieces, "|") != "Hello| there" {
+		t.Errorf("Expected the message contents, got %q", pieces)
+	}
+}
@@ -0,0 +1,125 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// ErrInvalidJSON is returned by GenerateJSON and GenerateStructured when the model
+// does not reply with valid JSON even after being asked again.
+var ErrInvalidJSON = errors.New("model did not reply with valid JSON")
+
+// Defaults of JSON and tool calls. Replies run longer than prose answers when they
+// list everything found in a text.
+const (
+	jsonTemperature = 0.2
+	jsonMaxTokens   = 1000
+)
+
+// correctionPrompt asks again after a reply that was not valid JSON, quoting the
+// original prompt, the parse error and the reply.
+const correctionPrompt = `%s
+
+Your previous reply could not be parsed (%v):
+%s
+
+Reply again with only the JSON object, without explanations or code fences.`
+
+// jsonGenerator is implemented by services whose API can be told to reply with a
+// JSON object, such as Mistral's json_object response format.
+type jsonGenerator interface {
+	generateJSON(ctx context.Context, prompt string, opts []GenerateOption) (string, Usage, error)
+}
+
+// GenerateJSON asks service for a JSON reply to prompt, which should describe the
+// JSON wanted. Providers with a JSON mode are asked to use it; for the others a
+// markdown code fence around the JSON is removed. A reply that is not valid JSON
+// is retried once with a corrective prompt, after which the error wraps
+// ErrInvalidJSON. The usage covers both attempts.
+func GenerateJSON(ctx context.Context, service LlmService, prompt string, opts ...GenerateOption) (json.RawMessage, Usage, error) {
+	var raw json.RawMessage
+	usage, err := generateValid(ctx, prompt, askJSON(service, opts), func(reply string) error {
+		// Decoding reports where the JSON goes wrong, which json.Valid does not.
+		var v any
+		if err := json.Unmarshal([]byte(reply), &v); err != nil {
+			return err
+		}
+		raw = json.RawMessage(reply)
+		return nil
+	})
+	return raw, usage, err
+}
+
+// GenerateStructured is GenerateJSON decoding the reply into out, which must be a
+// pointer. A reply that does not fit out, such as a string where out has a list,
+// is retried like invalid JSON.
+func GenerateStructured(ctx context.Context, service LlmService, prompt string, out any, opts ...GenerateOption) (Usage, error) {
+	return generateValid(ctx, prompt, askJSON(service, opts), func(reply string) error {
+		return json.Unmarshal([]byte(reply), out)
+	})
+}
+
+// askJSON returns a function asking service for a JSON reply, in its JSON mode
+// when it has one.
+func askJSON(service LlmService, opts []GenerateOption) func(ctx context.Context, request string) (string, Usage, error) {
+	return func(ctx context.Context, request string) (string, Usage, error) {
+		if generator, ok := service.(jsonGenerator); ok {
+			return generator.generateJSON(ctx, request, opts)
+		}
+		return service.GenerateTextWithUsage(ctx, request, opts...)
+	}
+}
+
+// generateValid asks for a JSON reply with ask until accept takes it, at most
+// twice. The error after the second attempt wraps ErrInvalidJSON and the error of
+// accept.
+func generateValid(ctx context.Context, prompt string, ask func(ctx context.Context, request string) (string, Usage, error), accept func(reply string) error) (Usage, error) {
+	var total Usage
+	request := prompt
+	for attempt := 1; ; attempt++ {
+		reply, usage, err := ask(ctx, request)
+		total = total.Add(usage)
+		if err != nil {
+			return total, err
+		}
+
+		reply = unfence(reply)
+		err = accept(reply)
+		if err == nil {
+			return total, nil
+		}
+		if attempt == 2 {
+			return total, fmt.Errorf("%w: %w", ErrInvalidJSON, err)
+		}
+		slog.WarnContext(ctx, "Asking again after a reply that was not valid JSON", "error", err, "reply_length", len(reply))
+		request = fmt.Sprintf(correctionPrompt, prompt, err, reply)
+	}
+}
+
+// unfence returns the JSON in reply, taking it out of a markdown code fence such
+// as ```json ... ``` when reply is not JSON as it stands.
+func unfence(reply string) string {
+	text := strings.TrimSpace(reply)
+	if json.Valid([]byte(text)) {
+		return text
+	}
+	start := strings.Index(text, "```")
+	if start < 0 {
+		return text
+	}
+	body := text[start+len("```"):]
+	// Skip the fence's info string, such as json.
+	if newline := strings.IndexByte(body, '\n'); newline >= 0 {
+		body = body[newline+1:]
+	} else {
+		body = strings.TrimPrefix(body, "json")
+	}
+	if end := strings.Index(body, "```"); end >= 0 {
+		body = body[:end]
+	}
+	return strings.TrimSpace(body)
+}
@@ -0,0 +1,121 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// newScriptedMistralService replies to successive chat completions with replies,
+// recording the prompt and response format of each request.
+func newScriptedMistralService(t *testing.T, replies ...string) (*MistralLlmService, *[]string, *[]string) {
+	t.Helper()
+	var prompts, formats []string
+	server := mockMistralServer(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+			ResponseFormat struct {
+				Type string `json:"type"`
+			} `json:"response_format"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		prompts = append(prompts, payload.Messages[0].Content)
+		formats = append(formats, payload.ResponseFormat.Type)
+		writeChoice(w, replies[len(prompts)-1])
+	})
+	t.Cleanup(server.Close)
+
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	service, err := NewMistralLlmService(WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("NewMistralLlmService failed: %v", err)
+	}
+	return service, &prompts, &formats
+}
+
+func TestGenerateJSON_ValidReply(t *testing.T) {
+	service, prompts, formats := newScriptedMistralService(t, `{"entities": []}`)
+
+	raw, _, err := GenerateJSON(context.Background(), service, "List the entities.")
+	if err != nil {
+		t.Fatalf("GenerateJSON failed: %v", err)
+	}
+	if string(raw) != `{"entities": []}` {
+		t.Errorf("Expected the reply unchanged, got %s", raw)
+	}
+	if len(*prompts) != 1 || (*formats)[0] != "json_object" {
+		t.Errorf("Expected one request in JSON mode, got formats %q", *formats)
+	}
+}
+
+func TestGenerateJSON_FencedReply(t *testing.T) {
+	// Anthropic has no JSON mode, so its replies are taken as text.
+	service := newAnthropicTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		writeMessage(w, "Here you go:\n```json\n{\"entities\": [{\"name\": \"Kuzu\"}]}\n```\n")
+	})
+
+	var out struct {
+		Entities []struct {
+			Name string `json:"name"`
+		} `json:"entities"`
+	}
+	if _, err := GenerateStructured(context.Background(), service, "List the entities.", &out); err != nil {
+		t.Fatalf("GenerateStructured failed: %v", err)
+	}
+	if len(out.Entities) != 1 || out.Entities[0].Name != "Kuzu" {
+		t.Errorf("Expected the fenced JSON decoded, got %+v", out)
+	}
+}
+
+func TestGenerateJSON_CorrectsInvalidReply(t *testing.T) {
+	service, prompts, _ := newScriptedMistralService(t, `{"entities": [`, `{"entities": []}`)
+
+	raw, _, err := GenerateJSON(context.Background(), service, "List the entities.")
+	if err != nil || string(raw) != `{"entities": []}` {
+		t.Fatalf("Expected the corrected reply, got %s and %v", raw, err)
+	}
+	if len(*prompts) != 2 || !strings.HasPrefix((*prompts)[1], "List the entities.") || !strings.Contains((*prompts)[1], `{"entities": [`) {
+		t.Errorf("Expected a corrective prompt quoting the prompt and bad reply, got %q", *prompts)
+	}
+}
+
+func TestGenerateJSON_Garbage(t *testing.T) {
+	service, prompts, _ := newScriptedMistralService(t, "I cannot help with that.", "Still no JSON, sorry.")
+
+	_, _, err := GenerateJSON(context.Background(), service, "List the entities.")
+	if !errors.Is(err, ErrInvalidJSON) {
+		t.Errorf("Expected ErrInvalidJSON, got %v", err)
+	}
+	if len(*prompts) != 2 {
+		t.Errorf("Expected exactly one retry, got %d requests", len(*prompts))
+	}
+
+	// A reply that is JSON but not of the wanted shape is retried too.
+	service, prompts, _ = newScriptedMistralService(t, `{"entities": "Kuzu"}`, `{"entities": "Kuzu"}`)
+	var out struct {
+		Entities []string `json:"entities"`
+	}
+	if _, err := GenerateStructured(context.Background(), service, "List the entities.", &out); !errors.Is(err, ErrInvalidJSON) || len(*prompts) != 2 {
+		t.Errorf("Expected ErrInvalidJSON after a retry, got %v after %d requests", err, len(*prompts))
+	}
+}
+
+func TestGenerateJSON_ProviderError(t *testing.T) {
+	server := mockMistralServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, "Unauthorized")
+	})
+	defer server.Close()
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	service, _ := NewMistralLlmService(WithBaseURL(server.URL))
+
+	if _, _, err := GenerateJSON(context.Background(), service, "List the entities."); err == nil || errors.Is(err, ErrInvalidJSON) || !strings.Contains(err.Error(), "(json)") {
+		t.Errorf("Expected the API error, got %v", err)
+	}
+}
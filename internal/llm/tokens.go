@@ -0,0 +1,127 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// charsPerWordToken is how many letters of a word one token covers after its
+// first, on average, in the providers' tokenizers.
+const charsPerWordToken = 5
+
+// tokenizerFactors scale the estimate of CountTokens, in percent, for model
+// families whose tokenizers split text finer than the others, by model name
+// prefix.
+var tokenizerFactors = []struct {
+	prefix string
+	factor int
+}{
+	{"mistral", 115},
+	{"open-mistral", 115},
+	{"ministral", 115},
+	{"pixtral", 115},
+	{"codestral", 115},
+	{"claude", 110},
+}
+
+// CountTokens estimates how many tokens text is for model, such as
+// "mistral-small-latest", to check it fits the model's context window before
+// sending it. No tokenizer is bundled, so the count is an approximation: each run
+// of letters is a token, plus one per further charsPerWordToken letters; each run
+// of up to three digits and each punctuation mark or symbol is a token; each
+// Chinese, Japanese or Korean character is a token; and whitespace is free.
+// Models whose tokenizers split text finer are scaled up. The error reports an
+// empty model.
+func CountTokens(model, text string) (int, error) {
+	if model == "" {
+		return 0, fmt.Errorf("model must not be empty")
+	}
+	tokens := approximateTokens(text)
+	for _, family := range tokenizerFactors {
+		if strings.HasPrefix(model, family.prefix) {
+			return (tokens*family.factor + 99) / 100, nil
+		}
+	}
+	return tokens, nil
+}
+
+// approximateTokens counts the tokens of text as CountTokens documents, before
+// scaling.
+func approximateTokens(text string) int {
+	runes := []rune(text)
+	tokens := 0
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		run := func(in func(rune) bool) int {
+			j := i
+			for j < len(runes) && in(runes[j]) {
+				j++
+			}
+			n := j - i
+			i = j
+			return n
+		}
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case ideographic(r):
+			tokens++
+			i++
+		case unicode.IsLetter(r):
+			n := run(func(r rune) bool { return unicode.IsLetter(r) && !ideographic(r) })
+			tokens += 1 + (n-1)/charsPerWordToken
+		case unicode.IsDigit(r):
+			tokens += (run(unicode.IsDigit) + 2) / 3
+		default:
+			tokens++
+			i++
+		}
+	}
+	return tokens
+}
+
+// ideographic reports whether r is of a script written without spaces between
+// words, whose characters tokenizers mostly take one at a time.
+func ideographic(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// contextWindows are the context windows of the providers' models, in tokens, by
+// model name prefix; the longest matching prefix wins.
+var contextWindows = map[string]int{
+	"mistral-small":   128_000,
+	"mistral-medium":  128_000,
+	"mistral-large":   128_000,
+	"ministral":       128_000,
+	"pixtral":         128_000,
+	"open-mistral-7b": 32_000,
+	"codestral":       256_000,
+	"gpt-4o":          128_000,
+	"gpt-4.1":         1_047_576,
+	"claude":          200_000,
+	"gemini-1.5-pro":  2_097_152,
+	"gemini":          1_048_576,
+	"llama3":          128_000,
+}
+
+// MaxContextTokens returns the context window of model in tokens, the most a
+// prompt and its reply together may use, and whether model is known.
+func MaxContextTokens(model string) (int, bool) {
+	best := ""
+	for prefix := range contextWindows {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	if best == "" {
+		return 0, false
+	}
+	return contextWindows[best], true
+}
+
+// ChatModel returns the provider of service and the model it generates text
+// with, looking through caches, or empty strings for services it does not know.
+func ChatModel(service LlmService) (Provider, string) {
+	return serviceChatModel(service)
+}
@@ -0,0 +1,56 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCountTokens(t *testing.T) {
+	tests := []struct {
+		model string
+		text  string
+		want  int
+	}{
+		{"gpt-4o-mini", "", 0},
+		{"gpt-4o-mini", "Hello, world!", 4},
+		{"gpt-4o-mini", "The quick brown fox jumps over the lazy dog.", 10},
+		{"gpt-4o-mini", "internationalization", 4},
+		{"gpt-4o-mini", "Order 1234567 shipped on 2025-01-31.", 14},
+		{"gpt-4o-mini", "東京タワー", 5},
+		{"gpt-4o-mini", "Ada Lovelace (1815–1852) wrote the first algorithm.", 16},
+		{"mistral-small-latest", "The quick brown fox jumps over the lazy dog.", 12},
+		{"claude-haiku-4-5", "The quick brown fox jumps over the lazy dog.", 11},
+		{"llama3.2", strings.Repeat("word ", 1000), 1000},
+	}
+	for _, tt := range tests {
+		got, err := CountTokens(tt.model, tt.text)
+		if err != nil {
+			t.Fatalf("CountTokens failed: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("Expected %d tokens for %q on %s, got %d", tt.want, tt.text, tt.model, got)
+		}
+	}
+	if _, err := CountTokens("", "text"); err == nil {
+		t.Errorf("Expected an empty model to be rejected")
+	}
+}
+
+func TestMaxContextTokens(t *testing.T) {
+	tests := map[string]int{
+		"mistral-small-latest":  128_000,
+		"open-mistral-7b":       32_000,
+		"gpt-4o-mini":           128_000,
+		"claude-sonnet-4-5":     200_000,
+		"gemini-2.5-flash":      1_048_576,
+		"gemini-1.5-pro-latest": 2_097_152,
+	}
+	for model, want := range tests {
+		if got, ok := MaxContextTokens(model); !ok || got != want {
+			t.Errorf("Expected %s to have a context of %d tokens, got %d, %v", model, want, got, ok)
+		}
+	}
+	if _, ok := MaxContextTokens("unreleased-model"); ok {
+		t.Errorf("Expected an unknown model to be reported as unknown")
+	}
+}
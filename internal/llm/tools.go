@@ -0,0 +1,73 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// ToolDefinition describes a function the model may call instead of answering in
+// text.
+type ToolDefinition struct {
+	Name        string
+	Description string
+	// Parameters is the JSON schema of the call's arguments, an object.
+	Parameters json.RawMessage
+}
+
+// ToolCallResult is the model's answer to GenerateWithTools: either a call to one
+// of the tools or, when the model chose not to call any, text.
+type ToolCallResult struct {
+	// Tool is the name of the called tool; empty when the model answered in text.
+	Tool string
+	// Arguments is the JSON object of arguments to the call.
+	Arguments json.RawMessage
+	Text      string
+	Usage     Usage
+}
+
+// Called reports whether the model called a tool rather than answering in text.
+func (r ToolCallResult) Called() bool {
+	return r.Tool != ""
+}
+
+// ToolCaller is implemented by LLM services that support function calling.
+type ToolCaller interface {
+	// GenerateWithTools answers prompt, offering the model tools to call. It
+	// returns the first call the model makes, or its text when it makes none.
+	GenerateWithTools(ctx context.Context, prompt string, tools []ToolDefinition, opts ...GenerateOption) (ToolCallResult, error)
+}
+
+// toolsPayload describes tools in the tools array of a chat completion request.
+func toolsPayload(tools []ToolDefinition) []map[string]interface{} {
+	payload := make([]map[string]interface{}, 0, len(tools))
+	for _, tool := range tools {
+		parameters := tool.Parameters
+		if len(parameters) == 0 {
+			parameters = json.RawMessage(`{"type": "object", "properties": {}}`)
+		}
+		payload = append(payload, map[string]interface{}{
+			"type": "function",
+			"function": map[string]interface{}{
+				"name":        tool.Name,
+				"description": tool.Description,
+				"parameters":  parameters,
+			},
+		})
+	}
+	return payload
+}
+
+// toolArguments returns the arguments of a tool call as a JSON object, decoding
+// them from the string they are usually sent in.
+func toolArguments(tool string, raw json.RawMessage) (json.RawMessage, error) {
+	var encoded string
+	if err := json.Unmarshal(raw, &encoded); err == nil {
+		raw = json.RawMessage(encoded)
+	}
+	var arguments map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &arguments); err != nil {
+		return nil, fmt.Errorf("invalid arguments for tool %s: %w", tool, err)
+	}
+	return raw, nil
+}
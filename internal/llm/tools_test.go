@@ -0,0 +1,79 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+var weatherTool = ToolDefinition{
+	Name:        "get_weather",
+	Description: "Look up the weather in a city.",
+	Parameters:  json.RawMessage(`{"type": "object", "properties": {"city": {"type": "string"}}, "required": ["city"]}`),
+}
+
+func TestMistralLlmService_GenerateWithTools(t *testing.T) {
+	var payload struct {
+		Tools []struct {
+			Type     string `json:"type"`
+			Function struct {
+				Name       string          `json:"name"`
+				Parameters json.RawMessage `json:"parameters"`
+			} `json:"function"`
+		} `json:"tools"`
+		ToolChoice string `json:"tool_choice"`
+	}
+	reply := ""
+	server := mockMistralServer(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, reply)
+	})
+	defer server.Close()
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	service, err := NewMistralLlmService(WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("NewMistralLlmService failed: %v", err)
+	}
+
+	reply = `{"choices": [{"message": {"content": "", "tool_calls": [{"id": "call_1", "function": {"name": "get_weather", "arguments": "{\"city\": \"Paris\"}"}}]}}],
+		"usage": {"prompt_tokens": 40, "completion_tokens": 8, "total_tokens": 48}}`
+	result, err := service.GenerateWithTools(context.Background(), "Is it raining in Paris?", []ToolDefinition{weatherTool})
+	if err != nil {
+		t.Fatalf("GenerateWithTools failed: %v", err)
+	}
+	if !result.Called() || result.Tool != "get_weather" || string(result.Arguments) != `{"city": "Paris"}` || result.Usage.TotalTokens != 48 {
+		t.Errorf("Expected a get_weather call for Paris, got %+v", result)
+	}
+	if len(payload.Tools) != 1 || payload.Tools[0].Type != "function" || payload.Tools[0].Function.Name != "get_weather" ||
+		!strings.Contains(string(payload.Tools[0].Function.Parameters), `"city"`) || payload.ToolChoice != "auto" {
+		t.Errorf("Expected the tool offered as a function, got %+v", payload)
+	}
+
+	// Some models send the arguments as an object rather than a string.
+	reply = `{"choices": [{"message": {"tool_calls": [{"function": {"name": "get_weather", "arguments": {"city": "Lyon"}}}]}}]}`
+	if result, err = service.GenerateWithTools(context.Background(), "And Lyon?", []ToolDefinition{weatherTool}); err != nil || string(result.Arguments) != `{"city": "Lyon"}` {
+		t.Errorf("Expected the object arguments, got %+v and %v", result, err)
+	}
+
+	reply = `{"choices": [{"message": {"content": "I don't need a tool for that."}}]}`
+	result, err = service.GenerateWithTools(context.Background(), "Hello", []ToolDefinition{weatherTool})
+	if err != nil {
+		t.Fatalf("Expected a text answer to succeed, got %v", err)
+	}
+	if result.Called() || result.Text != "I don't need a tool for that." {
+		t.Errorf("Expected a text answer without a call, got %+v", result)
+	}
+
+	reply = `{"choices": [{"message": {"tool_calls": [{"function": {"name": "get_weather", "arguments": "{\"city\": "}}]}}]}`
+	if _, err = service.GenerateWithTools(context.Background(), "Paris?", []ToolDefinition{weatherTool}); err == nil || !strings.Contains(err.Error(), "invalid arguments for tool get_weather") {
+		t.Errorf("Expected an invalid arguments error, got %v", err)
+	}
+
+	if _, err = service.GenerateWithTools(context.Background(), "Paris?", nil); err == nil {
+		t.Error("Expected an error without tools")
+	}
+}
@@ -0,0 +1,34 @@
+package llm
+
+// Usage counts the tokens of a request and its response as reported by the
+// provider, which is what it bills for. It is zero when the provider does not
+// report usage.
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+}
+
+// Add returns the combined usage of u and other.
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		PromptTokens:     u.PromptTokens + other.PromptTokens,
+		CompletionTokens: u.CompletionTokens + other.CompletionTokens,
+		TotalTokens:      u.TotalTokens + other.TotalTokens,
+	}
+}
+
+// chatCompletionUsage is the usage object of Mistral and OpenAI chat completions.
+type chatCompletionUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+func (u chatCompletionUsage) usage() Usage {
+	return Usage{PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens, TotalTokens: u.TotalTokens}
+}
+
+// finishLength is the finish_reason of a Mistral or OpenAI chat completion choice
+// cut off at max_tokens.
+const finishLength = "length"
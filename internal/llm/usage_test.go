@@ -0,0 +1,50 @@
+package llm
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestGenerateTextWithUsage(t *testing.T) {
+	reply := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, body)
+		}
+	}
+	chatCompletion := `{"choices": [{"message": {"content": "ok"}}], "usage": {"prompt_tokens": 12, "completion_tokens": 3, "total_tokens": 15}}`
+	want := Usage{PromptTokens: 12, CompletionTokens: 3, TotalTokens: 15}
+
+	t.Setenv("MISTRAL_API_KEY", "test_api_key")
+	mistral := mockMistralServer(reply(chatCompletion))
+	defer mistral.Close()
+	mistralService, err := NewMistralLlmService(WithBaseURL(mistral.URL))
+	if err != nil {
+		t.Fatalf("NewMistralLlmService failed: %v", err)
+	}
+	ollama, _ := mockOllamaServer(t, nil, reply(`{"message": {"role": "assistant", "content": "ok"}, "done": true, "prompt_eval_count": 12, "eval_count": 3}`))
+
+	services := map[Provider]LlmService{
+		ProviderMistral:   mistralService,
+		ProviderOpenAI:    newOpenAITestService(t, reply(chatCompletion)),
+		ProviderAnthropic: newAnthropicTestService(t, reply(`{"content": [{"type": "text", "text": "ok"}], "usage": {"input_tokens": 12, "output_tokens": 3}}`)),
+		ProviderOllama:    ollama,
+		ProviderGemini: newGeminiTestService(t, "gemini-test", reply(`{"candidates": [{"content": {"role": "model", "parts": [{"text": "ok"}]}}],
+			"usageMetadata": {"promptTokenCount": 12, "candidatesTokenCount": 3, "totalTokenCount": 15}}`)),
+	}
+	for provider, service := range services {
+		text, usage, err := service.GenerateTextWithUsage(context.Background(), "test prompt")
+		if err != nil || text != "ok" {
+			t.Errorf("%s: expected the reply, got %q and %v", provider, text, err)
+		}
+		if usage != want {
+			t.Errorf("%s: expected usage %+v, got %+v", provider, want, usage)
+		}
+	}
+
+	if got := want.Add(want); got != (Usage{PromptTokens: 24, CompletionTokens: 6, TotalTokens: 30}) {
+		t.Errorf("Expected Add to sum each count, got %+v", got)
+	}
+}
@@ -0,0 +1,210 @@
+// Package metrics counts the calls made to provider APIs and measures their
+// latency, so that slow runs can be traced to the provider or to the database.
+package metrics
+
+import (
+	"expvar"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Call is the measurement of one call to a provider's API.
+type Call struct {
+	// Provider names the API, such as "mistral".
+	Provider string
+	// Method names the kind of call, such as "chat" or "multimodal".
+	Method   string
+	Duration time.Duration
+	// ErrorClass is empty for a call that succeeded, and otherwise names the
+	// class of its failure, such as "rate_limited".
+	ErrorClass       string
+	PromptTokens     int
+	CompletionTokens int
+	// Texts and Bytes measure the texts sent to an embedding API.
+	Texts int
+	Bytes int
+	// CacheHits and CacheMisses count the texts of a call to a cache found in it
+	// and those passed on to the provider.
+	CacheHits   int
+	CacheMisses int
+}
+
+// Sink receives the measurements of calls. Implementations may aggregate them in
+// memory, as Registry does, or forward them to a monitoring system such as
+// Prometheus; they must be safe for concurrent use.
+type Sink interface {
+	RecordCall(call Call)
+}
+
+// Default is the registry the LLM services record to unless given another.
+var Default = NewRegistry()
+
+// Embeddings is the registry the embedding services record to unless given
+// another, apart from Default so that LLM and embedding calls are reported apart.
+var Embeddings = NewRegistry()
+
+// latencyWindow is how many of the latest latencies of a method Registry keeps
+// to compute percentiles.
+const latencyWindow = 1024
+
+// Registry is a Sink aggregating calls in memory by provider and method.
+type Registry struct {
+	mu      sync.Mutex
+	methods map[methodKey]*methodStats
+}
+
+type methodKey struct{ provider, method string }
+
+type methodStats struct {
+	requests         int
+	errors           map[string]int
+	latencies        []time.Duration // ring of the latest latencyWindow
+	next             int
+	total            time.Duration
+	promptTokens     int
+	completionTokens int
+	texts            int
+	bytes            int
+	cacheHits        int
+	cacheMisses      int
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{methods: make(map[methodKey]*methodStats)}
+}
+
+// RecordCall adds call to the statistics of its provider and method.
+func (r *Registry) RecordCall(call Call) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := methodKey{call.Provider, call.Method}
+	stats, ok := r.methods[key]
+	if !ok {
+		stats = &methodStats{errors: make(map[string]int)}
+		r.methods[key] = stats
+	}
+	stats.requests++
+	if call.ErrorClass != "" {
+		stats.errors[call.ErrorClass]++
+	}
+	if len(stats.latencies) < latencyWindow {
+		stats.latencies = append(stats.latencies, call.Duration)
+	} else {
+		stats.latencies[stats.next] = call.Duration
+		stats.next = (stats.next + 1) % latencyWindow
+	}
+	stats.total += call.Duration
+	stats.promptTokens += call.PromptTokens
+	stats.completionTokens += call.CompletionTokens
+	stats.texts += call.Texts
+	stats.bytes += call.Bytes
+	stats.cacheHits += call.CacheHits
+	stats.cacheMisses += call.CacheMisses
+}
+
+// MethodStats are the aggregated calls of one method of a provider.
+type MethodStats struct {
+	Provider string `json:"provider"`
+	Method   string `json:"method"`
+	Requests int    `json:"requests"`
+	Errors   int    `json:"errors"`
+	// ErrorsByClass counts the failed calls by ErrorClass.
+	ErrorsByClass map[string]int `json:"errors_by_class,omitempty"`
+	// P50 and P95 are percentiles of the latest calls' latency, and Total the
+	// time spent in every call.
+	P50              time.Duration `json:"p50_ns"`
+	P95              time.Duration `json:"p95_ns"`
+	Total            time.Duration `json:"total_ns"`
+	PromptTokens     int           `json:"prompt_tokens"`
+	CompletionTokens int           `json:"completion_tokens"`
+	Texts            int           `json:"texts,omitempty"`
+	Bytes            int           `json:"bytes,omitempty"`
+	CacheHits        int           `json:"cache_hits,omitempty"`
+	CacheMisses      int           `json:"cache_misses,omitempty"`
+}
+
+// CacheHitRate returns the share of the texts looked up in a cache that were
+// found in it, or 0 when none were.
+func (s MethodStats) CacheHitRate() float64 {
+	if s.CacheHits+s.CacheMisses == 0 {
+		return 0
+	}
+	return float64(s.CacheHits) / float64(s.CacheHits+s.CacheMisses)
+}
+
+// Snapshot returns the statistics of every method called so far, ordered by
+// provider and method.
+func (r *Registry) Snapshot() []MethodStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	snapshot := make([]MethodStats, 0, len(r.methods))
+	for key, stats := range r.methods {
+		s := MethodStats{
+			Provider:         key.provider,
+			Method:           key.method,
+			Requests:         stats.requests,
+			Total:            stats.total,
+			PromptTokens:     stats.promptTokens,
+			CompletionTokens: stats.completionTokens,
+			Texts:            stats.texts,
+			Bytes:            stats.bytes,
+			CacheHits:        stats.cacheHits,
+			CacheMisses:      stats.cacheMisses,
+		}
+		if len(stats.errors) > 0 {
+			s.ErrorsByClass = make(map[string]int, len(stats.errors))
+			for class, count := range stats.errors {
+				s.ErrorsByClass[class] = count
+				s.Errors += count
+			}
+		}
+		sorted := append([]time.Duration(nil), stats.latencies...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		s.P50, s.P95 = percentile(sorted, 50), percentile(sorted, 95)
+		snapshot = append(snapshot, s)
+	}
+	sort.Slice(snapshot, func(i, j int) bool {
+		if snapshot[i].Provider != snapshot[j].Provider {
+			return snapshot[i].Provider < snapshot[j].Provider
+		}
+		return snapshot[i].Method < snapshot[j].Method
+	})
+	return snapshot
+}
+
+// Reset forgets every call recorded so far.
+func (r *Registry) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.methods = make(map[methodKey]*methodStats)
+}
+
+// Publish exposes the snapshot of r as the expvar variable name, served by
+// expvar's handler at /debug/vars. Publishing a name again does nothing, as
+// expvar does not allow replacing a variable.
+func (r *Registry) Publish(name string) {
+	publishMu.Lock()
+	defer publishMu.Unlock()
+	if expvar.Get(name) != nil {
+		return
+	}
+	expvar.Publish(name, expvar.Func(func() any { return r.Snapshot() }))
+}
+
+// publishMu serializes Publish, as expvar.Publish panics on a name already taken.
+var publishMu sync.Mutex
+
+// percentile returns the p-th percentile of sorted by the nearest-rank method, or
+// zero when it is empty.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRegistry_Snapshot(t *testing.T) {
+	r := NewRegistry()
+	for n := 1; n <= 20; n++ {
+		r.RecordCall(Call{Provider: "mistral", Method: "chat", Duration: time.Duration(n) * time.Millisecond, PromptTokens: 10, CompletionTokens: 2})
+	}
+	r.RecordCall(Call{Provider: "mistral", Method: "chat", Duration: time.Second, ErrorClass: "rate_limited"})
+	r.RecordCall(Call{Provider: "mistral", Method: "chat", Duration: time.Second, ErrorClass: "rate_limited"})
+	r.RecordCall(Call{Provider: "anthropic", Method: "multimodal", Duration: time.Second, ErrorClass: "timeout"})
+
+	snapshot := r.Snapshot()
+	if len(snapshot) != 2 || snapshot[0].Provider != "anthropic" || snapshot[1].Provider != "mistral" {
+		t.Fatalf("Expected anthropic then mistral, got %+v", snapshot)
+	}
+	chat := snapshot[1]
+	if chat.Requests != 22 || chat.Errors != 2 || chat.ErrorsByClass["rate_limited"] != 2 {
+		t.Errorf("Expected 22 requests and 2 rate_limited errors, got %+v", chat)
+	}
+	if chat.P50 != 11*time.Millisecond || chat.P95 != time.Second {
+		t.Errorf("Expected p50 11ms and p95 1s, got %s and %s", chat.P50, chat.P95)
+	}
+	if chat.PromptTokens != 200 || chat.CompletionTokens != 40 {
+		t.Errorf("Expected 200 tokens in and 40 out, got %d and %d", chat.PromptTokens, chat.CompletionTokens)
+	}
+	if multimodal := snapshot[0]; multimodal.Requests != 1 || multimodal.ErrorsByClass["timeout"] != 1 {
+		t.Errorf("Expected 1 timed out request, got %+v", multimodal)
+	}
+
+	r.Reset()
+	if snapshot := r.Snapshot(); len(snapshot) != 0 {
+		t.Errorf("Expected no statistics after Reset, got %+v", snapshot)
+	}
+}
+
+func TestRegistry_LatencyWindow(t *testing.T) {
+	r := NewRegistry()
+	for range latencyWindow {
+		r.RecordCall(Call{Provider: "ollama", Method: "chat", Duration: time.Hour})
+	}
+	for range latencyWindow {
+		r.RecordCall(Call{Provider: "ollama", Method: "chat", Duration: time.Millisecond})
+	}
+	calls := r.Snapshot()[0]
+	if calls.P95 != time.Millisecond || calls.Requests != 2*latencyWindow {
+		t.Errorf("Expected percentiles over the latest %d requests, got %+v", latencyWindow, calls)
+	}
+}
+
+func TestRegistry_EmbeddingFields(t *testing.T) {
+	r := NewRegistry()
+	r.RecordCall(Call{Provider: "mistral", Method: "cache", Texts: 4, Bytes: 40, CacheHits: 3, CacheMisses: 1})
+	r.RecordCall(Call{Provider: "mistral", Method: "cache", Texts: 4, Bytes: 40, CacheHits: 1, CacheMisses: 3})
+	r.RecordCall(Call{Provider: "mistral", Method: "embed", Texts: 4, Bytes: 40})
+
+	snapshot := r.Snapshot()
+	if len(snapshot) != 2 || snapshot[0].Method != "cache" || snapshot[1].Method != "embed" {
+		t.Fatalf("Expected cache then embed, got %+v", snapshot)
+	}
+	cache := snapshot[0]
+	if cache.Texts != 8 || cache.Bytes != 80 || cache.CacheHits != 4 || cache.CacheMisses != 4 {
+		t.Errorf("Expected 8 texts of 80 bytes with 4 hits and 4 misses, got %+v", cache)
+	}
+	if rate := cache.CacheHitRate(); rate != 0.5 {
+		t.Errorf("Expected a hit rate of 0.5, got %v", rate)
+	}
+	if rate := snapshot[1].CacheHitRate(); rate != 0 {
+		t.Errorf("Expected a hit rate of 0 without lookups, got %v", rate)
+	}
+}
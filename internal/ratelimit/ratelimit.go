@@ -0,0 +1,104 @@
+// Package ratelimit spaces out calls to rate-limited provider APIs.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket that allows a steady number of requests per second,
+// with bursts of up to its burst size after idle periods. A nil *Limiter does not
+// limit anything.
+type Limiter struct {
+	rps   float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// New creates a limiter for rps requests per second, allowing burst requests at
+// once. A burst below 1 is taken as 1.
+func New(rps float64, burst int) *Limiter {
+	b := math.Max(float64(burst), 1)
+	return &Limiter{rps: rps, burst: b, tokens: b}
+}
+
+var (
+	sharedMu sync.Mutex
+	shared   = map[string]*Limiter{}
+)
+
+// FromEnv returns the limiter for the requests per second in the environment
+// variable key, such as MISTRAL_RPS, or nil when it is unset. Callers reading the
+// same variable and value share one limiter, so that every client of an account
+// draws from the same budget.
+func FromEnv(key string) (*Limiter, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil, nil
+	}
+	rps, err := strconv.ParseFloat(value, 64)
+	if err != nil || rps <= 0 || math.IsInf(rps, 0) {
+		return nil, fmt.Errorf("invalid %s %q: must be a positive number of requests per second", key, value)
+	}
+
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	id := key + "=" + value
+	if l, ok := shared[id]; ok {
+		return l, nil
+	}
+	l := New(rps, 1)
+	shared[id] = l
+	return l, nil
+}
+
+// Wait blocks until a request may be made, or returns ctx's error when ctx is done
+// first.
+func (l *Limiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return ctx.Err()
+	}
+	delay := l.reserve(time.Now())
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.cancel()
+		return ctx.Err()
+	}
+}
+
+// reserve takes a token at now and returns how long to wait until it is due.
+func (l *Limiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.last.IsZero() {
+		l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rps)
+	}
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rps * float64(time.Second))
+}
+
+// cancel returns the token of an abandoned reservation.
+func (l *Limiter) cancel() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens = math.Min(l.burst, l.tokens+1)
+}
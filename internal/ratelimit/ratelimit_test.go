@@ -0,0 +1,80 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimiter_SpacesRequests(t *testing.T) {
+	limiter := New(2, 1)
+	start := time.Unix(0, 0)
+
+	if delay := limiter.reserve(start); delay != 0 {
+		t.Errorf("Expected the first request to go at once, got a wait of %v", delay)
+	}
+	if delay := limiter.reserve(start); delay != 500*time.Millisecond {
+		t.Errorf("Expected the second request to wait 500ms, got %v", delay)
+	}
+	if delay := limiter.reserve(start.Add(100 * time.Millisecond)); delay != 900*time.Millisecond {
+		t.Errorf("Expected the third request to queue behind the second, got %v", delay)
+	}
+	// After a long idle period only the burst is available.
+	later := start.Add(time.Hour)
+	if delay := limiter.reserve(later); delay != 0 {
+		t.Errorf("Expected a request after idling to go at once, got %v", delay)
+	}
+	if delay := limiter.reserve(later); delay != 500*time.Millisecond {
+		t.Errorf("Expected the burst to be capped at 1, got a wait of %v", delay)
+	}
+}
+
+func TestLimiter_Wait(t *testing.T) {
+	limiter := New(20, 1)
+	start := time.Now()
+	for range 2 {
+		if err := limiter.Wait(context.Background()); err != nil {
+			t.Fatalf("Wait failed: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 45*time.Millisecond {
+		t.Errorf("Expected two rapid calls to be spaced by 50ms, took %v", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	slow := New(0.01, 1)
+	slow.Wait(ctx)
+	if err := slow.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the wait to end with the context, got %v", err)
+	}
+
+	var unlimited *Limiter
+	if err := unlimited.Wait(context.Background()); err != nil {
+		t.Errorf("Expected a nil limiter not to block, got %v", err)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("TEST_RPS", "")
+	if limiter, err := FromEnv("TEST_RPS"); limiter != nil || err != nil {
+		t.Errorf("Expected no limiter when unset, got %v and %v", limiter, err)
+	}
+
+	t.Setenv("TEST_RPS", "1.5")
+	first, err := FromEnv("TEST_RPS")
+	if err != nil || first == nil || first.rps != 1.5 {
+		t.Fatalf("Expected a 1.5 rps limiter, got %+v and %v", first, err)
+	}
+	if second, _ := FromEnv("TEST_RPS"); second != first {
+		t.Error("Expected callers of the same variable to share a limiter")
+	}
+
+	for _, value := range []string{"fast", "0", "-1"} {
+		t.Setenv("TEST_RPS", value)
+		if _, err := FromEnv("TEST_RPS"); err == nil {
+			t.Errorf("Expected an error for TEST_RPS=%q", value)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+// Package redact keeps the content of documents, which may hold customer data, out
+// of logs. Content is only logged at debug level, with Content; response bodies
+// logged on errors go through Body, which truncates them. Setting AMG_LOG_REDACT
+// to true replaces both with a hash, which still tells identical content apart.
+package redact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"unicode/utf8"
+)
+
+// EnvRedact is the environment variable that, when true, hashes logged content.
+const EnvRedact = "AMG_LOG_REDACT"
+
+// MaxBodyBytes is how much of a response body Body keeps.
+const MaxBodyBytes = 512
+
+// Enabled reports whether AMG_LOG_REDACT asks for content to be hashed.
+func Enabled() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv(EnvRedact))
+	return enabled
+}
+
+// Content returns an attribute logging text as is, or its hash when redacting. Log
+// it at debug level only.
+func Content(key, text string) slog.Attr {
+	if Enabled() {
+		return slog.String(key, Hash(text))
+	}
+	return slog.String(key, text)
+}
+
+// Body returns an attribute logging the first MaxBodyBytes of a response body, or
+// its hash when redacting.
+func Body(key, body string) slog.Attr {
+	if Enabled() {
+		return slog.String(key, Hash(body))
+	}
+	return slog.String(key, Truncate(body, MaxBodyBytes))
+}
+
+// Hash describes text by its length and a prefix of its SHA-256, like
+// "sha256:2cf24dba5fb0 (5 bytes)".
+func Hash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return fmt.Sprintf("sha256:%s (%d bytes)", hex.EncodeToString(sum[:6]), len(text))
+}
+
+// Truncate returns text cut to at most maxBytes, on a character boundary, noting
+// the length of the whole when it cuts.
+func Truncate(text string, maxBytes int) string {
+	if len(text) <= maxBytes {
+		return text
+	}
+	cut := maxBytes
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s... (%d bytes)", text[:cut], len(text))
+}
@@ -0,0 +1,46 @@
+package redact
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestTruncate(t *testing.T) {
+	if got := Truncate("short", 10); got != "short" {
+		t.Errorf("Expected short text unchanged, got %q", got)
+	}
+	if got := Truncate("0123456789abc", 10); got != "0123456789... (13 bytes)" {
+		t.Errorf("Expected the text cut to 10 bytes, got %q", got)
+	}
+	// "é" is two bytes; cutting inside it keeps the whole character out.
+	if got := Truncate("aaaaaaaaaé", 10); got != "aaaaaaaaa... (11 bytes)" {
+		t.Errorf("Expected the cut on a character boundary, got %q", got)
+	}
+}
+
+func TestHash(t *testing.T) {
+	if got := Hash("hello"); got != "sha256:2cf24dba5fb0 (5 bytes)" {
+		t.Errorf("Expected a stable hash, got %q", got)
+	}
+}
+
+func TestAttrs(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	secret := "Customer Jane Doe, account 1234. " + strings.Repeat("x", 1000)
+
+	logger.Error("failed", Body("response_body", secret))
+	if !strings.Contains(out.String(), "Customer Jane Doe") || strings.Contains(out.String(), strings.Repeat("x", MaxBodyBytes)) {
+		t.Errorf("Expected the body truncated to %d bytes, got %q", MaxBodyBytes, out.String())
+	}
+
+	t.Setenv(EnvRedact, "true")
+	out.Reset()
+	logger.Error("failed", Body("response_body", secret))
+	logger.Debug("extracted", Content("text", secret))
+	if strings.Contains(out.String(), "Jane Doe") || strings.Count(out.String(), Hash(secret)) != 2 {
+		t.Errorf("Expected the content hashed, got %q", out.String())
+	}
+}
@@ -0,0 +1,235 @@
+package retrieval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/llm"
+	"github.com/sandwichlabs/agent-memory-graph/internal/llm/prompts"
+)
+
+// DefaultContextTokens is the context budget of an answer when
+// AnswerOptions.ContextTokens is zero.
+const DefaultContextTokens = 4000
+
+// charsPerToken approximates how many characters of English text make a token.
+const charsPerToken = 4
+
+// ErrNoContext is returned by Answer when there are no hits to answer from.
+var ErrNoContext = errors.New("no context to answer from")
+
+// citationPattern matches inline citations like [1] and [1, 3].
+var citationPattern = regexp.MustCompile(`\s?\[(\d+(?:\s*,\s*\d+)*)\]`)
+
+// Generator generates text for a prompt. llm.LlmService implements it.
+type Generator interface {
+	GenerateText(ctx context.Context, prompt string, opts ...llm.GenerateOption) (string, error)
+}
+
+// StreamGenerator is a Generator that can deliver its reply as it is generated.
+// GenerateTextStream sends the reply's pieces on the first channel and closes it
+// when done; the second channel carries at most one error and is closed too. Both
+// stop promptly when ctx is cancelled.
+type StreamGenerator interface {
+	Generator
+	GenerateTextStream(ctx context.Context, prompt string, opts ...llm.GenerateOption) (<-chan string, <-chan error)
+}
+
+// AnswerOptions configures Answerer.Answer.
+type AnswerOptions struct {
+	// ContextTokens caps the estimated size of the hits packed into the prompt;
+	// DefaultContextTokens when zero.
+	ContextTokens int
+	// Stream, when set, receives the answer text as it is generated: piece by piece
+	// from a StreamGenerator, or whole from any other Generator. Streamed text has
+	// not had its citations verified yet.
+	Stream func(text string)
+	// Prompts holds the answer template; the built-in templates when nil.
+	Prompts *prompts.Set
+}
+
+// Answer is an answer grounded in retrieved hits.
+type Answer struct {
+	// Query is the standalone query a follow-up question was rewritten into, see
+	// Answerer.Converse.
+	Query string
+	// Text cites its context inline like [1], numbering Context from one.
+	Text string
+	// Context holds the hits given to the model, in prompt order.
+	Context []Hit
+	// Citations lists the cited context in order of first citation.
+	Citations []Citation
+	// Dropped holds the citation numbers the model made up, which were removed
+	// from Text.
+	Dropped []int
+	// Partial is set when generation stopped early, such as on cancellation, and
+	// Text holds what was generated until then.
+	Partial bool
+	// Confidence is the mean retrieval score of the cited hits clamped to [0, 1],
+	// and 0 when the answer cites nothing.
+	Confidence float64
+}
+
+// Citation is a context hit cited by an answer.
+type Citation struct {
+	Number int
+	Hit    Hit
+}
+
+// Answerer answers questions from retrieved hits with a language model.
+type Answerer struct {
+	llm Generator
+
+	mu       sync.Mutex
+	rewrites map[string]string
+}
+
+// NewAnswerer creates an answerer that generates answers with llm.
+func NewAnswerer(llm Generator) *Answerer {
+	return &Answerer{llm: llm, rewrites: make(map[string]string)}
+}
+
+// Answer asks the model to answer question from hits, best first, and verifies
+// the citations in its reply: only hits that were in the prompt can be cited. When
+// generation fails part way through a stream, Answer returns the partial answer
+// along with the error.
+func (a *Answerer) Answer(ctx context.Context, question string, hits []Hit, opts AnswerOptions) (Answer, error) {
+	if strings.TrimSpace(question) == "" {
+		return Answer{}, ErrEmptyQuery
+	}
+	if len(hits) == 0 {
+		return Answer{}, ErrNoContext
+	}
+	budget := opts.ContextTokens
+	if budget <= 0 {
+		budget = DefaultContextTokens
+	}
+
+	packed := packContext(hits, budget)
+	prompt, err := answerPrompt(opts.Prompts, question, packed)
+	if err != nil {
+		return Answer{}, err
+	}
+	reply, err := a.generate(ctx, prompt, opts.Stream)
+	if err != nil {
+		if reply == "" {
+			return Answer{}, fmt.Errorf("failed to generate answer: %w", err)
+		}
+		answer := verifyCitations(strings.TrimSpace(reply), packed)
+		answer.Partial = true
+		return answer, fmt.Errorf("failed to generate answer: %w", err)
+	}
+	return verifyCitations(strings.TrimSpace(reply), packed), nil
+}
+
+// generate returns the model's reply to prompt, streaming it to stream when set.
+// On failure mid-stream it returns the text received so far with the error.
+func (a *Answerer) generate(ctx context.Context, prompt string, stream func(string)) (string, error) {
+	streamer, ok := a.llm.(StreamGenerator)
+	if stream == nil || !ok {
+		reply, err := a.llm.GenerateText(ctx, prompt)
+		if err == nil && stream != nil {
+			stream(reply)
+		}
+		return reply, err
+	}
+
+	tokens, errs := streamer.GenerateTextStream(ctx, prompt)
+	var reply strings.Builder
+	for tokens != nil || errs != nil {
+		select {
+		case token, ok := <-tokens:
+			if !ok {
+				tokens = nil
+				continue
+			}
+			reply.WriteString(token)
+			stream(token)
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			if err != nil {
+				return reply.String(), err
+			}
+		case <-ctx.Done():
+			return reply.String(), ctx.Err()
+		}
+	}
+	return reply.String(), nil
+}
+
+// answerPrompt renders the answer template of set, or the built-in one when set is
+// nil, numbering each hit so the model can cite them inline.
+func answerPrompt(set *prompts.Set, question string, hits []Hit) (string, error) {
+	if set == nil {
+		set = prompts.Default()
+	}
+	data := prompts.AnswerData{Question: question, Context: make([]prompts.Passage, len(hits))}
+	for i, hit := range hits {
+		data.Context[i] = prompts.Passage{Number: i + 1, Content: hit.Content}
+	}
+	return set.Render(prompts.Answer, data)
+}
+
+// packContext keeps the hits that fit in budget tokens, best first. The best hit is
+// always kept, truncated if it alone exceeds the budget.
+func packContext(hits []Hit, budget int) []Hit {
+	remaining := budget * charsPerToken
+	packed := make([]Hit, 0, len(hits))
+	for i, hit := range hits {
+		if len(hit.Content) > remaining {
+			if i == 0 {
+				hit.Content = strings.ToValidUTF8(hit.Content[:remaining], "")
+				packed = append(packed, hit)
+			}
+			continue
+		}
+		remaining -= len(hit.Content)
+		packed = append(packed, hit)
+	}
+	return packed
+}
+
+// verifyCitations collects the citations of text to the packed hits, removing those
+// that refer to no hit.
+func verifyCitations(text string, packed []Hit) Answer {
+	answer := Answer{Context: packed}
+	cited := make(map[int]bool)
+	var total float64
+	answer.Text = citationPattern.ReplaceAllStringFunc(text, func(match string) string {
+		groups := citationPattern.FindStringSubmatch(match)
+		var valid []string
+		for _, field := range strings.Split(groups[1], ",") {
+			n, _ := strconv.Atoi(strings.TrimSpace(field))
+			if n < 1 || n > len(packed) {
+				if !slices.Contains(answer.Dropped, n) {
+					answer.Dropped = append(answer.Dropped, n)
+				}
+				continue
+			}
+			valid = append(valid, strconv.Itoa(n))
+			if !cited[n] {
+				cited[n] = true
+				answer.Citations = append(answer.Citations, Citation{Number: n, Hit: packed[n-1]})
+				total += packed[n-1].Score
+			}
+		}
+		if len(valid) == 0 {
+			return ""
+		}
+		prefix := match[:strings.Index(match, "[")]
+		return prefix + "[" + strings.Join(valid, ", ") + "]"
+	})
+	if len(answer.Citations) > 0 {
+		answer.Confidence = min(max(total/float64(len(answer.Citations)), 0), 1)
+	}
+	return answer
+}
@@ -0,0 +1,195 @@
+package retrieval
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/llm"
+)
+
+// scriptedLLM replies with its script in order and remembers the prompts.
+type scriptedLLM struct {
+	replies []string
+	err     error
+	prompts []string
+}
+
+func (l *scriptedLLM) GenerateText(ctx context.Context, prompt string, opts ...llm.GenerateOption) (string, error) {
+	l.prompts = append(l.prompts, prompt)
+	if l.err != nil {
+		return "", l.err
+	}
+	reply := l.replies[0]
+	l.replies = l.replies[1:]
+	return reply, nil
+}
+
+func answerHits() []Hit {
+	return []Hit{
+		{ChunkID: "a", Content: "Kuzu Inc builds KuzuDB.", Score: 0.9},
+		{ChunkID: "b", Content: "KuzuDB speaks Cypher.", Score: 0.5},
+		{ChunkID: "c", Content: strings.Repeat("padding ", 20), Score: 0.1},
+	}
+}
+
+func TestAnswer_VerifiesCitations(t *testing.T) {
+	llm := &scriptedLLM{replies: []string{"KuzuDB speaks Cypher [2] and is built by Kuzu Inc [1, 7]. It is fast [9].\n"}}
+	answer, err := NewAnswerer(llm).Answer(context.Background(), "what is kuzudb?", answerHits(), AnswerOptions{})
+	if err != nil {
+		t.Fatalf("Answer failed: %v", err)
+	}
+
+	if want := "KuzuDB speaks Cypher [2] and is built by Kuzu Inc [1]. It is fast."; answer.Text != want {
+		t.Errorf("Expected text %q, got %q", want, answer.Text)
+	}
+	var cited []string
+	for _, citation := range answer.Citations {
+		cited = append(cited, citation.Hit.ChunkID)
+	}
+	if want := []string{"b", "a"}; !reflect.DeepEqual(cited, want) {
+		t.Errorf("Expected citations %v in order, got %v", want, cited)
+	}
+	if want := []int{7, 9}; !reflect.DeepEqual(answer.Dropped, want) {
+		t.Errorf("Expected dropped citations %v, got %v", want, answer.Dropped)
+	}
+	if answer.Confidence != 0.7 {
+		t.Errorf("Expected confidence 0.7, got %v", answer.Confidence)
+	}
+	if !strings.Contains(llm.prompts[0], "[1] Kuzu Inc builds KuzuDB.") || !strings.Contains(llm.prompts[0], "Question: what is kuzudb?") {
+		t.Errorf("Expected a numbered prompt, got %q", llm.prompts[0])
+	}
+}
+
+func TestAnswer_PacksContextUnderBudget(t *testing.T) {
+	// 11 tokens are 44 characters: room for the first two hits only.
+	llm := &scriptedLLM{replies: []string{"It is padding [3]."}}
+	answer, err := NewAnswerer(llm).Answer(context.Background(), "what?", answerHits(), AnswerOptions{ContextTokens: 11})
+	if err != nil {
+		t.Fatalf("Answer failed: %v", err)
+	}
+	if len(answer.Context) != 2 {
+		t.Fatalf("Expected 2 hits of context, got %d", len(answer.Context))
+	}
+	if strings.Contains(llm.prompts[0], "padding") {
+		t.Errorf("Expected the hit over budget to be left out of the prompt")
+	}
+	if answer.Text != "It is padding." || len(answer.Citations) != 0 || answer.Confidence != 0 {
+		t.Errorf("Expected the citation to unsent context to be dropped, got %+v", answer)
+	}
+
+	// The best hit is truncated rather than left out.
+	llm = &scriptedLLM{replies: []string{"Kuzu [1]."}}
+	answer, err = NewAnswerer(llm).Answer(context.Background(), "what?", answerHits(), AnswerOptions{ContextTokens: 2})
+	if err != nil {
+		t.Fatalf("Answer failed: %v", err)
+	}
+	if len(answer.Context) != 1 || answer.Context[0].Content != "Kuzu Inc" {
+		t.Errorf("Expected the truncated best hit, got %+v", answer.Context)
+	}
+}
+
+func TestAnswer_Errors(t *testing.T) {
+	failing := errors.New("rate limited")
+	cases := []struct {
+		name     string
+		question string
+		hits     []Hit
+		llm      *scriptedLLM
+		want     error
+	}{
+		{"empty question", " ", answerHits(), &scriptedLLM{}, ErrEmptyQuery},
+		{"no hits", "what?", nil, &scriptedLLM{}, ErrNoContext},
+		{"llm failure", "what?", answerHits(), &scriptedLLM{err: failing}, failing},
+	}
+	for _, tc := range cases {
+		_, err := NewAnswerer(tc.llm).Answer(context.Background(), tc.question, tc.hits, AnswerOptions{})
+		if !errors.Is(err, tc.want) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, err)
+		}
+	}
+}
+
+// slowStream streams its tokens one per delay until the context is cancelled.
+type slowStream struct {
+	tokens []string
+	delay  time.Duration
+	done   chan struct{}
+}
+
+func (s *slowStream) GenerateText(ctx context.Context, prompt string, opts ...llm.GenerateOption) (string, error) {
+	return strings.Join(s.tokens, ""), nil
+}
+
+func (s *slowStream) GenerateTextStream(ctx context.Context, prompt string, opts ...llm.GenerateOption) (<-chan string, <-chan error) {
+	tokens, errs := make(chan string), make(chan error, 1)
+	go func() {
+		defer close(s.done)
+		defer close(errs)
+		defer close(tokens)
+		for _, token := range s.tokens {
+			select {
+			case <-time.After(s.delay):
+			case <-ctx.Done():
+				errs <- ctx.Err()
+				return
+			}
+			select {
+			case tokens <- token:
+			case <-ctx.Done():
+				errs <- ctx.Err()
+				return
+			}
+		}
+	}()
+	return tokens, errs
+}
+
+func TestAnswer_Streams(t *testing.T) {
+	llm := &slowStream{tokens: []string{"Kuzu Inc ", "builds ", "KuzuDB [1]."}, delay: time.Millisecond, done: make(chan struct{})}
+	var streamed []string
+	answer, err := NewAnswerer(llm).Answer(context.Background(), "who builds KuzuDB?", answerHits(), AnswerOptions{
+		Stream: func(text string) { streamed = append(streamed, text) },
+	})
+	if err != nil {
+		t.Fatalf("Answer failed: %v", err)
+	}
+	if !reflect.DeepEqual(streamed, llm.tokens) {
+		t.Errorf("Expected the tokens as they arrived, got %q", streamed)
+	}
+	if answer.Text != "Kuzu Inc builds KuzuDB [1]." || answer.Partial || len(answer.Citations) != 1 {
+		t.Errorf("Expected the complete cited answer, got %+v", answer)
+	}
+}
+
+func TestAnswer_CancelledStreamReturnsPartialAnswer(t *testing.T) {
+	llm := &slowStream{tokens: []string{"KuzuDB [2] ", "is ", "built ", "by ", "Kuzu Inc [1]."}, delay: 20 * time.Millisecond, done: make(chan struct{})}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var streamed []string
+	answer, err := NewAnswerer(llm).Answer(ctx, "who builds KuzuDB?", answerHits(), AnswerOptions{
+		Stream: func(text string) {
+			streamed = append(streamed, text)
+			if len(streamed) == 2 {
+				cancel()
+			}
+		},
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if !answer.Partial || answer.Text != "KuzuDB [2] is" {
+		t.Errorf("Expected the partial answer, got %+v", answer)
+	}
+	if len(answer.Citations) != 1 || answer.Citations[0].Number != 2 {
+		t.Errorf("Expected the partial answer's citation, got %+v", answer.Citations)
+	}
+	select {
+	case <-llm.done:
+	case <-time.After(time.Second):
+		t.Errorf("Expected the stream to stop after cancellation")
+	}
+}
@@ -0,0 +1,117 @@
+package retrieval
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults for NewQueryCache.
+const (
+	DefaultQueryCacheSize = 256
+	DefaultQueryCacheTTL  = 10 * time.Minute
+)
+
+// QueryCache is a least-recently-used cache of query embeddings whose entries expire
+// after a TTL. It caches vectors, not results, so hits stay fresh as the graph
+// changes. It is safe for concurrent use and meant to be shared by every retriever
+// in a process, such as the server's tool handlers.
+type QueryCache struct {
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu      sync.Mutex
+	order   *list.List // of *queryEntry, most recently used first
+	entries map[queryKey]*list.Element
+	hits    int64
+	misses  int64
+}
+
+// QueryCacheStats counts the lookups of a QueryCache.
+type QueryCacheStats struct {
+	Hits    int64
+	Misses  int64
+	Entries int
+}
+
+type queryKey struct {
+	provider, model, query string
+}
+
+type queryEntry struct {
+	key     queryKey
+	vector  []float32
+	expires time.Time
+}
+
+// NewQueryCache creates a cache holding up to size embeddings for ttl each,
+// DefaultQueryCacheSize and DefaultQueryCacheTTL when zero.
+func NewQueryCache(size int, ttl time.Duration) *QueryCache {
+	if size <= 0 {
+		size = DefaultQueryCacheSize
+	}
+	if ttl <= 0 {
+		ttl = DefaultQueryCacheTTL
+	}
+	return &QueryCache{
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		order:   list.New(),
+		entries: make(map[queryKey]*list.Element),
+	}
+}
+
+// Get returns the cached embedding of query by the provider's model, if any.
+func (c *QueryCache) Get(provider, model, query string) ([]float32, bool) {
+	key := queryKey{provider, model, normalizeQuery(query)}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*queryEntry)
+		if c.now().Before(entry.expires) {
+			c.order.MoveToFront(element)
+			c.hits++
+			return entry.vector, true
+		}
+		c.remove(element)
+	}
+	c.misses++
+	return nil, false
+}
+
+// Put caches the embedding of query by the provider's model, evicting the least
+// recently used entry when the cache is full. The vector must not be modified
+// afterwards.
+func (c *QueryCache) Put(provider, model, query string, vector []float32) {
+	key := queryKey{provider, model, normalizeQuery(query)}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+	c.entries[key] = c.order.PushFront(&queryEntry{key: key, vector: vector, expires: c.now().Add(c.ttl)})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+// Stats returns the hit and miss counts since the cache was created.
+func (c *QueryCache) Stats() QueryCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return QueryCacheStats{Hits: c.hits, Misses: c.misses, Entries: c.order.Len()}
+}
+
+func (c *QueryCache) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*queryEntry).key)
+}
+
+// normalizeQuery folds case and whitespace, which barely move an embedding, so
+// re-issued queries share an entry.
+func normalizeQuery(query string) string {
+	return strings.ToLower(strings.Join(strings.Fields(query), " "))
+}
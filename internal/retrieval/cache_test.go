@@ -0,0 +1,86 @@
+package retrieval
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestQueryCache_EvictsAndExpires(t *testing.T) {
+	cache := NewQueryCache(2, time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	cache.Put("gemini", "m", "Kuzu  builds", []float32{1})
+	cache.Put("gemini", "m", "cypher", []float32{2})
+	if vector, ok := cache.Get("gemini", "m", " kuzu BUILDS "); !ok || vector[0] != 1 {
+		t.Fatalf("Expected the normalized query to hit, got %v, %v", vector, ok)
+	}
+	if _, ok := cache.Get("mistral", "m", "kuzu builds"); ok {
+		t.Errorf("Expected another provider to miss")
+	}
+
+	// "cypher" is now the least recently used entry.
+	cache.Put("gemini", "m", "graphs", []float32{3})
+	if _, ok := cache.Get("gemini", "m", "cypher"); ok {
+		t.Errorf("Expected the least recently used entry to be evicted")
+	}
+	if _, ok := cache.Get("gemini", "m", "kuzu builds"); !ok {
+		t.Errorf("Expected the recently used entry to survive eviction")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := cache.Get("gemini", "m", "graphs"); ok {
+		t.Errorf("Expected the entry to expire")
+	}
+	want := QueryCacheStats{Hits: 2, Misses: 3, Entries: 1}
+	if got := cache.Stats(); got != want {
+		t.Errorf("Expected stats %+v, got %+v", want, got)
+	}
+}
+
+func TestSearch_ReusesCachedQueryEmbedding(t *testing.T) {
+	retriever, embeddings := newFixture()
+	cache := NewQueryCache(0, 0)
+	retriever.WithQueryCache(cache, "testing", "mock")
+
+	ctx := context.Background()
+	first, err := retriever.Search(ctx, "what does kuzu build", SearchOptions{})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	second, err := retriever.Search(ctx, "What does  Kuzu build", SearchOptions{})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(embeddings.types) != 1 {
+		t.Errorf("Expected 1 embedding request, got %d", len(embeddings.types))
+	}
+	if len(first) != len(second) || first[0].ChunkID != second[0].ChunkID {
+		t.Errorf("Expected the same hits, got %+v and %+v", first, second)
+	}
+	if got := cache.Stats(); got.Hits != 1 || got.Misses != 1 {
+		t.Errorf("Expected 1 hit and 1 miss, got %+v", got)
+	}
+}
+
+func TestQueryCache_ConcurrentUse(t *testing.T) {
+	cache := NewQueryCache(8, time.Minute)
+	var wg sync.WaitGroup
+	for i := range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			query := fmt.Sprintf("query %d", i%10)
+			if _, ok := cache.Get("p", "m", query); !ok {
+				cache.Put("p", "m", query, []float32{float32(i)})
+			}
+		}()
+	}
+	wg.Wait()
+	if got := cache.Stats(); got.Hits+got.Misses != 16 || got.Entries > 8 {
+		t.Errorf("Expected 16 lookups and at most 8 entries, got %+v", got)
+	}
+}
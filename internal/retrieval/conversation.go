@@ -0,0 +1,121 @@
+package retrieval
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// Roles of conversation turns.
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// maxRewrites bounds the follow-up rewrites an Answerer remembers.
+const maxRewrites = 256
+
+// Turn is a message of the conversation leading up to a question.
+type Turn struct {
+	Role    string
+	Content string
+}
+
+// Converse answers a follow-up question in the context of history, oldest turn
+// first. The question is rewritten into a standalone query, which is searched with
+// retriever and answered as by Answer; the returned Answer.Query holds it. When the
+// search finds nothing relevant, Converse returns ErrNoContext along with the query.
+func (a *Answerer) Converse(ctx context.Context, retriever *Retriever, question string, history []Turn, search SearchOptions, opts AnswerOptions) (Answer, error) {
+	if strings.TrimSpace(question) == "" {
+		return Answer{}, ErrEmptyQuery
+	}
+	query := a.Rewrite(ctx, question, history)
+	results, err := retriever.Retrieve(ctx, query, search)
+	if err != nil {
+		return Answer{Query: query}, err
+	}
+	if len(results.Hits) == 0 {
+		return Answer{Query: query}, ErrNoContext
+	}
+	answer, err := a.Answer(ctx, query, results.Hits, opts)
+	answer.Query = query
+	return answer, err
+}
+
+// Rewrite turns a follow-up question like "and what about the second option?" into
+// a query that can be searched without history. It returns the question unchanged
+// when there is no history, and when the LLM fails or replies with anything but a
+// JSON object holding the query. Rewrites are cached per question and history.
+func (a *Answerer) Rewrite(ctx context.Context, question string, history []Turn) string {
+	if len(history) == 0 {
+		return question
+	}
+	key := rewriteKey(question, history)
+	a.mu.Lock()
+	query, ok := a.rewrites[key]
+	a.mu.Unlock()
+	if ok {
+		return query
+	}
+
+	reply, err := a.llm.GenerateText(ctx, rewritePrompt(question, history))
+	if err == nil {
+		query, err = parseRewrite(reply)
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "follow-up rewriting failed, searching the question as asked", "error", err)
+		return question
+	}
+
+	a.mu.Lock()
+	if len(a.rewrites) >= maxRewrites {
+		clear(a.rewrites)
+	}
+	a.rewrites[key] = query
+	a.mu.Unlock()
+	return query
+}
+
+func rewritePrompt(question string, history []Turn) string {
+	var b strings.Builder
+	b.WriteString("Rewrite the follow-up question below into a standalone search query that can be understood ")
+	b.WriteString("without the conversation: resolve pronouns and references such as \"it\" or \"the second option\". ")
+	b.WriteString(`Reply with only a JSON object like {"query": "..."}.` + "\n\nConversation:\n")
+	for _, turn := range history {
+		fmt.Fprintf(&b, "%s: %s\n", turn.Role, turn.Content)
+	}
+	fmt.Fprintf(&b, "\nFollow-up question: %s\n", question)
+	return b.String()
+}
+
+// parseRewrite reads the query from the JSON object in reply, tolerating
+// surrounding prose or code fences.
+func parseRewrite(reply string) (string, error) {
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return "", fmt.Errorf("no JSON object in reply %q", reply)
+	}
+	var rewrite struct {
+		Query string `json:"query"`
+	}
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &rewrite); err != nil {
+		return "", fmt.Errorf("invalid rewrite: %w", err)
+	}
+	if strings.TrimSpace(rewrite.Query) == "" {
+		return "", fmt.Errorf("empty rewrite in reply %q", reply)
+	}
+	return strings.TrimSpace(rewrite.Query), nil
+}
+
+func rewriteKey(question string, history []Turn) string {
+	h := sha256.New()
+	for _, turn := range history {
+		fmt.Fprintf(h, "%s\x00%s\x00", turn.Role, turn.Content)
+	}
+	h.Write([]byte(question))
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,78 @@
+package retrieval
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func conversation() []Turn {
+	return []Turn{
+		{Role: RoleUser, Content: "Which graph databases support Cypher?"},
+		{Role: RoleAssistant, Content: "Neo4j and KuzuDB [1]."},
+	}
+}
+
+func TestConverse_RewritesFollowUp(t *testing.T) {
+	retriever, _ := newFixture()
+	embeddings := &phrasebook{}
+	retriever.embeddings = embeddings
+	llm := &scriptedLLM{replies: []string{
+		"```json\n{\"query\": \"Who builds KuzuDB?\"}\n```",
+		"Kuzu Inc [1].",
+	}}
+	answerer := NewAnswerer(llm)
+
+	answer, err := answerer.Converse(context.Background(), retriever, "and who builds the second one?", conversation(), SearchOptions{K: 1}, AnswerOptions{})
+	if err != nil {
+		t.Fatalf("Converse failed: %v", err)
+	}
+	if answer.Query != "Who builds KuzuDB?" {
+		t.Errorf("Expected the rewritten query, got %q", answer.Query)
+	}
+	if want := []string{"Who builds KuzuDB?"}; !reflect.DeepEqual(embeddings.texts, want) {
+		t.Errorf("Expected the rewritten query to be searched, got %v", embeddings.texts)
+	}
+	if !strings.Contains(llm.prompts[0], "assistant: Neo4j and KuzuDB [1].") || !strings.Contains(llm.prompts[0], "Follow-up question: and who builds the second one?") {
+		t.Errorf("Expected the history in the rewrite prompt, got %q", llm.prompts[0])
+	}
+	if !strings.Contains(llm.prompts[1], "Question: Who builds KuzuDB?") {
+		t.Errorf("Expected the answer prompt to ask the rewritten query, got %q", llm.prompts[1])
+	}
+	if answer.Text != "Kuzu Inc [1]." || len(answer.Citations) != 1 {
+		t.Errorf("Expected a cited answer, got %+v", answer)
+	}
+
+	// The same turn is rewritten from the cache.
+	if query := answerer.Rewrite(context.Background(), "and who builds the second one?", conversation()); query != "Who builds KuzuDB?" {
+		t.Errorf("Expected the cached rewrite, got %q", query)
+	}
+	if len(llm.prompts) != 2 {
+		t.Errorf("Expected no further LLM calls, got %d prompts", len(llm.prompts))
+	}
+}
+
+func TestRewrite_KeepsQuestionWithoutUsableRewrite(t *testing.T) {
+	cases := []struct {
+		name    string
+		history []Turn
+		llm     *scriptedLLM
+		calls   int
+	}{
+		{"no history", nil, &scriptedLLM{}, 0},
+		{"llm failure", conversation(), &scriptedLLM{err: errors.New("unavailable")}, 1},
+		{"not json", conversation(), &scriptedLLM{replies: []string{"Who builds it?"}}, 1},
+		{"empty query", conversation(), &scriptedLLM{replies: []string{`{"query": " "}`}}, 1},
+	}
+	for _, tc := range cases {
+		query := NewAnswerer(tc.llm).Rewrite(context.Background(), "who builds it?", tc.history)
+		if query != "who builds it?" {
+			t.Errorf("%s: expected the question unchanged, got %q", tc.name, query)
+		}
+		if len(tc.llm.prompts) != tc.calls {
+			t.Errorf("%s: expected %d LLM calls, got %d", tc.name, tc.calls, len(tc.llm.prompts))
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package retrieval
+
+import "strings"
+
+// DefaultDedupThreshold is the shingle overlap above which chunks read as copies
+// of each other, as overlapping chunks and re-ingested revisions do.
+const DefaultDedupThreshold = 0.8
+
+// shingleSize is the number of consecutive words in a shingle.
+const shingleSize = 3
+
+// Dedup configures collapsing near-identical hits, see Collapse.
+type Dedup struct {
+	// Threshold is the Jaccard similarity of word shingles, in (0, 1], at or above
+	// which a hit is collapsed into a better one; DefaultDedupThreshold when zero.
+	Threshold float64
+}
+
+// Collapse drops each hit whose content is at least threshold similar to a better
+// hit's, recording its ID and those it collapsed in the better hit's Collapsed.
+// Similarity is the Jaccard index of the sets of lowercase three-word shingles.
+// hits must be sorted best first; the order of the survivors is kept.
+func Collapse(hits []Hit, threshold float64) []Hit {
+	kept := make([]Hit, 0, len(hits))
+	shingles := make([]map[string]bool, 0, len(hits))
+	for _, hit := range hits {
+		set := shingle(hit.Content)
+		duplicate := false
+		for i := range kept {
+			if jaccard(set, shingles[i]) >= threshold {
+				kept[i].Collapsed = append(kept[i].Collapsed, hit.ChunkID)
+				kept[i].Collapsed = append(kept[i].Collapsed, hit.Collapsed...)
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			kept = append(kept, hit)
+			shingles = append(shingles, set)
+		}
+	}
+	return kept
+}
+
+// shingle returns the set of runs of shingleSize consecutive lowercase words in
+// text, or the whole text as one shingle when it has fewer words.
+func shingle(text string) map[string]bool {
+	words := strings.Fields(strings.ToLower(text))
+	set := make(map[string]bool)
+	if len(words) < shingleSize {
+		set[strings.Join(words, " ")] = true
+		return set
+	}
+	for i := 0; i+shingleSize <= len(words); i++ {
+		set[strings.Join(words[i:i+shingleSize], " ")] = true
+	}
+	return set
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	shared := 0
+	for s := range a {
+		if b[s] {
+			shared++
+		}
+	}
+	union := len(a) + len(b) - shared
+	if union == 0 {
+		return 1
+	}
+	return float64(shared) / float64(union)
+}
@@ -0,0 +1,61 @@
+package retrieval
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/storage"
+)
+
+const paragraph = "KuzuDB is an embedded graph database that speaks Cypher and stores data in columns for fast analytical queries. " +
+	"It is built by Kuzu Inc and used by the memory graph to keep chunks, entities and their relationships on disk."
+
+func TestCollapse(t *testing.T) {
+	hits := []Hit{
+		{ChunkID: "v2", Score: 0.9, Content: paragraph},
+		{ChunkID: "other", Score: 0.8, Content: "Mistral provides the embeddings used to search the memory graph."},
+		// A revision with one word changed and an overlapping chunk with a sentence more.
+		{ChunkID: "v1", Score: 0.7, Content: strings.Replace(paragraph, "analytical", "analytic", 1)},
+		{ChunkID: "overlap", Score: 0.6, Content: paragraph + " It runs in process."},
+		{ChunkID: "unrelated", Score: 0.5, Content: "Cypher is a query language."},
+	}
+
+	collapsed := Collapse(hits, DefaultDedupThreshold)
+	if got := describe(collapsed); !reflect.DeepEqual(got, []string{"v2:", "other:", "unrelated:"}) {
+		t.Fatalf("Expected the duplicates collapsed into the best hit, got %v", got)
+	}
+	if want := []string{"v1", "overlap"}; !reflect.DeepEqual(collapsed[0].Collapsed, want) {
+		t.Errorf("Expected %v collapsed into v2, got %v", want, collapsed[0].Collapsed)
+	}
+	if collapsed[1].Collapsed != nil || collapsed[2].Collapsed != nil {
+		t.Errorf("Expected distinct hits to collapse nothing, got %+v", collapsed[1:])
+	}
+
+	// A strict threshold keeps everything but the exact copies.
+	if got := Collapse(append(hits, Hit{ChunkID: "copy", Content: paragraph}), 1); len(got) != len(hits) || !reflect.DeepEqual(got[0].Collapsed, []string{"copy"}) {
+		t.Errorf("Expected only the exact copy collapsed, got %+v", got)
+	}
+}
+
+func TestSearch_DedupFillsKWithDistinctHits(t *testing.T) {
+	store := &fakeStore{chunks: []storage.ScoredChunk{
+		{Chunk: storage.Chunk{ID: "v2", Content: paragraph, Embedding: vectorFor(0)}},
+		{Chunk: storage.Chunk{ID: "v1", Content: paragraph + " It runs in process.", Embedding: vectorFor(0.1)}},
+		{Chunk: storage.Chunk{ID: "other", Content: "Mistral provides the embeddings.", Embedding: vectorFor(1)}},
+	}}
+	retriever := NewRetriever(store, uniformService{})
+
+	hits, err := retriever.Search(context.Background(), "kuzudb", SearchOptions{K: 2, Dedup: &Dedup{}})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(hits) != 2 || hits[0].ChunkID != "v2" || hits[1].ChunkID != "other" || !reflect.DeepEqual(hits[0].Collapsed, []string{"v1"}) {
+		t.Errorf("Expected v2 with v1 collapsed and other, got %+v", hits)
+	}
+
+	if _, err := retriever.Search(context.Background(), "kuzudb", SearchOptions{Dedup: &Dedup{Threshold: 1.5}}); err == nil {
+		t.Error("Expected an error for a threshold above 1")
+	}
+}
@@ -0,0 +1,45 @@
+package retrieval
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/embedding"
+)
+
+// EmbeddingRecord is the part of a memory graph that records how its vectors were
+// embedded.
+type EmbeddingRecord interface {
+	StoredEmbeddingDimensions(ctx context.Context) (int, error)
+	EmbeddingProvider(ctx context.Context) (string, error)
+	EmbeddingModel(ctx context.Context) (string, error)
+}
+
+// CheckEmbedder refuses to embed queries with service into vectors of another
+// length than the stored ones, or with another provider or model than the one
+// that embedded the memory graph, whose vectors would not compare. A graph that
+// did not record its provider and model is trusted.
+func CheckEmbedder(ctx context.Context, store EmbeddingRecord, service embedding.Service) error {
+	dimensions, err := store.StoredEmbeddingDimensions(ctx)
+	if err != nil {
+		return err
+	}
+	if err := embedding.CheckDimensions(service, dimensions); err != nil {
+		return err
+	}
+	provider, err := store.EmbeddingProvider(ctx)
+	if err != nil {
+		return err
+	}
+	if provider != "" && provider != string(service.GetType()) {
+		return fmt.Errorf("memory graph was embedded with %s, not %s: use --embedding-provider %s", provider, service.GetType(), provider)
+	}
+	model, err := store.EmbeddingModel(ctx)
+	if err != nil {
+		return err
+	}
+	if current := embedding.ModelOf(service); model != "" && current != "" && model != current {
+		return fmt.Errorf("memory graph was embedded with the %s model %s, not %s", provider, model, current)
+	}
+	return nil
+}
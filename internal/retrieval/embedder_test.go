@@ -0,0 +1,41 @@
+package retrieval
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/embedding"
+)
+
+// embeddingRecord records vectors of dimensions from provider and model.
+type embeddingRecord struct {
+	dimensions      int
+	provider, model string
+}
+
+func (r embeddingRecord) StoredEmbeddingDimensions(ctx context.Context) (int, error) {
+	return r.dimensions, nil
+}
+
+func (r embeddingRecord) EmbeddingProvider(ctx context.Context) (string, error) {
+	return r.provider, nil
+}
+
+func (r embeddingRecord) EmbeddingModel(ctx context.Context) (string, error) {
+	return r.model, nil
+}
+
+func TestCheckEmbedder(t *testing.T) {
+	service := embedding.NewMockService(embedding.WithMockDimensions(4))
+
+	for _, record := range []embeddingRecord{{dimensions: 4}, {dimensions: 4, provider: string(embedding.ProviderTestMock)}} {
+		if err := CheckEmbedder(context.Background(), record, service); err != nil {
+			t.Errorf("Expected %+v to match, got %v", record, err)
+		}
+	}
+	for _, record := range []embeddingRecord{{dimensions: 8}, {dimensions: 4, provider: string(embedding.ProviderMistral)}} {
+		if err := CheckEmbedder(context.Background(), record, service); err == nil {
+			t.Errorf("Expected %+v not to match the mock embedder", record)
+		}
+	}
+}
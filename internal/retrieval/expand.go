@@ -0,0 +1,146 @@
+package retrieval
+
+import (
+	"context"
+	"sort"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/storage"
+)
+
+// RetrieverGraph names hits added by graph expansion in Hit.Retrievers.
+const RetrieverGraph = "graph"
+
+// Defaults for GraphExpansion.
+const (
+	DefaultExpansionHops  = 1
+	MaxExpansionHops      = 2
+	DefaultExpansionDecay = 0.5
+	// DefaultChunksPerEntity is how many mentioning chunks each entity contributes.
+	DefaultChunksPerEntity = 2
+)
+
+// GraphExpansion configures the graph stage of Search. The entities mentioned by
+// the initial hits, and the entities up to Hops relationships away from them,
+// contribute their top mentioning chunks as extra candidates.
+type GraphExpansion struct {
+	// Hops is how many relationships to follow, DefaultExpansionHops when zero and
+	// at most MaxExpansionHops.
+	Hops int
+	// Budget caps the number of hits returned, initial hits included; twice the
+	// search K when zero.
+	Budget int
+	// Decay scores expanded chunks: a chunk sharing an entity with a hit scores the
+	// hit's score times Decay, one reached over a relationship times Decay², and so
+	// on. DefaultExpansionDecay when zero.
+	Decay float64
+	// ChunksPerEntity is DefaultChunksPerEntity when zero.
+	ChunksPerEntity int
+}
+
+// expand adds the chunks matching filter that are reachable from hits through the
+// entity graph, best first, until the budget is spent.
+func (r *Retriever) expand(ctx context.Context, hits []Hit, opts GraphExpansion, k int, filter storage.ChunkFilter) ([]Hit, error) {
+	hops := opts.Hops
+	if hops <= 0 {
+		hops = DefaultExpansionHops
+	}
+	hops = min(hops, MaxExpansionHops)
+	budget := opts.Budget
+	if budget <= 0 {
+		budget = 2 * k
+	}
+	decay := opts.Decay
+	if decay <= 0 {
+		decay = DefaultExpansionDecay
+	}
+	perEntity := opts.ChunksPerEntity
+	if perEntity <= 0 {
+		perEntity = DefaultChunksPerEntity
+	}
+	if len(hits) >= budget {
+		return hits[:budget], nil
+	}
+
+	ids := make([]string, 0, len(hits))
+	for _, hit := range hits {
+		ids = append(ids, hit.ChunkID)
+	}
+	mentions, err := r.store.MentionedEntities(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	// Score each entity by the best path to it from a hit.
+	scores := make(map[string]float64)
+	var frontier []string
+	reach := func(name string, score float64) {
+		if current, ok := scores[name]; !ok {
+			scores[name] = score
+			frontier = append(frontier, name)
+		} else if score > current {
+			scores[name] = score
+		}
+	}
+	for _, hit := range hits {
+		// Negative similarities would grow towards zero as they decay.
+		if hit.Score <= 0 {
+			continue
+		}
+		for _, name := range mentions[hit.ChunkID] {
+			reach(name, hit.Score*decay)
+		}
+	}
+	for hop := 0; hop < hops && len(frontier) > 0; hop++ {
+		related, err := r.store.RelatedEntities(ctx, frontier)
+		if err != nil {
+			return nil, err
+		}
+		current := frontier
+		frontier = nil
+		for _, name := range current {
+			for _, other := range related[name] {
+				reach(other, scores[name]*decay)
+			}
+		}
+	}
+
+	entities := make([]string, 0, len(scores))
+	for name := range scores {
+		entities = append(entities, name)
+	}
+	sort.Slice(entities, func(i, j int) bool {
+		if scores[entities[i]] != scores[entities[j]] {
+			return scores[entities[i]] > scores[entities[j]]
+		}
+		return entities[i] < entities[j]
+	})
+
+	seen := make(map[string]bool, budget)
+	for _, hit := range hits {
+		seen[hit.ChunkID] = true
+	}
+	for _, name := range entities {
+		if len(hits) >= budget {
+			break
+		}
+		chunks, err := r.store.MentioningChunks(ctx, name, perEntity, filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, chunk := range chunks {
+			if len(hits) >= budget {
+				break
+			}
+			if seen[chunk.ID] {
+				continue
+			}
+			seen[chunk.ID] = true
+			hit := newHit(chunk, RetrieverGraph)
+			hit.Score = scores[name]
+			hits = append(hits, hit)
+		}
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	return hits, nil
+}
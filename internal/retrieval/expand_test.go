@@ -0,0 +1,82 @@
+package retrieval
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/storage"
+)
+
+// newGraphFixture returns a retriever over a graph where the answer to "who builds
+// KuzuDB" sits in a chunk unlike the query, reachable only through the entity link
+// Kuzu Inc -[builds]-> KuzuDB -[speaks]-> Cypher.
+func newGraphFixture() *Retriever {
+	store := &fakeStore{
+		chunks: []storage.ScoredChunk{
+			{Chunk: storage.Chunk{ID: "question", Content: "Kuzu Inc was founded in Waterloo.", Embedding: vectorFor(0)}, Source: "company.md"},
+			{Chunk: storage.Chunk{ID: "noise", Content: "Unrelated notes.", Embedding: vectorFor(1)}, Source: "notes.md"},
+			{Chunk: storage.Chunk{ID: "answer", Content: "KuzuDB is an embedded graph database.", Embedding: vectorFor(-40)}, Source: "product.md"},
+			{Chunk: storage.Chunk{ID: "two-hops", Content: "Cypher is a query language.", Embedding: vectorFor(-40)}, Source: "cypher.md"},
+		},
+		mentions: map[string][]string{
+			"question": {"Kuzu Inc"},
+			"answer":   {"KuzuDB"},
+			"two-hops": {"Cypher"},
+		},
+		related: [][2]string{{"Kuzu Inc", "KuzuDB"}, {"KuzuDB", "Cypher"}},
+	}
+	return NewRetriever(store, uniformService{})
+}
+
+func TestSearch_GraphExpansionReachesLinkedChunks(t *testing.T) {
+	retriever := newGraphFixture()
+	ctx := context.Background()
+
+	hits, err := retriever.Search(ctx, "who builds KuzuDB", SearchOptions{K: 2})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if got := describe(hits); strings.Join(got, " ") != "question:vector noise:vector" {
+		t.Fatalf("Expected similarity alone to miss the answer, got %v", got)
+	}
+
+	hits, err = retriever.Search(ctx, "who builds KuzuDB", SearchOptions{K: 2, GraphExpansion: &GraphExpansion{}})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if got := describe(hits); strings.Join(got, " ") != "question:vector noise:vector answer:graph" {
+		t.Fatalf("Expected one hop to add the answer, got %v", got)
+	}
+	if expected := hits[0].Score * DefaultExpansionDecay * DefaultExpansionDecay; math.Abs(hits[2].Score-expected) > 1e-9 {
+		t.Errorf("Expected the answer to score %v, got %v", expected, hits[2].Score)
+	}
+}
+
+func TestSearch_GraphExpansionHopsAndBudget(t *testing.T) {
+	retriever := newGraphFixture()
+	ctx := context.Background()
+	cases := []struct {
+		name      string
+		expansion GraphExpansion
+		expected  string
+	}{
+		{"one hop", GraphExpansion{Budget: 5}, "question:vector answer:graph"},
+		{"two hops", GraphExpansion{Hops: 2, Budget: 5}, "question:vector answer:graph two-hops:graph"},
+		{"hops are capped", GraphExpansion{Hops: 5, Budget: 5}, "question:vector answer:graph two-hops:graph"},
+		{"default budget is twice K", GraphExpansion{Hops: 2}, "question:vector answer:graph"},
+		{"budget of K adds nothing", GraphExpansion{Hops: 2, Budget: 1}, "question:vector"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			hits, err := retriever.Search(ctx, "who builds KuzuDB", SearchOptions{K: 1, GraphExpansion: &c.expansion})
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			if got := strings.Join(describe(hits), " "); got != c.expected {
+				t.Errorf("Expected %s, got %s", c.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package retrieval
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/embedding"
+	"github.com/sandwichlabs/agent-memory-graph/internal/storage"
+)
+
+// defaultMinScores are similarity thresholds calibrated per embedding provider:
+// unrelated text scores around 0.5 with Gemini embeddings but around 0.65 with
+// Mistral's, so one threshold does not fit both.
+var defaultMinScores = map[embedding.Provider]float64{
+	embedding.ProviderGemini:  0.55,
+	embedding.ProviderMistral: 0.7,
+}
+
+// DefaultMinScore returns the SearchFilter.MinScore below which hits from provider's
+// embeddings are unlikely to be relevant, or 0, no threshold, for providers without
+// a calibration such as the test mock.
+func DefaultMinScore(provider embedding.Provider) float64 {
+	return defaultMinScores[provider]
+}
+
+// SearchFilter restricts a search to chunks of matching documents. Every set field
+// must match; zero values match everything. Filters apply in the store before the
+// K cutoff, so a filtered search still returns up to K hits.
+type SearchFilter struct {
+	Collection   string
+	DocumentID   string
+	SourcePrefix string
+	// AnyTags matches documents with at least one of the tags, AllTags those with
+	// every one of them. At most one of the two may be set.
+	AnyTags []string
+	AllTags []string
+	// IngestedAfter and IngestedBefore bound when the document was last ingested,
+	// inclusively.
+	IngestedAfter  time.Time
+	IngestedBefore time.Time
+	// MinScore, when non-zero, drops similarity hits scoring below it, see
+	// DefaultMinScore. Cosine scores range from -1 to 1.
+	MinScore float64
+}
+
+// Validate reports combinations of fields that can never match or contradict each other.
+func (f SearchFilter) Validate() error {
+	switch {
+	case len(f.AnyTags) > 0 && len(f.AllTags) > 0:
+		return fmt.Errorf("filter by any tags or all tags, not both")
+	case f.DocumentID != "" && (f.Collection != "" || f.SourcePrefix != ""):
+		return fmt.Errorf("a document ID already selects a single document; drop the collection and source prefix filters")
+	case !f.IngestedAfter.IsZero() && !f.IngestedBefore.IsZero() && f.IngestedAfter.After(f.IngestedBefore):
+		return fmt.Errorf("ingested-after %s is later than ingested-before %s",
+			f.IngestedAfter.Format(time.RFC3339), f.IngestedBefore.Format(time.RFC3339))
+	case f.MinScore < -1 || f.MinScore > 1:
+		return fmt.Errorf("minimum score %v is outside [-1, 1]", f.MinScore)
+	}
+	return nil
+}
+
+func (f SearchFilter) storage() storage.ChunkFilter {
+	return storage.ChunkFilter{
+		Collection:     f.Collection,
+		DocumentID:     f.DocumentID,
+		SourcePrefix:   f.SourcePrefix,
+		AnyTags:        f.AnyTags,
+		AllTags:        f.AllTags,
+		IngestedAfter:  f.IngestedAfter,
+		IngestedBefore: f.IngestedBefore,
+		MinScore:       f.MinScore,
+	}
+}
@@ -0,0 +1,81 @@
+package retrieval
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSearchFilter_Validate(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		name    string
+		filter  SearchFilter
+		wantErr bool
+	}{
+		{"empty", SearchFilter{}, false},
+		{"collection and tags", SearchFilter{Collection: "notes", AnyTags: []string{"a"}}, false},
+		{"any and all tags", SearchFilter{AnyTags: []string{"a"}, AllTags: []string{"b"}}, true},
+		{"document and collection", SearchFilter{DocumentID: "d1", Collection: "notes"}, true},
+		{"document and source prefix", SearchFilter{DocumentID: "d1", SourcePrefix: "notes/"}, true},
+		{"inverted range", SearchFilter{IngestedAfter: now, IngestedBefore: now.Add(-time.Hour)}, true},
+		{"open range", SearchFilter{IngestedAfter: now}, false},
+		{"min score too high", SearchFilter{MinScore: 1.5}, true},
+		{"negative min score", SearchFilter{MinScore: -0.5}, false},
+	}
+	for _, tc := range cases {
+		if err := tc.filter.Validate(); (err != nil) != tc.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
+
+func TestSearch_FilterAppliesBeforeK(t *testing.T) {
+	retriever, _ := newFixture()
+
+	// "far" ranks last overall but is the only chunk in the notes collection.
+	hits, err := retriever.Search(context.Background(), "query", SearchOptions{K: 1, Filter: SearchFilter{Collection: "notes"}})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(hits) != 1 || hits[0].ChunkID != "far" {
+		t.Fatalf("Expected the notes chunk, got %+v", hits)
+	}
+
+	_, err = retriever.Search(context.Background(), "query", SearchOptions{Filter: SearchFilter{MinScore: 2}})
+	if err == nil {
+		t.Errorf("Expected an invalid filter to fail the search")
+	}
+}
+
+func TestRetrieve_NoRelevantResults(t *testing.T) {
+	retriever, _ := newFixture()
+	ctx := context.Background()
+
+	// Every chunk scores below 0.99 except "near", whose vector matches the query.
+	results, err := retriever.Retrieve(ctx, "query", SearchOptions{Filter: SearchFilter{MinScore: 0.99}})
+	if err != nil {
+		t.Fatalf("Retrieve failed: %v", err)
+	}
+	if results.NoRelevantResults || len(results.Hits) != 1 {
+		t.Errorf("Expected the one relevant hit, got %+v", results)
+	}
+
+	// Keyword matches alone do not make the notes collection relevant.
+	results, err = retriever.Retrieve(ctx, "far", SearchOptions{Hybrid: true, Filter: SearchFilter{Collection: "notes", MinScore: 0.9}})
+	if err != nil {
+		t.Fatalf("Retrieve failed: %v", err)
+	}
+	if !results.NoRelevantResults || len(results.Hits) != 0 {
+		t.Errorf("Expected no relevant results, got %+v", results)
+	}
+
+	// An empty collection is not the same as an irrelevant query.
+	results, err = retriever.Retrieve(ctx, "far", SearchOptions{Filter: SearchFilter{Collection: "missing", MinScore: 0.9}})
+	if err != nil {
+		t.Fatalf("Retrieve failed: %v", err)
+	}
+	if results.NoRelevantResults || len(results.Hits) != 0 {
+		t.Errorf("Expected no hits without the no-relevant-results outcome, got %+v", results)
+	}
+}
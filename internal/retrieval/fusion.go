@@ -0,0 +1,104 @@
+package retrieval
+
+import (
+	"slices"
+	"sort"
+)
+
+// DefaultRRFK is the reciprocal rank fusion constant used when FusionOptions.K is
+// zero. Larger values flatten the advantage of the top ranks.
+const DefaultRRFK = 60
+
+// Names of the built-in retrievers, as recorded in Hit.Retrievers.
+const (
+	RetrieverVector  = "vector"
+	RetrieverKeyword = "keyword"
+)
+
+// Ranking is one retriever's hits, best first. Several rankings may come from the
+// same retriever, such as one per query of an expanded search.
+type Ranking struct {
+	Retriever string
+	Hits      []Hit
+}
+
+// FusionOptions configures Fuse.
+type FusionOptions struct {
+	// K is the rank constant; DefaultRRFK when zero.
+	K float64
+	// Weights scales each retriever's contribution by name. Retrievers without a
+	// weight count once.
+	Weights map[string]float64
+}
+
+// Fuse merges rankings with weighted reciprocal rank fusion: a hit at 1-based rank r
+// of a retriever with weight w scores w/(K+r), summed across retrievers. Hits are
+// deduplicated by chunk ID, recording every contributing retriever, and scores are
+// normalized so a hit ranked first by every retriever that returned hits scores 1. Ties are broken by
+// the hit's best rank, then by chunk ID.
+func Fuse(rankings []Ranking, opts FusionOptions) []Hit {
+	k := opts.K
+	if k <= 0 {
+		k = DefaultRRFK
+	}
+	weight := func(retriever string) float64 {
+		if w, ok := opts.Weights[retriever]; ok {
+			return w
+		}
+		return 1
+	}
+
+	type fused struct {
+		hit      Hit
+		bestRank int
+	}
+	byID := make(map[string]*fused)
+	var order []*fused
+	maxScore := 0.0
+	for _, ranking := range rankings {
+		w := weight(ranking.Retriever)
+		if len(ranking.Hits) > 0 {
+			maxScore += w / (k + 1)
+		}
+		// A retriever listing a chunk twice only counts its best rank.
+		seen := make(map[string]bool, len(ranking.Hits))
+		for rank, hit := range ranking.Hits {
+			if seen[hit.ChunkID] {
+				continue
+			}
+			seen[hit.ChunkID] = true
+
+			f, ok := byID[hit.ChunkID]
+			if !ok {
+				f = &fused{hit: hit, bestRank: rank + 1}
+				f.hit.Score, f.hit.Retrievers = 0, nil
+				byID[hit.ChunkID] = f
+				order = append(order, f)
+			}
+			f.bestRank = min(f.bestRank, rank+1)
+			if !slices.Contains(f.hit.Retrievers, ranking.Retriever) {
+				f.hit.Retrievers = append(f.hit.Retrievers, ranking.Retriever)
+			}
+			f.hit.Score += w / (k + float64(rank+1))
+		}
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := order[i], order[j]
+		if a.hit.Score != b.hit.Score {
+			return a.hit.Score > b.hit.Score
+		}
+		if a.bestRank != b.bestRank {
+			return a.bestRank < b.bestRank
+		}
+		return a.hit.ChunkID < b.hit.ChunkID
+	})
+	hits := make([]Hit, len(order))
+	for i, f := range order {
+		hits[i] = f.hit
+		if maxScore > 0 {
+			hits[i].Score /= maxScore
+		}
+	}
+	return hits
+}
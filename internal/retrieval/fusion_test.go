@@ -0,0 +1,139 @@
+package retrieval
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// ranked builds a ranking from chunk IDs, best first.
+func ranked(retriever string, ids ...string) Ranking {
+	ranking := Ranking{Retriever: retriever}
+	for _, id := range ids {
+		ranking.Hits = append(ranking.Hits, Hit{ChunkID: id, Content: id, Score: 0.5, Retrievers: []string{retriever}})
+	}
+	return ranking
+}
+
+// describe renders hits as "id:retriever+retriever" for compact comparisons.
+func describe(hits []Hit) []string {
+	out := make([]string, len(hits))
+	for i, hit := range hits {
+		out[i] = hit.ChunkID + ":" + strings.Join(hit.Retrievers, "+")
+	}
+	return out
+}
+
+func TestFuse(t *testing.T) {
+	cases := []struct {
+		name     string
+		rankings []Ranking
+		opts     FusionOptions
+		expected []string
+		topScore float64
+	}{
+		{
+			name:     "single list keeps its order",
+			rankings: []Ranking{ranked("vector", "a", "b", "c")},
+			expected: []string{"a:vector", "b:vector", "c:vector"},
+			topScore: 1,
+		},
+		{
+			name:     "no lists",
+			rankings: nil,
+			expected: []string{},
+		},
+		{
+			name:     "both lists empty",
+			rankings: []Ranking{ranked("vector"), ranked("keyword")},
+			expected: []string{},
+		},
+		{
+			name:     "one empty list normalizes against the other alone",
+			rankings: []Ranking{ranked("vector", "a", "b"), ranked("keyword")},
+			expected: []string{"a:vector", "b:vector"},
+			topScore: 1,
+		},
+		{
+			name:     "disjoint lists interleave with ties broken by chunk ID",
+			rankings: []Ranking{ranked("vector", "b", "d"), ranked("keyword", "a", "c")},
+			expected: []string{"a:keyword", "b:vector", "c:keyword", "d:vector"},
+			topScore: 0.5,
+		},
+		{
+			name:     "agreement beats a single first place",
+			rankings: []Ranking{ranked("vector", "a", "c"), ranked("keyword", "b", "c")},
+			expected: []string{"c:vector+keyword", "a:vector", "b:keyword"},
+		},
+		{
+			name:     "first place in every list scores 1",
+			rankings: []Ranking{ranked("vector", "a", "b"), ranked("keyword", "a", "c")},
+			expected: []string{"a:vector+keyword", "b:vector", "c:keyword"},
+			topScore: 1,
+		},
+		{
+			name:     "duplicates within a list count once at their best rank",
+			rankings: []Ranking{ranked("vector", "a", "b", "a"), ranked("keyword", "b")},
+			expected: []string{"b:vector+keyword", "a:vector"},
+		},
+		{
+			name:     "weights favor a retriever",
+			rankings: []Ranking{ranked("vector", "a", "b"), ranked("keyword", "c", "d")},
+			opts:     FusionOptions{Weights: map[string]float64{"keyword": 2}},
+			expected: []string{"c:keyword", "d:keyword", "a:vector", "b:vector"},
+		},
+		{
+			name:     "zero weight keeps hits but ranks them last",
+			rankings: []Ranking{ranked("vector", "a"), ranked("keyword", "b")},
+			opts:     FusionOptions{Weights: map[string]float64{"vector": 0}},
+			expected: []string{"b:keyword", "a:vector"},
+			topScore: 1,
+		},
+		{
+			name:     "default K rewards agreement across ranks",
+			rankings: []Ranking{ranked("vector", "a", "b"), ranked("keyword", "c", "d", "e", "f", "b")},
+			expected: []string{"b:vector+keyword", "a:vector", "c:keyword", "d:keyword", "e:keyword", "f:keyword"},
+		},
+		{
+			name:     "small K rewards top ranks",
+			rankings: []Ranking{ranked("vector", "a", "b"), ranked("keyword", "c", "d", "e", "f", "b")},
+			opts:     FusionOptions{K: 0.5},
+			expected: []string{"a:vector", "c:keyword", "b:vector+keyword", "d:keyword", "e:keyword", "f:keyword"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			hits := Fuse(c.rankings, c.opts)
+			got := describe(hits)
+			if strings.Join(got, " ") != strings.Join(c.expected, " ") {
+				t.Fatalf("Expected %v, got %v", c.expected, got)
+			}
+			for i := 1; i < len(hits); i++ {
+				if hits[i].Score > hits[i-1].Score {
+					t.Errorf("Expected scores in descending order, got %v then %v", hits[i-1].Score, hits[i].Score)
+				}
+			}
+			for _, hit := range hits {
+				if hit.Score < 0 || hit.Score > 1 {
+					t.Errorf("Expected normalized scores, got %v for %s", hit.Score, hit.ChunkID)
+				}
+			}
+			if c.topScore != 0 && math.Abs(hits[0].Score-c.topScore) > 1e-9 {
+				t.Errorf("Expected top score %v, got %v", c.topScore, hits[0].Score)
+			}
+		})
+	}
+}
+
+func TestFuse_DoesNotModifyInputs(t *testing.T) {
+	vector := ranked("vector", "a")
+	keyword := ranked("keyword", "a")
+
+	hits := Fuse([]Ranking{vector, keyword}, FusionOptions{})
+	if len(hits) != 1 || hits[0].Content != "a" {
+		t.Fatalf("Expected the fused hit to keep its content, got %+v", hits)
+	}
+	if len(vector.Hits[0].Retrievers) != 1 || vector.Hits[0].Score != 0.5 {
+		t.Errorf("Expected the input hit to be unchanged, got %+v", vector.Hits[0])
+	}
+}
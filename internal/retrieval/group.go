@@ -0,0 +1,89 @@
+package retrieval
+
+import (
+	"context"
+	"sort"
+)
+
+// DefaultChunksPerGroup is the number of hits a document group shows when Grouping
+// does not say.
+const DefaultChunksPerGroup = 3
+
+// Grouping configures grouping hits by document, see GroupByDocument.
+type Grouping struct {
+	// ChunksPerGroup caps the hits in each group; DefaultChunksPerGroup when zero.
+	ChunksPerGroup int
+	// Context attaches to each grouped hit the chunks before and after it in its
+	// document, read along NEXT_CHUNK edges.
+	Context bool
+}
+
+// Group is the hits from one document, best first.
+type Group struct {
+	DocumentID string
+	Source     string
+	Collection string
+	// Score is the best hit's score; groups are ordered by it.
+	Score float64
+	Hits  []Hit
+	// Omitted counts the document's hits beyond ChunksPerGroup. They remain in
+	// Results.Hits.
+	Omitted int
+}
+
+// GroupByDocument gathers hits by document, keeping up to perGroup hits of each in
+// their order, and orders the groups by their best hit's score. hits is not
+// modified, so a grouped search pages through the same ranking as a flat one.
+func GroupByDocument(hits []Hit, perGroup int) []Group {
+	var groups []Group
+	index := make(map[string]int)
+	for _, hit := range hits {
+		i, ok := index[hit.DocumentID]
+		if !ok {
+			i = len(groups)
+			index[hit.DocumentID] = i
+			groups = append(groups, Group{DocumentID: hit.DocumentID, Source: hit.Source, Collection: hit.Collection, Score: hit.Score})
+		}
+		group := &groups[i]
+		group.Score = max(group.Score, hit.Score)
+		if len(group.Hits) < perGroup {
+			group.Hits = append(group.Hits, hit)
+		} else {
+			group.Omitted++
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].Score > groups[j].Score })
+	return groups
+}
+
+// attachContext sets the Context of the grouped hits. A neighbour that is itself a
+// hit of the group is left out, as it is shown already.
+func (r *Retriever) attachContext(ctx context.Context, groups []Group) error {
+	var ids []string
+	for _, group := range groups {
+		for _, hit := range group.Hits {
+			ids = append(ids, hit.ChunkID)
+		}
+	}
+	neighbours, err := r.store.AdjacentChunks(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, group := range groups {
+		shown := make(map[string]bool, len(group.Hits))
+		for _, hit := range group.Hits {
+			shown[hit.ChunkID] = true
+		}
+		for i := range group.Hits {
+			adjacent := neighbours[group.Hits[i].ChunkID]
+			if adjacent.Previous != nil && shown[adjacent.Previous.ID] {
+				adjacent.Previous = nil
+			}
+			if adjacent.Next != nil && shown[adjacent.Next.ID] {
+				adjacent.Next = nil
+			}
+			group.Hits[i].Context = adjacent
+		}
+	}
+	return nil
+}
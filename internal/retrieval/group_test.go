@@ -0,0 +1,90 @@
+package retrieval
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestGroupByDocument(t *testing.T) {
+	hits := []Hit{
+		{ChunkID: "a0", DocumentID: "a", Score: 0.5},
+		{ChunkID: "b0", DocumentID: "b", Source: "b.md", Score: 0.9},
+		{ChunkID: "a1", DocumentID: "a", Score: 0.4},
+		{ChunkID: "a2", DocumentID: "a", Score: 0.3},
+	}
+
+	groups := GroupByDocument(hits, 2)
+	if len(groups) != 2 || groups[0].DocumentID != "b" || groups[0].Source != "b.md" || groups[0].Score != 0.9 {
+		t.Fatalf("Expected document b first by its best score, got %+v", groups)
+	}
+	if got := describe(groups[1].Hits); !reflect.DeepEqual(got, []string{"a0:", "a1:"}) || groups[1].Omitted != 1 || groups[1].Score != 0.5 {
+		t.Errorf("Expected a0 and a1 with one hit omitted, got %v (%d omitted)", got, groups[1].Omitted)
+	}
+	if hits[1].ChunkID != "b0" {
+		t.Errorf("Expected the hits left in order, got %v", describe(hits))
+	}
+}
+
+func TestRetrieve_GroupsByDocumentWithContext(t *testing.T) {
+	retriever, _ := newFixture()
+
+	results, err := retriever.Retrieve(context.Background(), "query", SearchOptions{Grouping: &Grouping{ChunksPerGroup: 1, Context: true}})
+	if err != nil {
+		t.Fatalf("Retrieve failed: %v", err)
+	}
+	if got := describe(results.Hits); !reflect.DeepEqual(got, []string{"near:vector", "mid:vector", "far:vector"}) {
+		t.Errorf("Expected the flat hits unchanged, got %v", got)
+	}
+	if len(results.Groups) != 2 || results.Groups[0].DocumentID != "d1" || results.Groups[1].DocumentID != "d2" {
+		t.Fatalf("Expected groups d1 and d2, got %+v", results.Groups)
+	}
+	near := results.Groups[0].Hits[0]
+	if near.ChunkID != "near" || near.Context.Previous == nil || near.Context.Previous.ID != "mid" || near.Context.Next != nil {
+		t.Errorf("Expected near with mid before it, got %+v", near)
+	}
+	if results.Groups[1].Hits[0].Context.Previous != nil || results.Groups[1].Hits[0].Context.Next != nil {
+		t.Errorf("Expected no context for a single-chunk document, got %+v", results.Groups[1].Hits[0].Context)
+	}
+
+	// A neighbour shown in the group is not repeated as context.
+	results, err = retriever.Retrieve(context.Background(), "query", SearchOptions{Grouping: &Grouping{Context: true}})
+	if err != nil {
+		t.Fatalf("Retrieve failed: %v", err)
+	}
+	if got := describe(results.Groups[0].Hits); !reflect.DeepEqual(got, []string{"near:vector", "mid:vector"}) || results.Groups[0].Hits[0].Context.Previous != nil {
+		t.Errorf("Expected near and mid without context, got %+v", results.Groups[0].Hits)
+	}
+
+	if _, err := retriever.Retrieve(context.Background(), "query", SearchOptions{Grouping: &Grouping{ChunksPerGroup: -1}}); err == nil {
+		t.Error("Expected an error for a negative group size")
+	}
+}
+
+func TestRetrieve_PagesGroupsLikeFlatHits(t *testing.T) {
+	retriever, _ := newFixture()
+
+	for _, grouping := range []*Grouping{nil, {ChunksPerGroup: 1}} {
+		first, err := retriever.Retrieve(context.Background(), "query", SearchOptions{K: 2, Grouping: grouping})
+		if err != nil {
+			t.Fatalf("Retrieve failed: %v", err)
+		}
+		if got := describe(first.Hits); !reflect.DeepEqual(got, []string{"near:vector", "mid:vector"}) || !first.More {
+			t.Errorf("Expected near and mid with more to come, got %v (more %v)", got, first.More)
+		}
+		second, err := retriever.Retrieve(context.Background(), "query", SearchOptions{K: 2, Offset: 2, Grouping: grouping})
+		if err != nil {
+			t.Fatalf("Retrieve failed: %v", err)
+		}
+		if got := describe(second.Hits); !reflect.DeepEqual(got, []string{"far:vector"}) || second.More {
+			t.Errorf("Expected far on the last page, got %v (more %v)", got, second.More)
+		}
+		if grouping != nil && (len(second.Groups) != 1 || second.Groups[0].DocumentID != "d2") {
+			t.Errorf("Expected the second page grouped on its own, got %+v", second.Groups)
+		}
+	}
+
+	if _, err := retriever.Retrieve(context.Background(), "query", SearchOptions{Offset: -1}); err == nil {
+		t.Error("Expected an error for a negative offset")
+	}
+}
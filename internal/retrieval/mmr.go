@@ -0,0 +1,62 @@
+package retrieval
+
+import "math"
+
+// DefaultMMRLambda weighs relevance over novelty for callers without a preference.
+const DefaultMMRLambda = 0.7
+
+// MMR configures maximal marginal relevance re-selection, see Diversify.
+type MMR struct {
+	// Lambda trades relevance (1) against novelty (0).
+	Lambda float64
+}
+
+// Diversify picks up to k hits by maximal marginal relevance: each step takes the
+// hit maximizing lambda*score - (1-lambda)*max similarity to the hits already
+// picked, where similarity is the cosine of their vectors. Hits without a vector
+// count as dissimilar to everything. Ties keep the input order.
+func Diversify(hits []Hit, vectors map[string][]float32, lambda float64, k int) []Hit {
+	if k > len(hits) {
+		k = len(hits)
+	}
+	selected := make([]Hit, 0, k)
+	picked := make([]bool, len(hits))
+	// redundancy[i] is hit i's highest similarity to any selected hit.
+	redundancy := make([]float64, len(hits))
+	for len(selected) < k {
+		best, bestValue := -1, math.Inf(-1)
+		for i, hit := range hits {
+			if picked[i] {
+				continue
+			}
+			if value := lambda*hit.Score - (1-lambda)*redundancy[i]; value > bestValue {
+				best, bestValue = i, value
+			}
+		}
+		picked[best] = true
+		selected = append(selected, hits[best])
+		for i, hit := range hits {
+			if !picked[i] {
+				redundancy[i] = max(redundancy[i], cosine(vectors[hit.ChunkID], vectors[hits[best].ChunkID]))
+			}
+		}
+	}
+	return selected
+}
+
+// cosine returns the cosine similarity of a and b, or 0 when either is missing.
+func cosine(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
@@ -0,0 +1,65 @@
+package retrieval
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestDiversify(t *testing.T) {
+	// Pairwise similarities: a~dup 1, a~c 0.6, b~c 0.8, a~b 0; "novel" has no vector.
+	vectors := map[string][]float32{
+		"a":   {1, 0},
+		"dup": {1, 0},
+		"b":   {0, 1},
+		"c":   {0.6, 0.8},
+	}
+	hits := []Hit{
+		{ChunkID: "a", Score: 0.9},
+		{ChunkID: "dup", Score: 0.85},
+		{ChunkID: "c", Score: 0.7},
+		{ChunkID: "b", Score: 0.6},
+		{ChunkID: "novel", Score: 0.1},
+	}
+	cases := []struct {
+		name     string
+		lambda   float64
+		k        int
+		expected string
+	}{
+		{"pure relevance keeps the ranking", 1, 5, "a dup c b novel"},
+		{"balanced skips the duplicate", 0.5, 3, "a b novel"},
+		{"pure novelty prefers unseen directions", 0, 4, "a b novel c"},
+		{"k beyond the candidates returns them all", 0.5, 10, "a b novel c dup"},
+		{"k of zero returns nothing", 0.5, 0, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			selected := Diversify(hits, vectors, c.lambda, c.k)
+			ids := make([]string, len(selected))
+			for i, hit := range selected {
+				ids[i] = hit.ChunkID
+			}
+			if got := strings.Join(ids, " "); got != c.expected {
+				t.Errorf("Expected %q, got %q", c.expected, got)
+			}
+		})
+	}
+}
+
+func TestSearch_MMR(t *testing.T) {
+	retriever, _ := newFixture()
+	ctx := context.Background()
+
+	hits, err := retriever.Search(ctx, "near", SearchOptions{K: 2, MMR: &MMR{Lambda: 0.1}})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(hits) != 2 || hits[0].ChunkID != "near" || hits[1].ChunkID != "far" {
+		t.Errorf("Expected near and the dissimilar far, got %v", describe(hits))
+	}
+
+	if _, err := retriever.Search(ctx, "near", SearchOptions{MMR: &MMR{Lambda: 1.5}}); err == nil {
+		t.Error("Expected an error for a lambda outside [0, 1], got nil")
+	}
+}
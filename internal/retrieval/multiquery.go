@@ -0,0 +1,76 @@
+package retrieval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// DefaultParaphrases is how many alternative queries QueryExpansion asks for when
+// Paraphrases is zero.
+const DefaultParaphrases = 3
+
+// QueryExpansion configures the multi-query stage of Search: the retriever's LLM
+// rewrites the query as paraphrases and sub-questions, each is searched on its own,
+// and the rankings are fused with reciprocal rank fusion, see Fuse. It costs an LLM
+// call per search.
+type QueryExpansion struct {
+	// Paraphrases is how many alternative queries to ask for; DefaultParaphrases
+	// when zero.
+	Paraphrases int
+}
+
+// paraphrase asks the LLM for up to n alternative phrasings of query. It returns
+// none when the LLM fails or replies with something other than a JSON array of
+// strings, so the search degrades to the original query.
+func (r *Retriever) paraphrase(ctx context.Context, query string, n int) []string {
+	reply, err := r.llm.GenerateText(ctx, paraphrasePrompt(query, n))
+	if err != nil {
+		slog.WarnContext(ctx, "query expansion failed, searching the original query only", "error", err)
+		return nil
+	}
+	queries, err := parseParaphrases(reply, query, n)
+	if err != nil {
+		slog.WarnContext(ctx, "query expansion failed, searching the original query only", "error", err)
+		return nil
+	}
+	return queries
+}
+
+func paraphrasePrompt(query string, n int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Rewrite the search query below as %d alternative queries that could find the same information: ", n)
+	b.WriteString("paraphrases using other words, or sub-questions it depends on. ")
+	b.WriteString("Reply with only a JSON array of strings.\n\n")
+	fmt.Fprintf(&b, "Query: %s\n", query)
+	return b.String()
+}
+
+// parseParaphrases reads the JSON array in reply, tolerating surrounding prose or
+// code fences, and keeps up to n distinct queries that differ from the original.
+func parseParaphrases(reply, query string, n int) ([]string, error) {
+	start, end := strings.Index(reply, "["), strings.LastIndex(reply, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no JSON array in reply %q", reply)
+	}
+	var candidates []string
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &candidates); err != nil {
+		return nil, fmt.Errorf("invalid paraphrases: %w", err)
+	}
+	seen := map[string]bool{normalizeQuery(query): true}
+	var queries []string
+	for _, candidate := range candidates {
+		key := normalizeQuery(candidate)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		queries = append(queries, strings.TrimSpace(candidate))
+		if len(queries) == n {
+			break
+		}
+	}
+	return queries, nil
+}
@@ -0,0 +1,136 @@
+package retrieval
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/embedding"
+)
+
+// phrasebook embeds the texts it knows with fixed vectors and anything else like
+// the mock service.
+type phrasebook struct {
+	vectors map[string][]float32
+	texts   []string
+}
+
+func (p *phrasebook) GetEmbeddings(ctx context.Context, text string, embeddingType embedding.EmbeddingType) (embedding.EmbedResponse, error) {
+	p.texts = append(p.texts, text)
+	if vector, ok := p.vectors[text]; ok {
+		return vector, nil
+	}
+	return embedding.NewMockService().GetEmbeddings(ctx, text, embeddingType)
+}
+
+func (p *phrasebook) GetEmbeddingsBatch(ctx context.Context, texts []string, embeddingType embedding.EmbeddingType) ([]embedding.EmbedResponse, error) {
+	vectors := make([]embedding.EmbedResponse, len(texts))
+	for n, text := range texts {
+		vectors[n], _ = p.GetEmbeddings(ctx, text, embeddingType)
+	}
+	return vectors, nil
+}
+
+func (p *phrasebook) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (p *phrasebook) GetType() embedding.Provider {
+	return embedding.ProviderTestMock
+}
+
+func TestSearch_ExpansionFusesParaphraseRankings(t *testing.T) {
+	retriever, _ := newFixture()
+	// Both paraphrases rank far, mid, near; the original query ranks near, mid, far.
+	embeddings := &phrasebook{vectors: map[string][]float32{"p1": vectorFor(5), "p2": vectorFor(5)}}
+	retriever.embeddings = embeddings
+	llm := &scriptedLLM{replies: []string{"```json\n[\"p1\", \"p2\"]\n```"}}
+	retriever.WithLLM(llm)
+
+	hits, err := retriever.Search(context.Background(), "original", SearchOptions{Expansion: &QueryExpansion{Paraphrases: 2}})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if want := []string{"original", "p1", "p2"}; !reflect.DeepEqual(embeddings.texts, want) {
+		t.Errorf("Expected embeddings of %v, got %v", want, embeddings.texts)
+	}
+
+	rrf := func(ranks ...int) float64 {
+		var score float64
+		for _, rank := range ranks {
+			score += 1 / (DefaultRRFK + float64(rank))
+		}
+		return score / (3.0 / (DefaultRRFK + 1))
+	}
+	want := []struct {
+		id    string
+		score float64
+	}{
+		{"far", rrf(3, 1, 1)},
+		{"mid", rrf(2, 2, 2)},
+		{"near", rrf(1, 3, 3)},
+	}
+	if len(hits) != len(want) {
+		t.Fatalf("Expected %d hits, got %+v", len(want), hits)
+	}
+	for i, w := range want {
+		if hits[i].ChunkID != w.id || math.Abs(hits[i].Score-w.score) > 1e-9 {
+			t.Errorf("Expected hit %d to be %s scoring %f, got %s scoring %f", i, w.id, w.score, hits[i].ChunkID, hits[i].Score)
+		}
+		if !reflect.DeepEqual(hits[i].Retrievers, []string{RetrieverVector}) {
+			t.Errorf("Expected %s found by the vector retriever once, got %v", hits[i].ChunkID, hits[i].Retrievers)
+		}
+	}
+}
+
+func TestSearch_ExpansionDegradesToSingleQuery(t *testing.T) {
+	cases := []struct {
+		name string
+		llm  *scriptedLLM
+	}{
+		{"llm failure", &scriptedLLM{err: errors.New("unavailable")}},
+		{"not json", &scriptedLLM{replies: []string{"Sure! Here are some ideas."}}},
+		{"only the original", &scriptedLLM{replies: []string{`["Original "]`}}},
+	}
+	plain, _ := newFixture()
+	want, err := plain.Search(context.Background(), "original", SearchOptions{})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	for _, tc := range cases {
+		retriever, embeddings := newFixture()
+		retriever.WithLLM(tc.llm)
+		hits, err := retriever.Search(context.Background(), "original", SearchOptions{Expansion: &QueryExpansion{}})
+		if err != nil {
+			t.Fatalf("%s: Search failed: %v", tc.name, err)
+		}
+		if len(embeddings.types) != 1 {
+			t.Errorf("%s: expected only the original query embedded, got %d embeddings", tc.name, len(embeddings.types))
+		}
+		if !reflect.DeepEqual(hits, want) {
+			t.Errorf("%s: expected the plain similarity ranking %+v, got %+v", tc.name, want, hits)
+		}
+	}
+}
+
+func TestSearch_ExpansionNeedsLLM(t *testing.T) {
+	retriever, _ := newFixture()
+	if _, err := retriever.Search(context.Background(), "original", SearchOptions{Expansion: &QueryExpansion{}}); err == nil {
+		t.Errorf("Expected an error without an LLM")
+	}
+}
+
+func TestParseParaphrases(t *testing.T) {
+	got, err := parseParaphrases(`Here you go: ["kuzu graph db", " Original ", "", "kuzu graph DB", "cypher engine", "extra"]`, "original", 2)
+	if err != nil {
+		t.Fatalf("parseParaphrases failed: %v", err)
+	}
+	if want := []string{"kuzu graph db", "cypher engine"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if _, err := parseParaphrases(`{"queries": "none"}`, "original", 2); err == nil {
+		t.Errorf("Expected an error for a reply without an array")
+	}
+}
@@ -0,0 +1,30 @@
+package retrieval
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Recency configures time decay of search scores, for memories where newer notes
+// usually supersede older ones.
+type Recency struct {
+	// HalfLife is the document age at which a hit's score is halved.
+	HalfLife time.Duration
+}
+
+// apply scales each hit's score by 0.5^(age/HalfLife), where age is the time since
+// its document was ingested as of now, and re-sorts the hits. Negative scores, which
+// would grow towards zero, and hits with an unknown or future ingestion time are not
+// decayed.
+func (r Recency) apply(hits []Hit, now time.Time) []Hit {
+	for i := range hits {
+		age := now.Sub(hits[i].IngestedAt)
+		if hits[i].Score <= 0 || hits[i].IngestedAt.IsZero() || age <= 0 {
+			continue
+		}
+		hits[i].Score *= math.Exp2(-float64(age) / float64(r.HalfLife))
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	return hits
+}
@@ -0,0 +1,57 @@
+package retrieval
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/storage"
+)
+
+func TestSearch_RecencyFavorsFreshChunks(t *testing.T) {
+	now := time.Now()
+	store := &fakeStore{chunks: []storage.ScoredChunk{
+		{Chunk: storage.Chunk{ID: "old", Content: "old", Embedding: vectorFor(0)}, IngestedAt: now.Add(-365 * 24 * time.Hour)},
+		{Chunk: storage.Chunk{ID: "fresh", Content: "fresh", Embedding: vectorFor(0.05)}, IngestedAt: now.Add(-24 * time.Hour)},
+	}}
+	retriever := NewRetriever(store, uniformService{})
+
+	hits, err := retriever.Search(context.Background(), "query", SearchOptions{})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if hits[0].ChunkID != "old" {
+		t.Fatalf("Expected the old chunk to be more similar, got %+v", hits)
+	}
+	similarity := hits[1].Score
+
+	hits, err = retriever.Search(context.Background(), "query", SearchOptions{Recency: &Recency{HalfLife: 7 * 24 * time.Hour}})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if hits[0].ChunkID != "fresh" {
+		t.Fatalf("Expected the fresh chunk first under a week's half-life, got %+v", hits)
+	}
+	if want := similarity * math.Exp2(-1.0/7); math.Abs(hits[0].Score-want) > 1e-3 {
+		t.Errorf("Expected a day-old score of %f, got %f", want, hits[0].Score)
+	}
+}
+
+func TestRecency_SkipsUndatedAndNegativeHits(t *testing.T) {
+	now := time.Now()
+	hits := Recency{HalfLife: time.Hour}.apply([]Hit{
+		{ChunkID: "negative", Score: -0.5, IngestedAt: now.Add(-time.Hour)},
+		{ChunkID: "undated", Score: 0.2},
+		{ChunkID: "decayed", Score: 0.8, IngestedAt: now.Add(-time.Hour)},
+	}, now)
+	want := []struct {
+		id    string
+		score float64
+	}{{"decayed", 0.4}, {"undated", 0.2}, {"negative", -0.5}}
+	for i, w := range want {
+		if hits[i].ChunkID != w.id || hits[i].Score != w.score {
+			t.Errorf("Expected hit %d to be %s scoring %v, got %s scoring %v", i, w.id, w.score, hits[i].ChunkID, hits[i].Score)
+		}
+	}
+}
@@ -0,0 +1,384 @@
+// Package retrieval finds the stored chunks relevant to a query. It is the search
+// backend shared by the amg CLI and the MCP server.
+package retrieval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/embedding"
+	"github.com/sandwichlabs/agent-memory-graph/internal/storage"
+)
+
+// DefaultK is the number of hits Search returns when SearchOptions.K is zero.
+const DefaultK = 8
+
+// candidatePool is how many candidates each retriever contributes to a hybrid,
+// expanded, recency-weighted or diversified search, as a multiple of K, so the later
+// stages can promote hits from outside the top K.
+const candidatePool = 3
+
+// ErrEmptyQuery is returned by Search for a blank query.
+var ErrEmptyQuery = errors.New("query is empty")
+
+// GraphStore is the part of the memory graph the retriever reads. *storage.KuzuStore
+// implements it.
+type GraphStore interface {
+	SimilaritySearch(ctx context.Context, vector []float32, k int, filter storage.ChunkFilter) ([]storage.ScoredChunk, error)
+	KeywordSearch(ctx context.Context, query string, k int, filter storage.ChunkFilter) ([]storage.ScoredChunk, error)
+	MentionedEntities(ctx context.Context, chunkIDs []string) (map[string][]string, error)
+	RelatedEntities(ctx context.Context, names []string) (map[string][]string, error)
+	MentioningChunks(ctx context.Context, name string, limit int, filter storage.ChunkFilter) ([]storage.ScoredChunk, error)
+	ChunkEmbeddings(ctx context.Context, ids []string) (map[string][]float32, error)
+	AdjacentChunks(ctx context.Context, ids []string) (map[string]storage.Neighbours, error)
+}
+
+// SearchOptions configures Search.
+type SearchOptions struct {
+	// K is the maximum number of hits; DefaultK when zero.
+	K int
+	// Offset skips that many hits of the ranking, to page through it K hits at a
+	// time. Graph expansion and grouping apply to the page.
+	Offset int
+	Filter SearchFilter
+	// Hybrid fuses the vector ranking with a keyword ranking, see Fuse. The filter's
+	// MinScore then applies to the vector hits before fusion.
+	Hybrid bool
+	Fusion FusionOptions
+	// Expansion, when set, also searches LLM paraphrases of the query and fuses the
+	// rankings. It needs a retriever created with WithLLM.
+	Expansion *QueryExpansion
+	// Recency, when set, decays scores with document age after fusion.
+	Recency *Recency
+	// Dedup, when set, collapses near-identical hits into the best of them after
+	// fusion, so that K hits say K different things.
+	Dedup *Dedup
+	// MMR, when set, re-selects the K hits from a larger candidate set for
+	// diversity, after fusion.
+	MMR *MMR
+	// GraphExpansion, when set, adds chunks connected to the hits through their
+	// entities, so more than K hits may be returned.
+	GraphExpansion *GraphExpansion
+	// Routing, when set, classifies the query and answers entity and relational
+	// questions from the entity graph; other queries get a hybrid search.
+	Routing *Routing
+	// Snippets, when set, attaches to each hit the passage that best matches the
+	// query, see NewSnippet.
+	Snippets *Snippets
+	// Grouping, when set, also returns the hits grouped by document in
+	// Results.Groups.
+	Grouping *Grouping
+}
+
+// Hit is a chunk relevant to a query, with the document it came from.
+type Hit struct {
+	ChunkID string
+	Content string
+	Score   float64
+	// Index is the chunk's position in its document. StartOffset and EndOffset are
+	// byte offsets into the source, or -1 when unknown.
+	Index       int
+	StartOffset int
+	EndOffset   int
+	// Page is the page of the PDF the chunk was read from, counted from 1, or 0.
+	Page int
+
+	DocumentID string
+	Source     string
+	Collection string
+	// IngestedAt is when the document was last ingested.
+	IngestedAt time.Time
+
+	// Retrievers names the retrievers that found the chunk.
+	Retrievers []string
+	// Collapsed lists the IDs of near-duplicate chunks folded into this hit.
+	Collapsed []string
+	// Snippet is set when the search asked for snippets.
+	Snippet Snippet
+	// Context holds the chunks around a grouped hit when the search asked for them.
+	Context storage.Neighbours
+}
+
+// Retriever searches a memory graph by meaning.
+type Retriever struct {
+	store      GraphStore
+	embeddings embedding.Service
+
+	cache           *QueryCache
+	provider, model string
+	llm             Generator
+}
+
+// NewRetriever creates a retriever that embeds queries with embeddings and looks
+// them up in store. Without embeddings, such as when no embedding provider is
+// configured, it falls back to keyword search and marks its results KeywordOnly.
+func NewRetriever(store GraphStore, embeddings embedding.Service) *Retriever {
+	return &Retriever{store: store, embeddings: embeddings}
+}
+
+// KeywordOnly reports whether r ranks by keyword because it has no embeddings.
+func (r *Retriever) KeywordOnly() bool {
+	return r.embeddings == nil
+}
+
+// WithQueryCache makes r look query embeddings up in cache before calling the
+// embedding service. provider and model identify the service's vectors, so retrievers
+// for different models can share a cache. It returns r.
+func (r *Retriever) WithQueryCache(cache *QueryCache, provider, model string) *Retriever {
+	r.cache, r.provider, r.model = cache, provider, model
+	return r
+}
+
+// WithLLM gives r the language model used by query expansion. It returns r.
+func (r *Retriever) WithLLM(llm Generator) *Retriever {
+	r.llm = llm
+	return r
+}
+
+// Results is the outcome of Retrieve.
+type Results struct {
+	Hits []Hit
+	// NoRelevantResults reports that the graph holds chunks matching the filter but
+	// none of them is similar enough to the query to clear the filter's MinScore.
+	// Callers should say they have no information rather than answer from Hits,
+	// which is then empty.
+	NoRelevantResults bool
+	// KeywordOnly reports that the hits were ranked by keyword search alone, with
+	// scores that are BM25 or fusion scores rather than similarities.
+	KeywordOnly bool
+	// Route is how a search with Routing was answered, nil without.
+	Route *RouteDecision
+	// Groups holds Hits grouped by document for a search with Grouping.
+	Groups []Group
+	// More reports that the ranking has hits beyond this page, from Offset+K on.
+	More bool
+}
+
+// Search returns the chunks most relevant to query, best first. Scores are cosine
+// similarities, or normalized fusion scores for a hybrid or expanded search; graph
+// expansion derives its scores from these.
+func (r *Retriever) Search(ctx context.Context, query string, opts SearchOptions) ([]Hit, error) {
+	results, err := r.Retrieve(ctx, query, opts)
+	return results.Hits, err
+}
+
+// Retrieve is Search, also reporting whether the query matched nothing relevant.
+// When the filter has a MinScore and no similarity hit clears it, keyword matches of
+// a hybrid search are not considered relevant either.
+func (r *Retriever) Retrieve(ctx context.Context, query string, opts SearchOptions) (Results, error) {
+	if strings.TrimSpace(query) == "" {
+		return Results{}, ErrEmptyQuery
+	}
+	k := opts.K
+	if k <= 0 {
+		k = DefaultK
+	}
+	if err := opts.Filter.Validate(); err != nil {
+		return Results{}, err
+	}
+	if opts.MMR != nil && (opts.MMR.Lambda < 0 || opts.MMR.Lambda > 1) {
+		return Results{}, fmt.Errorf("MMR lambda %v is outside [0, 1]", opts.MMR.Lambda)
+	}
+	if opts.Expansion != nil && r.llm == nil {
+		return Results{}, errors.New("query expansion needs a retriever with an LLM")
+	}
+	if opts.Recency != nil && opts.Recency.HalfLife <= 0 {
+		return Results{}, fmt.Errorf("recency half-life %v is not positive", opts.Recency.HalfLife)
+	}
+	if opts.Dedup != nil && (opts.Dedup.Threshold < 0 || opts.Dedup.Threshold > 1) {
+		return Results{}, fmt.Errorf("dedup threshold %v is outside [0, 1]", opts.Dedup.Threshold)
+	}
+	if opts.Offset < 0 {
+		return Results{}, fmt.Errorf("offset %d is negative", opts.Offset)
+	}
+	if opts.Grouping != nil && opts.Grouping.ChunksPerGroup < 0 {
+		return Results{}, fmt.Errorf("chunks per group %d is negative", opts.Grouping.ChunksPerGroup)
+	}
+	if opts.Routing != nil {
+		// Topical and low-confidence queries get a hybrid search.
+		opts.Hybrid = true
+	}
+	// The ranking is cut one hit past the page, to tell whether another follows.
+	end := opts.Offset + k
+	pool := end + 1
+	if opts.Hybrid || opts.MMR != nil || opts.Expansion != nil || opts.Recency != nil || opts.Dedup != nil {
+		pool = end * candidatePool
+	}
+
+	filter := opts.Filter.storage()
+	var rankings []Ranking
+	var route *RouteDecision
+	if opts.Routing != nil {
+		decision, graph, err := r.route(ctx, query, *opts.Routing, pool, filter)
+		if err != nil {
+			return Results{}, err
+		}
+		route = &decision
+		if len(graph.Hits) > 0 {
+			rankings = append(rankings, graph)
+		}
+	}
+
+	queries := []string{query}
+	if route != nil && route.Strategy == StrategyGraph {
+		queries = nil
+	} else if opts.Expansion != nil {
+		n := opts.Expansion.Paraphrases
+		if n <= 0 {
+			n = DefaultParaphrases
+		}
+		queries = append(queries, r.paraphrase(ctx, query, n)...)
+	}
+	var queryVector []float32
+	similar := false
+	for _, text := range queries {
+		if r.KeywordOnly() {
+			chunks, err := r.store.KeywordSearch(ctx, text, pool, filter)
+			if err != nil {
+				return Results{}, err
+			}
+			rankings = append(rankings, newRanking(RetrieverKeyword, chunks))
+			continue
+		}
+		vector, err := r.embedQuery(ctx, text)
+		if err != nil {
+			return Results{}, err
+		}
+		if queryVector == nil {
+			queryVector = vector
+		}
+		chunks, err := r.store.SimilaritySearch(ctx, vector, pool, filter)
+		if err != nil {
+			return Results{}, err
+		}
+		similar = similar || len(chunks) > 0
+		rankings = append(rankings, newRanking(RetrieverVector, chunks))
+
+		if opts.Hybrid {
+			chunks, err = r.store.KeywordSearch(ctx, text, pool, filter)
+			if err != nil {
+				return Results{}, err
+			}
+			rankings = append(rankings, newRanking(RetrieverKeyword, chunks))
+		}
+	}
+	if !similar && filter.MinScore != 0 && !r.KeywordOnly() && (route == nil || route.Strategy == StrategyHybrid) {
+		// Tell a query with nothing relevant apart from an empty graph or filter.
+		unscored := filter
+		unscored.MinScore = 0
+		candidates, err := r.store.SimilaritySearch(ctx, queryVector, 1, unscored)
+		if err != nil {
+			return Results{}, err
+		}
+		if len(candidates) > 0 {
+			return Results{NoRelevantResults: true, Route: route}, nil
+		}
+	}
+
+	hits := rankings[0].Hits
+	if len(rankings) > 1 {
+		hits = Fuse(rankings, opts.Fusion)
+	}
+	if opts.Recency != nil {
+		hits = opts.Recency.apply(hits, time.Now())
+	}
+	if opts.Dedup != nil {
+		threshold := opts.Dedup.Threshold
+		if threshold == 0 {
+			threshold = DefaultDedupThreshold
+		}
+		hits = Collapse(hits, threshold)
+	}
+	if opts.MMR != nil {
+		ids := make([]string, 0, len(hits))
+		for _, hit := range hits {
+			ids = append(ids, hit.ChunkID)
+		}
+		vectors, err := r.store.ChunkEmbeddings(ctx, ids)
+		if err != nil {
+			return Results{}, err
+		}
+		hits = Diversify(hits, vectors, opts.MMR.Lambda, end+1)
+	}
+	more := len(hits) > end
+	if more {
+		hits = hits[:end]
+	}
+	if opts.Offset < len(hits) {
+		hits = hits[opts.Offset:]
+	} else {
+		hits = nil
+	}
+
+	if opts.GraphExpansion != nil {
+		var err error
+		if hits, err = r.expand(ctx, hits, *opts.GraphExpansion, k, filter); err != nil {
+			return Results{Hits: hits, KeywordOnly: r.KeywordOnly(), Route: route}, err
+		}
+	}
+	if opts.Snippets != nil {
+		for i := range hits {
+			hits[i].Snippet = NewSnippet(hits[i].Content, query, opts.Snippets.Length)
+		}
+	}
+	results := Results{Hits: hits, KeywordOnly: r.KeywordOnly(), Route: route, More: more}
+	if opts.Grouping != nil {
+		perGroup := opts.Grouping.ChunksPerGroup
+		if perGroup == 0 {
+			perGroup = DefaultChunksPerGroup
+		}
+		results.Groups = GroupByDocument(hits, perGroup)
+		if opts.Grouping.Context {
+			if err := r.attachContext(ctx, results.Groups); err != nil {
+				return results, err
+			}
+		}
+	}
+	return results, nil
+}
+
+// embedQuery returns the embedding of query, from the cache when possible. Failed
+// embeddings are not cached.
+func (r *Retriever) embedQuery(ctx context.Context, query string) ([]float32, error) {
+	if r.cache != nil {
+		if vector, ok := r.cache.Get(r.provider, r.model, query); ok {
+			return vector, nil
+		}
+	}
+	vector, err := r.embeddings.GetEmbeddings(ctx, query, embedding.EmbeddingTypeRetrievalQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+	if r.cache != nil {
+		r.cache.Put(r.provider, r.model, query, vector)
+	}
+	return vector, nil
+}
+
+func newRanking(retriever string, chunks []storage.ScoredChunk) Ranking {
+	hits := make([]Hit, 0, len(chunks))
+	for _, chunk := range chunks {
+		hits = append(hits, newHit(chunk, retriever))
+	}
+	return Ranking{Retriever: retriever, Hits: hits}
+}
+
+func newHit(chunk storage.ScoredChunk, retriever string) Hit {
+	return Hit{
+		ChunkID:     chunk.ID,
+		Content:     chunk.Content,
+		Score:       chunk.Score,
+		Index:       chunk.Index,
+		StartOffset: chunk.StartOffset,
+		EndOffset:   chunk.EndOffset,
+		Page:        chunk.Page,
+		DocumentID:  chunk.DocumentID,
+		Source:      chunk.Source,
+		Collection:  chunk.Collection,
+		IngestedAt:  chunk.IngestedAt,
+		Retrievers:  []string{retriever},
+	}
+}
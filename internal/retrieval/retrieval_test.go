@@ -0,0 +1,278 @@
+package retrieval
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/embedding"
+	"github.com/sandwichlabs/agent-memory-graph/internal/storage"
+)
+
+// fakeStore is an in-memory GraphStore that ranks chunks by cosine similarity.
+type fakeStore struct {
+	chunks []storage.ScoredChunk
+	// mentions maps chunk IDs to entity names; related lists undirected entity pairs.
+	mentions map[string][]string
+	related  [][2]string
+}
+
+// SimilaritySearch honors the collection and minimum score filters.
+func (s *fakeStore) SimilaritySearch(ctx context.Context, vector []float32, k int, filter storage.ChunkFilter) ([]storage.ScoredChunk, error) {
+	hits := make([]storage.ScoredChunk, 0, len(s.chunks))
+	for _, chunk := range s.chunks {
+		chunk.Score = cosine(vector, chunk.Embedding)
+		if (filter.Collection != "" && chunk.Collection != filter.Collection) || (filter.MinScore != 0 && chunk.Score < filter.MinScore) {
+			continue
+		}
+		hits = append(hits, chunk)
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	if len(hits) > k {
+		hits = hits[:k]
+	}
+	return hits, nil
+}
+
+// KeywordSearch ranks chunks by how many query words their content contains.
+func (s *fakeStore) KeywordSearch(ctx context.Context, query string, k int, filter storage.ChunkFilter) ([]storage.ScoredChunk, error) {
+	var hits []storage.ScoredChunk
+	for _, chunk := range s.chunks {
+		for _, word := range strings.Fields(strings.ToLower(query)) {
+			if strings.Contains(strings.ToLower(chunk.Content), word) {
+				chunk.Score++
+			}
+		}
+		if chunk.Score > 0 {
+			hits = append(hits, chunk)
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	if len(hits) > k {
+		hits = hits[:k]
+	}
+	return hits, nil
+}
+
+func (s *fakeStore) ChunkEmbeddings(ctx context.Context, ids []string) (map[string][]float32, error) {
+	out := make(map[string][]float32)
+	for _, chunk := range s.chunks {
+		for _, id := range ids {
+			if chunk.ID == id {
+				out[id] = chunk.Embedding
+			}
+		}
+	}
+	return out, nil
+}
+
+// AdjacentChunks finds neighbours by index within a document.
+func (s *fakeStore) AdjacentChunks(ctx context.Context, ids []string) (map[string]storage.Neighbours, error) {
+	out := make(map[string]storage.Neighbours)
+	for _, id := range ids {
+		for _, chunk := range s.chunks {
+			if chunk.ID != id {
+				continue
+			}
+			var adjacent storage.Neighbours
+			for _, other := range s.chunks {
+				if other.DocumentID != chunk.DocumentID {
+					continue
+				}
+				switch other.Index {
+				case chunk.Index - 1:
+					adjacent.Previous = &other.Chunk
+				case chunk.Index + 1:
+					adjacent.Next = &other.Chunk
+				}
+			}
+			if adjacent.Previous != nil || adjacent.Next != nil {
+				out[id] = adjacent
+			}
+		}
+	}
+	return out, nil
+}
+
+func (s *fakeStore) MentionedEntities(ctx context.Context, chunkIDs []string) (map[string][]string, error) {
+	out := make(map[string][]string)
+	for _, id := range chunkIDs {
+		if names, ok := s.mentions[id]; ok {
+			out[id] = names
+		}
+	}
+	return out, nil
+}
+
+func (s *fakeStore) RelatedEntities(ctx context.Context, names []string) (map[string][]string, error) {
+	out := make(map[string][]string)
+	for _, name := range names {
+		for _, pair := range s.related {
+			if pair[0] == name {
+				out[name] = append(out[name], pair[1])
+			} else if pair[1] == name {
+				out[name] = append(out[name], pair[0])
+			}
+		}
+	}
+	return out, nil
+}
+
+func (s *fakeStore) MentioningChunks(ctx context.Context, name string, limit int, filter storage.ChunkFilter) ([]storage.ScoredChunk, error) {
+	var chunks []storage.ScoredChunk
+	for _, chunk := range s.chunks {
+		for _, mentioned := range s.mentions[chunk.ID] {
+			if mentioned == name && len(chunks) < limit {
+				chunks = append(chunks, chunk)
+			}
+		}
+	}
+	return chunks, nil
+}
+
+// recordingService wraps the mock embeddings and remembers the requested task type.
+type recordingService struct {
+	embedding.Service
+	types []embedding.EmbeddingType
+}
+
+func (s *recordingService) GetEmbeddings(ctx context.Context, text string, embeddingType embedding.EmbeddingType) (embedding.EmbedResponse, error) {
+	s.types = append(s.types, embeddingType)
+	return s.Service.GetEmbeddings(ctx, text, embeddingType)
+}
+
+// vectorFor returns a vector whose cosine similarity with the embedding of any
+// query by uniformService (a ramp) decreases as tilt grows.
+func vectorFor(tilt float32) []float32 {
+	vector := make([]float32, storage.EmbeddingDimensions)
+	for i := range vector {
+		vector[i] = float32(i)/1000.0 + tilt*float32(i%2)
+	}
+	return vector
+}
+
+// uniformService embeds every text as vectorFor(0), so that fixtures rank by the
+// tilt of their vectors whatever the query.
+type uniformService struct{}
+
+func (uniformService) GetEmbeddings(ctx context.Context, text string, embeddingType embedding.EmbeddingType) (embedding.EmbedResponse, error) {
+	return vectorFor(0), nil
+}
+
+func (s uniformService) GetEmbeddingsBatch(ctx context.Context, texts []string, embeddingType embedding.EmbeddingType) ([]embedding.EmbedResponse, error) {
+	vectors := make([]embedding.EmbedResponse, len(texts))
+	for n := range texts {
+		vectors[n] = vectorFor(0)
+	}
+	return vectors, nil
+}
+
+func (uniformService) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (uniformService) GetType() embedding.Provider {
+	return embedding.ProviderTestMock
+}
+
+func newFixture() (*Retriever, *recordingService) {
+	store := &fakeStore{chunks: []storage.ScoredChunk{
+		{Chunk: storage.Chunk{ID: "far", DocumentID: "d2", Content: "far", Embedding: vectorFor(5), StartOffset: 0, EndOffset: 3}, Source: "b.md", Collection: "notes"},
+		{Chunk: storage.Chunk{ID: "near", DocumentID: "d1", Content: "near", Index: 2, Embedding: vectorFor(0), StartOffset: 10, EndOffset: 14}, Source: "a.md", Collection: "default"},
+		{Chunk: storage.Chunk{ID: "mid", DocumentID: "d1", Content: "mid", Index: 1, Embedding: vectorFor(0.5), StartOffset: 4, EndOffset: 7}, Source: "a.md", Collection: "default"},
+	}}
+	embeddings := &recordingService{Service: uniformService{}}
+	return NewRetriever(store, embeddings), embeddings
+}
+
+func TestSearch_RanksByQuerySimilarity(t *testing.T) {
+	retriever, embeddings := newFixture()
+
+	hits, err := retriever.Search(context.Background(), "what is near?", SearchOptions{K: 2})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(hits) != 2 || hits[0].ChunkID != "near" || hits[1].ChunkID != "mid" {
+		t.Fatalf("Expected hits near and mid, got %+v", hits)
+	}
+	expected := Hit{ChunkID: "near", Content: "near", Score: hits[0].Score, Index: 2, StartOffset: 10, EndOffset: 14,
+		DocumentID: "d1", Source: "a.md", Collection: "default", Retrievers: []string{RetrieverVector}}
+	if !reflect.DeepEqual(hits[0], expected) {
+		t.Errorf("Expected hit %+v, got %+v", expected, hits[0])
+	}
+	if hits[0].Score < hits[1].Score {
+		t.Errorf("Expected hits best first, got scores %f and %f", hits[0].Score, hits[1].Score)
+	}
+	if len(embeddings.types) != 1 || embeddings.types[0] != embedding.EmbeddingTypeRetrievalQuery {
+		t.Errorf("Expected one query embedding, got %v", embeddings.types)
+	}
+}
+
+func TestSearch_DefaultsAndMinScore(t *testing.T) {
+	retriever, _ := newFixture()
+
+	hits, err := retriever.Search(context.Background(), "anything", SearchOptions{})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(hits) != 3 {
+		t.Errorf("Expected every chunk within the default K, got %d hits", len(hits))
+	}
+
+	hits, err = retriever.Search(context.Background(), "anything", SearchOptions{Filter: SearchFilter{MinScore: hits[1].Score}})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(hits) != 2 {
+		t.Errorf("Expected 2 hits at or above the minimum score, got %d", len(hits))
+	}
+}
+
+func TestSearch_EmptyQuery(t *testing.T) {
+	retriever, embeddings := newFixture()
+
+	if _, err := retriever.Search(context.Background(), "  ", SearchOptions{}); !errors.Is(err, ErrEmptyQuery) {
+		t.Errorf("Expected ErrEmptyQuery, got %v", err)
+	}
+	if len(embeddings.types) != 0 {
+		t.Errorf("Expected no embedding request for an empty query, got %d", len(embeddings.types))
+	}
+}
+
+func TestSearch_HybridFusesKeywordHits(t *testing.T) {
+	retriever, _ := newFixture()
+
+	hits, err := retriever.Search(context.Background(), "far", SearchOptions{K: 2, Hybrid: true})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if got := describe(hits); strings.Join(got, " ") != "far:vector+keyword near:vector" {
+		t.Errorf("Expected the keyword match to be promoted, got %v", got)
+	}
+}
+
+func TestRetrieve_KeywordOnlyWithoutEmbeddings(t *testing.T) {
+	fixture, _ := newFixture()
+	retriever := NewRetriever(fixture.store, nil)
+	if !retriever.KeywordOnly() {
+		t.Fatal("Expected a retriever without embeddings to be keyword-only")
+	}
+	if fixture.KeywordOnly() {
+		t.Error("Expected a retriever with embeddings not to be keyword-only")
+	}
+
+	// The minimum score only applies to similarities and must not hide keyword hits.
+	results, err := retriever.Retrieve(context.Background(), "far", SearchOptions{Hybrid: true, Filter: SearchFilter{MinScore: 0.9}})
+	if err != nil {
+		t.Fatalf("Retrieve failed: %v", err)
+	}
+	if !results.KeywordOnly || results.NoRelevantResults {
+		t.Errorf("Expected keyword-only results, got %+v", results)
+	}
+	if got := describe(results.Hits); strings.Join(got, " ") != "far:keyword" {
+		t.Errorf("Expected only the keyword match, got %v", got)
+	}
+}
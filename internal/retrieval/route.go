@@ -0,0 +1,282 @@
+package retrieval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/storage"
+)
+
+// DefaultRouteConfidence is the classification confidence below which a graph route
+// is blended with hybrid search rather than replacing it.
+const DefaultRouteConfidence = 0.6
+
+// Route is the kind of question a query asks.
+type Route string
+
+const (
+	// RouteEntity asks about a named entity, like "who is Alice?".
+	RouteEntity Route = "entity"
+	// RouteRelational asks how entities are connected, like "who is Alice working with?".
+	RouteRelational Route = "relational"
+	// RouteTopical asks about a subject rather than particular entities.
+	RouteTopical Route = "topical"
+)
+
+// Strategies a routed search can take.
+const (
+	// StrategyGraph ranks the chunks mentioning the query's entities only.
+	StrategyGraph = "graph"
+	// StrategyBlended fuses the graph ranking with hybrid search.
+	StrategyBlended = "blended"
+	// StrategyHybrid runs a hybrid search, as for topical queries and entities the
+	// graph does not know.
+	StrategyHybrid = "hybrid"
+)
+
+// Classifiers of a RouteDecision.
+const (
+	ClassifierLLM       = "llm"
+	ClassifierHeuristic = "heuristic"
+)
+
+// Routing configures query routing: the query is classified, by the retriever's LLM
+// when it has one and by keyword heuristics otherwise, and entity or relational
+// queries are answered from the entity graph instead of, or besides, hybrid search.
+type Routing struct {
+	// MinConfidence is the confidence a graph route needs to skip hybrid search;
+	// DefaultRouteConfidence when zero.
+	MinConfidence float64
+}
+
+// RouteDecision reports how a routed search was answered, for debugging.
+type RouteDecision struct {
+	Route Route
+	// Entities are the entity names the query refers to, as stored in the graph.
+	Entities   []string
+	Confidence float64
+	// Classifier is ClassifierLLM or ClassifierHeuristic.
+	Classifier string
+	// Strategy is StrategyGraph, StrategyBlended or StrategyHybrid.
+	Strategy string
+}
+
+var (
+	// quoted matches names in double quotes.
+	quoted = regexp.MustCompile(`"([^"]+)"`)
+	// relationalCues suggest a question about connections between entities.
+	relationalCues = []string{" with", "related", "relationship", "connect", "between", "knows", "works for", "work for", "partner", "collaborat", "link"}
+	// lookupCues suggest a question about an entity itself.
+	lookupCues = []string{"who is", "who's", "what is", "what's", "tell me about", "describe"}
+)
+
+// route classifies query and ranks the chunks mentioning its entities and, for a
+// relational query, their related entities. The ranking is empty for topical
+// queries and entities the graph does not know.
+func (r *Retriever) route(ctx context.Context, query string, routing Routing, limit int, filter storage.ChunkFilter) (RouteDecision, Ranking, error) {
+	minConfidence := routing.MinConfidence
+	if minConfidence <= 0 {
+		minConfidence = DefaultRouteConfidence
+	}
+	decision := r.classify(ctx, query)
+	decision.Strategy = StrategyHybrid
+	if decision.Route == RouteTopical {
+		return decision, Ranking{}, nil
+	}
+
+	graph, err := r.graphRanking(ctx, decision, limit, filter)
+	if err != nil {
+		return decision, Ranking{}, err
+	}
+	switch {
+	case len(graph.Hits) == 0:
+	case decision.Confidence >= minConfidence:
+		decision.Strategy = StrategyGraph
+	default:
+		decision.Strategy = StrategyBlended
+	}
+	return decision, graph, nil
+}
+
+// classify asks the retriever's LLM for the query's route, falling back to
+// classifyHeuristically without an LLM or a usable reply.
+func (r *Retriever) classify(ctx context.Context, query string) RouteDecision {
+	if r.llm == nil {
+		return classifyHeuristically(query)
+	}
+	reply, err := r.llm.GenerateText(ctx, routePrompt(query))
+	if err == nil {
+		var decision RouteDecision
+		if decision, err = parseRoute(reply); err == nil {
+			return decision
+		}
+	}
+	slog.WarnContext(ctx, "query classification failed, routing by heuristics", "error", err)
+	return classifyHeuristically(query)
+}
+
+func routePrompt(query string) string {
+	var b strings.Builder
+	b.WriteString("Classify the search query below for a knowledge graph of entities and their relationships. ")
+	b.WriteString(`The route is "entity" for a question about a named person, organization, product or other entity, `)
+	b.WriteString(`"relational" for a question about how entities are connected, and "topical" for anything else. `)
+	b.WriteString("List the entity names the query mentions, spelled as in the query. ")
+	b.WriteString(`Reply with only a JSON object like {"route": "relational", "entities": ["Alice"], "confidence": 0.9}, `)
+	b.WriteString("with confidence between 0 and 1.\n\n")
+	fmt.Fprintf(&b, "Query: %s\n", query)
+	return b.String()
+}
+
+// parseRoute reads the classification in reply, tolerating surrounding prose or
+// code fences. Entity and relational routes must name an entity.
+func parseRoute(reply string) (RouteDecision, error) {
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return RouteDecision{}, fmt.Errorf("no JSON object in reply %q", reply)
+	}
+	var classification struct {
+		Route      Route    `json:"route"`
+		Entities   []string `json:"entities"`
+		Confidence float64  `json:"confidence"`
+	}
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &classification); err != nil {
+		return RouteDecision{}, fmt.Errorf("invalid classification: %w", err)
+	}
+	decision := RouteDecision{
+		Route:      classification.Route,
+		Confidence: max(0, min(classification.Confidence, 1)),
+		Classifier: ClassifierLLM,
+	}
+	for _, name := range classification.Entities {
+		if name = strings.TrimSpace(name); name != "" && !slices.Contains(decision.Entities, name) {
+			decision.Entities = append(decision.Entities, name)
+		}
+	}
+	switch decision.Route {
+	case RouteTopical:
+	case RouteEntity, RouteRelational:
+		if len(decision.Entities) == 0 {
+			return RouteDecision{}, fmt.Errorf("%s route without entities in reply %q", decision.Route, reply)
+		}
+	default:
+		return RouteDecision{}, fmt.Errorf("unknown route %q", decision.Route)
+	}
+	return decision, nil
+}
+
+// classifyHeuristically takes quoted phrases and runs of capitalized words as the
+// query's entities, and looks for words that ask about connections or about the
+// entity itself. Without either cue, an entity route has low confidence.
+func classifyHeuristically(query string) RouteDecision {
+	decision := RouteDecision{Route: RouteTopical, Confidence: 0.5, Classifier: ClassifierHeuristic}
+	decision.Entities = queryEntities(query)
+	if len(decision.Entities) == 0 {
+		return decision
+	}
+
+	lower := strings.ToLower(query)
+	hasCue := func(cues []string) bool {
+		return slices.ContainsFunc(cues, func(cue string) bool { return strings.Contains(lower, cue) })
+	}
+	switch {
+	case hasCue(relationalCues):
+		decision.Route, decision.Confidence = RouteRelational, 0.7
+	case hasCue(lookupCues):
+		decision.Route, decision.Confidence = RouteEntity, 0.7
+	default:
+		decision.Route, decision.Confidence = RouteEntity, 0.4
+	}
+	return decision
+}
+
+// queryEntities returns the quoted phrases and runs of capitalized words of query,
+// skipping capitalized stopwords such as a leading "Who".
+func queryEntities(query string) []string {
+	var names []string
+	add := func(name string) {
+		if name != "" && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	for _, match := range quoted.FindAllStringSubmatch(query, -1) {
+		add(strings.TrimSpace(match[1]))
+	}
+
+	var run []string
+	for _, word := range strings.Fields(quoted.ReplaceAllString(query, " ")) {
+		word = strings.TrimFunc(word, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+		first, _ := utf8.DecodeRuneInString(word)
+		if unicode.IsUpper(first) && !stopwords[strings.ToLower(word)] && word != "I" {
+			run = append(run, word)
+			continue
+		}
+		add(strings.Join(run, " "))
+		run = nil
+	}
+	add(strings.Join(run, " "))
+	return names
+}
+
+// graphRanking ranks up to limit chunks mentioning the decision's entities. For a
+// relational route, chunks mentioning an entity related to them are added with a
+// lower score, and chunks mentioning both rank first.
+func (r *Retriever) graphRanking(ctx context.Context, decision RouteDecision, limit int, filter storage.ChunkFilter) (Ranking, error) {
+	ranking := Ranking{Retriever: RetrieverGraph}
+	index := make(map[string]int)
+	add := func(chunks []storage.ScoredChunk, score float64) {
+		for _, chunk := range chunks {
+			if i, ok := index[chunk.ID]; ok {
+				ranking.Hits[i].Score += score
+				continue
+			}
+			index[chunk.ID] = len(ranking.Hits)
+			hit := newHit(chunk, RetrieverGraph)
+			hit.Score = score
+			ranking.Hits = append(ranking.Hits, hit)
+		}
+	}
+
+	for _, name := range decision.Entities {
+		chunks, err := r.store.MentioningChunks(ctx, name, limit, filter)
+		if err != nil {
+			return Ranking{}, err
+		}
+		add(chunks, 1)
+	}
+	if decision.Route == RouteRelational && len(ranking.Hits) > 0 {
+		related, err := r.store.RelatedEntities(ctx, decision.Entities)
+		if err != nil {
+			return Ranking{}, err
+		}
+		var neighbours []string
+		for _, name := range decision.Entities {
+			for _, other := range related[name] {
+				if !slices.Contains(decision.Entities, other) && !slices.Contains(neighbours, other) {
+					neighbours = append(neighbours, other)
+				}
+			}
+		}
+		sort.Strings(neighbours)
+		for _, name := range neighbours {
+			chunks, err := r.store.MentioningChunks(ctx, name, DefaultChunksPerEntity, filter)
+			if err != nil {
+				return Ranking{}, err
+			}
+			add(chunks, DefaultExpansionDecay)
+		}
+	}
+
+	sort.SliceStable(ranking.Hits, func(i, j int) bool { return ranking.Hits[i].Score > ranking.Hits[j].Score })
+	if len(ranking.Hits) > limit {
+		ranking.Hits = ranking.Hits[:limit]
+	}
+	return ranking, nil
+}
@@ -0,0 +1,149 @@
+package retrieval
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/storage"
+)
+
+// newTeamFixture returns a retriever over chunks about Alice, who is related to
+// Carol, with the classifier scripted to reply with classification.
+func newTeamFixture(classification string) (*Retriever, *recordingService) {
+	store := &fakeStore{
+		chunks: []storage.ScoredChunk{
+			{Chunk: storage.Chunk{ID: "alice", Content: "Alice leads the graph team.", Embedding: vectorFor(2)}},
+			{Chunk: storage.Chunk{ID: "pair", Content: "Bob and Alice ship KuzuDB.", Embedding: vectorFor(3)}},
+			{Chunk: storage.Chunk{ID: "carol", Content: "Carol writes Cypher.", Embedding: vectorFor(4)}},
+			{Chunk: storage.Chunk{ID: "topic", Content: "Columnar storage speeds up analytics.", Embedding: vectorFor(0)}},
+		},
+		mentions: map[string][]string{
+			"alice": {"Alice"},
+			"pair":  {"Alice", "Bob"},
+			"carol": {"Carol"},
+		},
+		related: [][2]string{{"Alice", "Carol"}},
+	}
+	embeddings := &recordingService{Service: uniformService{}}
+	retriever := NewRetriever(store, embeddings).WithLLM(&scriptedLLM{replies: []string{classification}})
+	return retriever, embeddings
+}
+
+func TestRetrieve_Routing(t *testing.T) {
+	cases := []struct {
+		name           string
+		query          string
+		classification string
+		route          Route
+		strategy       string
+		hits           string
+		embedded       bool
+	}{
+		{
+			"entity lookup reads the graph", "Who is Alice?",
+			`{"route": "entity", "entities": ["Alice"], "confidence": 0.9}`,
+			RouteEntity, StrategyGraph, "alice:graph pair:graph", false,
+		},
+		{
+			"relational adds related entities", "Who is Alice working with?",
+			"```json\n{\"route\": \"relational\", \"entities\": [\"Alice\"], \"confidence\": 0.8}\n```",
+			RouteRelational, StrategyGraph, "alice:graph pair:graph carol:graph", false,
+		},
+		{
+			"topical runs a hybrid search", "columnar storage",
+			`{"route": "topical", "entities": [], "confidence": 0.9}`,
+			RouteTopical, StrategyHybrid, "topic:vector+keyword alice:vector pair:vector carol:vector", true,
+		},
+		{
+			"low confidence blends", "alice",
+			`{"route": "entity", "entities": ["Alice"], "confidence": 0.3}`,
+			RouteEntity, StrategyBlended, "alice:graph+vector+keyword pair:graph+vector+keyword topic:vector carol:vector", true,
+		},
+		{
+			"unknown entity falls back to hybrid", "Who is Dave?",
+			`{"route": "entity", "entities": ["Dave"], "confidence": 0.9}`,
+			RouteEntity, StrategyHybrid, "topic:vector alice:vector pair:vector carol:vector", true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			retriever, embeddings := newTeamFixture(tc.classification)
+
+			results, err := retriever.Retrieve(context.Background(), tc.query, SearchOptions{K: 4, Routing: &Routing{}})
+			if err != nil {
+				t.Fatalf("Retrieve failed: %v", err)
+			}
+			if results.Route == nil || results.Route.Route != tc.route || results.Route.Strategy != tc.strategy || results.Route.Classifier != ClassifierLLM {
+				t.Errorf("Expected route %s with strategy %s, got %+v", tc.route, tc.strategy, results.Route)
+			}
+			if got := strings.Join(describe(results.Hits), " "); got != tc.hits {
+				t.Errorf("Expected hits %q, got %q", tc.hits, got)
+			}
+			if embedded := len(embeddings.types) > 0; embedded != tc.embedded {
+				t.Errorf("Expected the query embedded: %v, got %v", tc.embedded, embedded)
+			}
+		})
+	}
+}
+
+func TestRetrieve_RoutingScoresRelatedChunksLower(t *testing.T) {
+	retriever, _ := newTeamFixture(`{"route": "relational", "entities": ["Alice"], "confidence": 0.9}`)
+
+	hits, err := retriever.Search(context.Background(), "Who works with Alice?", SearchOptions{Routing: &Routing{}})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	scores := map[string]float64{}
+	for _, hit := range hits {
+		scores[hit.ChunkID] = hit.Score
+	}
+	if want := map[string]float64{"alice": 1, "pair": 1, "carol": DefaultExpansionDecay}; !reflect.DeepEqual(scores, want) {
+		t.Errorf("Expected scores %v, got %v", want, scores)
+	}
+}
+
+func TestRetrieve_RoutingFallsBackToHeuristics(t *testing.T) {
+	for _, reply := range []string{"not json", `{"route": "relational", "entities": []}`, `{"route": "gossip"}`} {
+		retriever, _ := newTeamFixture(reply)
+
+		results, err := retriever.Retrieve(context.Background(), "Who is Alice working with?", SearchOptions{Routing: &Routing{}})
+		if err != nil {
+			t.Fatalf("%q: Retrieve failed: %v", reply, err)
+		}
+		want := &RouteDecision{Route: RouteRelational, Entities: []string{"Alice"}, Confidence: 0.7, Classifier: ClassifierHeuristic, Strategy: StrategyGraph}
+		if !reflect.DeepEqual(results.Route, want) {
+			t.Errorf("%q: Expected the heuristic route %+v, got %+v", reply, want, results.Route)
+		}
+	}
+
+	retriever, _ := newTeamFixture("")
+	retriever.llm = &scriptedLLM{err: errors.New("unavailable")}
+	if results, err := retriever.Retrieve(context.Background(), "columnar storage", SearchOptions{Routing: &Routing{}}); err != nil || results.Route.Classifier != ClassifierHeuristic {
+		t.Errorf("Expected a heuristic route after an LLM failure, got %+v (%v)", results.Route, err)
+	}
+}
+
+func TestClassifyHeuristically(t *testing.T) {
+	cases := []struct {
+		query      string
+		route      Route
+		entities   []string
+		confidence float64
+	}{
+		{"how does columnar storage work?", RouteTopical, nil, 0.5},
+		{"Who is Alice Smith?", RouteEntity, []string{"Alice Smith"}, 0.7},
+		{"Who is Alice working with at Kuzu Inc?", RouteRelational, []string{"Alice", "Kuzu Inc"}, 0.7},
+		{`what links "graph team" to Bob`, RouteRelational, []string{"graph team", "Bob"}, 0.7},
+		{"KuzuDB benchmarks", RouteEntity, []string{"KuzuDB"}, 0.4},
+	}
+	for _, tc := range cases {
+		got := classifyHeuristically(tc.query)
+		want := RouteDecision{Route: tc.route, Entities: tc.entities, Confidence: tc.confidence, Classifier: ClassifierHeuristic}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: Expected %+v, got %+v", tc.query, want, got)
+		}
+	}
+}
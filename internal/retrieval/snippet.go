@@ -0,0 +1,197 @@
+package retrieval
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultSnippetLength is the snippet length in bytes when none is given.
+const DefaultSnippetLength = 240
+
+// ellipsis marks where a snippet was cut from its chunk.
+const ellipsis = "…"
+
+// stopwords are query words too common to place or highlight a snippet.
+var stopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true,
+	"by": true, "do": true, "does": true, "for": true, "from": true, "how": true,
+	"in": true, "is": true, "it": true, "of": true, "on": true, "or": true, "that": true,
+	"the": true, "to": true, "was": true, "what": true, "when": true, "where": true,
+	"which": true, "who": true, "why": true, "with": true,
+}
+
+// Snippets configures the snippets attached to search hits.
+type Snippets struct {
+	// Length is the maximum snippet length in bytes, not counting the ellipses
+	// marking cuts; DefaultSnippetLength when zero.
+	Length int
+}
+
+// Span is a range of byte offsets, End exclusive.
+type Span struct {
+	Start, End int
+}
+
+// Snippet is the passage of a hit that best matches the query.
+type Snippet struct {
+	Text string
+	// Highlights are the byte offsets into Text of the words matching query terms.
+	Highlights []Span
+}
+
+// NewSnippet extracts the window of at most length bytes of content that matches
+// the most distinct query terms, then the most term occurrences, and trims it to
+// word boundaries. Whitespace is collapsed to single spaces, and cuts are marked
+// with an ellipsis. A word matches a term it starts with, case-insensitively, so
+// "build" matches "builds". Content without matches yields its beginning.
+func NewSnippet(content, query string, length int) Snippet {
+	if length <= 0 {
+		length = DefaultSnippetLength
+	}
+	text := strings.Join(strings.Fields(content), " ")
+	matches := matchTerms(text, queryTerms(query))
+	if len(text) <= length {
+		return Snippet{Text: text, Highlights: spans(matches, 0, len(text), 0)}
+	}
+
+	first, last := bestWindow(matches, length)
+	start, end := 0, length
+	if first >= 0 {
+		// Center the matches in the window, keeping it inside the text.
+		covered := matches[last].End - matches[first].Start
+		start = max(0, min(matches[first].Start-(length-covered)/2, len(text)-length))
+		end = start + length
+	}
+	start, end = trim(text, start, end, matches, first, last)
+
+	var b strings.Builder
+	prefix := 0
+	if start > 0 {
+		b.WriteString(ellipsis)
+		prefix = len(ellipsis)
+	}
+	b.WriteString(text[start:end])
+	if end < len(text) {
+		b.WriteString(ellipsis)
+	}
+	return Snippet{Text: b.String(), Highlights: spans(matches, start, end, prefix)}
+}
+
+// termMatch is a word of the text matching the query term numbered term.
+type termMatch struct {
+	Span
+	term int
+}
+
+// queryTerms returns the distinct lowercase words of query, without stopwords and
+// single characters.
+func queryTerms(query string) []string {
+	var terms []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(query), notWordRune) {
+		if utf8.RuneCountInString(word) > 1 && !stopwords[word] && !slices.Contains(terms, word) {
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+// matchTerms returns the words of text starting with one of terms, in order.
+func matchTerms(text string, terms []string) []termMatch {
+	var matches []termMatch
+	if len(terms) == 0 {
+		return matches
+	}
+	start := -1
+	for i, r := range text + " " {
+		if !notWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start < 0 {
+			continue
+		}
+		word := strings.ToLower(text[start:i])
+		for term, prefix := range terms {
+			if strings.HasPrefix(word, prefix) {
+				matches = append(matches, termMatch{Span: Span{Start: start, End: i}, term: term})
+				break
+			}
+		}
+		start = -1
+	}
+	return matches
+}
+
+// bestWindow returns the indexes of the first and last of the matches that fit in
+// length bytes covering the most distinct terms, then the most matches, preferring
+// earlier windows. Both are -1 without matches.
+func bestWindow(matches []termMatch, length int) (first, last int) {
+	first, last = -1, -1
+	bestTerms, bestCount := 0, 0
+	for i := range matches {
+		seen := map[int]bool{}
+		j := i
+		for ; j < len(matches) && matches[j].End-matches[i].Start <= length; j++ {
+			seen[matches[j].term] = true
+		}
+		if j == i {
+			// A single word longer than the snippet.
+			continue
+		}
+		if len(seen) > bestTerms || (len(seen) == bestTerms && j-i > bestCount) {
+			first, last, bestTerms, bestCount = i, j-1, len(seen), j-i
+		}
+	}
+	return first, last
+}
+
+// trim moves start forward and end backward to word boundaries of text without
+// cutting the matches first through last; without matches, first is -1. A window
+// that is a single long word is cut at rune boundaries instead.
+func trim(text string, start, end int, matches []termMatch, first, last int) (int, int) {
+	keepStart, keepEnd := end, start
+	if first >= 0 {
+		keepStart, keepEnd = matches[first].Start, matches[last].End
+	}
+	if start > 0 && text[start-1] != ' ' && start < keepStart {
+		if i := strings.IndexByte(text[start:keepStart], ' '); i >= 0 {
+			start += i + 1
+		} else {
+			start = keepStart
+		}
+	}
+	if end < len(text) && text[end] != ' ' && end > keepEnd {
+		if i := strings.LastIndexByte(text[keepEnd:end], ' '); i >= 0 {
+			end = keepEnd + i
+		} else if first >= 0 {
+			end = keepEnd
+		}
+	}
+	for start < len(text) && !utf8.RuneStart(text[start]) {
+		start++
+	}
+	for end > start && end < len(text) && !utf8.RuneStart(text[end]) {
+		end--
+	}
+	return start, end
+}
+
+// spans returns the matches within text[start:end] as offsets into the snippet,
+// which begins with prefix bytes before text[start].
+func spans(matches []termMatch, start, end, prefix int) []Span {
+	var out []Span
+	for _, match := range matches {
+		if match.Start >= start && match.End <= end {
+			out = append(out, Span{Start: match.Start - start + prefix, End: match.End - start + prefix})
+		}
+	}
+	return out
+}
+
+func notWordRune(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
@@ -0,0 +1,88 @@
+package retrieval
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// highlighted returns the highlighted words of snippet.
+func highlighted(snippet Snippet) []string {
+	var words []string
+	for _, span := range snippet.Highlights {
+		words = append(words, snippet.Text[span.Start:span.End])
+	}
+	return words
+}
+
+func TestNewSnippet_ShortChunkIsKeptWhole(t *testing.T) {
+	snippet := NewSnippet("Kuzu Inc\n  builds   KuzuDB.", "what does kuzu build?", 100)
+
+	if snippet.Text != "Kuzu Inc builds KuzuDB." {
+		t.Errorf("Expected the whole chunk with collapsed whitespace, got %q", snippet.Text)
+	}
+	if want := []string{"Kuzu", "builds", "KuzuDB"}; !reflect.DeepEqual(highlighted(snippet), want) {
+		t.Errorf("Expected highlights %v, got %v", want, highlighted(snippet))
+	}
+}
+
+func TestNewSnippet_TermsNearTheEndOfALongChunk(t *testing.T) {
+	filler := strings.Repeat("Unrelated words about the weather. ", 20)
+	content := filler + "The graph query language of KuzuDB is Cypher."
+
+	snippet := NewSnippet(content, "cypher in kuzudb", 60)
+
+	if !strings.HasPrefix(snippet.Text, ellipsis) || strings.HasSuffix(snippet.Text, ellipsis) {
+		t.Errorf("Expected a snippet cut at the start only, got %q", snippet.Text)
+	}
+	if !strings.HasSuffix(snippet.Text, "KuzuDB is Cypher.") {
+		t.Errorf("Expected the snippet to reach the matches at the end, got %q", snippet.Text)
+	}
+	if len(snippet.Text)-len(ellipsis) > 60 {
+		t.Errorf("Expected at most 60 bytes of content, got %d", len(snippet.Text)-len(ellipsis))
+	}
+	if word := strings.Fields(strings.TrimPrefix(snippet.Text, ellipsis))[0]; !strings.Contains(" "+content, " "+word+" ") {
+		t.Errorf("Expected the snippet to start at a word boundary, got %q", snippet.Text)
+	}
+	if want := []string{"KuzuDB", "Cypher"}; !reflect.DeepEqual(highlighted(snippet), want) {
+		t.Errorf("Expected highlights %v, got %v", want, highlighted(snippet))
+	}
+}
+
+func TestNewSnippet_PrefersWindowWithMoreTerms(t *testing.T) {
+	content := "Cypher is mentioned here. " + strings.Repeat("Padding sentence. ", 10) +
+		"Cypher and KuzuDB together. " + strings.Repeat("More padding. ", 10)
+
+	snippet := NewSnippet(content, "kuzudb cypher", 40)
+
+	if !strings.HasPrefix(snippet.Text, ellipsis) || !strings.HasSuffix(snippet.Text, ellipsis) {
+		t.Errorf("Expected a snippet cut at both ends, got %q", snippet.Text)
+	}
+	if want := []string{"Cypher", "KuzuDB"}; !reflect.DeepEqual(highlighted(snippet), want) {
+		t.Errorf("Expected the window matching both terms, got %q", snippet.Text)
+	}
+}
+
+func TestNewSnippet_WithoutMatchesKeepsTheBeginning(t *testing.T) {
+	snippet := NewSnippet("First words of a chunk that runs on for quite a while.", "zebra", 20)
+
+	if snippet.Text != "First words of a"+ellipsis {
+		t.Errorf("Expected the beginning up to a word boundary, got %q", snippet.Text)
+	}
+	if len(snippet.Highlights) != 0 {
+		t.Errorf("Expected no highlights, got %v", snippet.Highlights)
+	}
+}
+
+func TestSearch_AttachesSnippets(t *testing.T) {
+	retriever, _ := newFixture()
+
+	hits, err := retriever.Search(context.Background(), "near", SearchOptions{K: 1, Snippets: &Snippets{}})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if want := (Snippet{Text: "near", Highlights: []Span{{0, 4}}}); !reflect.DeepEqual(hits[0].Snippet, want) {
+		t.Errorf("Expected snippet %+v, got %+v", want, hits[0].Snippet)
+	}
+}
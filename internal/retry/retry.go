@@ -0,0 +1,167 @@
+// Package retry retries calls to provider APIs that fail transiently, with
+// jittered exponential backoff, for the LLM and embedding services alike.
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sandwichlabs/agent-memory-graph/internal/budget"
+)
+
+// Policy configures retrying transient failures: rate limiting, server errors
+// and dropped connections.
+type Policy struct {
+	// MaxAttempts is the number of attempts, including the first; 1 disables
+	// retries.
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry. It doubles for each further
+	// retry up to MaxDelay, and up to half of it is random jitter.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// MaxRetryAfter is the longest wait a throttled response may ask for with
+	// Retry-After, which replaces the delay; asking for longer ends the retries.
+	// Zero accepts any wait.
+	MaxRetryAfter time.Duration
+}
+
+// DefaultPolicy makes up to four attempts, waiting about 0.5s, 1s and 2s between
+// them, or up to a minute when the server asks.
+var DefaultPolicy = Policy{MaxAttempts: 4, BaseDelay: 500 * time.Millisecond, MaxDelay: 8 * time.Second, MaxRetryAfter: time.Minute}
+
+// Do calls attempt until it succeeds, fails with an error it does not report as
+// transient, or MaxAttempts is reached. It returns the last error, noting the
+// number of attempts when there were several, or ctx's error when ctx is done
+// while waiting to retry. Each retry is charged to the budget ctx carries, and
+// one it cannot afford ends the retries with ErrBudgetExhausted.
+func (p Policy) Do(ctx context.Context, name string, attempt func() (transient bool, err error)) error {
+	for n := 1; ; n++ {
+		transient, err := attempt()
+		if err != nil {
+			slog.DebugContext(ctx, "Attempt failed", "call", name, "attempt", n, "transient", transient)
+		}
+		if err == nil || !transient || ctx.Err() != nil {
+			return err
+		}
+		if n >= p.MaxAttempts {
+			if n > 1 {
+				return fmt.Errorf("%w (gave up after %d attempts)", err, n)
+			}
+			return err
+		}
+		delay := p.Delay(n)
+		var throttled *throttledError
+		if errors.As(err, &throttled) {
+			if p.MaxRetryAfter > 0 && throttled.after > p.MaxRetryAfter {
+				return fmt.Errorf("%w (server asked to wait %s, longer than the %s allowed)", err, throttled.after, p.MaxRetryAfter)
+			}
+			delay = throttled.after
+			slog.InfoContext(ctx, "Throttled, waiting as the server asked before retrying", "call", name, "attempt", n, "delay", delay)
+		} else {
+			slog.WarnContext(ctx, "Retrying after a transient failure", "call", name, "attempt", n, "delay", delay, "error", err)
+		}
+		if budgetErr := budget.FromContext(ctx).Retry(delay); budgetErr != nil {
+			slog.WarnContext(ctx, "Not retrying, as the run's budget is exhausted", "call", name, "attempt", n, "error", budgetErr)
+			return fmt.Errorf("%w (not retrying: %w)", budgetErr, err)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w (while waiting to retry after: %v)", ctx.Err(), err)
+		}
+	}
+}
+
+// Delay returns the wait before retry n, counting from 1.
+func (p Policy) Delay(n int) time.Duration {
+	delay := p.BaseDelay << (n - 1)
+	if delay <= 0 || (p.MaxDelay > 0 && delay > p.MaxDelay) {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+// throttledError is a failure whose response said how long to wait before
+// retrying.
+type throttledError struct {
+	err   error
+	after time.Duration
+}
+
+func (e *throttledError) Error() string { return e.err.Error() }
+
+func (e *throttledError) Unwrap() error { return e.err }
+
+// StatusError returns err for a response with a non-OK status, carrying the wait
+// its Retry-After header asks for, if any, to Do.
+func StatusError(resp *http.Response, err error) error {
+	if after, ok := After(resp.Header.Get("Retry-After"), time.Now()); ok {
+		return &throttledError{err: err, after: after}
+	}
+	return err
+}
+
+// After parses a Retry-After header, a number of seconds or an HTTP date, into
+// the wait from now. A date in the past is no wait.
+func After(header string, now time.Time) (time.Duration, bool) {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(header)
+	if err != nil {
+		return 0, false
+	}
+	return max(date.Sub(now), 0), true
+}
+
+// TransientStatus reports whether a response with status is worth retrying.
+func TransientStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// maxDrainBytes bounds what CloseBody reads of an unread response body.
+const maxDrainBytes = 64 << 10
+
+// CloseBody reads what is left of a small response body before closing it, so
+// that its connection can be reused, and closes a larger one unread.
+func CloseBody(body io.ReadCloser) {
+	io.Copy(io.Discard, io.LimitReader(body, maxDrainBytes))
+	body.Close()
+}
+
+// TransientError reports whether a failure to send a request or read its
+// response is worth retrying, such as a connection reset by the server.
+func TransientError(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		(errors.As(err, &netErr) && netErr.Timeout())
+}
@@ -0,0 +1,34 @@
+package retry
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	for header, want := range map[string]time.Duration{
+		"30":                            30 * time.Second,
+		" 0 ":                           0,
+		"Sun, 01 Jun 2025 12:00:45 GMT": 45 * time.Second,
+		"Sun, 01 Jun 2025 11:00:00 GMT": 0,
+	} {
+		if after, ok := After(header, now); !ok || after != want {
+			t.Errorf("Expected Retry-After %q to wait %v, got %v (%v)", header, want, after, ok)
+		}
+	}
+	for _, header := range []string{"", "-5", "soon"} {
+		if after, ok := After(header, now); ok {
+			t.Errorf("Expected Retry-After %q to fall back to the backoff, got %v", header, after)
+		}
+	}
+}
+
+func TestPolicy_Delay(t *testing.T) {
+	policy := Policy{BaseDelay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond}
+	for n, want := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 300 * time.Millisecond, 10: 300 * time.Millisecond} {
+		if delay := policy.Delay(n); delay < want/2 || delay > want {
+			t.Errorf("Expected retry %d to wait between %v and %v, got %v", n, want/2, want, delay)
+		}
+	}
+}
@@ -2,57 +2,112 @@ package server
 
 import (
 	"context"
-	"fmt"
-	"os"
+	"log/slog"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
+	"github.com/sandwichlabs/agent-memory-graph/internal/embedding"
+	"github.com/sandwichlabs/agent-memory-graph/internal/llm"
+	"github.com/sandwichlabs/agent-memory-graph/internal/metrics"
 )
 
-func Run(memoryPath string, serverName string) {
+// Expvar variables the server publishes request metrics as.
+const (
+	// llmMetricsVar holds the metrics of LLM requests.
+	llmMetricsVar = "amg_llm"
+	// embeddingMetricsVar holds the metrics of embedding requests and cache
+	// lookups.
+	embeddingMetricsVar = "amg_embedding"
+)
+
+// Run serves the memory graph at memoryPath over MCP on stdio. Its tools embed
+// queries with embeddingProvider and generate text with llmProvider; when
+// empty, these are read from AMG_EMBEDDING_PROVIDER and AMG_LLM_PROVIDER as by
+// embedding.ProviderFromEnv and llm.ProviderFromEnv. Metrics of the LLM and embedding
+// requests made are published as the expvar variables amg_llm and amg_embedding
+// and logged when the server stops.
+// With preflight, the embedding and LLM providers are pinged first and a bad key
+// or unreachable API fails Run before the server starts. The embedding provider
+// is not pinged when the tools fell back to keyword search.
+func Run(memoryPath string, serverName string, embeddingProvider embedding.Provider, llmProvider llm.Provider, preflight bool) error {
+	var err error
+	if embeddingProvider == "" {
+		embeddingProvider, err = embedding.ProviderFromEnv()
+	} else {
+		embeddingProvider, err = embedding.ParseProvider(string(embeddingProvider))
+	}
+	if err != nil {
+		return err
+	}
+	if llmProvider == "" {
+		llmProvider, err = llm.ProviderFromEnv()
+	} else {
+		llmProvider, err = llm.ParseProvider(string(llmProvider))
+	}
+	if err != nil {
+		return err
+	}
+	tools, err := newMemoryTools(memoryPath, embeddingProvider, llmProvider)
+	if err != nil {
+		return err
+	}
+	if preflight {
+		if err := tools.preflight(context.Background(), llmProvider); err != nil {
+			return err
+		}
+	}
+	slog.Info("Starting MCP server", "name", serverName, "memory", memoryPath, "embedding_provider", embeddingProvider, "llm_provider", llmProvider)
+	metrics.Default.Publish(llmMetricsVar)
+	metrics.Embeddings.Publish(embeddingMetricsVar)
+	defer func() {
+		for _, calls := range metrics.Default.Snapshot() {
+			slog.Info("LLM requests", "provider", calls.Provider, "method", calls.Method, "requests", calls.Requests,
+				"errors", calls.Errors, "errors_by_class", calls.ErrorsByClass, "p50", calls.P50, "p95", calls.P95,
+				"prompt_tokens", calls.PromptTokens, "completion_tokens", calls.CompletionTokens)
+		}
+		for _, calls := range metrics.Embeddings.Snapshot() {
+			slog.Info("Embedding requests", "provider", calls.Provider, "method", calls.Method, "requests", calls.Requests,
+				"errors", calls.Errors, "errors_by_class", calls.ErrorsByClass, "p50", calls.P50, "p95", calls.P95,
+				"texts", calls.Texts, "bytes", calls.Bytes, "cache_hits", calls.CacheHits, "cache_misses", calls.CacheMisses)
+		}
+	}()
+
 	// Initialize the MCP server with the provided memory path and server name
 	// Create a new MCP server instance
 	hooks := &server.Hooks{}
 
 	hooks.AddBeforeAny(func(ctx context.Context, id any, method mcp.MCPMethod, message any) {
-		fmt.Fprintf(os.Stderr, "beforeAny: %s, %v, %v\n", method, id, message)
+		slog.DebugContext(ctx, "beforeAny", "method", method, "id", id, "message", message)
 	})
 	hooks.AddOnSuccess(func(ctx context.Context, id any, method mcp.MCPMethod, message any, result any) {
-		fmt.Fprintf(os.Stderr, "onSuccess: %s, %v, %v, %v\n", method, id, message, result)
+		slog.DebugContext(ctx, "onSuccess", "method", method, "id", id, "message", message, "result", result)
 	})
 	hooks.AddOnError(func(ctx context.Context, id any, method mcp.MCPMethod, message any, err error) {
-		fmt.Fprintf(os.Stderr, "onError: %s, %v, %v, %v\n", method, id, message, err)
+		slog.ErrorContext(ctx, "onError", "method", method, "id", id, "message", message, "error", err)
 	})
 	hooks.AddBeforeInitialize(func(ctx context.Context, id any, message *mcp.InitializeRequest) {
-		fmt.Fprintf(os.Stderr, "beforeInitialize: %v, %v\n", id, message)
+		slog.DebugContext(ctx, "beforeInitialize", "id", id, "message", message)
 	})
 	hooks.AddOnRequestInitialization(func(ctx context.Context, id any, message any) error {
-		fmt.Fprintf(os.Stderr, "AddOnRequestInitialization: %v, %v\n", id, message)
+		slog.DebugContext(ctx, "AddOnRequestInitialization", "id", id, "message", message)
 		// authorization verification and other preprocessing tasks are performed.
 		return nil
 	})
 	hooks.AddAfterInitialize(func(ctx context.Context, id any, message *mcp.InitializeRequest, result *mcp.InitializeResult) {
-		fmt.Fprintf(os.Stderr, "afterInitialize: %v, %v, %v\n", id, message, result)
+		slog.DebugContext(ctx, "afterInitialize", "id", id, "message", message, "result", result)
 	})
 	hooks.AddAfterCallTool(func(ctx context.Context, id any, message *mcp.CallToolRequest, result *mcp.CallToolResult) {
-		fmt.Fprintf(os.Stderr, "afterCallTool: %v, %v, %v\n", id, message, result)
+		slog.DebugContext(ctx, "afterCallTool", "id", id, "message", message, "result", result)
 	})
 	hooks.AddBeforeCallTool(func(ctx context.Context, id any, message *mcp.CallToolRequest) {
-		fmt.Fprintf(os.Stderr, "beforeCallTool: %v, %v\n", id, message)
+		slog.DebugContext(ctx, "beforeCallTool", "id", id, "message", message)
 	})
-	// Define the task handler
-	// Define the tools
-	// tools :=
-	// handler := createTaskHandler(memoryPath)
-
 	s := server.NewMCPServer(serverName, "1.0.0",
 		server.WithToolCapabilities(true),
 		server.WithLogging(),
 		server.WithHooks(hooks),
 	)
-	// for _, tool := range tools {
-	// 	s.AddTool(*tool, handler) // Dereference tool
-	// }
+	tools.register(s)
 
-	server.ServeStdio(s)
+	return server.ServeStdio(s)
 }
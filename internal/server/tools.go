@@ -0,0 +1,389 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/sandwichlabs/agent-memory-graph/internal/api"
+	"github.com/sandwichlabs/agent-memory-graph/internal/embedding"
+	"github.com/sandwichlabs/agent-memory-graph/internal/llm"
+	"github.com/sandwichlabs/agent-memory-graph/internal/llm/prompts"
+	"github.com/sandwichlabs/agent-memory-graph/internal/retrieval"
+	"github.com/sandwichlabs/agent-memory-graph/internal/storage"
+)
+
+// memoryTools serves the memory graph in dir as MCP tools. The memory graph is
+// opened read-only for each call, so amg ingest can update it between calls,
+// while the query embeddings are cached across calls.
+type memoryTools struct {
+	dir        string
+	provider   embedding.Provider
+	embeddings embedding.Service
+	cache      *retrieval.QueryCache
+	// answerer is the one amg ask uses too; nil when the LLM service could not be
+	// created, for llmErr.
+	answerer *retrieval.Answerer
+	llmErr   error
+}
+
+// newMemoryTools creates the tools over the memory graph in dir, embedding
+// queries with embeddingProvider and answering questions with llmProvider.
+// Without the embedding provider's API key, or when the memory graph was embedded
+// with another provider, model or vector size, the tools search by keyword alone
+// and mark their results keyword-only. Without a usable LLM provider the tools
+// are still served, but answer_question fails.
+func newMemoryTools(dir string, embeddingProvider embedding.Provider, llmProvider llm.Provider) (*memoryTools, error) {
+	tools := &memoryTools{dir: dir, provider: embeddingProvider, cache: retrieval.NewQueryCache(0, 0)}
+	if key := embedding.MissingAPIKey(embeddingProvider); key != "" {
+		slog.Warn("searching by keyword only", "embedding_provider", embeddingProvider, "missing", key)
+	} else {
+		embeddings, err := embedding.New(embeddingProvider)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create embedding service: %w", err)
+		}
+		if err := checkEmbedder(dir, embeddings); err != nil {
+			slog.Warn("searching by keyword only", "embedding_provider", embeddingProvider, "error", err)
+		} else {
+			tools.embeddings = embeddings
+		}
+	}
+	if service, err := llm.NewLlmService(llmProvider); err != nil {
+		slog.Warn("answer_question is unavailable", "llm_provider", llmProvider, "error", err)
+		tools.llmErr = err
+	} else {
+		tools.answerer = retrieval.NewAnswerer(service)
+	}
+	return tools, nil
+}
+
+// checkEmbedder checks that the memory graph in dir was embedded like service
+// embeds queries, see retrieval.CheckEmbedder. A memory graph that cannot be
+// opened yet passes; the tools report it when called.
+func checkEmbedder(dir string, service embedding.Service) error {
+	store, err := storage.Open(dir, true)
+	if err != nil {
+		slog.Debug("not checking the embedder of an unopened memory graph", "error", err)
+		return nil
+	}
+	defer store.Close()
+	return retrieval.CheckEmbedder(context.Background(), store, service)
+}
+
+// preflight pings the embedding provider of the tools and llmProvider, see
+// embedding.Preflight and llm.Preflight. Keyword-only tools embed nothing, so
+// their embedding provider is not pinged.
+func (t *memoryTools) preflight(ctx context.Context, llmProvider llm.Provider) error {
+	if t.embeddings != nil {
+		if err := embedding.Preflight(ctx, t.provider, t.embeddings); err != nil {
+			return err
+		}
+		slog.Info("Embedding preflight passed", "embedding_provider", t.provider)
+	}
+	service, err := llm.NewLlmService(llmProvider)
+	if err != nil {
+		return err
+	}
+	if err := llm.Preflight(ctx, service); err != nil {
+		return err
+	}
+	slog.Info("LLM preflight passed", "llm_provider", llmProvider)
+	return nil
+}
+
+// register adds the tools to s.
+func (t *memoryTools) register(s *server.MCPServer) {
+	s.AddTool(searchMemoryTool, t.searchMemory)
+	s.AddTool(answerQuestionTool, t.answerQuestion)
+	s.AddTool(memoryStatsTool, t.memoryStats)
+}
+
+// searchParams are the parameters of the tools that search the memory graph,
+// read by searchOptions.
+var searchParams = []mcp.ToolOption{
+	mcp.WithNumber("k", mcp.Description("Maximum number of chunks to retrieve"), mcp.DefaultNumber(retrieval.DefaultK), mcp.Min(1)),
+	mcp.WithString("collection", mcp.Description("Only search documents in this collection")),
+	mcp.WithString("document_id", mcp.Description("Only search the document with this ID")),
+	mcp.WithString("source_prefix", mcp.Description("Only search documents whose source starts with this path or URL")),
+	mcp.WithArray("any_tags", mcp.Items(map[string]any{"type": "string"}), mcp.Description("Only search documents with any of these tags")),
+	mcp.WithArray("all_tags", mcp.Items(map[string]any{"type": "string"}), mcp.Description("Only search documents with every one of these tags; not with any_tags")),
+	mcp.WithString("ingested_after", mcp.Description("Only search documents ingested at or after this RFC 3339 time")),
+	mcp.WithString("ingested_before", mcp.Description("Only search documents ingested at or before this RFC 3339 time")),
+	mcp.WithNumber("min_score", mcp.Description("Drop chunks whose similarity is below this score, from -1 to 1 (defaults to a threshold calibrated for the embedding provider)")),
+	mcp.WithNumber("half_life_days", mcp.Description("Halve the score of chunks from documents ingested this many days ago, to favor recent memories (default: no decay)"), mcp.Min(0)),
+}
+
+var searchMemoryTool = mcp.NewTool("search_memory", append([]mcp.ToolOption{
+	mcp.WithDescription("Find the stored chunks most relevant to a query, best first. " +
+		"Results are marked keyword_only when the server has no usable embedding provider and ranks them by keyword alone."),
+	mcp.WithString("query", mcp.Required(), mcp.Description("What to search the memory graph for")),
+	mcp.WithString("cursor", mcp.Description("The next_cursor of the previous page, to return the next k chunks")),
+	mcp.WithBoolean("group", mcp.Description("Also return the chunks grouped by document, groups ordered by their best chunk")),
+	mcp.WithNumber("group_size", mcp.Description("Maximum number of chunks shown in each group"), mcp.DefaultNumber(retrieval.DefaultChunksPerGroup), mcp.Min(1)),
+	mcp.WithBoolean("context", mcp.Description("Attach the chunks before and after each grouped chunk; needs group")),
+}, searchParams...)...)
+
+// searchMemory answers search_memory with an api.QueryResponse. Pages are cut
+// from the flat ranking before grouping, so a cursor returns the same chunks
+// whether or not the search is grouped.
+func (t *memoryTools) searchMemory(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	query, err := req.RequireString("query")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	opts, err := t.searchOptions(req)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if opts.Offset, err = parseCursor(req.GetString("cursor", "")); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	withContext := req.GetBool("context", false)
+	switch size := req.GetInt("group_size", retrieval.DefaultChunksPerGroup); {
+	case !req.GetBool("group", false):
+		if withContext {
+			return mcp.NewToolResultError("context needs group"), nil
+		}
+	case size < 1:
+		return mcp.NewToolResultError(fmt.Sprintf("invalid group_size %d: must be at least 1", size)), nil
+	default:
+		opts.Grouping = &retrieval.Grouping{ChunksPerGroup: size, Context: withContext}
+	}
+
+	results, err := t.retrieve(ctx, query, opts)
+	if err != nil {
+		return mcp.NewToolResultErrorFromErr("search failed", err), nil
+	}
+	response := api.NewQueryResponse(query, results)
+	if results.More {
+		response.NextCursor = formatCursor(opts.Offset + len(results.Hits))
+	}
+	return jsonResult(response)
+}
+
+// formatCursor returns the opaque cursor of the page starting at offset in the
+// ranking.
+func formatCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+// parseCursor returns the ranking offset of a cursor from formatCursor; an empty
+// cursor is the first page.
+func parseCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	offset, err := strconv.Atoi(string(data))
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	return offset, nil
+}
+
+var answerQuestionTool = mcp.NewTool("answer_question", append([]mcp.ToolOption{
+	mcp.WithDescription("Answer a question from the memory graph, citing the chunks the answer draws on inline like [1]. " +
+		"Pass the conversation so far as history to answer a follow-up question, which is then rewritten into a standalone query for the search."),
+	mcp.WithString("question", mcp.Required(), mcp.Description("The question to answer")),
+	mcp.WithArray("history", mcp.Description("The turns of the conversation leading up to the question, oldest first"), mcp.Items(map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"role":    map[string]any{"type": "string", "enum": []string{retrieval.RoleUser, retrieval.RoleAssistant}},
+			"content": map[string]any{"type": "string"},
+		},
+		"required": []string{"role", "content"},
+	})),
+}, searchParams...)...)
+
+// noAnswer is the answer to a question nothing in the memory graph is relevant
+// to, as amg ask prints it.
+const noAnswer = "I don't have information about that."
+
+// answerQuestion answers answer_question with an api.AnswerResponse. When the call
+// carries a progress token, the answer is streamed as progress notifications
+// before the final result with its citations. When ctx is cancelled mid-stream,
+// such as when the server shuts down, generation stops and the answer so far is
+// returned marked partial.
+func (t *memoryTools) answerQuestion(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	question, err := req.RequireString("question")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if t.answerer == nil {
+		return mcp.NewToolResultErrorFromErr("answer_question needs an LLM provider", t.llmErr), nil
+	}
+	history, err := conversationHistory(req)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	opts, err := t.searchOptions(req)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	promptSet, err := prompts.Load(filepath.Join(t.dir, prompts.Dir))
+	if err != nil {
+		return mcp.NewToolResultErrorFromErr("failed to load the prompts", err), nil
+	}
+
+	store, err := storage.Open(t.dir, true)
+	if err != nil {
+		return mcp.NewToolResultErrorFromErr("failed to open the memory graph", err), nil
+	}
+	defer store.Close()
+	answer, err := t.answerer.Converse(ctx, t.retriever(store), question, history, opts, retrieval.AnswerOptions{
+		Stream:  streamProgress(ctx, req),
+		Prompts: promptSet,
+	})
+	switch {
+	case errors.Is(err, retrieval.ErrNoContext):
+		response := api.NewAnswerResponse(question, answer)
+		response.Answer, response.NoRelevantResults = noAnswer, true
+		return jsonResult(response)
+	case err != nil && !answer.Partial:
+		return mcp.NewToolResultErrorFromErr("answering failed", err), nil
+	}
+	if len(answer.Dropped) > 0 {
+		slog.WarnContext(ctx, "dropped citations to context the model was not given", "citations", answer.Dropped)
+	}
+	return jsonResult(api.NewAnswerResponse(question, answer))
+}
+
+// streamProgress returns a stream sending the answer generated so far to the
+// client in the message of a progress notification, or nil when the call did not
+// ask for progress.
+func streamProgress(ctx context.Context, req mcp.CallToolRequest) func(string) {
+	srv := server.ServerFromContext(ctx)
+	if srv == nil || req.Params.Meta == nil || req.Params.Meta.ProgressToken == nil {
+		return nil
+	}
+	var text strings.Builder
+	return func(piece string) {
+		text.WriteString(piece)
+		err := srv.SendNotificationToClient(ctx, "notifications/progress", map[string]any{
+			"progressToken": req.Params.Meta.ProgressToken,
+			"progress":      text.Len(),
+			"message":       text.String(),
+		})
+		if err != nil {
+			slog.DebugContext(ctx, "failed to send answer progress", "error", err)
+		}
+	}
+}
+
+// conversationHistory reads the history of an answer_question call.
+func conversationHistory(req mcp.CallToolRequest) ([]retrieval.Turn, error) {
+	turns, _ := req.GetArguments()["history"].([]any)
+	history := make([]retrieval.Turn, 0, len(turns))
+	for i, value := range turns {
+		turn, _ := value.(map[string]any)
+		role, _ := turn["role"].(string)
+		content, _ := turn["content"].(string)
+		if role != retrieval.RoleUser && role != retrieval.RoleAssistant {
+			return nil, fmt.Errorf("invalid history turn %d: role must be %s or %s", i, retrieval.RoleUser, retrieval.RoleAssistant)
+		}
+		history = append(history, retrieval.Turn{Role: role, Content: content})
+	}
+	return history, nil
+}
+
+// searchOptions builds the retrieval options from the searchParams of a call.
+func (t *memoryTools) searchOptions(req mcp.CallToolRequest) (retrieval.SearchOptions, error) {
+	opts := retrieval.SearchOptions{K: req.GetInt("k", retrieval.DefaultK)}
+	switch days := req.GetFloat("half_life_days", 0); {
+	case days < 0:
+		return opts, fmt.Errorf("invalid half_life_days %v: must not be negative", days)
+	case days > 0:
+		opts.Recency = &retrieval.Recency{HalfLife: time.Duration(days * float64(24*time.Hour))}
+	}
+	var err error
+	opts.Filter, err = t.searchFilter(req)
+	return opts, err
+}
+
+// searchFilter builds the filter of a search call.
+func (t *memoryTools) searchFilter(req mcp.CallToolRequest) (retrieval.SearchFilter, error) {
+	filter := retrieval.SearchFilter{
+		Collection:   req.GetString("collection", ""),
+		DocumentID:   req.GetString("document_id", ""),
+		SourcePrefix: req.GetString("source_prefix", ""),
+		AnyTags:      req.GetStringSlice("any_tags", nil),
+		AllTags:      req.GetStringSlice("all_tags", nil),
+		MinScore:     req.GetFloat("min_score", retrieval.DefaultMinScore(t.provider)),
+	}
+	var err error
+	if filter.IngestedAfter, err = parseTime(req.GetString("ingested_after", "")); err != nil {
+		return filter, fmt.Errorf("invalid ingested_after: %w", err)
+	}
+	if filter.IngestedBefore, err = parseTime(req.GetString("ingested_before", "")); err != nil {
+		return filter, fmt.Errorf("invalid ingested_before: %w", err)
+	}
+	return filter, filter.Validate()
+}
+
+// parseTime parses an RFC 3339 time; an empty string is the zero time.
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a time like 2025-01-31T09:00:00Z", s)
+	}
+	return t, nil
+}
+
+// retrieve searches the memory graph for query.
+func (t *memoryTools) retrieve(ctx context.Context, query string, opts retrieval.SearchOptions) (retrieval.Results, error) {
+	store, err := storage.Open(t.dir, true)
+	if err != nil {
+		return retrieval.Results{}, err
+	}
+	defer store.Close()
+	return t.retriever(store).Retrieve(ctx, query, opts)
+}
+
+// retriever creates a retriever over store sharing the tools' query cache.
+func (t *memoryTools) retriever(store *storage.KuzuStore) *retrieval.Retriever {
+	return retrieval.NewRetriever(store, t.embeddings).WithQueryCache(t.cache, string(t.provider), embedding.ModelOf(t.embeddings))
+}
+
+var memoryStatsTool = mcp.NewTool("memory_stats",
+	mcp.WithDescription("Count the documents, chunks, entities and relationships in the memory graph, and the hits of the server's query cache."),
+)
+
+// memoryStats answers memory_stats with an api.Stats.
+func (t *memoryTools) memoryStats(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	store, err := storage.Open(t.dir, true)
+	if err != nil {
+		return mcp.NewToolResultErrorFromErr("failed to open the memory graph", err), nil
+	}
+	defer store.Close()
+	stored, err := store.Stats(ctx)
+	if err != nil {
+		return mcp.NewToolResultErrorFromErr("failed to count the memory graph", err), nil
+	}
+	stats := api.NewStats(stored)
+	stats.QueryCache = api.NewQueryCacheStats(t.cache.Stats())
+	return jsonResult(stats)
+}
+
+// jsonResult returns v, one of the api types, as the JSON text of a tool result.
+func jsonResult(v any) (*mcp.CallToolResult, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return mcp.NewToolResultText(string(data)), nil
+}
@@ -0,0 +1,505 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/sandwichlabs/agent-memory-graph/internal/api"
+	"github.com/sandwichlabs/agent-memory-graph/internal/embedding"
+	"github.com/sandwichlabs/agent-memory-graph/internal/llm"
+	"github.com/sandwichlabs/agent-memory-graph/internal/retrieval"
+	"github.com/sandwichlabs/agent-memory-graph/internal/storage"
+)
+
+// seedGraph creates a memory graph with a document about Kuzu in the research
+// collection, ingested in January 2025, and one about Go in the notes
+// collection, ingested in June, embedded by the "testing" provider.
+func seedGraph(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	store, err := storage.Open(dir, false)
+	if err != nil {
+		t.Fatalf("Failed to create memory graph: %v", err)
+	}
+	defer store.Close()
+
+	documents := []struct {
+		doc     storage.Document
+		content []string
+	}{
+		{storage.Document{ID: "kuzu", Source: "notes/kuzu.md", Collection: "research", Tags: []string{"db"}, IngestedAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}, []string{"Kuzu Inc builds KuzuDB.", "KuzuDB speaks Cypher."}},
+		{storage.Document{ID: "go", Source: "notes/go.md", Collection: "notes", Tags: []string{"lang"}, IngestedAt: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)}, []string{"Go compiles quickly."}},
+	}
+	for _, document := range documents {
+		var chunks []storage.Chunk
+		for i, content := range document.content {
+			chunks = append(chunks, storage.Chunk{
+				ID: fmt.Sprintf("%s-%d", document.doc.ID, i), DocumentID: document.doc.ID, Content: content, Index: i,
+				Embedding: embedding.MockVector(content, storage.EmbeddingDimensions),
+			})
+		}
+		if err := store.SaveDocument(context.Background(), document.doc, chunks); err != nil {
+			t.Fatalf("Failed to save document: %v", err)
+		}
+	}
+	return dir
+}
+
+func newTestTools(t *testing.T, dir string) *memoryTools {
+	t.Helper()
+	tools, err := newMemoryTools(dir, embedding.ProviderTestMock, llm.ProviderTestMock)
+	if err != nil {
+		t.Fatalf("Failed to create tools: %v", err)
+	}
+	return tools
+}
+
+// callTool calls handler with arguments, failing the test on a protocol error.
+func callTool(t *testing.T, handler func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error), arguments map[string]any) *mcp.CallToolResult {
+	t.Helper()
+	var req mcp.CallToolRequest
+	req.Params.Arguments = arguments
+	result, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Tool call failed: %v", err)
+	}
+	return result
+}
+
+// resultText returns the text content of result.
+func resultText(t *testing.T, result *mcp.CallToolResult) string {
+	t.Helper()
+	if len(result.Content) != 1 {
+		t.Fatalf("Expected one content item, got %+v", result.Content)
+	}
+	text, ok := mcp.AsTextContent(result.Content[0])
+	if !ok {
+		t.Fatalf("Expected text content, got %+v", result.Content[0])
+	}
+	return text.Text
+}
+
+// searchResponse calls search_memory with arguments and decodes its response.
+func searchResponse(t *testing.T, tools *memoryTools, arguments map[string]any) api.QueryResponse {
+	t.Helper()
+	result := callTool(t, tools.searchMemory, arguments)
+	if result.IsError {
+		t.Fatalf("Expected a search response, got error %q", resultText(t, result))
+	}
+	var response api.QueryResponse
+	if err := json.Unmarshal([]byte(resultText(t, result)), &response); err != nil {
+		t.Fatalf("Expected a query response, got %q: %v", resultText(t, result), err)
+	}
+	return response
+}
+
+func TestSearchMemory_Filters(t *testing.T) {
+	tools := newTestTools(t, seedGraph(t))
+
+	tests := []struct {
+		name      string
+		arguments map[string]any
+		want      []string
+	}{
+		{"no filter", map[string]any{}, []string{"kuzu-0", "kuzu-1", "go-0"}},
+		{"collection", map[string]any{"collection": "notes"}, []string{"go-0"}},
+		{"document", map[string]any{"document_id": "kuzu"}, []string{"kuzu-0", "kuzu-1"}},
+		{"source prefix", map[string]any{"source_prefix": "notes/go"}, []string{"go-0"}},
+		{"any tags", map[string]any{"any_tags": []any{"lang", "other"}}, []string{"go-0"}},
+		{"all tags", map[string]any{"all_tags": []any{"db", "lang"}}, nil},
+		{"ingested after", map[string]any{"ingested_after": "2025-03-01T00:00:00Z"}, []string{"go-0"}},
+		{"ingested before", map[string]any{"ingested_before": "2025-03-01T00:00:00Z"}, []string{"kuzu-0", "kuzu-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			arguments := map[string]any{"query": "Kuzu Inc builds KuzuDB.", "min_score": -1}
+			for key, value := range tt.arguments {
+				arguments[key] = value
+			}
+			response := searchResponse(t, tools, arguments)
+			var got []string
+			for _, chunk := range response.Results {
+				got = append(got, chunk.ID)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expected %v, got %v", tt.want, got)
+			}
+			for _, id := range tt.want {
+				if !strings.Contains(strings.Join(got, " "), id) {
+					t.Errorf("Expected %v, got %v", tt.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchMemory_RejectsInvalidFilters(t *testing.T) {
+	tools := newTestTools(t, seedGraph(t))
+
+	tests := []struct {
+		name      string
+		arguments map[string]any
+		want      string
+	}{
+		{"missing query", map[string]any{}, "query"},
+		{"both tag modes", map[string]any{"query": "kuzu", "any_tags": []any{"db"}, "all_tags": []any{"lang"}}, "tags"},
+		{"bad time", map[string]any{"query": "kuzu", "ingested_after": "yesterday"}, "invalid ingested_after"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := callTool(t, tools.searchMemory, tt.arguments)
+			if !result.IsError || !strings.Contains(resultText(t, result), tt.want) {
+				t.Errorf("Expected an error mentioning %q, got %+v", tt.want, result)
+			}
+		})
+	}
+}
+
+func TestMemoryStats_CountsQueryCacheLookups(t *testing.T) {
+	tools := newTestTools(t, seedGraph(t))
+
+	// The second search differs only in case and spacing, so it reuses the
+	// embedding of the first.
+	searchResponse(t, tools, map[string]any{"query": "What does Kuzu build?"})
+	searchResponse(t, tools, map[string]any{"query": "what does  kuzu build?"})
+
+	result := callTool(t, tools.memoryStats, nil)
+	var stats api.Stats
+	if err := json.Unmarshal([]byte(resultText(t, result)), &stats); err != nil {
+		t.Fatalf("Expected memory statistics, got %q: %v", resultText(t, result), err)
+	}
+	if stats.Documents != 2 || stats.Chunks != 3 {
+		t.Errorf("Expected 2 documents and 3 chunks, got %+v", stats)
+	}
+	want := api.QueryCacheStats{Hits: 1, Misses: 1, Entries: 1}
+	if stats.QueryCache == nil || *stats.QueryCache != want {
+		t.Errorf("Expected query cache stats %+v, got %+v", want, stats.QueryCache)
+	}
+}
+
+func TestAnswerQuestion_CitesRetrievedChunks(t *testing.T) {
+	tools := newTestTools(t, seedGraph(t))
+	model := &llm.MockLlmService{Response: "Kuzu Inc builds KuzuDB [1], which is fast [7]."}
+	tools.answerer = retrieval.NewAnswerer(model)
+
+	result := callTool(t, tools.answerQuestion, map[string]any{"question": "Kuzu Inc builds KuzuDB.", "collection": "research"})
+	var response api.AnswerResponse
+	if err := json.Unmarshal([]byte(resultText(t, result)), &response); err != nil {
+		t.Fatalf("Expected an answer, got %q: %v", resultText(t, result), err)
+	}
+	if response.Answer != "Kuzu Inc builds KuzuDB [1], which is fast." {
+		t.Errorf("Expected the answer without the made-up citation, got %q", response.Answer)
+	}
+	if len(response.Citations) != 1 || response.Citations[0].Number != 1 || response.Citations[0].Chunk.ID != "kuzu-0" {
+		t.Errorf("Expected the answer to cite kuzu-0, got %+v", response.Citations)
+	}
+	if !reflect.DeepEqual(response.DroppedCitations, []int{7}) {
+		t.Errorf("Expected citation 7 dropped, got %v", response.DroppedCitations)
+	}
+	if prompts := model.Prompts(); len(prompts) != 1 || strings.Contains(prompts[0], "Go compiles quickly.") {
+		t.Errorf("Expected one prompt holding only the research collection, got %q", prompts)
+	}
+}
+
+func TestAnswerQuestion_NeedsLLM(t *testing.T) {
+	t.Setenv("MISTRAL_API_KEY", "")
+	tools, err := newMemoryTools(seedGraph(t), embedding.ProviderTestMock, llm.ProviderMistral)
+	if err != nil {
+		t.Fatalf("Expected the tools without an LLM, got %v", err)
+	}
+
+	result := callTool(t, tools.answerQuestion, map[string]any{"question": "Who builds KuzuDB?"})
+	if !result.IsError || !strings.Contains(resultText(t, result), "MISTRAL_API_KEY") {
+		t.Errorf("Expected an error naming the missing key, got %+v", result)
+	}
+}
+
+func TestSearchMemory_HalfLife(t *testing.T) {
+	tools := newTestTools(t, seedGraph(t))
+	arguments := map[string]any{"query": "Kuzu Inc builds KuzuDB.", "document_id": "kuzu", "k": 1}
+
+	response := searchResponse(t, tools, arguments)
+	if len(response.Results) != 1 || response.Results[0].Score < 0.999 {
+		t.Fatalf("Expected the exact match undecayed, got %+v", response.Results)
+	}
+
+	// The document was ingested in January 2025, many 30-day half-lives ago.
+	arguments["half_life_days"] = 30
+	response = searchResponse(t, tools, arguments)
+	if len(response.Results) != 1 || response.Results[0].Score > 0.01 {
+		t.Errorf("Expected the old match decayed, got %+v", response.Results)
+	}
+
+	arguments["half_life_days"] = -1
+	if result := callTool(t, tools.searchMemory, arguments); !result.IsError || !strings.Contains(resultText(t, result), "half_life_days") {
+		t.Errorf("Expected a negative half-life to be refused, got %+v", result)
+	}
+}
+
+func TestAnswerQuestion_RewritesFollowUpsWithHistory(t *testing.T) {
+	tools := newTestTools(t, seedGraph(t))
+	model := &llm.MockLlmService{Responses: []llm.MockResponse{
+		{Text: `{"query": "Go compiles quickly."}`},
+		{Text: "It compiles quickly [1]."},
+	}}
+	tools.answerer = retrieval.NewAnswerer(model)
+
+	result := callTool(t, tools.answerQuestion, map[string]any{
+		"question": "and how fast does it build?",
+		"history": []any{
+			map[string]any{"role": "user", "content": "What language is the server written in?"},
+			map[string]any{"role": "assistant", "content": "Go."},
+		},
+		"k": 1,
+	})
+	var response api.AnswerResponse
+	if err := json.Unmarshal([]byte(resultText(t, result)), &response); err != nil {
+		t.Fatalf("Expected an answer, got %q: %v", resultText(t, result), err)
+	}
+	if response.Query != "Go compiles quickly." {
+		t.Errorf("Expected the rewritten query, got %q", response.Query)
+	}
+	if len(response.Citations) != 1 || response.Citations[0].Chunk.ID != "go-0" {
+		t.Errorf("Expected the answer to cite the chunk the rewrite found, got %+v", response.Citations)
+	}
+	if prompts := model.Prompts(); len(prompts) != 2 || !strings.Contains(prompts[0], "assistant: Go.") {
+		t.Errorf("Expected the history in the rewrite prompt, got %q", prompts)
+	}
+
+	result = callTool(t, tools.answerQuestion, map[string]any{
+		"question": "and how fast does it build?",
+		"history":  []any{map[string]any{"role": "system", "content": "Be brief."}},
+	})
+	if !result.IsError || !strings.Contains(resultText(t, result), "history turn 0") {
+		t.Errorf("Expected the unknown role to be refused, got %+v", result)
+	}
+}
+
+// slowStream streams its tokens one per delay until the context is cancelled,
+// closing done when it stops.
+type slowStream struct {
+	llm.MockLlmService
+	tokens []string
+	delay  time.Duration
+	done   chan struct{}
+}
+
+func (s *slowStream) GenerateTextStream(ctx context.Context, prompt string, opts ...llm.GenerateOption) (<-chan string, <-chan error) {
+	tokens, errs := make(chan string), make(chan error, 1)
+	go func() {
+		defer close(s.done)
+		defer close(errs)
+		defer close(tokens)
+		for _, token := range s.tokens {
+			select {
+			case <-time.After(s.delay):
+			case <-ctx.Done():
+				errs <- ctx.Err()
+				return
+			}
+			select {
+			case tokens <- token:
+			case <-ctx.Done():
+				errs <- ctx.Err()
+				return
+			}
+		}
+	}()
+	return tokens, errs
+}
+
+// testSession is the session of a client receiving notifications on a channel.
+type testSession struct {
+	notifications chan mcp.JSONRPCNotification
+}
+
+func (s *testSession) Initialize()                                         {}
+func (s *testSession) Initialized() bool                                   { return true }
+func (s *testSession) NotificationChannel() chan<- mcp.JSONRPCNotification { return s.notifications }
+func (s *testSession) SessionID() string                                   { return "test" }
+
+func TestAnswerQuestion_StreamsProgressUntilCancelled(t *testing.T) {
+	tools := newTestTools(t, seedGraph(t))
+	model := &slowStream{tokens: []string{"KuzuDB ", "is built ", "by Kuzu Inc [1]", "."}, delay: 20 * time.Millisecond, done: make(chan struct{})}
+	tools.answerer = retrieval.NewAnswerer(model)
+	srv := server.NewMCPServer("test", "1.0.0", server.WithToolCapabilities(true))
+	tools.register(srv)
+
+	// Cancel the call once the client has been sent two pieces of the answer.
+	session := &testSession{notifications: make(chan mcp.JSONRPCNotification, 8)}
+	ctx, cancel := context.WithCancel(srv.WithContext(context.Background(), session))
+	defer cancel()
+	var messages []string
+	received := make(chan struct{})
+	go func() {
+		defer close(received)
+		for notification := range session.notifications {
+			fields := notification.Params.AdditionalFields
+			if notification.Method != "notifications/progress" || fields["progressToken"] != "answer-1" {
+				t.Errorf("Expected answer progress, got %+v", notification)
+			}
+			messages = append(messages, fields["message"].(string))
+			if len(messages) == 2 {
+				cancel()
+			}
+		}
+	}()
+
+	response := srv.HandleMessage(ctx, json.RawMessage(`{"jsonrpc": "2.0", "id": 1, "method": "tools/call", "params": {
+		"name": "answer_question", "arguments": {"question": "Kuzu Inc builds KuzuDB.", "k": 1}, "_meta": {"progressToken": "answer-1"}}}`))
+	close(session.notifications)
+	<-received
+
+	if want := []string{"KuzuDB ", "KuzuDB is built "}; !reflect.DeepEqual(messages, want) {
+		t.Errorf("Expected progress %q, got %q", want, messages)
+	}
+	result, ok := response.(mcp.JSONRPCResponse).Result.(mcp.CallToolResult)
+	if !ok {
+		t.Fatalf("Expected a tool result, got %+v", response)
+	}
+	var answer api.AnswerResponse
+	if err := json.Unmarshal([]byte(resultText(t, &result)), &answer); err != nil {
+		t.Fatalf("Expected an answer, got %q: %v", resultText(t, &result), err)
+	}
+	if !answer.Partial || answer.Answer != "KuzuDB is built" {
+		t.Errorf("Expected the partial answer, got %+v", answer)
+	}
+	select {
+	case <-model.done:
+	case <-time.After(time.Second):
+		t.Errorf("Expected the stream to stop after cancellation")
+	}
+}
+
+func TestSearchMemory_MarksKeywordOnlyResults(t *testing.T) {
+	dir := seedGraph(t)
+	t.Setenv("MISTRAL_API_KEY", "")
+	tools, err := newMemoryTools(dir, embedding.ProviderMistral, llm.ProviderTestMock)
+	if err != nil {
+		t.Fatalf("Expected the tools without an embedding key, got %v", err)
+	}
+
+	response := searchResponse(t, tools, map[string]any{"query": "cypher"})
+	if !response.KeywordOnly || len(response.Results) != 1 || response.Results[0].ID != "kuzu-1" {
+		t.Errorf("Expected the keyword match marked keyword-only, got %+v", response)
+	}
+	result := callTool(t, tools.searchMemory, map[string]any{"query": "cypher"})
+	if !strings.Contains(resultText(t, result), `"keyword_only":true`) {
+		t.Errorf("Expected keyword_only in the structured result, got %s", resultText(t, result))
+	}
+
+	// A configured provider still ranks by similarity.
+	response = searchResponse(t, newTestTools(t, dir), map[string]any{"query": "cypher"})
+	if response.KeywordOnly {
+		t.Errorf("Expected similarity results, got %+v", response)
+	}
+}
+
+func TestSearchMemory_PagesGroupedAndFlatAlike(t *testing.T) {
+	tools := newTestTools(t, seedGraph(t))
+
+	// pages returns the chunk IDs of each page and the cursors between them.
+	pages := func(group bool) ([][]string, []string) {
+		var ids [][]string
+		var cursors []string
+		cursor := ""
+		for len(ids) < 5 {
+			response := searchResponse(t, tools, map[string]any{"query": "KuzuDB", "k": 2, "min_score": -1, "group": group, "group_size": 1, "cursor": cursor})
+			var page []string
+			for _, chunk := range response.Results {
+				page = append(page, chunk.ID)
+			}
+			if group {
+				grouped := 0
+				for _, g := range response.Groups {
+					grouped += len(g.Results) + g.Omitted
+				}
+				if grouped != len(page) {
+					t.Errorf("Expected the groups to hold the page's %d chunks, got %+v", len(page), response.Groups)
+				}
+			} else if response.Groups != nil {
+				t.Errorf("Expected no groups without group, got %+v", response.Groups)
+			}
+			ids = append(ids, page)
+			if response.NextCursor == "" {
+				return ids, cursors
+			}
+			cursors = append(cursors, response.NextCursor)
+			cursor = response.NextCursor
+		}
+		t.Fatalf("Expected paging to end, got pages %v", ids)
+		return nil, nil
+	}
+
+	flatIDs, flatCursors := pages(false)
+	groupedIDs, groupedCursors := pages(true)
+	if len(flatIDs) != 2 || len(flatIDs[0]) != 2 || len(flatIDs[1]) != 1 {
+		t.Fatalf("Expected two pages of the three chunks, got %v", flatIDs)
+	}
+	if !reflect.DeepEqual(groupedIDs, flatIDs) || !reflect.DeepEqual(groupedCursors, flatCursors) {
+		t.Errorf("Expected the grouped pages %v with cursors %v, got %v with %v", flatIDs, flatCursors, groupedIDs, groupedCursors)
+	}
+
+	for _, arguments := range []map[string]any{
+		{"query": "KuzuDB", "cursor": "not a cursor"},
+		{"query": "KuzuDB", "context": true},
+		{"query": "KuzuDB", "group": true, "group_size": 0},
+	} {
+		if result := callTool(t, tools.searchMemory, arguments); !result.IsError {
+			t.Errorf("Expected %v to be refused, got %q", arguments, resultText(t, result))
+		}
+	}
+}
+
+func TestSearchMemory_KeywordOnlyWithAnotherEmbedder(t *testing.T) {
+	dir := seedGraph(t)
+	store, err := storage.Open(dir, false)
+	if err != nil {
+		t.Fatalf("Failed to open memory graph: %v", err)
+	}
+	if err := store.SetEmbeddingProvider(context.Background(), string(embedding.ProviderMistral)); err != nil {
+		t.Fatalf("Failed to record the embedding provider: %v", err)
+	}
+	store.Close()
+
+	// The testing provider's vectors do not compare with the recorded mistral ones.
+	response := searchResponse(t, newTestTools(t, dir), map[string]any{"query": "cypher"})
+	if !response.KeywordOnly || len(response.Results) != 1 || response.Results[0].ID != "kuzu-1" {
+		t.Errorf("Expected the keyword match marked keyword-only, got %+v", response)
+	}
+}
+
+// unauthorizedEmbedder is an embedding service whose provider rejects the key.
+type unauthorizedEmbedder struct {
+	embedding.Service
+}
+
+func (unauthorizedEmbedder) Ping(ctx context.Context) error {
+	return embedding.ErrUnauthorized
+}
+
+func TestPreflight_PingsTheEmbeddingProvider(t *testing.T) {
+	tools := newTestTools(t, seedGraph(t))
+	if err := tools.preflight(context.Background(), llm.ProviderTestMock); err != nil {
+		t.Fatalf("Expected the test providers to pass, got %v", err)
+	}
+
+	tools.embeddings = unauthorizedEmbedder{tools.embeddings}
+	if err := tools.preflight(context.Background(), llm.ProviderTestMock); !errors.Is(err, embedding.ErrUnauthorized) {
+		t.Errorf("Expected the rejected embedding key to fail preflight, got %v", err)
+	}
+
+	// Keyword-only tools embed nothing to ping for.
+	tools.embeddings = nil
+	if err := tools.preflight(context.Background(), llm.ProviderTestMock); err != nil {
+		t.Errorf("Expected keyword-only tools to skip the embedding ping, got %v", err)
+	}
+}
@@ -0,0 +1,264 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Entity is a named thing mentioned by chunks, such as a person, organization or concept.
+type Entity struct {
+	Name    string
+	Type    string
+	Aliases []string
+
+	// MentionCount and RelationshipCount are filled in by ListEntities and GetEntity.
+	MentionCount      int
+	RelationshipCount int
+}
+
+// Relationship is a directed, labeled edge between two entities.
+type Relationship struct {
+	Subject   string
+	Predicate string
+	Object    string
+}
+
+// EntityFilter narrows ListEntities. Zero values match everything.
+type EntityFilter struct {
+	// Match is a case-insensitive substring of the entity name.
+	Match string
+	Type  string
+	Limit int
+}
+
+// EntityDetail is an entity together with its relationships and the chunks that mention it.
+type EntityDetail struct {
+	Entity
+	Relationships []Relationship
+	Chunks        []ScoredChunk
+}
+
+// saveEntities merges the chunk's entities and relationships into the graph. Names that
+// match an alias of an existing entity are attached to that entity instead.
+func (s *KuzuStore) saveEntities(chunk Chunk) error {
+	for _, entity := range chunk.Entities {
+		name, err := s.mergeEntity(entity.Name, entity.Type)
+		if err != nil {
+			return err
+		}
+		if err := s.execute("MATCH (c:Chunk {id: $chunk}), (e:Entity {name: $name}) MERGE (c)-[:MENTIONS]->(e)", map[string]any{
+			"chunk": chunk.ID,
+			"name":  name,
+		}); err != nil {
+			return err
+		}
+	}
+	for _, rel := range chunk.Relationships {
+		subject, err := s.mergeEntity(rel.Subject, "")
+		if err != nil {
+			return err
+		}
+		object, err := s.mergeEntity(rel.Object, "")
+		if err != nil {
+			return err
+		}
+		if err := s.execute("MATCH (a:Entity {name: $subject}), (b:Entity {name: $object}) MERGE (a)-[:RELATED {predicate: $predicate}]->(b)", map[string]any{
+			"subject":   subject,
+			"object":    object,
+			"predicate": rel.Predicate,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeEntity creates the entity if needed and returns its canonical name.
+func (s *KuzuStore) mergeEntity(name, entityType string) (string, error) {
+	name = strings.TrimSpace(name)
+	rows, err := s.rows("MATCH (e:Entity) WHERE list_contains(e.aliases, $name) RETURN e.name", map[string]any{"name": name})
+	if err != nil {
+		return "", err
+	}
+	if len(rows) > 0 {
+		return asString(rows[0][0]), nil
+	}
+	if err := s.execute("MERGE (e:Entity {name: $name}) ON CREATE SET e.type = $type, e.aliases = []", map[string]any{
+		"name": name,
+		"type": entityType,
+	}); err != nil {
+		return "", err
+	}
+	if entityType != "" {
+		// Fill in a type for entities first seen as a bare relationship endpoint.
+		if err := s.execute("MATCH (e:Entity {name: $name}) WHERE e.type = '' SET e.type = $type", map[string]any{
+			"name": name,
+			"type": entityType,
+		}); err != nil {
+			return "", err
+		}
+	}
+	return name, nil
+}
+
+// ListEntities returns entities with their mention and relationship counts,
+// most mentioned first.
+func (s *KuzuStore) ListEntities(ctx context.Context, filter EntityFilter) ([]Entity, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	// The filter is applied here rather than in a WHERE clause: Kuzu can count
+	// edges of deleted chunks when a property filter is pushed into the scan.
+	rows, err := s.rows(`MATCH (e:Entity)
+		RETURN e.name, e.type, e.aliases,
+			COUNT { MATCH (:Chunk)-[:MENTIONS]->(e) } AS mentions,
+			COUNT { MATCH (e)-[:RELATED]-(:Entity) } AS relationships
+		ORDER BY mentions DESC, e.name`, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list entities: %w", err)
+	}
+
+	match := strings.ToLower(filter.Match)
+	entities := make([]Entity, 0, min(len(rows), limit))
+	for _, row := range rows {
+		if len(entities) == limit {
+			break
+		}
+		entity := Entity{
+			Name:              asString(row[0]),
+			Type:              asString(row[1]),
+			Aliases:           asStrings(row[2]),
+			MentionCount:      int(asInt64(row[3])),
+			RelationshipCount: int(asInt64(row[4])),
+		}
+		if !strings.Contains(strings.ToLower(entity.Name), match) || (filter.Type != "" && entity.Type != filter.Type) {
+			continue
+		}
+		entities = append(entities, entity)
+	}
+	return entities, nil
+}
+
+// GetEntity returns an entity by name or alias with its relationships and up to
+// chunkLimit mentioning chunks. It returns nil when no such entity exists.
+func (s *KuzuStore) GetEntity(ctx context.Context, name string, chunkLimit int) (*EntityDetail, error) {
+	rows, err := s.rows(`MATCH (e:Entity) WHERE e.name = $name OR list_contains(e.aliases, $name)
+		RETURN e.name, e.type, e.aliases`, map[string]any{"name": name})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get entity: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	detail := &EntityDetail{Entity: Entity{
+		Name:    asString(rows[0][0]),
+		Type:    asString(rows[0][1]),
+		Aliases: asStrings(rows[0][2]),
+	}}
+
+	rows, err = s.rows(`MATCH (a:Entity)-[r:RELATED]->(b:Entity)
+		WHERE a.name = $name OR b.name = $name
+		RETURN a.name, r.predicate, b.name ORDER BY a.name, r.predicate, b.name`, map[string]any{"name": detail.Name})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get relationships: %w", err)
+	}
+	for _, row := range rows {
+		detail.Relationships = append(detail.Relationships, Relationship{
+			Subject:   asString(row[0]),
+			Predicate: asString(row[1]),
+			Object:    asString(row[2]),
+		})
+	}
+	detail.RelationshipCount = len(detail.Relationships)
+
+	if chunkLimit <= 0 {
+		chunkLimit = 5
+	}
+	rows, err = s.rows(`MATCH (d:Document)-[:HAS_CHUNK]->(c:Chunk)-[:MENTIONS]->(e:Entity {name: $name})
+		OPTIONAL MATCH (c)-[:MENTIONS]->(other:Entity)
+		WITH d, c, count(other) AS entities
+		RETURN c.id, c.content, c.idx, c.start_offset, c.end_offset, d.id, d.source, entities
+		ORDER BY entities DESC, d.source, c.idx`, map[string]any{"name": detail.Name})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mentioning chunks: %w", err)
+	}
+	detail.MentionCount = len(rows)
+	for i, row := range rows {
+		if i == chunkLimit {
+			break
+		}
+		detail.Chunks = append(detail.Chunks, ScoredChunk{
+			Chunk: Chunk{
+				ID:          asString(row[0]),
+				Content:     asString(row[1]),
+				Index:       int(asInt64(row[2])),
+				StartOffset: int(asInt64(row[3])),
+				EndOffset:   int(asInt64(row[4])),
+				DocumentID:  asString(row[5]),
+			},
+			Source: asString(row[6]),
+		})
+	}
+	return detail, nil
+}
+
+// MergeEntities folds each duplicate into keep: mentions and relationships are moved,
+// the duplicate's name and aliases become aliases of keep, and the duplicate is deleted.
+func (s *KuzuStore) MergeEntities(ctx context.Context, keep string, dupes []string) (err error) {
+	if detail, err := s.GetEntity(ctx, keep, 1); err != nil {
+		return err
+	} else if detail == nil || detail.Name != keep {
+		return fmt.Errorf("entity %q not found", keep)
+	}
+
+	if err := s.query("BEGIN TRANSACTION"); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			s.query("ROLLBACK")
+		}
+	}()
+
+	statements := []string{
+		"MATCH (c:Chunk)-[:MENTIONS]->(d:Entity {name: $dupe}), (k:Entity {name: $keep}) MERGE (c)-[:MENTIONS]->(k)",
+		"MATCH (d:Entity {name: $dupe})-[r:RELATED]->(o:Entity), (k:Entity {name: $keep}) WHERE o.name <> $keep MERGE (k)-[:RELATED {predicate: r.predicate}]->(o)",
+		"MATCH (o:Entity)-[r:RELATED]->(d:Entity {name: $dupe}), (k:Entity {name: $keep}) WHERE o.name <> $keep MERGE (o)-[:RELATED {predicate: r.predicate}]->(k)",
+		"MATCH (d:Entity {name: $dupe}), (k:Entity {name: $keep}) SET k.aliases = list_distinct(list_concat(k.aliases, list_concat([d.name], d.aliases)))",
+		"MATCH (d:Entity {name: $dupe}) DETACH DELETE d",
+	}
+	for _, dupe := range dupes {
+		if dupe == keep {
+			continue
+		}
+		rows, err := s.rows("MATCH (d:Entity {name: $dupe}) RETURN d.name", map[string]any{"dupe": dupe})
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return fmt.Errorf("entity %q not found", dupe)
+		}
+		for _, stmt := range statements {
+			// Kuzu rejects parameters that the statement does not reference.
+			params := map[string]any{"dupe": dupe}
+			if strings.Contains(stmt, "$keep") {
+				params["keep"] = keep
+			}
+			if err := s.execute(stmt, params); err != nil {
+				return fmt.Errorf("failed to merge %q into %q: %w", dupe, keep, err)
+			}
+		}
+	}
+	return s.query("COMMIT")
+}
+
+func asStrings(v any) []string {
+	values, _ := v.([]any)
+	out := make([]string, 0, len(values))
+	for _, value := range values {
+		out = append(out, asString(value))
+	}
+	return out
+}
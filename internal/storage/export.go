@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WriteDOT writes the graph in Graphviz DOT format. Nodes are labeled with their
+// type and edges with their predicate.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, "digraph memory {")
+	fmt.Fprintln(out, "  node [shape=box];")
+	for _, node := range g.Nodes {
+		label := node.Name
+		if node.Type != "" {
+			label += "\n" + node.Type
+		}
+		fmt.Fprintf(out, "  %s [label=%s];\n", strconv.Quote(node.Name), strconv.Quote(label))
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(out, "  %s -> %s [label=%s];\n", strconv.Quote(edge.Subject), strconv.Quote(edge.Object), strconv.Quote(edge.Predicate))
+	}
+	fmt.Fprintln(out, "}")
+	return out.Flush()
+}
+
+// WriteGraphML writes the graph in GraphML format with name and type attributes on
+// nodes and a predicate attribute on edges.
+func (g *Graph) WriteGraphML(w io.Writer) error {
+	ids := make(map[string]string, len(g.Nodes))
+	for i, node := range g.Nodes {
+		ids[node.Name] = fmt.Sprintf("n%d", i)
+	}
+
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, `<?xml version="1.0" encoding="UTF-8"?>`)
+	fmt.Fprintln(out, `<graphml xmlns="http://graphml.graphdrawing.org/xmlns">`)
+	fmt.Fprintln(out, `  <key id="name" for="node" attr.name="name" attr.type="string"/>`)
+	fmt.Fprintln(out, `  <key id="type" for="node" attr.name="type" attr.type="string"/>`)
+	fmt.Fprintln(out, `  <key id="predicate" for="edge" attr.name="predicate" attr.type="string"/>`)
+	fmt.Fprintln(out, `  <graph id="memory" edgedefault="directed">`)
+	for _, node := range g.Nodes {
+		fmt.Fprintf(out, "    <node id=%q>\n", ids[node.Name])
+		fmt.Fprintf(out, "      <data key=\"name\">%s</data>\n", escapeXML(node.Name))
+		fmt.Fprintf(out, "      <data key=\"type\">%s</data>\n", escapeXML(node.Type))
+		fmt.Fprintln(out, "    </node>")
+	}
+	for i, edge := range g.Edges {
+		fmt.Fprintf(out, "    <edge id=\"e%d\" source=%q target=%q>\n", i, ids[edge.Subject], ids[edge.Object])
+		fmt.Fprintf(out, "      <data key=\"predicate\">%s</data>\n", escapeXML(edge.Predicate))
+		fmt.Fprintln(out, "    </edge>")
+	}
+	fmt.Fprintln(out, "  </graph>")
+	fmt.Fprintln(out, "</graphml>")
+	return out.Flush()
+}
+
+func escapeXML(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestGraphWriteDOT_SortedAndEscaped(t *testing.T) {
+	graph := &Graph{
+		Nodes: []Entity{{Name: "KuzuDB", Type: "PRODUCT"}, {Name: `Kuzu "Inc"`, Type: "ORG"}},
+		Edges: []Relationship{{Subject: `Kuzu "Inc"`, Predicate: "builds", Object: "KuzuDB"}},
+	}
+	graph.sort()
+
+	var buf bytes.Buffer
+	if err := graph.WriteDOT(&buf); err != nil {
+		t.Fatalf("WriteDOT failed: %v", err)
+	}
+
+	expected := `digraph memory {
+  node [shape=box];
+  "Kuzu \"Inc\"" [label="Kuzu \"Inc\"\nORG"];
+  "KuzuDB" [label="KuzuDB\nPRODUCT"];
+  "Kuzu \"Inc\"" -> "KuzuDB" [label="builds"];
+}
+`
+	if buf.String() != expected {
+		t.Errorf("Expected DOT:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestGraphWriteGraphML_EscapesText(t *testing.T) {
+	graph := &Graph{
+		Nodes: []Entity{{Name: "A&B"}, {Name: "C"}},
+		Edges: []Relationship{{Subject: "A&B", Predicate: "<owns>", Object: "C"}},
+	}
+
+	var buf bytes.Buffer
+	if err := graph.WriteGraphML(&buf); err != nil {
+		t.Fatalf("WriteGraphML failed: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`<data key="name">A&amp;B</data>`,
+		`<edge id="e0" source="n0" target="n1">`,
+		`<data key="predicate">&lt;owns&gt;</data>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected GraphML to contain %s, got:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ChunkFilter restricts searches to chunks of matching documents. Every set field
+// must match; zero values match everything.
+type ChunkFilter struct {
+	Collection   string
+	DocumentID   string
+	SourcePrefix string
+	// AnyTags matches documents with at least one of the tags, AllTags those with
+	// every one of them.
+	AnyTags []string
+	AllTags []string
+	// IngestedAfter and IngestedBefore bound when the document was last ingested,
+	// inclusively.
+	IngestedAfter  time.Time
+	IngestedBefore time.Time
+	// MinScore drops similarity search hits scoring below it when non-zero.
+	MinScore float64
+}
+
+// conditions returns the filter as Cypher conditions on the document d, adding
+// their parameters to params. It returns "" when the filter matches everything.
+func (f ChunkFilter) conditions(params map[string]any) string {
+	var conditions []string
+	add := func(condition, name string, value any) {
+		conditions = append(conditions, condition)
+		params[name] = value
+	}
+	if f.Collection != "" {
+		add("d.collection = $f_collection", "f_collection", f.Collection)
+	}
+	if f.DocumentID != "" {
+		add("d.id = $f_document", "f_document", f.DocumentID)
+	}
+	if f.SourcePrefix != "" {
+		add("starts_with(d.source, $f_prefix)", "f_prefix", f.SourcePrefix)
+	}
+	// One parameter per tag: Kuzu cannot bind an empty list, and list_contains on a
+	// NULL tags column is simply false.
+	if len(f.AnyTags) > 0 {
+		matches := make([]string, len(f.AnyTags))
+		for i, tag := range f.AnyTags {
+			name := fmt.Sprintf("f_any%d", i)
+			matches[i] = "list_contains(d.tags, $" + name + ")"
+			params[name] = tag
+		}
+		conditions = append(conditions, "("+strings.Join(matches, " OR ")+")")
+	}
+	for i, tag := range f.AllTags {
+		add(fmt.Sprintf("list_contains(d.tags, $f_all%d)", i), fmt.Sprintf("f_all%d", i), tag)
+	}
+	if !f.IngestedAfter.IsZero() {
+		add("d.ingested_at >= $f_after", "f_after", f.IngestedAfter)
+	}
+	if !f.IngestedBefore.IsZero() {
+		add("d.ingested_at <= $f_before", "f_before", f.IngestedBefore)
+	}
+	return strings.Join(conditions, " AND ")
+}
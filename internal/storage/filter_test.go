@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestSimilaritySearch_Filters(t *testing.T) {
+	store, err := Open(t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	// Each document's chunk is less similar to the query than the one before.
+	vector := func(tilt float32) []float32 {
+		v := make([]float32, EmbeddingDimensions)
+		v[0], v[1] = 1, tilt
+		return v
+	}
+	docs := []Document{
+		{ID: "a", Source: "notes/a.md", Collection: "research", IngestedAt: now.Add(-72 * time.Hour), Tags: []string{"kuzu", "db"}},
+		{ID: "b", Source: "notes/b.md", Collection: "research", IngestedAt: now.Add(-24 * time.Hour), Tags: []string{"kuzu"}},
+		{ID: "c", Source: "web/c.html", Collection: "web", IngestedAt: now},
+	}
+	for i, doc := range docs {
+		chunks := []Chunk{{ID: doc.ID + "-0", Content: "text", Embedding: vector(float32(i))}}
+		if err := store.SaveDocument(ctx, doc, chunks); err != nil {
+			t.Fatalf("Failed to save document %s: %v", doc.ID, err)
+		}
+	}
+
+	cases := []struct {
+		name   string
+		filter ChunkFilter
+		k      int
+		want   []string
+	}{
+		{"none", ChunkFilter{}, 5, []string{"a-0", "b-0", "c-0"}},
+		{"collection", ChunkFilter{Collection: "web"}, 5, []string{"c-0"}},
+		{"document", ChunkFilter{DocumentID: "b"}, 5, []string{"b-0"}},
+		{"source prefix", ChunkFilter{SourcePrefix: "notes/"}, 5, []string{"a-0", "b-0"}},
+		{"any tags", ChunkFilter{AnyTags: []string{"db", "missing"}}, 5, []string{"a-0"}},
+		{"all tags", ChunkFilter{AllTags: []string{"kuzu", "db"}}, 5, []string{"a-0"}},
+		{"ingested range", ChunkFilter{IngestedAfter: now.Add(-48 * time.Hour), IngestedBefore: now.Add(-time.Hour)}, 5, []string{"b-0"}},
+		{"min score", ChunkFilter{MinScore: 0.6}, 5, []string{"a-0", "b-0"}},
+		// The filter applies before the limit, so the best match outside it does not
+		// use up the only slot.
+		{"before limit", ChunkFilter{Collection: "web"}, 1, []string{"c-0"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			hits, err := store.SimilaritySearch(ctx, vector(0), tc.k, tc.filter)
+			if err != nil {
+				t.Fatalf("SimilaritySearch failed: %v", err)
+			}
+			var got []string
+			for _, hit := range hits {
+				got = append(got, hit.ID)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Expected %v, got %v", tc.want, got)
+			}
+
+			// Keyword search shares the same conditions.
+			keyword, err := store.KeywordSearch(ctx, "text", tc.k, tc.filter)
+			if err != nil {
+				t.Fatalf("KeywordSearch failed: %v", err)
+			}
+			got = got[:0]
+			for _, hit := range keyword {
+				got = append(got, hit.ID)
+			}
+			sort.Strings(got)
+			if tc.filter.MinScore == 0 && !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Expected keyword hits %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
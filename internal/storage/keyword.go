@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// BM25 parameters used by KeywordSearch.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// stopwords are ignored by KeywordSearch; they match nearly every chunk.
+var stopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "by": true,
+	"do": true, "does": true, "for": true, "from": true, "how": true, "in": true, "is": true, "it": true,
+	"of": true, "on": true, "or": true, "that": true, "the": true, "this": true, "to": true, "was": true,
+	"what": true, "when": true, "where": true, "which": true, "who": true, "why": true, "with": true,
+}
+
+// KeywordSearch returns up to k chunks matching filter, ranked by BM25 over the words
+// of query, best first. Chunks sharing no word with the query are not returned.
+// filter.MinScore does not apply to BM25 scores.
+func (s *KuzuStore) KeywordSearch(ctx context.Context, query string, k int, filter ChunkFilter) ([]ScoredChunk, error) {
+	terms := distinct(tokenize(query))
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	rows, err := s.rows("MATCH (c:Chunk) RETURN count(c), avg(size(c.content))", nil)
+	if err != nil {
+		return nil, fmt.Errorf("keyword search failed: %w", err)
+	}
+	total, avgLength := float64(asInt64(rows[0][0])), asFloat64(rows[0][1])
+	if total == 0 {
+		return nil, nil
+	}
+
+	// Narrow the candidates with a substring match, then score whole words in Go.
+	conditions := make([]string, len(terms))
+	params := make(map[string]any, len(terms))
+	for i, term := range terms {
+		conditions[i] = fmt.Sprintf("lower(c.content) CONTAINS $t%d", i)
+		params[fmt.Sprintf("t%d", i)] = term
+	}
+	where := "(" + strings.Join(conditions, " OR ") + ")"
+	if conditions := filter.conditions(params); conditions != "" {
+		where += " AND " + conditions
+	}
+	rows, err = s.rows(`MATCH (d:Document)-[:HAS_CHUNK]->(c:Chunk)
+		WHERE `+where+`
+		RETURN c.id, c.content, c.idx, c.start_offset, c.end_offset, d.id, d.source, d.collection, d.ingested_at, c.page`, params)
+	if err != nil {
+		return nil, fmt.Errorf("keyword search failed: %w", err)
+	}
+
+	type candidate struct {
+		chunk ScoredChunk
+		tf    map[string]int
+	}
+	candidates := make([]candidate, 0, len(rows))
+	df := make(map[string]int, len(terms))
+	for _, row := range rows {
+		c := candidate{
+			chunk: ScoredChunk{
+				Chunk: Chunk{
+					ID:          asString(row[0]),
+					Content:     asString(row[1]),
+					Index:       int(asInt64(row[2])),
+					StartOffset: int(asInt64(row[3])),
+					EndOffset:   int(asInt64(row[4])),
+					Page:        int(asInt64(row[9])),
+					DocumentID:  asString(row[5]),
+				},
+				Source:     asString(row[6]),
+				Collection: asString(row[7]),
+				IngestedAt: asTime(row[8]),
+			},
+			tf: make(map[string]int, len(terms)),
+		}
+		for _, word := range tokenize(c.chunk.Content) {
+			c.tf[word]++
+		}
+		for _, term := range terms {
+			if c.tf[term] > 0 {
+				df[term]++
+			}
+		}
+		candidates = append(candidates, c)
+	}
+
+	hits := make([]ScoredChunk, 0, len(candidates))
+	for _, c := range candidates {
+		length := float64(len(c.chunk.Content))
+		for _, term := range terms {
+			tf := float64(c.tf[term])
+			if tf == 0 {
+				continue
+			}
+			idf := math.Log(1 + (total-float64(df[term])+0.5)/(float64(df[term])+0.5))
+			c.chunk.Score += idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*length/avgLength))
+		}
+		if c.chunk.Score > 0 {
+			hits = append(hits, c.chunk)
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	if len(hits) > k {
+		hits = hits[:k]
+	}
+	return hits, nil
+}
+
+// tokenize splits text into lowercase words, dropping stopwords and single characters.
+func tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	out := words[:0]
+	for _, word := range words {
+		if len(word) > 1 && !stopwords[word] {
+			out = append(out, word)
+		}
+	}
+	return out
+}
+
+func distinct(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	out := make([]string, 0, len(values))
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			out = append(out, value)
+		}
+	}
+	return out
+}
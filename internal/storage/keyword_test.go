@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	got := tokenize("What is KuzuDB's query-language? It's Cypher, v2!")
+	expected := []string{"kuzudb", "query", "language", "cypher", "v2"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestKeywordSearch_RanksWholeWords(t *testing.T) {
+	store, err := Open(t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	chunks := []Chunk{
+		{ID: "doc-0", Content: "Kuzu is an embedded graph database."},
+		{ID: "doc-1", Content: "The graph database speaks Cypher. Cypher queries match graph patterns."},
+		{ID: "doc-2", Content: "Paragraphs about photography."},
+	}
+	for i := range chunks {
+		chunks[i].Embedding = make([]float32, EmbeddingDimensions)
+	}
+	if err := store.SaveDocument(context.Background(), Document{ID: "doc", Source: "doc.md", Collection: "notes"}, chunks); err != nil {
+		t.Fatalf("Failed to save document: %v", err)
+	}
+
+	hits, err := store.KeywordSearch(context.Background(), "Which graph speaks cypher?", 5, ChunkFilter{})
+	if err != nil {
+		t.Fatalf("KeywordSearch failed: %v", err)
+	}
+	if len(hits) != 2 || hits[0].ID != "doc-1" || hits[1].ID != "doc-0" {
+		t.Fatalf("Expected doc-1 then doc-0, got %+v", hits)
+	}
+	if hits[0].Source != "doc.md" || hits[0].Collection != "notes" || hits[0].Score <= hits[1].Score {
+		t.Errorf("Expected scored hits with document info, got %+v", hits)
+	}
+
+	if hits, err := store.KeywordSearch(context.Background(), "the of and", 5, ChunkFilter{}); err != nil || len(hits) != 0 {
+		t.Errorf("Expected no hits for stopwords only, got %v (err %v)", hits, err)
+	}
+}
@@ -0,0 +1,617 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kuzudb/go-kuzu"
+)
+
+// DatabaseFile is the name of the Kuzu database file inside a memory graph directory.
+const DatabaseFile = "amg.db"
+
+// EmbeddingDimensions is the size of the Chunk embedding column of memory graphs
+// created without WithEmbeddingDimensions.
+const EmbeddingDimensions = 768
+
+// SchemaVersion is the version of the schema created by this build. It is recorded in
+// the Meta table so tools can detect databases created by incompatible versions.
+const SchemaVersion = 7
+
+// DefaultCollection is the collection documents are stored in when none is given.
+const DefaultCollection = "default"
+
+// ErrLocked is returned by Open when another process holds the database's write lock.
+var ErrLocked = errors.New("memory graph is locked by another amg process")
+
+// ErrReadOnly is returned by destructive operations on a store opened read-only.
+var ErrReadOnly = errors.New("memory graph is opened read-only")
+
+// Document is a single ingested source, such as a file.
+type Document struct {
+	ID         string
+	Source     string
+	Collection string
+	IngestedAt time.Time
+	// ContentHash identifies the ingested content so unchanged sources can be skipped.
+	ContentHash string
+	// Tags are free-form labels given at ingest time, used to select documents to prune.
+	Tags []string
+}
+
+// Chunk is a piece of a document together with its embedding.
+// StartOffset and EndOffset are byte offsets into the original source.
+type Chunk struct {
+	ID          string
+	DocumentID  string
+	Content     string
+	Index       int
+	StartOffset int
+	EndOffset   int
+	// Page is the page of the PDF the chunk was read from, counted from 1, or 0
+	// for documents without pages.
+	Page      int
+	Embedding []float32
+	// Int8Embedding stands in for Embedding in memory graphs storing int8
+	// vectors: value n is Int8Embedding[n]*EmbeddingScale + EmbeddingOffset.
+	Int8Embedding   []int8
+	EmbeddingScale  float32
+	EmbeddingOffset float32
+
+	// Entities and Relationships extracted from the chunk, persisted as MENTIONS and RELATED edges.
+	Entities      []Entity
+	Relationships []Relationship
+}
+
+// ScoredChunk is a chunk returned from a search along with its source document.
+type ScoredChunk struct {
+	Chunk
+	Source     string
+	Collection string
+	IngestedAt time.Time
+	Score      float64
+}
+
+// KuzuStore persists documents and chunks in a Kuzu graph database.
+type KuzuStore struct {
+	db       *kuzu.Database
+	conn     *kuzu.Connection
+	readOnly bool
+	// dimensions is the size of the Chunk embedding column.
+	dimensions int
+}
+
+// OpenOption configures Open.
+type OpenOption func(*KuzuStore)
+
+// WithEmbeddingDimensions sizes the Chunk embedding column of a new memory graph,
+// for the vectors of the provider about to fill it. An existing memory graph keeps
+// the size it was created with.
+func WithEmbeddingDimensions(dimensions int) OpenOption {
+	return func(s *KuzuStore) {
+		if dimensions > 0 {
+			s.dimensions = dimensions
+		}
+	}
+}
+
+// Open opens (or creates) the memory graph database inside dir.
+// When readOnly is set the database must already exist.
+func Open(dir string, readOnly bool, opts ...OpenOption) (*KuzuStore, error) {
+	path := filepath.Join(dir, DatabaseFile)
+	if readOnly {
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("memory graph database not found at %s: %w", path, err)
+		}
+	} else if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create memory graph directory %s: %w", dir, err)
+	}
+
+	config := kuzu.DefaultSystemConfig()
+	config.ReadOnly = readOnly
+	db, err := kuzu.OpenDatabase(path, config)
+	if err != nil {
+		if pid := lockHolder(path); pid != 0 {
+			return nil, fmt.Errorf("%w: process %d has %s open for writing", ErrLocked, pid, path)
+		}
+		return nil, fmt.Errorf("failed to open database %s: %w", path, err)
+	}
+	conn, err := kuzu.OpenConnection(db)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open connection: %w", err)
+	}
+
+	s := &KuzuStore{db: db, conn: conn, readOnly: readOnly, dimensions: EmbeddingDimensions}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if dims, err := s.StoredEmbeddingDimensions(context.Background()); err == nil {
+		s.dimensions = dims
+	}
+	if !readOnly {
+		if err := s.ensureSchema(); err != nil {
+			s.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Close releases the connection and database handles.
+func (s *KuzuStore) Close() {
+	s.conn.Close()
+	s.db.Close()
+}
+
+func (s *KuzuStore) ensureSchema() error {
+	statements := []string{
+		"CREATE NODE TABLE IF NOT EXISTS Document(id STRING, source STRING, collection STRING, ingested_at TIMESTAMP, PRIMARY KEY (id))",
+		fmt.Sprintf("CREATE NODE TABLE IF NOT EXISTS Chunk(id STRING, content STRING, idx INT64, start_offset INT64, end_offset INT64, embedding FLOAT[%d], PRIMARY KEY (id))", s.dimensions),
+		"CREATE REL TABLE IF NOT EXISTS HAS_CHUNK(FROM Document TO Chunk)",
+		"CREATE REL TABLE IF NOT EXISTS NEXT_CHUNK(FROM Chunk TO Chunk)",
+		"CREATE NODE TABLE IF NOT EXISTS Entity(name STRING, type STRING, aliases STRING[], PRIMARY KEY (name))",
+		"CREATE REL TABLE IF NOT EXISTS MENTIONS(FROM Chunk TO Entity)",
+		"CREATE REL TABLE IF NOT EXISTS RELATED(FROM Entity TO Entity, predicate STRING)",
+		"CREATE NODE TABLE IF NOT EXISTS Meta(key STRING, value STRING, PRIMARY KEY (key))",
+		// v2: content hashes for change detection.
+		"ALTER TABLE Document ADD IF NOT EXISTS content_hash STRING DEFAULT ''",
+		// v3: staging column for amg reindex.
+		fmt.Sprintf("ALTER TABLE Chunk ADD IF NOT EXISTS pending_embedding FLOAT[%d]", s.dimensions),
+		// v4: document tags for amg prune.
+		"ALTER TABLE Document ADD IF NOT EXISTS tags STRING[]",
+		// v5: int8 vectors, see Chunk.Int8Embedding.
+		fmt.Sprintf("ALTER TABLE Chunk ADD IF NOT EXISTS embedding_int8 INT8[%d]", s.dimensions),
+		"ALTER TABLE Chunk ADD IF NOT EXISTS embedding_scale FLOAT",
+		"ALTER TABLE Chunk ADD IF NOT EXISTS embedding_offset FLOAT",
+		// v6: staging int8 vectors for amg reindex.
+		fmt.Sprintf("ALTER TABLE Chunk ADD IF NOT EXISTS pending_embedding_int8 INT8[%d]", s.dimensions),
+		"ALTER TABLE Chunk ADD IF NOT EXISTS pending_embedding_scale FLOAT",
+		"ALTER TABLE Chunk ADD IF NOT EXISTS pending_embedding_offset FLOAT",
+		// v7: the pages of PDF chunks.
+		"ALTER TABLE Chunk ADD IF NOT EXISTS page INT64 DEFAULT 0",
+	}
+	for _, stmt := range statements {
+		if err := s.query(stmt); err != nil {
+			return fmt.Errorf("failed to create schema: %w", err)
+		}
+	}
+	if err := s.execute(`MERGE (m:Meta {key: 'schema_version'})
+		ON CREATE SET m.value = $version
+		ON MATCH SET m.value = CASE WHEN CAST(m.value AS INT64) < $number THEN $version ELSE m.value END`, map[string]any{
+		"version": strconv.Itoa(SchemaVersion),
+		"number":  int64(SchemaVersion),
+	}); err != nil {
+		return fmt.Errorf("failed to record schema version: %w", err)
+	}
+	return nil
+}
+
+// StoredSchemaVersion returns the schema version recorded in the database,
+// or 0 when the database predates schema versioning.
+func (s *KuzuStore) StoredSchemaVersion(ctx context.Context) (int, error) {
+	rows, err := s.rows("MATCH (m:Meta {key: 'schema_version'}) RETURN m.value", nil)
+	if err != nil || len(rows) == 0 {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(asString(rows[0][0]))
+	if err != nil {
+		return 0, fmt.Errorf("invalid schema version %q: %w", asString(rows[0][0]), err)
+	}
+	return version, nil
+}
+
+// StoredEmbeddingDimensions returns the declared size of the Chunk embedding
+// column. It fails when the memory graph has no Chunk table yet.
+func (s *KuzuStore) StoredEmbeddingDimensions(ctx context.Context) (int, error) {
+	return s.columnDimensions("embedding")
+}
+
+// columnDimensions returns the declared size of the Chunk array column name, such
+// as FLOAT[768].
+func (s *KuzuStore) columnDimensions(name string) (int, error) {
+	rows, err := s.rows("CALL table_info('Chunk') RETURN name, type", nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read Chunk table info: %w", err)
+	}
+	for _, row := range rows {
+		if asString(row[0]) != name {
+			continue
+		}
+		var dims int
+		_, size, ok := strings.Cut(asString(row[1]), "[")
+		if _, err := fmt.Sscanf(size, "%d]", &dims); !ok || err != nil {
+			return 0, fmt.Errorf("unexpected %s column type %q", name, asString(row[1]))
+		}
+		return dims, nil
+	}
+	return 0, fmt.Errorf("chunk table has no %s column", name)
+}
+
+// CheckEngine verifies that the Kuzu library can be loaded by opening an in-memory database.
+func CheckEngine() error {
+	db, err := kuzu.OpenInMemoryDatabase(kuzu.DefaultSystemConfig())
+	if err != nil {
+		return err
+	}
+	db.Close()
+	return nil
+}
+
+// SaveDocument stores a document and its chunks, replacing any chunks
+// previously stored for the same document ID.
+func (s *KuzuStore) SaveDocument(ctx context.Context, doc Document, chunks []Chunk) (err error) {
+	if err := s.query("BEGIN TRANSACTION"); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := s.query("ROLLBACK"); rbErr != nil {
+				slog.ErrorContext(ctx, "failed to roll back transaction", "error", rbErr)
+			}
+		}
+	}()
+
+	if err := s.execute("MATCH (d:Document {id: $id})-[:HAS_CHUNK]->(c:Chunk) DETACH DELETE c", map[string]any{"id": doc.ID}); err != nil {
+		return fmt.Errorf("failed to delete previous chunks: %w", err)
+	}
+	params := map[string]any{
+		"id":           doc.ID,
+		"source":       doc.Source,
+		"collection":   doc.Collection,
+		"ingested_at":  doc.IngestedAt,
+		"content_hash": doc.ContentHash,
+	}
+	// Kuzu cannot bind an empty slice, so untagged documents get a literal empty list.
+	tags := "[]"
+	if len(doc.Tags) > 0 {
+		tags, params["tags"] = "$tags", doc.Tags
+	}
+	if err := s.execute(`MERGE (d:Document {id: $id})
+		SET d.source = $source, d.collection = $collection, d.ingested_at = $ingested_at, d.content_hash = $content_hash, d.tags = `+tags, params); err != nil {
+		return fmt.Errorf("failed to save document: %w", err)
+	}
+
+	for i, chunk := range chunks {
+		params := map[string]any{
+			"doc_id":       doc.ID,
+			"id":           chunk.ID,
+			"content":      chunk.Content,
+			"idx":          int64(chunk.Index),
+			"start_offset": int64(chunk.StartOffset),
+			"end_offset":   int64(chunk.EndOffset),
+			"page":         int64(chunk.Page),
+		}
+		if n := max(len(chunk.Embedding), len(chunk.Int8Embedding)); n > 0 && n != s.dimensions {
+			return fmt.Errorf("chunk %s has %d dimensions but the memory graph stores %d", chunk.ID, n, s.dimensions)
+		}
+		// A chunk whose text the embedding provider refused is stored without a
+		// vector, which reindexing with only missing vectors fills in.
+		vector := ""
+		switch {
+		case chunk.Int8Embedding != nil:
+			vector = ", embedding_int8: $embedding_int8, embedding_scale: $embedding_scale, embedding_offset: $embedding_offset"
+			params["embedding_int8"], params["embedding_scale"], params["embedding_offset"] = chunk.Int8Embedding, chunk.EmbeddingScale, chunk.EmbeddingOffset
+		case chunk.Embedding != nil:
+			vector = ", embedding: $embedding"
+			params["embedding"] = chunk.Embedding
+		}
+		if err := s.execute(`MATCH (d:Document {id: $doc_id})
+			CREATE (d)-[:HAS_CHUNK]->(:Chunk {id: $id, content: $content, idx: $idx, start_offset: $start_offset, end_offset: $end_offset, page: $page`+vector+`})`, params); err != nil {
+			return fmt.Errorf("failed to save chunk %s: %w", chunk.ID, err)
+		}
+		if i > 0 {
+			if err := s.execute("MATCH (a:Chunk {id: $prev}), (b:Chunk {id: $next}) CREATE (a)-[:NEXT_CHUNK]->(b)", map[string]any{
+				"prev": chunks[i-1].ID,
+				"next": chunk.ID,
+			}); err != nil {
+				return fmt.Errorf("failed to link chunk %s: %w", chunk.ID, err)
+			}
+		}
+		if err := s.saveEntities(chunk); err != nil {
+			return fmt.Errorf("failed to save entities for chunk %s: %w", chunk.ID, err)
+		}
+	}
+
+	return s.query("COMMIT")
+}
+
+// ContentHash returns the content hash recorded for a document, or "" when the
+// document is not stored.
+func (s *KuzuStore) ContentHash(ctx context.Context, id string) (string, error) {
+	rows, err := s.rows("MATCH (d:Document {id: $id}) RETURN d.content_hash", map[string]any{"id": id})
+	if err != nil {
+		return "", fmt.Errorf("failed to read document %s: %w", id, err)
+	}
+	if len(rows) == 0 {
+		return "", nil
+	}
+	return asString(rows[0][0]), nil
+}
+
+// DeleteDocument removes a document and its chunks. Entities stay in the graph
+// even when no chunk mentions them any more.
+func (s *KuzuStore) DeleteDocument(ctx context.Context, id string) error {
+	if err := s.execute("MATCH (d:Document {id: $id})-[:HAS_CHUNK]->(c:Chunk) DETACH DELETE c", map[string]any{"id": id}); err != nil {
+		return fmt.Errorf("failed to delete chunks of document %s: %w", id, err)
+	}
+	if err := s.execute("MATCH (d:Document {id: $id}) DETACH DELETE d", map[string]any{"id": id}); err != nil {
+		return fmt.Errorf("failed to delete document %s: %w", id, err)
+	}
+	return nil
+}
+
+// storedEmbedding returns the vector of chunk c as a FLOAT array, whether it is
+// stored as floats or as int8 values, which it dequantizes.
+func (s *KuzuStore) storedEmbedding() string {
+	return fmt.Sprintf(`CASE WHEN c.embedding IS NOT NULL THEN c.embedding
+	ELSE CAST(list_transform(CAST(c.embedding_int8 AS INT8[]), x -> CAST(x AS FLOAT) * c.embedding_scale + c.embedding_offset) AS FLOAT[%d]) END`, s.dimensions)
+}
+
+// SimilaritySearch returns the k chunks matching filter whose embeddings are closest
+// to vector by cosine similarity. Int8 vectors are dequantized, so a memory graph
+// may hold vectors of either precision. There is no vector index: every stored
+// vector matching filter is scored.
+func (s *KuzuStore) SimilaritySearch(ctx context.Context, vector []float32, k int, filter ChunkFilter) ([]ScoredChunk, error) {
+	params := map[string]any{"vector": vector, "k": int64(k)}
+	// Chunks without a vector, such as those the embedding provider refused, have
+	// a NULL score that would sort ahead of every similarity.
+	where := "WHERE (c.embedding IS NOT NULL OR c.embedding_int8 IS NOT NULL)"
+	if conditions := filter.conditions(params); conditions != "" {
+		where += " AND " + conditions
+	}
+	minScore := ""
+	if filter.MinScore != 0 {
+		minScore, params["min_score"] = "WHERE score >= $min_score", filter.MinScore
+	}
+	rows, err := s.rows(`MATCH (d:Document)-[:HAS_CHUNK]->(c:Chunk) `+where+`
+		WITH d, c, array_cosine_similarity(`+s.storedEmbedding()+`, $vector) AS score `+minScore+`
+		RETURN c.id, c.content, c.idx, c.start_offset, c.end_offset, d.id, d.source, d.collection, d.ingested_at, score, c.page
+		ORDER BY score DESC LIMIT $k`, params)
+	if err != nil {
+		return nil, fmt.Errorf("similarity search failed: %w", err)
+	}
+
+	hits := make([]ScoredChunk, 0, len(rows))
+	for _, row := range rows {
+		hits = append(hits, ScoredChunk{
+			Chunk: Chunk{
+				ID:          asString(row[0]),
+				Content:     asString(row[1]),
+				Index:       int(asInt64(row[2])),
+				StartOffset: int(asInt64(row[3])),
+				EndOffset:   int(asInt64(row[4])),
+				Page:        int(asInt64(row[10])),
+				DocumentID:  asString(row[5]),
+			},
+			Source:     asString(row[6]),
+			Collection: asString(row[7]),
+			IngestedAt: asTime(row[8]),
+			Score:      asFloat64(row[9]),
+		})
+	}
+	return hits, nil
+}
+
+// ChunkEmbeddings returns the stored vectors of the given chunks by ID, int8
+// vectors dequantized. Chunks without a vector are omitted.
+func (s *KuzuStore) ChunkEmbeddings(ctx context.Context, ids []string) (map[string][]float32, error) {
+	vectors := make(map[string][]float32, len(ids))
+	if len(ids) == 0 {
+		return vectors, nil
+	}
+	rows, err := s.rows("MATCH (c:Chunk) WHERE list_contains($ids, c.id) AND (c.embedding IS NOT NULL OR c.embedding_int8 IS NOT NULL) RETURN c.id, "+s.storedEmbedding(),
+		map[string]any{"ids": ids})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chunk embeddings: %w", err)
+	}
+	for _, row := range rows {
+		vectors[asString(row[0])] = asFloat32s(row[1])
+	}
+	return vectors, nil
+}
+
+// Neighbours are the chunks before and after a chunk in its document, nil at the
+// document's ends. Their embeddings are not loaded.
+type Neighbours struct {
+	Previous *Chunk
+	Next     *Chunk
+}
+
+// AdjacentChunks returns the neighbours of the given chunks along their NEXT_CHUNK
+// edges. Chunks that are alone in their document, or missing, are left out.
+func (s *KuzuStore) AdjacentChunks(ctx context.Context, ids []string) (map[string]Neighbours, error) {
+	neighbours := make(map[string]Neighbours, len(ids))
+	if len(ids) == 0 {
+		return neighbours, nil
+	}
+	const fields = "RETURN c.id, n.id, n.content, n.idx, n.start_offset, n.end_offset, n.page"
+	for _, direction := range []struct {
+		query    string
+		previous bool
+	}{
+		{"MATCH (n:Chunk)-[:NEXT_CHUNK]->(c:Chunk) WHERE list_contains($ids, c.id) " + fields, true},
+		{"MATCH (c:Chunk)-[:NEXT_CHUNK]->(n:Chunk) WHERE list_contains($ids, c.id) " + fields, false},
+	} {
+		rows, err := s.rows(direction.query, map[string]any{"ids": ids})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read adjacent chunks: %w", err)
+		}
+		for _, row := range rows {
+			id := asString(row[0])
+			chunk := &Chunk{
+				ID:          asString(row[1]),
+				Content:     asString(row[2]),
+				Index:       int(asInt64(row[3])),
+				StartOffset: int(asInt64(row[4])),
+				EndOffset:   int(asInt64(row[5])),
+				Page:        int(asInt64(row[6])),
+			}
+			adjacent := neighbours[id]
+			if direction.previous {
+				adjacent.Previous = chunk
+			} else {
+				adjacent.Next = chunk
+			}
+			neighbours[id] = adjacent
+		}
+	}
+	return neighbours, nil
+}
+
+// Collections returns the distinct collection names in the database, sorted by name.
+func (s *KuzuStore) Collections(ctx context.Context) ([]string, error) {
+	rows, err := s.rows("MATCH (d:Document) RETURN DISTINCT d.collection AS collection ORDER BY collection", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collections: %w", err)
+	}
+	collections := make([]string, 0, len(rows))
+	for _, row := range rows {
+		collections = append(collections, asString(row[0]))
+	}
+	return collections, nil
+}
+
+// Stats summarizes the contents of the memory graph.
+type Stats struct {
+	Documents     int
+	Chunks        int
+	Entities      int
+	Relationships int
+	// Collections maps each collection name to its document count.
+	Collections map[string]int
+}
+
+// Stats counts the documents, chunks, entities and relationships in the database.
+func (s *KuzuStore) Stats(ctx context.Context) (Stats, error) {
+	stats := Stats{Collections: map[string]int{}}
+	counts := []struct {
+		stmt  string
+		count *int
+	}{
+		{"MATCH (d:Document) RETURN count(d)", &stats.Documents},
+		{"MATCH (c:Chunk) RETURN count(c)", &stats.Chunks},
+		{"MATCH (e:Entity) RETURN count(e)", &stats.Entities},
+		{"MATCH (:Entity)-[r:RELATED]->(:Entity) RETURN count(r)", &stats.Relationships},
+	}
+	for _, c := range counts {
+		rows, err := s.rows(c.stmt, nil)
+		if err != nil {
+			return Stats{}, fmt.Errorf("failed to collect stats: %w", err)
+		}
+		*c.count = int(asInt64(rows[0][0]))
+	}
+
+	rows, err := s.rows("MATCH (d:Document) RETURN d.collection, count(d)", nil)
+	if err != nil {
+		return Stats{}, fmt.Errorf("failed to collect stats: %w", err)
+	}
+	for _, row := range rows {
+		stats.Collections[asString(row[0])] = int(asInt64(row[1]))
+	}
+	return stats, nil
+}
+
+// query runs a statement without parameters and discards its result.
+func (s *KuzuStore) query(stmt string) error {
+	result, err := s.conn.Query(stmt)
+	if err != nil {
+		return err
+	}
+	result.Close()
+	return nil
+}
+
+// execute runs a parameterized statement and discards its result.
+func (s *KuzuStore) execute(stmt string, params map[string]any) error {
+	prepared, err := s.conn.Prepare(stmt)
+	if err != nil {
+		return err
+	}
+	defer prepared.Close()
+
+	result, err := s.conn.Execute(prepared, params)
+	if err != nil {
+		return err
+	}
+	result.Close()
+	return nil
+}
+
+// rows runs a parameterized statement and collects every returned row.
+func (s *KuzuStore) rows(stmt string, params map[string]any) ([][]any, error) {
+	prepared, err := s.conn.Prepare(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer prepared.Close()
+
+	result, err := s.conn.Execute(prepared, params)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	var rows [][]any
+	for result.HasNext() {
+		tuple, err := result.Next()
+		if err != nil {
+			return nil, err
+		}
+		values, err := tuple.GetAsSlice()
+		tuple.Close()
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, values)
+	}
+	return rows, nil
+}
+
+func asString(v any) string {
+	s, _ := v.(string)
+	return s
+}
+
+func asInt64(v any) int64 {
+	switch n := v.(type) {
+	case int64:
+		return n
+	case int32:
+		return int64(n)
+	case int:
+		return int64(n)
+	}
+	return 0
+}
+
+func asFloat64(v any) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case float32:
+		return float64(n)
+	}
+	return 0
+}
+
+func asTime(v any) time.Time {
+	t, _ := v.(time.Time)
+	return t
+}
+
+func asFloat32s(v any) []float32 {
+	values, _ := v.([]any)
+	out := make([]float32, 0, len(values))
+	for _, value := range values {
+		out = append(out, float32(asFloat64(value)))
+	}
+	return out
+}
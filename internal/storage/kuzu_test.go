@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestChunkEmbeddings(t *testing.T) {
+	store, err := Open(t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	vector := make([]float32, EmbeddingDimensions)
+	vector[0], vector[1] = 0.5, -0.25
+	chunks := []Chunk{{ID: "doc-0", Content: "a", Embedding: vector}}
+	if err := store.SaveDocument(context.Background(), Document{ID: "doc", Source: "doc.md"}, chunks); err != nil {
+		t.Fatalf("Failed to save document: %v", err)
+	}
+
+	vectors, err := store.ChunkEmbeddings(context.Background(), []string{"doc-0", "missing"})
+	if err != nil {
+		t.Fatalf("ChunkEmbeddings failed: %v", err)
+	}
+	if len(vectors) != 1 {
+		t.Fatalf("Expected 1 vector, got %d", len(vectors))
+	}
+	got := vectors["doc-0"]
+	if len(got) != EmbeddingDimensions || got[0] != 0.5 || got[1] != -0.25 {
+		t.Errorf("Expected the stored vector, got %v", got[:2])
+	}
+}
+
+func TestOpen_WithEmbeddingDimensions(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Open(dir, false, WithEmbeddingDimensions(384))
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	dims, err := store.StoredEmbeddingDimensions(context.Background())
+	if err != nil || dims != 384 {
+		t.Fatalf("Expected a new embedding column of 384 dimensions, got %d (%v)", dims, err)
+	}
+	vector := make([]float32, 384)
+	vector[0] = 1
+	if err := store.SaveDocument(context.Background(), Document{ID: "doc", Source: "doc.md"}, []Chunk{{ID: "doc-0", Content: "a", Embedding: vector}}); err != nil {
+		t.Fatalf("Failed to save document: %v", err)
+	}
+	err = store.SaveDocument(context.Background(), Document{ID: "long", Source: "long.md"}, []Chunk{{ID: "long-0", Content: "b", Embedding: make([]float32, EmbeddingDimensions)}})
+	if err == nil || err.Error() != "chunk long-0 has 768 dimensions but the memory graph stores 384" {
+		t.Errorf("Expected vectors of another length refused, got %v", err)
+	}
+	hits, err := store.SimilaritySearch(context.Background(), vector, 1, ChunkFilter{})
+	if err != nil || len(hits) != 1 || hits[0].ID != "doc-0" {
+		t.Errorf("Expected the chunk found, got %v (%v)", hits, err)
+	}
+	store.Close()
+
+	// The column keeps its size once created.
+	store, err = Open(dir, false, WithEmbeddingDimensions(1024))
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+	if dims, err := store.StoredEmbeddingDimensions(context.Background()); err != nil || dims != 384 {
+		t.Errorf("Expected the existing 384 dimensions kept, got %d (%v)", dims, err)
+	}
+}
+
+func TestSimilaritySearch_MixedPrecision(t *testing.T) {
+	store, err := Open(t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	float := make([]float32, EmbeddingDimensions)
+	float[0] = 1
+	// Stands for 0.1 in every dimension but the second, which is 0.1 + 0.01*100.
+	quantized := make([]int8, EmbeddingDimensions)
+	quantized[1] = 100
+	chunks := []Chunk{{ID: "float-0", Content: "float", Embedding: float}}
+	if err := store.SaveDocument(context.Background(), Document{ID: "float", Source: "float.md"}, chunks); err != nil {
+		t.Fatalf("Failed to save document: %v", err)
+	}
+	chunks = []Chunk{{ID: "int8-0", Content: "int8", Int8Embedding: quantized, EmbeddingScale: 0.01, EmbeddingOffset: 0.1}}
+	if err := store.SaveDocument(context.Background(), Document{ID: "int8", Source: "int8.md"}, chunks); err != nil {
+		t.Fatalf("Failed to save document: %v", err)
+	}
+
+	query := make([]float32, EmbeddingDimensions)
+	query[1] = 1
+	hits, err := store.SimilaritySearch(context.Background(), query, 2, ChunkFilter{})
+	if err != nil {
+		t.Fatalf("SimilaritySearch failed: %v", err)
+	}
+	if len(hits) != 2 || hits[0].ID != "int8-0" || hits[1].ID != "float-0" {
+		t.Fatalf("Expected the int8 chunk then the float one, got %v", hits)
+	}
+	want := 1.1 / math.Sqrt(1.1*1.1+0.01*(EmbeddingDimensions-1))
+	if math.Abs(hits[0].Score-want) > 1e-4 {
+		t.Errorf("Expected the dequantized vector to score %v, got %v", want, hits[0].Score)
+	}
+
+	vectors, err := store.ChunkEmbeddings(context.Background(), []string{"int8-0"})
+	if err != nil {
+		t.Fatalf("ChunkEmbeddings failed: %v", err)
+	}
+	if got := vectors["int8-0"]; len(got) != EmbeddingDimensions || math.Abs(float64(got[0])-0.1) > 1e-6 || math.Abs(float64(got[1])-1.1) > 1e-6 {
+		t.Errorf("Expected the dequantized vector, got %v", got[:2])
+	}
+	missing, err := store.CountChunksToEmbed(context.Background(), false)
+	if err != nil {
+		t.Fatalf("CountChunksToEmbed failed: %v", err)
+	}
+	if missing != 0 {
+		t.Errorf("Expected the int8 chunk to count as embedded, got %d to embed", missing)
+	}
+}
+
+func TestSimilaritySearch_SkipsChunksWithoutVectors(t *testing.T) {
+	store, err := Open(t.TempDir(), false, WithEmbeddingDimensions(3))
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	// b stands for a chunk the embedding provider refused, saved without a vector.
+	chunks := []Chunk{
+		{ID: "a", Content: "a", Index: 0, Embedding: []float32{1, 0, 0}},
+		{ID: "b", Content: "b", Index: 1},
+		{ID: "c", Content: "c", Index: 2, Embedding: []float32{0, 1, 0}},
+	}
+	if err := store.SaveDocument(context.Background(), Document{ID: "doc", Source: "doc.md"}, chunks); err != nil {
+		t.Fatalf("Failed to save document: %v", err)
+	}
+
+	hits, err := store.SimilaritySearch(context.Background(), []float32{1, 0, 0}, 2, ChunkFilter{})
+	if err != nil {
+		t.Fatalf("SimilaritySearch failed: %v", err)
+	}
+	if len(hits) != 2 || hits[0].ID != "a" || hits[1].ID != "c" {
+		t.Fatalf("Expected a then c, got %v", hits)
+	}
+}
+
+func TestAdjacentChunks(t *testing.T) {
+	store, err := Open(t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	chunks := []Chunk{
+		{ID: "doc-0", Content: "first", Index: 0},
+		{ID: "doc-1", Content: "second", Index: 1, StartOffset: 6, EndOffset: 12},
+		{ID: "doc-2", Content: "third", Index: 2},
+	}
+	for i := range chunks {
+		chunks[i].Embedding = make([]float32, EmbeddingDimensions)
+	}
+	if err := store.SaveDocument(context.Background(), Document{ID: "doc", Source: "doc.md"}, chunks); err != nil {
+		t.Fatalf("Failed to save document: %v", err)
+	}
+	if err := store.SaveDocument(context.Background(), Document{ID: "solo", Source: "solo.md"}, []Chunk{{ID: "solo-0", Content: "alone", Embedding: make([]float32, EmbeddingDimensions)}}); err != nil {
+		t.Fatalf("Failed to save document: %v", err)
+	}
+
+	neighbours, err := store.AdjacentChunks(context.Background(), []string{"doc-0", "doc-1", "solo-0", "missing"})
+	if err != nil {
+		t.Fatalf("AdjacentChunks failed: %v", err)
+	}
+	if len(neighbours) != 2 {
+		t.Fatalf("Expected neighbours for 2 chunks, got %v", neighbours)
+	}
+	if first := neighbours["doc-0"]; first.Previous != nil || first.Next == nil || first.Next.ID != "doc-1" || first.Next.StartOffset != 6 {
+		t.Errorf("Expected doc-0 to be followed by doc-1 only, got %+v", first)
+	}
+	if middle := neighbours["doc-1"]; middle.Previous == nil || middle.Previous.Content != "first" || middle.Next == nil || middle.Next.Index != 2 {
+		t.Errorf("Expected doc-1 between doc-0 and doc-2, got %+v", middle)
+	}
+}
@@ -0,0 +1,6 @@
+package storage
+
+// lockHolder is not implemented on Windows; Kuzu's own error is reported instead.
+func lockHolder(path string) int {
+	return 0
+}
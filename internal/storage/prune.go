@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+)
+
+// PruneFilter selects documents for PruneCandidates. Every set field must match;
+// zero values match everything.
+type PruneFilter struct {
+	// IngestedBefore matches documents last ingested before this time.
+	IngestedBefore time.Time
+	Collection     string
+	Tag            string
+}
+
+// PruneCandidate is a document that a prune would remove.
+type PruneCandidate struct {
+	Document
+	// Size is the length in bytes of the ingested content, as far as its chunks cover it.
+	Size   int
+	Chunks int
+	// Entities is the number of entities mentioned only by this document.
+	Entities int
+}
+
+// PrunePlan lists the documents matching a PruneFilter and what removing them implies.
+type PrunePlan struct {
+	Candidates []PruneCandidate
+	// OrphanedEntities is the number of entities left unmentioned once every
+	// candidate is removed, including any that are unmentioned already.
+	OrphanedEntities int
+}
+
+// PruneResult counts what Prune removed.
+type PruneResult struct {
+	Documents int
+	Chunks    int
+	Entities  int
+}
+
+// PruneCandidates returns the documents matching filter, oldest first.
+func (s *KuzuStore) PruneCandidates(ctx context.Context, filter PruneFilter) (*PrunePlan, error) {
+	rows, err := s.rows(`MATCH (d:Document)
+		OPTIONAL MATCH (d)-[:HAS_CHUNK]->(c:Chunk)
+		RETURN d.id, d.source, d.collection, d.ingested_at, d.tags, count(c), max(c.end_offset)`, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list documents: %w", err)
+	}
+
+	plan := &PrunePlan{}
+	for _, row := range rows {
+		doc := Document{
+			ID:         asString(row[0]),
+			Source:     asString(row[1]),
+			Collection: asString(row[2]),
+			IngestedAt: asTime(row[3]),
+			Tags:       asStrings(row[4]),
+		}
+		if !filter.IngestedBefore.IsZero() && !doc.IngestedAt.Before(filter.IngestedBefore) {
+			continue
+		}
+		if filter.Collection != "" && doc.Collection != filter.Collection {
+			continue
+		}
+		if filter.Tag != "" && !slices.Contains(doc.Tags, filter.Tag) {
+			continue
+		}
+		plan.Candidates = append(plan.Candidates, PruneCandidate{
+			Document: doc,
+			Size:     max(int(asInt64(row[6])), 0),
+			Chunks:   int(asInt64(row[5])),
+		})
+	}
+	sort.Slice(plan.Candidates, func(i, j int) bool {
+		return plan.Candidates[i].IngestedAt.Before(plan.Candidates[j].IngestedAt)
+	})
+
+	mentions, err := s.entityDocuments()
+	if err != nil {
+		return nil, err
+	}
+	pruned := make(map[string]bool, len(plan.Candidates))
+	index := make(map[string]int, len(plan.Candidates))
+	for n, candidate := range plan.Candidates {
+		pruned[candidate.ID] = true
+		index[candidate.ID] = n
+	}
+	for _, documents := range mentions {
+		if len(documents) == 1 {
+			for id := range documents {
+				if n, ok := index[id]; ok {
+					plan.Candidates[n].Entities++
+				}
+			}
+		}
+		orphaned := true
+		for id := range documents {
+			if !pruned[id] {
+				orphaned = false
+				break
+			}
+		}
+		if orphaned {
+			plan.OrphanedEntities++
+		}
+	}
+	return plan, nil
+}
+
+// Prune removes the given documents with their chunks, then deletes every entity
+// that no remaining chunk mentions, all in one transaction.
+func (s *KuzuStore) Prune(ctx context.Context, ids []string) (result PruneResult, err error) {
+	if s.readOnly {
+		return PruneResult{}, fmt.Errorf("cannot prune: %w", ErrReadOnly)
+	}
+	if err := s.query("BEGIN TRANSACTION"); err != nil {
+		return PruneResult{}, err
+	}
+	defer func() {
+		if err != nil {
+			s.query("ROLLBACK")
+		}
+	}()
+
+	for _, id := range ids {
+		rows, err := s.rows("MATCH (d:Document {id: $id}) OPTIONAL MATCH (d)-[:HAS_CHUNK]->(c:Chunk) RETURN count(d), count(c)", map[string]any{"id": id})
+		if err != nil {
+			return PruneResult{}, fmt.Errorf("failed to read document %s: %w", id, err)
+		}
+		if len(rows) == 0 || asInt64(rows[0][0]) == 0 {
+			continue
+		}
+		if err := s.DeleteDocument(ctx, id); err != nil {
+			return PruneResult{}, err
+		}
+		result.Documents++
+		result.Chunks += int(asInt64(rows[0][1]))
+	}
+
+	mentions, err := s.entityDocuments()
+	if err != nil {
+		return PruneResult{}, err
+	}
+	for _, name := range orphans(mentions) {
+		if err := s.execute("MATCH (e:Entity {name: $name}) DETACH DELETE e", map[string]any{"name": name}); err != nil {
+			return PruneResult{}, fmt.Errorf("failed to delete entity %q: %w", name, err)
+		}
+		result.Entities++
+	}
+	return result, s.query("COMMIT")
+}
+
+// entityDocuments maps every entity name to the set of documents whose chunks
+// mention it; unmentioned entities map to an empty set. Mentions are resolved in
+// Go rather than with a WHERE filter, which Kuzu miscounts after chunk deletions.
+func (s *KuzuStore) entityDocuments() (map[string]map[string]bool, error) {
+	rows, err := s.rows("MATCH (e:Entity) RETURN e.name", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list entities: %w", err)
+	}
+	mentions := make(map[string]map[string]bool, len(rows))
+	for _, row := range rows {
+		mentions[asString(row[0])] = map[string]bool{}
+	}
+	rows, err = s.rows("MATCH (d:Document)-[:HAS_CHUNK]->(:Chunk)-[:MENTIONS]->(e:Entity) RETURN DISTINCT e.name, d.id", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mentions: %w", err)
+	}
+	for _, row := range rows {
+		if documents, ok := mentions[asString(row[0])]; ok {
+			documents[asString(row[1])] = true
+		}
+	}
+	return mentions, nil
+}
+
+func orphans(mentions map[string]map[string]bool) []string {
+	var names []string
+	for name, documents := range mentions {
+		if len(documents) == 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
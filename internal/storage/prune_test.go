@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPrune_RemovesDocumentsAndOrphanedEntities(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Open(dir, false)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	ctx := context.Background()
+	now := time.Now().UTC()
+	save := func(doc Document, entities ...string) {
+		t.Helper()
+		chunk := Chunk{ID: doc.ID + "-0", Content: "text", EndOffset: 40, Embedding: make([]float32, EmbeddingDimensions)}
+		for _, name := range entities {
+			chunk.Entities = append(chunk.Entities, Entity{Name: name, Type: "CONCEPT"})
+		}
+		if err := store.SaveDocument(ctx, doc, []Chunk{chunk}); err != nil {
+			t.Fatalf("Failed to save document %s: %v", doc.ID, err)
+		}
+	}
+	save(Document{ID: "old", Source: "old.md", Collection: "scratch", IngestedAt: now.Add(-200 * 24 * time.Hour), Tags: []string{"temp"}}, "Kuzu", "Scratchpad")
+	save(Document{ID: "new", Source: "new.md", Collection: "scratch", IngestedAt: now}, "Kuzu")
+	save(Document{ID: "kept", Source: "kept.md", Collection: "default", IngestedAt: now.Add(-300 * 24 * time.Hour)}, "Archive")
+
+	plan, err := store.PruneCandidates(ctx, PruneFilter{IngestedBefore: now.Add(-180 * 24 * time.Hour), Collection: "scratch", Tag: "temp"})
+	if err != nil {
+		t.Fatalf("PruneCandidates failed: %v", err)
+	}
+	if len(plan.Candidates) != 1 || plan.Candidates[0].ID != "old" {
+		t.Fatalf("Expected only the old scratch document, got %+v", plan.Candidates)
+	}
+	if got := plan.Candidates[0]; got.Size != 40 || got.Chunks != 1 || got.Entities != 1 {
+		t.Errorf("Expected size 40, 1 chunk and 1 entity, got size %d, %d chunks and %d entities", got.Size, got.Chunks, got.Entities)
+	}
+	if plan.OrphanedEntities != 1 {
+		t.Errorf("Expected 1 orphaned entity, got %d", plan.OrphanedEntities)
+	}
+	store.Close()
+
+	readOnly, err := Open(dir, true)
+	if err != nil {
+		t.Fatalf("Failed to open store read-only: %v", err)
+	}
+	if _, err := readOnly.Prune(ctx, []string{"old"}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Expected ErrReadOnly, got %v", err)
+	}
+	readOnly.Close()
+
+	store, err = Open(dir, false)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer store.Close()
+	result, err := store.Prune(ctx, []string{"old"})
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if result != (PruneResult{Documents: 1, Chunks: 1, Entities: 1}) {
+		t.Errorf("Expected 1 document, 1 chunk and 1 entity removed, got %+v", result)
+	}
+	stats, err := store.Stats(ctx)
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.Documents != 2 || stats.Chunks != 2 || stats.Entities != 2 {
+		t.Errorf("Expected 2 documents, chunks and entities left, got %+v", stats)
+	}
+}
@@ -0,0 +1,255 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// Meta keys describing how the stored vectors were produced.
+const (
+	metaEmbeddingProvider = "embedding_provider"
+	metaEmbeddingModel    = "embedding_model"
+	// metaEmbeddingPrecision is how new vectors are stored: "float32" or "int8".
+	metaEmbeddingPrecision = "embedding_precision"
+	// metaReindexProvider marks an unfinished reindex: chunks with a pending
+	// embedding already hold vectors from this provider, and metaReindexModel's
+	// model.
+	metaReindexProvider = "reindex_provider"
+	metaReindexModel    = "reindex_model"
+)
+
+// EmbeddingProvider returns the provider that produced the stored vectors, or ""
+// when it was never recorded.
+func (s *KuzuStore) EmbeddingProvider(ctx context.Context) (string, error) {
+	return s.meta(metaEmbeddingProvider)
+}
+
+// SetEmbeddingProvider records the provider that produced the stored vectors.
+func (s *KuzuStore) SetEmbeddingProvider(ctx context.Context, provider string) error {
+	return s.setMeta(metaEmbeddingProvider, provider)
+}
+
+// EmbeddingModel returns the model that produced the stored vectors, or "" when
+// it was never recorded.
+func (s *KuzuStore) EmbeddingModel(ctx context.Context) (string, error) {
+	return s.meta(metaEmbeddingModel)
+}
+
+// SetEmbeddingModel records the model that produced the stored vectors.
+func (s *KuzuStore) SetEmbeddingModel(ctx context.Context, model string) error {
+	return s.setMeta(metaEmbeddingModel, model)
+}
+
+// EmbeddingPrecision returns how new vectors are stored, or "" when it was never
+// recorded.
+func (s *KuzuStore) EmbeddingPrecision(ctx context.Context) (string, error) {
+	return s.meta(metaEmbeddingPrecision)
+}
+
+// SetEmbeddingPrecision records how new vectors are stored. Vectors stored
+// before keep their precision.
+func (s *KuzuStore) SetEmbeddingPrecision(ctx context.Context, precision string) error {
+	return s.setMeta(metaEmbeddingPrecision, precision)
+}
+
+// BeginReindex prepares to re-embed every chunk with provider's model into vectors
+// of dimensions, which may differ from the stored ones: the pending vectors are
+// staged in columns of their own size. An unfinished reindex with the same
+// provider, model and dimensions is resumed; any other is discarded.
+func (s *KuzuStore) BeginReindex(ctx context.Context, provider, model string, dimensions int) (resumed bool, err error) {
+	current, err := s.meta(metaReindexProvider)
+	if err != nil {
+		return false, err
+	}
+	currentModel, err := s.meta(metaReindexModel)
+	if err != nil {
+		return false, err
+	}
+	staged, err := s.columnDimensions("pending_embedding")
+	if err != nil {
+		return false, err
+	}
+	if current == provider && currentModel == model && staged == dimensions {
+		return true, nil
+	}
+	if current != "" {
+		slog.InfoContext(ctx, "discarding unfinished reindex", "provider", current, "model", currentModel)
+	}
+
+	statements := []string{"MATCH (c:Chunk) SET c.pending_embedding = NULL, c.pending_embedding_int8 = NULL, c.pending_embedding_scale = NULL, c.pending_embedding_offset = NULL"}
+	if staged != dimensions {
+		// Kuzu only drops a column safely between checkpoints, with no
+		// uncheckpointed writes to the table on either side.
+		statements = []string{
+			"CHECKPOINT",
+			"ALTER TABLE Chunk DROP pending_embedding",
+			fmt.Sprintf("ALTER TABLE Chunk ADD pending_embedding FLOAT[%d]", dimensions),
+			"ALTER TABLE Chunk DROP pending_embedding_int8",
+			fmt.Sprintf("ALTER TABLE Chunk ADD pending_embedding_int8 INT8[%d]", dimensions),
+			"CHECKPOINT",
+			"MATCH (c:Chunk) SET c.pending_embedding_scale = NULL, c.pending_embedding_offset = NULL",
+		}
+	}
+	for _, stmt := range statements {
+		if err := s.query(stmt); err != nil {
+			return false, fmt.Errorf("failed to reset pending embeddings: %w", err)
+		}
+	}
+	if err := s.setMeta(metaReindexModel, model); err != nil {
+		return false, err
+	}
+	return false, s.setMeta(metaReindexProvider, provider)
+}
+
+// missingEmbedding is the condition on chunk c of lacking a pending embedding, or
+// an embedding, of either precision.
+func missingEmbedding(pending bool) string {
+	if pending {
+		return "c.pending_embedding IS NULL AND c.pending_embedding_int8 IS NULL"
+	}
+	return "c.embedding IS NULL AND c.embedding_int8 IS NULL"
+}
+
+// ChunksToEmbed returns up to limit chunks with IDs after the given one, in ID
+// order. With pending set it returns chunks still lacking a pending embedding for
+// the current reindex; otherwise chunks lacking an embedding altogether.
+func (s *KuzuStore) ChunksToEmbed(ctx context.Context, pending bool, after string, limit int) ([]Chunk, error) {
+	rows, err := s.rows(`MATCH (c:Chunk) WHERE `+missingEmbedding(pending)+` AND c.id > $after
+		RETURN c.id, c.content ORDER BY c.id LIMIT $limit`,
+		map[string]any{"after": after, "limit": int64(limit)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chunks to embed: %w", err)
+	}
+	chunks := make([]Chunk, 0, len(rows))
+	for _, row := range rows {
+		chunks = append(chunks, Chunk{ID: asString(row[0]), Content: asString(row[1])})
+	}
+	return chunks, nil
+}
+
+// CountChunksToEmbed returns how many chunks ChunksToEmbed would return in total.
+func (s *KuzuStore) CountChunksToEmbed(ctx context.Context, pending bool) (int, error) {
+	rows, err := s.rows("MATCH (c:Chunk) WHERE "+missingEmbedding(pending)+" RETURN count(c)", nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count chunks to embed: %w", err)
+	}
+	return int(asInt64(rows[0][0])), nil
+}
+
+// SaveEmbeddings writes the vectors of a batch of chunks in one transaction, as
+// floats or, for chunks with an Int8Embedding, as int8 values, into the pending
+// columns when pending is set.
+func (s *KuzuStore) SaveEmbeddings(ctx context.Context, chunks []Chunk, pending bool) (err error) {
+	prefix := ""
+	if pending {
+		prefix = "pending_"
+	}
+	if err := s.query("BEGIN TRANSACTION"); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			s.query("ROLLBACK")
+		}
+	}()
+	for _, chunk := range chunks {
+		stmt := fmt.Sprintf("MATCH (c:Chunk {id: $id}) SET c.%sembedding = $embedding", prefix)
+		params := map[string]any{"id": chunk.ID, "embedding": chunk.Embedding}
+		if chunk.Int8Embedding != nil {
+			stmt = fmt.Sprintf("MATCH (c:Chunk {id: $id}) SET c.%[1]sembedding_int8 = $embedding, c.%[1]sembedding_scale = $scale, c.%[1]sembedding_offset = $offset", prefix)
+			params["embedding"], params["scale"], params["offset"] = chunk.Int8Embedding, chunk.EmbeddingScale, chunk.EmbeddingOffset
+		}
+		if err := s.execute(stmt, params); err != nil {
+			return fmt.Errorf("failed to save embedding for chunk %s: %w", chunk.ID, err)
+		}
+	}
+	return s.query("COMMIT")
+}
+
+// FinishReindex swaps every pending embedding into place and records the reindex
+// provider and model as those of the stored vectors, atomically. A chunk without
+// a pending embedding is left without a vector rather than keep one of the old
+// model.
+//
+// When the pending vectors are of another size, the embedding columns that
+// SimilaritySearch scans are first rebuilt at it. Kuzu cannot alter a table
+// within a transaction, so should the swap fail after the rebuild the memory
+// graph holds no vectors until the reindex is resumed, which swaps in the staged
+// ones again.
+func (s *KuzuStore) FinishReindex(ctx context.Context) (err error) {
+	provider, err := s.meta(metaReindexProvider)
+	if err != nil {
+		return err
+	}
+	if provider == "" {
+		return fmt.Errorf("no reindex in progress")
+	}
+	model, err := s.meta(metaReindexModel)
+	if err != nil {
+		return err
+	}
+	dimensions, err := s.columnDimensions("pending_embedding")
+	if err != nil {
+		return err
+	}
+	if dimensions != s.dimensions {
+		// As in BeginReindex, drop the columns between checkpoints.
+		for _, stmt := range []string{
+			"CHECKPOINT",
+			"ALTER TABLE Chunk DROP embedding",
+			fmt.Sprintf("ALTER TABLE Chunk ADD embedding FLOAT[%d]", dimensions),
+			"ALTER TABLE Chunk DROP embedding_int8",
+			fmt.Sprintf("ALTER TABLE Chunk ADD embedding_int8 INT8[%d]", dimensions),
+			"CHECKPOINT",
+		} {
+			if err := s.query(stmt); err != nil {
+				return fmt.Errorf("failed to resize the embedding columns to %d dimensions: %w", dimensions, err)
+			}
+		}
+		s.dimensions = dimensions
+	}
+
+	if err := s.query("BEGIN TRANSACTION"); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			s.query("ROLLBACK")
+		}
+	}()
+	if err := s.execute(`MATCH (c:Chunk)
+		SET c.embedding = c.pending_embedding, c.embedding_int8 = c.pending_embedding_int8,
+			c.embedding_scale = c.pending_embedding_scale, c.embedding_offset = c.pending_embedding_offset,
+			c.pending_embedding = NULL, c.pending_embedding_int8 = NULL, c.pending_embedding_scale = NULL, c.pending_embedding_offset = NULL`, nil); err != nil {
+		return fmt.Errorf("failed to swap embeddings: %w", err)
+	}
+	if err := s.setMeta(metaEmbeddingProvider, provider); err != nil {
+		return err
+	}
+	if err := s.setMeta(metaEmbeddingModel, model); err != nil {
+		return err
+	}
+	if err := s.execute("MATCH (m:Meta) WHERE m.key IN [$provider, $model] DELETE m", map[string]any{"provider": metaReindexProvider, "model": metaReindexModel}); err != nil {
+		return fmt.Errorf("failed to clear reindex checkpoint: %w", err)
+	}
+	return s.query("COMMIT")
+}
+
+func (s *KuzuStore) meta(key string) (string, error) {
+	rows, err := s.rows("MATCH (m:Meta {key: $key}) RETURN m.value", map[string]any{"key": key})
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	if len(rows) == 0 {
+		return "", nil
+	}
+	return asString(rows[0][0]), nil
+}
+
+func (s *KuzuStore) setMeta(key, value string) error {
+	if err := s.execute("MERGE (m:Meta {key: $key}) SET m.value = $value", map[string]any{"key": key, "value": value}); err != nil {
+		return fmt.Errorf("failed to record %s: %w", key, err)
+	}
+	return nil
+}
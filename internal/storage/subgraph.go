@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Graph is a set of entities and the relationships between them, sorted by name so
+// that exports are stable between runs.
+type Graph struct {
+	Nodes []Entity
+	Edges []Relationship
+}
+
+// Subgraph returns the entities within hops relationships of the named entity (or
+// alias), ignoring edge direction, together with every relationship among them.
+func (s *KuzuStore) Subgraph(ctx context.Context, name string, hops int) (*Graph, error) {
+	detail, err := s.GetEntity(ctx, name, 1)
+	if err != nil {
+		return nil, err
+	}
+	if detail == nil {
+		return nil, fmt.Errorf("entity %q not found", name)
+	}
+
+	nodes := map[string]Entity{detail.Name: detail.Entity}
+	frontier := []string{detail.Name}
+	for hop := 0; hop < hops && len(frontier) > 0; hop++ {
+		rows, err := s.rows(`MATCH (a:Entity)-[:RELATED]-(b:Entity) WHERE list_contains($names, a.name)
+			RETURN DISTINCT b.name, b.type, b.aliases`, map[string]any{"names": frontier})
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand subgraph: %w", err)
+		}
+		frontier = nil
+		for _, row := range rows {
+			entity := Entity{Name: asString(row[0]), Type: asString(row[1]), Aliases: asStrings(row[2])}
+			if _, ok := nodes[entity.Name]; !ok {
+				nodes[entity.Name] = entity
+				frontier = append(frontier, entity.Name)
+			}
+		}
+	}
+
+	names := make([]string, 0, len(nodes))
+	for name := range nodes {
+		names = append(names, name)
+	}
+	rows, err := s.rows(`MATCH (a:Entity)-[r:RELATED]->(b:Entity)
+		WHERE list_contains($names, a.name) AND list_contains($names, b.name)
+		RETURN a.name, r.predicate, b.name`, map[string]any{"names": names})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subgraph relationships: %w", err)
+	}
+
+	graph := &Graph{}
+	for _, entity := range nodes {
+		graph.Nodes = append(graph.Nodes, entity)
+	}
+	for _, row := range rows {
+		graph.Edges = append(graph.Edges, Relationship{Subject: asString(row[0]), Predicate: asString(row[1]), Object: asString(row[2])})
+	}
+	graph.sort()
+	return graph, nil
+}
+
+// FullGraph returns every entity and relationship in the memory graph.
+func (s *KuzuStore) FullGraph(ctx context.Context) (*Graph, error) {
+	rows, err := s.rows("MATCH (e:Entity) RETURN e.name, e.type, e.aliases", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list entities: %w", err)
+	}
+	graph := &Graph{}
+	for _, row := range rows {
+		graph.Nodes = append(graph.Nodes, Entity{Name: asString(row[0]), Type: asString(row[1]), Aliases: asStrings(row[2])})
+	}
+
+	rows, err = s.rows("MATCH (a:Entity)-[r:RELATED]->(b:Entity) RETURN a.name, r.predicate, b.name", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list relationships: %w", err)
+	}
+	for _, row := range rows {
+		graph.Edges = append(graph.Edges, Relationship{Subject: asString(row[0]), Predicate: asString(row[1]), Object: asString(row[2])})
+	}
+	graph.sort()
+	return graph, nil
+}
+
+// EntityCount returns the number of entities in the memory graph.
+func (s *KuzuStore) EntityCount(ctx context.Context) (int, error) {
+	rows, err := s.rows("MATCH (e:Entity) RETURN count(e)", nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count entities: %w", err)
+	}
+	return int(asInt64(rows[0][0])), nil
+}
+
+func (g *Graph) sort() {
+	sort.Slice(g.Nodes, func(i, j int) bool { return g.Nodes[i].Name < g.Nodes[j].Name })
+	sort.Slice(g.Edges, func(i, j int) bool {
+		a, b := g.Edges[i], g.Edges[j]
+		if a.Subject != b.Subject {
+			return a.Subject < b.Subject
+		}
+		if a.Object != b.Object {
+			return a.Object < b.Object
+		}
+		return a.Predicate < b.Predicate
+	})
+}
+
+// MentionedEntities maps each of the chunks to the names of the entities it mentions.
+func (s *KuzuStore) MentionedEntities(ctx context.Context, chunkIDs []string) (map[string][]string, error) {
+	mentions := make(map[string][]string)
+	if len(chunkIDs) == 0 {
+		return mentions, nil
+	}
+	rows, err := s.rows(`MATCH (c:Chunk)-[:MENTIONS]->(e:Entity) WHERE list_contains($ids, c.id)
+		RETURN c.id, e.name ORDER BY c.id, e.name`, map[string]any{"ids": chunkIDs})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mentioned entities: %w", err)
+	}
+	for _, row := range rows {
+		id := asString(row[0])
+		mentions[id] = append(mentions[id], asString(row[1]))
+	}
+	return mentions, nil
+}
+
+// RelatedEntities maps each of the named entities to the entities one relationship
+// away from it, ignoring edge direction.
+func (s *KuzuStore) RelatedEntities(ctx context.Context, names []string) (map[string][]string, error) {
+	related := make(map[string][]string)
+	if len(names) == 0 {
+		return related, nil
+	}
+	rows, err := s.rows(`MATCH (a:Entity)-[:RELATED]-(b:Entity) WHERE list_contains($names, a.name) AND a.name <> b.name
+		RETURN DISTINCT a.name, b.name ORDER BY a.name, b.name`, map[string]any{"names": names})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get related entities: %w", err)
+	}
+	for _, row := range rows {
+		name := asString(row[0])
+		related[name] = append(related[name], asString(row[1]))
+	}
+	return related, nil
+}
+
+// MentioningChunks returns up to limit chunks matching filter that mention the named
+// entity, those mentioning the most entities first. filter.MinScore does not apply.
+func (s *KuzuStore) MentioningChunks(ctx context.Context, name string, limit int, filter ChunkFilter) ([]ScoredChunk, error) {
+	params := map[string]any{"name": name, "limit": int64(limit)}
+	where := ""
+	if conditions := filter.conditions(params); conditions != "" {
+		where = "WHERE " + conditions
+	}
+	rows, err := s.rows(`MATCH (d:Document)-[:HAS_CHUNK]->(c:Chunk)-[:MENTIONS]->(e:Entity {name: $name}) `+where+`
+		OPTIONAL MATCH (c)-[:MENTIONS]->(other:Entity)
+		WITH d, c, count(other) AS entities
+		RETURN c.id, c.content, c.idx, c.start_offset, c.end_offset, d.id, d.source, d.collection, d.ingested_at, c.page
+		ORDER BY entities DESC, d.source, c.idx LIMIT $limit`, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mentioning chunks: %w", err)
+	}
+	chunks := make([]ScoredChunk, 0, len(rows))
+	for _, row := range rows {
+		chunks = append(chunks, ScoredChunk{
+			Chunk: Chunk{
+				ID:          asString(row[0]),
+				Content:     asString(row[1]),
+				Index:       int(asInt64(row[2])),
+				StartOffset: int(asInt64(row[3])),
+				EndOffset:   int(asInt64(row[4])),
+				Page:        int(asInt64(row[9])),
+				DocumentID:  asString(row[5]),
+			},
+			Source:     asString(row[6]),
+			Collection: asString(row[7]),
+			IngestedAt: asTime(row[8]),
+		})
+	}
+	return chunks, nil
+}
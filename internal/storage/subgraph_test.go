@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestGraphLookups_ForExpansion(t *testing.T) {
+	store, err := Open(t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+
+	chunks := []Chunk{
+		{ID: "doc-0", Content: "Kuzu Inc builds KuzuDB.", Entities: []Entity{{Name: "Kuzu Inc", Type: "ORG"}, {Name: "KuzuDB", Type: "PRODUCT"}},
+			Relationships: []Relationship{{Subject: "Kuzu Inc", Predicate: "builds", Object: "KuzuDB"}}},
+		{ID: "doc-1", Content: "KuzuDB speaks Cypher.", Entities: []Entity{{Name: "KuzuDB", Type: "PRODUCT"}},
+			Relationships: []Relationship{{Subject: "KuzuDB", Predicate: "speaks", Object: "Cypher"}}},
+	}
+	for i := range chunks {
+		chunks[i].Index = i
+		chunks[i].Embedding = make([]float32, EmbeddingDimensions)
+	}
+	if err := store.SaveDocument(ctx, Document{ID: "doc", Source: "kuzu.md", Collection: "notes"}, chunks); err != nil {
+		t.Fatalf("Failed to save document: %v", err)
+	}
+
+	mentions, err := store.MentionedEntities(ctx, []string{"doc-0", "doc-1", "missing"})
+	if err != nil {
+		t.Fatalf("MentionedEntities failed: %v", err)
+	}
+	expectedMentions := map[string][]string{"doc-0": {"Kuzu Inc", "KuzuDB"}, "doc-1": {"KuzuDB"}}
+	if !reflect.DeepEqual(mentions, expectedMentions) {
+		t.Errorf("Expected mentions %v, got %v", expectedMentions, mentions)
+	}
+
+	related, err := store.RelatedEntities(ctx, []string{"KuzuDB"})
+	if err != nil {
+		t.Fatalf("RelatedEntities failed: %v", err)
+	}
+	if expected := map[string][]string{"KuzuDB": {"Cypher", "Kuzu Inc"}}; !reflect.DeepEqual(related, expected) {
+		t.Errorf("Expected related entities %v, got %v", expected, related)
+	}
+
+	mentioning, err := store.MentioningChunks(ctx, "KuzuDB", 1, ChunkFilter{})
+	if err != nil {
+		t.Fatalf("MentioningChunks failed: %v", err)
+	}
+	if len(mentioning) != 1 || mentioning[0].ID != "doc-0" || mentioning[0].Collection != "notes" {
+		t.Errorf("Expected doc-0, which mentions the most entities, got %+v", mentioning)
+	}
+}